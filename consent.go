@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+)
+
+// consentTerms are the terms users accept on the consent page before they
+// access the upstreams.
+type consentTerms struct {
+	// version is the version of the terms, recorded in the sessions of the
+	// users who accepted them.
+	version string
+
+	// store persists the version of the terms each user accepted, if any.
+	store sessionsapi.ConsentStore
+
+	// secret signs the tokens of the consent forms. It is the cookie secret
+	// of the options, which is wiped once the configuration is replaced.
+	secret []byte
+}
+
+// buildConsentTerms builds the consent terms from the options, or nil when
+// users are not asked to accept terms.
+func buildConsentTerms(opts *options.Options) (*consentTerms, error) {
+	if opts.Consent.TermsFile == "" {
+		return nil, nil
+	}
+
+	terms := &consentTerms{
+		version: opts.Consent.Version,
+		secret:  opts.Cookie.Secret,
+	}
+	if opts.Consent.Persist {
+		store, err := sessions.NewConsentStore(&opts.Session, opts.Cookie.Name+"-consent-")
+		if err != nil {
+			return nil, err
+		}
+		terms.store = store
+	}
+	return terms, nil
+}
+
+// token returns the token of the consent form of the user, so that the terms
+// cannot be accepted by a cross-site request on their behalf.
+// NOTE: Error checking (G104) is purposefully skipped:
+// `hash.Hash` interface's `Write` has an error signature, but
+// `hmac.hmac.Write` does not use it.
+/* #nosec G104 */
+func (c *consentTerms) token(session *sessionsapi.SessionState) string {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte("consent|" + c.version + "|" + sessionUser(session)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sessionUser returns the user of a session, who consents and signs out
+// everywhere: its email, or its user when it has no email.
+func sessionUser(session *sessionsapi.SessionState) string {
+	if session.Email != "" {
+		return session.Email
+	}
+	return session.User
+}
+
+// checkConsent returns ErrNeedsConsent when the user of the session has not
+// accepted the current version of the consent terms.
+// A version the user accepted in an earlier session is saved in the session,
+// so that the store is not queried again.
+func (p *OAuthProxy) checkConsent(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
+	if p.consent == nil || session.ConsentVersion == p.consent.version {
+		return nil
+	}
+
+	accepted, err := p.loadConsent(req.Context(), session)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrNeedsConsent
+	}
+	return p.SaveSession(rw, req, session)
+}
+
+// loadConsent returns whether the user of the session accepted the current
+// version of the consent terms, in the session or in an earlier session
+// whose consent was persisted, which is then recorded in the session.
+func (p *OAuthProxy) loadConsent(ctx context.Context, session *sessionsapi.SessionState) (bool, error) {
+	if p.consent == nil || session.ConsentVersion == p.consent.version {
+		return true, nil
+	}
+	if p.consent.store == nil {
+		return false, nil
+	}
+
+	version, err := p.consent.store.AcceptedVersion(ctx, sessionUser(session))
+	if err != nil {
+		return false, fmt.Errorf("error loading the consent of %s: %v", sessionUser(session), err)
+	}
+	if version != p.consent.version {
+		return false, nil
+	}
+	session.ConsentVersion = version
+	return true, nil
+}
+
+// consentURL returns the URL of the consent page, which redirects to the
+// appRedirect once the terms are accepted.
+func (p *OAuthProxy) consentURL(appRedirect string) string {
+	return p.ProxyPrefix + consentPath + "?" + url.Values{"rd": {appRedirect}}.Encode()
+}
+
+// Consent serves the consent page, and records that the user accepted the
+// terms when its form is submitted.
+func (p *OAuthProxy) Consent(rw http.ResponseWriter, req *http.Request) {
+	if p.consent == nil {
+		p.ErrorPage(rw, req, http.StatusNotFound, "consent terms are not configured")
+		return
+	}
+
+	session := middlewareapi.GetRequestScope(req).Session
+	if session == nil {
+		signInURL, err := p.signInURL(req)
+		if err != nil {
+			logger.Errorf("Error obtaining redirect: %v", err)
+			p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+			return
+		}
+		http.Redirect(rw, req, signInURL, http.StatusFound)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	appRedirect := req.Form.Get("rd")
+	if !p.redirectValidator.IsValidRedirect(appRedirect) {
+		appRedirect = "/"
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		p.pageWriter.WriteConsentPage(rw, req, pagewriter.ConsentPageOpts{
+			RedirectURL: appRedirect,
+			Token:       p.consent.token(session),
+		})
+	case http.MethodPost:
+		if !hmac.Equal([]byte(req.PostForm.Get("token")), []byte(p.consent.token(session))) {
+			p.ErrorPage(rw, req, http.StatusForbidden, "invalid consent token")
+			return
+		}
+
+		session.ConsentVersion = p.consent.version
+		if p.consent.store != nil {
+			if err := p.consent.store.Accept(req.Context(), sessionUser(session), p.consent.version); err != nil {
+				logger.Errorf("Error saving the consent of %s: %v", sessionUser(session), err)
+				p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if err := p.SaveSession(rw, req, session); err != nil {
+			logger.Errorf("Error saving session: %v", err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+		logger.PrintAuthf(sessionUser(session), req, logger.AuthSuccess, "Accepted the consent terms version %s", p.consent.version)
+		http.Redirect(rw, req, appRedirect, http.StatusFound)
+	default:
+		p.ErrorPage(rw, req, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
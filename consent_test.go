@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsent(t *testing.T) {
+	termsFile, err := ioutil.TempFile("", "oauth2-proxy-consent-test-*.html")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(termsFile.Name()) })
+	_, err = termsFile.WriteString("<p>Be nice.</p>")
+	require.NoError(t, err)
+	require.NoError(t, termsFile.Close())
+
+	opts := baseTestOptions()
+	opts.Consent.TermsFile = termsFile.Name()
+	opts.Consent.Version = "2022-01"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+	proxy.basicAuthValidator = AlwaysSuccessfulValidator{}
+
+	sessionCookie := func(rw *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rw.Result().Cookies() {
+			if c.Name == opts.Cookie.Name {
+				return c
+			}
+		}
+		t.Fatal("no session cookie was set")
+		return nil
+	}
+
+	formData := url.Values{"username": {"someuser"}, "password": {"somepass"}, "rd": {"/foo"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(formData.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/oauth2/consent?rd=%2Ffoo", rw.Header().Get("Location"))
+	cookie := sessionCookie(rw)
+
+	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.AddCookie(cookie)
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/oauth2/consent?rd=%2Ffoo", rw.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/oauth2/consent?rd=%2Ffoo", nil)
+	req.AddCookie(cookie)
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "<p>Be nice.</p>")
+
+	accept := func(token string) *httptest.ResponseRecorder {
+		formData := url.Values{"rd": {"/foo"}, "token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/consent", strings.NewReader(formData.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	assert.Equal(t, http.StatusForbidden, accept("forged").Code)
+
+	rw = accept(proxy.consent.token(&sessions.SessionState{User: "someuser"}))
+	require.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/foo", rw.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/oauth2/auth", nil)
+	req.AddCookie(sessionCookie(rw))
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+}
+
+func TestConsentNotConfigured(t *testing.T) {
+	opts := baseTestOptions()
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/consent", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/watcher"
+)
+
+const (
+	// customTemplatesLocalesDir is the folder of the message catalogs of the
+	// custom templates directory.
+	customTemplatesLocalesDir = "locales"
+
+	// customTemplatesPartialsDir is the folder of the partials of the custom
+	// templates directory, which are included by its templates.
+	customTemplatesPartialsDir = "partials"
+)
+
+// watchCustomTemplates reloads the configuration when the files of the custom
+// templates directory, or of its folders of message catalogs and partials,
+// change, until done is closed.
+// The static files are served from the disk, so they are not watched.
+func watchCustomTemplates(dir string, done <-chan bool, reload func()) error {
+	dirs := []string{dir}
+	for _, sub := range []string{customTemplatesLocalesDir, customTemplatesPartialsDir} {
+		subDir := filepath.Join(dir, sub)
+		if info, err := os.Stat(subDir); err == nil && info.IsDir() {
+			dirs = append(dirs, subDir)
+		}
+	}
+
+	changed := make(chan struct{}, 1)
+	for _, d := range dirs {
+		if err := watcher.WatchDirForUpdates(d, done, notifyChanged(changed)); err != nil {
+			return err
+		}
+	}
+
+	go reloadWhenSettled(changed, done, reload)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchCustomTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth2-proxy-custom-templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	locales := filepath.Join(dir, customTemplatesLocalesDir)
+	require.NoError(t, os.Mkdir(locales, 0700))
+	partials := filepath.Join(dir, customTemplatesPartialsDir)
+	require.NoError(t, os.Mkdir(partials, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sign_in.html"), []byte("sign in"), 0600))
+
+	reloads := make(chan struct{}, 2)
+	done := make(chan bool)
+	defer close(done)
+	require.NoError(t, watchCustomTemplates(dir, done, func() { reloads <- struct{}{} }))
+
+	for _, file := range []string{filepath.Join(dir, "sign_in.html"), filepath.Join(locales, "de.json"), filepath.Join(partials, "header.html")} {
+		require.NoError(t, ioutil.WriteFile(file, []byte("{}"), 0600))
+
+		select {
+		case <-reloads:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the configuration was not reloaded after %s changed", file)
+		}
+	}
+}
+
+func TestCustomTemplatesStaticFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth2-proxy-custom-templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	static := filepath.Join(dir, "static")
+	require.NoError(t, os.Mkdir(static, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(static, "logo.svg"), []byte("<svg></svg>"), 0600))
+
+	opts := baseTestOptions()
+	opts.Templates.Path = dir
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/static/logo.svg", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "<svg></svg>", rw.Body.String())
+	assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"))
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/static/missing.svg", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// debugSignals toggle debug logging for every component.
+var debugSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os"
+
+// debugSignals toggle debug logging for every component. Windows has no
+// signal for this, so debug logging can only be changed on the metrics server.
+var debugSignals []os.Signal
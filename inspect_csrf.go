@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/spf13/pflag"
+)
+
+// inspectCSRFCommand is the name of the command that prints the state of a
+// CSRF cookie.
+const inspectCSRFCommand = "inspect-csrf"
+
+// runInspectCSRF decrypts the CSRF cookie value given by the arguments with
+// the cookie secret, prints its claims and returns the exit code.
+func runInspectCSRF(args []string, stdout, stderr io.Writer) int {
+	flagSet := pflag.NewFlagSet("oauth2-proxy inspect-csrf", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	secret := flagSet.String("cookie-secret", os.Getenv("OAUTH2_PROXY_COOKIE_SECRET"), "the seed string for secure cookies")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if flagSet.NArg() != 1 || *secret == "" {
+		fmt.Fprintln(stderr, "usage: oauth2-proxy inspect-csrf --cookie-secret <secret> <cookie value>")
+		return 2
+	}
+
+	claims, err := cookies.DecodeCSRFClaims(flagSet.Arg(0), []byte(*secret))
+	if err != nil {
+		fmt.Fprintf(stderr, "could not decode CSRF cookie: %v\n", err)
+		return 1
+	}
+
+	data, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "could not marshal CSRF claims: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(data))
+	return 0
+}
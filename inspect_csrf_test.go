@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectCSRFCommand(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	opts := options.NewOptions()
+	opts.Cookie.Secret = []byte(secret)
+	csrf, err := cookies.NewCSRF(&opts.Cookie, "verifier")
+	require.NoError(t, err)
+	csrf.SetRedirect("/foo")
+	cookie, err := csrf.SetCookie(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/oauth2/start", nil))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		args         []string
+		expectedCode int
+	}{
+		"valid cookie": {
+			args:         []string{"--cookie-secret", secret, cookie.Value},
+			expectedCode: 0,
+		},
+		"wrong secret": {
+			args:         []string{"--cookie-secret", "fedcba9876543210fedcba9876543210", cookie.Value},
+			expectedCode: 1,
+		},
+		"no cookie value": {
+			args:         []string{"--cookie-secret", secret},
+			expectedCode: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			require.Equal(t, tc.expectedCode, runInspectCSRF(tc.args, stdout, stderr))
+			if tc.expectedCode != 0 {
+				assert.NotEmpty(t, stderr.String())
+				return
+			}
+
+			claims := cookies.CSRFClaims{}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &claims))
+			assert.NotEmpty(t, claims.State)
+			assert.Equal(t, "verifier", claims.CodeVerifier)
+			assert.Equal(t, "/foo", claims.Redirect)
+		})
+	}
+}
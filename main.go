@@ -1,21 +1,46 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
+	"reflect"
 	"runtime"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/configsource"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/controller"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/fips"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/secrets"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/statsd"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/watcher"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
 )
 
 func main() {
 	logger.SetFlags(logger.Lshortfile)
+	audit.ProductVersion = VERSION
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case validateCommand:
+			os.Exit(runValidate(os.Args[2:], os.Stdout))
+		case schemaCommand:
+			os.Exit(runSchema(os.Args[2:], os.Stdout, os.Stderr))
+		case inspectCSRFCommand:
+			os.Exit(runInspectCSRF(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
 
 	configFlagSet := pflag.NewFlagSet("oauth2-proxy", pflag.ContinueOnError)
 
@@ -24,12 +49,18 @@ func main() {
 	configFlagSet.ParseErrorsWhitelist.UnknownFlags = true
 
 	config := configFlagSet.String("config", "", "path to config file")
-	alphaConfig := configFlagSet.String("alpha-config", "", "path to alpha config file (use at your own risk - the structure in this config file may change between minor releases)")
+	alphaConfig := configFlagSet.String("alpha-config", "", "path or https://, s3:// or gs:// URL of alpha config file (use at your own risk - the structure in this config file may change between minor releases)")
 	convertConfig := configFlagSet.Bool("convert-config-to-alpha", false, "if true, the proxy will load configuration as normal and convert existing configuration to the alpha config structure, and print it to stdout")
+	convertConfigToLegacy := configFlagSet.Bool("convert-config-to-legacy", false, "if true, the proxy will load the alpha configuration and convert it back to the legacy config structure, and print it to stdout in toml format")
 	showVersion := configFlagSet.Bool("version", false, "print version string")
+	watchConfig := configFlagSet.Bool("watch-config", false, "reload the configuration when the config or alpha config file changes, as well as on SIGHUP")
 	configFlagSet.Parse(os.Args[1:])
 
 	if *showVersion {
+		if fips.ModuleEnabled() {
+			fmt.Printf("oauth2-proxy %s (built with %s, FIPS module: %s)\n", VERSION, runtime.Version(), fips.Module)
+			return
+		}
 		fmt.Printf("oauth2-proxy %s (built with %s)\n", VERSION, runtime.Version())
 		return
 	}
@@ -37,6 +68,9 @@ func main() {
 	if *convertConfig && *alphaConfig != "" {
 		logger.Fatal("cannot use alpha-config and convert-config-to-alpha together")
 	}
+	if *convertConfigToLegacy && *alphaConfig == "" {
+		logger.Fatal("convert-config-to-legacy requires alpha-config")
+	}
 
 	opts, err := loadConfiguration(*config, *alphaConfig, configFlagSet, os.Args[1:])
 	if err != nil {
@@ -50,32 +84,237 @@ func main() {
 		return
 	}
 
+	if *convertConfigToLegacy {
+		if err := printLegacyConfig(opts, os.Stdout); err != nil {
+			logger.Fatalf("ERROR: could not convert config: %v", err)
+		}
+		return
+	}
+
+	if err := resolveSecrets(opts); err != nil {
+		logger.Fatalf("ERROR: %v", err)
+	}
+	if err := applyKubernetesController(opts); err != nil {
+		logger.Fatalf("ERROR: %v", err)
+	}
+
 	if err = validation.Validate(opts); err != nil {
 		logger.Fatalf("%s", err)
 	}
+	if fips.Enabled(opts.FIPSMode) {
+		encryption.SetFIPSMode(true)
+		logger.Printf("FIPS mode enabled (module: %s, active: %t)", fips.Module, fips.ModuleEnabled())
+	}
 
-	validator := NewValidator(opts.EmailDomains, opts.AuthenticatedEmailsFile)
+	validatorDone := make(chan bool)
+	validator := newValidatorImpl(opts.EmailDomains, opts.AuthenticatedEmailsFile, validatorDone, func() {})
 	oauthproxy, err := NewOAuthProxy(opts, validator)
 	if err != nil {
 		logger.Fatalf("ERROR: Failed to initialise OAuth2 Proxy: %v", err)
 	}
+	sinks, err := audit.NewSinks(opts.Logging)
+	if err != nil {
+		logger.Fatalf("ERROR: %v", err)
+	}
+	logger.SetAuthSinks(sinks...)
+
+	reload := func() {
+		if err := oauthproxy.ReloadConfig(); err != nil {
+			logger.Errorf("ERROR: %v", err)
+		}
+	}
+
+	if opts.WatchSecretFiles {
+		if err := watchSecretFiles(secretFiles(opts), validatorDone, reload); err != nil {
+			logger.Fatalf("ERROR: %v", err)
+		}
+	}
+	if opts.Templates.Watch {
+		if err := watchCustomTemplates(opts.Templates.Path, validatorDone, reload); err != nil {
+			logger.Fatalf("ERROR: %v", err)
+		}
+	}
+
+	oauthproxy.SetReloadFunc(func() (*OAuthProxy, error) {
+		reloaded, err := loadConfiguration(*config, *alphaConfig, configFlagSet, os.Args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if err := resolveSecrets(reloaded); err != nil {
+			return nil, err
+		}
+		if err := applyKubernetesController(reloaded); err != nil {
+			return nil, err
+		}
+		if err := validation.Validate(reloaded); err != nil {
+			return nil, err
+		}
+		warnRestartRequired(opts, reloaded)
+
+		done := make(chan bool)
+		validator := newValidatorImpl(reloaded.EmailDomains, reloaded.AuthenticatedEmailsFile, done, func() {})
+		p, err := newOAuthProxy(reloaded, validator)
+		if err != nil {
+			close(done)
+			return nil, err
+		}
+		if reloaded.WatchSecretFiles {
+			if err := watchSecretFiles(secretFiles(reloaded), done, reload); err != nil {
+				close(done)
+				return nil, err
+			}
+		}
+		if reloaded.Templates.Watch {
+			if err := watchCustomTemplates(reloaded.Templates.Path, done, reload); err != nil {
+				close(done)
+				return nil, err
+			}
+		}
+
+		sinks, err := audit.NewSinks(reloaded.Logging)
+		if err != nil {
+			close(done)
+			return nil, err
+		}
+
+		// Replace the auth sinks, which closes those of the previous
+		// configuration, and stop watching the authenticated emails file,
+		// secret files and custom templates of the previous configuration
+		logger.SetAuthSinks(sinks...)
+		close(validatorDone)
+		validatorDone = done
+		return p, nil
+	})
+
+	if *watchConfig {
+		for _, path := range []string{*config, *alphaConfig} {
+			if path == "" || configsource.IsRemote(path) {
+				continue
+			}
+			if err := watcher.WatchFileForUpdates(path, nil, reload); err != nil {
+				logger.Fatalf("ERROR: %v", err)
+			}
+		}
+	}
+
+	if configsource.IsRemote(*alphaConfig) && opts.AlphaConfigPollInterval > 0 {
+		source, err := alphaConfigSource(*alphaConfig, opts)
+		if err != nil {
+			logger.Fatalf("ERROR: %v", err)
+		}
+		go source.Poll(context.Background(), opts.AlphaConfigPollInterval, reload)
+	}
+
+	if secrets.HasReferences() {
+		go secrets.Run(context.Background(), opts.SecretRefreshInterval, reload)
+	}
+
+	if opts.KubernetesController != nil {
+		c, err := controller.Get(*opts.KubernetesController)
+		if err != nil {
+			logger.Fatalf("ERROR: %v", err)
+		}
+		go c.Run(context.Background(), reload)
+	}
+
+	if opts.StatsD.Address != "" {
+		exporter, err := statsd.NewExporter(statsd.Config{
+			Address:       opts.StatsD.Address,
+			Prefix:        opts.StatsD.Prefix,
+			Tags:          opts.StatsD.Tags,
+			FlushInterval: opts.StatsD.FlushInterval,
+		}, prometheus.DefaultGatherer)
+		if err != nil {
+			logger.Fatalf("ERROR: %v", err)
+		}
+		go exporter.Run(context.Background())
+	}
 
 	rand.Seed(time.Now().UnixNano())
 
 	if err := oauthproxy.Start(); err != nil {
 		logger.Fatalf("ERROR: Failed to start OAuth2 Proxy: %v", err)
 	}
+
+	// Closing the auth sinks sends or keeps the events that are still queued
+	logger.SetAuthSinks()
+}
+
+// warnRestartRequired logs a warning when options that are only applied at
+// start up have changed in the reloaded configuration.
+func warnRestartRequired(opts, reloaded *options.Options) {
+	if !reflect.DeepEqual(withoutCertificates(opts.Server), withoutCertificates(reloaded.Server)) ||
+		!reflect.DeepEqual(withoutCertificates(opts.MetricsServer), withoutCertificates(reloaded.MetricsServer)) {
+		logger.Printf("WARNING: Changes to the server and metrics server options are not applied until the proxy is restarted")
+	}
+	if !reflect.DeepEqual(opts.KubernetesController, reloaded.KubernetesController) {
+		logger.Printf("WARNING: Changes to the kubernetes controller options are not watched until the proxy is restarted")
+	}
+	if opts.FIPSMode != reloaded.FIPSMode {
+		logger.Printf("WARNING: Changes to the FIPS mode of the servers are not applied until the proxy is restarted")
+	}
+	if !reflect.DeepEqual(opts.StatsD, reloaded.StatsD) {
+		logger.Printf("WARNING: Changes to the statsd options are not applied until the proxy is restarted")
+	}
+}
+
+// withoutCertificates returns the server options without their certificates,
+// which are replaced when the configuration is reloaded.
+func withoutCertificates(server options.Server) options.Server {
+	if server.TLS != nil {
+		tls := *server.TLS
+		tls.Key, tls.Cert, tls.AdditionalCertificates = nil, nil, nil
+		server.TLS = &tls
+	}
+	return server
+}
+
+// applyKubernetesController overlays the routes and providers of the
+// Kubernetes custom resources on the options, when the controller mode is
+// configured.
+// This is not applied by the validate command, so that the configuration can
+// be validated outside of the cluster.
+func applyKubernetesController(opts *options.Options) error {
+	if opts.KubernetesController == nil {
+		return nil
+	}
+
+	c, err := controller.Get(*opts.KubernetesController)
+	if err != nil {
+		return fmt.Errorf("failed to load kubernetes resources: %v", err)
+	}
+	c.Apply(opts)
+	return nil
 }
 
 // loadConfiguration will load in the user's configuration.
 // It will either load the alpha configuration (if alphaConfig is given)
 // or the legacy configuration.
+// References to secrets in secret stores are left unresolved, so that the
+// configuration can be converted without revealing the secrets.
 func loadConfiguration(config, alphaConfig string, extraFlags *pflag.FlagSet, args []string) (*options.Options, error) {
+	var opts *options.Options
+	var err error
 	if alphaConfig != "" {
 		logger.Printf("WARNING: You are using alpha configuration. The structure in this configuration file may change without notice. You MUST remove conflicting options from your existing configuration.")
-		return loadAlphaOptions(config, alphaConfig, extraFlags, args)
+		opts, err = loadAlphaOptions(config, alphaConfig, extraFlags, args)
+	} else {
+		opts, err = loadLegacyOptions(config, extraFlags, args)
 	}
-	return loadLegacyOptions(config, extraFlags, args)
+	if err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// resolveSecrets replaces the references to secrets in secret stores in the
+// options with the values of the secrets.
+// This must only be done when the configuration is served or validated.
+func resolveSecrets(opts *options.Options) error {
+	if err := secrets.ResolveReferences(context.Background(), opts); err != nil {
+		return fmt.Errorf("failed to resolve secrets: %v", err)
+	}
+	return nil
 }
 
 // loadLegacyOptions loads the old toml options using the legacy flagset
@@ -110,7 +349,7 @@ func loadAlphaOptions(config, alphaConfig string, extraFlags *pflag.FlagSet, arg
 	}
 
 	alphaOpts := &options.AlphaOptions{}
-	if err := options.LoadYAML(alphaConfig, alphaOpts); err != nil {
+	if err := loadAlphaConfig(alphaConfig, opts, alphaOpts); err != nil {
 		return nil, fmt.Errorf("failed to load alpha options: %v", err)
 	}
 
@@ -118,6 +357,34 @@ func loadAlphaOptions(config, alphaConfig string, extraFlags *pflag.FlagSet, arg
 	return opts, nil
 }
 
+// loadAlphaConfig loads the alpha configuration from the file, or fetches it
+// from the remote source when the alpha config is a URL.
+func loadAlphaConfig(alphaConfig string, opts *options.Options, into *options.AlphaOptions) error {
+	if !configsource.IsRemote(alphaConfig) {
+		return options.LoadYAML(alphaConfig, into)
+	}
+
+	source, err := alphaConfigSource(alphaConfig, opts)
+	if err != nil {
+		return err
+	}
+	data, _, err := source.Fetch(context.Background())
+	if err != nil {
+		return err
+	}
+	return options.LoadYAMLData(source.Name(), data, into)
+}
+
+// alphaConfigSource returns the remote source of the alpha configuration.
+// The source is shared by each load of the configuration so that reloads only
+// download the configuration when it has changed.
+func alphaConfigSource(alphaConfig string, opts *options.Options) (*configsource.Source, error) {
+	return configsource.GetSource(configsource.Options{
+		URL:           alphaConfig,
+		PublicKeyFile: opts.AlphaConfigPublicKeyFile,
+	})
+}
+
 // loadOptions loads the configuration using the old style format into the
 // core options.Options struct.
 // This means that none of the options that have been converted to alpha config
@@ -154,3 +421,33 @@ func printConvertedConfig(opts *options.Options) error {
 
 	return nil
 }
+
+// printLegacyConfig converts the loaded configuration back to the legacy
+// options and renders these in TOML format.
+// Options that have no legacy equivalent are listed in comments at the top of
+// the output so that they can be reviewed before the configuration is used.
+func printLegacyConfig(opts *options.Options, out io.Writer) error {
+	legacyOpts := options.NewLegacyOptions()
+	notes := legacyOpts.ExtractFrom(opts)
+
+	data, err := legacyOpts.MarshalTOML()
+	if err != nil {
+		return fmt.Errorf("unable to marshal config: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if len(notes) > 0 {
+		buf.WriteString("# The following options have no equivalent in the legacy configuration:\n")
+		for _, note := range notes {
+			fmt.Fprintf(buf, "#   - %s\n", note)
+		}
+		buf.WriteString("\n")
+	}
+	buf.Write(data)
+
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("unable to write output: %v", err)
+	}
+
+	return nil
+}
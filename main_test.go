@@ -78,6 +78,7 @@ providers:
     insecureSkipNonce: true
     audienceClaims: [aud]
     extraAudiences: []
+    keysRefreshInterval: 15m
   loginURLParameters:
   - name: approval_prompt
     default:
@@ -105,7 +106,7 @@ redirect_url="http://localhost:4180/oauth2/callback"
 		opts, err := options.NewLegacyOptions().ToOptions()
 		Expect(err).ToNot(HaveOccurred())
 
-		opts.Cookie.Secret = "OQINaROshtE9TcZkNAm-5Zs2Pv3xaWytBmc5W7sPX7w="
+		opts.Cookie.Secret = []byte("OQINaROshtE9TcZkNAm-5Zs2Pv3xaWytBmc5W7sPX7w=")
 		opts.EmailDomains = []string{"example.com"}
 		opts.Cookie.Secure = false
 		opts.RawRedirectURL = "http://localhost:4180/oauth2/callback"
@@ -146,18 +147,19 @@ redirect_url="http://localhost:4180/oauth2/callback"
 			options.Provider{
 				ID:           "google=oauth2-proxy",
 				Type:         "google",
-				ClientSecret: "b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK",
+				ClientSecret: []byte("b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK"),
 				ClientID:     "oauth2-proxy",
 				AzureConfig: options.AzureOptions{
 					Tenant: "common",
 				},
 				OIDCConfig: options.OIDCOptions{
-					GroupsClaim:       "groups",
-					EmailClaim:        "email",
-					UserIDClaim:       "email",
-					AudienceClaims:    []string{"aud"},
-					ExtraAudiences:    []string{},
-					InsecureSkipNonce: true,
+					GroupsClaim:         "groups",
+					EmailClaim:          "email",
+					UserIDClaim:         "email",
+					AudienceClaims:      []string{"aud"},
+					ExtraAudiences:      []string{},
+					InsecureSkipNonce:   true,
+					KeysRefreshInterval: durationPtr(options.DefaultOIDCKeysRefreshInterval),
 				},
 				LoginURLParameters: []options.LoginURLParameter{
 					{Name: "approval_prompt", Default: []string{"force"}},
@@ -238,6 +240,14 @@ redirect_url="http://localhost:4180/oauth2/callback"
 			alphaConfigContent: testAlphaConfig,
 			expectedOptions:    testExpectedOptions,
 		}),
+		Entry("with references to secrets", loadConfigurationTableInput{
+			configContent: testCoreConfig + strings.Replace(testLegacyConfig, "b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK", "vault:secret/data/oauth2-proxy#client_secret", 1),
+			expectedOptions: func() *options.Options {
+				opts := testExpectedOptions()
+				opts.Providers[0].ClientSecret = []byte("vault:secret/data/oauth2-proxy#client_secret")
+				return opts
+			},
+		}),
 		Entry("with bad legacy configuration", loadConfigurationTableInput{
 			configContent:   testCoreConfig + "unknown_field=\"something\"",
 			expectedOptions: func() *options.Options { return nil },
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -13,6 +16,7 @@ import (
 	"os/signal"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,20 +25,30 @@ import (
 	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	optionsutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/assertion"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/fips"
 	proxyhttp "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/http"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/matcher"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/systemd"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
@@ -44,13 +58,40 @@ const (
 	schemeHTTPS     = "https"
 	applicationJSON = "application/json"
 
-	robotsPath        = "/robots.txt"
-	signInPath        = "/sign_in"
-	signOutPath       = "/sign_out"
-	oauthStartPath    = "/start"
-	oauthCallbackPath = "/callback"
-	authOnlyPath      = "/auth"
-	userInfoPath      = "/userinfo"
+	// webSocketGUID is used to compute the Sec-WebSocket-Accept header when
+	// completing a WebSocket handshake.
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	robotsPath            = "/robots.txt"
+	signInPath            = "/sign_in"
+	signOutPath           = "/sign_out"
+	oauthStartPath        = "/start"
+	oauthCallbackPath     = "/callback"
+	authOnlyPath          = "/auth"
+	userInfoPath          = "/userinfo"
+	jwksPath              = "/jwks"
+	silentAuthPath        = "/silent"
+	consentPath           = "/consent"
+	signOutEverywherePath = "/sign_out_everywhere"
+	sessionPath           = "/session"
+	refreshPath           = "/refresh"
+	eventsPath            = "/events"
+	staticPath            = "/static"
+
+	// logLevelPath is served by the metrics server
+	logLevelPath = "/log-level"
+
+	// providerLookupCachePath is served by the metrics server to flush the
+	// provider lookup cache
+	providerLookupCachePath = "/provider-lookup-cache"
+
+	// providerPreferenceExpire is how long the provider users last signed
+	// in with is remembered
+	providerPreferenceExpire = 365 * 24 * time.Hour
+
+	// rememberMeParam is the parameter of the sign-in requests of users who
+	// chose to stay signed in, when cookie_remember_me is set
+	rememberMeParam = "remember_me"
 )
 
 var (
@@ -59,6 +100,10 @@ var (
 
 	// ErrAccessDenied means the user should receive a 401 Unauthorized response
 	ErrAccessDenied = errors.New("access denied")
+
+	// ErrNeedsConsent means the user should be redirected to the consent page
+	// to accept the consent terms
+	ErrNeedsConsent = errors.New("consent terms not accepted")
 )
 
 // allowedRoute manages method + path based allowlists
@@ -68,8 +113,16 @@ type allowedRoute struct {
 	pathRegex *regexp.Regexp
 }
 
-type apiRoute struct {
-	pathRegex *regexp.Regexp
+// routeAllowlist matches requests against the allowed routes.
+// The paths of the routes are matched as a set for each method, so that
+// requests are not matched against every route in turn. Negated routes
+// allow every path but their own, so they are matched in turn.
+type routeAllowlist struct {
+	// paths are the paths of the routes by method, with the routes of all
+	// methods under the empty method.
+	paths map[string]*matcher.RegexpSet
+
+	negated []allowedRoute
 }
 
 // OAuthProxy is the main authentication proxy
@@ -79,12 +132,22 @@ type OAuthProxy struct {
 
 	SignInPath string
 
-	allowedRoutes       []allowedRoute
-	apiRoutes           []apiRoute
+	allowedRoutes       *routeAllowlist
+	apiRoutes           *matcher.RegexpSet
+	redirectRoutes      *matcher.RegexpSet
 	redirectURL         *url.URL // the url to receive requests at
+	callbackURLs        *redirect.CallbackURLs
 	whitelistDomains    []string
 	provider            providers.Provider
+	providerID          string
+	providerClient      *http.Client
+	providerPreference  string // the cookie remembering the provider, if any
 	sessionStore        sessionsapi.SessionStore
+	replayCache         sessionsapi.ReplayCache
+	replayCacheTTL      time.Duration
+	jwtAssertionSigner  *assertion.Signer
+	signInChallenge     challenge.Challenge
+	redirectSigner      *redirect.Signer
 	ProxyPrefix         string
 	basicAuthValidator  basic.Validator
 	basicAuthGroups     []string
@@ -92,27 +155,95 @@ type OAuthProxy struct {
 	skipAuthPreflight   bool
 	skipJwtBearerTokens bool
 	forceJSONErrors     bool
+	negotiateUnauthn    bool
+	wsAuthCloseFrames   bool
+	silentAuthOrigins   map[string]bool
+	consent             *consentTerms
+	signOutEverywhere   *signOutEverywhere
+	sessionEvents       *sessionEvents
+	userInfo            *options.UserInfo
 	realClientIPParser  ipapi.RealClientIPParser
 	trustedIPs          *ip.NetSet
+	emailDenialReason   string
 
 	sessionChain      alice.Chain
 	headersChain      alice.Chain
+	authHeadersChain  alice.Chain
+	authCache         alice.Constructor
 	preAuthChain      alice.Chain
 	pageWriter        pagewriter.Writer
 	server            proxyhttp.Server
-	upstreamProxy     http.Handler
+	appServer         proxyhttp.Server
+	metricsServer     proxyhttp.Server
+	upstreamProxy     upstream.Proxy
 	serveMux          *mux.Router
 	redirectValidator redirect.Validator
 	appDirector       redirect.AppDirector
+
+	handler *reloadHandler
+	reload  ReloadFunc
+
+	// requests counts the requests served by the reload handler, so that the
+	// stores of a replaced configuration are closed once it is idle.
+	requestsMutex sync.Mutex
+	requests      int
+	replaced      bool
+
+	tenants      []tenantProxy
+	reverseProxy bool
+
+	// serverTLS and metricsServerTLS are the TLS options of the servers,
+	// whose certificates are replaced when the configuration is reloaded.
+	serverTLS        *options.TLS
+	metricsServerTLS *options.TLS
 }
 
 // NewOAuthProxy creates a new instance of OAuthProxy from the options provided
 func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthProxy, error) {
+	p, err := newOAuthProxy(opts, validator)
+	if err != nil {
+		return nil, err
+	}
+	p.handler = newReloadHandler(p)
+
+	if err := p.setupServer(opts); err != nil {
+		return nil, fmt.Errorf("error setting up server: %v", err)
+	}
+
+	return p, nil
+}
+
+// newOAuthProxy creates the handlers of an OAuthProxy from the options
+// provided without setting up its servers.
+// It is used to rebuild the OAuthProxy when the configuration is reloaded.
+func newOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthProxy, error) {
+	// Validation may replace the default client, whose transport holds the TLS
+	// configuration of the provider
+	providerClient := newProviderClient(opts)
+
 	sessionStore, err := sessions.NewSessionStore(&opts.Session, &opts.Cookie)
 	if err != nil {
 		return nil, fmt.Errorf("error initialising session store: %v", err)
 	}
 
+	var replayCache sessionsapi.ReplayCache
+	if opts.Session.ReplayCacheTTL > 0 {
+		replayCache, err = sessions.NewReplayCache(&opts.Session, opts.Cookie.Name+"-replay-")
+		if err != nil {
+			return nil, fmt.Errorf("error initialising replay cache: %v", err)
+		}
+	}
+
+	consent, err := buildConsentTerms(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising consent terms: %v", err)
+	}
+
+	signOutEverywhere, err := buildSignOutEverywhere(opts, sessionStore)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising sign out everywhere: %v", err)
+	}
+
 	var basicAuthValidator basic.Validator
 	if opts.HtpasswdFile != "" {
 		logger.Printf("using htpasswd file: %s", opts.HtpasswdFile)
@@ -123,14 +254,26 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		}
 	}
 
-	provider, err := providers.NewProvider(opts.Providers[0])
+	provider, err := providers.NewProvider(opts.Providers[0], providerClient)
 	if err != nil {
 		return nil, fmt.Errorf("error intiailising provider: %v", err)
 	}
+	provider.Data().LookupCache = providers.NewLookupCache(opts.ProviderLookupCacheTTL, opts.ProviderLookupCacheMaxEntries)
+
+	var redirectSigner *redirect.Signer
+	if opts.SignRedirects {
+		redirectSigner = redirect.NewSigner(opts.Cookie.Secret)
+	}
+
+	signInChallenge, err := challenge.New(opts.SignInChallenge, opts.Cookie.Secret, replayCache)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising sign-in challenge: %v", err)
+	}
 
 	pageWriter, err := pagewriter.NewWriter(pagewriter.Opts{
 		TemplatesPath:    opts.Templates.Path,
 		CustomLogo:       opts.Templates.CustomLogo,
+		ConsentTermsFile: opts.Consent.TermsFile,
 		ProxyPrefix:      opts.ProxyPrefix,
 		Footer:           opts.Templates.Footer,
 		Version:          VERSION,
@@ -138,6 +281,22 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		ProviderName:     buildProviderName(provider, opts.Providers[0].Name),
 		SignInMessage:    buildSignInMessage(opts),
 		DisplayLoginForm: basicAuthValidator != nil && opts.Templates.DisplayLoginForm,
+		RememberMe:       opts.Cookie.RememberMe,
+
+		Providers:                buildSignInProviders(provider, opts),
+		ProviderSearch:           opts.Templates.ProviderSearch,
+		ProviderPreferenceCookie: buildProviderPreferenceCookie(opts),
+
+		DefaultLanguage:      opts.Templates.DefaultLanguage,
+		BrandColor:           opts.Templates.BrandColor,
+		BrandBackgroundColor: opts.Templates.BrandBackgroundColor,
+		FooterLinks:          buildFooterLinks(opts.Templates.FooterLinks),
+
+		ContentSecurityPolicy: opts.Templates.ContentSecurityPolicy,
+		ReferrerPolicy:        opts.Templates.ReferrerPolicy,
+		FrameAncestors:        opts.Templates.FrameAncestors,
+		Challenge:             signInChallenge,
+		RedirectSigner:        redirectSigner,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error initialising page writer: %v", err)
@@ -158,6 +317,10 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 	if redirectURL.Path == "" {
 		redirectURL.Path = fmt.Sprintf("%s/callback", opts.ProxyPrefix)
 	}
+	callbackURLs, err := redirect.NewCallbackURLs(opts.RedirectURLHosts, opts.RedirectURLTemplate)
+	if err != nil {
+		return nil, err
+	}
 
 	logger.Printf("OAuthProxy configured for %s Client ID: %s", provider.Data().ProviderName, opts.Providers[0].ClientID)
 	refresh := "disabled"
@@ -181,25 +344,52 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, err
 	}
 
-	apiRoutes, err := buildAPIRoutes(opts)
+	apiRoutes, err := buildPathRoutes(opts.APIRoutes, "API")
+	if err != nil {
+		return nil, err
+	}
+	redirectRoutes, err := buildPathRoutes(opts.RedirectRoutes, "Redirect")
 	if err != nil {
 		return nil, err
 	}
 
-	preAuthChain, err := buildPreAuthChain(opts)
+	preAuthChain, err := buildPreAuthChain(opts, upstreamProxy)
 	if err != nil {
 		return nil, fmt.Errorf("could not build pre-auth chain: %v", err)
 	}
-	sessionChain := buildSessionChain(opts, provider, sessionStore, basicAuthValidator)
+	sessionChain := buildSessionChain(opts, provider, providerClient, sessionStore, basicAuthValidator, upstreamProxy)
 	headersChain, err := buildHeadersChain(opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not build headers chain: %v", err)
 	}
+	authHeadersChain, err := buildAuthHeadersChain(opts, headersChain)
+	if err != nil {
+		return nil, fmt.Errorf("could not build auth headers chain: %v", err)
+	}
+
+	// JWT assertions are only sent to upstreams, not in auth responses
+	var jwtAssertionSigner *assertion.Signer
+	if opts.JWTAssertionKeyFile != "" {
+		jwtAssertionSigner, err = assertion.LoadSigner(opts.JWTAssertionKeyFile, opts.JWTAssertionIssuer)
+		if err != nil {
+			return nil, fmt.Errorf("could not load JWT assertion key: %v", err)
+		}
+		headersChain = headersChain.Append(middleware.NewJWTAssertion(jwtAssertionSigner, jwtAssertionAudience(upstreamProxy)))
+	}
+	authCache := middleware.NewAuthCache(&middleware.AuthCacheOptions{
+		CookieName: opts.Cookie.Name,
+		TTL:        opts.AuthCacheTTL,
+		MaxEntries: opts.AuthCacheMaxEntries,
+	})
 
-	redirectValidator := redirect.NewValidator(opts.WhitelistDomains)
+	redirectValidator, err := redirect.NewPolicyValidator(opts.WhitelistDomains, opts.RedirectPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("could not create redirect validator: %v", err)
+	}
 	appDirector := redirect.NewAppDirector(redirect.AppDirectorOpts{
 		ProxyPrefix: opts.ProxyPrefix,
 		Validator:   redirectValidator,
+		Signer:      redirectSigner,
 	})
 
 	p := &OAuthProxy{
@@ -211,31 +401,58 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		ProxyPrefix:         opts.ProxyPrefix,
 		provider:            provider,
 		sessionStore:        sessionStore,
+		replayCache:         replayCache,
+		replayCacheTTL:      opts.Session.ReplayCacheTTL,
+		jwtAssertionSigner:  jwtAssertionSigner,
+		signInChallenge:     signInChallenge,
+		redirectSigner:      redirectSigner,
 		redirectURL:         redirectURL,
+		providerID:          opts.Providers[0].ID,
+		providerClient:      providerClient,
+		providerPreference:  buildProviderPreferenceCookie(opts),
+		callbackURLs:        callbackURLs,
 		apiRoutes:           apiRoutes,
-		allowedRoutes:       allowedRoutes,
+		redirectRoutes:      redirectRoutes,
+		allowedRoutes:       newRouteAllowlist(allowedRoutes),
 		whitelistDomains:    opts.WhitelistDomains,
 		skipAuthPreflight:   opts.SkipAuthPreflight,
 		skipJwtBearerTokens: opts.SkipJwtBearerTokens,
 		realClientIPParser:  opts.GetRealClientIPParser(),
 		SkipProviderButton:  opts.SkipProviderButton,
 		forceJSONErrors:     opts.ForceJSONErrors,
+		negotiateUnauthn:    opts.UnauthenticatedResponse == options.UnauthenticatedResponseNegotiate,
+		wsAuthCloseFrames:   opts.WebSocketAuthCloseFrames,
+		silentAuthOrigins:   buildSilentAuthOrigins(opts.SilentAuthOrigins),
+		consent:             consent,
+		signOutEverywhere:   signOutEverywhere,
+		sessionEvents:       buildSessionEvents(opts),
+		userInfo:            opts.UserInfo,
 		trustedIPs:          trustedIPs,
+		emailDenialReason:   emailDenialReason(opts),
 
 		basicAuthValidator: basicAuthValidator,
 		basicAuthGroups:    opts.HtpasswdUserGroups,
 		sessionChain:       sessionChain,
 		headersChain:       headersChain,
+		authHeadersChain:   authHeadersChain,
+		authCache:          authCache,
 		preAuthChain:       preAuthChain,
 		pageWriter:         pageWriter,
 		upstreamProxy:      upstreamProxy,
 		redirectValidator:  redirectValidator,
 		appDirector:        appDirector,
+		reverseProxy:       opts.ReverseProxy,
+		serverTLS:          opts.Server.TLS,
+		metricsServerTLS:   opts.MetricsServer.TLS,
 	}
 	p.buildServeMux(opts.ProxyPrefix)
 
-	if err := p.setupServer(opts); err != nil {
-		return nil, fmt.Errorf("error setting up server: %v", err)
+	for i, tenantOpts := range opts.GetTenantOptions() {
+		tenant, err := newOAuthProxy(tenantOpts, validator)
+		if err != nil {
+			return nil, fmt.Errorf("error initialising tenant %q: %v", opts.Tenants[i].ID, err)
+		}
+		p.tenants = append(p.tenants, tenantProxy{hosts: opts.Tenants[i].Hosts, proxy: tenant})
 	}
 
 	return p, nil
@@ -255,18 +472,63 @@ func (p *OAuthProxy) Start() error {
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 		<-sigint
+		if err := systemd.Notify(systemd.Stopping); err != nil {
+			logger.Errorf("Error notifying systemd: %v", err)
+		}
 		cancel() // cancel the context
 	}()
 
+	if p.reload != nil {
+		go func() {
+			sighup := make(chan os.Signal, 1)
+			signal.Notify(sighup, syscall.SIGHUP)
+			for range sighup {
+				if err := p.ReloadConfig(); err != nil {
+					logger.Errorf("ERROR: %v", err)
+				}
+			}
+		}()
+	}
+
+	if len(debugSignals) > 0 {
+		go func() {
+			sigdebug := make(chan os.Signal, 1)
+			signal.Notify(sigdebug, debugSignals...)
+			for range sigdebug {
+				if logger.ToggleDebug() {
+					logger.Printf("Debug logging enabled for every component")
+				} else {
+					logger.Printf("Debug logging disabled, logging level restored to %s", logger.GetLevel())
+				}
+			}
+		}()
+	}
+
+	// The listeners are bound when the server is set up so connections are
+	// queued until the server starts serving them
+	if err := systemd.Notify(systemd.Ready); err != nil {
+		logger.Errorf("Error notifying systemd: %v", err)
+	}
+
 	return p.server.Start(ctx)
 }
 
 func (p *OAuthProxy) setupServer(opts *options.Options) error {
 	serverOpts := proxyhttp.Opts{
-		Handler:           p,
+		Handler:           p.handler,
 		BindAddress:       opts.Server.BindAddress,
 		SecureBindAddress: opts.Server.SecureBindAddress,
 		TLS:               opts.Server.TLS,
+		EnableHTTP2:       opts.Server.EnableHTTP2,
+		ACME:              opts.Server.ACME,
+		FIPS:              fips.Enabled(opts.FIPSMode),
+	}
+	if opts.Server.ACME != nil && opts.Server.ACME.UseSessionStore {
+		client, err := redis.NewRedisClient(opts.Session.Redis)
+		if err != nil {
+			return fmt.Errorf("could not create redis client for ACME cache: %v", err)
+		}
+		serverOpts.ACMECache = redis.NewCertCache(client, opts.Cookie.Name+"-acme-")
 	}
 
 	appServer, err := proxyhttp.NewServer(serverOpts)
@@ -274,20 +536,62 @@ func (p *OAuthProxy) setupServer(opts *options.Options) error {
 		return fmt.Errorf("could not build app server: %v", err)
 	}
 
+	// The metrics server also serves the logging level, so that debug logging
+	// can be enabled without exposing it on the app server, and flushes the
+	// provider lookup cache
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/", middleware.DefaultMetricsHandler)
+	metricsMux.Handle(logLevelPath, logger.LevelHandler())
+	metricsMux.Handle(providerLookupCachePath, p.providerLookupCacheHandler())
+	metricsHandler, err := metricsAuthorization(opts.MetricsServer, metricsMux)
+	if err != nil {
+		return err
+	}
+
 	metricsServer, err := proxyhttp.NewServer(proxyhttp.Opts{
-		Handler:           middleware.DefaultMetricsHandler,
+		Handler:           metricsHandler,
 		BindAddress:       opts.MetricsServer.BindAddress,
 		SecureBindAddress: opts.MetricsServer.SecureBindAddress,
 		TLS:               opts.MetricsServer.TLS,
+		FIPS:              fips.Enabled(opts.FIPSMode),
 	})
 	if err != nil {
 		return fmt.Errorf("could not build metrics server: %v", err)
 	}
 
+	p.appServer, p.metricsServer = appServer, metricsServer
 	p.server = proxyhttp.NewServerGroup(appServer, metricsServer)
 	return nil
 }
 
+// providerLookupCacheHandler flushes the provider lookup cache of the current
+// configuration, as each configuration that is reloaded has a cache of its own.
+func (p *OAuthProxy) providerLookupCacheHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		p.handler.load().provider.Data().LookupCache.FlushHandler().ServeHTTP(rw, req)
+	})
+}
+
+// metricsAuthorization requires the bearer token of the metrics server, if
+// it has one, in requests to the handler.
+// Client certificates are verified by the TLS listener of the server.
+func metricsAuthorization(server options.Server, handler http.Handler) (http.Handler, error) {
+	if server.BearerToken == nil {
+		return handler, nil
+	}
+
+	token, err := optionsutil.GetSecretValue(server.BearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not load metrics bearer token: %v", err)
+	}
+	// Files usually end with a new line, which is not part of the token
+	trimmed := strings.TrimSpace(string(token))
+	if trimmed == "" {
+		return nil, errors.New("metrics bearer token is empty")
+	}
+	return middleware.NewBearerTokenAuthorization(trimmed)(handler), nil
+}
+
 func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	// Use the encoded path here so we can have the option to pass it on in the upstream mux.
 	// Otherwise something like /%2F/ would be redirected to / here already.
@@ -301,7 +605,18 @@ func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	// The authonly path should be registered separately to prevent it from getting no-cache headers.
 	// We do this to allow users to have a short cache (via nginx) of the response to reduce the
 	// likelihood of multiple reuests trying to referesh sessions simultaneously.
-	r.Path(proxyPrefix + authOnlyPath).Handler(p.sessionChain.ThenFunc(p.AuthOnly))
+	// Allow decisions may be cached to avoid loading the session for every request.
+	r.Path(proxyPrefix + authOnlyPath).Handler(p.authCache(p.sessionChain.ThenFunc(p.AuthOnly)))
+
+	// The keys of the JWT assertions may be cached by upstreams, so are not
+	// served with no-cache headers either
+	if p.jwtAssertionSigner != nil {
+		r.Path(proxyPrefix + jwksPath).Handler(p.jwtAssertionSigner.JWKSHandler())
+	}
+
+	// The static files of the custom templates directory are revalidated by
+	// browsers rather than not cached at all
+	r.PathPrefix(proxyPrefix + staticPath + "/").Handler(http.StripPrefix(proxyPrefix+staticPath, http.HandlerFunc(p.pageWriter.WriteStaticFile)))
 
 	// This will register all of the paths under the proxy prefix, except the auth only path so that no cache headers
 	// are not applied.
@@ -320,16 +635,29 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	s.Path(signOutPath).HandlerFunc(p.SignOut)
 	s.Path(oauthStartPath).HandlerFunc(p.OAuthStart)
 	s.Path(oauthCallbackPath).HandlerFunc(p.OAuthCallback)
+	s.Path(silentAuthPath).HandlerFunc(p.SilentAuth)
+
+	// The consent page is only shown to users who signed in
+	s.Path(consentPath).Handler(p.sessionChain.ThenFunc(p.Consent))
+	s.Path(signOutEverywherePath).Handler(p.sessionChain.ThenFunc(p.SignOutEverywhere))
 
 	// The userinfo endpoint needs to load sessions before handling the request
 	s.Path(userInfoPath).Handler(p.sessionChain.ThenFunc(p.UserInfo))
+	s.Path(sessionPath).Handler(p.sessionChain.ThenFunc(p.SessionStatus))
+	s.Path(refreshPath).Handler(p.forceSessionRefresh(p.sessionChain.ThenFunc(p.SessionStatus)))
+	s.Path(eventsPath).Handler(p.sessionChain.ThenFunc(p.SessionEvents))
 }
 
 // buildPreAuthChain constructs a chain that should process every request before
 // the OAuth2 Proxy authentication logic kicks in.
 // For example forcing HTTPS or health checks.
-func buildPreAuthChain(opts *options.Options) (alice.Chain, error) {
-	chain := alice.New(middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader))
+func buildPreAuthChain(opts *options.Options, readiness middleware.Verifiable) (alice.Chain, error) {
+	chain := alice.New(
+		middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader),
+		middleware.NewProviderScope(opts.Providers[0].ID),
+		middleware.NewGRPCStatus(),
+		middleware.NewSecurityHeaders(opts.SecurityHeaders),
+	)
 
 	if opts.ForceHTTPS {
 		_, httpsPort, err := net.SplitHostPort(opts.Server.SecureBindAddress)
@@ -352,21 +680,55 @@ func buildPreAuthChain(opts *options.Options) (alice.Chain, error) {
 	if opts.Logging.SilencePing {
 		chain = chain.Append(
 			middleware.NewHealthCheck(healthCheckPaths, healthCheckUserAgents),
+			middleware.NewReadinessCheck(opts.ReadyPath, readiness),
 			middleware.NewRequestLogger(),
 		)
 	} else {
 		chain = chain.Append(
 			middleware.NewRequestLogger(),
 			middleware.NewHealthCheck(healthCheckPaths, healthCheckUserAgents),
+			middleware.NewReadinessCheck(opts.ReadyPath, readiness),
 		)
 	}
 
 	chain = chain.Append(middleware.NewRequestMetricsWithDefaultRegistry())
 
+	// Requests are shed after the health checks, which are always answered,
+	// and are logged and counted in the metrics
+	chain = chain.Append(middleware.NewLoadShedding(concurrency.NewLimiter(
+		concurrency.StageRequests, opts.LoadShedding.MaxInFlightRequests, opts.LoadShedding.QueueTimeout)))
+
+	// CORS preflight requests do not include credentials so must be answered
+	// before authentication
+	cors, err := middleware.NewCORS(opts.UpstreamServers)
+	if err != nil {
+		return alice.Chain{}, fmt.Errorf("could not build CORS middleware: %v", err)
+	}
+	chain = chain.Append(cors)
+
 	return chain, nil
 }
 
-func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
+// newProviderClient creates the client of the requests to the providers,
+// configured by the provider client and load shedding options.
+func newProviderClient(opts *options.Options) *http.Client {
+	return requests.NewProviderClient(requests.TransportConfig{
+		MaxIdleConns:          opts.ProviderClient.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.ProviderClient.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.ProviderClient.MaxConnsPerHost,
+		IdleConnTimeout:       opts.ProviderClient.IdleConnTimeout,
+		KeepAlive:             opts.ProviderClient.KeepAlive,
+		TLSSessionCacheSize:   opts.ProviderClient.TLSSessionCacheSize,
+		DialTimeout:           opts.ProviderClient.DialTimeout,
+		TLSHandshakeTimeout:   opts.ProviderClient.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ProviderClient.ResponseHeaderTimeout,
+		RequestTimeout:        opts.ProviderClient.RequestTimeout,
+		MaxConcurrentRequests: opts.LoadShedding.MaxConcurrentProviderRequests,
+		QueueTimeout:          opts.LoadShedding.QueueTimeout,
+	})
+}
+
+func buildSessionChain(opts *options.Options, provider providers.Provider, providerClient *http.Client, sessionStore sessionsapi.SessionStore, validator basic.Validator, upstreamProxy upstream.Proxy) alice.Chain {
 	chain := alice.New()
 
 	if opts.SkipJwtBearerTokens {
@@ -386,16 +748,74 @@ func buildSessionChain(opts *options.Options, provider providers.Provider, sessi
 		chain = chain.Append(middleware.NewBasicAuthSessionLoader(validator, opts.HtpasswdUserGroups, opts.LegacyPreferEmailToUser))
 	}
 
+	if opts.ClientCertificateSessions {
+		chain = chain.Append(middleware.NewClientCertificateSessionLoader(opts.ClientCertificateUserAttribute))
+	}
+
+	// Requests to the provider are made with the provider client, and are
+	// labelled for the provider metrics
+	refreshSession := func(ctx context.Context, s *sessionsapi.SessionState) (bool, error) {
+		ctx = requests.WithClient(requests.WithProviderEndpoint(ctx, opts.Providers[0].ID, requests.ProviderEndpointRefresh), providerClient)
+		return provider.RefreshSession(ctx, s)
+	}
+	validateSession := func(ctx context.Context, s *sessionsapi.SessionState) bool {
+		ctx = requests.WithClient(requests.WithProviderEndpoint(ctx, opts.Providers[0].ID, requests.ProviderEndpointValidate), providerClient)
+		return provider.ValidateSession(ctx, s)
+	}
+
 	chain = chain.Append(middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
-		SessionStore:    sessionStore,
-		RefreshPeriod:   opts.Cookie.Refresh,
-		RefreshSession:  provider.RefreshSession,
-		ValidateSession: provider.ValidateSession,
+		SessionStore:       sessionStore,
+		RefreshPeriod:      opts.Cookie.Refresh,
+		RefreshSession:     refreshSession,
+		ValidateSession:    validateSession,
+		ValidationInterval: opts.SessionValidationInterval,
+		RefreshPolicy:      buildSessionRefreshPolicy(opts, upstreamProxy),
+		RefreshLimiter: concurrency.NewLimiter(
+			concurrency.StageRefresh, opts.LoadShedding.MaxConcurrentRefreshes, opts.LoadShedding.QueueTimeout),
 	}))
 
 	return chain
 }
 
+// buildSessionRefreshPolicy returns the session refresh policy of the upstream
+// serving each request, using the global intervals for anything the upstream
+// does not override.
+func buildSessionRefreshPolicy(opts *options.Options, upstreamProxy upstream.Proxy) func(*http.Request) *middleware.SessionRefreshPolicy {
+	return func(req *http.Request) *middleware.SessionRefreshPolicy {
+		u, ok := upstreamProxy.MatchUpstream(req)
+		if !ok || u.Session == nil {
+			return nil
+		}
+
+		policy := &middleware.SessionRefreshPolicy{
+			RefreshPeriod:      opts.Cookie.Refresh,
+			ValidationInterval: opts.SessionValidationInterval,
+		}
+		if u.Session.RefreshPeriod != nil {
+			policy.RefreshPeriod = u.Session.RefreshPeriod.Duration()
+		}
+		if u.Session.ValidationInterval != nil {
+			policy.ValidationInterval = u.Session.ValidationInterval.Duration()
+		}
+		return policy
+	}
+}
+
+// jwtAssertionAudience returns the audience of the JWT assertions sent to the
+// upstream serving each request: its JWT assertion audience, or its ID.
+func jwtAssertionAudience(upstreamProxy upstream.Proxy) func(*http.Request) string {
+	return func(req *http.Request) string {
+		u, ok := upstreamProxy.MatchUpstream(req)
+		if !ok {
+			return ""
+		}
+		if u.JWTAssertionAudience != "" {
+			return u.JWTAssertionAudience
+		}
+		return u.ID
+	}
+}
+
 func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
 	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders)
 	if err != nil {
@@ -410,6 +830,23 @@ func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
 	return alice.New(requestInjector, responseInjector), nil
 }
 
+// buildAuthHeadersChain builds the chain that adds headers to responses from
+// the auth endpoint.
+// When no auth response headers are configured the headers chain is used so
+// that the inject response headers are returned.
+func buildAuthHeadersChain(opts *options.Options, headersChain alice.Chain) (alice.Chain, error) {
+	if len(opts.AuthResponseHeaders) == 0 {
+		return headersChain, nil
+	}
+
+	responseInjector, err := middleware.NewResponseHeaderInjector(opts.AuthResponseHeaders)
+	if err != nil {
+		return alice.Chain{}, fmt.Errorf("error constructing auth response header injector: %v", err)
+	}
+
+	return alice.New(responseInjector), nil
+}
+
 func buildSignInMessage(opts *options.Options) string {
 	var msg string
 	if len(opts.Templates.Banner) >= 1 {
@@ -435,6 +872,42 @@ func buildProviderName(p providers.Provider, override string) string {
 	return p.Data().ProviderName
 }
 
+// buildSignInProviders returns the providers rendered as buttons on the
+// sign-in page: the providers users can sign in with, which is the first
+// configured provider.
+func buildSignInProviders(p providers.Provider, opts *options.Options) []pagewriter.SignInProvider {
+	return []pagewriter.SignInProvider{{
+		ID:    opts.Providers[0].ID,
+		Name:  buildProviderName(p, opts.Providers[0].Name),
+		Type:  string(opts.Providers[0].Type),
+		Group: opts.Providers[0].SignInGroup,
+	}}
+}
+
+// buildProviderPreferenceCookie returns the name of the cookie remembering
+// the provider users last signed in with, or an empty name when it is not
+// remembered.
+func buildProviderPreferenceCookie(opts *options.Options) string {
+	if !opts.Templates.RememberProvider {
+		return ""
+	}
+	return opts.Cookie.Name + "_provider"
+}
+
+// buildFooterLinks builds the links of the footer of the sign-in and error
+// pages from their `label=url` options, validated to contain a `=`.
+func buildFooterLinks(links []string) []pagewriter.FooterLink {
+	footerLinks := make([]pagewriter.FooterLink, 0, len(links))
+	for _, link := range links {
+		parts := strings.SplitN(link, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		footerLinks = append(footerLinks, pagewriter.FooterLink{Label: parts[0], URL: parts[1]})
+	}
+	return footerLinks
+}
+
 // buildRoutesAllowlist builds an []allowedRoute  list from either the legacy
 // SkipAuthRegex option (paths only support) or newer SkipAuthRoutes option
 // (method=path support)
@@ -484,22 +957,38 @@ func buildRoutesAllowlist(opts *options.Options) ([]allowedRoute, error) {
 	return routes, nil
 }
 
-// buildAPIRoutes builds an []apiRoute from ApiRoutes option
-func buildAPIRoutes(opts *options.Options) ([]apiRoute, error) {
-	routes := make([]apiRoute, 0, len(opts.APIRoutes))
+// newRouteAllowlist groups the allowed routes for matching.
+func newRouteAllowlist(routes []allowedRoute) *routeAllowlist {
+	paths := make(map[string][]*regexp.Regexp)
+	allowlist := &routeAllowlist{paths: make(map[string]*matcher.RegexpSet)}
+	for _, route := range routes {
+		if route.negate {
+			allowlist.negated = append(allowlist.negated, route)
+			continue
+		}
+		paths[route.method] = append(paths[route.method], route.pathRegex)
+	}
+	for method, regexes := range paths {
+		allowlist.paths[method] = matcher.NewRegexpSet(regexes...)
+	}
+	return allowlist
+}
+
+// buildPathRoutes builds a set of the path regex options such as ApiRoutes
+// and RedirectRoutes
+func buildPathRoutes(paths []string, kind string) (*matcher.RegexpSet, error) {
+	regexes := make([]*regexp.Regexp, 0, len(paths))
 
-	for _, path := range opts.APIRoutes {
+	for _, path := range paths {
 		compiledRegex, err := regexp.Compile(path)
 		if err != nil {
 			return nil, err
 		}
-		logger.Printf("API route - Path: %s", path)
-		routes = append(routes, apiRoute{
-			pathRegex: compiledRegex,
-		})
+		logger.Printf("%s route - Path: %s", kind, path)
+		regexes = append(regexes, compiledRegex)
 	}
 
-	return routes, nil
+	return matcher.NewRegexpSet(regexes...), nil
 }
 
 // ClearSessionCookie creates a cookie to unset the user's authentication cookie
@@ -519,6 +1008,10 @@ func (p *OAuthProxy) SaveSession(rw http.ResponseWriter, req *http.Request, s *s
 }
 
 func (p *OAuthProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if tenant := p.tenantFor(req); tenant != nil {
+		tenant.ServeHTTP(rw, req)
+		return
+	}
 	p.serveMux.ServeHTTP(rw, req)
 }
 
@@ -532,13 +1025,20 @@ func (p *OAuthProxy) ErrorPage(rw http.ResponseWriter, req *http.Request, code i
 		redirectURL = "/"
 	}
 
+	// Unexpected errors that have no code of their own are internal errors
 	scope := middlewareapi.GetRequestScope(req)
+	if scope.ErrorCode == "" && code >= http.StatusInternalServerError {
+		errcode.Record(req, errcode.InternalError)
+	}
+
 	p.pageWriter.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
-		Status:      code,
-		RedirectURL: redirectURL,
-		RequestID:   scope.RequestID,
-		AppError:    appError,
-		Messages:    messages,
+		Status:         code,
+		RedirectURL:    redirectURL,
+		RequestID:      scope.RequestID,
+		ErrorCode:      scope.ErrorCode,
+		AppError:       appError,
+		Messages:       messages,
+		AcceptLanguage: req.Header.Get("Accept-Language"),
 	})
 }
 
@@ -564,7 +1064,12 @@ func isAllowedPath(req *http.Request, route allowedRoute) bool {
 
 // IsAllowedRoute is used to check if the request method & path is allowed without auth
 func (p *OAuthProxy) isAllowedRoute(req *http.Request) bool {
-	for _, route := range p.allowedRoutes {
+	for _, method := range []string{"", req.Method} {
+		if paths, ok := p.allowedRoutes.paths[method]; ok && paths.MatchString(req.URL.Path) {
+			return true
+		}
+	}
+	for _, route := range p.allowedRoutes.negated {
 		if isAllowedMethod(req, route) && isAllowedPath(req, route) {
 			return true
 		}
@@ -572,13 +1077,48 @@ func (p *OAuthProxy) isAllowedRoute(req *http.Request) bool {
 	return false
 }
 
-func (p *OAuthProxy) isAPIPath(req *http.Request) bool {
-	for _, route := range p.apiRoutes {
-		if route.pathRegex.MatchString(req.URL.Path) {
-			return true
-		}
+func (p *OAuthProxy) isAPIPath(path string) bool {
+	return p.apiRoutes.MatchString(path)
+}
+
+func (p *OAuthProxy) isRedirectPath(path string) bool {
+	return p.redirectRoutes.MatchString(path)
+}
+
+// redirectUnauthenticated determines whether an unauthenticated request for
+// the path should be redirected to sign in, or should receive a 401 response.
+// API routes and redirect routes take precedence, then the Accept header is
+// used when content negotiation is enabled.
+func (p *OAuthProxy) redirectUnauthenticated(req *http.Request, path string, defaultRedirect bool) bool {
+	switch {
+	case p.isAPIPath(path):
+		return false
+	case p.isRedirectPath(path):
+		return true
+	case p.negotiateUnauthn:
+		return acceptsHTML(req)
+	default:
+		return defaultRedirect
 	}
-	return false
+}
+
+// signInURL returns the URL that an unauthenticated user should visit to
+// sign in and then return to the current request.
+func (p *OAuthProxy) signInURL(req *http.Request) (string, error) {
+	appRedirect, err := p.appDirector.GetRedirect(req)
+	if err != nil {
+		return "", err
+	}
+
+	signInPath := p.SignInPath
+	if p.SkipProviderButton {
+		signInPath = p.ProxyPrefix + oauthStartPath
+	}
+	params := url.Values{"rd": {appRedirect}}
+	if p.redirectSigner != nil {
+		params.Set(redirect.SignatureParam, p.redirectSigner.Sign(appRedirect))
+	}
+	return signInPath + "?" + params.Encode(), nil
 }
 
 // isTrustedIP is used to check if a request comes from a trusted client IP address.
@@ -610,12 +1150,14 @@ func (p *OAuthProxy) SignInPage(rw http.ResponseWriter, req *http.Request, code
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
-	rw.WriteHeader(code)
 
 	redirectURL, err := p.appDirector.GetRedirect(req)
 	if err != nil {
+		errcode.Record(req, errcode.InvalidRedirect)
 		logger.Errorf("Error obtaining redirect: %v", err)
-		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		// Keep the status of the sign in page, which tells the client why it
+		// was shown
+		p.ErrorPage(rw, req, code, err.Error())
 		return
 	}
 
@@ -645,24 +1187,49 @@ func (p *OAuthProxy) ManualSignIn(req *http.Request) (string, bool, int) {
 	return "", false, http.StatusUnauthorized
 }
 
+// browserSession returns whether the session of a sign-in should only last
+// until the browser is closed: when users may choose to stay signed in, with
+// the remember_me parameter, but did not.
+func (p *OAuthProxy) browserSession(req *http.Request) bool {
+	return p.CookieOptions.RememberMe && req.FormValue(rememberMeParam) != "true"
+}
+
 // SignIn serves a page prompting users to sign in
 func (p *OAuthProxy) SignIn(rw http.ResponseWriter, req *http.Request) {
 	redirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
+		errcode.Record(req, errcode.InvalidRedirect)
 		logger.Errorf("Error obtaining redirect: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if req.Method == http.MethodPost && p.basicAuthValidator != nil {
+		if err := p.verifySignInChallenge(req); err != nil {
+			p.SignInPage(rw, req, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
 	user, ok, statusCode := p.ManualSignIn(req)
 	if ok {
-		session := &sessionsapi.SessionState{User: user, Groups: p.basicAuthGroups}
+		session := &sessionsapi.SessionState{User: user, Groups: p.basicAuthGroups, BrowserSession: p.browserSession(req)}
+		consented, err := p.loadConsent(req.Context(), session)
+		if err != nil {
+			logger.Errorf("Error loading consent: %v", err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
 		err = p.SaveSession(rw, req, session)
 		if err != nil {
+			errcode.Record(req, errcode.SessionSaveFailed)
 			logger.Printf("Error saving session: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if !consented {
+			redirect = p.consentURL(redirect)
+		}
 		http.Redirect(rw, req, redirect, http.StatusFound)
 	} else {
 		if p.SkipProviderButton {
@@ -692,7 +1259,7 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userInfo := struct {
+	var userInfo interface{} = struct {
 		User              string   `json:"user"`
 		Email             string   `json:"email"`
 		Groups            []string `json:"groups,omitempty"`
@@ -703,6 +1270,9 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 		Groups:            session.Groups,
 		PreferredUsername: session.PreferredUsername,
 	}
+	if p.userInfo != nil {
+		userInfo = buildUserInfo(req.Context(), p.userInfo, session)
+	}
 
 	if err := json.NewEncoder(rw).Encode(userInfo); err != nil {
 		logger.Printf("Error encoding user info: %v", err)
@@ -714,27 +1284,90 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 	redirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
+		errcode.Record(req, errcode.InvalidRedirect)
 		logger.Errorf("Error obtaining redirect: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	// The session is loaded only to report who signed out
+	session, _ := p.LoadCookiedSession(req)
 	err = p.ClearSessionCookie(rw, req)
 	if err != nil {
 		logger.Errorf("Error clearing session cookie: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if session != nil {
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthSuccess, securityEvent(logger.EventLogout, "", session),
+			"Signed out: %s", session)
+	}
+	// With RP-initiated logout the user is signed out of the provider too,
+	// which sends them back to the redirect afterwards
+	if logoutURL := p.provider.Data().GetLogoutURL(session, p.getAbsoluteURL(req, redirect)); logoutURL != "" {
+		redirect = logoutURL
+	}
 	http.Redirect(rw, req, redirect, http.StatusFound)
 }
 
 // OAuthStart starts the OAuth2 authentication flow
 func (p *OAuthProxy) OAuthStart(rw http.ResponseWriter, req *http.Request) {
+	if err := p.verifySignInChallenge(req); err != nil {
+		// Requests that were not submitted from the sign-in page, eg from a
+		// link to the start endpoint, are shown the sign-in page
+		if errors.Is(err, challenge.ErrNoResponse) {
+			p.SignInPage(rw, req, http.StatusForbidden)
+		} else {
+			p.SignInPage(rw, req, http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	// The provider is chosen with the buttons of the sign-in page
+	if providerID := req.URL.Query().Get("provider"); providerID != "" {
+		if providerID != p.providerID {
+			p.ErrorPage(rw, req, http.StatusBadRequest, fmt.Sprintf("unknown provider %q", providerID))
+			return
+		}
+		p.rememberProvider(rw, req)
+	}
+
 	// start the flow permitting login URL query parameters to be overridden from the request URL
-	p.doOAuthStart(rw, req, req.URL.Query())
+	p.doOAuthStart(rw, req, req.URL.Query(), "")
 }
 
-func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, overrides url.Values) {
+// rememberProvider sets the cookie remembering the provider the user signs in
+// with, when it is enabled.
+func (p *OAuthProxy) rememberProvider(rw http.ResponseWriter, req *http.Request) {
+	if p.providerPreference == "" {
+		return
+	}
+	http.SetCookie(rw, cookies.MakeCookieFromOptions(req, p.providerPreference, p.providerID, p.CookieOptions, providerPreferenceExpire, time.Now()))
+}
+
+// verifySignInChallenge checks the response to the sign-in challenge, if
+// any, submitted with a sign-in form.
+func (p *OAuthProxy) verifySignInChallenge(req *http.Request) error {
+	if p.signInChallenge == nil {
+		return nil
+	}
+
+	err := p.signInChallenge.Verify(req)
+	if err != nil && !errors.Is(err, challenge.ErrNoResponse) {
+		errcode.Record(req, errcode.ChallengeFailed)
+		logger.PrintAuthf("", req, logger.AuthFailure, "Failed sign-in challenge: %v", err)
+	}
+	return err
+}
+
+// doOAuthStart redirects to the login URL of the provider.
+// A silent authentication, with the origin its result is posted to, does not
+// prompt the user.
+func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, overrides url.Values, silentOrigin string) {
 	extraParams := p.provider.Data().LoginURLParams(overrides)
+	if silentOrigin != "" {
+		extraParams.Del("approval_prompt")
+		extraParams.Set("prompt", "none")
+	}
 	prepareNoCache(rw)
 
 	var codeChallenge, codeVerifier, codeChallengeMethod string
@@ -768,10 +1401,14 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 
 	appRedirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
+		errcode.Record(req, errcode.InvalidRedirect)
 		logger.Errorf("Error obtaining application redirect: %v", err)
 		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
 		return
 	}
+	csrf.SetRedirect(appRedirect)
+	csrf.SetSilentOrigin(silentOrigin)
+	csrf.SetBrowserSession(p.browserSession(req))
 
 	callbackRedirect := p.getOAuthRedirectURI(req)
 	loginURL := p.provider.GetLoginURL(
@@ -804,6 +1441,10 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 	errorString := req.Form.Get("error")
 	if errorString != "" {
+		errcode.Record(req, errcode.ProviderError)
+		if p.silentAuthError(rw, req, errorString) {
+			return
+		}
 		logger.Errorf("Error while parsing OAuth2 callback: %s", errorString)
 		message := fmt.Sprintf("Login Failed: The upstream identity provider returned an error: %s", errorString)
 		// Set the debug message and override the non debug message to be the same for this case
@@ -813,13 +1454,29 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 
 	csrf, err := cookies.LoadCSRFCookie(req, p.CookieOptions)
 	if err != nil {
+		errcode.Record(req, errcode.CSRFMissing)
 		logger.Println(req, logger.AuthFailure, "Invalid authentication via OAuth2: unable to obtain CSRF cookie")
 		p.ErrorPage(rw, req, http.StatusForbidden, err.Error(), "Login Failed: Unable to find a valid CSRF token. Please try again.")
 		return
 	}
 
+	replayed, err := p.consumeCallback(req, csrf)
+	if err != nil {
+		errcode.Record(req, errcode.InternalError)
+		logger.Errorf("Error checking the OAuth2 callback for replay: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if replayed {
+		errcode.Record(req, errcode.CallbackReplayed)
+		logger.Println(req, logger.AuthFailure, "Invalid authentication via OAuth2: authorization code or nonce already used")
+		p.ErrorPage(rw, req, http.StatusForbidden, "authorization code or nonce already used", "Login Failed: This login link was already used. Please try again.")
+		return
+	}
+
 	session, err := p.redeemCode(req, csrf.GetCodeVerifier())
 	if err != nil {
+		errcode.Record(req, errcode.RedeemFailed)
 		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
@@ -827,6 +1484,7 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 
 	err = p.enrichSessionState(req.Context(), session)
 	if err != nil {
+		errcode.Record(req, errcode.EnrichFailed)
 		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
@@ -836,20 +1494,32 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 
 	nonce, appRedirect, err := decodeState(req)
 	if err != nil {
+		errcode.Record(req, errcode.InvalidState)
 		logger.Errorf("Error while parsing OAuth2 state: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	if !csrf.CheckOAuthState(nonce) {
-		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: CSRF token mismatch, potential attack")
+		errcode.Record(req, errcode.CSRFMismatch)
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthFailure, securityEvent(logger.EventLoginDenied, logger.ReasonCSRF, session),
+			"Invalid authentication via OAuth2: CSRF token mismatch, potential attack")
 		p.ErrorPage(rw, req, http.StatusForbidden, "CSRF token mismatch, potential attack", "Login Failed: Unable to find a valid CSRF token. Please try again.")
 		return
 	}
 
+	// The redirect of the encrypted CSRF cookie cannot be tampered with,
+	// unlike the redirect mirrored back in the state by the IdP
+	if csrfRedirect := csrf.GetRedirect(); csrfRedirect != "" {
+		appRedirect = csrfRedirect
+	}
+
 	csrf.SetSessionNonce(session)
-	if !p.provider.ValidateSession(req.Context(), session) {
-		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session validation failed: %s", session)
+	session.BrowserSession = csrf.GetBrowserSession()
+	if !p.provider.ValidateSession(p.providerContext(req.Context(), requests.ProviderEndpointValidate), session) {
+		errcode.Record(req, errcode.SessionInvalid)
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthFailure, securityEvent(logger.EventLoginDenied, logger.ReasonSessionInvalid, session),
+			"Session validation failed: %s", session)
 		p.ErrorPage(rw, req, http.StatusForbidden, "Session validation failed")
 		return
 	}
@@ -863,21 +1533,120 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
 	}
-	if p.Validator(session.Email) && authorized {
-		logger.PrintAuthf(session.Email, req, logger.AuthSuccess, "Authenticated via OAuth2: %s", session)
-		err := p.SaveSession(rw, req, session)
+	validEmail := p.Validator(session.Email)
+	if validEmail && authorized {
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthSuccess, securityEvent(logger.EventLoginSuccess, "", session),
+			"Authenticated via OAuth2: %s", session)
+		consented, err := p.loadConsent(req.Context(), session)
+		if err != nil {
+			logger.Errorf("Error loading consent for %s: %v", remoteAddr, err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+		err = p.SaveSession(rw, req, session)
 		if err != nil {
+			errcode.Record(req, errcode.SessionSaveFailed)
 			logger.Errorf("Error saving session state for %s: %v", remoteAddr, err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if origin := csrf.GetSilentOrigin(); origin != "" {
+			writeSilentAuthResult(rw, origin, "")
+			return
+		}
+		if !consented {
+			appRedirect = p.consentURL(appRedirect)
+		}
 		http.Redirect(rw, req, appRedirect, http.StatusFound)
 	} else {
-		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: unauthorized")
+		reason := p.denialReason(!validEmail)
+		errcode.Record(req, denialCodes[reason])
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthFailure, securityEvent(logger.EventLoginDenied, reason, session),
+			"Invalid authentication via OAuth2: unauthorized (%s)", reason)
+		if origin := csrf.GetSilentOrigin(); origin != "" {
+			writeSilentAuthResult(rw, origin, silentAuthAccessDenied)
+			return
+		}
 		p.ErrorPage(rw, req, http.StatusForbidden, "Invalid session: unauthorized")
 	}
 }
 
+// denialReason returns the reason of a security event denying or removing the
+// session of a user whose email address is not allowed, or otherwise whose
+// groups are not allowed.
+func (p *OAuthProxy) denialReason(invalidEmail bool) string {
+	if invalidEmail {
+		return p.emailDenialReason
+	}
+	return logger.ReasonGroup
+}
+
+// denialCodes are the codes of the errors of users that are denied a session
+// for each reason.
+var denialCodes = map[string]string{
+	logger.ReasonEmailDomain: errcode.EmailDomainDenied,
+	logger.ReasonEmailList:   errcode.EmailListDenied,
+	logger.ReasonEmail:       errcode.EmailDenied,
+	logger.ReasonGroup:       errcode.GroupDenied,
+}
+
+// emailDenialReason returns the reason of security events for email addresses
+// that are not allowed, by how allowed email addresses are configured.
+func emailDenialReason(opts *options.Options) string {
+	switch {
+	case opts.AuthenticatedEmailsFile == "":
+		return logger.ReasonEmailDomain
+	case len(opts.EmailDomains) == 0:
+		return logger.ReasonEmailList
+	default:
+		return logger.ReasonEmail
+	}
+}
+
+// securityEvent returns the details of a security event for the session,
+// including how the IdP reported that the user authenticated.
+func securityEvent(event, reason string, session *sessionsapi.SessionState) logger.SecurityEvent {
+	acr, amr := session.AuthenticationContext()
+	return logger.SecurityEvent{
+		Event:  event,
+		Reason: reason,
+		ACR:    acr,
+		AMR:    amr,
+	}
+}
+
+// consumeCallback records the authorization code and the OIDC nonce of the
+// callback as used in the replay cache, when it is enabled, returning whether
+// either of them was used before.
+// The code is hashed so that codes are not stored.
+func (p *OAuthProxy) consumeCallback(req *http.Request, csrf cookies.CSRF) (bool, error) {
+	if p.replayCache == nil {
+		return false, nil
+	}
+
+	keys := []string{"nonce-" + csrf.HashOIDCNonce()}
+	if code := req.Form.Get("code"); code != "" {
+		keys = append(keys, "code-"+encryption.HashNonce([]byte(code)))
+	}
+
+	replayed := false
+	for _, key := range keys {
+		consumed, err := p.replayCache.Consume(req.Context(), key, p.replayCacheTTL)
+		if err != nil {
+			return false, err
+		}
+		replayed = replayed || !consumed
+	}
+	return replayed, nil
+}
+
+// providerContext returns a context whose requests to the provider are made
+// with the provider client, and are labelled as requests to the endpoint for
+// the provider metrics.
+func (p *OAuthProxy) providerContext(ctx context.Context, endpoint string) context.Context {
+	return requests.WithClient(requests.WithProviderEndpoint(ctx, p.providerID, endpoint), p.providerClient)
+}
+
 func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessionsapi.SessionState, error) {
 	code := req.Form.Get("code")
 	if code == "" {
@@ -885,7 +1654,8 @@ func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessio
 	}
 
 	redirectURI := p.getOAuthRedirectURI(req)
-	s, err := p.provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
+	ctx := p.providerContext(req.Context(), requests.ProviderEndpointRedeem)
+	s, err := p.provider.Redeem(ctx, redirectURI, code, codeVerifier)
 	if err != nil {
 		return nil, err
 	}
@@ -902,6 +1672,7 @@ func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessio
 }
 
 func (p *OAuthProxy) enrichSessionState(ctx context.Context, s *sessionsapi.SessionState) error {
+	ctx = p.providerContext(ctx, requests.ProviderEndpointProfile)
 	var err error
 	if s.Email == "" {
 		// TODO(@NickMeves): Remove once all provider are updated to implement EnrichSession
@@ -920,7 +1691,7 @@ func (p *OAuthProxy) enrichSessionState(ctx context.Context, s *sessionsapi.Sess
 func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 	session, err := p.getAuthenticatedSession(rw, req)
 	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		p.authOnlyUnauthenticated(rw, req, err)
 		return
 	}
 
@@ -933,11 +1704,45 @@ func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 
 	// we are authenticated
 	p.addHeadersForProxying(rw, session)
-	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	p.authHeadersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusAccepted)
 	})).ServeHTTP(rw, req)
 }
 
+// authOnlyUnauthenticated responds to an AuthOnly request without a valid
+// session.
+// Ingress controllers such as Traefik return this response to the client, so
+// requests may be redirected to sign in when configured.
+// NGINX auth_request only accepts 401 and 403 responses.
+func (p *OAuthProxy) authOnlyUnauthenticated(rw http.ResponseWriter, req *http.Request, err error) {
+	if err == ErrNeedsLogin && p.redirectUnauthenticated(req, forwardedPath(req), false) {
+		signInURL, err := p.signInURL(req)
+		if err != nil {
+			logger.Errorf("Error obtaining redirect: %v", err)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(rw, req, signInURL, http.StatusFound)
+		return
+	}
+
+	if p.negotiateUnauthn {
+		p.errorUnauthenticated(rw, req)
+		return
+	}
+	http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// forwardedPath returns the path of the request being authenticated by the
+// AuthOnly endpoint.
+func forwardedPath(req *http.Request) string {
+	uri, err := url.ParseRequestURI(requestutil.GetRequestURI(req))
+	if err != nil {
+		return req.URL.Path
+	}
+	return uri.Path
+}
+
 // Proxy proxies the user request if the user is authenticated else it prompts
 // them to authenticate
 func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
@@ -949,9 +1754,26 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 		p.headersChain.Then(p.upstreamProxy).ServeHTTP(rw, req)
 	case ErrNeedsLogin:
 		// we need to send the user to a login screen
-		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
+		if p.wsAuthCloseFrames && requestutil.IsWebSocketRequest(req) {
+			logger.Printf("No valid authentication in request. Access Denied.")
+			// WebSocket clients cannot read the response to a failed handshake
+			p.errorWebSocket(rw, req, http.StatusUnauthorized)
+			return
+		}
+		if requestutil.IsGRPCRequest(req) {
+			logger.Printf("No valid authentication in request. Access Denied.")
+			// gRPC clients cannot follow a redirect, the status is converted
+			// to a grpc-status by the gRPC middleware
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if p.forceJSONErrors || !p.redirectUnauthenticated(req, req.URL.Path, !isAjax(req)) {
 			logger.Printf("No valid authentication in request. Access Denied.")
 			// no point redirecting an AJAX request
+			if p.negotiateUnauthn {
+				p.errorUnauthenticated(rw, req)
+				return
+			}
 			p.errorJSON(rw, http.StatusUnauthorized)
 			return
 		}
@@ -961,18 +1783,40 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 			// start OAuth flow, but only with the default login URL params - do not
 			// consider this request's query params as potential overrides, since
 			// the user did not explicitly start the login flow
-			p.doOAuthStart(rw, req, nil)
+			p.doOAuthStart(rw, req, nil, "")
 		} else {
 			p.SignInPage(rw, req, http.StatusForbidden)
 		}
 
 	case ErrAccessDenied:
+		if p.wsAuthCloseFrames && requestutil.IsWebSocketRequest(req) {
+			p.errorWebSocket(rw, req, http.StatusForbidden)
+			return
+		}
 		if p.forceJSONErrors {
 			p.errorJSON(rw, http.StatusForbidden)
 		} else {
 			p.ErrorPage(rw, req, http.StatusForbidden, "The session failed authorization checks")
 		}
 
+	case ErrNeedsConsent:
+		// the consent terms can only be accepted in the browser
+		if p.wsAuthCloseFrames && requestutil.IsWebSocketRequest(req) {
+			p.errorWebSocket(rw, req, http.StatusForbidden)
+			return
+		}
+		if p.forceJSONErrors || isAjax(req) || requestutil.IsGRPCRequest(req) {
+			p.errorJSON(rw, http.StatusForbidden)
+			return
+		}
+		appRedirect, err := p.appDirector.GetRedirect(req)
+		if err != nil {
+			logger.Errorf("Error obtaining redirect: %v", err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+		http.Redirect(rw, req, p.consentURL(appRedirect), http.StatusFound)
+
 	default:
 		// unknown error
 		logger.Errorf("Unexpected internal error: %v", err)
@@ -1005,7 +1849,34 @@ func prepareNoCacheMiddleware(next http.Handler) http.Handler {
 // getOAuthRedirectURI returns the redirectURL that the upstream OAuth Provider will
 // redirect clients to once authenticated.
 // This is usually the OAuthProxy callback URL.
+// getAbsoluteURL resolves a redirect, which may be relative, against the
+// scheme and host of the request
+func (p *OAuthProxy) getAbsoluteURL(req *http.Request, redirect string) string {
+	u, err := url.Parse(redirect)
+	if err != nil || u.IsAbs() {
+		return redirect
+	}
+
+	base := &url.URL{
+		Scheme: requestutil.GetRequestProto(req),
+		Host:   requestutil.GetRequestHost(req),
+		Path:   "/",
+	}
+	if base.Scheme == "" {
+		base.Scheme = schemeHTTP
+	}
+	if p.CookieOptions.Secure {
+		base.Scheme = schemeHTTPS
+	}
+	return base.ResolveReference(u).String()
+}
+
 func (p *OAuthProxy) getOAuthRedirectURI(req *http.Request) string {
+	// a redirect URL for the host of the request takes precedence
+	if callbackURL, ok := p.callbackURLs.Get(requestutil.GetRequestHost(req)); ok {
+		return callbackURL.String()
+	}
+
 	// if `p.redirectURL` already has a host, return it
 	if p.redirectURL.Host != "" {
 		return p.redirectURL.String()
@@ -1053,7 +1924,10 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 	}
 
 	if invalidEmail || !authorized {
-		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authorization via session: removing session %s", session)
+		reason := p.denialReason(invalidEmail)
+		errcode.Record(req, denialCodes[reason])
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthFailure, securityEvent(logger.EventForcedLogout, reason, session),
+			"Invalid authorization via session: removing session %s", session)
 		// Invalid session, clear it
 		err := p.ClearSessionCookie(rw, req)
 		if err != nil {
@@ -1062,6 +1936,10 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 		return nil, ErrAccessDenied
 	}
 
+	if err := p.checkConsent(rw, req, session); err != nil {
+		return nil, err
+	}
+
 	return session, nil
 }
 
@@ -1223,3 +2101,79 @@ func (p *OAuthProxy) errorJSON(rw http.ResponseWriter, code int) {
 	// application/json
 	rw.Write([]byte("{}"))
 }
+
+// acceptsHTML determines whether the request Accept header allows an HTML
+// response, as sent by browsers when navigating.
+// Requests without an Accept header, or that only accept `*/*` such as fetch
+// requests, are not considered to accept HTML.
+func acceptsHTML(req *http.Request) bool {
+	for _, mimeTypes := range req.Header.Values("Accept") {
+		for _, mimeType := range strings.Split(mimeTypes, ",") {
+			mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+			if mimeType == "text/html" || mimeType == "application/xhtml+xml" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorUnauthenticated returns a 401 response with the sign in URL in the
+// `WWW-Authenticate` header and JSON body so that clients which cannot
+// follow a redirect can send the user to sign in.
+func (p *OAuthProxy) errorUnauthenticated(rw http.ResponseWriter, req *http.Request) {
+	signInURL, err := p.signInURL(req)
+	if err != nil {
+		logger.Errorf("Error obtaining redirect: %v", err)
+		p.errorJSON(rw, http.StatusUnauthorized)
+		return
+	}
+
+	rw.Header().Set("WWW-Authenticate", fmt.Sprintf("Cookie realm=%q, form-action=%q, cookie-name=%q", "oauth2-proxy", signInURL, p.CookieOptions.Name))
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(http.StatusUnauthorized)
+	body, _ := json.Marshal(map[string]string{
+		"error":     "unauthorized",
+		"signInURL": signInURL,
+	})
+	rw.Write(body)
+}
+
+// errorWebSocket completes the WebSocket handshake and then closes the
+// connection with a close code of 4000 plus the HTTP status code, eg 4401,
+// so that WebSocket clients can determine why the connection failed.
+func (p *OAuthProxy) errorWebSocket(rw http.ResponseWriter, req *http.Request, code int) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	hijacker, ok := rw.(http.Hijacker)
+	if key == "" || !ok {
+		p.errorJSON(rw, code)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		logger.Errorf("Error hijacking WebSocket connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// SHA-1 is required by the WebSocket protocol
+	/* #nosec G401 */
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+
+	closeCode := 4000 + code
+	reason := http.StatusText(code)
+	buf.Write([]byte{0x88, byte(2 + len(reason)), byte(closeCode >> 8), byte(closeCode)})
+	buf.WriteString(reason)
+	if err := buf.Flush(); err != nil {
+		logger.Errorf("Error writing WebSocket close frame: %v", err)
+		return
+	}
+
+	// Give the client a chance to acknowledge the close before the connection
+	// is closed
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.Copy(ioutil.Discard, conn)
+}
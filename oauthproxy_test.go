@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -19,6 +23,7 @@ import (
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
@@ -288,7 +293,7 @@ func TestPassGroupsHeadersWithGroups(t *testing.T) {
 		User:        userName,
 		Groups:      groups,
 		Email:       emailAddress,
-		AccessToken: "oauth_token",
+		AccessToken: []byte("oauth_token"),
 		CreatedAt:   &created,
 	}
 
@@ -324,6 +329,7 @@ type PassAccessTokenTestOptions struct {
 	PassAccessToken bool
 	ValidToken      bool
 	ProxyUpstream   options.Upstream
+	ReplayCacheTTL  time.Duration
 }
 
 func NewPassAccessTokenTest(opts PassAccessTokenTestOptions) (*PassAccessTokenTest, error) {
@@ -363,6 +369,7 @@ func NewPassAccessTokenTest(opts PassAccessTokenTestOptions) (*PassAccessTokenTe
 	}
 
 	patt.opts.Cookie.Secure = false
+	patt.opts.Session.ReplayCacheTTL = opts.ReplayCacheTTL
 	if opts.PassAccessToken {
 		patt.opts.InjectRequestHeaders = []options.Header{
 			{
@@ -571,6 +578,25 @@ func TestSessionValidationFailure(t *testing.T) {
 	assert.Equal(t, "", cookie)
 }
 
+func TestCallbackReplay(t *testing.T) {
+	patTest, err := NewPassAccessTokenTest(PassAccessTokenTestOptions{
+		ValidToken:     true,
+		ReplayCacheTTL: 15 * time.Minute,
+	})
+	require.NoError(t, err)
+	t.Cleanup(patTest.Close)
+
+	code, cookie := patTest.getCallbackEndpoint()
+	assert.Equal(t, http.StatusFound, code)
+	assert.NotEqual(t, "", cookie)
+
+	// The callback is made with the same authorization code, as when the
+	// callback URL has leaked
+	code, cookie = patTest.getCallbackEndpoint()
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "", cookie)
+}
+
 type SignInPageTest struct {
 	opts                 *options.Options
 	proxy                *OAuthProxy
@@ -657,6 +683,48 @@ func TestManualSignInStoresUserGroupsInTheSession(t *testing.T) {
 	assert.Equal(t, userGroups, s.Groups)
 }
 
+func TestManualSignInRememberMe(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Cookie.RememberMe = true
+	err := validation.Validate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.basicAuthValidator = AlwaysSuccessfulValidator{}
+
+	signIn := func(rememberMe string) *http.Cookie {
+		rw := httptest.NewRecorder()
+		formData := url.Values{}
+		formData.Set("username", "someuser")
+		formData.Set("password", "somepass")
+		if rememberMe != "" {
+			formData.Set("remember_me", rememberMe)
+		}
+		signInReq, _ := http.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(formData.Encode()))
+		signInReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		proxy.ServeHTTP(rw, signInReq)
+		assert.Equal(t, http.StatusFound, rw.Code)
+
+		for _, c := range rw.Result().Cookies() {
+			if c.Name == opts.Cookie.Name {
+				return c
+			}
+		}
+		t.Fatal("no session cookie was set")
+		return nil
+	}
+
+	assert.True(t, signIn("").Expires.IsZero())
+	assert.False(t, signIn("true").Expires.IsZero())
+}
+
 type ManualSignInValidator struct{}
 
 func (ManualSignInValidator) Validate(user, password string) bool {
@@ -710,6 +778,57 @@ func TestManualSignInCorrectCredentials(t *testing.T) {
 	assert.Equal(t, http.StatusFound, statusCode)
 }
 
+func TestSignInChallenge(t *testing.T) {
+	opts := baseTestOptions()
+	opts.SignInChallenge.Type = options.SignInChallengeProofOfWork
+	opts.SignInChallenge.ProofOfWorkDifficulty = 1
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	require.NoError(t, err)
+	proxy.basicAuthValidator = ManualSignInValidator{}
+
+	signIn := func(method, path string, formData url.Values) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(formData.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	// Solve a puzzle with a difficulty of 1, which half of the nonces solve
+	page, err := proxy.signInChallenge.Issue()
+	require.NoError(t, err)
+	solved := url.Values{"pow_token": {page.Token}}
+	for i := 0; solved.Get("pow_nonce") == ""; i++ {
+		if hash := sha256.Sum256([]byte(page.Token + ":" + strconv.Itoa(i))); hash[0] < 0x80 {
+			solved.Set("pow_nonce", strconv.Itoa(i))
+		}
+	}
+
+	t.Run("start without a response shows the sign in page", func(t *testing.T) {
+		rw := signIn(http.MethodGet, "/oauth2/start", url.Values{})
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+		assert.Contains(t, rw.Body.String(), `name="pow_token"`)
+	})
+
+	t.Run("sign in without a response fails", func(t *testing.T) {
+		rw := signIn(http.MethodPost, "/oauth2/sign_in", url.Values{"username": {"admin"}, "password": {"adminPass"}})
+		assert.Equal(t, http.StatusUnprocessableEntity, rw.Code)
+	})
+
+	t.Run("sign in with a solved puzzle succeeds", func(t *testing.T) {
+		formData := url.Values{"username": {"admin"}, "password": {"adminPass"}}
+		formData.Set("pow_token", solved.Get("pow_token"))
+		formData.Set("pow_nonce", solved.Get("pow_nonce"))
+		rw := signIn(http.MethodPost, "/oauth2/sign_in", formData)
+		assert.Equal(t, http.StatusFound, rw.Code)
+	})
+}
+
 func TestSignInPageIncludesTargetRedirect(t *testing.T) {
 	sipTest, err := NewSignInPageTest(false)
 	if err != nil {
@@ -886,7 +1005,7 @@ func TestLoadCookiedSession(t *testing.T) {
 	}
 
 	created := time.Now()
-	startSession := &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &created}
+	startSession := &sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("my_access_token"), CreatedAt: &created}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -922,7 +1041,7 @@ func TestProcessCookieRefreshNotSet(t *testing.T) {
 
 	reference := time.Now().Add(time.Duration(-2) * time.Hour)
 
-	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &reference}
+	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &reference}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -943,7 +1062,7 @@ func TestProcessCookieFailIfCookieExpired(t *testing.T) {
 	}
 
 	reference := time.Now().Add(time.Duration(25) * time.Hour * -1)
-	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &reference}
+	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &reference}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -963,7 +1082,7 @@ func TestProcessCookieFailIfRefreshSetAndCookieExpired(t *testing.T) {
 	}
 
 	reference := time.Now().Add(time.Duration(25) * time.Hour * -1)
-	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &reference}
+	startSession := &sessions.SessionState{Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &reference}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -997,7 +1116,7 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 				User:        "john.doe",
 				Email:       "john.doe@example.com",
 				Groups:      []string{"example", "groups"},
-				AccessToken: "my_access_token",
+				AccessToken: []byte("my_access_token"),
 			},
 			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"groups\":[\"example\",\"groups\"]}\n",
 		},
@@ -1015,7 +1134,7 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 			session: &sessions.SessionState{
 				User:        "john.doe",
 				Email:       "john.doe@example.com",
-				AccessToken: "my_access_token",
+				AccessToken: []byte("my_access_token"),
 			},
 			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\"}\n",
 		},
@@ -1026,7 +1145,7 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 				PreferredUsername: "john",
 				Email:             "john.doe@example.com",
 				Groups:            []string{"example", "groups"},
-				AccessToken:       "my_access_token",
+				AccessToken:       []byte("my_access_token"),
 			},
 			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"groups\":[\"example\",\"groups\"],\"preferredUsername\":\"john\"}\n",
 		},
@@ -1049,6 +1168,33 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 	}
 }
 
+func TestUserInfoEndpointWithFields(t *testing.T) {
+	test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+		opts.UserInfo = &options.UserInfo{
+			Fields: []options.UserInfoField{
+				{Name: "id", Claim: "user"},
+				{Name: "profile.email", Claim: "email"},
+			},
+			Groups: true,
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.req, _ = http.NewRequest("GET", test.opts.ProxyPrefix+"/userinfo", nil)
+	err = test.SaveSession(&sessions.SessionState{
+		User:        "john.doe",
+		Email:       "john.doe@example.com",
+		AccessToken: []byte("my_access_token"),
+	})
+	assert.NoError(t, err)
+
+	test.proxy.ServeHTTP(test.rw, test.req)
+	assert.Equal(t, http.StatusOK, test.rw.Code)
+	bodyBytes, _ := ioutil.ReadAll(test.rw.Body)
+	assert.Equal(t, "{\"groups\":[],\"id\":\"john.doe\",\"profile\":{\"email\":\"john.doe@example.com\"}}\n", string(bodyBytes))
+}
+
 func TestUserInfoEndpointUnauthorizedOnNoCookieSetError(t *testing.T) {
 	test, err := NewUserInfoEndpointTest()
 	if err != nil {
@@ -1088,7 +1234,7 @@ func TestAuthOnlyEndpointAccepted(t *testing.T) {
 
 	created := time.Now()
 	startSession := &sessions.SessionState{
-		Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &created}
+		Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &created}
 	err = test.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -1120,7 +1266,7 @@ func TestAuthOnlyEndpointUnauthorizedOnExpiration(t *testing.T) {
 
 	reference := time.Now().Add(time.Duration(25) * time.Hour * -1)
 	startSession := &sessions.SessionState{
-		Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &reference}
+		Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &reference}
 	err = test.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -1138,7 +1284,7 @@ func TestAuthOnlyEndpointUnauthorizedOnEmailValidationFailure(t *testing.T) {
 
 	created := time.Now()
 	startSession := &sessions.SessionState{
-		Email: "michael.bland@gsa.gov", AccessToken: "my_access_token", CreatedAt: &created}
+		Email: "michael.bland@gsa.gov", AccessToken: []byte("my_access_token"), CreatedAt: &created}
 	err = test.SaveSession(startSession)
 	assert.NoError(t, err)
 	test.validateUser = false
@@ -1218,7 +1364,7 @@ func TestAuthOnlyEndpointSetXAuthRequestHeaders(t *testing.T) {
 
 	created := time.Now()
 	startSession := &sessions.SessionState{
-		User: "oauth_user", Groups: []string{"oauth_groups"}, Email: "oauth_user@example.com", AccessToken: "oauth_token", CreatedAt: &created}
+		User: "oauth_user", Groups: []string{"oauth_groups"}, Email: "oauth_user@example.com", AccessToken: []byte("oauth_token"), CreatedAt: &created}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -1229,6 +1375,89 @@ func TestAuthOnlyEndpointSetXAuthRequestHeaders(t *testing.T) {
 	assert.Equal(t, "oauth_user@example.com", pcTest.rw.Header().Get("X-Auth-Request-Email"))
 }
 
+func TestAuthOnlyEndpointSetAuthResponseHeaders(t *testing.T) {
+	var pcTest ProcessCookieTest
+
+	pcTest.opts = baseTestOptions()
+	pcTest.opts.InjectResponseHeaders = []options.Header{
+		{
+			Name: "X-Auth-Request-User",
+			Values: []options.HeaderValue{
+				{
+					ClaimSource: &options.ClaimSource{
+						Claim: "user",
+					},
+				},
+			},
+		},
+	}
+	pcTest.opts.AuthResponseHeaders = []options.Header{
+		{
+			Name: "Remote-User",
+			Values: []options.HeaderValue{
+				{
+					ClaimSource: &options.ClaimSource{
+						Claim: "user",
+					},
+				},
+			},
+		},
+		{
+			Name: "Remote-Groups",
+			Values: []options.HeaderValue{
+				{
+					ClaimSource: &options.ClaimSource{
+						Claim: "groups",
+						Join:  ",",
+					},
+				},
+			},
+		},
+		{
+			Name: "Remote-Name",
+			Values: []options.HeaderValue{
+				{
+					TemplateSource: &options.TemplateSource{
+						Template: "{{ .User }} <{{ .Email }}>",
+					},
+				},
+			},
+		},
+	}
+	err := validation.Validate(pcTest.opts)
+	assert.NoError(t, err)
+
+	pcTest.proxy, err = NewOAuthProxy(pcTest.opts, func(email string) bool {
+		return pcTest.validateUser
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcTest.proxy.provider = &TestProvider{
+		ProviderData: &providers.ProviderData{},
+		ValidToken:   true,
+	}
+
+	pcTest.validateUser = true
+
+	pcTest.rw = httptest.NewRecorder()
+	pcTest.req, _ = http.NewRequest("GET",
+		pcTest.opts.ProxyPrefix+"/auth", nil)
+
+	created := time.Now()
+	startSession := &sessions.SessionState{
+		User: "oauth_user", Groups: []string{"admins", "users"}, Email: "oauth_user@example.com", AccessToken: []byte("oauth_token"), CreatedAt: &created}
+	err = pcTest.SaveSession(startSession)
+	assert.NoError(t, err)
+
+	pcTest.proxy.ServeHTTP(pcTest.rw, pcTest.req)
+	assert.Equal(t, http.StatusAccepted, pcTest.rw.Code)
+	assert.Equal(t, "oauth_user", pcTest.rw.Header().Get("Remote-User"))
+	assert.Equal(t, []string{"admins,users"}, pcTest.rw.Header().Values("Remote-Groups"))
+	assert.Equal(t, "oauth_user <oauth_user@example.com>", pcTest.rw.Header().Get("Remote-Name"))
+	assert.Equal(t, "", pcTest.rw.Header().Get("X-Auth-Request-User"))
+}
+
 func TestAuthOnlyEndpointSetBasicAuthTrueRequestHeaders(t *testing.T) {
 	var pcTest ProcessCookieTest
 
@@ -1311,7 +1540,7 @@ func TestAuthOnlyEndpointSetBasicAuthTrueRequestHeaders(t *testing.T) {
 
 	created := time.Now()
 	startSession := &sessions.SessionState{
-		User: "oauth_user", Email: "oauth_user@example.com", AccessToken: "oauth_token", CreatedAt: &created}
+		User: "oauth_user", Email: "oauth_user@example.com", AccessToken: []byte("oauth_token"), CreatedAt: &created}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -1391,7 +1620,7 @@ func TestAuthOnlyEndpointSetBasicAuthFalseRequestHeaders(t *testing.T) {
 
 	created := time.Now()
 	startSession := &sessions.SessionState{
-		User: "oauth_user", Email: "oauth_user@example.com", AccessToken: "oauth_token", CreatedAt: &created}
+		User: "oauth_user", Email: "oauth_user@example.com", AccessToken: []byte("oauth_token"), CreatedAt: &created}
 	err = pcTest.SaveSession(startSession)
 	assert.NoError(t, err)
 
@@ -1567,7 +1796,7 @@ func (st *SignatureTest) MakeRequestWithExpectedKey(method, body, key string) er
 	req.Header = st.header
 
 	state := &sessions.SessionState{
-		Email: "mbland@acm.org", AccessToken: "my_access_token"}
+		Email: "mbland@acm.org", AccessToken: []byte("my_access_token")}
 	err = proxy.SaveSession(st.rw, req, state)
 	if err != nil {
 		return err
@@ -1714,9 +1943,179 @@ func TestAjaxForbiddendRequest(t *testing.T) {
 	assert.NotEqual(t, applicationJSON, mime)
 }
 
+func TestUnauthenticatedResponseNegotiate(t *testing.T) {
+	opts := baseTestOptions()
+	opts.ReverseProxy = true
+	opts.UnauthenticatedResponse = options.UnauthenticatedResponseNegotiate
+	opts.APIRoutes = []string{"^/api/"}
+	opts.RedirectRoutes = []string{"^/download/"}
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		path             string
+		forwardedURI     string
+		accept           string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			name:         "proxied browser request",
+			path:         "/app",
+			accept:       "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "proxied fetch request",
+			path:         "/app",
+			accept:       "*/*",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "proxied browser request to an API route",
+			path:         "/api/items",
+			accept:       "text/html",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "auth request from a browser",
+			path:             "/oauth2/auth",
+			forwardedURI:     "/app",
+			accept:           "text/html",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=%2Fapp",
+		},
+		{
+			name:         "auth request from fetch",
+			path:         "/oauth2/auth",
+			forwardedURI: "/app",
+			accept:       "*/*",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "auth request to a redirect route",
+			path:             "/oauth2/auth",
+			forwardedURI:     "/download/file",
+			accept:           "*/*",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=%2Fdownload%2Ffile",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Header.Set("Accept", tc.accept)
+			if tc.forwardedURI != "" {
+				req.Header.Set("X-Forwarded-Uri", tc.forwardedURI)
+			}
+			rw := httptest.NewRecorder()
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedCode, rw.Code)
+			assert.Equal(t, tc.expectedLocation, rw.Header().Get("Location"))
+			if tc.expectedCode == http.StatusUnauthorized {
+				assert.Equal(t, applicationJSON, rw.Header().Get("Content-Type"))
+				assert.Contains(t, rw.Header().Get("WWW-Authenticate"), `form-action="/oauth2/sign_in?rd=`)
+				assert.Contains(t, rw.Body.String(), `"signInURL":"/oauth2/sign_in?rd=`)
+			}
+		})
+	}
+}
+
+func TestSignedRedirects(t *testing.T) {
+	opts := baseTestOptions()
+	opts.SignRedirects = true
+	opts.ReverseProxy = true
+	opts.RedirectRoutes = []string{"^/download/"}
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+	signInRedirect := regexp.MustCompile(signInRedirectPattern)
+
+	req := httptest.NewRequest("GET", "/oauth2/auth", nil)
+	req.Header.Set("X-Forwarded-Uri", "/download/file")
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+	signInURL := rw.Header().Get("Location")
+	assert.Equal(t, "/oauth2/sign_in?rd=%2Fdownload%2Ffile&rd_sig="+redirect.NewSigner(opts.Cookie.Secret).Sign("/download/file"), signInURL)
+
+	testCases := []struct {
+		name             string
+		signInURL        string
+		expectedRedirect string
+	}{
+		{
+			name:             "signed redirect",
+			signInURL:        signInURL,
+			expectedRedirect: "/download/file",
+		},
+		{
+			name:             "unsigned redirect",
+			signInURL:        "/oauth2/sign_in?rd=%2Fdownload%2Ffile",
+			expectedRedirect: "/",
+		},
+		{
+			name:             "tampered redirect",
+			signInURL:        strings.Replace(signInURL, "file", "other", 1),
+			expectedRedirect: "/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			proxy.ServeHTTP(rw, httptest.NewRequest("GET", tc.signInURL, nil))
+
+			match := signInRedirect.FindStringSubmatch(rw.Body.String())
+			require.Len(t, match, 2)
+			assert.Equal(t, tc.expectedRedirect, match[1])
+		})
+	}
+}
+
+func TestWebSocketAuthCloseFrame(t *testing.T) {
+	opts := baseTestOptions()
+	opts.WebSocketAuthCloseFrames = true
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	assert.NoError(t, err)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /socket HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	assert.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	frame := make([]byte, 4)
+	_, err = io.ReadFull(reader, frame)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x88), frame[0])
+	assert.Equal(t, 4401, int(frame[2])<<8|int(frame[3]))
+}
+
 func TestClearSplitCookie(t *testing.T) {
 	opts := baseTestOptions()
-	opts.Cookie.Secret = base64CookieSecret
+	opts.Cookie.Secret = []byte(base64CookieSecret)
 	opts.Cookie.Name = "oauth2"
 	opts.Cookie.Domains = []string{"abc"}
 	err := validation.Validate(opts)
@@ -1919,6 +2318,53 @@ func TestGetJwtSession(t *testing.T) {
 	assert.Equal(t, test.rw.Header().Get("X-Auth-Request-Email"), "john@example.com")
 }
 
+func Test_getOAuthRedirectURI(t *testing.T) {
+	opts := baseTestOptions()
+	opts.RawRedirectURL = "https://auth.example.com/oauth2/callback"
+	opts.RedirectURLHosts = []string{"vanity.example.org=https://vanity.example.org/oauth2/callback"}
+	opts.RedirectURLTemplate = "https://{host}/oauth2/callback"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	testCases := map[string]string{
+		"vanity.example.org": "https://vanity.example.org/oauth2/callback",
+		"app.example.net":    "https://app.example.net/oauth2/callback",
+		"":                   "https://auth.example.com/oauth2/callback",
+	}
+	for host, expected := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start", nil)
+		req.Host = host
+		assert.Equal(t, expected, proxy.getOAuthRedirectURI(req), host)
+	}
+}
+
+func TestOAuthStartProvider(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Templates.RememberProvider = true
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=unknown", nil))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=providerID", nil))
+	assert.Equal(t, http.StatusFound, rw.Code)
+	var preference *http.Cookie
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == opts.Cookie.Name+"_provider" {
+			preference = c
+		}
+	}
+	require.NotNil(t, preference)
+	assert.Equal(t, "providerID", preference.Value)
+}
+
 func Test_prepareNoCache(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		prepareNoCache(w)
@@ -2028,10 +2474,10 @@ func Test_noCacheHeaders(t *testing.T) {
 
 func baseTestOptions() *options.Options {
 	opts := options.NewOptions()
-	opts.Cookie.Secret = rawCookieSecret
+	opts.Cookie.Secret = []byte(rawCookieSecret)
 	opts.Providers[0].ID = "providerID"
 	opts.Providers[0].ClientID = clientID
-	opts.Providers[0].ClientSecret = clientSecret
+	opts.Providers[0].ClientSecret = []byte(clientSecret)
 	opts.EmailDomains = []string{"*"}
 
 	// Default injected headers for legacy configuration
@@ -2855,7 +3301,7 @@ func TestProxyAllowedGroups(t *testing.T) {
 			session := &sessions.SessionState{
 				Groups:      tt.groups,
 				Email:       emailAddress,
-				AccessToken: "oauth_token",
+				AccessToken: []byte("oauth_token"),
 				CreatedAt:   &created,
 			}
 
@@ -2991,7 +3437,7 @@ func TestAuthOnlyAllowedGroups(t *testing.T) {
 			session := &sessions.SessionState{
 				Groups:      tc.groups,
 				Email:       emailAddress,
-				AccessToken: "oauth_token",
+				AccessToken: []byte("oauth_token"),
 				CreatedAt:   &created,
 			}
 
@@ -3087,7 +3533,7 @@ func TestAuthOnlyAllowedGroupsWithSkipMethods(t *testing.T) {
 				session := &sessions.SessionState{
 					Groups:      tc.groups,
 					Email:       "test",
-					AccessToken: "oauth_token",
+					AccessToken: []byte("oauth_token"),
 					CreatedAt:   &created,
 				}
 				err = test.SaveSession(session)
@@ -3173,7 +3619,7 @@ func TestAuthOnlyAllowedEmailDomains(t *testing.T) {
 			session := &sessions.SessionState{
 				Groups:      groups,
 				Email:       tc.email,
-				AccessToken: "oauth_token",
+				AccessToken: []byte("oauth_token"),
 				CreatedAt:   &created,
 			}
 
@@ -3240,7 +3686,7 @@ func TestAuthOnlyAllowedEmails(t *testing.T) {
 			session := &sessions.SessionState{
 				Groups:      groups,
 				Email:       tc.email,
-				AccessToken: "oauth_token",
+				AccessToken: []byte("oauth_token"),
 				CreatedAt:   &created,
 			}
 
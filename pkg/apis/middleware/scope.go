@@ -25,6 +25,10 @@ type RequestScope struct {
 	// Otherwise a random UUID is set.
 	RequestID string
 
+	// TraceID is the ID of the trace of the request, from its W3C
+	// `traceparent` header, when the trace is sampled.
+	TraceID string
+
 	// Session details the authenticated users information (if it exists).
 	Session *sessions.SessionState
 
@@ -35,12 +39,23 @@ type RequestScope struct {
 	// ClearSession indicates whether the user should be logged out or not.
 	ClearSession bool
 
+	// ForceSessionRefresh indicates whether the stored session should be
+	// refreshed when it is loaded, regardless of its age.
+	ForceSessionRefresh bool
+
 	// SessionRevalidated indicates whether the session has been revalidated since
 	// it was loaded or not.
 	SessionRevalidated bool
 
 	// Upstream tracks which upstream was used for this request
 	Upstream string
+
+	// Provider is the ID of the provider that authenticates the request
+	Provider string
+
+	// ErrorCode is the machine-readable code of the error that the request
+	// failed with, if any.
+	ErrorCode string
 }
 
 // GetRequestScope returns the current request scope from the given request
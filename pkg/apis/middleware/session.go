@@ -49,9 +49,9 @@ func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 			User:              claims.Subject,
 			Groups:            claims.Groups,
 			PreferredUsername: claims.PreferredUsername,
-			AccessToken:       token,
-			IDToken:           token,
-			RefreshToken:      "",
+			AccessToken:       []byte(token),
+			IDToken:           []byte(token),
+			RefreshToken:      nil,
 			ExpiresOn:         &idToken.Expiry,
 		}
 
@@ -29,6 +29,15 @@ type AlphaOptions struct {
 	// or from a static secret value.
 	InjectResponseHeaders []Header `json:"injectResponseHeaders,omitempty"`
 
+	// AuthResponseHeaders is used to configure the headers that are returned
+	// by the `/oauth2/auth` endpoint when using the proxy as an external
+	// authentication provider.
+	// Ingress controllers copy these headers to the upstream request, and
+	// each expects its own header names (eg. NGINX `auth_response_headers`).
+	// When set, these headers are returned instead of InjectResponseHeaders
+	// for `/oauth2/auth` responses.
+	AuthResponseHeaders []Header `json:"authResponseHeaders,omitempty"`
+
 	// Server is used to configure the HTTP(S) server for the proxy application.
 	// You may choose to run both HTTP and HTTPS servers simultaneously.
 	// This can be done by setting the BindAddress and the SecureBindAddress simultaneously.
@@ -43,6 +52,29 @@ type AlphaOptions struct {
 
 	// Providers is used to configure multiple providers.
 	Providers Providers `json:"providers,omitempty"`
+
+	// Tenants is used to serve multiple applications, each with its own
+	// provider, session cookie and upstreams, from a single proxy.
+	// Requests are served by the tenant whose hosts match the Host header,
+	// or by the main configuration when no tenant matches.
+	Tenants []Tenant `json:"tenants,omitempty"`
+
+	// RedirectPolicy allows redirects to absolute URLs by scheme, port, path
+	// and regex, in addition to the whitelist domains.
+	RedirectPolicy *RedirectPolicy `json:"redirectPolicy,omitempty"`
+
+	// CookiePolicies override the SameSite and Secure attributes of the
+	// cookies for requests to particular hosts.
+	CookiePolicies []CookiePolicy `json:"cookiePolicies,omitempty"`
+
+	// UserInfo selects, renames and nests the fields of the response of the
+	// `/oauth2/userinfo` endpoint.
+	UserInfo *UserInfo `json:"userInfo,omitempty"`
+
+	// KubernetesController enables the controller mode, in which routes and
+	// providers are also loaded from custom resources in a Kubernetes
+	// namespace and reconciled as they change.
+	KubernetesController *KubernetesController `json:"kubernetesController,omitempty"`
 }
 
 // MergeInto replaces alpha options in the Options struct with the values
@@ -51,9 +83,15 @@ func (a *AlphaOptions) MergeInto(opts *Options) {
 	opts.UpstreamServers = a.UpstreamConfig
 	opts.InjectRequestHeaders = a.InjectRequestHeaders
 	opts.InjectResponseHeaders = a.InjectResponseHeaders
+	opts.AuthResponseHeaders = a.AuthResponseHeaders
 	opts.Server = a.Server
 	opts.MetricsServer = a.MetricsServer
 	opts.Providers = a.Providers
+	opts.Tenants = a.Tenants
+	opts.RedirectPolicy = a.RedirectPolicy
+	opts.Cookie.Policies = a.CookiePolicies
+	opts.UserInfo = a.UserInfo
+	opts.KubernetesController = a.KubernetesController
 }
 
 // ExtractFrom populates the fields in the AlphaOptions with the values from
@@ -62,7 +100,13 @@ func (a *AlphaOptions) ExtractFrom(opts *Options) {
 	a.UpstreamConfig = opts.UpstreamServers
 	a.InjectRequestHeaders = opts.InjectRequestHeaders
 	a.InjectResponseHeaders = opts.InjectResponseHeaders
+	a.AuthResponseHeaders = opts.AuthResponseHeaders
 	a.Server = opts.Server
 	a.MetricsServer = opts.MetricsServer
 	a.Providers = opts.Providers
+	a.Tenants = opts.Tenants
+	a.RedirectPolicy = opts.RedirectPolicy
+	a.CookiePolicies = opts.Cookie.Policies
+	a.UserInfo = opts.UserInfo
+	a.KubernetesController = opts.KubernetesController
 }
@@ -9,10 +9,18 @@ type Templates struct {
 	// template.
 	// These files will be used instead of the default templates if present.
 	// If either file is missing, the default will be used instead.
+	// Message catalogs in a locales folder, eg locales/de.json, override the
+	// messages of the pages in their language.
+	// Templates in a partials folder can be included by the templates, and
+	// the files of a static folder are served under /oauth2/static/.
 	Path string `flag:"custom-templates-dir" cfg:"custom_templates_dir"`
 
+	// Watch reloads the templates, partials and message catalogs when the
+	// files of the custom templates folder change.
+	Watch bool `flag:"watch-custom-templates-dir" cfg:"watch_custom_templates_dir"`
+
 	// CustomLogo is the path or a URL to a logo that should replace the default logo
-	// on the sign_in page template.
+	// on the sign_in page template. It is also rendered on the error pages.
 	// Supported formats are .svg, .png, .jpg and .jpeg.
 	// If URL is used the format support depends on the browser.
 	// To disable the default logo, set this value to "-".
@@ -25,6 +33,23 @@ type Templates struct {
 	// Footer overrides the default sign_in page footer text.
 	Footer string `flag:"footer" cfg:"footer"`
 
+	// FooterLinks are links rendered in the footer of the sign_in and error
+	// pages, in the format `label=url`.
+	FooterLinks []string `flag:"footer-link" cfg:"footer_links"`
+
+	// BrandColor is the color of the buttons and links of the sign_in and
+	// error pages, as a hex color, eg #0078d4, or a color name.
+	BrandColor string `flag:"brand-color" cfg:"brand_color"`
+
+	// BrandBackgroundColor is the background color of the sign_in and error
+	// pages, as a hex color or a color name.
+	BrandBackgroundColor string `flag:"brand-background-color" cfg:"brand_background_color"`
+
+	// DefaultLanguage is the language of the sign_in and error pages when
+	// none of the languages of the Accept-Language header of the request
+	// have a message catalog.
+	DefaultLanguage string `flag:"default-language" cfg:"default_language"`
+
 	// DisplayLoginForm determines whether the sign_in page should render a
 	// password form if a static passwords file (htpasswd file) has been
 	// configured.
@@ -35,17 +60,50 @@ type Templates struct {
 	// information.
 	// Use only for diagnosing backend errors.
 	Debug bool `flag:"show-debug-on-error" cfg:"show_debug_on_error"`
+
+	// ContentSecurityPolicy is the Content-Security-Policy header of the
+	// sign_in and error pages.
+	// Any `{nonce}` placeholders are replaced with a nonce generated for each
+	// page. Templates pass the nonce to their inline scripts and styles with
+	// `nonce="{{.CSPNonce}}"`.
+	ContentSecurityPolicy string `flag:"pages-content-security-policy" cfg:"pages_content_security_policy"`
+
+	// ReferrerPolicy is the Referrer-Policy header of the sign_in and error
+	// pages.
+	ReferrerPolicy string `flag:"pages-referrer-policy" cfg:"pages_referrer_policy"`
+
+	// FrameAncestors is the source list of the frame-ancestors directive added
+	// to the Content-Security-Policy of the sign_in and error pages, eg 'none'.
+	FrameAncestors string `flag:"pages-frame-ancestors" cfg:"pages_frame_ancestors"`
+
+	// ProviderSearch renders a search box filtering the provider buttons of
+	// the sign_in page when there are several providers.
+	ProviderSearch bool `flag:"sign-in-provider-search" cfg:"sign_in_provider_search"`
+
+	// RememberProvider remembers the provider users last signed in with in a
+	// preference cookie, and highlights its button on the sign_in page.
+	RememberProvider bool `flag:"sign-in-remember-provider" cfg:"sign_in_remember_provider"`
 }
 
 func templatesFlagSet() *pflag.FlagSet {
 	flagSet := pflag.NewFlagSet("templates", pflag.ExitOnError)
 
 	flagSet.String("custom-templates-dir", "", "path to custom html templates")
+	flagSet.Bool("watch-custom-templates-dir", false, "reload the custom html templates, partials and message catalogs when they change")
 	flagSet.String("custom-sign-in-logo", "", "path or URL to an custom image for the sign_in page logo. Use \"-\" to disable default logo.")
 	flagSet.String("banner", "", "custom banner string. Use \"-\" to disable default banner.")
 	flagSet.String("footer", "", "custom footer string. Use \"-\" to disable default footer.")
+	flagSet.StringSlice("footer-link", []string{}, "link rendered in the footer of the sign_in and error pages, in the format label=url (may be given multiple times)")
+	flagSet.String("brand-color", "", "color of the buttons and links of the sign_in and error pages, as a hex color or a color name")
+	flagSet.String("brand-background-color", "", "background color of the sign_in and error pages, as a hex color or a color name")
+	flagSet.String("default-language", "en", "language of the sign_in and error pages when the Accept-Language header of the request matches no message catalog")
 	flagSet.Bool("display-htpasswd-form", true, "display username / password login form if an htpasswd file is provided")
 	flagSet.Bool("show-debug-on-error", false, "show detailed error information on error pages (WARNING: this may contain sensitive information - do not use in production)")
+	flagSet.String("pages-content-security-policy", "", "Content-Security-Policy of the sign_in and error pages. {nonce} is replaced with a nonce generated for each page")
+	flagSet.String("pages-referrer-policy", "", "Referrer-Policy of the sign_in and error pages")
+	flagSet.String("pages-frame-ancestors", "", "sources allowed to frame the sign_in and error pages, added to their Content-Security-Policy as frame-ancestors (eg 'none')")
+	flagSet.Bool("sign-in-provider-search", false, "render a search box filtering the provider buttons of the sign_in page when there are several providers")
+	flagSet.Bool("sign-in-remember-provider", false, "remember the provider users last signed in with in a preference cookie, and highlight its button on the sign_in page")
 
 	return flagSet
 }
@@ -54,5 +112,6 @@ func templatesFlagSet() *pflag.FlagSet {
 func templatesDefaults() Templates {
 	return Templates{
 		DisplayLoginForm: true,
+		DefaultLanguage:  "en",
 	}
 }
@@ -1,6 +1,7 @@
 package options
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -19,6 +20,30 @@ type SecretSource struct {
 	FromFile string `json:"fromFile,omitempty"`
 }
 
+// Secret holds a secret in a byte slice rather than a string, so that it can
+// be wiped from memory once the configuration is no longer used.
+// Intentional blank line below to keep this first part of the comment out of
+// any generated references.
+
+// Secret is a secret string, such as a client secret.
+// +reference-gen:alias-name=string
+type Secret []byte
+
+// UnmarshalJSON sets the secret to the bytes of the string.
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = Secret(str)
+	return nil
+}
+
+// MarshalJSON marshals the secret as a string.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
 // Duration is an alias for time.Duration so that we can ensure the marshalling
 // and unmarshalling of string durations is done as users expect.
 // Intentional blank line below to keep this first part of the comment out of
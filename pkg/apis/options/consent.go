@@ -0,0 +1,30 @@
+package options
+
+import "github.com/spf13/pflag"
+
+// Consent contains the options for the terms users accept on an interstitial
+// page before they first access the upstreams, eg. a legal banner.
+type Consent struct {
+	// TermsFile is the path of an HTML file with the terms users accept.
+	// Users are not asked to accept terms when it is empty.
+	TermsFile string `flag:"consent-terms-file" cfg:"consent_terms_file"`
+
+	// Version is the version of the terms. Users accept the terms again when
+	// it changes.
+	Version string `flag:"consent-version" cfg:"consent_version"`
+
+	// Persist remembers the version of the terms each user accepted in the
+	// redis session store, so that they are not asked again when they sign
+	// in with a new session.
+	Persist bool `flag:"consent-persist" cfg:"consent_persist"`
+}
+
+func consentFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("consent", pflag.ExitOnError)
+
+	flagSet.String("consent-terms-file", "", "path of an HTML file with terms users accept on an interstitial page before they first access the upstreams")
+	flagSet.String("consent-version", "", "version of the consent terms; users accept the terms again when it changes")
+	flagSet.Bool("consent-persist", false, "remember the version of the consent terms each user accepted in the redis session store, rather than only in their session")
+
+	return flagSet
+}
@@ -8,23 +8,69 @@ import (
 
 // Cookie contains configuration options relating to Cookie configuration
 type Cookie struct {
-	Name           string        `flag:"cookie-name" cfg:"cookie_name"`
-	Secret         string        `flag:"cookie-secret" cfg:"cookie_secret"`
-	Domains        []string      `flag:"cookie-domain" cfg:"cookie_domains"`
-	Path           string        `flag:"cookie-path" cfg:"cookie_path"`
-	Expire         time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
-	Refresh        time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
-	Secure         bool          `flag:"cookie-secure" cfg:"cookie_secure"`
-	HTTPOnly       bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
-	SameSite       string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
-	CSRFPerRequest bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
-	CSRFExpire     time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
+	Name                string        `flag:"cookie-name" cfg:"cookie_name"`
+	LegacyNames         []string      `flag:"cookie-legacy-name" cfg:"cookie_legacy_names"`
+	Secret              Secret        `flag:"cookie-secret" cfg:"cookie_secret"`
+	Domains             []string      `flag:"cookie-domain" cfg:"cookie_domains"`
+	Path                string        `flag:"cookie-path" cfg:"cookie_path"`
+	Expire              time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
+	Refresh             time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
+	Secure              bool          `flag:"cookie-secure" cfg:"cookie_secure"`
+	HTTPOnly            bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
+	SameSite            string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
+	CSRFPerRequest      bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
+	CSRFExpire          time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
+	CSRFPerRequestLimit int           `flag:"cookie-csrf-per-request-limit" cfg:"cookie_csrf_per_request_limit"`
+	CSRFCombined        bool          `flag:"cookie-csrf-combined" cfg:"cookie_csrf_combined"`
+	Cipher              string        `flag:"cookie-cipher" cfg:"cookie_cipher"`
+	CipherRejectLegacy  bool          `flag:"cookie-cipher-reject-legacy" cfg:"cookie_cipher_reject_legacy"`
+	Prefix              string        `flag:"cookie-prefix" cfg:"cookie_prefix"`
+	RememberMe          bool          `flag:"cookie-remember-me" cfg:"cookie_remember_me"`
+
+	// Not used in the legacy config, name not allowed to match an external key (cookiePolicies)
+	Policies []CookiePolicy `cfg:",internal"`
+}
+
+// CookiePolicy overrides the SameSite and Secure attributes of the cookies
+// set for requests to particular hosts, so that the cookies of one
+// application can differ from those of the others, eg. to allow an
+// application embedded in an iframe on another site to sign in.
+type CookiePolicy struct {
+	// Hosts are the hosts the policy applies to, which are matched against
+	// the Host header (or X-Forwarded-Host when the reverse proxy option is
+	// set).
+	// A leading `*.` matches any subdomain, eg. `*.example.com`.
+	// Policies for exact hosts take precedence over those for subdomains.
+	Hosts []string `json:"hosts,omitempty"`
+
+	// SameSite overrides the SameSite attribute of the cookies, one of
+	// `lax`, `strict` or `none`.
+	// The cookie_samesite option applies when it is not set.
+	SameSite string `json:"sameSite,omitempty"`
+
+	// Secure overrides the Secure attribute of the cookies.
+	// The cookie_secure option applies when it is not set.
+	// Cookies with a SameSite attribute of `none` must be secure.
+	Secure *bool `json:"secure,omitempty"`
 }
 
+// The values of the cookie prefix option, which add the prefix to the names
+// of the cookies and set the attributes browsers require for it.
+const (
+	// CookiePrefixHost adds the __Host- prefix, which requires a secure
+	// cookie with a path of "/" and no domain
+	CookiePrefixHost = "host"
+
+	// CookiePrefixSecure adds the __Secure- prefix, which requires a secure
+	// cookie
+	CookiePrefixSecure = "secure"
+)
+
 func cookieFlagSet() *pflag.FlagSet {
 	flagSet := pflag.NewFlagSet("cookie", pflag.ExitOnError)
 
 	flagSet.String("cookie-name", "_oauth2_proxy", "the name of the cookie that the oauth_proxy creates")
+	flagSet.StringSlice("cookie-legacy-name", []string{}, "previous names of the session cookie, which are still read until the session is next saved with the cookie-name (may be given multiple times)")
 	flagSet.String("cookie-secret", "", "the seed string for secure cookies (optionally base64 encoded)")
 	flagSet.StringSlice("cookie-domain", []string{}, "Optional cookie domains to force cookies to (ie: `.yourcompany.com`). The longest domain matching the request's host will be used (or the shortest cookie domain if there is no match).")
 	flagSet.String("cookie-path", "/", "an optional cookie path to force cookies to (ie: /poc/)*")
@@ -35,22 +81,35 @@ func cookieFlagSet() *pflag.FlagSet {
 	flagSet.String("cookie-samesite", "", "set SameSite cookie attribute (ie: \"lax\", \"strict\", \"none\", or \"\"). ")
 	flagSet.Bool("cookie-csrf-per-request", false, "When this property is set to true, then the CSRF cookie name is built based on the state and varies per request. If property is set to false, then CSRF cookie has the same name for all requests.")
 	flagSet.Duration("cookie-csrf-expire", time.Duration(15)*time.Minute, "expire timeframe for CSRF cookie")
+	flagSet.Int("cookie-csrf-per-request-limit", 0, "the maximum number of per-request CSRF cookies, evicting the oldest when a new one is created; 0 for no limit")
+	flagSet.Bool("cookie-csrf-combined", false, "keep the per-request CSRF states in a single encrypted cookie, holding at most cookie-csrf-per-request-limit states (5 by default, at most 10)")
+	flagSet.String("cookie-cipher", "aes-cfb", "the algorithm encrypting session and CSRF cookies: \"aes-cfb\", \"aes-gcm\", \"xchacha20-poly1305\" or \"aes-gcm-siv\". Cookies encrypted with any of them are still decrypted")
+	flagSet.Bool("cookie-cipher-reject-legacy", false, "no longer decrypt cookies encrypted by the unauthenticated aes-cfb algorithm, once the sessions have been saved with another cookie-cipher")
+	flagSet.String("cookie-prefix", "", "prefix the names of the cookies with __Host- (\"host\") or __Secure- (\"secure\"), setting the attributes browsers require for the prefix")
+	flagSet.Bool("cookie-remember-me", false, "let users choose when signing in whether to stay signed in for cookie-expire, with a \"keep me signed in\" checkbox or the remember_me=true parameter, rather than until the browser is closed")
 	return flagSet
 }
 
 // cookieDefaults creates a Cookie populating each field with its default value
 func cookieDefaults() Cookie {
 	return Cookie{
-		Name:           "_oauth2_proxy",
-		Secret:         "",
-		Domains:        nil,
-		Path:           "/",
-		Expire:         time.Duration(168) * time.Hour,
-		Refresh:        time.Duration(0),
-		Secure:         true,
-		HTTPOnly:       true,
-		SameSite:       "",
-		CSRFPerRequest: false,
-		CSRFExpire:     time.Duration(15) * time.Minute,
+		Name:                "_oauth2_proxy",
+		LegacyNames:         nil,
+		Secret:              nil,
+		Domains:             nil,
+		Path:                "/",
+		Expire:              time.Duration(168) * time.Hour,
+		Refresh:             time.Duration(0),
+		Secure:              true,
+		HTTPOnly:            true,
+		SameSite:            "",
+		CSRFPerRequest:      false,
+		CSRFExpire:          time.Duration(15) * time.Minute,
+		CSRFPerRequestLimit: 0,
+		CSRFCombined:        false,
+		Cipher:              "aes-cfb",
+		CipherRejectLegacy:  false,
+		Prefix:              "",
+		RememberMe:          false,
 	}
 }
@@ -25,6 +25,9 @@ type HeaderValue struct {
 
 	// Allow users to load the value from a session claim
 	*ClaimSource `json:",omitempty"`
+
+	// Allow users to render the value from a template of the session
+	*TemplateSource `json:",omitempty"`
 }
 
 // ClaimSource allows loading a header value from a claim within the session
@@ -41,4 +44,102 @@ type ClaimSource struct {
 	// Note the value of claim will become the basic auth username and the
 	// basicAuthPassword will be used as the password value.
 	BasicAuthPassword *SecretSource `json:"basicAuthPassword,omitempty"`
+
+	// Join is an optional separator used to join multiple values of the claim
+	// into a single header value.
+	// Eg: `,` to send groups as `X-Groups: admins,users` rather than one
+	// header value per group.
+	// By default each value of the claim is added as a separate header value.
+	Join string `json:"join,omitempty"`
+}
+
+// TemplateSource allows rendering a header value from a Go template.
+// The template is executed with the session state, so session fields such as
+// `{{ .Email }}` and `{{ .User }}` are available, as well as claims through
+// `{{ .GetClaim "name" }}`.
+// The `join` function joins a list of values with a separator,
+// eg: `{{ join ";" .Groups }}`.
+type TemplateSource struct {
+	// Template is the Go template used to render the header value.
+	// Empty rendered values are not added to the header.
+	Template string `json:"template,omitempty"`
+}
+
+// RequestHeaderPolicy modifies the request headers sent to a single upstream.
+// Headers are removed, then renamed, then set, then encrypted.
+type RequestHeaderPolicy struct {
+	// Remove is a list of request headers that are removed before the request
+	// is proxied to the upstream.
+	// Eg: `X-Forwarded-User` to strip any spoofed identity header when the
+	// upstream is reached through a route that skips authentication.
+	Remove []string `json:"remove,omitempty"`
+
+	// Rename renames request headers to the names expected by the upstream.
+	// Identity headers injected by OAuth2 Proxy may be renamed.
+	Rename []HeaderRename `json:"rename,omitempty"`
+
+	// Set adds static headers to the request, replacing any existing values.
+	Set []StaticHeader `json:"set,omitempty"`
+
+	// Encrypt encrypts request headers for the upstream, so that they can
+	// only be read by the upstream and not by any hops in between.
+	Encrypt *HeaderEncryption `json:"encrypt,omitempty"`
+}
+
+// HeaderEncryption encrypts request headers as compact JWEs (RFC 7516) for
+// the public key of an upstream.
+// Each value of the headers is encrypted separately, with A256GCM, and its
+// content key is encrypted with RSA-OAEP-256 for RSA keys or ECDH-ES+A256KW
+// for ECDSA keys.
+type HeaderEncryption struct {
+	// Headers are the names of the headers to encrypt.
+	// Eg: `X-Forwarded-Access-Token` or `Authorization`.
+	Headers []string `json:"headers,omitempty"`
+
+	// PublicKeyFile is the path to the PEM encoded RSA or ECDSA public key,
+	// or a certificate of one, of the upstream.
+	PublicKeyFile string `json:"publicKeyFile,omitempty"`
+
+	// KeyID is set as the `kid` of the JWEs, so that the upstream can select
+	// its decryption key when rotating keys.
+	KeyID string `json:"keyID,omitempty"`
+}
+
+// ResponseHeaderPolicy modifies the response headers returned from a single
+// upstream.
+// Headers are removed, then set, then defaulted.
+type ResponseHeaderPolicy struct {
+	// Remove is a list of response headers that are removed before the
+	// response is returned to the client.
+	// Eg: `Server` or `X-Powered-By`.
+	Remove []string `json:"remove,omitempty"`
+
+	// Set adds headers to the response, replacing any values set by the
+	// upstream.
+	// Eg: `Strict-Transport-Security` or `Content-Security-Policy`.
+	Set []StaticHeader `json:"set,omitempty"`
+
+	// Default adds headers to the response only when they were not set by the
+	// upstream.
+	// Eg: `Cache-Control` or `X-Frame-Options`.
+	Default []StaticHeader `json:"default,omitempty"`
+}
+
+// HeaderRename renames a header.
+type HeaderRename struct {
+	// From is the name of the existing header.
+	From string `json:"from,omitempty"`
+
+	// To is the new name of the header.
+	// Any existing values for the new name are replaced.
+	To string `json:"to,omitempty"`
+}
+
+// StaticHeader is a header with a fixed value.
+type StaticHeader struct {
+	// Name is the name of the header.
+	Name string `json:"name,omitempty"`
+
+	// Value is the value of the header.
+	Value string `json:"value,omitempty"`
 }
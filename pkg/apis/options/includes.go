@@ -0,0 +1,137 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// includesKey is the key of the list of files that a YAML configuration file
+// includes.
+const includesKey = "includes"
+
+// resolveIncludes loads the files listed under the includes key of the
+// decoded document and merges the document over them.
+// Included files are merged in order, each over the last, and may include
+// other files themselves. Relative paths are resolved from the directory of
+// the file that includes them.
+// The stack holds the files currently being included, to detect cycles.
+func resolveIncludes(path string, document interface{}, stack []string) (interface{}, error) {
+	m, ok := document.(map[string]interface{})
+	if !ok {
+		return document, nil
+	}
+	value, ok := m[includesKey]
+	if !ok {
+		return document, nil
+	}
+	delete(m, includesKey)
+
+	includes, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of file paths", includesKey)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve path %q: %v", path, err)
+	}
+	stack = append(stack, absPath)
+
+	var merged interface{}
+	for _, include := range includes {
+		includePath, ok := include.(string)
+		if !ok || includePath == "" {
+			return nil, fmt.Errorf("%s must be a list of file paths", includesKey)
+		}
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(absPath), includePath)
+		}
+		for _, including := range stack {
+			if including == includePath {
+				return nil, errors.New("include cycle: " + strings.Join(append(stack, includePath), " -> "))
+			}
+		}
+
+		data, err := ioutil.ReadFile(includePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load included file: %w", err)
+		}
+		included, err := decodeYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling included file %q: %w", includePath, err)
+		}
+		included, err = resolveIncludes(includePath, included, stack)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeYAML(merged, included)
+	}
+
+	return mergeYAML(merged, m), nil
+}
+
+// mergeYAML deep merges the overlay over the base document.
+// Maps are merged key by key and a null value in the overlay removes the key.
+// Lists of maps that all have an id are merged by id: entries with the same
+// id are merged and other entries in the overlay are appended. Any other
+// value in the overlay, including other lists, replaces the value in the base.
+func mergeYAML(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return o
+		}
+		for key, value := range o {
+			if value == nil {
+				delete(b, key)
+				continue
+			}
+			b[key] = mergeYAML(b[key], value)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || !hasIDs(b) || !hasIDs(o) {
+			return o
+		}
+		for _, entry := range o {
+			id := entry.(map[string]interface{})["id"]
+			merged := false
+			for i, existing := range b {
+				if existing.(map[string]interface{})["id"] == id {
+					b[i] = mergeYAML(existing, entry)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				b = append(b, entry)
+			}
+		}
+		return b
+	default:
+		return overlay
+	}
+}
+
+// hasIDs determines whether every entry of the list is a map with a string
+// id.
+func hasIDs(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, entry := range list {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := m["id"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package options
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Includes", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "oauth2-proxy-test-includes")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	type includesTableInput struct {
+		files          map[string]string
+		expectedOutput *AlphaOptions
+		expectedErr    error
+	}
+
+	DescribeTable("LoadYAML",
+		func(in includesTableInput) {
+			for name, content := range in.files {
+				Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(Succeed())
+			}
+
+			into := &AlphaOptions{}
+			err := LoadYAML(filepath.Join(dir, "config.yaml"), into)
+			if in.expectedErr != nil {
+				Expect(err).To(MatchError(strings.ReplaceAll(in.expectedErr.Error(), "DIR", dir)))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(into).To(Equal(in.expectedOutput))
+		},
+		Entry("with an overlay", includesTableInput{
+			files: map[string]string{
+				"base.yaml": `
+upstreamConfig:
+  proxyRawPath: true
+  upstreams:
+  - id: app
+    path: /
+    uri: http://app
+  - id: api
+    path: /api/
+    uri: http://api
+providers:
+- id: provider
+  clientID: base
+  clientSecret: secret
+`,
+				"config.yaml": `
+includes:
+- base.yaml
+upstreamConfig:
+  upstreams:
+  - id: app
+    uri: http://app.production
+  - id: admin
+    path: /admin/
+    uri: http://admin
+providers:
+- id: provider
+  clientID: production
+  clientSecret: null
+`,
+			},
+			expectedOutput: &AlphaOptions{
+				UpstreamConfig: UpstreamConfig{
+					ProxyRawPath: true,
+					Upstreams: []Upstream{
+						{ID: "app", Path: "/", URI: "http://app.production"},
+						{ID: "api", Path: "/api/", URI: "http://api"},
+						{ID: "admin", Path: "/admin/", URI: "http://admin"},
+					},
+				},
+				Providers: Providers{
+					{ID: "provider", ClientID: "production"},
+				},
+			},
+		}),
+		Entry("with nested includes", includesTableInput{
+			files: map[string]string{
+				"common.yaml": `
+providers:
+- id: provider
+  clientID: common
+`,
+				"base.yaml": `
+includes:
+- common.yaml
+upstreamConfig:
+  proxyRawPath: true
+`,
+				"config.yaml": `
+includes:
+- base.yaml
+providers:
+- id: provider
+  clientSecret: secret
+`,
+			},
+			expectedOutput: &AlphaOptions{
+				UpstreamConfig: UpstreamConfig{
+					ProxyRawPath: true,
+				},
+				Providers: Providers{
+					{ID: "provider", ClientID: "common", ClientSecret: []byte("secret")},
+				},
+			},
+		}),
+		Entry("with an include cycle", includesTableInput{
+			files: map[string]string{
+				"base.yaml": `
+includes:
+- config.yaml
+`,
+				"config.yaml": `
+includes:
+- base.yaml
+`,
+			},
+			expectedErr: errors.New("error loading config includes: include cycle: DIR/config.yaml -> DIR/base.yaml -> DIR/config.yaml"),
+		}),
+		Entry("with a missing include", includesTableInput{
+			files: map[string]string{
+				"config.yaml": `
+includes:
+- missing.yaml
+`,
+			},
+			expectedErr: errors.New("error loading config includes: unable to load included file: open DIR/missing.yaml: no such file or directory"),
+		}),
+		Entry("with invalid includes", includesTableInput{
+			files: map[string]string{
+				"config.yaml": `
+includes: base.yaml
+`,
+			},
+			expectedErr: errors.New("error loading config includes: includes must be a list of file paths"),
+		}),
+	)
+})
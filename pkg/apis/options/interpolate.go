@@ -0,0 +1,94 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// interpolationPattern matches `${...}` references, along with an
+	// optional leading `$` used to escape them.
+	interpolationPattern = regexp.MustCompile(`\$?\$\{([^{}]*)\}`)
+
+	// envVarNamePattern matches the names of environment variables that can
+	// be referenced. Other references, such as the `${1}` used in rewrite
+	// targets, are left unchanged.
+	envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// interpolateValue replaces the references in the string values of the
+// decoded YAML document.
+// `${NAME}` is replaced with the value of the environment variable NAME, which
+// must be set, and `${NAME:-default}` with the value of NAME or the default
+// when it is unset or empty. `${file:/path}` is replaced with the contents of
+// the file without any trailing newline, and `$${` with a literal `${`.
+// Only values are interpolated, so the references cannot change the structure
+// of the document, and interpolated values are always strings.
+func interpolateValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return interpolateString(v)
+	case []interface{}:
+		for i := range v {
+			interpolated, err := interpolateValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = interpolated
+		}
+	case map[string]interface{}:
+		for key := range v {
+			interpolated, err := interpolateValue(v[key])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = interpolated
+		}
+	}
+	return value, nil
+}
+
+// interpolateString replaces the references in the string.
+func interpolateString(s string) (string, error) {
+	var errs []string
+	result := interpolationPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			// Escaped reference
+			return match[1:]
+		}
+
+		reference := match[2 : len(match)-1]
+		if strings.HasPrefix(reference, "file:") {
+			path := strings.TrimPrefix(reference, "file:")
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("could not read file %q: %v", path, err))
+				return match
+			}
+			return strings.TrimSuffix(strings.TrimSuffix(string(contents), "\n"), "\r")
+		}
+
+		name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+		if !envVarNamePattern.MatchString(name) {
+			return match
+		}
+		value, ok := os.LookupEnv(name)
+		if hasDefault && value == "" {
+			return defaultValue
+		}
+		if !ok {
+			errs = append(errs, fmt.Sprintf("environment variable %q is not set", name))
+			return match
+		}
+		return value
+	})
+
+	if len(errs) > 0 {
+		return "", errors.New(strings.Join(errs, ", "))
+	}
+	return result, nil
+}
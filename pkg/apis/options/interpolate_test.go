@@ -0,0 +1,98 @@
+package options
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("interpolateValue", func() {
+	var secretFile string
+
+	BeforeEach(func() {
+		file, err := ioutil.TempFile("", "oauth2-proxy-test-secret")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.Write([]byte("file-secret\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		secretFile = file.Name()
+
+		Expect(os.Setenv("OAUTH2_PROXY_TEST_SECRET", "env-secret")).To(Succeed())
+		Expect(os.Setenv("OAUTH2_PROXY_TEST_EMPTY", "")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(secretFile)).To(Succeed())
+		Expect(os.Unsetenv("OAUTH2_PROXY_TEST_SECRET")).To(Succeed())
+		Expect(os.Unsetenv("OAUTH2_PROXY_TEST_EMPTY")).To(Succeed())
+	})
+
+	type interpolateTableInput struct {
+		config       string
+		expectedJSON string
+		expectedErr  error
+	}
+
+	DescribeTable("with references",
+		func(in interpolateTableInput) {
+			config := strings.ReplaceAll(in.config, "SECRET_FILE", secretFile)
+			document, err := decodeYAML([]byte(config))
+			Expect(err).ToNot(HaveOccurred())
+			document, err = interpolateValue(document)
+			if in.expectedErr != nil {
+				Expect(err).To(MatchError(in.expectedErr.Error()))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			data, err := json.Marshal(document)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(in.expectedJSON))
+		},
+		Entry("with an environment variable", interpolateTableInput{
+			config:       "secret: ${OAUTH2_PROXY_TEST_SECRET}",
+			expectedJSON: `{"secret":"env-secret"}`,
+		}),
+		Entry("with a reference within a value", interpolateTableInput{
+			config:       "secret: \"prefix-${OAUTH2_PROXY_TEST_SECRET}-suffix\"",
+			expectedJSON: `{"secret":"prefix-env-secret-suffix"}`,
+		}),
+		Entry("with an unset environment variable", interpolateTableInput{
+			config:      "secret: ${OAUTH2_PROXY_TEST_UNSET}",
+			expectedErr: errors.New("secret: environment variable \"OAUTH2_PROXY_TEST_UNSET\" is not set"),
+		}),
+		Entry("with a default for an unset environment variable", interpolateTableInput{
+			config:       "secret: ${OAUTH2_PROXY_TEST_UNSET:-default}",
+			expectedJSON: `{"secret":"default"}`,
+		}),
+		Entry("with a default for an empty environment variable", interpolateTableInput{
+			config:       "secret: ${OAUTH2_PROXY_TEST_EMPTY:-default}",
+			expectedJSON: `{"secret":"default"}`,
+		}),
+		Entry("with a file", interpolateTableInput{
+			config:       "secrets:\n- ${file:SECRET_FILE}",
+			expectedJSON: `{"secrets":["file-secret"]}`,
+		}),
+		Entry("with a missing file", interpolateTableInput{
+			config:      "secret: ${file:/does/not/exist}",
+			expectedErr: errors.New("secret: could not read file \"/does/not/exist\": open /does/not/exist: no such file or directory"),
+		}),
+		Entry("with an escaped reference", interpolateTableInput{
+			config:       "secret: $${OAUTH2_PROXY_TEST_SECRET}",
+			expectedJSON: `{"secret":"${OAUTH2_PROXY_TEST_SECRET}"}`,
+		}),
+		Entry("with a regular expression reference", interpolateTableInput{
+			config:       "rewriteTarget: /${1}",
+			expectedJSON: `{"rewriteTarget":"/${1}"}`,
+		}),
+		Entry("with a reference in a key", interpolateTableInput{
+			config:       "${OAUTH2_PROXY_TEST_SECRET}: 1",
+			expectedJSON: `{"${OAUTH2_PROXY_TEST_SECRET}":1}`,
+		}),
+	)
+})
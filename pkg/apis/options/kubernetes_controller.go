@@ -0,0 +1,24 @@
+package options
+
+// KubernetesController configures the proxy to load routes and providers
+// from custom resources in a Kubernetes namespace, so that they can be
+// managed with GitOps and changed without restarting the proxy.
+// The proxy must run within the cluster, with a service account that may
+// get, list and watch the `OAuth2ProxyConfig` and `ProtectedRoute` resources
+// of the `oauth2-proxy.github.io` group.
+type KubernetesController struct {
+	// Namespace is the namespace of the custom resources.
+	// Defaults to the namespace of the pod.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ConfigName is the name of the `OAuth2ProxyConfig` resource whose
+	// providers, including their allowed groups, replace the configured
+	// providers.
+	// Defaults to `oauth2-proxy`.
+	ConfigName string `json:"configName,omitempty"`
+
+	// RouteSelector is a label selector that limits the `ProtectedRoute`
+	// resources that are served, eg. `app.kubernetes.io/instance=internal`.
+	// By default all `ProtectedRoute` resources in the namespace are served.
+	RouteSelector string `json:"routeSelector,omitempty"`
+}
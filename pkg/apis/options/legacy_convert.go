@@ -0,0 +1,588 @@
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExtractFrom populates the legacy options with the values from the Options,
+// reversing ToOptions so that alpha configuration can be converted back to
+// the legacy configuration.
+// The legacy options should be created with NewLegacyOptions so that options
+// without a value in the Options keep their legacy defaults.
+// The returned notes describe the options that cannot be converted, as they
+// have no equivalent in the legacy configuration.
+func (l *LegacyOptions) ExtractFrom(opts *Options) []string {
+	l.Options = *opts
+
+	var notes []string
+	notes = append(notes, l.LegacyUpstreams.extractFrom(opts.UpstreamServers)...)
+	notes = append(notes, l.LegacyHeaders.extractFrom(opts.InjectRequestHeaders, opts.InjectResponseHeaders)...)
+	if len(opts.AuthResponseHeaders) > 0 {
+		notes = append(notes, notConverted("authResponseHeaders"))
+	}
+	notes = append(notes, l.LegacyServer.extractFrom(opts.Server, opts.MetricsServer)...)
+	notes = append(notes, l.LegacyProvider.extractFrom(opts.Providers)...)
+	if len(opts.Tenants) > 0 {
+		notes = append(notes, notConverted("tenants"))
+	}
+	if len(opts.Cookie.Policies) > 0 {
+		notes = append(notes, notConverted("cookiePolicies"))
+	}
+	if opts.UserInfo != nil {
+		notes = append(notes, notConverted("userInfo"))
+	}
+	if opts.KubernetesController != nil {
+		notes = append(notes, notConverted("kubernetesController"))
+	}
+
+	l.Options.LegacyPreferEmailToUser = l.LegacyHeaders.PreferEmailToUser
+	return notes
+}
+
+func (l *LegacyUpstreams) extractFrom(upstreams UpstreamConfig) []string {
+	var notes []string
+	if upstreams.ProxyRawPath {
+		notes = append(notes, notConverted("upstreamConfig.proxyRawPath"))
+	}
+	l.DynamicUpstreamsFile = upstreams.DynamicUpstreamsFile
+
+	// The legacy proxy options apply to every upstream, so are taken from
+	// the first upstream that is proxied
+	for _, upstream := range upstreams.Upstreams {
+		if !upstream.Static {
+			l.extractProxyOptions(upstream)
+			break
+		}
+	}
+
+	l.Upstreams = nil
+	for i, upstream := range upstreams.Upstreams {
+		path := fmt.Sprintf("upstreamConfig.upstreams[%d]", i)
+		upstreamString, err := legacyUpstreamString(upstream)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("%s: %v", notConverted(path), err))
+			continue
+		}
+		l.Upstreams = append(l.Upstreams, upstreamString)
+
+		single := *l
+		single.Upstreams = []string{upstreamString}
+		converted, err := single.convert()
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("%s: %v", notConverted(path), err))
+			continue
+		}
+		convertedUpstream := converted.Upstreams[0]
+		convertedUpstream.ID = upstream.ID
+		notes = append(notes, unconvertedFields(path, withUpstreamDefaults(upstream), convertedUpstream)...)
+	}
+
+	return notes
+}
+
+// extractProxyOptions sets the legacy proxy options from the upstream.
+func (l *LegacyUpstreams) extractProxyOptions(upstream Upstream) {
+	upstream = withUpstreamDefaults(upstream)
+	l.FlushInterval = upstream.FlushInterval.Duration()
+	l.PassHostHeader = *upstream.PassHostHeader
+	l.ProxyWebSockets = *upstream.ProxyWebSockets
+	l.SSLUpstreamInsecureSkipVerify = upstream.InsecureSkipTLSVerify
+	l.Timeout = upstream.Timeout.Duration()
+}
+
+// withUpstreamDefaults sets the defaults of the proxy options that are unset
+// on a proxied upstream, which the legacy configuration always sets.
+func withUpstreamDefaults(upstream Upstream) Upstream {
+	if upstream.Static {
+		return upstream
+	}
+	if upstream.FlushInterval == nil {
+		flushInterval := Duration(DefaultUpstreamFlushInterval)
+		upstream.FlushInterval = &flushInterval
+	}
+	if upstream.PassHostHeader == nil {
+		passHostHeader := true
+		upstream.PassHostHeader = &passHostHeader
+	}
+	if upstream.ProxyWebSockets == nil {
+		proxyWebSockets := true
+		upstream.ProxyWebSockets = &proxyWebSockets
+	}
+	if upstream.Timeout == nil {
+		timeout := Duration(DefaultUpstreamTimeout)
+		upstream.Timeout = &timeout
+	}
+	return upstream
+}
+
+// legacyUpstreamString returns the legacy upstream that serves the upstream
+// path from the upstream URI.
+func legacyUpstreamString(upstream Upstream) (string, error) {
+	if upstream.Static {
+		if upstream.Path != "/" {
+			return "", errors.New("static upstreams are only supported on the root path")
+		}
+		code := 200
+		if upstream.StaticCode != nil {
+			code = *upstream.StaticCode
+		}
+		return fmt.Sprintf("static://%d", code), nil
+	}
+
+	if upstream.URI == "" {
+		return "", errors.New("only upstreams with a single uri are supported")
+	}
+	u, err := url.Parse(upstream.URI)
+	if err != nil {
+		return "", fmt.Errorf("could not parse uri %q: %v", upstream.URI, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if upstream.Path == u.Path {
+			return upstream.URI, nil
+		}
+		return upstream.URI + "#" + upstream.Path, nil
+	case "unix":
+		if upstream.Path == "/" {
+			return upstream.URI, nil
+		}
+		return upstream.URI + "#" + upstream.Path, nil
+	default:
+		uriPath := u.Path
+		if uriPath == "" {
+			uriPath = "/"
+		}
+		if upstream.Path != uriPath {
+			return "", fmt.Errorf("the path %q must match the path of the uri %q", upstream.Path, upstream.URI)
+		}
+		return upstream.URI, nil
+	}
+}
+
+// extractFrom finds the legacy header options that set the headers given.
+// When several combinations of options set the same headers, the combination
+// with the fewest changes from the defaults is used.
+func (l *LegacyHeaders) extractFrom(requestHeaders, responseHeaders []Header) []string {
+	defaults := NewLegacyOptions().LegacyHeaders
+	defaultFlags := defaults.flags()
+
+	candidate := LegacyHeaders{
+		BasicAuthPassword: basicAuthPassword(requestHeaders, responseHeaders),
+	}
+	flags := candidate.flags()
+
+	var found *LegacyHeaders
+	fewestChanges := len(flags) + 1
+	for combination := 0; combination < 1<<len(flags); combination++ {
+		changes := 0
+		for i := range flags {
+			*flags[i] = combination&(1<<i) != 0
+			if *flags[i] != *defaultFlags[i] {
+				changes++
+			}
+		}
+		if changes >= fewestChanges {
+			continue
+		}
+
+		request, response := candidate.convert()
+		if headersEqual(request, requestHeaders) && headersEqual(response, responseHeaders) {
+			match := candidate
+			found = &match
+			fewestChanges = changes
+		}
+	}
+
+	if found == nil {
+		*l = LegacyHeaders{SkipAuthStripHeaders: defaults.SkipAuthStripHeaders}
+		reason := ": only the headers set by the legacy header options are supported"
+		return []string{
+			notConverted("injectRequestHeaders") + reason,
+			notConverted("injectResponseHeaders") + reason,
+		}
+	}
+	*l = *found
+	return nil
+}
+
+// flags returns the boolean options of the legacy headers.
+func (l *LegacyHeaders) flags() []*bool {
+	return []*bool{
+		&l.PassBasicAuth,
+		&l.PassAccessToken,
+		&l.PassUserHeaders,
+		&l.PassAuthorization,
+		&l.SetBasicAuth,
+		&l.SetXAuthRequest,
+		&l.SetAuthorization,
+		&l.PreferEmailToUser,
+		&l.SkipAuthStripHeaders,
+	}
+}
+
+// basicAuthPassword returns the password of the first basic auth header.
+func basicAuthPassword(headerLists ...[]Header) string {
+	for _, headers := range headerLists {
+		for _, header := range headers {
+			for _, value := range header.Values {
+				if value.ClaimSource != nil && value.ClaimSource.BasicAuthPassword != nil {
+					return string(value.ClaimSource.BasicAuthPassword.Value)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// headersEqual determines whether the headers have the same encoding.
+func headersEqual(a, b []Header) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aJSON, bJSON)
+}
+
+func (l *LegacyServer) extractFrom(server, metricsServer Server) []string {
+	defaults := NewLegacyOptions().LegacyServer
+	*l = LegacyServer{
+		HTTPAddress:          server.BindAddress,
+		HTTPSAddress:         server.SecureBindAddress,
+		EnableHTTP2:          server.EnableHTTP2,
+		MetricsAddress:       metricsServer.BindAddress,
+		MetricsSecureAddress: metricsServer.SecureBindAddress,
+	}
+
+	if tls := server.TLS; tls != nil {
+		l.TLSKeyFile = secretSourceFile(tls.Key)
+		l.TLSCertFile = secretSourceFile(tls.Cert)
+		l.TLSMinVersion = tls.MinVersion
+		l.TLSCipherSuites = tls.CipherSuites
+		for _, certificate := range tls.AdditionalCertificates {
+			l.TLSSNICertFiles = append(l.TLSSNICertFiles, secretSourceFile(certificate.Cert))
+			l.TLSSNIKeyFiles = append(l.TLSSNIKeyFiles, secretSourceFile(certificate.Key))
+		}
+		l.TLSClientCAFile = secretSourceFile(tls.ClientCA)
+		l.TLSClientAuth = tls.ClientAuth
+	}
+	if acme := server.ACME; acme != nil {
+		l.ACMEDomains = acme.Domains
+		l.ACMEEmail = acme.Email
+		l.ACMEDirectoryURL = acme.DirectoryURL
+		l.ACMEAcceptTOS = acme.AcceptTOS
+		l.ACMECacheDir = acme.CacheDir
+		l.ACMEUseSessionStore = acme.UseSessionStore
+	}
+	switch {
+	case l.TLSKeyFile != "" || l.TLSCertFile != "":
+		// Only the HTTPS server is run with certificates, so keep the default
+		if server.BindAddress == "" {
+			l.HTTPAddress = defaults.HTTPAddress
+		}
+	case server.ACME == nil && server.SecureBindAddress == "":
+		// The HTTPS server is disabled without certificates, so keep the default
+		l.HTTPSAddress = defaults.HTTPSAddress
+	}
+
+	if tls := metricsServer.TLS; tls != nil {
+		l.MetricsTLSKeyFile = secretSourceFile(tls.Key)
+		l.MetricsTLSCertFile = secretSourceFile(tls.Cert)
+	}
+
+	appServer, convertedMetricsServer := l.convert()
+	notes := unconvertedFields("server", server, appServer)
+	return append(notes, unconvertedFields("metricsServer", metricsServer, convertedMetricsServer)...)
+}
+
+// secretSourceFile returns the file of the secret source, as the legacy
+// configuration only loads certificates and keys from files.
+func secretSourceFile(source *SecretSource) string {
+	if source == nil {
+		return ""
+	}
+	return source.FromFile
+}
+
+func (l *LegacyProvider) extractFrom(providers Providers) []string {
+	if len(providers) == 0 {
+		return nil
+	}
+
+	var notes []string
+	for i := 1; i < len(providers); i++ {
+		notes = append(notes, fmt.Sprintf("%s: only a single provider is supported", notConverted(fmt.Sprintf("providers[%d]", i))))
+	}
+
+	provider := providers[0]
+	defaults := NewLegacyOptions().LegacyProvider
+	*l = LegacyProvider{
+		ClientID:         provider.ClientID,
+		ClientSecret:     string(provider.ClientSecret),
+		ClientSecretFile: provider.ClientSecretFile,
+
+		KeycloakGroups:           provider.KeycloakConfig.Groups,
+		AzureTenant:              provider.AzureConfig.Tenant,
+		BitbucketTeam:            provider.BitbucketConfig.Team,
+		BitbucketRepository:      provider.BitbucketConfig.Repository,
+		GitHubOrg:                provider.GitHubConfig.Org,
+		GitHubTeam:               provider.GitHubConfig.Team,
+		GitHubRepo:               provider.GitHubConfig.Repo,
+		GitHubToken:              provider.GitHubConfig.Token,
+		GitHubUsers:              provider.GitHubConfig.Users,
+		GitLabGroup:              provider.GitLabConfig.Group,
+		GitLabProjects:           provider.GitLabConfig.Projects,
+		GoogleGroups:             provider.GoogleConfig.Groups,
+		GoogleAdminEmail:         provider.GoogleConfig.AdminEmail,
+		GoogleServiceAccountJSON: provider.GoogleConfig.ServiceAccountJSON,
+
+		ProviderType:                       string(provider.Type),
+		ProviderName:                       provider.Name,
+		ProviderCAFiles:                    provider.CAFiles,
+		OIDCIssuerURL:                      provider.OIDCConfig.IssuerURL,
+		InsecureOIDCAllowUnverifiedEmail:   provider.OIDCConfig.InsecureAllowUnverifiedEmail,
+		InsecureOIDCSkipIssuerVerification: provider.OIDCConfig.InsecureSkipIssuerVerification,
+		InsecureOIDCSkipNonce:              provider.OIDCConfig.InsecureSkipNonce,
+		SkipOIDCDiscovery:                  provider.OIDCConfig.SkipDiscovery,
+		OIDCJwksURL:                        provider.OIDCConfig.JwksURL,
+		OIDCEmailClaim:                     provider.OIDCConfig.EmailClaim,
+		OIDCGroupsClaim:                    provider.OIDCConfig.GroupsClaim,
+		OIDCAudienceClaims:                 provider.OIDCConfig.AudienceClaims,
+		OIDCExtraAudiences:                 provider.OIDCConfig.ExtraAudiences,
+		OIDCKeysRefreshInterval:            provider.OIDCConfig.KeysRefreshInterval.Duration(),
+		LoginURL:                           provider.LoginURL,
+		RedeemURL:                          provider.RedeemURL,
+		ProfileURL:                         provider.ProfileURL,
+		ProtectedResource:                  provider.ProtectedResource,
+		ValidateURL:                        provider.ValidateURL,
+		RPInitiatedLogout:                  provider.RPInitiatedLogout,
+		LogoutURL:                          provider.LogoutURL,
+		RevokeURL:                          provider.RevokeURL,
+		Scope:                              provider.Scope,
+		UserIDClaim:                        provider.OIDCConfig.UserIDClaim,
+		AllowedGroups:                      provider.AllowedGroups,
+		AllowedRoles:                       provider.KeycloakConfig.Roles,
+
+		// The default is only used when neither prompt is set
+		ApprovalPrompt: defaults.ApprovalPrompt,
+
+		JWTKey:              provider.LoginGovConfig.JWTKey,
+		JWTKeyFile:          provider.LoginGovConfig.JWTKeyFile,
+		PubJWKURL:           provider.LoginGovConfig.PubJWKURL,
+		CodeChallengeMethod: provider.CodeChallengeMethod,
+	}
+
+	// The legacy options only set a single default value for these parameters
+	for _, param := range provider.LoginURLParameters {
+		if len(param.Default) != 1 || len(param.Allow) != 0 {
+			continue
+		}
+		switch param.Name {
+		case "acr_values":
+			l.AcrValues = param.Default[0]
+		case "prompt":
+			l.Prompt = param.Default[0]
+		case "approval_prompt":
+			l.ApprovalPrompt = param.Default[0]
+		}
+	}
+
+	converted, err := l.convert()
+	if err != nil {
+		return append(notes, fmt.Sprintf("%s: %v", notConverted("providers[0]"), err))
+	}
+	convertedProvider := converted[0]
+	convertedProvider.ID = provider.ID
+	provider.LoginURLParameters = sortedLoginURLParameters(provider.LoginURLParameters)
+	convertedProvider.LoginURLParameters = sortedLoginURLParameters(convertedProvider.LoginURLParameters)
+	return append(notes, unconvertedFields("providers[0]", provider, convertedProvider)...)
+}
+
+// sortedLoginURLParameters returns a copy of the parameters sorted by name,
+// so that they can be compared regardless of their order.
+func sortedLoginURLParameters(params []LoginURLParameter) []LoginURLParameter {
+	sorted := append([]LoginURLParameter{}, params...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// notConverted describes an option that cannot be converted to the legacy
+// configuration.
+func notConverted(path string) string {
+	return path + " cannot be converted to the legacy configuration"
+}
+
+// unconvertedFields compares the JSON encoding of the original options with
+// the options converted back from the legacy options, and describes each
+// field that differs.
+func unconvertedFields(path string, original, converted interface{}) []string {
+	originalFields := jsonFields(path, original)
+	convertedFields := jsonFields(path, converted)
+
+	var notes []string
+	for field, value := range originalFields {
+		if !reflect.DeepEqual(value, convertedFields[field]) {
+			notes = append(notes, notConverted(field))
+		}
+	}
+	for field := range convertedFields {
+		if _, ok := originalFields[field]; !ok {
+			notes = append(notes, field+" is set by the legacy configuration")
+		}
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// jsonFields flattens the JSON encoding of the value into its fields that
+// are set, keyed by their paths.
+func jsonFields(path string, value interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(value)
+	if err != nil {
+		fields[path] = err.Error()
+		return fields
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		fields[path] = err.Error()
+		return fields
+	}
+	addJSONFields(path, decoded, fields)
+	return fields
+}
+
+func addJSONFields(path string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			addJSONFields(path+"."+key, child, fields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			addJSONFields(fmt.Sprintf("%s[%d]", path, i), child, fields)
+		}
+	case nil, bool, string, float64:
+		if v != nil && v != false && v != "" && v != float64(0) {
+			fields[path] = v
+		}
+	}
+}
+
+// MarshalTOML renders the legacy options that differ from their defaults as a
+// TOML configuration file.
+func (l *LegacyOptions) MarshalTOML() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := writeTOML(buf, "", reflect.ValueOf(*l), reflect.ValueOf(*NewLegacyOptions())); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTOML writes the options that differ from the defaults using the names
+// of their `cfg` tags, following the same structure as registerFlags.
+func writeTOML(buf *bytes.Buffer, prefix string, val, defaults reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldName := strings.Join([]string{prefix, field.Name}, ".")
+
+		cfgName := field.Tag.Get("cfg")
+		if cfgName == ",internal" || isUnexported(field.Name) {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := writeTOML(buf, fieldName, val.Field(i), defaults.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, defaultValue := val.Field(i), defaults.Field(i)
+		if reflect.DeepEqual(value.Interface(), defaultValue.Interface()) ||
+			(value.Kind() == reflect.Slice && value.Len() == 0 && defaultValue.Len() == 0) {
+			continue
+		}
+
+		encoded, err := tomlValue(value)
+		if err != nil {
+			return fmt.Errorf("field %q %v", fieldName, err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", cfgName, encoded)
+	}
+	return nil
+}
+
+// tomlValue encodes the value of an option in TOML.
+// Durations are encoded as strings, which are parsed when loaded.
+func tomlValue(value reflect.Value) (string, error) {
+	if d, ok := value.Interface().(time.Duration); ok {
+		return tomlString(d.String()), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return tomlString(value.String()), nil
+	case reflect.Bool:
+		return fmt.Sprintf("%t", value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", value.Int()), nil
+	case reflect.Slice:
+		var values []string
+		for i := 0; i < value.Len(); i++ {
+			encoded, err := tomlValue(value.Index(i))
+			if err != nil {
+				return "", err
+			}
+			values = append(values, encoded)
+		}
+		return "[" + strings.Join(values, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("has unsupported type %s", value.Type())
+	}
+}
+
+// tomlString encodes a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Legacy Options Conversion", func() {
+	Context("ExtractFrom", func() {
+		DescribeTable("converts options from legacy options back to the same legacy options",
+			func(modify func(*LegacyOptions)) {
+				legacyOpts := NewLegacyOptions()
+				modify(legacyOpts)
+
+				opts, err := legacyOpts.ToOptions()
+				Expect(err).ToNot(HaveOccurred())
+
+				extracted := NewLegacyOptions()
+				Expect(extracted.ExtractFrom(opts)).To(BeEmpty())
+				Expect(extracted.LegacyUpstreams).To(Equal(legacyOpts.LegacyUpstreams))
+				Expect(extracted.LegacyHeaders).To(Equal(legacyOpts.LegacyHeaders))
+				Expect(extracted.LegacyServer).To(Equal(legacyOpts.LegacyServer))
+				Expect(extracted.LegacyProvider).To(Equal(legacyOpts.LegacyProvider))
+			},
+			Entry("with the default options", func(*LegacyOptions) {}),
+			Entry("with upstreams", func(l *LegacyOptions) {
+				l.LegacyUpstreams.Upstreams = []string{"http://foo.bar/baz", "file:///var/lib/website#/bar", "unix:///var/run/app.sock#/app/", "static://204"}
+				l.LegacyUpstreams.Timeout = 5 * time.Second
+				l.LegacyUpstreams.PassHostHeader = false
+			}),
+			Entry("with response headers and an access token", func(l *LegacyOptions) {
+				l.LegacyHeaders.PassAccessToken = true
+				l.LegacyHeaders.SetXAuthRequest = true
+				l.LegacyHeaders.SetAuthorization = true
+			}),
+			Entry("with a basic auth password and email as the user", func(l *LegacyOptions) {
+				l.LegacyHeaders.BasicAuthPassword = "super-secret"
+				l.LegacyHeaders.PreferEmailToUser = true
+				l.LegacyHeaders.SkipAuthStripHeaders = false
+			}),
+			Entry("with TLS", func(l *LegacyOptions) {
+				l.LegacyServer.TLSCertFile = "tls.crt"
+				l.LegacyServer.TLSKeyFile = "tls.key"
+				l.LegacyServer.TLSMinVersion = "TLS1.3"
+				l.LegacyServer.TLSClientCAFile = "ca.crt"
+				l.LegacyServer.MetricsSecureAddress = ":9443"
+				l.LegacyServer.MetricsTLSCertFile = "metrics.crt"
+				l.LegacyServer.MetricsTLSKeyFile = "metrics.key"
+			}),
+			Entry("with a GitHub provider", func(l *LegacyOptions) {
+				l.LegacyProvider.ProviderType = "github"
+				l.LegacyProvider.ProviderName = "GitHub"
+				l.LegacyProvider.ClientID = "oauth-proxy"
+				l.LegacyProvider.GitHubOrg = "oauth2-proxy"
+				l.LegacyProvider.GitHubUsers = []string{"octocat"}
+				l.LegacyProvider.Prompt = "consent"
+				l.LegacyProvider.AcrValues = "urn:mace:incommon:iap:silver"
+			}),
+		)
+
+		type extractNotesTableInput struct {
+			modify        func(*Options)
+			expectedNotes []string
+		}
+
+		DescribeTable("describes the options that cannot be converted",
+			func(in extractNotesTableInput) {
+				opts, err := NewLegacyOptions().ToOptions()
+				Expect(err).ToNot(HaveOccurred())
+				in.modify(opts)
+
+				Expect(NewLegacyOptions().ExtractFrom(opts)).To(Equal(in.expectedNotes))
+			},
+			Entry("with an upstream rewrite target", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.UpstreamServers.Upstreams = []Upstream{
+						{ID: "api", Path: "/api/", URI: "http://api/api/", RewriteTarget: "/v1/"},
+					}
+				},
+				expectedNotes: []string{
+					"upstreamConfig.upstreams[0].rewriteTarget cannot be converted to the legacy configuration",
+				},
+			}),
+			Entry("with an upstream path that differs from the uri", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.UpstreamServers.Upstreams = []Upstream{
+						{ID: "api", Path: "/api/", URI: "http://api/"},
+					}
+				},
+				expectedNotes: []string{
+					"upstreamConfig.upstreams[0] cannot be converted to the legacy configuration: the path \"/api/\" must match the path of the uri \"http://api/\"",
+				},
+			}),
+			Entry("with upstreams with different timeouts", extractNotesTableInput{
+				modify: func(opts *Options) {
+					timeout := Duration(time.Minute)
+					opts.UpstreamServers.Upstreams = []Upstream{
+						{ID: "app", Path: "/", URI: "http://app/"},
+						{ID: "api", Path: "/api/", URI: "http://api/api/", Timeout: &timeout},
+					}
+				},
+				expectedNotes: []string{
+					"upstreamConfig.upstreams[1].timeout cannot be converted to the legacy configuration",
+				},
+			}),
+			Entry("with a custom request header", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.InjectRequestHeaders = []Header{
+						{Name: "X-Custom", Values: []HeaderValue{{ClaimSource: &ClaimSource{Claim: "custom"}}}},
+					}
+				},
+				expectedNotes: []string{
+					"injectRequestHeaders cannot be converted to the legacy configuration: only the headers set by the legacy header options are supported",
+					"injectResponseHeaders cannot be converted to the legacy configuration: only the headers set by the legacy header options are supported",
+				},
+			}),
+			Entry("with a TLS key value", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.Server.BindAddress = ""
+					opts.Server.SecureBindAddress = ":443"
+					opts.Server.TLS = &TLS{
+						Key:  &SecretSource{Value: []byte("key")},
+						Cert: &SecretSource{FromFile: "tls.crt"},
+					}
+				},
+				expectedNotes: []string{
+					"server.TLS.Key.value cannot be converted to the legacy configuration",
+				},
+			}),
+			Entry("with multiple providers and tenants", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.Providers = append(opts.Providers, Provider{ID: "second", Type: "oidc"})
+					opts.Tenants = []Tenant{{ID: "tenant"}}
+				},
+				expectedNotes: []string{
+					"providers[1] cannot be converted to the legacy configuration: only a single provider is supported",
+					"tenants cannot be converted to the legacy configuration",
+				},
+			}),
+			Entry("with cookie policies", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.Cookie.Policies = []CookiePolicy{{Hosts: []string{"embedded.example.com"}, SameSite: "none"}}
+				},
+				expectedNotes: []string{
+					"cookiePolicies cannot be converted to the legacy configuration",
+				},
+			}),
+			Entry("with user info fields", extractNotesTableInput{
+				modify: func(opts *Options) {
+					opts.UserInfo = &UserInfo{Fields: []UserInfoField{{Name: "email"}}}
+				},
+				expectedNotes: []string{
+					"userInfo cannot be converted to the legacy configuration",
+				},
+			}),
+		)
+	})
+
+	Context("MarshalTOML", func() {
+		It("renders nothing for the default options", func() {
+			data, err := NewLegacyOptions().MarshalTOML()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(BeEmpty())
+		})
+
+		It("renders the options that differ from the defaults", func() {
+			legacyOpts := NewLegacyOptions()
+			legacyOpts.LegacyUpstreams.Upstreams = []string{"http://foo.bar/baz", "static://204"}
+			legacyOpts.LegacyUpstreams.Timeout = 5 * time.Second
+			legacyOpts.LegacyServer.EnableHTTP2 = true
+			legacyOpts.LegacyProvider.ClientID = "oauth-proxy"
+			legacyOpts.LegacyProvider.ClientSecret = "a \"quoted\"\tsecret\\"
+			legacyOpts.Options.Cookie.Expire = time.Hour
+
+			data, err := legacyOpts.MarshalTOML()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(`upstreams = ["http://foo.bar/baz", "static://204"]
+upstream_timeout = "5s"
+enable_http2 = true
+client_id = "oauth-proxy"
+client_secret = "a \"quoted\"\tsecret\\"
+cookie_expire = "1h0m0s"
+`))
+
+			By("Loading the rendered options")
+			configFile, err := ioutil.TempFile("", "oauth2-proxy-test-legacy-toml")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.Remove(configFile.Name())
+			_, err = configFile.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(configFile.Close()).To(Succeed())
+
+			loaded := &LegacyOptions{}
+			Expect(Load(configFile.Name(), NewLegacyFlagSet(), loaded)).To(Succeed())
+			Expect(loaded.LegacyUpstreams).To(Equal(legacyOpts.LegacyUpstreams))
+			Expect(loaded.LegacyServer).To(Equal(legacyOpts.LegacyServer))
+			Expect(loaded.LegacyProvider.ClientID).To(Equal(legacyOpts.LegacyProvider.ClientID))
+			Expect(loaded.LegacyProvider.ClientSecret).To(Equal(legacyOpts.LegacyProvider.ClientSecret))
+			Expect(loaded.Options.Cookie.Expire).To(Equal(legacyOpts.Options.Cookie.Expire))
+		})
+	})
+})
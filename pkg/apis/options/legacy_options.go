@@ -48,15 +48,16 @@ func NewLegacyOptions() *LegacyOptions {
 		},
 
 		LegacyProvider: LegacyProvider{
-			ProviderType:          "google",
-			AzureTenant:           "common",
-			ApprovalPrompt:        "force",
-			UserIDClaim:           "email",
-			OIDCEmailClaim:        "email",
-			OIDCGroupsClaim:       "groups",
-			OIDCAudienceClaims:    []string{"aud"},
-			OIDCExtraAudiences:    []string{},
-			InsecureOIDCSkipNonce: true,
+			ProviderType:            "google",
+			AzureTenant:             "common",
+			ApprovalPrompt:          "force",
+			UserIDClaim:             "email",
+			OIDCEmailClaim:          "email",
+			OIDCGroupsClaim:         "groups",
+			OIDCAudienceClaims:      []string{"aud"},
+			OIDCExtraAudiences:      []string{},
+			OIDCKeysRefreshInterval: DefaultOIDCKeysRefreshInterval,
+			InsecureOIDCSkipNonce:   true,
 		},
 
 		Options: *NewOptions(),
@@ -103,6 +104,7 @@ type LegacyUpstreams struct {
 	SSLUpstreamInsecureSkipVerify bool          `flag:"ssl-upstream-insecure-skip-verify" cfg:"ssl_upstream_insecure_skip_verify"`
 	Upstreams                     []string      `flag:"upstream" cfg:"upstreams"`
 	Timeout                       time.Duration `flag:"upstream-timeout" cfg:"upstream_timeout"`
+	DynamicUpstreamsFile          string        `flag:"dynamic-upstreams-file" cfg:"dynamic_upstreams_file"`
 }
 
 func legacyUpstreamsFlagSet() *pflag.FlagSet {
@@ -114,12 +116,15 @@ func legacyUpstreamsFlagSet() *pflag.FlagSet {
 	flagSet.Bool("ssl-upstream-insecure-skip-verify", false, "skip validation of certificates presented when using HTTPS upstreams")
 	flagSet.StringSlice("upstream", []string{}, "the http url(s) of the upstream endpoint, file:// paths for static files or static://<status_code> for static response. Routing is based on the path")
 	flagSet.Duration("upstream-timeout", DefaultUpstreamTimeout, "maximum amount of time the server will wait for a response from the upstream")
+	flagSet.String("dynamic-upstreams-file", "", "path to a YAML file of additional upstreams that is watched so upstreams can be added and removed at runtime")
 
 	return flagSet
 }
 
 func (l *LegacyUpstreams) convert() (UpstreamConfig, error) {
-	upstreams := UpstreamConfig{}
+	upstreams := UpstreamConfig{
+		DynamicUpstreamsFile: l.DynamicUpstreamsFile,
+	}
 
 	for _, upstreamString := range l.Upstreams {
 		u, err := url.Parse(upstreamString)
@@ -152,6 +157,16 @@ func (l *LegacyUpstreams) convert() (UpstreamConfig, error) {
 				// Trim the fragment from the end of the URI
 				upstream.URI = strings.SplitN(upstreamString, "#", 2)[0]
 			}
+		case "unix":
+			// The URI path is the socket path, so serve from the fragment or
+			// the root path
+			upstream.ID = "/"
+			upstream.Path = "/"
+			if u.Fragment != "" {
+				upstream.ID = u.Fragment
+				upstream.Path = u.Fragment
+				upstream.URI = strings.SplitN(upstreamString, "#", 2)[0]
+			}
 		case "static":
 			responseCode, err := strconv.Atoi(u.Host)
 			if err != nil {
@@ -447,16 +462,29 @@ func getXAuthRequestAccessTokenHeader() Header {
 }
 
 type LegacyServer struct {
-	MetricsAddress       string   `flag:"metrics-address" cfg:"metrics_address"`
-	MetricsSecureAddress string   `flag:"metrics-secure-address" cfg:"metrics_secure_address"`
-	MetricsTLSCertFile   string   `flag:"metrics-tls-cert-file" cfg:"metrics_tls_cert_file"`
-	MetricsTLSKeyFile    string   `flag:"metrics-tls-key-file" cfg:"metrics_tls_key_file"`
-	HTTPAddress          string   `flag:"http-address" cfg:"http_address"`
-	HTTPSAddress         string   `flag:"https-address" cfg:"https_address"`
-	TLSCertFile          string   `flag:"tls-cert-file" cfg:"tls_cert_file"`
-	TLSKeyFile           string   `flag:"tls-key-file" cfg:"tls_key_file"`
-	TLSMinVersion        string   `flag:"tls-min-version" cfg:"tls_min_version"`
-	TLSCipherSuites      []string `flag:"tls-cipher-suite" cfg:"tls_cipher_suites"`
+	MetricsAddress         string   `flag:"metrics-address" cfg:"metrics_address"`
+	MetricsSecureAddress   string   `flag:"metrics-secure-address" cfg:"metrics_secure_address"`
+	MetricsTLSCertFile     string   `flag:"metrics-tls-cert-file" cfg:"metrics_tls_cert_file"`
+	MetricsTLSKeyFile      string   `flag:"metrics-tls-key-file" cfg:"metrics_tls_key_file"`
+	MetricsTLSClientCAFile string   `flag:"metrics-tls-client-ca-file" cfg:"metrics_tls_client_ca_file"`
+	MetricsBearerTokenFile string   `flag:"metrics-bearer-token-file" cfg:"metrics_bearer_token_file"`
+	HTTPAddress            string   `flag:"http-address" cfg:"http_address"`
+	HTTPSAddress           string   `flag:"https-address" cfg:"https_address"`
+	TLSCertFile            string   `flag:"tls-cert-file" cfg:"tls_cert_file"`
+	TLSKeyFile             string   `flag:"tls-key-file" cfg:"tls_key_file"`
+	TLSMinVersion          string   `flag:"tls-min-version" cfg:"tls_min_version"`
+	TLSCipherSuites        []string `flag:"tls-cipher-suite" cfg:"tls_cipher_suites"`
+	TLSSNICertFiles        []string `flag:"tls-sni-cert-file" cfg:"tls_sni_cert_files"`
+	TLSSNIKeyFiles         []string `flag:"tls-sni-key-file" cfg:"tls_sni_key_files"`
+	TLSClientCAFile        string   `flag:"tls-client-ca-file" cfg:"tls_client_ca_file"`
+	TLSClientAuth          string   `flag:"tls-client-auth" cfg:"tls_client_auth"`
+	EnableHTTP2            bool     `flag:"enable-http2" cfg:"enable_http2"`
+	ACMEDomains            []string `flag:"acme-domain" cfg:"acme_domains"`
+	ACMEEmail              string   `flag:"acme-email" cfg:"acme_email"`
+	ACMEDirectoryURL       string   `flag:"acme-directory-url" cfg:"acme_directory_url"`
+	ACMEAcceptTOS          bool     `flag:"acme-accept-tos" cfg:"acme_accept_tos"`
+	ACMECacheDir           string   `flag:"acme-cache-dir" cfg:"acme_cache_dir"`
+	ACMEUseSessionStore    bool     `flag:"acme-use-session-store" cfg:"acme_use_session_store"`
 }
 
 func legacyServerFlagset() *pflag.FlagSet {
@@ -466,12 +494,25 @@ func legacyServerFlagset() *pflag.FlagSet {
 	flagSet.String("metrics-secure-address", "", "the address /metrics will be served on for HTTPS clients (e.g. \":9100\")")
 	flagSet.String("metrics-tls-cert-file", "", "path to certificate file for secure metrics server")
 	flagSet.String("metrics-tls-key-file", "", "path to private key file for secure metrics server")
-	flagSet.String("http-address", "127.0.0.1:4180", "[http://]<addr>:<port> or unix://<path> to listen on for HTTP clients")
-	flagSet.String("https-address", ":443", "<addr>:<port> to listen on for HTTPS clients")
+	flagSet.String("metrics-tls-client-ca-file", "", "path to the CA certificates used to verify the certificates that clients of the secure metrics server must present")
+	flagSet.String("metrics-bearer-token-file", "", "path to a file containing the bearer token that requests to the metrics server must present")
+	flagSet.String("http-address", "127.0.0.1:4180", "[http://]<addr>:<port>, unix://<path> or fd://<name> (systemd socket activation) to listen on for HTTP clients")
+	flagSet.String("https-address", ":443", "<addr>:<port> or fd://<name> (systemd socket activation) to listen on for HTTPS clients")
 	flagSet.String("tls-cert-file", "", "path to certificate file")
 	flagSet.String("tls-key-file", "", "path to private key file")
 	flagSet.String("tls-min-version", "", "minimal TLS version for HTTPS clients (either \"TLS1.2\" or \"TLS1.3\")")
 	flagSet.StringSlice("tls-cipher-suite", []string{}, "restricts TLS cipher suites to those listed (e.g. TLS_RSA_WITH_RC4_128_SHA) (may be given multiple times)")
+	flagSet.StringSlice("tls-sni-cert-file", []string{}, "path to an additional certificate file served to clients requesting one of its names with SNI (may be given multiple times)")
+	flagSet.StringSlice("tls-sni-key-file", []string{}, "path to the private key file of the additional certificate given at the same position in --tls-sni-cert-file (may be given multiple times)")
+	flagSet.String("tls-client-ca-file", "", "path to the CA certificates used to verify client certificates presented to the HTTPS server")
+	flagSet.String("tls-client-auth", "", "whether HTTPS clients must present a certificate when --tls-client-ca-file is set: \"optional\" or \"required\" (default \"optional\")")
+	flagSet.Bool("enable-http2", false, "allow clients to connect using HTTP/2 (cleartext h2c for HTTP clients), required for proxying gRPC")
+	flagSet.StringSlice("acme-domain", []string{}, "request certificates for HTTPS clients for this domain using ACME (may be given multiple times)")
+	flagSet.String("acme-email", "", "contact email address for the ACME account")
+	flagSet.String("acme-directory-url", "", "ACME directory URL of the certificate authority (default Let's Encrypt)")
+	flagSet.Bool("acme-accept-tos", false, "accept the terms of service of the ACME certificate authority")
+	flagSet.String("acme-cache-dir", "", "directory to store ACME certificates and the account key in")
+	flagSet.Bool("acme-use-session-store", false, "store ACME certificates and the account key in the redis session store")
 
 	return flagSet
 }
@@ -498,30 +539,35 @@ type LegacyProvider struct {
 
 	// These options allow for other providers besides Google, with
 	// potential overrides.
-	ProviderType                       string   `flag:"provider" cfg:"provider"`
-	ProviderName                       string   `flag:"provider-display-name" cfg:"provider_display_name"`
-	ProviderCAFiles                    []string `flag:"provider-ca-file" cfg:"provider_ca_files"`
-	OIDCIssuerURL                      string   `flag:"oidc-issuer-url" cfg:"oidc_issuer_url"`
-	InsecureOIDCAllowUnverifiedEmail   bool     `flag:"insecure-oidc-allow-unverified-email" cfg:"insecure_oidc_allow_unverified_email"`
-	InsecureOIDCSkipIssuerVerification bool     `flag:"insecure-oidc-skip-issuer-verification" cfg:"insecure_oidc_skip_issuer_verification"`
-	InsecureOIDCSkipNonce              bool     `flag:"insecure-oidc-skip-nonce" cfg:"insecure_oidc_skip_nonce"`
-	SkipOIDCDiscovery                  bool     `flag:"skip-oidc-discovery" cfg:"skip_oidc_discovery"`
-	OIDCJwksURL                        string   `flag:"oidc-jwks-url" cfg:"oidc_jwks_url"`
-	OIDCEmailClaim                     string   `flag:"oidc-email-claim" cfg:"oidc_email_claim"`
-	OIDCGroupsClaim                    string   `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
-	OIDCAudienceClaims                 []string `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
-	OIDCExtraAudiences                 []string `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
-	LoginURL                           string   `flag:"login-url" cfg:"login_url"`
-	RedeemURL                          string   `flag:"redeem-url" cfg:"redeem_url"`
-	ProfileURL                         string   `flag:"profile-url" cfg:"profile_url"`
-	ProtectedResource                  string   `flag:"resource" cfg:"resource"`
-	ValidateURL                        string   `flag:"validate-url" cfg:"validate_url"`
-	Scope                              string   `flag:"scope" cfg:"scope"`
-	Prompt                             string   `flag:"prompt" cfg:"prompt"`
-	ApprovalPrompt                     string   `flag:"approval-prompt" cfg:"approval_prompt"` // Deprecated by OIDC 1.0
-	UserIDClaim                        string   `flag:"user-id-claim" cfg:"user_id_claim"`
-	AllowedGroups                      []string `flag:"allowed-group" cfg:"allowed_groups"`
-	AllowedRoles                       []string `flag:"allowed-role" cfg:"allowed_roles"`
+	ProviderType                       string        `flag:"provider" cfg:"provider"`
+	ProviderName                       string        `flag:"provider-display-name" cfg:"provider_display_name"`
+	ProviderCAFiles                    []string      `flag:"provider-ca-file" cfg:"provider_ca_files"`
+	OIDCIssuerURL                      string        `flag:"oidc-issuer-url" cfg:"oidc_issuer_url"`
+	InsecureOIDCAllowUnverifiedEmail   bool          `flag:"insecure-oidc-allow-unverified-email" cfg:"insecure_oidc_allow_unverified_email"`
+	InsecureOIDCSkipIssuerVerification bool          `flag:"insecure-oidc-skip-issuer-verification" cfg:"insecure_oidc_skip_issuer_verification"`
+	InsecureOIDCSkipNonce              bool          `flag:"insecure-oidc-skip-nonce" cfg:"insecure_oidc_skip_nonce"`
+	SkipOIDCDiscovery                  bool          `flag:"skip-oidc-discovery" cfg:"skip_oidc_discovery"`
+	OIDCJwksURL                        string        `flag:"oidc-jwks-url" cfg:"oidc_jwks_url"`
+	OIDCEmailClaim                     string        `flag:"oidc-email-claim" cfg:"oidc_email_claim"`
+	OIDCGroupsClaim                    string        `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
+	OIDCAudienceClaims                 []string      `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
+	OIDCExtraAudiences                 []string      `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
+	OIDCKeysRefreshInterval            time.Duration `flag:"oidc-keys-refresh-interval" cfg:"oidc_keys_refresh_interval"`
+	LoginURL                           string        `flag:"login-url" cfg:"login_url"`
+	RedeemURL                          string        `flag:"redeem-url" cfg:"redeem_url"`
+	ProfileURL                         string        `flag:"profile-url" cfg:"profile_url"`
+	ProtectedResource                  string        `flag:"resource" cfg:"resource"`
+	ValidateURL                        string        `flag:"validate-url" cfg:"validate_url"`
+	RPInitiatedLogout                  bool          `flag:"rp-initiated-logout" cfg:"rp_initiated_logout"`
+	LogoutURL                          string        `flag:"logout-url" cfg:"logout_url"`
+	RevokeURL                          string        `flag:"revoke-url" cfg:"revoke_url"`
+	Scope                              string        `flag:"scope" cfg:"scope"`
+	Prompt                             string        `flag:"prompt" cfg:"prompt"`
+	ApprovalPrompt                     string        `flag:"approval-prompt" cfg:"approval_prompt"` // Deprecated by OIDC 1.0
+	UserIDClaim                        string        `flag:"user-id-claim" cfg:"user_id_claim"`
+	AllowedGroups                      []string      `flag:"allowed-group" cfg:"allowed_groups"`
+	AllowedRoles                       []string      `flag:"allowed-role" cfg:"allowed_roles"`
+	AllowedLoginURLParameters          []string      `flag:"allowed-login-url-parameter" cfg:"allowed_login_url_parameters"`
 
 	AcrValues  string `flag:"acr-values" cfg:"acr_values"`
 	JWTKey     string `flag:"jwt-key" cfg:"jwt_key"`
@@ -567,11 +613,15 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.String("oidc-email-claim", OIDCEmailClaim, "which OIDC claim contains the user's email")
 	flagSet.StringSlice("oidc-audience-claim", OIDCAudienceClaims, "which OIDC claims are used as audience to verify against client id")
 	flagSet.StringSlice("oidc-extra-audience", []string{}, "additional audiences allowed to pass audience verification")
+	flagSet.Duration("oidc-keys-refresh-interval", DefaultOIDCKeysRefreshInterval, "period between background refreshes of the OIDC discovery document and signing keys")
 	flagSet.String("login-url", "", "Authentication endpoint")
 	flagSet.String("redeem-url", "", "Token redemption endpoint")
 	flagSet.String("profile-url", "", "Profile access endpoint")
 	flagSet.String("resource", "", "The resource that is protected (Azure AD only)")
 	flagSet.String("validate-url", "", "Access token validation endpoint")
+	flagSet.Bool("rp-initiated-logout", false, "sign users out of the provider too when they sign out, by redirecting them to its end session endpoint")
+	flagSet.String("logout-url", "", "End session endpoint, discovered for OIDC providers by default")
+	flagSet.String("revoke-url", "", "Token revocation endpoint, discovered for OIDC providers by default")
 	flagSet.String("scope", "", "OAuth scope specification")
 	flagSet.String("prompt", "", "OIDC prompt")
 	flagSet.String("approval-prompt", "force", "OAuth approval_prompt")
@@ -586,6 +636,7 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.String("user-id-claim", OIDCEmailClaim, "(DEPRECATED for `oidc-email-claim`) which claim contains the user ID")
 	flagSet.StringSlice("allowed-group", []string{}, "restrict logins to members of this group (may be given multiple times)")
 	flagSet.StringSlice("allowed-role", []string{}, "(keycloak-oidc) restrict logins to members of these roles (may be given multiple times)")
+	flagSet.StringSlice("allowed-login-url-parameter", []string{}, "query parameter of the start URL passed to the login URL of the provider, eg login_hint, optionally restricted to the values matching a pattern with name=pattern (may be given multiple times)")
 
 	return flagSet
 }
@@ -594,6 +645,7 @@ func (l LegacyServer) convert() (Server, Server) {
 	appServer := Server{
 		BindAddress:       l.HTTPAddress,
 		SecureBindAddress: l.HTTPSAddress,
+		EnableHTTP2:       l.EnableHTTP2,
 	}
 	if l.TLSKeyFile != "" || l.TLSCertFile != "" {
 		appServer.TLS = &TLS{
@@ -608,8 +660,27 @@ func (l LegacyServer) convert() (Server, Server) {
 		if len(l.TLSCipherSuites) != 0 {
 			appServer.TLS.CipherSuites = l.TLSCipherSuites
 		}
+		appServer.TLS.AdditionalCertificates = convertSNICertificates(l.TLSSNICertFiles, l.TLSSNIKeyFiles)
+		l.convertClientAuth(appServer.TLS)
 		// Preserve backwards compatibility, only run one server
 		appServer.BindAddress = ""
+	} else if len(l.ACMEDomains) > 0 {
+		// The HTTP server is kept to answer HTTP-01 challenges
+		appServer.ACME = &ACME{
+			Domains:         l.ACMEDomains,
+			Email:           l.ACMEEmail,
+			DirectoryURL:    l.ACMEDirectoryURL,
+			AcceptTOS:       l.ACMEAcceptTOS,
+			CacheDir:        l.ACMECacheDir,
+			UseSessionStore: l.ACMEUseSessionStore,
+		}
+		if l.TLSMinVersion != "" || len(l.TLSCipherSuites) != 0 || l.TLSClientCAFile != "" {
+			appServer.TLS = &TLS{
+				MinVersion:   l.TLSMinVersion,
+				CipherSuites: l.TLSCipherSuites,
+			}
+			l.convertClientAuth(appServer.TLS)
+		}
 	} else {
 		// Disable the HTTPS server if there's no certificates.
 		// This preserves backwards compatibility.
@@ -630,16 +701,73 @@ func (l LegacyServer) convert() (Server, Server) {
 			},
 		}
 	}
+	if l.MetricsTLSClientCAFile != "" {
+		// Client certificates are required, as they authenticate the clients
+		// of the metrics server
+		if metricsServer.TLS == nil {
+			metricsServer.TLS = &TLS{}
+		}
+		metricsServer.TLS.ClientCA = &SecretSource{
+			FromFile: l.MetricsTLSClientCAFile,
+		}
+		metricsServer.TLS.ClientAuth = TLSClientAuthRequired
+	}
+	if l.MetricsBearerTokenFile != "" {
+		metricsServer.BearerToken = &SecretSource{
+			FromFile: l.MetricsBearerTokenFile,
+		}
+	}
 
 	return appServer, metricsServer
 }
 
+// convertClientAuth sets the client certificate verification options of the TLS config.
+func (l LegacyServer) convertClientAuth(tls *TLS) {
+	if l.TLSClientCAFile != "" {
+		tls.ClientCA = &SecretSource{
+			FromFile: l.TLSClientCAFile,
+		}
+	}
+	tls.ClientAuth = l.TLSClientAuth
+}
+
+// convertSNICertificates pairs the certificate and key files by position.
+// Unpaired files are converted without a key or cert so that they are
+// reported by validation.
+func convertSNICertificates(certFiles, keyFiles []string) []TLSCertificate {
+	count := len(certFiles)
+	if len(keyFiles) > count {
+		count = len(keyFiles)
+	}
+
+	var certificates []TLSCertificate
+	for i := 0; i < count; i++ {
+		certificate := TLSCertificate{}
+		if i < len(certFiles) {
+			certificate.Cert = &SecretSource{FromFile: certFiles[i]}
+		}
+		if i < len(keyFiles) {
+			certificate.Key = &SecretSource{FromFile: keyFiles[i]}
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates
+}
+
+// legacySecret converts a legacy secret, leaving it nil when it is not set.
+func legacySecret(secret string) Secret {
+	if secret == "" {
+		return nil
+	}
+	return Secret(secret)
+}
+
 func (l *LegacyProvider) convert() (Providers, error) {
 	providers := Providers{}
 
 	provider := Provider{
 		ClientID:            l.ClientID,
-		ClientSecret:        l.ClientSecret,
+		ClientSecret:        legacySecret(l.ClientSecret),
 		ClientSecretFile:    l.ClientSecretFile,
 		Type:                ProviderType(l.ProviderType),
 		CAFiles:             l.ProviderCAFiles,
@@ -648,6 +776,9 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		ProfileURL:          l.ProfileURL,
 		ProtectedResource:   l.ProtectedResource,
 		ValidateURL:         l.ValidateURL,
+		RPInitiatedLogout:   l.RPInitiatedLogout,
+		LogoutURL:           l.LogoutURL,
+		RevokeURL:           l.RevokeURL,
 		Scope:               l.Scope,
 		AllowedGroups:       l.AllowedGroups,
 		CodeChallengeMethod: l.CodeChallengeMethod,
@@ -667,6 +798,10 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		AudienceClaims:                 l.OIDCAudienceClaims,
 		ExtraAudiences:                 l.OIDCExtraAudiences,
 	}
+	if l.OIDCKeysRefreshInterval != 0 {
+		keysRefreshInterval := Duration(l.OIDCKeysRefreshInterval)
+		provider.OIDCConfig.KeysRefreshInterval = &keysRefreshInterval
+	}
 
 	// Support for legacy configuration option
 	if l.ForceCodeChallengeMethod != "" && l.CodeChallengeMethod == "" {
@@ -744,9 +879,35 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		urlParams = append(urlParams, LoginURLParameter{Name: "approval_prompt", Default: []string{"force"}})
 	}
 
-	provider.LoginURLParameters = urlParams
+	provider.LoginURLParameters = allowLegacyLoginURLParameters(urlParams, l.AllowedLoginURLParameters)
 
 	providers = append(providers, provider)
 
 	return providers, nil
 }
+
+// allowLegacyLoginURLParameters allows the parameters of the start URL, given
+// as `name` or `name=pattern`, to be passed to the login URL. Without a
+// pattern, any value is allowed.
+func allowLegacyLoginURLParameters(urlParams []LoginURLParameter, allowed []string) []LoginURLParameter {
+	for _, param := range allowed {
+		name, pattern, found := strings.Cut(param, "=")
+		if !found {
+			pattern = ".+"
+		}
+		rule := URLParameterRule{Pattern: &pattern}
+
+		idx := -1
+		for i := range urlParams {
+			if urlParams[i].Name == name {
+				idx = i
+			}
+		}
+		if idx == -1 {
+			urlParams = append(urlParams, LoginURLParameter{Name: name})
+			idx = len(urlParams) - 1
+		}
+		urlParams[idx].Allow = append(urlParams[idx].Allow, rule)
+	}
+	return urlParams
+}
@@ -123,6 +123,8 @@ var _ = Describe("Legacy Options", func() {
 			opts.Providers[0].OIDCConfig.InsecureSkipNonce = true
 			opts.Providers[0].OIDCConfig.AudienceClaims = []string{"aud"}
 			opts.Providers[0].OIDCConfig.ExtraAudiences = []string{}
+			keysRefreshInterval := Duration(DefaultOIDCKeysRefreshInterval)
+			opts.Providers[0].OIDCConfig.KeysRefreshInterval = &keysRefreshInterval
 			opts.Providers[0].LoginURLParameters = []LoginURLParameter{
 				{Name: "approval_prompt", Default: []string{"force"}},
 			}
@@ -185,6 +187,18 @@ var _ = Describe("Legacy Options", func() {
 			Timeout:               &timeout,
 		}
 
+		validUnixWithFragment := "unix:///var/run/app.sock#/app"
+		validUnixWithFragmentUpstream := Upstream{
+			ID:                    "/app",
+			Path:                  "/app",
+			URI:                   "unix:///var/run/app.sock",
+			InsecureSkipTLSVerify: skipVerify,
+			PassHostHeader:        &passHostHeader,
+			ProxyWebSockets:       &proxyWebSockets,
+			FlushInterval:         &flushInterval,
+			Timeout:               &timeout,
+		}
+
 		validStatic := "static://204"
 		validStaticCode := 204
 		validStaticUpstream := Upstream{
@@ -260,6 +274,11 @@ var _ = Describe("Legacy Options", func() {
 				expectedUpstreams: []Upstream{validFileWithFragmentUpstream},
 				errMsg:            "",
 			}),
+			Entry("with a valid unix socket upstream with a fragment", &convertUpstreamsTableInput{
+				upstreamStrings:   []string{validUnixWithFragment},
+				expectedUpstreams: []Upstream{validUnixWithFragmentUpstream},
+				errMsg:            "",
+			}),
 			Entry("with a valid static upstream", &convertUpstreamsTableInput{
 				upstreamStrings:   []string{validStatic},
 				expectedUpstreams: []Upstream{validStaticUpstream},
@@ -883,6 +902,84 @@ var _ = Describe("Legacy Options", func() {
 					TLS:               tlsConfigCipherSuites,
 				},
 			}),
+			Entry("with ACME domains specified starts app HTTP and HTTPS servers", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:   insecureAddr,
+					HTTPSAddress:  secureAddr,
+					ACMEDomains:   []string{"example.com"},
+					ACMEAcceptTOS: true,
+					ACMECacheDir:  "/var/cache/oauth2-proxy",
+					TLSMinVersion: minVersion,
+				},
+				expectedAppServer: Server{
+					BindAddress:       insecureAddr,
+					SecureBindAddress: secureAddr,
+					TLS: &TLS{
+						MinVersion: minVersion,
+					},
+					ACME: &ACME{
+						Domains:   []string{"example.com"},
+						AcceptTOS: true,
+						CacheDir:  "/var/cache/oauth2-proxy",
+					},
+				},
+			}),
+			Entry("with TLS options specified with SNI certificates", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:     insecureAddr,
+					HTTPSAddress:    secureAddr,
+					TLSKeyFile:      keyPath,
+					TLSCertFile:     crtPath,
+					TLSSNICertFiles: []string{"app.crt", "api.crt"},
+					TLSSNIKeyFiles:  []string{"app.key"},
+				},
+				expectedAppServer: Server{
+					SecureBindAddress: secureAddr,
+					TLS: &TLS{
+						Cert: tlsConfig.Cert,
+						Key:  tlsConfig.Key,
+						AdditionalCertificates: []TLSCertificate{
+							{
+								Cert: &SecretSource{FromFile: "app.crt"},
+								Key:  &SecretSource{FromFile: "app.key"},
+							},
+							{
+								Cert: &SecretSource{FromFile: "api.crt"},
+							},
+						},
+					},
+				},
+			}),
+			Entry("with TLS options specified with a client CA", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:     insecureAddr,
+					HTTPSAddress:    secureAddr,
+					TLSKeyFile:      keyPath,
+					TLSCertFile:     crtPath,
+					TLSClientCAFile: "ca.crt",
+					TLSClientAuth:   TLSClientAuthRequired,
+				},
+				expectedAppServer: Server{
+					SecureBindAddress: secureAddr,
+					TLS: &TLS{
+						Cert:       tlsConfig.Cert,
+						Key:        tlsConfig.Key,
+						ClientCA:   &SecretSource{FromFile: "ca.crt"},
+						ClientAuth: TLSClientAuthRequired,
+					},
+				},
+			}),
+			Entry("with HTTP/2 enabled", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:  insecureAddr,
+					HTTPSAddress: secureAddr,
+					EnableHTTP2:  true,
+				},
+				expectedAppServer: Server{
+					BindAddress: insecureAddr,
+					EnableHTTP2: true,
+				},
+			}),
 			Entry("with metrics HTTP and HTTPS addresses", legacyServersTableInput{
 				legacyServer: LegacyServer{
 					HTTPAddress:          insecureAddr,
@@ -916,6 +1013,29 @@ var _ = Describe("Legacy Options", func() {
 					TLS:               tlsConfig,
 				},
 			}),
+			Entry("with metrics client certificates and a bearer token", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:            insecureAddr,
+					MetricsSecureAddress:   secureMetricsAddr,
+					MetricsTLSKeyFile:      keyPath,
+					MetricsTLSCertFile:     crtPath,
+					MetricsTLSClientCAFile: "/path/to/ca.crt",
+					MetricsBearerTokenFile: "/path/to/token",
+				},
+				expectedAppServer: Server{
+					BindAddress: insecureAddr,
+				},
+				expectedMetricsServer: Server{
+					SecureBindAddress: secureMetricsAddr,
+					TLS: &TLS{
+						Key:        &SecretSource{FromFile: keyPath},
+						Cert:       &SecretSource{FromFile: crtPath},
+						ClientCA:   &SecretSource{FromFile: "/path/to/ca.crt"},
+						ClientAuth: TLSClientAuthRequired,
+					},
+					BearerToken: &SecretSource{FromFile: "/path/to/token"},
+				},
+			}),
 		)
 	})
 
@@ -958,6 +1078,24 @@ var _ = Describe("Legacy Options", func() {
 			Prompt:       "switch_user",
 		}
 
+		anyPrompt := ".+"
+		emailLoginHint := `^[^@]*@example\.com$`
+		allowedLoginURLParametersProvider := Provider{
+			ID:       "google=" + clientID,
+			ClientID: clientID,
+			Type:     "google",
+			LoginURLParameters: []LoginURLParameter{
+				{Name: "prompt", Default: []string{"switch_user"}, Allow: []URLParameterRule{{Pattern: &anyPrompt}}},
+				{Name: "login_hint", Allow: []URLParameterRule{{Pattern: &emailLoginHint}}},
+			},
+		}
+		allowedLoginURLParametersLegacyProvider := LegacyProvider{
+			ClientID:                  clientID,
+			ProviderType:              "google",
+			Prompt:                    "switch_user",
+			AllowedLoginURLParameters: []string{"prompt", `login_hint=^[^@]*@example\.com$`},
+		}
+
 		displayNameProvider := Provider{
 			ID:                 "displayName",
 			Name:               "displayName",
@@ -1014,6 +1152,11 @@ var _ = Describe("Legacy Options", func() {
 				expectedProviders: Providers{defaultProviderWithPrompt},
 				errMsg:            "",
 			}),
+			Entry("with allowed login URL parameters", &convertProvidersTableInput{
+				legacyProvider:    allowedLoginURLParametersLegacyProvider,
+				expectedProviders: Providers{allowedLoginURLParametersProvider},
+				errMsg:            "",
+			}),
 			Entry("with provider display name", &convertProvidersTableInput{
 				legacyProvider:    displayNameLegacyProvider,
 				expectedProviders: Providers{displayNameProvider},
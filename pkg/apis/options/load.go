@@ -1,6 +1,8 @@
 package options
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -41,6 +43,11 @@ func Load(configFileName string, flagSet *pflag.FlagSet, into interface{}) error
 		return fmt.Errorf("unable to register flags: %w", err)
 	}
 
+	err = applyProfile(v, into)
+	if err != nil {
+		return fmt.Errorf("unable to apply profile: %w", err)
+	}
+
 	// UnmarhsalExact will return an error if the config includes options that are
 	// not mapped to felds of the into struct
 	err = v.UnmarshalExact(into, decodeFromCfgTag)
@@ -120,8 +127,19 @@ func registerFlags(v *viper.Viper, prefix string, flagSet *pflag.FlagSet, option
 
 // decodeFromCfgTag sets the Viper decoder to read the names from the `cfg` tag
 // on each struct entry.
+// Strings are decoded as is into byte slices, such as secrets, rather than
+// split into lists of bytes.
 func decodeFromCfgTag(c *mapstructure.DecoderConfig) {
 	c.TagName = "cfg"
+	c.DecodeHook = mapstructure.ComposeDecodeHookFunc(stringToBytesHookFunc, c.DecodeHook)
+}
+
+// stringToBytesHookFunc decodes strings into byte slices.
+func stringToBytesHookFunc(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Slice || to.Elem().Kind() != reflect.Uint8 {
+		return data, nil
+	}
+	return []byte(data.(string)), nil
 }
 
 // isUnexported checks if a field name starts with a lowercase letter and therefore
@@ -137,12 +155,10 @@ func isUnexported(name string) bool {
 }
 
 // LoadYAML will load a YAML based configuration file into the options interface provided.
+// Any files listed under the `includes` key are loaded and merged first, then
+// references to environment variables and files in the string values of the
+// configuration are interpolated before it is loaded.
 func LoadYAML(configFileName string, into interface{}) error {
-	v := viper.New()
-	v.SetConfigFile(configFileName)
-	v.SetConfigType("yaml")
-	v.SetTypeByDefaultValue(true)
-
 	if configFileName == "" {
 		return errors.New("no configuration file provided")
 	}
@@ -152,6 +168,34 @@ func LoadYAML(configFileName string, into interface{}) error {
 		return fmt.Errorf("unable to load config file: %w", err)
 	}
 
+	return LoadYAMLData(configFileName, data, into)
+}
+
+// LoadYAMLData will load YAML based configuration data into the options interface
+// provided, in the same way as LoadYAML.
+// The name is the file name the data is treated as being loaded from, and is
+// used to resolve the paths of any relative includes.
+func LoadYAMLData(name string, data []byte, into interface{}) error {
+	document, err := decodeYAML(data)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling config: %w", err)
+	}
+
+	document, err = resolveIncludes(name, document, nil)
+	if err != nil {
+		return fmt.Errorf("error loading config includes: %w", err)
+	}
+
+	document, err = interpolateValue(document)
+	if err != nil {
+		return fmt.Errorf("error interpolating config: %w", err)
+	}
+
+	data, err = json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling config: %w", err)
+	}
+
 	// UnmarshalStrict will return an error if the config includes options that are
 	// not mapped to felds of the into struct
 	if err := yaml.UnmarshalStrict(data, into, yaml.DisallowUnknownFields); err != nil {
@@ -160,3 +204,21 @@ func LoadYAML(configFileName string, into interface{}) error {
 
 	return nil
 }
+
+// decodeYAML decodes the YAML document into generic maps, lists and values
+// so that it can be merged and interpolated before being loaded.
+// Numbers are decoded as json.Number to retain their original form.
+func decodeYAML(data []byte) (interface{}, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting YAML to JSON: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %v", err)
+	}
+	return document, nil
+}
@@ -0,0 +1,53 @@
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// DefaultConcurrencyQueueTimeout is the default time that work over a
+// concurrency limit waits for room before it is rejected.
+const DefaultConcurrencyQueueTimeout = time.Second
+
+// LoadShedding contains the options limiting the work done at once by the
+// proxy, so that an overloaded proxy rejects requests quickly with a 503
+// Service Unavailable response rather than queueing them until they time out.
+// The limits are disabled when they are zero.
+type LoadShedding struct {
+	// MaxInFlightRequests limits the requests served at once. Health and
+	// readiness checks are not limited.
+	MaxInFlightRequests int `flag:"max-in-flight-requests" cfg:"max_in_flight_requests"`
+
+	// MaxConcurrentRefreshes limits the sessions refreshed at once.
+	// Sessions that have not expired are used without being refreshed when
+	// there is no room to refresh them.
+	MaxConcurrentRefreshes int `flag:"max-concurrent-refreshes" cfg:"max_concurrent_refreshes"`
+
+	// MaxConcurrentProviderRequests limits the requests made to the
+	// providers at once.
+	MaxConcurrentProviderRequests int `flag:"max-concurrent-provider-requests" cfg:"max_concurrent_provider_requests"`
+
+	// QueueTimeout is how long work over a limit waits for room before it
+	// is rejected. Work over a limit is rejected immediately when it is zero.
+	QueueTimeout time.Duration `flag:"concurrency-queue-timeout" cfg:"concurrency_queue_timeout"`
+}
+
+func loadSheddingFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("load-shedding", pflag.ExitOnError)
+
+	flagSet.Int("max-in-flight-requests", 0, "maximum number of requests served at once, further requests are rejected with a 503 (0 for no limit)")
+	flagSet.Int("max-concurrent-refreshes", 0, "maximum number of sessions refreshed at once, further sessions are used without being refreshed until they expire (0 for no limit)")
+	flagSet.Int("max-concurrent-provider-requests", 0, "maximum number of requests made to the providers at once (0 for no limit)")
+	flagSet.Duration("concurrency-queue-timeout", DefaultConcurrencyQueueTimeout, "how long requests over a concurrency limit wait before they are rejected (0 to reject them immediately)")
+
+	return flagSet
+}
+
+// loadSheddingDefaults creates a LoadShedding structure, populating each
+// field with its default value
+func loadSheddingDefaults() LoadShedding {
+	return LoadShedding{
+		QueueTimeout: DefaultConcurrencyQueueTimeout,
+	}
+}
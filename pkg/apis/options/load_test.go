@@ -37,26 +37,39 @@ var _ = Describe("Load", func() {
 		},
 
 		LegacyProvider: LegacyProvider{
-			ProviderType:          "google",
-			AzureTenant:           "common",
-			ApprovalPrompt:        "force",
-			UserIDClaim:           "email",
-			OIDCEmailClaim:        "email",
-			OIDCGroupsClaim:       "groups",
-			OIDCAudienceClaims:    []string{"aud"},
-			InsecureOIDCSkipNonce: true,
+			ProviderType:            "google",
+			AzureTenant:             "common",
+			ApprovalPrompt:          "force",
+			UserIDClaim:             "email",
+			OIDCEmailClaim:          "email",
+			OIDCGroupsClaim:         "groups",
+			OIDCAudienceClaims:      []string{"aud"},
+			OIDCKeysRefreshInterval: DefaultOIDCKeysRefreshInterval,
+			InsecureOIDCSkipNonce:   true,
 		},
 
 		Options: Options{
-			ProxyPrefix:        "/oauth2",
-			PingPath:           "/ping",
-			RealClientIPHeader: "X-Real-IP",
-			ForceHTTPS:         false,
-			Cookie:             cookieDefaults(),
-			Session:            sessionOptionsDefaults(),
-			Templates:          templatesDefaults(),
-			SkipAuthPreflight:  false,
-			Logging:            loggingDefaults(),
+			ProxyPrefix:                   "/oauth2",
+			PingPath:                      "/ping",
+			ReadyPath:                     "/ready",
+			RealClientIPHeader:            "X-Real-IP",
+			ForceHTTPS:                    false,
+			Cookie:                        cookieDefaults(),
+			Session:                       sessionOptionsDefaults(),
+			Templates:                     templatesDefaults(),
+			SkipAuthPreflight:             false,
+			AuthCacheMaxEntries:           DefaultAuthCacheMaxEntries,
+			ProviderLookupCacheMaxEntries: DefaultProviderLookupCacheMaxEntries,
+			UnauthenticatedResponse:       UnauthenticatedResponseDefault,
+			Logging:                       loggingDefaults(),
+			StatsD:                        statsdDefaults(),
+			ProviderClient:                providerClientDefaults(),
+			LoadShedding:                  loadSheddingDefaults(),
+			SignInChallenge:               signInChallengeDefaults(),
+			SecretRefreshInterval:         DefaultSecretRefreshInterval,
+			JWTAssertionIssuer:            DefaultJWTAssertionIssuer,
+
+			ClientCertificateUserAttribute: ClientCertificateUserCN,
 		},
 	}
 
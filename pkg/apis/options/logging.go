@@ -1,6 +1,8 @@
 package options
 
 import (
+	"time"
+
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/spf13/pflag"
 )
@@ -15,10 +17,22 @@ type Logging struct {
 	StandardFormat  string         `flag:"standard-logging-format" cfg:"standard_logging_format"`
 	ErrToInfo       bool           `flag:"errors-to-info-log" cfg:"errors_to_info_log"`
 	ExcludePaths    []string       `flag:"exclude-logging-path" cfg:"exclude_logging_paths"`
+	ExcludeRegexes  []string       `flag:"exclude-logging-path-regex" cfg:"exclude_logging_path_regexes"`
+	SampleRates     []string       `flag:"request-logging-sample-rate" cfg:"request_logging_sample_rates"`
 	LocalTime       bool           `flag:"logging-local-time" cfg:"logging_local_time"`
 	SilencePing     bool           `flag:"silence-ping-logging" cfg:"silence_ping_logging"`
 	RequestIDHeader string         `flag:"request-id-header" cfg:"request_id_header"`
+	Format          string         `flag:"logging-format" cfg:"logging_format"`
+	IncludeFields   []string       `flag:"logging-include-field" cfg:"logging_include_fields"`
+	ExcludeFields   []string       `flag:"logging-exclude-field" cfg:"logging_exclude_fields"`
+	RedactTokens    bool           `flag:"logging-redact-tokens" cfg:"logging_redact_tokens"`
+	RedactPatterns  []string       `flag:"logging-redact-pattern" cfg:"logging_redact_patterns"`
+	Level           string         `flag:"logging-level" cfg:"logging_level"`
+	DebugComponents []string       `flag:"logging-debug-component" cfg:"logging_debug_components"`
 	File            LogFileOptions `cfg:",squash"`
+	Syslog          SyslogOptions  `cfg:",squash"`
+	Webhook         WebhookOptions `cfg:",squash"`
+	Kafka           KafkaOptions   `cfg:",squash"`
 }
 
 // LogFileOptions contains options for configuring logging to a file
@@ -30,6 +44,33 @@ type LogFileOptions struct {
 	Compress   bool   `flag:"logging-compress" cfg:"logging_compress"`
 }
 
+// SyslogOptions contains options for forwarding auth events to a syslog
+// server
+type SyslogOptions struct {
+	Address string `flag:"audit-syslog-address" cfg:"audit_syslog_address"`
+	Format  string `flag:"audit-syslog-format" cfg:"audit_syslog_format"`
+	CAFile  string `flag:"audit-syslog-ca-file" cfg:"audit_syslog_ca_file"`
+}
+
+// WebhookOptions contains options for sending auth events to a webhook
+type WebhookOptions struct {
+	URL           string        `flag:"audit-webhook-url" cfg:"audit_webhook_url"`
+	Secret        string        `flag:"audit-webhook-secret" cfg:"audit_webhook_secret"`
+	BatchSize     int           `flag:"audit-webhook-batch-size" cfg:"audit_webhook_batch_size"`
+	FlushInterval time.Duration `flag:"audit-webhook-flush-interval" cfg:"audit_webhook_flush_interval"`
+	BufferFile    string        `flag:"audit-webhook-buffer-file" cfg:"audit_webhook_buffer_file"`
+}
+
+// KafkaOptions contains options for producing auth events to a Kafka topic
+type KafkaOptions struct {
+	Brokers      []string `flag:"audit-kafka-broker" cfg:"audit_kafka_brokers"`
+	Topic        string   `flag:"audit-kafka-topic" cfg:"audit_kafka_topic"`
+	TLS          bool     `flag:"audit-kafka-tls" cfg:"audit_kafka_tls"`
+	CAFile       string   `flag:"audit-kafka-ca-file" cfg:"audit_kafka_ca_file"`
+	SASLUsername string   `flag:"audit-kafka-sasl-username" cfg:"audit_kafka_sasl_username"`
+	SASLPassword string   `flag:"audit-kafka-sasl-password" cfg:"audit_kafka_sasl_password"`
+}
+
 func loggingFlagSet() *pflag.FlagSet {
 	flagSet := pflag.NewFlagSet("logging", pflag.ExitOnError)
 
@@ -42,9 +83,18 @@ func loggingFlagSet() *pflag.FlagSet {
 	flagSet.Bool("errors-to-info-log", false, "Log errors to the standard logging channel instead of stderr")
 
 	flagSet.StringSlice("exclude-logging-path", []string{}, "Exclude logging requests to paths (eg: '/path1,/path2,/path3')")
+	flagSet.StringSlice("exclude-logging-path-regex", []string{}, "Exclude logging requests to paths matching a regex (may be given multiple times)")
+	flagSet.StringSlice("request-logging-sample-rate", []string{}, "Log requests with a status code or class at a rate between 0 and 1, eg: '2xx=0.01' (may be given multiple times)")
 	flagSet.Bool("logging-local-time", true, "If the time in log files and backup filenames are local or UTC time")
 	flagSet.Bool("silence-ping-logging", false, "Disable logging of requests to ping endpoint")
 	flagSet.String("request-id-header", "X-Request-Id", "Request header to use as the request ID")
+	flagSet.String("logging-format", logger.TextFormat, "Format of log lines: text or json")
+	flagSet.StringSlice("logging-include-field", []string{}, "Only write these fields to JSON log lines (may be given multiple times)")
+	flagSet.StringSlice("logging-exclude-field", []string{}, "Omit these fields from JSON log lines (may be given multiple times)")
+	flagSet.Bool("logging-redact-tokens", true, "Mask tokens, secrets and Authorization headers in log lines and auth events")
+	flagSet.StringSlice("logging-redact-pattern", []string{}, "Mask the matches of a regex in log lines and auth events (may be given multiple times)")
+	flagSet.String("logging-level", "info", "Lowest level of standard log lines to write: debug, info or error")
+	flagSet.StringSlice("logging-debug-component", []string{}, "Write debug log lines of a component: sessions, providers or upstream (may be given multiple times)")
 
 	flagSet.String("logging-filename", "", "File to log requests to, empty for stdout")
 	flagSet.Int("logging-max-size", 100, "Maximum size in megabytes of the log file before rotation")
@@ -52,6 +102,21 @@ func loggingFlagSet() *pflag.FlagSet {
 	flagSet.Int("logging-max-backups", 0, "Maximum number of old log files to retain; 0 to disable")
 	flagSet.Bool("logging-compress", false, "Should rotated log files be compressed using gzip")
 
+	flagSet.String("audit-syslog-address", "", "Syslog server to forward auth events to (tcp://host:port, tls://host:port or udp://host:port)")
+	flagSet.String("audit-syslog-format", "cef", "Format of auth events forwarded to syslog: cef or leef")
+	flagSet.String("audit-syslog-ca-file", "", "CA certificate file to verify a TLS syslog server")
+	flagSet.String("audit-webhook-url", "", "URL of a webhook to send batches of auth events to")
+	flagSet.String("audit-webhook-secret", "", "Secret used to sign the batches of auth events sent to the webhook with HMAC-SHA256")
+	flagSet.Int("audit-webhook-batch-size", 100, "Maximum number of auth events in a batch sent to the webhook")
+	flagSet.Duration("audit-webhook-flush-interval", 5*time.Second, "Longest time an auth event waits to be sent to the webhook in a batch")
+	flagSet.String("audit-webhook-buffer-file", "", "File holding auth events that could not be sent while the webhook is unavailable")
+	flagSet.StringSlice("audit-kafka-broker", []string{}, "Kafka broker (host:port) to look up the audit topic from (may be given multiple times)")
+	flagSet.String("audit-kafka-topic", "", "Kafka topic to produce auth events to")
+	flagSet.Bool("audit-kafka-tls", false, "Connect to the Kafka brokers with TLS")
+	flagSet.String("audit-kafka-ca-file", "", "CA certificate file to verify the Kafka brokers")
+	flagSet.String("audit-kafka-sasl-username", "", "Username to authenticate to the Kafka brokers with SASL/PLAIN")
+	flagSet.String("audit-kafka-sasl-password", "", "Password to authenticate to the Kafka brokers with SASL/PLAIN")
+
 	return flagSet
 }
 
@@ -62,6 +127,9 @@ func loggingDefaults() Logging {
 		LocalTime:       true,
 		SilencePing:     false,
 		RequestIDHeader: "X-Request-Id",
+		Format:          logger.TextFormat,
+		RedactTokens:    true,
+		Level:           "info",
 		AuthEnabled:     true,
 		AuthFormat:      logger.DefaultAuthLoggingFormat,
 		RequestEnabled:  true,
@@ -76,5 +144,12 @@ func loggingDefaults() Logging {
 			MaxBackups: 0,
 			Compress:   false,
 		},
+		Syslog: SyslogOptions{
+			Format: "cef",
+		},
+		Webhook: WebhookOptions{
+			BatchSize:     100,
+			FlushInterval: 5 * time.Second,
+		},
 	}
 }
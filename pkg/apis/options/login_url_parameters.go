@@ -80,7 +80,8 @@ type LoginURLParameter struct {
 	// Allow specifies rules about how the default (if any) may be
 	// overridden via the query string to `/oauth2/start`.  Only
 	// values that match one or more of the allow rules will be
-	// forwarded to the IdP.
+	// forwarded to the IdP.  The parameters set by the proxy itself,
+	// such as `redirect_uri` and `state`, cannot be overridden.
 	//+optional
 	Allow []URLParameterRule `json:"allow,omitempty"`
 }
@@ -3,6 +3,7 @@ package options
 import (
 	"crypto"
 	"net/url"
+	"time"
 
 	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
@@ -18,18 +19,24 @@ type SignatureData struct {
 // Options holds Configuration Options that can be set by Command Line Flag,
 // or Config File
 type Options struct {
-	ProxyPrefix        string   `flag:"proxy-prefix" cfg:"proxy_prefix"`
-	PingPath           string   `flag:"ping-path" cfg:"ping_path"`
-	PingUserAgent      string   `flag:"ping-user-agent" cfg:"ping_user_agent"`
-	ReverseProxy       bool     `flag:"reverse-proxy" cfg:"reverse_proxy"`
-	RealClientIPHeader string   `flag:"real-client-ip-header" cfg:"real_client_ip_header"`
-	TrustedIPs         []string `flag:"trusted-ip" cfg:"trusted_ips"`
-	ForceHTTPS         bool     `flag:"force-https" cfg:"force_https"`
-	RawRedirectURL     string   `flag:"redirect-url" cfg:"redirect_url"`
+	ProxyPrefix         string   `flag:"proxy-prefix" cfg:"proxy_prefix"`
+	PingPath            string   `flag:"ping-path" cfg:"ping_path"`
+	PingUserAgent       string   `flag:"ping-user-agent" cfg:"ping_user_agent"`
+	ReadyPath           string   `flag:"ready-path" cfg:"ready_path"`
+	ReverseProxy        bool     `flag:"reverse-proxy" cfg:"reverse_proxy"`
+	RealClientIPHeader  string   `flag:"real-client-ip-header" cfg:"real_client_ip_header"`
+	TrustedIPs          []string `flag:"trusted-ip" cfg:"trusted_ips"`
+	ForceHTTPS          bool     `flag:"force-https" cfg:"force_https"`
+	RawRedirectURL      string   `flag:"redirect-url" cfg:"redirect_url"`
+	RedirectURLHosts    []string `flag:"redirect-url-host" cfg:"redirect_url_hosts"`
+	RedirectURLTemplate string   `flag:"redirect-url-template" cfg:"redirect_url_template"`
+	Profile             string   `flag:"profile" cfg:"profile"`
+	FIPSMode            bool     `flag:"fips-mode" cfg:"fips_mode"`
 
 	AuthenticatedEmailsFile string   `flag:"authenticated-emails-file" cfg:"authenticated_emails_file"`
 	EmailDomains            []string `flag:"email-domain" cfg:"email_domains"`
 	WhitelistDomains        []string `flag:"whitelist-domain" cfg:"whitelist_domains"`
+	SignRedirects           bool     `flag:"sign-redirects" cfg:"sign_redirects"`
 	HtpasswdFile            string   `flag:"htpasswd-file" cfg:"htpasswd_file"`
 	HtpasswdUserGroups      []string `flag:"htpasswd-user-group" cfg:"htpasswd_user_groups"`
 
@@ -37,6 +44,14 @@ type Options struct {
 	Session   SessionOptions `cfg:",squash"`
 	Logging   Logging        `cfg:",squash"`
 	Templates Templates      `cfg:",squash"`
+	StatsD    StatsD         `cfg:",squash"`
+
+	ProviderClient ProviderClient `cfg:",squash"`
+	LoadShedding   LoadShedding   `cfg:",squash"`
+
+	SignInChallenge SignInChallenge `cfg:",squash"`
+	SecurityHeaders SecurityHeaders `cfg:",squash"`
+	Consent         Consent         `cfg:",squash"`
 
 	// Not used in the legacy config, name not allowed to match an external key (upstreams)
 	// TODO(JoelSpeed): Rename when legacy config is removed
@@ -44,13 +59,23 @@ type Options struct {
 
 	InjectRequestHeaders  []Header `cfg:",internal"`
 	InjectResponseHeaders []Header `cfg:",internal"`
+	AuthResponseHeaders   []Header `cfg:",internal"`
 
 	Server        Server `cfg:",internal"`
 	MetricsServer Server `cfg:",internal"`
 
 	Providers Providers `cfg:",internal"`
 
+	Tenants []Tenant `cfg:",internal"`
+
+	RedirectPolicy *RedirectPolicy `cfg:",internal"`
+
+	UserInfo *UserInfo `cfg:",internal"`
+
+	KubernetesController *KubernetesController `cfg:",internal"`
+
 	APIRoutes             []string `flag:"api-route" cfg:"api_routes"`
+	RedirectRoutes        []string `flag:"redirect-route" cfg:"redirect_routes"`
 	SkipAuthRegex         []string `flag:"skip-auth-regex" cfg:"skip_auth_regex"`
 	SkipAuthRoutes        []string `flag:"skip-auth-route" cfg:"skip_auth_routes"`
 	SkipJwtBearerTokens   bool     `flag:"skip-jwt-bearer-tokens" cfg:"skip_jwt_bearer_tokens"`
@@ -60,9 +85,35 @@ type Options struct {
 	SkipAuthPreflight     bool     `flag:"skip-auth-preflight" cfg:"skip_auth_preflight"`
 	ForceJSONErrors       bool     `flag:"force-json-errors" cfg:"force_json_errors"`
 
+	UnauthenticatedResponse string `flag:"unauthenticated-response" cfg:"unauthenticated_response"`
+
+	WebSocketAuthCloseFrames bool `flag:"websocket-auth-close-frames" cfg:"websocket_auth_close_frames"`
+
+	SilentAuthOrigins []string `flag:"silent-auth-origin" cfg:"silent_auth_origins"`
+
+	ClientCertificateSessions      bool   `flag:"client-certificate-sessions" cfg:"client_certificate_sessions"`
+	ClientCertificateUserAttribute string `flag:"client-certificate-user-attribute" cfg:"client_certificate_user_attribute"`
+
+	AuthCacheTTL        time.Duration `flag:"auth-cache-ttl" cfg:"auth_cache_ttl"`
+	AuthCacheMaxEntries int           `flag:"auth-cache-max-entries" cfg:"auth_cache_max_entries"`
+
+	ProviderLookupCacheTTL        time.Duration `flag:"provider-lookup-cache-ttl" cfg:"provider_lookup_cache_ttl"`
+	ProviderLookupCacheMaxEntries int           `flag:"provider-lookup-cache-max-entries" cfg:"provider_lookup_cache_max_entries"`
+
+	SessionValidationInterval time.Duration `flag:"session-validation-interval" cfg:"session_validation_interval"`
+
 	SignatureKey    string `flag:"signature-key" cfg:"signature_key"`
 	GCPHealthChecks bool   `flag:"gcp-healthchecks" cfg:"gcp_healthchecks"`
 
+	JWTAssertionKeyFile string `flag:"jwt-assertion-key-file" cfg:"jwt_assertion_key_file"`
+	JWTAssertionIssuer  string `flag:"jwt-assertion-issuer" cfg:"jwt_assertion_issuer"`
+
+	AlphaConfigPublicKeyFile string        `flag:"alpha-config-public-key-file" cfg:"alpha_config_public_key_file"`
+	AlphaConfigPollInterval  time.Duration `flag:"alpha-config-poll-interval" cfg:"alpha_config_poll_interval"`
+
+	SecretRefreshInterval time.Duration `flag:"secret-refresh-interval" cfg:"secret_refresh_interval"`
+	WatchSecretFiles      bool          `flag:"watch-secret-files" cfg:"watch_secret_files"`
+
 	// This is used for backwards compatibility for basic auth users
 	LegacyPreferEmailToUser bool `cfg:",internal"`
 
@@ -72,6 +123,7 @@ type Options struct {
 	oidcVerifier       internaloidc.IDTokenVerifier
 	jwtBearerVerifiers []internaloidc.IDTokenVerifier
 	realClientIPParser ipapi.RealClientIPParser
+	tenantOptions      []*Options
 }
 
 // Options for Getting internal values
@@ -82,6 +134,7 @@ func (o *Options) GetJWTBearerVerifiers() []internaloidc.IDTokenVerifier {
 	return o.jwtBearerVerifiers
 }
 func (o *Options) GetRealClientIPParser() ipapi.RealClientIPParser { return o.realClientIPParser }
+func (o *Options) GetTenantOptions() []*Options                    { return o.tenantOptions }
 
 // Options for Setting internal values
 func (o *Options) SetRedirectURL(s *url.URL)                              { o.redirectURL = s }
@@ -89,20 +142,70 @@ func (o *Options) SetSignatureData(s *SignatureData)                      { o.si
 func (o *Options) SetOIDCVerifier(s internaloidc.IDTokenVerifier)         { o.oidcVerifier = s }
 func (o *Options) SetJWTBearerVerifiers(s []internaloidc.IDTokenVerifier) { o.jwtBearerVerifiers = s }
 func (o *Options) SetRealClientIPParser(s ipapi.RealClientIPParser)       { o.realClientIPParser = s }
+func (o *Options) SetTenantOptions(s []*Options)                          { o.tenantOptions = s }
+
+// DefaultJWTAssertionIssuer is the default issuer of the JWT assertions sent
+// to upstreams.
+const DefaultJWTAssertionIssuer = "oauth2-proxy"
+
+// DefaultSecretRefreshInterval is the default interval at which secrets
+// referenced from secret stores are read again.
+const DefaultSecretRefreshInterval = 5 * time.Minute
+
+// DefaultAuthCacheMaxEntries is the default number of decisions held in the
+// auth endpoint cache.
+const DefaultAuthCacheMaxEntries = 10000
+
+// DefaultProviderLookupCacheMaxEntries is the default number of provider
+// lookups held in the provider lookup cache.
+const DefaultProviderLookupCacheMaxEntries = 10000
+
+const (
+	// UnauthenticatedResponseDefault redirects unauthenticated proxied
+	// requests to sign in, except for AJAX requests and API routes, and
+	// returns 401 from the auth endpoint.
+	UnauthenticatedResponseDefault = "default"
+
+	// UnauthenticatedResponseNegotiate redirects unauthenticated requests
+	// that accept HTML to sign in, and returns a 401 with the sign in URL to
+	// all other requests, including those to the auth endpoint.
+	UnauthenticatedResponseNegotiate = "negotiate"
+)
+
+// The client certificate attributes that may be used as the user of sessions
+// created from client certificates.
+const (
+	ClientCertificateUserCN    = "cn"
+	ClientCertificateUserDNS   = "dns"
+	ClientCertificateUserURI   = "uri"
+	ClientCertificateUserEmail = "email"
+)
 
 // NewOptions constructs a new Options with defaulted values
 func NewOptions() *Options {
 	return &Options{
-		ProxyPrefix:        "/oauth2",
-		Providers:          providerDefaults(),
-		PingPath:           "/ping",
-		RealClientIPHeader: "X-Real-IP",
-		ForceHTTPS:         false,
-		Cookie:             cookieDefaults(),
-		Session:            sessionOptionsDefaults(),
-		Templates:          templatesDefaults(),
-		SkipAuthPreflight:  false,
-		Logging:            loggingDefaults(),
+		ProxyPrefix:                   "/oauth2",
+		Providers:                     providerDefaults(),
+		PingPath:                      "/ping",
+		ReadyPath:                     "/ready",
+		RealClientIPHeader:            "X-Real-IP",
+		ForceHTTPS:                    false,
+		Cookie:                        cookieDefaults(),
+		Session:                       sessionOptionsDefaults(),
+		Templates:                     templatesDefaults(),
+		SkipAuthPreflight:             false,
+		AuthCacheMaxEntries:           DefaultAuthCacheMaxEntries,
+		ProviderLookupCacheMaxEntries: DefaultProviderLookupCacheMaxEntries,
+		UnauthenticatedResponse:       UnauthenticatedResponseDefault,
+		Logging:                       loggingDefaults(),
+		StatsD:                        statsdDefaults(),
+		ProviderClient:                providerClientDefaults(),
+		LoadShedding:                  loadSheddingDefaults(),
+		SignInChallenge:               signInChallengeDefaults(),
+
+		ClientCertificateUserAttribute: ClientCertificateUserCN,
+		SecretRefreshInterval:          DefaultSecretRefreshInterval,
+		JWTAssertionIssuer:             DefaultJWTAssertionIssuer,
 	}
 }
 
@@ -115,26 +218,48 @@ func NewFlagSet() *pflag.FlagSet {
 	flagSet.StringSlice("trusted-ip", []string{}, "list of IPs or CIDR ranges to allow to bypass authentication. WARNING: trusting by IP has inherent security flaws, read the configuration documentation for more information.")
 	flagSet.Bool("force-https", false, "force HTTPS redirect for HTTP requests")
 	flagSet.String("redirect-url", "", "the OAuth Redirect URL. ie: \"https://internalapp.yourcompany.com/oauth2/callback\"")
+	flagSet.StringSlice("redirect-url-host", []string{}, "the OAuth Redirect URL for requests to a host, taking precedence over --redirect-url-template and --redirect-url. Format: domain=url, where domains prefixed with . or *. also match subdomains (may be given multiple times)")
+	flagSet.String("redirect-url-template", "", "the OAuth Redirect URL derived from the host of the request, taking precedence over --redirect-url. ie: \"https://{host}/oauth2/callback\"")
+	flagSet.String("profile", "", "preset of option defaults to apply: \"strict\" for hardened defaults. Options that are set explicitly take precedence")
 	flagSet.StringSlice("skip-auth-regex", []string{}, "(DEPRECATED for --skip-auth-route) bypass authentication for requests path's that match (may be given multiple times)")
 	flagSet.StringSlice("skip-auth-route", []string{}, "bypass authentication for requests that match the method & path. Format: method=path_regex OR method!=path_regex. For all methods: path_regex OR !=path_regex")
 	flagSet.StringSlice("api-route", []string{}, "return HTTP 401 instead of redirecting to authentication server if token is not valid. Format: path_regex")
+	flagSet.StringSlice("redirect-route", []string{}, "redirect unauthenticated requests to sign in, including requests to the auth endpoint, instead of returning HTTP 401. Format: path_regex")
+	flagSet.String("unauthenticated-response", UnauthenticatedResponseDefault, "how to respond to unauthenticated requests: \"default\" or \"negotiate\" (redirect requests that accept HTML to sign in and return 401 with the sign in URL to others)")
 	flagSet.Bool("skip-provider-button", false, "will skip sign-in-page to directly reach the next step: oauth/start")
 	flagSet.Bool("skip-auth-preflight", false, "will skip authentication for OPTIONS requests")
 	flagSet.Bool("ssl-insecure-skip-verify", false, "skip validation of certificates presented when using HTTPS providers")
 	flagSet.Bool("skip-jwt-bearer-tokens", false, "will skip requests that have verified JWT bearer tokens (default false)")
 	flagSet.Bool("force-json-errors", false, "will force JSON errors instead of HTTP error pages or redirects")
+	flagSet.Duration("auth-cache-ttl", time.Duration(0), "cache allowed /oauth2/auth decisions for each session cookie for this duration to avoid loading the session on every request (0 disables the cache)")
+	flagSet.Int("auth-cache-max-entries", DefaultAuthCacheMaxEntries, "the maximum number of decisions held in the /oauth2/auth cache")
+	flagSet.Duration("provider-lookup-cache-ttl", time.Duration(0), "cache the provider API calls made for each user when they sign in, such as their GitHub teams or GitLab project permissions, for this duration (0 disables the cache)")
+	flagSet.Int("provider-lookup-cache-max-entries", DefaultProviderLookupCacheMaxEntries, "the maximum number of provider lookups held in the provider lookup cache")
+	flagSet.Duration("session-validation-interval", time.Duration(0), "validate sessions with the provider when they are older than this duration and have not been validated within it (0 disables validation between refreshes)")
+	flagSet.Bool("client-certificate-sessions", false, "create sessions from client certificates verified by the HTTPS listener (requires --tls-client-ca-file)")
+	flagSet.String("client-certificate-user-attribute", ClientCertificateUserCN, "the client certificate attribute used as the session user: \"cn\", \"dns\", \"uri\" or \"email\" (the first SAN of the type)")
+	flagSet.StringSlice("silent-auth-origin", []string{}, "origin of a single-page app allowed to sign users in silently with the /oauth2/silent endpoint, which posts the result to the app (may be given multiple times)")
+	flagSet.Bool("websocket-auth-close-frames", false, "complete the handshake of unauthenticated WebSocket requests and close the connection with code 4401 (unauthenticated) or 4403 (forbidden) instead of returning an error page")
 	flagSet.StringSlice("extra-jwt-issuers", []string{}, "if skip-jwt-bearer-tokens is set, a list of extra JWT issuer=audience pairs (where the issuer URL has a .well-known/openid-configuration or a .well-known/jwks.json)")
 
 	flagSet.StringSlice("email-domain", []string{}, "authenticate emails with the specified domain (may be given multiple times). Use * to authenticate any email")
 	flagSet.StringSlice("whitelist-domain", []string{}, "allowed domains for redirection after authentication. Prefix domain with a . or a *. to allow subdomains (eg .example.com, *.example.com)")
+	flagSet.Bool("fips-mode", false, "restrict the TLS servers to FIPS 140-3 approved cipher suites and curves, and reject configuration of non-approved algorithms")
+	flagSet.Bool("sign-redirects", false, "sign the rd parameter of the sign in links generated by the proxy, and only accept unsigned rd parameters that are absolute URLs in the whitelist domains or redirect policy")
 	flagSet.String("authenticated-emails-file", "", "authenticate against emails via file (one per line)")
 	flagSet.String("htpasswd-file", "", "additionally authenticate against a htpasswd file. Entries must be created with \"htpasswd -B\" for bcrypt encryption")
 	flagSet.StringSlice("htpasswd-user-group", []string{}, "the groups to be set on sessions for htpasswd users (may be given multiple times)")
 	flagSet.String("proxy-prefix", "/oauth2", "the url root path that this proxy should be nested under (e.g. /<oauth2>/sign_in)")
 	flagSet.String("ping-path", "/ping", "the ping endpoint that can be used for basic health checks")
 	flagSet.String("ping-user-agent", "", "special User-Agent that will be used for basic health checks")
+	flagSet.String("ready-path", "/ready", "the ready endpoint that can be used for deep health checks")
 	flagSet.String("session-store-type", "cookie", "the session storage provider to use")
 	flagSet.Bool("session-cookie-minimal", false, "strip OAuth tokens from cookie session stores if they aren't needed (cookie session store only)")
+	flagSet.String("session-cookie-overflow", "", "store sessions too large for a cookie in redis, with \"redis\", instead of splitting them across cookies (cookie session store only)")
+	flagSet.Duration("session-replay-cache-ttl", 0, "remember the authorization codes and OIDC nonces of the callback for this duration, in the session store, to reject their replay; 0 to disable")
+	flagSet.Bool("session-sign-out-everywhere", false, "index the sessions of each user in the redis session store, so that users can sign out of all of their sessions at once")
+	flagSet.Bool("session-revoke-refresh-tokens", false, "revoke the refresh tokens of the sessions with the provider when users sign out everywhere")
+	flagSet.Duration("session-expiry-warning", 5*time.Minute, "warn the browsers listening to the session events at /oauth2/events this long before their session expires; 0 to disable")
 	flagSet.String("redis-connection-url", "", "URL of redis server for redis session storage (eg: redis://HOST[:PORT])")
 	flagSet.String("redis-password", "", "Redis password. Applicable for all Redis configurations. Will override any password set in `--redis-connection-url`")
 	flagSet.Bool("redis-use-sentinel", false, "Connect to redis via sentinels. Must set --redis-sentinel-master-name and --redis-sentinel-connection-urls to use this feature")
@@ -142,16 +267,37 @@ func NewFlagSet() *pflag.FlagSet {
 	flagSet.String("redis-sentinel-master-name", "", "Redis sentinel master name. Used in conjunction with --redis-use-sentinel")
 	flagSet.String("redis-ca-path", "", "Redis custom CA path")
 	flagSet.Bool("redis-insecure-skip-tls-verify", false, "Use insecure TLS connection to redis")
+	flagSet.Bool("redis-use-spiffe", false, "Connect to redis with mutual TLS using the SPIFFE workload identity of the proxy")
+	flagSet.String("redis-spiffe-endpoint-socket", "", "Address of the SPIFFE workload API (eg unix:///run/spire/sockets/agent.sock). Defaults to the SPIFFE_ENDPOINT_SOCKET environment variable")
+	flagSet.StringSlice("redis-spiffe-id", []string{}, "SPIFFE ID that redis may present (may be given multiple times). Defaults to any ID in the trust domain of the proxy")
 	flagSet.StringSlice("redis-sentinel-connection-urls", []string{}, "List of Redis sentinel connection URLs (eg redis://HOST[:PORT]). Used in conjunction with --redis-use-sentinel")
 	flagSet.Bool("redis-use-cluster", false, "Connect to redis cluster. Must set --redis-cluster-connection-urls to use this feature")
 	flagSet.StringSlice("redis-cluster-connection-urls", []string{}, "List of Redis cluster connection URLs (eg redis://HOST[:PORT]). Used in conjunction with --redis-use-cluster")
 	flagSet.Int("redis-connection-idle-timeout", 0, "Redis connection idle timeout seconds, if Redis timeout option is non-zero, the --redis-connection-idle-timeout must be less then Redis timeout option")
+	flagSet.Int("redis-connection-pool-size", 0, "maximum number of connections to each Redis node (0 for 10 connections per CPU, or 5 with Redis cluster)")
+	flagSet.Int("redis-connection-min-idle", 0, "minimum number of idle connections kept open to each Redis node, so that bursts of requests do not wait for new connections")
+	flagSet.Duration("redis-connection-dial-timeout", time.Duration(0), "timeout for establishing new connections to Redis (0 for 5 seconds)")
+	flagSet.Duration("redis-connection-read-timeout", time.Duration(0), "timeout for reading the replies of Redis (0 for 3 seconds, -1 for no timeout)")
+	flagSet.Duration("redis-connection-write-timeout", time.Duration(0), "timeout for writing commands to Redis (0 for the read timeout, -1 for no timeout)")
+	flagSet.Duration("redis-connection-pool-timeout", time.Duration(0), "time to wait for a connection when all the connections of the pool are busy (0 for the read timeout plus 1 second)")
 	flagSet.String("signature-key", "", "GAP-Signature request signature key (algorithm:secretkey)")
 	flagSet.Bool("gcp-healthchecks", false, "Enable GCP/GKE healthcheck endpoints")
+	flagSet.String("jwt-assertion-key-file", "", "path to a PEM encoded RSA, ECDSA or Ed25519 private key signing JWT assertions of the identity of users sent to upstreams in the X-Auth-Request-Jwt-Assertion header. Its public key is served at /oauth2/jwks")
+	flagSet.String("jwt-assertion-issuer", DefaultJWTAssertionIssuer, "the issuer (iss) of the JWT assertions sent to upstreams")
+	flagSet.String("alpha-config-public-key-file", "", "path to a PEM encoded public key used to verify the signature of a remote alpha config, published at the URL of the config with a .sig suffix")
+	flagSet.Duration("alpha-config-poll-interval", time.Duration(0), "interval at which to poll a remote alpha config for changes and reload it (0 to disable)")
+	flagSet.Bool("watch-secret-files", false, "reload the configuration when the client secret, TLS certificate and key or JWT key files change")
+	flagSet.Duration("secret-refresh-interval", DefaultSecretRefreshInterval, "interval at which secrets referenced from secret stores, eg. vault:<path>#<field>, are read again and the configuration reloaded when they change (0 to disable). Leased secrets are always renewed before they expire")
 
 	flagSet.AddFlagSet(cookieFlagSet())
 	flagSet.AddFlagSet(loggingFlagSet())
 	flagSet.AddFlagSet(templatesFlagSet())
+	flagSet.AddFlagSet(statsdFlagSet())
+	flagSet.AddFlagSet(providerClientFlagSet())
+	flagSet.AddFlagSet(loadSheddingFlagSet())
+	flagSet.AddFlagSet(signInChallengeFlagSet())
+	flagSet.AddFlagSet(securityHeadersFlagSet())
+	flagSet.AddFlagSet(consentFlagSet())
 
 	return flagSet
 }
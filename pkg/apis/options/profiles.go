@@ -0,0 +1,93 @@
+package options
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileStrict is the profile of hardened defaults for new deployments.
+const ProfileStrict = "strict"
+
+// profileOption is the name of the option that selects the profile.
+const profileOption = "profile"
+
+// Profiles are named presets of option defaults, keyed by the name of the
+// option in the config file, with values in the format of their flags.
+// A profile only changes defaults: options set by flags, environment
+// variables or the config file take precedence over the profile.
+var Profiles = map[string]map[string]string{
+	ProfileStrict: {
+		"cookie_name":           "__Host-oauth2_proxy",
+		"cookie_secure":         "true",
+		"cookie_samesite":       "strict",
+		"cookie_refresh":        "5m",
+		"code_challenge_method": "S256",
+		"tls_min_version":       "TLS1.2",
+		"tls_cipher_suites": strings.Join([]string{
+			"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+			"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+		}, ","),
+	},
+}
+
+// applyProfile sets the defaults of the profile selected by the profile
+// option, when the options have one.
+// The defaults are only set for the options that exist in the options
+// interface, as the alpha configuration replaces some of the options.
+func applyProfile(v *viper.Viper, into interface{}) error {
+	if !hasOption(reflect.TypeOf(into), profileOption) {
+		return nil
+	}
+
+	name := v.GetString(profileOption)
+	if name == "" {
+		return nil
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		var names []string
+		for profileName := range Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown profile %q, must be one of: %s", name, strings.Join(names, ", "))
+	}
+
+	for option, value := range profile {
+		if hasOption(reflect.TypeOf(into), option) {
+			v.SetDefault(option, value)
+		}
+	}
+	return nil
+}
+
+// hasOption determines whether the options type has a field with the `cfg`
+// name given, including within squashed structs.
+func hasOption(typ reflect.Type, cfgName string) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("cfg")
+		if tag == ",squash" && hasOption(field.Type, cfgName) {
+			return true
+		}
+		if tag == cfgName {
+			return true
+		}
+	}
+	return false
+}
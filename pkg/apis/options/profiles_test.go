@@ -0,0 +1,113 @@
+package options
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profiles", func() {
+	type profileTableInput struct {
+		configFile  string
+		env         map[string]string
+		args        []string
+		expectedErr error
+		check       func(*LegacyOptions)
+	}
+
+	strictCipherSuites := []string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	}
+
+	DescribeTable("Load with a profile",
+		func(in profileTableInput) {
+			var configFileName string
+			if in.configFile != "" {
+				configFile, err := ioutil.TempFile("", "oauth2-proxy-test-profile-config-file")
+				Expect(err).ToNot(HaveOccurred())
+				defer os.Remove(configFile.Name())
+				_, err = configFile.Write([]byte(in.configFile))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(configFile.Close()).To(Succeed())
+				configFileName = configFile.Name()
+			}
+
+			for k, v := range in.env {
+				Expect(os.Setenv(k, v)).To(Succeed())
+				defer os.Unsetenv(k)
+			}
+
+			flagSet := NewLegacyFlagSet()
+			Expect(flagSet.Parse(in.args)).To(Succeed())
+
+			legacyOpts := &LegacyOptions{}
+			err := Load(configFileName, flagSet, legacyOpts)
+			if in.expectedErr != nil {
+				Expect(err).To(MatchError(in.expectedErr.Error()))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			in.check(legacyOpts)
+		},
+		Entry("without a profile", profileTableInput{
+			check: func(l *LegacyOptions) {
+				Expect(l.Options.Cookie).To(Equal(cookieDefaults()))
+				Expect(l.LegacyProvider.CodeChallengeMethod).To(BeEmpty())
+				Expect(l.LegacyServer.TLSCipherSuites).To(BeEmpty())
+			},
+		}),
+		Entry("with the strict profile flag", profileTableInput{
+			args: []string{"--profile=strict"},
+			check: func(l *LegacyOptions) {
+				Expect(l.Options.Profile).To(Equal(ProfileStrict))
+				Expect(l.Options.Cookie.Name).To(Equal("__Host-oauth2_proxy"))
+				Expect(l.Options.Cookie.Secure).To(BeTrue())
+				Expect(l.Options.Cookie.SameSite).To(Equal("strict"))
+				Expect(l.Options.Cookie.Refresh).To(Equal(5 * time.Minute))
+				Expect(l.LegacyProvider.CodeChallengeMethod).To(Equal("S256"))
+				Expect(l.LegacyServer.TLSMinVersion).To(Equal("TLS1.2"))
+				Expect(l.LegacyServer.TLSCipherSuites).To(Equal(strictCipherSuites))
+			},
+		}),
+		Entry("with the strict profile in the environment", profileTableInput{
+			env: map[string]string{"OAUTH2_PROXY_PROFILE": "strict"},
+			check: func(l *LegacyOptions) {
+				Expect(l.Options.Cookie.SameSite).To(Equal("strict"))
+			},
+		}),
+		Entry("with the strict profile and explicit overrides", profileTableInput{
+			configFile: "profile = \"strict\"\ncookie_samesite = \"lax\"\n",
+			env:        map[string]string{"OAUTH2_PROXY_COOKIE_NAME": "_oauth2_proxy"},
+			args:       []string{"--cookie-refresh=1h"},
+			check: func(l *LegacyOptions) {
+				Expect(l.Options.Cookie.SameSite).To(Equal("lax"))
+				Expect(l.Options.Cookie.Name).To(Equal("_oauth2_proxy"))
+				Expect(l.Options.Cookie.Refresh).To(Equal(time.Hour))
+				Expect(l.LegacyProvider.CodeChallengeMethod).To(Equal("S256"))
+			},
+		}),
+		Entry("with an unknown profile", profileTableInput{
+			args:        []string{"--profile=lax"},
+			expectedErr: errors.New("unable to apply profile: unknown profile \"lax\", must be one of: strict"),
+		}),
+	)
+
+	It("applies the options of the profile in the core options", func() {
+		flagSet := NewFlagSet()
+		Expect(flagSet.Parse([]string{"--profile=strict"})).To(Succeed())
+
+		opts := &Options{}
+		Expect(Load("", flagSet, opts)).To(Succeed())
+		Expect(opts.Cookie.SameSite).To(Equal("strict"))
+	})
+})
@@ -0,0 +1,99 @@
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// DefaultProviderMaxIdleConns is the default maximum number of idle
+	// connections to each provider.
+	DefaultProviderMaxIdleConns = 100
+
+	// DefaultProviderMaxIdleConnsPerHost is the default maximum number of idle
+	// connections to each host of a provider.
+	DefaultProviderMaxIdleConnsPerHost = 10
+
+	// DefaultProviderTLSSessionCacheSize is the default number of TLS sessions
+	// kept to resume the connections to each provider.
+	DefaultProviderTLSSessionCacheSize = 64
+)
+
+// ProviderClient contains the options for the connections of the requests to
+// the providers. Each provider has connections of its own, so that a slow
+// provider cannot take all the connections and delay the requests to the
+// others.
+type ProviderClient struct {
+	// MaxIdleConns is the maximum number of idle connections to each
+	// provider.
+	MaxIdleConns int `flag:"provider-max-idle-conns" cfg:"provider_max_idle_conns"`
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections to each
+	// host of a provider.
+	MaxIdleConnsPerHost int `flag:"provider-max-idle-conns-per-host" cfg:"provider_max_idle_conns_per_host"`
+
+	// MaxConnsPerHost limits the connections to each host of a provider.
+	// Requests wait for a connection once the limit is reached.
+	// No limit is applied when it is zero.
+	MaxConnsPerHost int `flag:"provider-max-conns-per-host" cfg:"provider_max_conns_per_host"`
+
+	// IdleConnTimeout is how long idle connections are kept open.
+	// They are kept until they are closed by the provider when it is zero.
+	IdleConnTimeout time.Duration `flag:"provider-idle-conn-timeout" cfg:"provider_idle_conn_timeout"`
+
+	// KeepAlive is the interval of the TCP keep-alive probes of the
+	// connections. The probes are disabled when it is negative.
+	KeepAlive time.Duration `flag:"provider-keep-alive" cfg:"provider_keep_alive"`
+
+	// TLSSessionCacheSize is the number of TLS sessions kept to resume the
+	// connections to each provider without a full handshake.
+	// Sessions are not resumed when it is zero.
+	TLSSessionCacheSize int `flag:"provider-tls-session-cache-size" cfg:"provider_tls_session_cache_size"`
+
+	// DialTimeout is the timeout of establishing connections.
+	DialTimeout time.Duration `flag:"provider-dial-timeout" cfg:"provider_dial_timeout"`
+
+	// TLSHandshakeTimeout is the timeout of the TLS handshakes of the
+	// connections.
+	TLSHandshakeTimeout time.Duration `flag:"provider-tls-handshake-timeout" cfg:"provider_tls_handshake_timeout"`
+
+	// ResponseHeaderTimeout is the time to wait for the headers of the
+	// responses once the requests are sent.
+	ResponseHeaderTimeout time.Duration `flag:"provider-response-header-timeout" cfg:"provider_response_header_timeout"`
+
+	// RequestTimeout is the timeout of the requests, including reading the
+	// bodies of the responses.
+	RequestTimeout time.Duration `flag:"provider-request-timeout" cfg:"provider_request_timeout"`
+}
+
+func providerClientFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("provider-client", pflag.ExitOnError)
+
+	flagSet.Int("provider-max-idle-conns", DefaultProviderMaxIdleConns, "maximum number of idle connections to each provider")
+	flagSet.Int("provider-max-idle-conns-per-host", DefaultProviderMaxIdleConnsPerHost, "maximum number of idle connections to each host of a provider")
+	flagSet.Int("provider-max-conns-per-host", 0, "maximum number of connections to each host of a provider, requests wait for a connection once it is reached (0 for no limit)")
+	flagSet.Duration("provider-idle-conn-timeout", 90*time.Second, "how long idle connections to the providers are kept open (0 to keep them until the provider closes them)")
+	flagSet.Duration("provider-keep-alive", 30*time.Second, "interval of the TCP keep-alive probes of the connections to the providers (negative to disable the probes)")
+	flagSet.Int("provider-tls-session-cache-size", DefaultProviderTLSSessionCacheSize, "number of TLS sessions kept to resume the connections to each provider without a full handshake (0 to disable resumption)")
+	flagSet.Duration("provider-dial-timeout", 30*time.Second, "timeout of establishing connections to the providers")
+	flagSet.Duration("provider-tls-handshake-timeout", 10*time.Second, "timeout of the TLS handshakes of the connections to the providers")
+	flagSet.Duration("provider-response-header-timeout", time.Duration(0), "time to wait for the headers of the responses of the providers (0 for no timeout)")
+	flagSet.Duration("provider-request-timeout", time.Duration(0), "timeout of the requests to the providers, including reading their responses (0 for no timeout)")
+
+	return flagSet
+}
+
+// providerClientDefaults creates a ProviderClient structure, populating each
+// field with its default value
+func providerClientDefaults() ProviderClient {
+	return ProviderClient{
+		MaxIdleConns:        DefaultProviderMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultProviderMaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSSessionCacheSize: DefaultProviderTLSSessionCacheSize,
+		DialTimeout:         30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
@@ -1,11 +1,17 @@
 package options
 
+import "time"
+
 const (
 	// OIDCEmailClaim is the generic email claim used by the OIDC provider.
 	OIDCEmailClaim = "email"
 
 	// OIDCGroupsClaim is the generic groups claim used by the OIDC provider.
 	OIDCGroupsClaim = "groups"
+
+	// DefaultOIDCKeysRefreshInterval is the default value for the OIDC
+	// KeysRefreshInterval.
+	DefaultOIDCKeysRefreshInterval = 15 * time.Minute
 )
 
 // OIDCAudienceClaims is the generic audience claim list used by the OIDC provider.
@@ -21,7 +27,7 @@ type Provider struct {
 	ClientID string `json:"clientID,omitempty"`
 	// ClientSecret is the OAuth Client Secret that is defined in the provider
 	// This value is required for all providers.
-	ClientSecret string `json:"clientSecret,omitempty"`
+	ClientSecret Secret `json:"clientSecret,omitempty"`
 	// ClientSecretFile is the name of the file
 	// containing the OAuth Client Secret, it will be used if ClientSecret is not set.
 	ClientSecretFile string `json:"clientSecretFile,omitempty"`
@@ -56,6 +62,9 @@ type Provider struct {
 	// Name is the providers display name
 	// if set, it will be shown to the users in the login page.
 	Name string `json:"name,omitempty"`
+	// SignInGroup is the heading the button of the provider is listed under
+	// on the sign-in page, with the other providers of the group.
+	SignInGroup string `json:"signInGroup,omitempty"`
 	// CAFiles is a list of paths to CA certificates that should be used when connecting to the provider.
 	// If not specified, the default Go trust sources are used instead
 	CAFiles []string `json:"caFiles,omitempty"`
@@ -72,6 +81,19 @@ type Provider struct {
 	ProtectedResource string `json:"resource,omitempty"`
 	// ValidateURL is the access token validation endpoint
 	ValidateURL string `json:"validateURL,omitempty"`
+	// RPInitiatedLogout signs users out of the provider too when they sign
+	// out, by redirecting them to the LogoutURL with their ID token as the
+	// `id_token_hint` and the validated sign out redirect as the
+	// `post_logout_redirect_uri`.
+	RPInitiatedLogout bool `json:"rpInitiatedLogout,omitempty"`
+	// LogoutURL is the end session endpoint of the provider.
+	// Defaults to the `end_session_endpoint` of the OIDC discovery.
+	LogoutURL string `json:"logoutURL,omitempty"`
+	// RevokeURL is the token revocation endpoint of the provider, which
+	// revokes the refresh tokens of the sessions of users who sign out
+	// everywhere.
+	// Defaults to the `revocation_endpoint` of the OIDC discovery.
+	RevokeURL string `json:"revokeURL,omitempty"`
 	// Scope is the OAuth scope specification
 	Scope string `json:"scope,omitempty"`
 	// AllowedGroups is a list of restrict logins to members of this group
@@ -225,6 +247,12 @@ type OIDCOptions struct {
 	// ExtraAudiences is a list of additional audiences that are allowed
 	// to pass verification in addition to the client id.
 	ExtraAudiences []string `json:"extraAudiences,omitempty"`
+	// KeysRefreshInterval is the period between refreshes of the discovery
+	// document and the signing keys of the provider, which are fetched in the
+	// background so that key rotations do not delay requests. The previous
+	// keys are used while the provider cannot be reached.
+	// Defaults to 15m.
+	KeysRefreshInterval *Duration `json:"keysRefreshInterval,omitempty"`
 }
 
 type LoginGovOptions struct {
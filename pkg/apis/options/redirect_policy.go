@@ -0,0 +1,44 @@
+package options
+
+// RedirectPolicy allows redirects after signing in or out to absolute URLs
+// that match its rules, in addition to the whitelist domains.
+// Relative redirects are always allowed.
+type RedirectPolicy struct {
+	// Rules are the URLs that redirects are allowed to.
+	// A redirect is allowed when it matches any of the rules.
+	Rules []RedirectRule `json:"rules,omitempty"`
+}
+
+// RedirectRule matches the absolute URLs of redirects.
+// A redirect matches the rule when it matches all of the conditions set on
+// the rule. Each rule must set a domain or a regex.
+type RedirectRule struct {
+	// Domain is the host of the redirect, without a port.
+	// A leading `.` or `*.` also matches any subdomain, eg `.example.com`.
+	Domain string `json:"domain,omitempty"`
+
+	// Schemes are the allowed schemes of the redirect, `http` or `https`.
+	// Defaults to both.
+	Schemes []string `json:"schemes,omitempty"`
+
+	// Ports are the allowed ports of the redirect, as single ports, eg
+	// `8443`, or inclusive ranges, eg `8000-8999`.
+	// Redirects without a port are on the default port of their scheme, 80
+	// or 443.
+	// Defaults to the default port of the scheme only.
+	Ports []string `json:"ports,omitempty"`
+
+	// PathPrefixes are the allowed prefixes of the path of the redirect,
+	// which is cleaned of `.` and `..` segments before it is matched.
+	// End a prefix with `/` to only match its subpaths, eg `/app/`.
+	// Defaults to any path.
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+
+	// Regex is a regular expression matched against the whole URL of the
+	// redirect. It is always anchored at the start and end of the URL, so
+	// that it cannot match a URL that only contains an allowed URL.
+	// The other conditions of the rule also apply, so set the ports of
+	// URLs on other ports than the default port of their scheme.
+	// Eg: `https://[a-z0-9-]+\.apps\.example\.com/.*`
+	Regex string `json:"regex,omitempty"`
+}
@@ -0,0 +1,51 @@
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// SecurityHeaders contains the options for the security headers added to
+// the responses that the proxy generates itself, such as its sign in, error
+// and health check responses. Responses of upstreams are not changed.
+type SecurityHeaders struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header of
+	// HTTPS responses. The header is not sent when it is zero.
+	HSTSMaxAge time.Duration `flag:"hsts-max-age" cfg:"hsts_max_age"`
+
+	// HSTSIncludeSubdomains adds the includeSubDomains directive to the
+	// Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool `flag:"hsts-include-subdomains" cfg:"hsts_include_subdomains"`
+
+	// HSTSPreload adds the preload directive to the Strict-Transport-Security
+	// header, to consent to the domain being added to the HSTS preload lists
+	// of browsers.
+	HSTSPreload bool `flag:"hsts-preload" cfg:"hsts_preload"`
+
+	// ContentTypeNosniff sets the X-Content-Type-Options header to nosniff.
+	ContentTypeNosniff bool `flag:"content-type-nosniff" cfg:"content_type_nosniff"`
+
+	// CrossOriginOpenerPolicy is the Cross-Origin-Opener-Policy header.
+	CrossOriginOpenerPolicy string `flag:"cross-origin-opener-policy" cfg:"cross_origin_opener_policy"`
+
+	// CrossOriginEmbedderPolicy is the Cross-Origin-Embedder-Policy header.
+	CrossOriginEmbedderPolicy string `flag:"cross-origin-embedder-policy" cfg:"cross_origin_embedder_policy"`
+
+	// PermissionsPolicy is the Permissions-Policy header.
+	PermissionsPolicy string `flag:"permissions-policy" cfg:"permissions_policy"`
+}
+
+func securityHeadersFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("security-headers", pflag.ExitOnError)
+
+	flagSet.Duration("hsts-max-age", time.Duration(0), "max-age of the Strict-Transport-Security header of HTTPS responses generated by the proxy (0 disables the header)")
+	flagSet.Bool("hsts-include-subdomains", false, "add the includeSubDomains directive to the Strict-Transport-Security header")
+	flagSet.Bool("hsts-preload", false, "add the preload directive to the Strict-Transport-Security header")
+	flagSet.Bool("content-type-nosniff", false, "set the X-Content-Type-Options header of responses generated by the proxy to nosniff")
+	flagSet.String("cross-origin-opener-policy", "", "Cross-Origin-Opener-Policy header of responses generated by the proxy")
+	flagSet.String("cross-origin-embedder-policy", "", "Cross-Origin-Embedder-Policy header of responses generated by the proxy")
+	flagSet.String("permissions-policy", "", "Permissions-Policy header of responses generated by the proxy")
+
+	return flagSet
+}
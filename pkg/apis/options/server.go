@@ -13,6 +13,54 @@ type Server struct {
 	// TLS contains the information for loading the certificate and key for the
 	// secure traffic and further configuration for the TLS server.
 	TLS *TLS
+
+	// EnableHTTP2 allows clients to connect using HTTP/2.
+	// Secure traffic negotiates HTTP/2 with ALPN and insecure traffic accepts
+	// cleartext HTTP/2 (h2c).
+	// This is required to proxy gRPC requests.
+	EnableHTTP2 bool
+
+	// ACME enables automatic issuance and renewal of the certificate for the
+	// secure traffic using the ACME protocol, eg. with Let's Encrypt.
+	// When set, the TLS key and cert must not be set.
+	ACME *ACME
+
+	// BearerToken is the token that requests must present in their
+	// Authorization header, as `Bearer <token>`, to be served.
+	// Typically this will come from a file.
+	// This is only supported by the metrics server.
+	BearerToken *SecretSource
+}
+
+// ACME contains the configuration for requesting certificates from an ACME
+// certificate authority.
+// Challenges are answered using TLS-ALPN-01 on the secure address and
+// HTTP-01 on the insecure address, which must be reachable on port 80 for
+// HTTP-01 to succeed.
+type ACME struct {
+	// Domains is the list of host names certificates may be requested for.
+	// Requests for other host names are rejected during the TLS handshake.
+	Domains []string
+
+	// Email is the contact address registered with the ACME account, used
+	// by the certificate authority to notify about problems with certificates.
+	Email string
+
+	// DirectoryURL is the URL of the ACME directory of the certificate authority.
+	// Defaults to the Let's Encrypt production directory.
+	DirectoryURL string
+
+	// AcceptTOS confirms that the terms of service of the certificate
+	// authority have been accepted. This must be set to request certificates.
+	AcceptTOS bool
+
+	// CacheDir is the directory where the account key and certificates are
+	// stored so they are reused after a restart.
+	CacheDir string
+
+	// UseSessionStore stores the account key and certificates in the Redis
+	// session store instead of a directory so they are shared by all replicas.
+	UseSessionStore bool
 }
 
 // TLS contains the information for loading a TLS certificate and key
@@ -37,4 +85,42 @@ type TLS struct {
 	// If not specified, the default Go safe cipher list is used.
 	// List of valid cipher suites can be found in the [crypto/tls documentation](https://pkg.go.dev/crypto/tls#pkg-constants).
 	CipherSuites []string
+
+	// AdditionalCertificates are served in place of the Key and Cert to
+	// clients that request a server name (SNI) matching one of the names in
+	// the certificate, including wildcard names such as `*.example.com`.
+	// The Key and Cert are served when no additional certificate matches.
+	AdditionalCertificates []TLSCertificate
+
+	// ClientCA is the PEM encoded bundle of CA certificates used to verify
+	// the certificates presented by clients.
+	// Typically this will come from a file.
+	ClientCA *SecretSource
+
+	// ClientAuth determines whether clients must present a certificate when
+	// a ClientCA is set.
+	// Either "optional", which verifies certificates presented by clients but
+	// allows clients without a certificate, or "required".
+	// Defaults to "optional".
+	ClientAuth string
+}
+
+const (
+	// TLSClientAuthOptional verifies client certificates when presented.
+	TLSClientAuthOptional = "optional"
+
+	// TLSClientAuthRequired rejects clients that do not present a valid
+	// certificate.
+	TLSClientAuthRequired = "required"
+)
+
+// TLSCertificate contains the information for loading a TLS certificate and key.
+type TLSCertificate struct {
+	// Key is the TLS key data to use.
+	// Typically this will come from a file.
+	Key *SecretSource
+
+	// Cert is the TLS certificate data to use.
+	// Typically this will come from a file.
+	Cert *SecretSource
 }
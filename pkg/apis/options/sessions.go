@@ -1,10 +1,28 @@
 package options
 
+import "time"
+
 // SessionOptions contains configuration options for the SessionStore providers.
 type SessionOptions struct {
 	Type   string             `flag:"session-store-type" cfg:"session_store_type"`
 	Cookie CookieStoreOptions `cfg:",squash"`
 	Redis  RedisStoreOptions  `cfg:",squash"`
+
+	// ReplayCacheTTL is how long the authorization codes and OIDC nonces of
+	// the callback are remembered to reject their replay, 0 to disable
+	ReplayCacheTTL time.Duration `flag:"session-replay-cache-ttl" cfg:"session_replay_cache_ttl"`
+
+	// SignOutEverywhere indexes the sessions of each user in the redis session
+	// store, so that users can sign out of all of their sessions at once
+	SignOutEverywhere bool `flag:"session-sign-out-everywhere" cfg:"session_sign_out_everywhere"`
+
+	// RevokeRefreshTokens revokes the refresh tokens of the sessions with the
+	// provider when users sign out everywhere
+	RevokeRefreshTokens bool `flag:"session-revoke-refresh-tokens" cfg:"session_revoke_refresh_tokens"`
+
+	// ExpiryWarning is how long before the session expires the browsers
+	// listening to its events are warned that it is about to expire
+	ExpiryWarning time.Duration `flag:"session-expiry-warning" cfg:"session_expiry_warning"`
 }
 
 // CookieSessionStoreType is used to indicate the CookieSessionStore should be
@@ -18,21 +36,38 @@ var RedisSessionStoreType = "redis"
 // CookieStoreOptions contains configuration options for the CookieSessionStore.
 type CookieStoreOptions struct {
 	Minimal bool `flag:"session-cookie-minimal" cfg:"session_cookie_minimal"`
+
+	// Overflow is where the sessions too large for a cookie are stored
+	// instead, empty to split them across cookies
+	Overflow string `flag:"session-cookie-overflow" cfg:"session_cookie_overflow"`
 }
 
+// RedisCookieOverflow is used to indicate that the CookieSessionStore should
+// store the sessions too large for a cookie in Redis.
+var RedisCookieOverflow = "redis"
+
 // RedisStoreOptions contains configuration options for the RedisSessionStore.
 type RedisStoreOptions struct {
-	ConnectionURL          string   `flag:"redis-connection-url" cfg:"redis_connection_url"`
-	Password               string   `flag:"redis-password" cfg:"redis_password"`
-	UseSentinel            bool     `flag:"redis-use-sentinel" cfg:"redis_use_sentinel"`
-	SentinelPassword       string   `flag:"redis-sentinel-password" cfg:"redis_sentinel_password"`
-	SentinelMasterName     string   `flag:"redis-sentinel-master-name" cfg:"redis_sentinel_master_name"`
-	SentinelConnectionURLs []string `flag:"redis-sentinel-connection-urls" cfg:"redis_sentinel_connection_urls"`
-	UseCluster             bool     `flag:"redis-use-cluster" cfg:"redis_use_cluster"`
-	ClusterConnectionURLs  []string `flag:"redis-cluster-connection-urls" cfg:"redis_cluster_connection_urls"`
-	CAPath                 string   `flag:"redis-ca-path" cfg:"redis_ca_path"`
-	InsecureSkipTLSVerify  bool     `flag:"redis-insecure-skip-tls-verify" cfg:"redis_insecure_skip_tls_verify"`
-	IdleTimeout            int      `flag:"redis-connection-idle-timeout" cfg:"redis_connection_idle_timeout"`
+	ConnectionURL          string        `flag:"redis-connection-url" cfg:"redis_connection_url"`
+	Password               string        `flag:"redis-password" cfg:"redis_password"`
+	UseSentinel            bool          `flag:"redis-use-sentinel" cfg:"redis_use_sentinel"`
+	SentinelPassword       string        `flag:"redis-sentinel-password" cfg:"redis_sentinel_password"`
+	SentinelMasterName     string        `flag:"redis-sentinel-master-name" cfg:"redis_sentinel_master_name"`
+	SentinelConnectionURLs []string      `flag:"redis-sentinel-connection-urls" cfg:"redis_sentinel_connection_urls"`
+	UseCluster             bool          `flag:"redis-use-cluster" cfg:"redis_use_cluster"`
+	ClusterConnectionURLs  []string      `flag:"redis-cluster-connection-urls" cfg:"redis_cluster_connection_urls"`
+	CAPath                 string        `flag:"redis-ca-path" cfg:"redis_ca_path"`
+	InsecureSkipTLSVerify  bool          `flag:"redis-insecure-skip-tls-verify" cfg:"redis_insecure_skip_tls_verify"`
+	IdleTimeout            int           `flag:"redis-connection-idle-timeout" cfg:"redis_connection_idle_timeout"`
+	PoolSize               int           `flag:"redis-connection-pool-size" cfg:"redis_connection_pool_size"`
+	MinIdleConns           int           `flag:"redis-connection-min-idle" cfg:"redis_connection_min_idle"`
+	DialTimeout            time.Duration `flag:"redis-connection-dial-timeout" cfg:"redis_connection_dial_timeout"`
+	ReadTimeout            time.Duration `flag:"redis-connection-read-timeout" cfg:"redis_connection_read_timeout"`
+	WriteTimeout           time.Duration `flag:"redis-connection-write-timeout" cfg:"redis_connection_write_timeout"`
+	PoolTimeout            time.Duration `flag:"redis-connection-pool-timeout" cfg:"redis_connection_pool_timeout"`
+	UseSPIFFE              bool          `flag:"redis-use-spiffe" cfg:"redis_use_spiffe"`
+	SPIFFEEndpointSocket   string        `flag:"redis-spiffe-endpoint-socket" cfg:"redis_spiffe_endpoint_socket"`
+	SPIFFEIDs              []string      `flag:"redis-spiffe-id" cfg:"redis_spiffe_ids"`
 }
 
 func sessionOptionsDefaults() SessionOptions {
@@ -41,5 +76,6 @@ func sessionOptionsDefaults() SessionOptions {
 		Cookie: CookieStoreOptions{
 			Minimal: false,
 		},
+		ExpiryWarning: 5 * time.Minute,
 	}
 }
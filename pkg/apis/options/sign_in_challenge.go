@@ -0,0 +1,58 @@
+package options
+
+import "github.com/spf13/pflag"
+
+const (
+	// SignInChallengeHCaptcha verifies users with hCaptcha
+	SignInChallengeHCaptcha = "hcaptcha"
+
+	// SignInChallengeTurnstile verifies users with Cloudflare Turnstile
+	SignInChallengeTurnstile = "turnstile"
+
+	// SignInChallengeProofOfWork verifies users with a proof-of-work puzzle
+	// solved by their browser
+	SignInChallengeProofOfWork = "proof-of-work"
+
+	// DefaultProofOfWorkDifficulty is the default number of leading zero bits
+	// of proof-of-work solutions
+	DefaultProofOfWorkDifficulty = 16
+)
+
+// SignInChallenge contains the options for the challenge that users pass on
+// the sign_in page before signing in with the provider or the htpasswd form,
+// to slow automated abuse of the login endpoints.
+type SignInChallenge struct {
+	// Type is the type of the challenge: hcaptcha, turnstile or
+	// proof-of-work. The sign_in page has no challenge when it is empty.
+	Type string `flag:"sign-in-challenge" cfg:"sign_in_challenge"`
+
+	// SiteKey is the site key of the hCaptcha or Turnstile widget.
+	SiteKey string `flag:"sign-in-challenge-site-key" cfg:"sign_in_challenge_site_key"`
+
+	// SecretKey is the secret key used to verify hCaptcha or Turnstile
+	// responses.
+	SecretKey string `flag:"sign-in-challenge-secret-key" cfg:"sign_in_challenge_secret_key"`
+
+	// ProofOfWorkDifficulty is the number of leading zero bits of the hash of
+	// proof-of-work solutions. Each extra bit doubles the work of the browser.
+	ProofOfWorkDifficulty int `flag:"sign-in-challenge-pow-difficulty" cfg:"sign_in_challenge_pow_difficulty"`
+}
+
+func signInChallengeFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("sign-in-challenge", pflag.ExitOnError)
+
+	flagSet.String("sign-in-challenge", "", "challenge users pass on the sign_in page before signing in (hcaptcha, turnstile or proof-of-work)")
+	flagSet.String("sign-in-challenge-site-key", "", "site key of the hCaptcha or Turnstile sign-in challenge")
+	flagSet.String("sign-in-challenge-secret-key", "", "secret key of the hCaptcha or Turnstile sign-in challenge")
+	flagSet.Int("sign-in-challenge-pow-difficulty", DefaultProofOfWorkDifficulty, "number of leading zero bits of the hash of proof-of-work sign-in challenge solutions")
+
+	return flagSet
+}
+
+// signInChallengeDefaults creates a SignInChallenge structure, populating
+// each field with its default value
+func signInChallengeDefaults() SignInChallenge {
+	return SignInChallenge{
+		ProofOfWorkDifficulty: DefaultProofOfWorkDifficulty,
+	}
+}
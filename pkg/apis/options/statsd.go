@@ -0,0 +1,35 @@
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// StatsD contains the options for sending metrics to a DogStatsD agent, such
+// as the Datadog agent, instead of or as well as serving them to Prometheus
+type StatsD struct {
+	Address       string        `flag:"statsd-address" cfg:"statsd_address"`
+	Prefix        string        `flag:"statsd-prefix" cfg:"statsd_prefix"`
+	Tags          []string      `flag:"statsd-tag" cfg:"statsd_tags"`
+	FlushInterval time.Duration `flag:"statsd-flush-interval" cfg:"statsd_flush_interval"`
+}
+
+func statsdFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("statsd", pflag.ExitOnError)
+
+	flagSet.String("statsd-address", "", "DogStatsD agent to send metrics to (host:port, udp://host:port or unix:///path/to/dsd.socket)")
+	flagSet.String("statsd-prefix", "", "Prefix of the names of the metrics sent to the DogStatsD agent (eg. \"oauth2_proxy.\")")
+	flagSet.StringSlice("statsd-tag", []string{}, "Tag added to the metrics sent to the DogStatsD agent, as key:value (may be given multiple times)")
+	flagSet.Duration("statsd-flush-interval", 10*time.Second, "Interval at which metrics are sent to the DogStatsD agent")
+
+	return flagSet
+}
+
+// statsdDefaults creates a StatsD structure, populating each field with its
+// default value
+func statsdDefaults() StatsD {
+	return StatsD{
+		FlushInterval: 10 * time.Second,
+	}
+}
@@ -0,0 +1,78 @@
+package options
+
+// Tenant overrides the configuration for requests to particular hosts so
+// that a single proxy can front many applications with different identity
+// provider clients.
+// Any options that are not set for the tenant are inherited from the main
+// configuration.
+type Tenant struct {
+	// ID should be a unique identifier for the tenant.
+	// This value is required for all tenants.
+	ID string `json:"id,omitempty"`
+
+	// Hosts are the hosts served by the tenant, which are matched against the
+	// Host header (or X-Forwarded-Host when the reverse proxy option is set).
+	// A leading `*.` matches any subdomain, eg. `*.example.com`.
+	// At least one host is required for each tenant.
+	Hosts []string `json:"hosts,omitempty"`
+
+	// Providers replaces the providers of the main configuration for the
+	// tenant. This includes the client credentials and the groups that are
+	// allowed to access the tenant.
+	Providers Providers `json:"providers,omitempty"`
+
+	// Cookie overrides the session cookie name and domains for the tenant.
+	Cookie *TenantCookie `json:"cookie,omitempty"`
+
+	// RedirectURL overrides the OAuth redirect URL for the tenant.
+	// This must be set when an absolute redirect URL is configured for the
+	// main configuration.
+	RedirectURL string `json:"redirectURL,omitempty"`
+
+	// UpstreamConfig replaces the upstreams of the main configuration for
+	// the tenant.
+	UpstreamConfig *UpstreamConfig `json:"upstreamConfig,omitempty"`
+}
+
+// TenantCookie overrides the session cookie for a tenant.
+type TenantCookie struct {
+	// Name is the name of the session cookie.
+	Name string `json:"name,omitempty"`
+
+	// Domains are the domains of the session cookie.
+	Domains []string `json:"domains,omitempty"`
+}
+
+// ForTenant returns a copy of the options with the overrides of the tenant
+// applied.
+// The values set during validation are not copied as they must be set for
+// the tenant by validating the returned options.
+func (o *Options) ForTenant(tenant Tenant) *Options {
+	opts := *o
+	opts.Tenants = nil
+	opts.redirectURL = nil
+	opts.signatureData = nil
+	opts.oidcVerifier = nil
+	opts.jwtBearerVerifiers = nil
+	opts.realClientIPParser = nil
+	opts.tenantOptions = nil
+
+	if len(tenant.Providers) > 0 {
+		opts.Providers = tenant.Providers
+	}
+	if tenant.Cookie != nil {
+		if tenant.Cookie.Name != "" {
+			opts.Cookie.Name = tenant.Cookie.Name
+		}
+		if len(tenant.Cookie.Domains) > 0 {
+			opts.Cookie.Domains = tenant.Cookie.Domains
+		}
+	}
+	if tenant.RedirectURL != "" {
+		opts.RawRedirectURL = tenant.RedirectURL
+	}
+	if tenant.UpstreamConfig != nil {
+		opts.UpstreamServers = *tenant.UpstreamConfig
+	}
+	return &opts
+}
@@ -1,6 +1,9 @@
 package options
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	// DefaultUpstreamFlushInterval is the default value for the Upstream FlushInterval.
@@ -8,6 +11,56 @@ const (
 
 	// DefaultUpstreamTimeout is the maximum duration a network dial to a upstream server for a response.
 	DefaultUpstreamTimeout = 30 * time.Second
+
+	// DefaultUpstreamConnectTimeout is the default value for the Upstream ConnectTimeout.
+	DefaultUpstreamConnectTimeout = 30 * time.Second
+
+	// DefaultRetryBudgetPercent is the default value for the UpstreamRetries BudgetPercent.
+	DefaultRetryBudgetPercent = 20
+
+	// DefaultOutlierConsecutiveFailures is the default value for the
+	// OutlierDetection ConsecutiveFailures.
+	DefaultOutlierConsecutiveFailures = 5
+
+	// DefaultOutlierEjectionDuration is the default value for the
+	// OutlierDetection EjectionDuration.
+	DefaultOutlierEjectionDuration = 30 * time.Second
+
+	// DefaultDiscoveryRefreshInterval is the default value for the
+	// UpstreamDiscovery RefreshInterval.
+	DefaultDiscoveryRefreshInterval = 30 * time.Second
+
+	// DefaultCompressionMinSize is the default value for the Compression MinSize.
+	DefaultCompressionMinSize = 1024
+
+	// DefaultCacheMaxEntries is the default value for the ResponseCache MaxEntries.
+	DefaultCacheMaxEntries = 1000
+
+	// DefaultCacheMaxEntrySize is the default value for the ResponseCache MaxEntrySize.
+	DefaultCacheMaxEntrySize = 1 << 20
+
+	// DefaultShadowPercentage is the default value for the TrafficShadow Percentage.
+	DefaultShadowPercentage = 100
+
+	// DefaultFastCGIIndex is the default value for the FastCGI Index.
+	DefaultFastCGIIndex = "index.php"
+
+	// DefaultFastCGISplitPath is the default value for the FastCGI SplitPath.
+	DefaultFastCGISplitPath = ".php"
+
+	// DefaultHealthCheckInterval is the default value for the HealthCheck Interval.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultHealthCheckTimeout is the default value for the HealthCheck Timeout.
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	// DefaultHealthCheckHealthyThreshold is the default value for the
+	// HealthCheck HealthyThreshold.
+	DefaultHealthCheckHealthyThreshold = 2
+
+	// DefaultHealthCheckUnhealthyThreshold is the default value for the
+	// HealthCheck UnhealthyThreshold.
+	DefaultHealthCheckUnhealthyThreshold = 3
 )
 
 // UpstreamConfig is a collection of definitions for upstream servers.
@@ -19,6 +72,12 @@ type UpstreamConfig struct {
 	// Upstreams represents the configuration for the upstream servers.
 	// Requests will be proxied to this upstream if the path matches the request path.
 	Upstreams []Upstream `json:"upstreams,omitempty"`
+
+	// DynamicUpstreamsFile is the path to a YAML file with an `upstreams` list
+	// of additional upstreams. The file is watched for changes so that
+	// upstreams can be added, updated and removed without a restart.
+	// The IDs and paths of the dynamic upstreams must not clash with Upstreams.
+	DynamicUpstreamsFile string `json:"dynamicUpstreamsFile,omitempty"`
 }
 
 // Upstream represents the configuration for an upstream server.
@@ -44,26 +103,83 @@ type Upstream struct {
 	// Eg: With a Path of `^/baz/(.*)`, a RewriteTarget of `/foo/$1` would rewrite
 	// the request `/baz/abc/123` to `/foo/abc/123` before proxying to the
 	// upstream server.
+	// Named capture groups may be referenced by name.
+	// Eg: With a Path of `^/users/(?P<user>[^/]+)/(?P<rest>.*)`, a RewriteTarget
+	// of `/${rest}?user=${user}` would rewrite the request `/users/john/profile`
+	// to `/profile?user=john`.
 	RewriteTarget string `json:"rewriteTarget,omitempty"`
 
-	// The URI of the upstream server. This may be an HTTP(S) server of a File
-	// based URL. It may include a path, in which case all requests will be served
-	// under that path.
+	// RewriteRules are conditional rewrites that are applied when the request
+	// headers match.
+	// The first rule whose headers all match the request is used in place of
+	// the RewriteTarget. When no rule matches, the RewriteTarget is used, or
+	// the path is left unchanged if no RewriteTarget is set.
+	// The Path must be a pattern as with the RewriteTarget.
+	RewriteRules []RewriteRule `json:"rewriteRules,omitempty"`
+
+	// AllowedMethods restricts the HTTP methods that may be used for requests
+	// to the upstream.
+	// Authenticated requests using any other method receive a 405 response.
+	// Eg: `["GET", "HEAD"]` to only allow read-only access to the upstream.
+	// Defaults to allowing all methods.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// RequestHeaders modifies the request headers sent to this upstream,
+	// after any injected request headers have been added.
+	RequestHeaders *RequestHeaderPolicy `json:"requestHeaders,omitempty"`
+
+	// ResponseHeaders modifies the response headers returned from this
+	// upstream, so that security headers can be enforced for upstreams that
+	// do not set them.
+	ResponseHeaders *ResponseHeaderPolicy `json:"responseHeaders,omitempty"`
+
+	// The URI of the upstream server. This may be an HTTP(S) server, a File
+	// based URL, a unix domain socket serving HTTP or a FastCGI server
+	// listening on TCP or a unix domain socket. HTTP(S) and File URIs
+	// may include a path, in which case all requests will be served under
+	// that path.
 	// Eg:
 	// - http://localhost:8080
 	// - https://service.localhost
 	// - https://service.localhost/path
 	// - file://host/path
+	// - unix:///path/to.sock
+	// - fcgi://localhost:9000
+	// - fcgi:///run/php-fpm.sock
 	// If the URI's path is "/base" and the incoming request was for "/dir",
 	// the upstream request will be for "/base/dir".
 	URI string `json:"uri,omitempty"`
 
+	// URIs is a list of URIs for replicated HTTP(S) upstream servers.
+	// Requests are load balanced across the URIs according to LoadBalancing.
+	// This may be used instead of URI.
+	URIs []string `json:"uris,omitempty"`
+
+	// LoadBalancing configures how requests are distributed across the URIs.
+	LoadBalancing *LoadBalancing `json:"loadBalancing,omitempty"`
+
+	// Discovery configures dynamic discovery of the upstream servers.
+	// Discovered servers are load balanced according to LoadBalancing and
+	// are added to any URIs.
+	Discovery *UpstreamDiscovery `json:"discovery,omitempty"`
+
 	// InsecureSkipTLSVerify will skip TLS verification of upstream HTTPS hosts.
 	// This option is insecure and will allow potential Man-In-The-Middle attacks
 	// betweem OAuth2 Proxy and the usptream server.
 	// Defaults to false.
 	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
 
+	// CAFiles is a list of paths to CA certificates that should be used when
+	// verifying the certificates presented by HTTPS upstream servers.
+	// When not set, the system certificate pool is used.
+	CAFiles []string `json:"caFiles,omitempty"`
+
+	// SPIFFE enables mutual TLS with the upstream server using the X.509 SVID
+	// of the proxy from the SPIFFE Workload API.
+	// The upstream server must present an SVID with one of the authorized
+	// SPIFFE IDs in place of a certificate for its host name.
+	SPIFFE *SPIFFE `json:"spiffe,omitempty"`
+
 	// Static will make all requests to this upstream have a static response.
 	// The response will have a body of "Authenticated" and a response code
 	// matching StaticCode.
@@ -74,6 +190,13 @@ type Upstream struct {
 	// This option can only be used with Static enabled.
 	StaticCode *int `json:"staticCode,omitempty"`
 
+	// FileServer configures how files are served for File based URIs.
+	FileServer *FileServer `json:"fileServer,omitempty"`
+
+	// StaticResponse configures a templated body for the Static response.
+	// This option can only be used with Static enabled.
+	StaticResponse *StaticResponse `json:"staticResponse,omitempty"`
+
 	// FlushInterval is the period between flushing the response buffer when
 	// streaming response from the upstream.
 	// Defaults to 1 second.
@@ -88,7 +211,484 @@ type Upstream struct {
 	// Defaults to true.
 	ProxyWebSockets *bool `json:"proxyWebSockets,omitempty"`
 
+	// WebSocketAllowedOrigins restricts the Origin of WebSocket upgrade
+	// requests, to protect against cross-site WebSocket hijacking.
+	// Origins may contain wildcards, eg `https://*.example.com`.
+	// Upgrade requests from other origins receive a 403 response.
+	// Defaults to allowing all origins.
+	WebSocketAllowedOrigins []string `json:"webSocketAllowedOrigins,omitempty"`
+
+	// HTTP2 enables HTTP/2 for all requests to the upstream server.
+	// HTTP upstreams are connected to using cleartext HTTP/2 (h2c) with prior
+	// knowledge and HTTPS upstreams must support HTTP/2.
+	// When disabled, HTTP/2 is only used with HTTPS upstreams that negotiate it.
+	// WebSocket connections always use HTTP/1.1.
+	// Defaults to false.
+	HTTP2 bool `json:"http2,omitempty"`
+
 	// Timeout is the maximum duration the server will wait for a response from the upstream server.
 	// Defaults to 30 seconds.
 	Timeout *Duration `json:"timeout,omitempty"`
+
+	// ConnectTimeout is the maximum duration the server will wait for a
+	// connection to the upstream server to be established.
+	// Defaults to 30 seconds.
+	ConnectTimeout *Duration `json:"connectTimeout,omitempty"`
+
+	// Retries configures retrying of idempotent requests that fail to reach
+	// the upstream server.
+	// Requests are not retried unless this is set.
+	Retries *UpstreamRetries `json:"retries,omitempty"`
+
+	// OutlierDetection configures ejection of upstream hosts that
+	// repeatedly fail.
+	// While a host is ejected, requests to it fail immediately rather than
+	// waiting for the upstream to time out.
+	OutlierDetection *OutlierDetection `json:"outlierDetection,omitempty"`
+
+	// HealthCheck configures active health checking of the upstream servers.
+	// Unhealthy servers do not receive requests and cause the ready endpoint
+	// to fail when no healthy servers remain.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+
+	// Compression configures brotli or gzip compression of responses from
+	// the upstream for clients that accept it.
+	// Responses are not compressed unless this is set.
+	Compression *Compression `json:"compression,omitempty"`
+
+	// Cache configures caching of GET responses from the upstream.
+	// Responses are only cached when they allow it with a max-age or
+	// s-maxage Cache-Control directive.
+	// Responses are not cached unless this is set.
+	Cache *ResponseCache `json:"cache,omitempty"`
+
+	// Session overrides how often sessions are refreshed and validated for
+	// requests to the upstream.
+	// When not set, the cookie refresh and session validation intervals are
+	// used.
+	Session *UpstreamSession `json:"session,omitempty"`
+
+	// Shadow configures mirroring of requests to a secondary upstream.
+	// Mirrored requests are sent in the background and their responses are
+	// discarded, so they never affect the response to the client.
+	Shadow *TrafficShadow `json:"shadow,omitempty"`
+
+	// FastCGI configures how requests are mapped to FastCGI parameters for
+	// fcgi:// URIs.
+	FastCGI *FastCGI `json:"fastCGI,omitempty"`
+
+	// CORS configures Cross-Origin Resource Sharing for requests to the
+	// upstream.
+	// Preflight requests are answered before authentication, and CORS
+	// headers are added to all responses, including authentication errors,
+	// replacing any CORS headers set by the upstream.
+	CORS *CORS `json:"cors,omitempty"`
+
+	// Streaming configures the upstream for long lived streaming responses
+	// such as Server-Sent Events or long-polling endpoints.
+	// When set, responses are flushed to the client as soon as they are
+	// received from the upstream, ignoring the FlushInterval.
+	Streaming *UpstreamStreaming `json:"streaming,omitempty"`
+
+	// KubernetesImpersonation configures the upstream as a Kubernetes API server.
+	// When set, the authenticated user is passed to the API server using
+	// impersonation headers and the request is authenticated using the
+	// configured service account token.
+	KubernetesImpersonation *KubernetesImpersonation `json:"kubernetesImpersonation,omitempty"`
+
+	// JWTAssertionAudience is the audience (aud) of the JWT assertions of the
+	// identity of users sent to the upstream, when a JWT assertion key file is
+	// configured.
+	// Defaults to the ID of the upstream.
+	JWTAssertionAudience string `json:"jwtAssertionAudience,omitempty"`
+}
+
+// FileServer configures how a File based upstream serves directories.
+type FileServer struct {
+	// DirectoryListing enables listing the contents of directories that do
+	// not contain an index file.
+	// When disabled, requests for these directories receive a 403 response.
+	// Defaults to true.
+	DirectoryListing *bool `json:"directoryListing,omitempty"`
+
+	// IndexFiles are the files served for requests to a directory, in order
+	// of preference.
+	// Defaults to ["index.html"].
+	IndexFiles []string `json:"indexFiles,omitempty"`
+}
+
+// StaticResponse configures the body of a static response.
+type StaticResponse struct {
+	// Template is a Go template used to render the response body.
+	// The template is given the authenticated `.Session` (with `User`,
+	// `Email`, `Groups` and `PreferredUsername`, or nil when there is no
+	// session) and the `.Request` (with `Method`, `Host`, `Path`, `Query` and
+	// `Header`).
+	// HTML content types are rendered using html/template so that values are
+	// escaped.
+	// Eg: `Hello {{ .Session.User }}`
+	Template string `json:"template,omitempty"`
+
+	// ContentType is the Content-Type of the response.
+	// Defaults to "text/plain; charset=utf-8".
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// RewriteRule is a rewrite target that is used when the request headers match.
+type RewriteRule struct {
+	// Target is the rewrite target used when the rule matches.
+	// Capture groups from the upstream Path may be referenced as with the
+	// RewriteTarget. Named capture groups from the header values may be
+	// referenced using `${name}`. Header values whose named capture groups
+	// contain `/`, `?` or `#`, or are `.` or `..`, do not match.
+	Target string `json:"target,omitempty"`
+
+	// Headers are the conditions that must all match for the rule to be used.
+	Headers []RewriteHeaderMatch `json:"headers,omitempty"`
+}
+
+// RewriteHeaderMatch matches the value of a request header.
+type RewriteHeaderMatch struct {
+	// Name is the name of the request header to match.
+	Name string `json:"name,omitempty"`
+
+	// Value is a regular expression that the header value must match.
+	// When the header has multiple values, any value may match.
+	// When empty, the header only needs to be present.
+	// Eg: `^(?P<version>v[0-9]+)$` would match a header value of `v2` and
+	// capture `v2` for use as `${version}` in the rule Target.
+	Value string `json:"value,omitempty"`
+}
+
+// UpstreamDiscovery configures how upstream servers are discovered.
+// Exactly one of DNSSRV or Kubernetes must be set.
+type UpstreamDiscovery struct {
+	// DNSSRV is the name of a DNS SRV record listing the upstream servers.
+	// Eg: `_http._tcp.app.default.svc.cluster.local`
+	DNSSRV string `json:"dnsSRV,omitempty"`
+
+	// Kubernetes discovers the upstream servers from the EndpointSlices of a
+	// Kubernetes Service.
+	// OAuth2 Proxy must be running within the cluster with permission to list
+	// EndpointSlices in the Service namespace.
+	Kubernetes *KubernetesDiscovery `json:"kubernetes,omitempty"`
+
+	// Scheme is the scheme used to connect to the discovered servers.
+	// Valid options are "http" and "https".
+	// Defaults to "http".
+	Scheme string `json:"scheme,omitempty"`
+
+	// RefreshInterval is the period between refreshes of the discovered
+	// servers.
+	// Defaults to 30 seconds.
+	RefreshInterval *Duration `json:"refreshInterval,omitempty"`
+}
+
+// KubernetesDiscovery identifies the Kubernetes Service whose endpoints are
+// used as upstream servers.
+type KubernetesDiscovery struct {
+	// Service is the name of the Kubernetes Service.
+	Service string `json:"service,omitempty"`
+
+	// Namespace is the namespace of the Kubernetes Service.
+	// Defaults to the namespace OAuth2 Proxy is running in.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port is the name of the Service port to connect to.
+	// Defaults to the first port of the endpoints.
+	Port string `json:"port,omitempty"`
+}
+
+// LoadBalancing configures load balancing across multiple upstream URIs.
+type LoadBalancing struct {
+	// Policy is the algorithm used to choose the URI for each request.
+	// Valid options are "round-robin" and "least-connections".
+	// Defaults to "round-robin".
+	Policy LoadBalancingPolicy `json:"policy,omitempty"`
+
+	// StickySessions routes all requests from an authenticated user to the
+	// same URI while it is available.
+	// Unauthenticated requests are balanced according to the Policy.
+	StickySessions bool `json:"stickySessions,omitempty"`
+}
+
+// LoadBalancingPolicy is used to enumerate the different load balancing
+// algorithms.
+// Valid options are: round-robin and least-connections.
+type LoadBalancingPolicy string
+
+const (
+	// RoundRobinPolicy sends requests to each URI in turn.
+	RoundRobinPolicy LoadBalancingPolicy = "round-robin"
+
+	// LeastConnectionsPolicy sends requests to the URI with the fewest
+	// requests in flight.
+	LeastConnectionsPolicy LoadBalancingPolicy = "least-connections"
+)
+
+// UpstreamRetries configures retries for requests to an upstream.
+// Only idempotent requests without a body (GET, HEAD, OPTIONS, TRACE and
+// DELETE) are retried.
+// Requests are retried when the upstream could not be reached or responds
+// with a 502, 503 or 504.
+type UpstreamRetries struct {
+	// Attempts is the maximum number of times a request will be retried.
+	Attempts int `json:"attempts,omitempty"`
+
+	// BudgetPercent limits the number of retries to a percentage of the
+	// requests made to the upstream, preventing retries from overloading
+	// an upstream that is already failing.
+	// Defaults to 20.
+	BudgetPercent int `json:"budgetPercent,omitempty"`
+}
+
+// OutlierDetection configures ejection of failing upstream hosts.
+type OutlierDetection struct {
+	// ConsecutiveFailures is the number of consecutive failures after which a
+	// host is ejected.
+	// Failures are connection errors and 502, 503 or 504 responses.
+	// Defaults to 5.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+
+	// EjectionDuration is the period for which a host is ejected.
+	// Defaults to 30 seconds.
+	EjectionDuration *Duration `json:"ejectionDuration,omitempty"`
+}
+
+// HealthCheck configures an active health check probe for an upstream.
+// A server is healthy when the probe returns a 2xx or 3xx response.
+type HealthCheck struct {
+	// Path is the path requested on each upstream server to check its health.
+	// This value is required.
+	Path string `json:"path,omitempty"`
+
+	// Interval is the period between health checks.
+	// Defaults to 10 seconds.
+	Interval *Duration `json:"interval,omitempty"`
+
+	// Timeout is the maximum duration to wait for a health check response.
+	// Defaults to 5 seconds.
+	Timeout *Duration `json:"timeout,omitempty"`
+
+	// HealthyThreshold is the number of consecutive successful checks required
+	// before an unhealthy server is considered healthy.
+	// Defaults to 2.
+	HealthyThreshold int `json:"healthyThreshold,omitempty"`
+
+	// UnhealthyThreshold is the number of consecutive failed checks required
+	// before a healthy server is considered unhealthy.
+	// Defaults to 3.
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+}
+
+// Compression configures compression of upstream responses.
+// Responses that are already encoded, event streams, gRPC responses and
+// responses to HEAD or range requests are never compressed.
+// Responses are encoded with brotli (br) or gzip, whichever the client
+// accepts with the higher quality value, preferring brotli.
+type Compression struct {
+	// ContentTypes are the media types of responses that are compressed.
+	// Defaults to common text based types such as HTML, CSS, JavaScript,
+	// JSON, XML and SVG.
+	ContentTypes []string `json:"contentTypes,omitempty"`
+
+	// MinSize is the minimum Content-Length in bytes of responses that are
+	// compressed. Responses with an unknown length are always compressed.
+	// Defaults to 1024.
+	MinSize int `json:"minSize,omitempty"`
+}
+
+// ResponseCache configures caching of upstream responses.
+// Responses that set cookies, vary on all headers, or have a no-store,
+// no-cache or private Cache-Control directive are never cached, except that
+// private responses may be cached per user.
+type ResponseCache struct {
+	// Shared determines whether cached responses are shared between all
+	// authenticated users.
+	// When false, responses are cached separately for each user and private
+	// responses may be cached.
+	// Defaults to false.
+	Shared bool `json:"shared,omitempty"`
+
+	// Directory is the directory in which cached response bodies are stored.
+	// Any existing cached responses in the directory are removed on startup.
+	// When not set, response bodies are stored in memory.
+	Directory string `json:"directory,omitempty"`
+
+	// MaxEntries is the maximum number of responses that are cached.
+	// The least recently used response is evicted when the cache is full.
+	// Defaults to 1000.
+	MaxEntries int `json:"maxEntries,omitempty"`
+
+	// MaxEntrySize is the maximum size in bytes of a response body that is
+	// cached.
+	// Defaults to 1048576 (1MiB).
+	MaxEntrySize int64 `json:"maxEntrySize,omitempty"`
+}
+
+// UpstreamSession overrides the session refresh and validation intervals for
+// requests to an upstream.
+// Latency sensitive routes can disable inline refreshing by setting the
+// intervals to zero, while interactive routes may use shorter intervals.
+type UpstreamSession struct {
+	// RefreshPeriod is the age after which sessions are refreshed with the
+	// provider when they are used for the upstream.
+	// A zero duration disables refreshing for the upstream.
+	RefreshPeriod *Duration `json:"refreshPeriod,omitempty"`
+
+	// ValidationInterval is the age after which sessions that are not
+	// refreshed are validated with the provider when they are used for the
+	// upstream. Each session is validated at most once per interval.
+	// A zero duration disables validation for the upstream.
+	ValidationInterval *Duration `json:"validationInterval,omitempty"`
+}
+
+// TrafficShadow configures mirroring of requests to a shadow upstream.
+// Mirrored requests include the identity headers of the original request.
+// Requests with bodies larger than 1MiB are not mirrored.
+type TrafficShadow struct {
+	// URI is the HTTP(S) URI of the shadow upstream.
+	// The request path is appended to the URI's host.
+	// The CAFiles and InsecureSkipTLSVerify options of the upstream also
+	// apply to the shadow upstream.
+	URI string `json:"uri,omitempty"`
+
+	// Percentage is the percentage of requests that are mirrored.
+	// Must be between 0 and 100.
+	// Defaults to 100.
+	Percentage *int `json:"percentage,omitempty"`
+
+	// Timeout is the maximum duration to wait for the shadow upstream to
+	// respond to a mirrored request.
+	// Defaults to 30 seconds.
+	Timeout *Duration `json:"timeout,omitempty"`
+}
+
+// FastCGI configures requests to a FastCGI upstream.
+// The standard CGI parameters, including an HTTP_ parameter for each
+// request header, are sent with every request.
+type FastCGI struct {
+	// Root is the document root on the FastCGI server.
+	// SCRIPT_FILENAME is the root joined with the script name.
+	// This option is required.
+	Root string `json:"root,omitempty"`
+
+	// Index is the script used for requests to directories.
+	// Defaults to "index.php".
+	Index string `json:"index,omitempty"`
+
+	// SplitPath is the suffix that ends the script name within the request
+	// path. The remainder of the path is passed as PATH_INFO.
+	// Eg: With a SplitPath of ".php", the request `/index.php/users` has a
+	// SCRIPT_NAME of `/index.php` and a PATH_INFO of `/users`.
+	// Defaults to ".php".
+	SplitPath string `json:"splitPath,omitempty"`
+
+	// Params are additional parameters sent with each request.
+	// These override the standard CGI parameters of the same name.
+	Params []FastCGIParam `json:"params,omitempty"`
+}
+
+// FastCGIParam is a parameter sent to a FastCGI upstream.
+type FastCGIParam struct {
+	// Name is the name of the parameter.
+	Name string `json:"name,omitempty"`
+
+	// Value is the value of the parameter.
+	Value string `json:"value,omitempty"`
+}
+
+// CORS configures Cross-Origin Resource Sharing for an upstream.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// Origins may contain wildcards, eg `https://*.example.com`, or may be
+	// `*` to allow any origin.
+	// This option is required.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests.
+	// Defaults to allowing the headers requested in the preflight request.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+
+	// ExposedHeaders are the response headers that cross-origin requests
+	// may read.
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+
+	// AllowCredentials allows cross-origin requests to include credentials
+	// such as the session cookie.
+	// This cannot be used with an AllowedOrigin of `*`.
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+
+	// MaxAge is how long browsers may cache the result of a preflight
+	// request.
+	// Defaults to not being set, leaving the browser default.
+	MaxAge *Duration `json:"maxAge,omitempty"`
+}
+
+// UpstreamStreaming configures how streaming responses are proxied.
+type UpstreamStreaming struct {
+	// Timeout is the maximum duration the server will wait for the response
+	// headers from the upstream server.
+	// This replaces the upstream Timeout for streaming requests.
+	// Defaults to no timeout.
+	Timeout *Duration `json:"timeout,omitempty"`
+
+	// IdleTimeout is the maximum duration to wait for data from the upstream
+	// server before the stream is closed.
+	// Defaults to no timeout.
+	IdleTimeout *Duration `json:"idleTimeout,omitempty"`
+}
+
+// KubernetesImpersonation configures how an authenticated session is mapped
+// to Kubernetes impersonation headers.
+// The service account used must be granted the `impersonate` verb on the
+// users and groups it should be able to impersonate.
+type KubernetesImpersonation struct {
+	// Token is the bearer token used to authenticate to the API server.
+	// When read from a file, the file is re-read whenever it changes so that
+	// projected service account tokens may be rotated.
+	Token *SecretSource `json:"token,omitempty"`
+
+	// UserClaim is the session claim used to populate the Impersonate-User header.
+	// Defaults to "user".
+	UserClaim string `json:"userClaim,omitempty"`
+
+	// UserPrefix is prepended to the user before it is sent to the API server.
+	// Eg. "oidc:"
+	UserPrefix string `json:"userPrefix,omitempty"`
+
+	// GroupsClaim is the session claim used to populate the Impersonate-Group headers.
+	// Defaults to "groups".
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+
+	// GroupPrefix is prepended to each group before it is sent to the API server.
+	GroupPrefix string `json:"groupPrefix,omitempty"`
+}
+
+// SPIFFE configures workload identity from the SPIFFE Workload API.
+type SPIFFE struct {
+	// EndpointSocket is the address of the Workload API, eg.
+	// `unix:///run/spire/sockets/agent.sock`.
+	// Defaults to the SPIFFE_ENDPOINT_SOCKET environment variable.
+	EndpointSocket string `json:"endpointSocket,omitempty"`
+
+	// AuthorizedIDs are the SPIFFE IDs the upstream server may present.
+	// When empty, any SPIFFE ID in the trust domain of the proxy is accepted.
+	AuthorizedIDs []string `json:"authorizedIDs,omitempty"`
+}
+
+// LoadDynamicUpstreams loads the upstreams from a DynamicUpstreamsFile.
+func LoadDynamicUpstreams(path string) ([]Upstream, error) {
+	dynamic := &struct {
+		Upstreams []Upstream `json:"upstreams,omitempty"`
+	}{}
+	if err := LoadYAML(path, dynamic); err != nil {
+		return nil, fmt.Errorf("could not load dynamic upstreams: %v", err)
+	}
+	return dynamic.Upstreams, nil
 }
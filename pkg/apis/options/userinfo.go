@@ -0,0 +1,32 @@
+package options
+
+// UserInfo shapes the JSON response of the `/oauth2/userinfo` endpoint, so
+// that frontends get a stable set of identity fields.
+// Without it, the response has the `user`, `email`, `groups` and
+// `preferredUsername` of the session.
+type UserInfo struct {
+	// Fields are the fields of the response, which replace the default
+	// fields. Fields whose claim is missing or empty are omitted.
+	Fields []UserInfoField `json:"fields,omitempty"`
+
+	// Groups adds the groups of the user to the response as the `groups`
+	// field, which is an empty list when the user has no groups.
+	Groups bool `json:"groups,omitempty"`
+}
+
+// UserInfoField is a field of the response of the `/oauth2/userinfo`
+// endpoint.
+type UserInfoField struct {
+	// Name is the name of the field in the response.
+	// Dots nest the field in objects, eg `profile.name` for
+	// `{"profile": {"name": ...}}`.
+	Name string `json:"name,omitempty"`
+
+	// Claim is the claim the value of the field is loaded from.
+	// The `user`, `email`, `groups` and `preferred_username` claims are
+	// loaded from the session, and any other claim from the ID token, with
+	// its JSON type. Dots select nested claims of the ID token, eg
+	// `address.country`.
+	// Defaults to the name of the field.
+	Claim string `json:"claim,omitempty"`
+}
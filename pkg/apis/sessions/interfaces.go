@@ -14,6 +14,34 @@ type SessionStore interface {
 	Clear(rw http.ResponseWriter, req *http.Request) error
 }
 
+// UserSessionStore is a SessionStore that indexes the sessions of each user,
+// so that all of them can be cleared at once
+type UserSessionStore interface {
+	SessionStore
+	// ClearUserSessions clears all of the sessions of the user of the
+	// session, on every device, and returns the sessions it cleared.
+	ClearUserSessions(ctx context.Context, s *SessionState) ([]*SessionState, error)
+}
+
+// ReplayCache records values that may only be used once, such as the
+// authorization codes and OIDC nonces of the OAuth2 callback
+type ReplayCache interface {
+	// Consume records the key as used until the expiration. It returns false
+	// when the key was already used.
+	Consume(ctx context.Context, key string, expiration time.Duration) (bool, error)
+}
+
+// ConsentStore remembers the version of the consent terms each user accepted,
+// so that it outlives their sessions
+type ConsentStore interface {
+	// AcceptedVersion returns the version of the terms the user accepted, or
+	// an empty string when they have not accepted any.
+	AcceptedVersion(ctx context.Context, user string) (string, error)
+
+	// Accept records that the user accepted the version of the terms.
+	Accept(ctx context.Context, user string, version string) error
+}
+
 var ErrLockNotObtained = errors.New("lock: not obtained")
 var ErrNotLocked = errors.New("tried to release not existing lock")
 
@@ -3,9 +3,12 @@ package sessions
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
@@ -19,9 +22,11 @@ type SessionState struct {
 	CreatedAt *time.Time `msgpack:"ca,omitempty"`
 	ExpiresOn *time.Time `msgpack:"eo,omitempty"`
 
-	AccessToken  string `msgpack:"at,omitempty"`
-	IDToken      string `msgpack:"it,omitempty"`
-	RefreshToken string `msgpack:"rt,omitempty"`
+	// The tokens are held in byte slices rather than strings, so that they
+	// can be wiped from memory with Wipe once the session is no longer used
+	AccessToken  []byte `msgpack:"at,omitempty"`
+	IDToken      []byte `msgpack:"it,omitempty"`
+	RefreshToken []byte `msgpack:"rt,omitempty"`
 
 	Nonce []byte `msgpack:"n,omitempty"`
 
@@ -30,6 +35,13 @@ type SessionState struct {
 	Groups            []string `msgpack:"g,omitempty"`
 	PreferredUsername string   `msgpack:"pu,omitempty"`
 
+	// BrowserSession is whether the session cookie expires when the browser
+	// is closed, as the user did not choose to stay signed in
+	BrowserSession bool `msgpack:"bs,omitempty"`
+
+	// ConsentVersion is the version of the consent terms the user accepted
+	ConsentVersion string `msgpack:"cv,omitempty"`
+
 	// Internal helpers, not serialized
 	Clock clock.Clock `msgpack:"-"`
 	Lock  Lock        `msgpack:"-"`
@@ -103,10 +115,10 @@ func (s *SessionState) Age() time.Duration {
 // String constructs a summary of the session state
 func (s *SessionState) String() string {
 	o := fmt.Sprintf("Session{email:%s user:%s PreferredUsername:%s", s.Email, s.User, s.PreferredUsername)
-	if s.AccessToken != "" {
+	if len(s.AccessToken) > 0 {
 		o += " token:true"
 	}
-	if s.IDToken != "" {
+	if len(s.IDToken) > 0 {
 		o += " id_token:true"
 	}
 	if s.CreatedAt != nil && !s.CreatedAt.IsZero() {
@@ -115,7 +127,7 @@ func (s *SessionState) String() string {
 	if s.ExpiresOn != nil && !s.ExpiresOn.IsZero() {
 		o += fmt.Sprintf(" expires:%s", s.ExpiresOn)
 	}
-	if s.RefreshToken != "" {
+	if len(s.RefreshToken) > 0 {
 		o += " refresh_token:true"
 	}
 	if len(s.Groups) > 0 {
@@ -130,15 +142,15 @@ func (s *SessionState) GetClaim(claim string) []string {
 	}
 	switch claim {
 	case "access_token":
-		return []string{s.AccessToken}
+		return []string{string(s.AccessToken)}
 	case "id_token":
-		return []string{s.IDToken}
+		return []string{string(s.IDToken)}
 	case "created_at":
 		return []string{s.CreatedAt.String()}
 	case "expires_on":
 		return []string{s.ExpiresOn.String()}
 	case "refresh_token":
-		return []string{s.RefreshToken}
+		return []string{string(s.RefreshToken)}
 	case "email":
 		return []string{s.Email}
 	case "user":
@@ -154,17 +166,64 @@ func (s *SessionState) GetClaim(claim string) []string {
 	}
 }
 
+// AuthenticationContext returns the authentication context class reference
+// (`acr`) and authentication methods references (`amr`) that the IdP reported
+// in the ID token, if it has them.
+// The ID token is not verified again, as it was verified when it was redeemed.
+func (s *SessionState) AuthenticationContext() (acr string, amr []string) {
+	if s == nil || len(s.IDToken) == 0 {
+		return "", nil
+	}
+	parts := strings.Split(string(s.IDToken), ".")
+	if len(parts) < 2 {
+		return "", nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil
+	}
+
+	var claims struct {
+		ACR string          `json:"acr"`
+		AMR json.RawMessage `json:"amr"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", nil
+	}
+	// Some IdPs report a single method as a string rather than an array
+	if err := json.Unmarshal(claims.AMR, &amr); err != nil {
+		var method string
+		if json.Unmarshal(claims.AMR, &method) == nil && method != "" {
+			amr = []string{method}
+		}
+	}
+	return claims.ACR, amr
+}
+
 // CheckNonce compares the Nonce against a potential hash of it
 func (s *SessionState) CheckNonce(hashed string) bool {
 	return encryption.CheckNonce(s.Nonce, hashed)
 }
 
+// Wipe zeroes the tokens of the session once it is no longer used, so that
+// they do not remain in memory until they are garbage collected
+func (s *SessionState) Wipe() {
+	if s == nil {
+		return
+	}
+	encryption.Wipe(s.AccessToken)
+	encryption.Wipe(s.IDToken)
+	encryption.Wipe(s.RefreshToken)
+}
+
 // EncodeSessionState returns an encrypted, lz4 compressed, MessagePack encoded session
 func (s *SessionState) EncodeSessionState(c encryption.Cipher, compress bool) ([]byte, error) {
 	packed, err := msgpack.Marshal(s)
 	if err != nil {
 		return nil, fmt.Errorf("error marshalling session state to msgpack: %w", err)
 	}
+	// The plaintext holds the tokens of the session
+	defer encryption.Wipe(packed)
 
 	if !compress {
 		return c.Encrypt(packed)
@@ -174,6 +233,7 @@ func (s *SessionState) EncodeSessionState(c encryption.Cipher, compress bool) ([
 	if err != nil {
 		return nil, err
 	}
+	defer encryption.Wipe(compressed)
 	return c.Encrypt(compressed)
 }
 
@@ -183,6 +243,8 @@ func DecodeSessionState(data []byte, c encryption.Cipher, compressed bool) (*Ses
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting the session state: %w", err)
 	}
+	// The tokens are copied out of the plaintext when it is unmarshalled
+	defer encryption.Wipe(decrypted)
 
 	packed := decrypted
 	if compressed {
@@ -190,6 +252,7 @@ func DecodeSessionState(data []byte, c encryption.Cipher, compressed bool) (*Ses
 		if err != nil {
 			return nil, err
 		}
+		defer encryption.Wipe(packed)
 	}
 
 	var ss SessionState
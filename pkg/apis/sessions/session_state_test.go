@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
 )
 
 func timePtr(t time.Time) *time.Time {
@@ -69,9 +71,9 @@ func TestString(t *testing.T) {
 				PreferredUsername: "preferred.user",
 				CreatedAt:         &created,
 				ExpiresOn:         &expires,
-				AccessToken:       "access.token",
-				IDToken:           "id.token",
-				RefreshToken:      "refresh.token",
+				AccessToken:       []byte("access.token"),
+				IDToken:           []byte("id.token"),
+				RefreshToken:      []byte("refresh.token"),
 			},
 			expected: "Session{email:email@email.email user:some.user PreferredUsername:preferred.user token:true id_token:true created:2000-01-01 00:00:00 +0000 UTC expires:2000-01-01 01:00:00 +0000 UTC refresh_token:true}",
 		},
@@ -101,7 +103,7 @@ func TestString(t *testing.T) {
 				Email:             "email@email.email",
 				User:              "some.user",
 				PreferredUsername: "preferred.user",
-				AccessToken:       "access.token",
+				AccessToken:       []byte("access.token"),
 			},
 			expected: "Session{email:email@email.email user:some.user PreferredUsername:preferred.user token:true}",
 		},
@@ -111,7 +113,7 @@ func TestString(t *testing.T) {
 				Email:             "email@email.email",
 				User:              "some.user",
 				PreferredUsername: "preferred.user",
-				IDToken:           "id.token",
+				IDToken:           []byte("id.token"),
 			},
 			expected: "Session{email:email@email.email user:some.user PreferredUsername:preferred.user id_token:true}",
 		},
@@ -121,7 +123,7 @@ func TestString(t *testing.T) {
 				Email:             "email@email.email",
 				User:              "some.user",
 				PreferredUsername: "preferred.user",
-				RefreshToken:      "refresh.token",
+				RefreshToken:      []byte("refresh.token"),
 			},
 			expected: "Session{email:email@email.email user:some.user PreferredUsername:preferred.user refresh_token:true}",
 		},
@@ -157,6 +159,45 @@ func TestAge(t *testing.T) {
 	assert.Equal(t, time.Hour, ss.Age().Round(time.Minute))
 }
 
+func TestAuthenticationContext(t *testing.T) {
+	idToken := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+	}
+
+	testCases := map[string]struct {
+		session *SessionState
+		acr     string
+		amr     []string
+	}{
+		"without an ID token": {
+			session: &SessionState{},
+		},
+		"with a malformed ID token": {
+			session: &SessionState{IDToken: []byte("not-a-jwt")},
+		},
+		"without the claims": {
+			session: &SessionState{IDToken: []byte(idToken(`{"sub":"123"}`))},
+		},
+		"with an array of methods": {
+			session: &SessionState{IDToken: []byte(idToken(`{"acr":"urn:mace:incommon:iap:silver","amr":["pwd","otp"]}`))},
+			acr:     "urn:mace:incommon:iap:silver",
+			amr:     []string{"pwd", "otp"},
+		},
+		"with a single method": {
+			session: &SessionState{IDToken: []byte(idToken(`{"amr":"mfa"}`))},
+			amr:     []string{"mfa"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			acr, amr := tc.session.AuthenticationContext()
+			assert.Equal(t, tc.acr, acr)
+			assert.Equal(t, tc.amr, amr)
+		})
+	}
+}
+
 // TestEncodeAndDecodeSessionState encodes & decodes various session states
 // and confirms the operation is 1:1
 func TestEncodeAndDecodeSessionState(t *testing.T) {
@@ -172,55 +213,55 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 			Email:             "username@example.com",
 			User:              "username",
 			PreferredUsername: "preferred.username",
-			AccessToken:       "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
-			IDToken:           "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			AccessToken:       []byte("AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
+			IDToken:           []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			CreatedAt:         &created,
 			ExpiresOn:         &expires,
-			RefreshToken:      "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			RefreshToken:      []byte("RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
 		},
 		"No ExpiresOn": {
 			Email:             "username@example.com",
 			User:              "username",
 			PreferredUsername: "preferred.username",
-			AccessToken:       "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
-			IDToken:           "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			AccessToken:       []byte("AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
+			IDToken:           []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			CreatedAt:         &created,
-			RefreshToken:      "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			RefreshToken:      []byte("RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
 		},
 		"No PreferredUsername": {
 			Email:        "username@example.com",
 			User:         "username",
-			AccessToken:  "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
-			IDToken:      "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			AccessToken:  []byte("AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
+			IDToken:      []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			CreatedAt:    &created,
 			ExpiresOn:    &expires,
-			RefreshToken: "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			RefreshToken: []byte("RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			Nonce:        []byte("abcdef1234567890abcdef1234567890"),
 		},
 		"Minimal session": {
 			User:         "username",
-			IDToken:      "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			IDToken:      []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			CreatedAt:    &created,
-			RefreshToken: "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			RefreshToken: []byte("RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 		},
 		"Bearer authorization header created session": {
 			Email:       "username",
 			User:        "username",
-			AccessToken: "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
-			IDToken:     "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			AccessToken: []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
+			IDToken:     []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			ExpiresOn:   &expires,
 		},
 		"With groups": {
 			Email:             "username@example.com",
 			User:              "username",
 			PreferredUsername: "preferred.username",
-			AccessToken:       "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
-			IDToken:           "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			AccessToken:       []byte("AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
+			IDToken:           []byte("IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			CreatedAt:         &created,
 			ExpiresOn:         &expires,
-			RefreshToken:      "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			RefreshToken:      []byte("RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7"),
 			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
 			Groups:            []string{"group-a", "group-b"},
 		},
@@ -268,6 +309,54 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 	}
 }
 
+func TestDecodeSessionStateWithStringTokens(t *testing.T) {
+	// Sessions encoded before the tokens were held in byte slices
+	type legacySessionState struct {
+		AccessToken  string `msgpack:"at,omitempty"`
+		IDToken      string `msgpack:"it,omitempty"`
+		RefreshToken string `msgpack:"rt,omitempty"`
+		Email        string `msgpack:"e,omitempty"`
+	}
+	packed, err := msgpack.Marshal(&legacySessionState{
+		AccessToken:  "AccessToken",
+		IDToken:      "IDToken",
+		RefreshToken: "RefreshToken",
+		Email:        "username@example.com",
+	})
+	assert.NoError(t, err)
+
+	c, err := encryption.NewGCMCipher([]byte("0123456789abcdef"))
+	assert.NoError(t, err)
+	encrypted, err := c.Encrypt(packed)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeSessionState(encrypted, c, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("AccessToken"), decoded.AccessToken)
+	assert.Equal(t, []byte("IDToken"), decoded.IDToken)
+	assert.Equal(t, []byte("RefreshToken"), decoded.RefreshToken)
+	assert.Equal(t, "username@example.com", decoded.Email)
+}
+
+func TestWipe(t *testing.T) {
+	ss := &SessionState{
+		Email:        "username@example.com",
+		AccessToken:  []byte("AccessToken"),
+		IDToken:      []byte("IDToken"),
+		RefreshToken: []byte("RefreshToken"),
+	}
+	ss.Wipe()
+
+	assert.Equal(t, make([]byte, len("AccessToken")), ss.AccessToken)
+	assert.Equal(t, make([]byte, len("IDToken")), ss.IDToken)
+	assert.Equal(t, make([]byte, len("RefreshToken")), ss.RefreshToken)
+	assert.Equal(t, "username@example.com", ss.Email)
+
+	// A nil session has nothing to wipe
+	var nilSession *SessionState
+	nilSession.Wipe()
+}
+
 func compareSessionStates(t *testing.T, expected *SessionState, actual *SessionState) {
 	if expected.CreatedAt != nil {
 		assert.NotNil(t, actual.CreatedAt)
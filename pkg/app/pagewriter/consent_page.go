@@ -0,0 +1,115 @@
+package pagewriter
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// consentPageWriter is used to render the page of the terms users accept
+// before accessing the upstreams.
+type consentPageWriter struct {
+	// template is the consent page HTML template.
+	template *template.Template
+
+	// errorPageWriter is used to render an error if there are problems with rendering the consent page.
+	errorPageWriter *errorPageWriter
+
+	// proxyPrefix is the prefix under which OAuth2 Proxy pages are served.
+	proxyPrefix string
+
+	// terms are the terms users accept, as HTML.
+	terms string
+
+	// footer is the footer to be displayed at the bottom of the page.
+	// If not set, a default footer will be used.
+	footer string
+
+	// version is the OAuth2 Proxy version to be used in the default footer.
+	version string
+
+	// logoData is the logo to render in the template.
+	logoData string
+
+	// securityHeaders are the security headers set on consent pages.
+	securityHeaders *securityHeaders
+
+	// catalogs are the message catalogs the page is rendered with.
+	catalogs *catalogs
+
+	// brand is the branding of the page.
+	brand branding
+}
+
+// ConsentPageOpts bundles up all the content needed to write the Consent Page
+type ConsentPageOpts struct {
+	// RedirectURL is where users are redirected once they accepted the terms
+	RedirectURL string
+	// Token protects the form accepting the terms from cross-site requests
+	Token string
+}
+
+// WriteConsentPage writes the consent page to the given response writer.
+func (c *consentPageWriter) WriteConsentPage(rw http.ResponseWriter, req *http.Request, opts ConsentPageOpts) {
+	nonce := c.securityHeaders.write(rw)
+	rw.WriteHeader(http.StatusOK)
+
+	msgs := c.catalogs.forAcceptLanguage(req.Header.Get("Accept-Language"))
+
+	// We allow unescaped template.HTML since it is user configured options
+	/* #nosec G203 */
+	data := struct {
+		ProxyPrefix string
+		Redirect    string
+		Token       string
+		Terms       template.HTML
+		Footer      template.HTML
+		Version     string
+		LogoData    template.HTML
+		CSPNonce    string
+		Lang        string
+		Messages    pageMessages
+		Brand       branding
+	}{
+		ProxyPrefix: c.proxyPrefix,
+		Redirect:    opts.RedirectURL,
+		Token:       opts.Token,
+		Terms:       template.HTML(c.terms),
+		Footer:      template.HTML(c.footer),
+		Version:     c.version,
+		LogoData:    template.HTML(c.logoData),
+		CSPNonce:    nonce,
+		Lang:        msgs.lang,
+		Messages:    msgs,
+		Brand:       c.brand,
+	}
+
+	if err := c.template.Execute(rw, data); err != nil {
+		logger.Printf("Error rendering consent template: %v", err)
+		scope := middlewareapi.GetRequestScope(req)
+		c.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
+			Status:         http.StatusInternalServerError,
+			RedirectURL:    opts.RedirectURL,
+			RequestID:      scope.RequestID,
+			AppError:       err.Error(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+		})
+	}
+}
+
+// loadConsentTerms reads the HTML of the terms of the consent page, if any.
+func loadConsentTerms(termsPath string) (string, error) {
+	if termsPath == "" {
+		return "", nil
+	}
+
+	terms, err := os.ReadFile(termsPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read consent terms file: %v", err)
+	}
+	return string(terms), nil
+}
@@ -0,0 +1,79 @@
+package pagewriter
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Consent Page", func() {
+	Context("Consent Page Writer", func() {
+		var request *http.Request
+		var consentPage *consentPageWriter
+
+		BeforeEach(func() {
+			errorTmpl, err := template.New("").Parse("{{.Title}} | {{.RequestID}}")
+			Expect(err).ToNot(HaveOccurred())
+			errorPage := &errorPageWriter{
+				template: errorTmpl,
+				catalogs: testCatalogs,
+			}
+
+			tmpl, err := template.New("").Parse("{{.ProxyPrefix}} {{.Redirect}} {{.Token}} {{.Terms}} {{.Lang}} {{.Messages.T \"consent.accept\"}}")
+			Expect(err).ToNot(HaveOccurred())
+
+			consentPage = &consentPageWriter{
+				template:        tmpl,
+				errorPageWriter: errorPage,
+				proxyPrefix:     "/prefix",
+				terms:           "<p>Terms</p>",
+				catalogs:        testCatalogs,
+			}
+
+			request = httptest.NewRequest("", "http://127.0.0.1/", nil)
+			request = middlewareapi.AddRequestScope(request, &middlewareapi.RequestScope{
+				RequestID: testRequestID,
+			})
+		})
+
+		It("Writes the template to the response writer", func() {
+			recorder := httptest.NewRecorder()
+			consentPage.WriteConsentPage(recorder, request, ConsentPageOpts{RedirectURL: "/redirect", Token: "token"})
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("/prefix /redirect token <p>Terms</p> en Accept"))
+		})
+
+		It("Writes the page in the language of the request", func() {
+			request.Header.Set("Accept-Language", "fr")
+
+			recorder := httptest.NewRecorder()
+			consentPage.WriteConsentPage(recorder, request, ConsentPageOpts{RedirectURL: "/redirect", Token: "token"})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(HaveSuffix("fr Accepter"))
+		})
+
+		It("Writes an error if the template can't be rendered", func() {
+			tmpl, err := template.New("").Parse("{{.Unknown}}")
+			Expect(err).ToNot(HaveOccurred())
+			consentPage.template = tmpl
+
+			recorder := httptest.NewRecorder()
+			consentPage.WriteConsentPage(recorder, request, ConsentPageOpts{RedirectURL: "/redirect", Token: "token"})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal(fmt.Sprintf("Internal Server Error | %s", testRequestID)))
+		})
+	})
+})
@@ -6,18 +6,11 @@ import (
 	"net/http"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
-// errorMessages are default error messages for each of the different
-// http status codes expected to be rendered in the error page.
-var errorMessages = map[int]string{
-	http.StatusInternalServerError: "Oops! Something went wrong. For more information contact your server administrator.",
-	http.StatusNotFound:            "We could not find the resource you were looking for.",
-	http.StatusForbidden:           "You do not have permission to access this resource.",
-	http.StatusUnauthorized:        "You need to be logged in to access this resource.",
-}
-
 // errorPageWriter is used to render error pages.
 type errorPageWriter struct {
 	// template is the error page HTML template.
@@ -36,6 +29,21 @@ type errorPageWriter struct {
 	// debug determines whether errors pages should be rendered with detailed
 	// errors.
 	debug bool
+
+	// securityHeaders are the security headers set on error pages.
+	securityHeaders *securityHeaders
+
+	// redirectSigner, when set, signs the redirect of the sign-in form.
+	redirectSigner *redirect.Signer
+
+	// catalogs are the message catalogs the page is rendered with.
+	catalogs *catalogs
+
+	// brand is the branding of the page.
+	brand branding
+
+	// logoData is the custom logo to render in the template, if any.
+	logoData string
 }
 
 // ErrorPageOpts bundles up all the content needed to write the Error Page
@@ -46,18 +54,27 @@ type ErrorPageOpts struct {
 	RedirectURL string
 	// The UUID of the request
 	RequestID string
+	// The machine-readable code of the error, which is passed in the query
+	// string when signing in again
+	ErrorCode string
 	// App Error shown in debug mode
 	AppError string
 	// Generic error messages shown in non-debug mode
 	Messages []interface{}
+	// The Accept-Language header of the request, which selects the language
+	// of the page
+	AcceptLanguage string
 }
 
 // WriteErrorPage writes an error page to the given response writer.
 // It uses the passed redirectURL to give users the option to go back to where
 // they originally came from or try signing in again.
 func (e *errorPageWriter) WriteErrorPage(rw http.ResponseWriter, opts ErrorPageOpts) {
+	nonce := e.securityHeaders.write(rw)
 	rw.WriteHeader(opts.Status)
 
+	msgs := e.catalogs.forAcceptLanguage(opts.AcceptLanguage)
+
 	// We allow unescaped template.HTML since it is user configured options
 	/* #nosec G203 */
 	data := struct {
@@ -67,17 +84,33 @@ func (e *errorPageWriter) WriteErrorPage(rw http.ResponseWriter, opts ErrorPageO
 		StatusCode  int
 		Redirect    string
 		RequestID   string
+		ErrorCode   string
 		Footer      template.HTML
 		Version     string
+		CSPNonce    string
+		Lang        string
+		Messages    pageMessages
+		Brand       branding
+		LogoData    template.HTML
+
+		RedirectSignature string
 	}{
-		Title:       http.StatusText(opts.Status),
-		Message:     e.getMessage(opts.Status, opts.AppError, opts.Messages...),
+		Title:       errorTitle(msgs, opts.Status),
+		Message:     e.getMessage(msgs, opts.Status, opts.AppError, opts.Messages...),
 		ProxyPrefix: e.proxyPrefix,
 		StatusCode:  opts.Status,
 		Redirect:    opts.RedirectURL,
 		RequestID:   opts.RequestID,
+		ErrorCode:   opts.ErrorCode,
 		Footer:      template.HTML(e.footer),
 		Version:     e.version,
+		CSPNonce:    nonce,
+		Lang:        msgs.lang,
+		Messages:    msgs,
+		Brand:       e.brand,
+		LogoData:    template.HTML(e.logoData),
+
+		RedirectSignature: e.redirectSigner.Sign(opts.RedirectURL),
 	}
 
 	if err := e.template.Execute(rw, data); err != nil {
@@ -90,14 +123,17 @@ func (e *errorPageWriter) WriteErrorPage(rw http.ResponseWriter, opts ErrorPageO
 // when there are issues with upstream servers.
 // It is expected to always render a bad gateway error.
 func (e *errorPageWriter) ProxyErrorHandler(rw http.ResponseWriter, req *http.Request, proxyErr error) {
+	errcode.Record(req, errcode.UpstreamError)
 	logger.Errorf("Error proxying to upstream server: %v", proxyErr)
 	scope := middlewareapi.GetRequestScope(req)
 	e.WriteErrorPage(rw, ErrorPageOpts{
-		Status:      http.StatusBadGateway,
-		RedirectURL: "", // The user is already logged in and has hit an upstream error. Makes no sense to redirect in this case.
-		RequestID:   scope.RequestID,
-		AppError:    proxyErr.Error(),
-		Messages:    []interface{}{"There was a problem connecting to the upstream server."},
+		Status:         http.StatusBadGateway,
+		RedirectURL:    "", // The user is already logged in and has hit an upstream error. Makes no sense to redirect in this case.
+		RequestID:      scope.RequestID,
+		ErrorCode:      scope.ErrorCode,
+		AppError:       proxyErr.Error(),
+		Messages:       []interface{}{"There was a problem connecting to the upstream server."},
+		AcceptLanguage: req.Header.Get("Accept-Language"),
 	})
 }
 
@@ -105,8 +141,9 @@ func (e *errorPageWriter) ProxyErrorHandler(rw http.ResponseWriter, req *http.Re
 // If the errorPagewriter.Debug is enabled, the application error takes precedence.
 // Otherwise, any messages will be used.
 // The first message is expected to be a format string.
-// If no messages are supplied, a default error message will be used.
-func (e *errorPageWriter) getMessage(status int, appError string, messages ...interface{}) string {
+// If no messages are supplied, the default error message of the status in the
+// language of the page will be used.
+func (e *errorPageWriter) getMessage(msgs pageMessages, status int, appError string, messages ...interface{}) string {
 	if e.debug {
 		return appError
 	}
@@ -114,8 +151,17 @@ func (e *errorPageWriter) getMessage(status int, appError string, messages ...in
 		format := fmt.Sprintf("%v", messages[0])
 		return fmt.Sprintf(format, messages[1:]...)
 	}
-	if msg, ok := errorMessages[status]; ok {
+	if msg, ok := msgs.catalog[fmt.Sprintf("error.message.%d", status)]; ok {
 		return msg
 	}
-	return "Unknown error"
+	return msgs.T("error.message.unknown")
+}
+
+// errorTitle returns the title of the error page of the status in the
+// language of the page, or the status text when it has no translation.
+func errorTitle(msgs pageMessages, status int) string {
+	if title, ok := msgs.catalog[fmt.Sprintf("error.title.%d", status)]; ok {
+		return title
+	}
+	return http.StatusText(status)
 }
@@ -23,6 +23,7 @@ var _ = Describe("Error Page Writer", func() {
 			proxyPrefix: "/prefix/",
 			footer:      "Custom Footer Text",
 			version:     "v0.0.0-test",
+			catalogs:    testCatalogs,
 		}
 	})
 
@@ -41,6 +42,36 @@ var _ = Describe("Error Page Writer", func() {
 			Expect(string(body)).To(Equal("Forbidden You do not have permission to access this resource. /prefix/ 403 /redirect 11111111-2222-4333-8444-555555555555 Custom Footer Text v0.0.0-test"))
 		})
 
+		It("Writes the template in the language of the request", func() {
+			recorder := httptest.NewRecorder()
+			errorPage.WriteErrorPage(recorder, ErrorPageOpts{
+				Status:         404,
+				RedirectURL:    "/redirect",
+				RequestID:      testRequestID,
+				AcceptLanguage: "fr-CA, en;q=0.5",
+			})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("Introuvable Nous n&#39;avons pas trouvé la ressource que vous recherchez. /prefix/ 404 /redirect 11111111-2222-4333-8444-555555555555 Custom Footer Text v0.0.0-test"))
+		})
+
+		It("Writes the error code", func() {
+			tmpl, err := template.New("").Parse("{{.StatusCode}} {{.ErrorCode}}")
+			Expect(err).ToNot(HaveOccurred())
+			errorPage.template = tmpl
+
+			recorder := httptest.NewRecorder()
+			errorPage.WriteErrorPage(recorder, ErrorPageOpts{
+				Status:    403,
+				ErrorCode: "group_denied",
+			})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("403 group_denied"))
+		})
+
 		It("With a different code, uses the stock message for the correct code", func() {
 			recorder := httptest.NewRecorder()
 			errorPage.WriteErrorPage(recorder, ErrorPageOpts{
@@ -100,6 +131,7 @@ var _ = Describe("Error Page Writer", func() {
 			body, err := ioutil.ReadAll(recorder.Result().Body)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(string(body)).To(Equal("Bad Gateway There was a problem connecting to the upstream server. /prefix/ 502  11111111-2222-4333-8444-555555555555 Custom Footer Text v0.0.0-test"))
+			Expect(middlewareapi.GetRequestScope(req).ErrorCode).To(Equal("upstream_error"))
 		})
 	})
 
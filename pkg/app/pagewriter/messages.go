@@ -0,0 +1,200 @@
+package pagewriter
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// localesDir is the folder of the message catalogs, embedded and in the
+// custom templates directory.
+const localesDir = "locales"
+
+// defaultLocales are the default message catalogs, with a JSON file of
+// messages for each language.
+//
+//go:embed locales/*.json
+var defaultLocales embed.FS
+
+// catalog maps the IDs of messages to their text.
+type catalog map[string]string
+
+// pageMessages are the messages of a page in the language of the request.
+type pageMessages struct {
+	// lang is the language of the messages.
+	lang string
+
+	// catalog holds the messages of the language.
+	catalog catalog
+}
+
+// T returns the message with the ID, formatted with the args.
+// The ID is returned when there is no message with the ID, so that missing
+// messages of custom templates are noticed.
+func (m pageMessages) T(id string, args ...interface{}) string {
+	msg, ok := m.catalog[id]
+	if !ok {
+		return id
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// catalogs holds the message catalogs of each language, with the messages
+// missing from a catalog filled in from the catalog of its base language and
+// then from the catalog of the default language.
+type catalogs struct {
+	defaultLanguage string
+	languages       map[string]catalog
+}
+
+// loadCatalogs loads the default message catalogs, overridden by the catalogs
+// of the locales folder of the custom directory, if any.
+func loadCatalogs(customDir, defaultLanguage string) (*catalogs, error) {
+	raw := map[string]catalog{}
+
+	entries, err := defaultLocales.ReadDir(localesDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read default message catalogs: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := defaultLocales.ReadFile(path.Join(localesDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read default message catalog %s: %v", entry.Name(), err)
+		}
+		if err := addCatalog(raw, entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if customDir != "" {
+		files, err := filepath.Glob(filepath.Join(customDir, localesDir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("could not list message catalogs: %v", err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("could not read message catalog: %v", err)
+			}
+			if err := addCatalog(raw, file, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	defaultLanguage = strings.ToLower(defaultLanguage)
+	if _, ok := raw[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("there is no message catalog for the default language %q", defaultLanguage)
+	}
+
+	c := &catalogs{
+		defaultLanguage: defaultLanguage,
+		languages:       map[string]catalog{},
+	}
+	for lang := range raw {
+		merged := catalog{}
+		for _, fallback := range []string{defaultLanguage, baseLanguage(lang), lang} {
+			for id, msg := range raw[fallback] {
+				merged[id] = msg
+			}
+		}
+		c.languages[lang] = merged
+	}
+	return c, nil
+}
+
+// addCatalog parses the messages of a catalog file, named after its language,
+// eg de.json or pt-BR.json, and adds them to the catalog of the language.
+func addCatalog(catalogs map[string]catalog, fileName string, data []byte) error {
+	msgs := catalog{}
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return fmt.Errorf("could not parse message catalog %s: %v", fileName, err)
+	}
+
+	lang := strings.ToLower(strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName)))
+	if catalogs[lang] == nil {
+		catalogs[lang] = catalog{}
+	}
+	for id, msg := range msgs {
+		catalogs[lang][id] = msg
+	}
+	return nil
+}
+
+// forAcceptLanguage returns the messages of the language of the
+// Accept-Language header preferred by the user that has a catalog, or of the
+// default language.
+// A language without a catalog of its own, eg en-GB, uses the catalog of its
+// base language, eg en.
+func (c *catalogs) forAcceptLanguage(acceptLanguage string) pageMessages {
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		for _, candidate := range []string{lang, baseLanguage(lang)} {
+			if msgs, ok := c.languages[candidate]; ok {
+				return pageMessages{lang: candidate, catalog: msgs}
+			}
+		}
+	}
+	return pageMessages{lang: c.defaultLanguage, catalog: c.languages[c.defaultLanguage]}
+}
+
+// parseAcceptLanguage returns the lowercase languages of an Accept-Language
+// header, eg `de-CH, de;q=0.9, en;q=0.8`, in order of preference.
+// Languages with a quality of 0 and the `*` wildcard are omitted.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang    string
+		quality float64
+	}
+
+	langs := []weighted{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality > 0 {
+			langs = append(langs, weighted{lang: lang, quality: quality})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].quality > langs[j].quality
+	})
+
+	result := make([]string, 0, len(langs))
+	for _, l := range langs {
+		result = append(result, l.lang)
+	}
+	return result
+}
+
+// baseLanguage returns the language of a language tag without its region or
+// script, eg de for de-CH.
+func baseLanguage(lang string) string {
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		return lang[:i]
+	}
+	return lang
+}
@@ -0,0 +1,117 @@
+package pagewriter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// testCatalogs are the default message catalogs.
+var testCatalogs = func() *catalogs {
+	c, err := loadCatalogs("", "en")
+	if err != nil {
+		panic(err)
+	}
+	return c
+}()
+
+var _ = Describe("Messages", func() {
+	DescribeTable("parseAcceptLanguage",
+		func(header string, expected []string) {
+			Expect(parseAcceptLanguage(header)).To(Equal(expected))
+		},
+		Entry("with no header", "", []string{}),
+		Entry("with a single language", "de", []string{"de"}),
+		Entry("with weighted languages", "en;q=0.5, de-CH, fr;q=0.8", []string{"de-ch", "fr", "en"}),
+		Entry("with excluded languages and a wildcard", "de;q=0, es, *;q=0.1", []string{"es"}),
+		Entry("with an invalid quality", "de;q=high, fr", []string{"fr"}),
+	)
+
+	DescribeTable("forAcceptLanguage",
+		func(header, expectedLang, expectedTitle string) {
+			msgs := testCatalogs.forAcceptLanguage(header)
+			Expect(msgs.lang).To(Equal(expectedLang))
+			Expect(msgs.T("sign_in.title")).To(Equal(expectedTitle))
+		},
+		Entry("without a header, uses the default language", "", "en", "Sign In"),
+		Entry("with a catalog for the language", "fr", "fr", "Connexion"),
+		Entry("with a regional language, uses its base language", "de-CH", "de", "Anmelden"),
+		Entry("with a preferred language without a catalog", "ja, es;q=0.9", "es", "Iniciar sesión"),
+		Entry("with no language with a catalog", "ja", "en", "Sign In"),
+	)
+
+	It("formats messages with their arguments", func() {
+		msgs := testCatalogs.forAcceptLanguage("de")
+		Expect(msgs.T("sign_in.with_provider", "GitHub")).To(Equal("Mit GitHub anmelden"))
+	})
+
+	It("returns the ID of missing messages", func() {
+		msgs := testCatalogs.forAcceptLanguage("")
+		Expect(msgs.T("missing.message")).To(Equal("missing.message"))
+	})
+
+	Context("with custom catalogs", func() {
+		var customDir string
+
+		BeforeEach(func() {
+			var err error
+			customDir, err = ioutil.TempDir("", "oauth2-proxy-messages-test")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Mkdir(filepath.Join(customDir, localesDir), 0700)).To(Succeed())
+			writeCatalog := func(name, content string) {
+				Expect(ioutil.WriteFile(filepath.Join(customDir, localesDir, name), []byte(content), 0600)).To(Succeed())
+			}
+			writeCatalog("de.json", `{"sign_in.title": "Einloggen", "custom.message": "Eigene Nachricht"}`)
+			writeCatalog("pt-BR.json", `{"sign_in.title": "Entrar"}`)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(customDir)).To(Succeed())
+		})
+
+		It("overrides the default messages", func() {
+			c, err := loadCatalogs(customDir, "en")
+			Expect(err).ToNot(HaveOccurred())
+
+			msgs := c.forAcceptLanguage("de")
+			Expect(msgs.T("sign_in.title")).To(Equal("Einloggen"))
+			Expect(msgs.T("custom.message")).To(Equal("Eigene Nachricht"))
+			Expect(msgs.T("sign_in.username")).To(Equal("Benutzername"))
+		})
+
+		It("adds languages, with the messages of the default language", func() {
+			c, err := loadCatalogs(customDir, "en")
+			Expect(err).ToNot(HaveOccurred())
+
+			msgs := c.forAcceptLanguage("pt-BR")
+			Expect(msgs.lang).To(Equal("pt-br"))
+			Expect(msgs.T("sign_in.title")).To(Equal("Entrar"))
+			Expect(msgs.T("sign_in.username")).To(Equal("Username"))
+		})
+
+		It("uses a custom default language", func() {
+			c, err := loadCatalogs(customDir, "de")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(c.forAcceptLanguage("ja").T("sign_in.title")).To(Equal("Einloggen"))
+			Expect(c.forAcceptLanguage("pt").T("sign_in.username")).To(Equal("Benutzername"))
+		})
+
+		It("fails with an invalid catalog", func() {
+			Expect(ioutil.WriteFile(filepath.Join(customDir, localesDir, "fr.json"), []byte("{"), 0600)).To(Succeed())
+
+			_, err := loadCatalogs(customDir, "en")
+			Expect(err).To(MatchError(HavePrefix("could not parse message catalog")))
+		})
+	})
+
+	It("fails without a catalog for the default language", func() {
+		_, err := loadCatalogs("", "ja")
+		Expect(err).To(MatchError(`there is no message catalog for the default language "ja"`))
+	})
+})
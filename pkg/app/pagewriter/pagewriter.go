@@ -3,32 +3,65 @@ package pagewriter
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
 )
 
-// Writer is an interface for rendering html templates for both sign-in and
-// error pages.
+// Writer is an interface for rendering html templates for sign-in, error,
+// consent and sign-out pages.
 // It can also be used to write errors for the http.ReverseProxy used in the
 // upstream package.
 type Writer interface {
 	WriteSignInPage(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int)
 	WriteErrorPage(rw http.ResponseWriter, opts ErrorPageOpts)
+	WriteConsentPage(rw http.ResponseWriter, req *http.Request, opts ConsentPageOpts)
+	WriteSignOutPage(rw http.ResponseWriter, req *http.Request, opts SignOutPageOpts)
 	ProxyErrorHandler(rw http.ResponseWriter, req *http.Request, proxyErr error)
 	WriteRobotsTxt(rw http.ResponseWriter, req *http.Request)
+	WriteStaticFile(rw http.ResponseWriter, req *http.Request)
 }
 
 // pageWriter implements the Writer interface
 type pageWriter struct {
 	*errorPageWriter
 	*signInPageWriter
+	*consentPageWriter
+	*signOutPageWriter
 	*staticPageWriter
 }
 
+// FooterLink is a link rendered in the footer of the sign-in and error pages.
+type FooterLink struct {
+	Label string
+	URL   string
+}
+
+// branding is the branding of the sign-in and error pages, passed to their
+// templates.
+type branding struct {
+	// Color is the color of the buttons and links, if any.
+	Color string
+
+	// BackgroundColor is the background color of the pages, if any.
+	BackgroundColor string
+
+	// FooterLinks are the links rendered in the footer.
+	FooterLinks []FooterLink
+}
+
 // Opts contains all options required to configure the template
 // rendering within OAuth2 Proxy.
 type Opts struct {
 	// TemplatesPath is the path from which to load custom templates for the sign-in and error pages.
+	// Message catalogs in its locales folder override the default messages.
 	TemplatesPath string
 
+	// DefaultLanguage is the language of the pages when the Accept-Language
+	// header of the request matches no message catalog.
+	// It defaults to English.
+	DefaultLanguage string
+
 	// ProxyPrefix is the prefix under which OAuth2 Proxy pages are served.
 	ProxyPrefix string
 
@@ -46,16 +79,67 @@ type Opts struct {
 	// DisplayLoginForm determines whether or not the basic auth password form is displayed on the sign-in page.
 	DisplayLoginForm bool
 
+	// RememberMe renders a "keep me signed in" checkbox in the sign-in forms,
+	// for users to choose whether their session lasts until the browser is
+	// closed or until the cookie expires.
+	RememberMe bool
+
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	ProviderName string
 
+	// Providers are the providers users can sign in with, each rendered as a
+	// button. When empty, a single button is rendered with the ProviderName.
+	Providers []SignInProvider
+
+	// ProviderSearch renders a search box filtering the provider buttons when
+	// there are several providers.
+	ProviderSearch bool
+
+	// ProviderPreferenceCookie is the name of the cookie holding the ID of
+	// the provider the user last signed in with, whose button is highlighted.
+	// The provider is not remembered when it is empty.
+	ProviderPreferenceCookie string
+
 	// SignInMessage is the messge displayed above the login button.
 	SignInMessage string
 
 	// CustomLogo is the path or URL to a logo to be displayed on the sign in page.
 	// The logo can be either PNG, JPG/JPEG or SVG.
 	// If a URL is used, image support depends on the browser.
+	// A custom logo is also displayed on the error pages.
 	CustomLogo string
+
+	// ConsentTermsFile is the path of an HTML file with the terms of the
+	// consent page, if any.
+	ConsentTermsFile string
+
+	// BrandColor is the color of the buttons and links of the pages.
+	BrandColor string
+
+	// BrandBackgroundColor is the background color of the pages.
+	BrandBackgroundColor string
+
+	// FooterLinks are links displayed in the footer of the pages.
+	FooterLinks []FooterLink
+
+	// ContentSecurityPolicy is the Content-Security-Policy of the sign-in and
+	// error pages.
+	// Any `{nonce}` placeholders are replaced with a nonce generated for each
+	// page, which is passed to the templates to allow inline scripts and styles.
+	ContentSecurityPolicy string
+
+	// ReferrerPolicy is the Referrer-Policy of the sign-in and error pages.
+	ReferrerPolicy string
+
+	// FrameAncestors is the source list of the frame-ancestors directive of
+	// the Content-Security-Policy of the sign-in and error pages.
+	FrameAncestors string
+
+	// Challenge is the challenge rendered in the sign-in forms, if any.
+	Challenge challenge.Challenge
+
+	// RedirectSigner, when set, signs the redirects of the sign-in forms.
+	RedirectSigner *redirect.Signer
 }
 
 // NewWriter constructs a Writer from the options given to allow
@@ -66,17 +150,44 @@ func NewWriter(opts Opts) (Writer, error) {
 		return nil, fmt.Errorf("error loading templates: %v", err)
 	}
 
+	defaultLanguage := opts.DefaultLanguage
+	if defaultLanguage == "" {
+		defaultLanguage = "en"
+	}
+	catalogs, err := loadCatalogs(opts.TemplatesPath, defaultLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("error loading message catalogs: %v", err)
+	}
+
 	logoData, err := loadCustomLogo(opts.CustomLogo)
 	if err != nil {
 		return nil, fmt.Errorf("error loading logo: %v", err)
 	}
 
+	// The error pages only display custom logos
+	errorLogoData := ""
+	if opts.CustomLogo != "" {
+		errorLogoData = logoData
+	}
+
+	headers := newSecurityHeaders(opts)
+	brand := branding{
+		Color:           opts.BrandColor,
+		BackgroundColor: opts.BrandBackgroundColor,
+		FooterLinks:     opts.FooterLinks,
+	}
+
 	errorPage := &errorPageWriter{
-		template:    templates.Lookup("error.html"),
-		proxyPrefix: opts.ProxyPrefix,
-		footer:      opts.Footer,
-		version:     opts.Version,
-		debug:       opts.Debug,
+		template:        templates.Lookup("error.html"),
+		proxyPrefix:     opts.ProxyPrefix,
+		footer:          opts.Footer,
+		version:         opts.Version,
+		debug:           opts.Debug,
+		securityHeaders: headers,
+		redirectSigner:  opts.RedirectSigner,
+		catalogs:        catalogs,
+		brand:           brand,
+		logoData:        errorLogoData,
 	}
 
 	signInPage := &signInPageWriter{
@@ -84,11 +195,50 @@ func NewWriter(opts Opts) (Writer, error) {
 		errorPageWriter:  errorPage,
 		proxyPrefix:      opts.ProxyPrefix,
 		providerName:     opts.ProviderName,
+		providers:        opts.Providers,
+		providerSearch:   opts.ProviderSearch,
+		preferenceCookie: opts.ProviderPreferenceCookie,
 		signInMessage:    opts.SignInMessage,
 		footer:           opts.Footer,
 		version:          opts.Version,
 		displayLoginForm: opts.DisplayLoginForm,
+		rememberMe:       opts.RememberMe,
 		logoData:         logoData,
+		securityHeaders:  headers,
+		challenge:        opts.Challenge,
+		redirectSigner:   opts.RedirectSigner,
+		catalogs:         catalogs,
+		brand:            brand,
+	}
+
+	consentTerms, err := loadConsentTerms(opts.ConsentTermsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading consent terms: %v", err)
+	}
+
+	consentPage := &consentPageWriter{
+		template:        templates.Lookup(consentTemplateName),
+		errorPageWriter: errorPage,
+		proxyPrefix:     opts.ProxyPrefix,
+		terms:           consentTerms,
+		footer:          opts.Footer,
+		version:         opts.Version,
+		logoData:        logoData,
+		securityHeaders: headers,
+		catalogs:        catalogs,
+		brand:           brand,
+	}
+
+	signOutPage := &signOutPageWriter{
+		template:        templates.Lookup(signOutTemplateName),
+		errorPageWriter: errorPage,
+		proxyPrefix:     opts.ProxyPrefix,
+		footer:          opts.Footer,
+		version:         opts.Version,
+		logoData:        logoData,
+		securityHeaders: headers,
+		catalogs:        catalogs,
+		brand:           brand,
 	}
 
 	staticPages, err := newStaticPageWriter(opts.TemplatesPath, errorPage)
@@ -97,9 +247,11 @@ func NewWriter(opts Opts) (Writer, error) {
 	}
 
 	return &pageWriter{
-		errorPageWriter:  errorPage,
-		signInPageWriter: signInPage,
-		staticPageWriter: staticPages,
+		errorPageWriter:   errorPage,
+		signInPageWriter:  signInPage,
+		consentPageWriter: consentPage,
+		signOutPageWriter: signOutPage,
+		staticPageWriter:  staticPages,
 	}, nil
 }
 
@@ -108,10 +260,13 @@ func NewWriter(opts Opts) (Writer, error) {
 // If any of the funcs are not provided, a default implementation will be used.
 // This is primarily for us in testing.
 type WriterFuncs struct {
-	SignInPageFunc func(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int)
-	ErrorPageFunc  func(rw http.ResponseWriter, opts ErrorPageOpts)
-	ProxyErrorFunc func(rw http.ResponseWriter, req *http.Request, proxyErr error)
-	RobotsTxtfunc  func(rw http.ResponseWriter, req *http.Request)
+	SignInPageFunc  func(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int)
+	ErrorPageFunc   func(rw http.ResponseWriter, opts ErrorPageOpts)
+	ConsentPageFunc func(rw http.ResponseWriter, req *http.Request, opts ConsentPageOpts)
+	SignOutPageFunc func(rw http.ResponseWriter, req *http.Request, opts SignOutPageOpts)
+	ProxyErrorFunc  func(rw http.ResponseWriter, req *http.Request, proxyErr error)
+	RobotsTxtfunc   func(rw http.ResponseWriter, req *http.Request)
+	StaticFileFunc  func(rw http.ResponseWriter, req *http.Request)
 }
 
 // WriteSignInPage implements the Writer interface.
@@ -123,6 +278,7 @@ func (w *WriterFuncs) WriteSignInPage(rw http.ResponseWriter, req *http.Request,
 		return
 	}
 
+	rw.WriteHeader(statusCode)
 	if _, err := rw.Write([]byte("Sign In")); err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 	}
@@ -144,6 +300,34 @@ func (w *WriterFuncs) WriteErrorPage(rw http.ResponseWriter, opts ErrorPageOpts)
 	}
 }
 
+// WriteConsentPage implements the Writer interface.
+// If the ConsentPageFunc is provided, this will be used, else a default
+// implementation will be used.
+func (w *WriterFuncs) WriteConsentPage(rw http.ResponseWriter, req *http.Request, opts ConsentPageOpts) {
+	if w.ConsentPageFunc != nil {
+		w.ConsentPageFunc(rw, req, opts)
+		return
+	}
+
+	if _, err := rw.Write([]byte("Consent")); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// WriteSignOutPage implements the Writer interface.
+// If the SignOutPageFunc is provided, this will be used, else a default
+// implementation will be used.
+func (w *WriterFuncs) WriteSignOutPage(rw http.ResponseWriter, req *http.Request, opts SignOutPageOpts) {
+	if w.SignOutPageFunc != nil {
+		w.SignOutPageFunc(rw, req, opts)
+		return
+	}
+
+	if _, err := rw.Write([]byte("Sign Out")); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 // ProxyErrorHandler implements the Writer interface.
 // If the ProxyErrorFunc is provided, this will be used, else a default
 // implementation will be used.
@@ -172,3 +356,15 @@ func (w *WriterFuncs) WriteRobotsTxt(rw http.ResponseWriter, req *http.Request)
 		rw.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// WriteStaticFile implements the Writer interface.
+// If the StaticFileFunc is provided, this will be used, else a default
+// implementation will be used.
+func (w *WriterFuncs) WriteStaticFile(rw http.ResponseWriter, req *http.Request) {
+	if w.StaticFileFunc != nil {
+		w.StaticFileFunc(rw, req)
+		return
+	}
+
+	http.NotFound(rw, req)
+}
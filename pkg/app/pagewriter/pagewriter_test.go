@@ -65,6 +65,44 @@ var _ = Describe("Writer", func() {
 			})
 		})
 
+		Context("With consent terms", func() {
+			var termsFile string
+
+			BeforeEach(func() {
+				f, err := ioutil.TempFile("", "oauth2-proxy-pagewriter-test-*.html")
+				Expect(err).ToNot(HaveOccurred())
+				termsFile = f.Name()
+				Expect(f.Close()).To(Succeed())
+				Expect(ioutil.WriteFile(termsFile, []byte("<p>Authorized use only</p>"), 0600)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Expect(os.Remove(termsFile)).To(Succeed())
+			})
+
+			It("Writes the terms in the default consent template", func() {
+				opts.ConsentTermsFile = termsFile
+				writer, err := NewWriter(opts)
+				Expect(err).ToNot(HaveOccurred())
+
+				recorder := httptest.NewRecorder()
+				writer.WriteConsentPage(recorder, request, ConsentPageOpts{RedirectURL: "/redirect", Token: "token"})
+
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(HavePrefix("\n<!DOCTYPE html>"))
+				Expect(string(body)).To(ContainSubstring("<p>Authorized use only</p>"))
+				Expect(string(body)).To(ContainSubstring(`<input type="hidden" name="rd" value="/redirect">`))
+			})
+
+			It("Should return an error when the terms file is missing", func() {
+				opts.ConsentTermsFile = termsFile + ".missing"
+				writer, err := NewWriter(opts)
+				Expect(err).To(MatchError(HavePrefix("error loading consent terms: could not read consent terms file")))
+				Expect(writer).To(BeNil())
+			})
+		})
+
 		Context("With custom templates", func() {
 			var customDir string
 
@@ -209,6 +247,64 @@ var _ = Describe("Writer", func() {
 			}),
 		)
 
+		DescribeTable("WriteConsentPage",
+			func(in writerFuncsTableInput) {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("", "/consent", nil)
+				in.writer.WriteConsentPage(rw, req, ConsentPageOpts{RedirectURL: "<redirectURL>", Token: "<token>"})
+
+				Expect(rw.Result().StatusCode).To(Equal(in.expectedStatus))
+
+				body, err := ioutil.ReadAll(rw.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(in.expectedBody))
+			},
+			Entry("With no override", writerFuncsTableInput{
+				writer:         &WriterFuncs{},
+				expectedStatus: 200,
+				expectedBody:   "Consent",
+			}),
+			Entry("With an override function", writerFuncsTableInput{
+				writer: &WriterFuncs{
+					ConsentPageFunc: func(rw http.ResponseWriter, req *http.Request, opts ConsentPageOpts) {
+						rw.WriteHeader(202)
+						rw.Write([]byte(fmt.Sprintf("%s %s %s", req.URL.Path, opts.RedirectURL, opts.Token)))
+					},
+				},
+				expectedStatus: 202,
+				expectedBody:   "/consent <redirectURL> <token>",
+			}),
+		)
+
+		DescribeTable("WriteSignOutPage",
+			func(in writerFuncsTableInput) {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("", "/sign_out_everywhere", nil)
+				in.writer.WriteSignOutPage(rw, req, SignOutPageOpts{User: "<user>", RedirectURL: "<redirectURL>", Token: "<token>"})
+
+				Expect(rw.Result().StatusCode).To(Equal(in.expectedStatus))
+
+				body, err := ioutil.ReadAll(rw.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(in.expectedBody))
+			},
+			Entry("With no override", writerFuncsTableInput{
+				writer:         &WriterFuncs{},
+				expectedStatus: 200,
+				expectedBody:   "Sign Out",
+			}),
+			Entry("With an override function", writerFuncsTableInput{
+				writer: &WriterFuncs{
+					SignOutPageFunc: func(rw http.ResponseWriter, req *http.Request, opts SignOutPageOpts) {
+						rw.WriteHeader(202)
+						rw.Write([]byte(fmt.Sprintf("%s %s %s %s", req.URL.Path, opts.User, opts.RedirectURL, opts.Token)))
+					},
+				},
+				expectedStatus: 202,
+				expectedBody:   "/sign_out_everywhere <user> <redirectURL> <token>",
+			}),
+		)
+
 		DescribeTable("ProxyErrorHandler",
 			func(in writerFuncsTableInput) {
 				rw := httptest.NewRecorder()
@@ -277,5 +373,34 @@ var _ = Describe("Writer", func() {
 				expectedBody:   "Disallow: *",
 			}),
 		)
+
+		DescribeTable("WriteStaticFile",
+			func(in writerFuncsTableInput) {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("", "/style.css", nil)
+				in.writer.WriteStaticFile(rw, req)
+
+				Expect(rw.Result().StatusCode).To(Equal(in.expectedStatus))
+
+				body, err := ioutil.ReadAll(rw.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(in.expectedBody))
+			},
+			Entry("With no override", writerFuncsTableInput{
+				writer:         &WriterFuncs{},
+				expectedStatus: 404,
+				expectedBody:   "404 page not found\n",
+			}),
+			Entry("With an override function", writerFuncsTableInput{
+				writer: &WriterFuncs{
+					StaticFileFunc: func(rw http.ResponseWriter, req *http.Request) {
+						rw.WriteHeader(202)
+						rw.Write([]byte("body {}"))
+					},
+				},
+				expectedStatus: 202,
+				expectedBody:   "body {}",
+			}),
+		)
 	})
 })
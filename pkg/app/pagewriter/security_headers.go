@@ -0,0 +1,89 @@
+package pagewriter
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// cspNoncePlaceholder is replaced in the Content-Security-Policy with the
+// nonce generated for each rendered page.
+const cspNoncePlaceholder = "{nonce}"
+
+// securityHeaders writes the security headers of the sign-in and error pages.
+type securityHeaders struct {
+	// contentSecurityPolicy is the Content-Security-Policy of the pages.
+	// It may contain the nonce placeholder.
+	contentSecurityPolicy string
+
+	// referrerPolicy is the Referrer-Policy of the pages.
+	referrerPolicy string
+
+	// frameAncestors is the source list of the frame-ancestors directive
+	// added to the Content-Security-Policy.
+	frameAncestors string
+}
+
+// newSecurityHeaders constructs the security headers from the options.
+// If none of the headers are configured, it returns nil.
+func newSecurityHeaders(opts Opts) *securityHeaders {
+	if opts.ContentSecurityPolicy == "" && opts.ReferrerPolicy == "" && opts.FrameAncestors == "" {
+		return nil
+	}
+
+	csp := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(opts.ContentSecurityPolicy), ";"))
+	if opts.FrameAncestors != "" {
+		if csp != "" {
+			csp += "; "
+		}
+		csp += "frame-ancestors " + opts.FrameAncestors
+	}
+
+	return &securityHeaders{
+		contentSecurityPolicy: csp,
+		referrerPolicy:        opts.ReferrerPolicy,
+		frameAncestors:        opts.FrameAncestors,
+	}
+}
+
+// write sets the security headers on the response and returns the nonce
+// that inline scripts and styles of the page must carry.
+// The nonce is empty unless the Content-Security-Policy uses it.
+// This must be called before the response header is written.
+func (s *securityHeaders) write(rw http.ResponseWriter) string {
+	if s == nil {
+		return ""
+	}
+
+	var nonce string
+	csp := s.contentSecurityPolicy
+	if strings.Contains(csp, cspNoncePlaceholder) {
+		b, err := encryption.Nonce(16)
+		if err != nil {
+			// Without a nonce, the inline scripts and styles are blocked but
+			// the page is still served under its policy
+			logger.Errorf("Error generating content security policy nonce: %v", err)
+		} else {
+			nonce = base64.RawURLEncoding.EncodeToString(b)
+		}
+		csp = strings.ReplaceAll(csp, cspNoncePlaceholder, nonce)
+	}
+
+	if csp != "" {
+		rw.Header().Set("Content-Security-Policy", csp)
+	}
+	if s.referrerPolicy != "" {
+		rw.Header().Set("Referrer-Policy", s.referrerPolicy)
+	}
+	// X-Frame-Options is set for browsers that do not support frame-ancestors
+	switch s.frameAncestors {
+	case "'none'":
+		rw.Header().Set("X-Frame-Options", "DENY")
+	case "'self'":
+		rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}
+	return nonce
+}
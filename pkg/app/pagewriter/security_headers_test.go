@@ -0,0 +1,132 @@
+package pagewriter
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Security Headers", func() {
+	type securityHeadersTableInput struct {
+		opts            Opts
+		expectedHeaders http.Header
+	}
+
+	DescribeTable("write",
+		func(in securityHeadersTableInput) {
+			rw := httptest.NewRecorder()
+			nonce := newSecurityHeaders(in.opts).write(rw)
+			Expect(nonce).To(BeEmpty())
+			Expect(rw.Header()).To(Equal(in.expectedHeaders))
+		},
+		Entry("with no headers configured", securityHeadersTableInput{
+			opts:            Opts{},
+			expectedHeaders: http.Header{},
+		}),
+		Entry("with a content security policy", securityHeadersTableInput{
+			opts: Opts{
+				ContentSecurityPolicy: "default-src 'self'",
+			},
+			expectedHeaders: http.Header{
+				"Content-Security-Policy": []string{"default-src 'self'"},
+			},
+		}),
+		Entry("with a referrer policy", securityHeadersTableInput{
+			opts: Opts{
+				ReferrerPolicy: "no-referrer",
+			},
+			expectedHeaders: http.Header{
+				"Referrer-Policy": []string{"no-referrer"},
+			},
+		}),
+		Entry("with frame ancestors and a content security policy", securityHeadersTableInput{
+			opts: Opts{
+				ContentSecurityPolicy: "default-src 'self';",
+				FrameAncestors:        "https://portal.example.com",
+			},
+			expectedHeaders: http.Header{
+				"Content-Security-Policy": []string{"default-src 'self'; frame-ancestors https://portal.example.com"},
+			},
+		}),
+		Entry("with frame ancestors of none", securityHeadersTableInput{
+			opts: Opts{
+				FrameAncestors: "'none'",
+			},
+			expectedHeaders: http.Header{
+				"Content-Security-Policy": []string{"frame-ancestors 'none'"},
+				"X-Frame-Options":         []string{"DENY"},
+			},
+		}),
+		Entry("with frame ancestors of self", securityHeadersTableInput{
+			opts: Opts{
+				FrameAncestors: "'self'",
+			},
+			expectedHeaders: http.Header{
+				"Content-Security-Policy": []string{"frame-ancestors 'self'"},
+				"X-Frame-Options":         []string{"SAMEORIGIN"},
+			},
+		}),
+	)
+
+	Context("with a nonce in the content security policy", func() {
+		var headers *securityHeaders
+
+		BeforeEach(func() {
+			headers = newSecurityHeaders(Opts{
+				ContentSecurityPolicy: "script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'",
+			})
+		})
+
+		It("generates a new nonce for each page", func() {
+			rw := httptest.NewRecorder()
+			nonce := headers.write(rw)
+			Expect(nonce).ToNot(BeEmpty())
+			Expect(rw.Header().Get("Content-Security-Policy")).To(Equal("script-src 'nonce-" + nonce + "'; style-src 'nonce-" + nonce + "'"))
+
+			Expect(headers.write(httptest.NewRecorder())).ToNot(Equal(nonce))
+		})
+
+		It("passes the nonce to the error page template", func() {
+			tmpl, err := template.New("").Parse(`{{.CSPNonce}}`)
+			Expect(err).ToNot(HaveOccurred())
+			errorPage := &errorPageWriter{
+				template:        tmpl,
+				securityHeaders: headers,
+				catalogs:        testCatalogs,
+			}
+
+			rw := httptest.NewRecorder()
+			errorPage.WriteErrorPage(rw, ErrorPageOpts{Status: http.StatusForbidden})
+			Expect(rw.Code).To(Equal(http.StatusForbidden))
+
+			body, err := ioutil.ReadAll(rw.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).ToNot(BeEmpty())
+			Expect(rw.Header().Get("Content-Security-Policy")).To(HavePrefix("script-src 'nonce-" + string(body) + "'"))
+		})
+
+		It("passes the nonce to the sign-in page template", func() {
+			tmpl, err := template.New("").Parse(`{{.CSPNonce}}`)
+			Expect(err).ToNot(HaveOccurred())
+			signInPage := &signInPageWriter{
+				template:        tmpl,
+				errorPageWriter: &errorPageWriter{catalogs: testCatalogs},
+				securityHeaders: headers,
+				catalogs:        testCatalogs,
+			}
+
+			rw := httptest.NewRecorder()
+			signInPage.WriteSignInPage(rw, httptest.NewRequest("", "http://127.0.0.1/", nil), "/redirect", http.StatusOK)
+
+			body, err := ioutil.ReadAll(rw.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).ToNot(BeEmpty())
+			Expect(rw.Header().Get("Content-Security-Policy")).To(HavePrefix("script-src 'nonce-" + string(body) + "'"))
+		})
+	})
+})
@@ -14,12 +14,44 @@ import (
 	"net/http"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
 //go:embed default_logo.svg
 var defaultLogoData string
 
+// SignInProvider is a provider users can sign in with, rendered as a button
+// on the sign-in page.
+type SignInProvider struct {
+	// ID is the ID of the provider, passed to the start endpoint.
+	ID string
+
+	// Name is the name of the provider displayed on its button.
+	Name string
+
+	// Type is the type of the provider, which brands its button.
+	Type string
+
+	// Group is the heading the button is listed under, if any.
+	Group string
+}
+
+// signInProviderGroup is a group of provider buttons of the sign-in page.
+type signInProviderGroup struct {
+	Name      string
+	Providers []signInProviderButton
+}
+
+// signInProviderButton is the button of a provider of the sign-in page.
+type signInProviderButton struct {
+	SignInProvider
+
+	// LastUsed is whether the user last signed in with the provider.
+	LastUsed bool
+}
+
 // signInPageWriter is used to render sign-in pages.
 type signInPageWriter struct {
 	// Template is the sign-in page HTML template.
@@ -34,6 +66,16 @@ type signInPageWriter struct {
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	providerName string
 
+	// providers are the providers rendered as buttons, if any.
+	providers []SignInProvider
+
+	// providerSearch renders a search box filtering the provider buttons.
+	providerSearch bool
+
+	// preferenceCookie is the name of the cookie holding the ID of the
+	// provider the user last signed in with, if it is remembered.
+	preferenceCookie string
+
 	// SignInMessage is the messge displayed above the login button.
 	signInMessage string
 
@@ -47,36 +89,100 @@ type signInPageWriter struct {
 	// DisplayLoginForm determines whether or not the basic auth password form is displayed on the sign-in page.
 	displayLoginForm bool
 
+	// rememberMe renders a checkbox in the sign-in forms for users to stay
+	// signed in after closing the browser.
+	rememberMe bool
+
 	// LogoData is the logo to render in the template.
 	// This should contain valid html.
 	logoData string
+
+	// securityHeaders are the security headers set on sign-in pages.
+	securityHeaders *securityHeaders
+
+	// challenge is the challenge rendered in the sign-in forms, if any.
+	challenge challenge.Challenge
+
+	// redirectSigner, when set, signs the redirect of the sign-in forms.
+	redirectSigner *redirect.Signer
+
+	// catalogs are the message catalogs the page is rendered with.
+	catalogs *catalogs
+
+	// brand is the branding of the page.
+	brand branding
 }
 
 // WriteSignInPage writes the sign-in page to the given response writer.
 // It uses the redirectURL to be able to set the final destination for the user post login.
+// The statusCode is written as the status of the response.
 func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int) {
+	var page *challenge.Page
+	if s.challenge != nil {
+		var err error
+		page, err = s.challenge.Issue()
+		if err != nil {
+			logger.Errorf("Error issuing sign-in challenge: %v", err)
+			scope := middlewareapi.GetRequestScope(req)
+			s.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
+				Status:         http.StatusInternalServerError,
+				RedirectURL:    redirectURL,
+				RequestID:      scope.RequestID,
+				AppError:       err.Error(),
+				AcceptLanguage: req.Header.Get("Accept-Language"),
+			})
+			return
+		}
+	}
+
+	nonce := s.securityHeaders.write(rw)
+	rw.WriteHeader(statusCode)
+
+	groups, count := s.providerGroups(req)
+	msgs := s.catalogs.forAcceptLanguage(req.Header.Get("Accept-Language"))
+
 	// We allow unescaped template.HTML since it is user configured options
 	/* #nosec G203 */
 	t := struct {
-		ProviderName  string
-		SignInMessage template.HTML
-		StatusCode    int
-		CustomLogin   bool
-		Redirect      string
-		Version       string
-		ProxyPrefix   string
-		Footer        template.HTML
-		LogoData      template.HTML
+		ProviderName   string
+		ProviderGroups []signInProviderGroup
+		ProviderSearch bool
+		SignInMessage  template.HTML
+		StatusCode     int
+		CustomLogin    bool
+		RememberMe     bool
+		Redirect       string
+		Version        string
+		ProxyPrefix    string
+		Footer         template.HTML
+		LogoData       template.HTML
+		CSPNonce       string
+		Challenge      *challenge.Page
+		Lang           string
+		Messages       pageMessages
+		Brand          branding
+
+		RedirectSignature string
 	}{
-		ProviderName:  s.providerName,
-		SignInMessage: template.HTML(s.signInMessage),
-		StatusCode:    statusCode,
-		CustomLogin:   s.displayLoginForm,
-		Redirect:      redirectURL,
-		Version:       s.version,
-		ProxyPrefix:   s.proxyPrefix,
-		Footer:        template.HTML(s.footer),
-		LogoData:      template.HTML(s.logoData),
+		ProviderName:   s.providerName,
+		ProviderGroups: groups,
+		ProviderSearch: s.providerSearch && count > 1,
+		SignInMessage:  template.HTML(s.signInMessage),
+		StatusCode:     statusCode,
+		CustomLogin:    s.displayLoginForm,
+		RememberMe:     s.rememberMe,
+		Redirect:       redirectURL,
+		Version:        s.version,
+		ProxyPrefix:    s.proxyPrefix,
+		Footer:         template.HTML(s.footer),
+		LogoData:       template.HTML(s.logoData),
+		CSPNonce:       nonce,
+		Challenge:      page,
+		Lang:           msgs.lang,
+		Messages:       msgs,
+		Brand:          s.brand,
+
+		RedirectSignature: s.redirectSigner.Sign(redirectURL),
 	}
 
 	err := s.template.Execute(rw, t)
@@ -84,12 +190,47 @@ func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Req
 		logger.Printf("Error rendering sign-in template: %v", err)
 		scope := middlewareapi.GetRequestScope(req)
 		s.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
-			Status:      http.StatusInternalServerError,
-			RedirectURL: redirectURL,
-			RequestID:   scope.RequestID,
-			AppError:    err.Error(),
+			Status:         http.StatusInternalServerError,
+			RedirectURL:    redirectURL,
+			RequestID:      scope.RequestID,
+			AppError:       err.Error(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+		})
+	}
+}
+
+// providerGroups returns the provider buttons grouped in the order of the
+// first provider of each group, and the number of providers.
+// Without providers, there is a single button named after the provider name.
+func (s *signInPageWriter) providerGroups(req *http.Request) ([]signInProviderGroup, int) {
+	if len(s.providers) == 0 {
+		return []signInProviderGroup{{
+			Providers: []signInProviderButton{{SignInProvider: SignInProvider{Name: s.providerName}}},
+		}}, 1
+	}
+
+	var lastUsed string
+	if s.preferenceCookie != "" {
+		if c, err := req.Cookie(s.preferenceCookie); err == nil {
+			lastUsed = c.Value
+		}
+	}
+
+	groups := []signInProviderGroup{}
+	index := map[string]int{}
+	for _, provider := range s.providers {
+		i, ok := index[provider.Group]
+		if !ok {
+			i = len(groups)
+			index[provider.Group] = i
+			groups = append(groups, signInProviderGroup{Name: provider.Group})
+		}
+		groups[i].Providers = append(groups[i].Providers, signInProviderButton{
+			SignInProvider: provider,
+			LastUsed:       lastUsed != "" && provider.ID == lastUsed,
 		})
 	}
+	return groups, len(s.providers)
 }
 
 // loadCustomLogo loads the logo file from the path and encodes it to an HTML
@@ -12,11 +12,28 @@ import (
 	"strings"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
+// testChallenge is a challenge.Challenge that issues the page or fails with
+// the error.
+type testChallenge struct {
+	page *challenge.Page
+	err  error
+}
+
+func (c *testChallenge) Issue() (*challenge.Page, error) {
+	return c.page, c.err
+}
+
+func (c *testChallenge) Verify(_ *http.Request) error {
+	return c.err
+}
+
 var _ = Describe("SignIn Page", func() {
 
 	Context("SignIn Page Writer", func() {
@@ -28,6 +45,7 @@ var _ = Describe("SignIn Page", func() {
 			Expect(err).ToNot(HaveOccurred())
 			errorPage := &errorPageWriter{
 				template: errorTmpl,
+				catalogs: testCatalogs,
 			}
 
 			tmpl, err := template.New("").Parse("{{.ProxyPrefix}} {{.ProviderName}} {{.SignInMessage}} {{.Footer}} {{.Version}} {{.Redirect}} {{.CustomLogin}} {{.LogoData}}")
@@ -43,6 +61,7 @@ var _ = Describe("SignIn Page", func() {
 				version:          "v0.0.0-test",
 				displayLoginForm: true,
 				logoData:         "Logo Data",
+				catalogs:         testCatalogs,
 			}
 
 			request = httptest.NewRequest("", "http://127.0.0.1/", nil)
@@ -61,6 +80,66 @@ var _ = Describe("SignIn Page", func() {
 				Expect(string(body)).To(Equal("/prefix/ My Provider Sign In Here Custom Footer Text v0.0.0-test /redirect true Logo Data"))
 			})
 
+			It("Writes the status code", func() {
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusForbidden)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			})
+
+			It("Writes the issued challenge", func() {
+				tmpl, err := template.New("").Parse("{{.Challenge.Type}} {{.Challenge.Token}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.challenge = &testChallenge{page: &challenge.Page{Type: "proof-of-work", Token: "token"}}
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("proof-of-work token"))
+			})
+
+			It("Writes the signature of the redirect", func() {
+				tmpl, err := template.New("").Parse("{{.Redirect}} {{.RedirectSignature}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.redirectSigner = redirect.NewSigner([]byte("secret"))
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("/redirect " + redirect.NewSigner([]byte("secret")).Sign("/redirect")))
+			})
+
+			It("Writes the remember me checkbox", func() {
+				tmpl, err := template.New("").Parse("{{.RememberMe}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.rememberMe = true
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("true"))
+			})
+
+			It("Writes an error if the challenge can't be issued", func() {
+				signInPage.challenge = &testChallenge{err: errors.New("no entropy")}
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(fmt.Sprintf("Internal Server Error | %s", testRequestID)))
+			})
+
 			It("Writes an error if the template can't be rendered", func() {
 				// Overwrite the template with something bad
 				tmpl, err := template.New("").Parse("{{.Unknown}}")
@@ -75,6 +154,46 @@ var _ = Describe("SignIn Page", func() {
 				Expect(string(body)).To(Equal(fmt.Sprintf("Internal Server Error | %s", testRequestID)))
 			})
 		})
+
+		Context("providerGroups", func() {
+			It("has a single button with the provider name without providers", func() {
+				groups, count := signInPage.providerGroups(request)
+				Expect(count).To(Equal(1))
+				Expect(groups).To(Equal([]signInProviderGroup{{
+					Providers: []signInProviderButton{{SignInProvider: SignInProvider{Name: "My Provider"}}},
+				}}))
+			})
+
+			It("groups the providers in the order of their first provider", func() {
+				signInPage.providers = []SignInProvider{
+					{ID: "corp", Name: "Corp", Group: "Employees"},
+					{ID: "github", Name: "GitHub", Type: "github"},
+					{ID: "partner", Name: "Partner", Group: "Employees"},
+				}
+
+				groups, count := signInPage.providerGroups(request)
+				Expect(count).To(Equal(3))
+				Expect(groups).To(HaveLen(2))
+				Expect(groups[0].Name).To(Equal("Employees"))
+				Expect(groups[0].Providers).To(HaveLen(2))
+				Expect(groups[0].Providers[1].ID).To(Equal("partner"))
+				Expect(groups[1].Name).To(BeEmpty())
+				Expect(groups[1].Providers[0].ID).To(Equal("github"))
+			})
+
+			It("marks the provider of the preference cookie as last used", func() {
+				signInPage.providers = []SignInProvider{{ID: "corp", Name: "Corp"}, {ID: "github", Name: "GitHub"}}
+				request.AddCookie(&http.Cookie{Name: "_oauth2_proxy_provider", Value: "github"})
+
+				groups, _ := signInPage.providerGroups(request)
+				Expect(groups[0].Providers[1].LastUsed).To(BeFalse())
+
+				signInPage.preferenceCookie = "_oauth2_proxy_provider"
+				groups, _ = signInPage.providerGroups(request)
+				Expect(groups[0].Providers[0].LastUsed).To(BeFalse())
+				Expect(groups[0].Providers[1].LastUsed).To(BeTrue())
+			})
+		})
 	})
 
 	Context("loadCustomLogo", func() {
@@ -0,0 +1,99 @@
+package pagewriter
+
+import (
+	"html/template"
+	"net/http"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// signOutPageWriter is used to render the page users sign out of this device
+// or of all of their sessions from.
+type signOutPageWriter struct {
+	// template is the sign-out page HTML template.
+	template *template.Template
+
+	// errorPageWriter is used to render an error if there are problems with rendering the sign-out page.
+	errorPageWriter *errorPageWriter
+
+	// proxyPrefix is the prefix under which OAuth2 Proxy pages are served.
+	proxyPrefix string
+
+	// footer is the footer to be displayed at the bottom of the page.
+	// If not set, a default footer will be used.
+	footer string
+
+	// version is the OAuth2 Proxy version to be used in the default footer.
+	version string
+
+	// logoData is the logo to render in the template.
+	logoData string
+
+	// securityHeaders are the security headers set on sign-out pages.
+	securityHeaders *securityHeaders
+
+	// catalogs are the message catalogs the page is rendered with.
+	catalogs *catalogs
+
+	// brand is the branding of the page.
+	brand branding
+}
+
+// SignOutPageOpts bundles up all the content needed to write the Sign Out Page
+type SignOutPageOpts struct {
+	// User is the user who is signed in
+	User string
+	// RedirectURL is where users are redirected once they signed out
+	RedirectURL string
+	// Token protects the form signing out everywhere from cross-site requests
+	Token string
+}
+
+// WriteSignOutPage writes the sign-out page to the given response writer.
+func (s *signOutPageWriter) WriteSignOutPage(rw http.ResponseWriter, req *http.Request, opts SignOutPageOpts) {
+	nonce := s.securityHeaders.write(rw)
+	rw.WriteHeader(http.StatusOK)
+
+	msgs := s.catalogs.forAcceptLanguage(req.Header.Get("Accept-Language"))
+
+	// We allow unescaped template.HTML since it is user configured options
+	/* #nosec G203 */
+	data := struct {
+		ProxyPrefix string
+		User        string
+		Redirect    string
+		Token       string
+		Footer      template.HTML
+		Version     string
+		LogoData    template.HTML
+		CSPNonce    string
+		Lang        string
+		Messages    pageMessages
+		Brand       branding
+	}{
+		ProxyPrefix: s.proxyPrefix,
+		User:        opts.User,
+		Redirect:    opts.RedirectURL,
+		Token:       opts.Token,
+		Footer:      template.HTML(s.footer),
+		Version:     s.version,
+		LogoData:    template.HTML(s.logoData),
+		CSPNonce:    nonce,
+		Lang:        msgs.lang,
+		Messages:    msgs,
+		Brand:       s.brand,
+	}
+
+	if err := s.template.Execute(rw, data); err != nil {
+		logger.Printf("Error rendering sign-out template: %v", err)
+		scope := middlewareapi.GetRequestScope(req)
+		s.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
+			Status:         http.StatusInternalServerError,
+			RedirectURL:    opts.RedirectURL,
+			RequestID:      scope.RequestID,
+			AppError:       err.Error(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+		})
+	}
+}
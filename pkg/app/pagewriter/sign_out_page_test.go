@@ -0,0 +1,78 @@
+package pagewriter
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign Out Page", func() {
+	Context("Sign Out Page Writer", func() {
+		var request *http.Request
+		var signOutPage *signOutPageWriter
+
+		BeforeEach(func() {
+			errorTmpl, err := template.New("").Parse("{{.Title}} | {{.RequestID}}")
+			Expect(err).ToNot(HaveOccurred())
+			errorPage := &errorPageWriter{
+				template: errorTmpl,
+				catalogs: testCatalogs,
+			}
+
+			tmpl, err := template.New("").Parse("{{.ProxyPrefix}} {{.User}} {{.Redirect}} {{.Token}} {{.Lang}} {{.Messages.T \"sign_out.everywhere\"}}")
+			Expect(err).ToNot(HaveOccurred())
+
+			signOutPage = &signOutPageWriter{
+				template:        tmpl,
+				errorPageWriter: errorPage,
+				proxyPrefix:     "/prefix",
+				catalogs:        testCatalogs,
+			}
+
+			request = httptest.NewRequest("", "http://127.0.0.1/", nil)
+			request = middlewareapi.AddRequestScope(request, &middlewareapi.RequestScope{
+				RequestID: testRequestID,
+			})
+		})
+
+		It("Writes the template to the response writer", func() {
+			recorder := httptest.NewRecorder()
+			signOutPage.WriteSignOutPage(recorder, request, SignOutPageOpts{User: "john.doe@example.com", RedirectURL: "/redirect", Token: "token"})
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("/prefix john.doe@example.com /redirect token en Sign out everywhere"))
+		})
+
+		It("Writes the page in the language of the request", func() {
+			request.Header.Set("Accept-Language", "fr")
+
+			recorder := httptest.NewRecorder()
+			signOutPage.WriteSignOutPage(recorder, request, SignOutPageOpts{User: "john.doe@example.com", RedirectURL: "/redirect", Token: "token"})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(HaveSuffix("fr Se déconnecter partout"))
+		})
+
+		It("Writes an error if the template can't be rendered", func() {
+			tmpl, err := template.New("").Parse("{{.Unknown}}")
+			Expect(err).ToNot(HaveOccurred())
+			signOutPage.template = tmpl
+
+			recorder := httptest.NewRecorder()
+			signOutPage.WriteSignOutPage(recorder, request, SignOutPageOpts{User: "john.doe@example.com", RedirectURL: "/redirect", Token: "token"})
+
+			body, err := ioutil.ReadAll(recorder.Result().Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal(fmt.Sprintf("Internal Server Error | %s", testRequestID)))
+		})
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -14,6 +15,10 @@ import (
 
 const (
 	robotsTxtName = "robots.txt"
+
+	// staticFilesDir is the folder of the custom directory whose files are
+	// served under the static path, eg stylesheets and images of the pages.
+	staticFilesDir = "static"
 )
 
 //go:embed robots.txt
@@ -23,6 +28,10 @@ var defaultRobotsTxt []byte
 type staticPageWriter struct {
 	pageGetter      *pageGetter
 	errorPageWriter *errorPageWriter
+
+	// staticFiles serves the files of the static folder of the custom
+	// directory, or is nil when there is none.
+	staticFiles http.Handler
 }
 
 // WriteRobotsTxt writes the robots.txt content to the response writer.
@@ -30,6 +39,27 @@ func (s *staticPageWriter) WriteRobotsTxt(rw http.ResponseWriter, req *http.Requ
 	s.writePage(rw, req, robotsTxtName)
 }
 
+// WriteStaticFile writes the file of the static folder of the custom
+// directory at the path of the request, which must be relative to the static
+// path. The files are read from the disk for every request, so that changes
+// are served without a restart, and revalidated by browsers before they are
+// used from their cache.
+func (s *staticPageWriter) WriteStaticFile(rw http.ResponseWriter, req *http.Request) {
+	if s.staticFiles == nil {
+		scope := middlewareapi.GetRequestScope(req)
+		s.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
+			Status:         http.StatusNotFound,
+			RequestID:      scope.RequestID,
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+		})
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	s.staticFiles.ServeHTTP(rw, req)
+}
+
 // writePage writes the content of the page to the response writer.
 func (s *staticPageWriter) writePage(rw http.ResponseWriter, req *http.Request, pageName string) {
 	_, err := rw.Write(s.pageGetter.getPage(pageName))
@@ -37,9 +67,10 @@ func (s *staticPageWriter) writePage(rw http.ResponseWriter, req *http.Request,
 		logger.Printf("Error writing %q: %v", pageName, err)
 		scope := middlewareapi.GetRequestScope(req)
 		s.errorPageWriter.WriteErrorPage(rw, ErrorPageOpts{
-			Status:    http.StatusInternalServerError,
-			RequestID: scope.RequestID,
-			AppError:  err.Error(),
+			Status:         http.StatusInternalServerError,
+			RequestID:      scope.RequestID,
+			AppError:       err.Error(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
 		})
 		return
 	}
@@ -54,9 +85,50 @@ func newStaticPageWriter(customDir string, errorWriter *errorPageWriter) (*stati
 	return &staticPageWriter{
 		pageGetter:      pageGetter,
 		errorPageWriter: errorWriter,
+		staticFiles:     staticFilesHandler(customDir),
 	}, nil
 }
 
+// staticFilesHandler serves the files of the static folder of the custom
+// directory, or returns nil when there is none.
+func staticFilesHandler(customDir string) http.Handler {
+	if customDir == "" {
+		return nil
+	}
+	dir := filepath.Join(customDir, staticFilesDir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	return http.FileServer(staticFileSystem{http.Dir(dir)})
+}
+
+// staticFileSystem only serves regular files, so that the contents of the
+// folders and hidden files, eg .git, are not served.
+type staticFileSystem struct {
+	http.FileSystem
+}
+
+// Open opens the regular file with the name, or returns an error that the
+// file does not exist.
+func (fs staticFileSystem) Open(name string) (http.File, error) {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return nil, os.ErrNotExist
+		}
+	}
+
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
 // loadStaticPages loads static page content from the custom directory provided.
 // If any file is not provided in the custom directory, the default will be used
 // instead.
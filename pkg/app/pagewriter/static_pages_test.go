@@ -25,6 +25,7 @@ var _ = Describe("Static Pages", func() {
 		Expect(err).ToNot(HaveOccurred())
 		errorPage = &errorPageWriter{
 			template: errorTmpl,
+			catalogs: testCatalogs,
 		}
 
 		customDir, err = ioutil.TempDir("", "oauth2-proxy-static-pages-test")
@@ -65,6 +66,60 @@ var _ = Describe("Static Pages", func() {
 					Expect(recorder.Result().StatusCode).To(Equal(http.StatusOK))
 				})
 			})
+
+			Context("WriteStaticFile", func() {
+				BeforeEach(func() {
+					staticDir := filepath.Join(customDir, staticFilesDir)
+					Expect(os.MkdirAll(filepath.Join(staticDir, "images"), 0700)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(staticDir, "style.css"), []byte("body {}"), 0400)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(staticDir, ".secret"), []byte("secret"), 0400)).To(Succeed())
+
+					var err error
+					pageWriter, err = newStaticPageWriter(customDir, errorPage)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("Should write the static file", func() {
+					recorder := httptest.NewRecorder()
+					pageWriter.WriteStaticFile(recorder, httptest.NewRequest("", "http://127.0.0.1/style.css", nil))
+
+					Expect(recorder.Result().StatusCode).To(Equal(http.StatusOK))
+					Expect(recorder.Body.String()).To(Equal("body {}"))
+					Expect(recorder.Header().Get("Content-Type")).To(Equal("text/css; charset=utf-8"))
+					Expect(recorder.Header().Get("Cache-Control")).To(Equal("no-cache"))
+				})
+
+				It("Should serve changes to the static file", func() {
+					Expect(os.Chmod(filepath.Join(customDir, staticFilesDir, "style.css"), 0600)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(customDir, staticFilesDir, "style.css"), []byte("body { color: red }"), 0600)).To(Succeed())
+
+					recorder := httptest.NewRecorder()
+					pageWriter.WriteStaticFile(recorder, httptest.NewRequest("", "http://127.0.0.1/style.css", nil))
+					Expect(recorder.Body.String()).To(Equal("body { color: red }"))
+				})
+
+				It("Should not list the folders", func() {
+					for _, path := range []string{"/", "/images/"} {
+						recorder := httptest.NewRecorder()
+						pageWriter.WriteStaticFile(recorder, httptest.NewRequest("", "http://127.0.0.1"+path, nil))
+						Expect(recorder.Result().StatusCode).To(Equal(http.StatusNotFound))
+					}
+				})
+
+				It("Should not write hidden files", func() {
+					recorder := httptest.NewRecorder()
+					pageWriter.WriteStaticFile(recorder, httptest.NewRequest("", "http://127.0.0.1/.secret", nil))
+					Expect(recorder.Result().StatusCode).To(Equal(http.StatusNotFound))
+				})
+
+				It("Should not write files outside of the static folder", func() {
+					recorder := httptest.NewRecorder()
+					req := httptest.NewRequest("", "http://127.0.0.1/", nil)
+					req.URL.Path = "/../" + robotsTxtName
+					pageWriter.WriteStaticFile(recorder, req)
+					Expect(recorder.Body.String()).ToNot(Equal(customRobots))
+				})
+			})
 		})
 
 		Context("Without custom content", func() {
@@ -101,6 +156,16 @@ var _ = Describe("Static Pages", func() {
 					Expect(recorder.Result().StatusCode).To(Equal(http.StatusInternalServerError))
 				})
 			})
+
+			Context("WriteStaticFile", func() {
+				It("Should write a not found error page", func() {
+					recorder := httptest.NewRecorder()
+					pageWriter.WriteStaticFile(recorder, request)
+
+					Expect(recorder.Result().StatusCode).To(Equal(http.StatusNotFound))
+					Expect(recorder.Body.String()).To(Equal("Not Found"))
+				})
+			})
 		})
 	})
 
@@ -14,8 +14,14 @@ import (
 )
 
 const (
-	errorTemplateName  = "error.html"
-	signInTemplateName = "sign_in.html"
+	errorTemplateName   = "error.html"
+	signInTemplateName  = "sign_in.html"
+	consentTemplateName = "consent.html"
+	signOutTemplateName = "sign_out.html"
+
+	// partialsDir is the folder of the custom directory whose templates can
+	// be included by the pages, eg {{template "header.html" .}}.
+	partialsDir = "partials"
 )
 
 //go:embed error.html
@@ -24,15 +30,25 @@ var defaultErrorTemplate string
 //go:embed sign_in.html
 var defaultSignInTemplate string
 
-// loadTemplates adds the Sign In and Error templates from the custom template
+//go:embed consent.html
+var defaultConsentTemplate string
+
+//go:embed sign_out.html
+var defaultSignOutTemplate string
+
+// loadTemplates adds the Sign In, Error, Consent and Sign Out templates from the custom template
 // directory, or uses the defaults if they do not exist or the custom directory
-// is not provided.
+// is not provided. The partials of the custom directory are added first, so
+// that the pages can include them.
 func loadTemplates(customDir string) (*template.Template, error) {
 	t := template.New("").Funcs(template.FuncMap{
 		"ToUpper": strings.ToUpper,
 		"ToLower": strings.ToLower,
 	})
-	var err error
+	t, err := addPartials(t, customDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not add partials: %v", err)
+	}
 	t, err = addTemplate(t, customDir, signInTemplateName, defaultSignInTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("could not add Sign In template: %v", err)
@@ -41,10 +57,35 @@ func loadTemplates(customDir string) (*template.Template, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not add Error template: %v", err)
 	}
+	t, err = addTemplate(t, customDir, consentTemplateName, defaultConsentTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("could not add Consent template: %v", err)
+	}
+	t, err = addTemplate(t, customDir, signOutTemplateName, defaultSignOutTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("could not add Sign Out template: %v", err)
+	}
 
 	return t, nil
 }
 
+// addPartials adds the templates of the partials folder of the custom
+// directory, named after their file names.
+func addPartials(t *template.Template, customDir string) (*template.Template, error) {
+	if customDir == "" {
+		return t, nil
+	}
+	files, err := filepath.Glob(filepath.Join(customDir, partialsDir, "*.html"))
+	if err != nil || len(files) == 0 {
+		return t, err
+	}
+	t, err = t.ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse partials: %v", err)
+	}
+	return t, nil
+}
+
 // addTemplate will add the template from the custom directory if provided,
 // else it will add the default template.
 func addTemplate(t *template.Template, customDir, fileName, defaultTemplate string) (*template.Template, error) {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -40,18 +41,36 @@ var _ = Describe("Templates", func() {
 				ProxyPrefix string
 				Redirect    string
 				Footer      string
+				CSPNonce    string
+				Lang        string
+				Messages    pageMessages
+				Brand       branding
+
+				RedirectSignature string
 
 				// For default sign_in template
-				SignInMessage string
-				ProviderName  string
-				CustomLogin   bool
-				LogoData      string
+				SignInMessage  string
+				ProviderName   string
+				ProviderGroups []signInProviderGroup
+				ProviderSearch bool
+				CustomLogin    bool
+				RememberMe     bool
+				LogoData       string
+				Challenge      *challenge.Page
 
 				// For default error template
 				StatusCode int
 				Title      string
 				Message    string
 				RequestID  string
+				ErrorCode  string
+
+				// For default consent template
+				Terms string
+				Token string
+
+				// For default sign-out template
+				User string
 
 				// For custom templates
 				TestString string
@@ -59,16 +78,45 @@ var _ = Describe("Templates", func() {
 				ProxyPrefix: "<proxy-prefix>",
 				Redirect:    "<redirect>",
 				Footer:      "<footer>",
+				CSPNonce:    "<csp-nonce>",
+				Lang:        "de",
+				Messages:    testCatalogs.forAcceptLanguage("de"),
+				Brand: branding{
+					Color:       "#0078d4",
+					FooterLinks: []FooterLink{{Label: "<label>", URL: "https://example.com/privacy"}},
+				},
+
+				RedirectSignature: "<redirect-signature>",
 
 				SignInMessage: "<sign-in-message>",
 				ProviderName:  "<provider-name>",
-				CustomLogin:   false,
-				LogoData:      "<logo>",
+				ProviderGroups: []signInProviderGroup{{
+					Name: "<group>",
+					Providers: []signInProviderButton{{
+						SignInProvider: SignInProvider{ID: "<id>", Name: "<provider-name>", Type: "github"},
+						LastUsed:       true,
+					}},
+				}},
+				ProviderSearch: true,
+				CustomLogin:    false,
+				RememberMe:     true,
+				LogoData:       "<logo>",
+				Challenge: &challenge.Page{
+					Type:       "proof-of-work",
+					Token:      "<token>",
+					Difficulty: 16,
+				},
 
 				StatusCode: 404,
 				Title:      "<title>",
 				Message:    "<message>",
 				RequestID:  "<request-id>",
+				ErrorCode:  "<error-code>",
+
+				Terms: "<terms>",
+				Token: "<token>",
+
+				User: "<user>",
 
 				TestString: "Testing",
 			}
@@ -85,12 +133,43 @@ var _ = Describe("Templates", func() {
 				buf := bytes.NewBuffer([]byte{})
 				Expect(t.ExecuteTemplate(buf, signInTemplateName, data)).To(Succeed())
 				Expect(buf.String()).To(HavePrefix("\n<!DOCTYPE html>"))
+				Expect(buf.String()).To(ContainSubstring(`<input type="hidden" name="pow_token" value="&lt;token&gt;">`))
+				Expect(buf.String()).To(ContainSubstring(`var token = "\u003ctoken\u003e";`))
+				Expect(buf.String()).To(ContainSubstring(`<p class="heading">&lt;group&gt;</p>`))
+				Expect(buf.String()).To(ContainSubstring(`<button type="submit" class="button is-fullwidth provider-github" name="provider" value="&lt;id&gt;" autofocus>Mit &lt;provider-name&gt; anmelden</button>`))
+				Expect(buf.String()).To(ContainSubstring(`id="provider-search"`))
+				Expect(buf.String()).To(ContainSubstring(`<input type="checkbox" name="remember_me" value="true">
+              Angemeldet bleiben`))
+				Expect(buf.String()).To(ContainSubstring(`<html lang="de" charset="utf-8">`))
+				Expect(buf.String()).To(ContainSubstring(`.button.is-primary, .button.is-primary:hover { background-color: #0078d4; }`))
+				Expect(buf.String()).To(ContainSubstring(`<a href="https://example.com/privacy" class="has-text-grey">&lt;label&gt;</a>`))
 			})
 
 			It("Use the default error page", func() {
 				buf := bytes.NewBuffer([]byte{})
 				Expect(t.ExecuteTemplate(buf, errorTemplateName, data)).To(Succeed())
 				Expect(buf.String()).To(HavePrefix("\n<!DOCTYPE html>"))
+				Expect(buf.String()).To(ContainSubstring(`Anfrage-ID: &lt;request-id&gt;`))
+				Expect(buf.String()).To(ContainSubstring(`<a href="https://example.com/privacy" class="has-text-grey">&lt;label&gt;</a>`))
+			})
+
+			It("Use the default consent page", func() {
+				buf := bytes.NewBuffer([]byte{})
+				Expect(t.ExecuteTemplate(buf, consentTemplateName, data)).To(Succeed())
+				Expect(buf.String()).To(HavePrefix("\n<!DOCTYPE html>"))
+				Expect(buf.String()).To(ContainSubstring(`<title>Nutzungsbedingungen</title>`))
+				Expect(buf.String()).To(ContainSubstring(`&lt;terms&gt;`))
+				Expect(buf.String()).To(ContainSubstring(`<input type="hidden" name="token" value="&lt;token&gt;">`))
+				Expect(buf.String()).To(ContainSubstring(`<form method="POST" action="%3cproxy-prefix%3e/consent">`))
+			})
+
+			It("Use the default sign-out page", func() {
+				buf := bytes.NewBuffer([]byte{})
+				Expect(t.ExecuteTemplate(buf, signOutTemplateName, data)).To(Succeed())
+				Expect(buf.String()).To(HavePrefix("\n<!DOCTYPE html>"))
+				Expect(buf.String()).To(ContainSubstring(`Angemeldet als &lt;user&gt;`))
+				Expect(buf.String()).To(ContainSubstring(`<input type="hidden" name="token" value="&lt;token&gt;">`))
+				Expect(buf.String()).To(ContainSubstring(`<form class="block" method="POST" action="%3cproxy-prefix%3e/sign_out_everywhere">`))
 			})
 		})
 
@@ -159,6 +238,59 @@ var _ = Describe("Templates", func() {
 				})
 			})
 
+			Context("With a consent template", func() {
+				BeforeEach(func() {
+					consentFile := filepath.Join(customDir, consentTemplateName)
+					Expect(ioutil.WriteFile(consentFile, []byte(`{{.TestString}}`), 0600)).To(Succeed())
+
+					var err error
+					t, err = loadTemplates(customDir)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("Use the custom consent page", func() {
+					buf := bytes.NewBuffer([]byte{})
+					Expect(t.ExecuteTemplate(buf, consentTemplateName, data)).To(Succeed())
+					Expect(buf.String()).To(Equal("Testing"))
+				})
+			})
+
+			Context("With partials", func() {
+				BeforeEach(func() {
+					partials := filepath.Join(customDir, partialsDir)
+					Expect(os.Mkdir(partials, 0700)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(partials, "header.html"), []byte(`<h1>{{.TestString}}</h1>`), 0600)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(partials, "footer.html"), []byte(`{{define "footer"}}<p>{{.TestString | ToLower}}</p>{{end}}`), 0600)).To(Succeed())
+
+					signInFile := filepath.Join(customDir, signInTemplateName)
+					Expect(ioutil.WriteFile(signInFile, []byte(`{{template "header.html" .}}{{template "footer" .}}`), 0600)).To(Succeed())
+
+					var err error
+					t, err = loadTemplates(customDir)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("Includes the partials in the custom sign_in page", func() {
+					buf := bytes.NewBuffer([]byte{})
+					Expect(t.ExecuteTemplate(buf, signInTemplateName, data)).To(Succeed())
+					Expect(buf.String()).To(Equal("<h1>Testing</h1><p>testing</p>"))
+				})
+			})
+
+			Context("With an invalid partial", func() {
+				BeforeEach(func() {
+					partials := filepath.Join(customDir, partialsDir)
+					Expect(os.Mkdir(partials, 0700)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(partials, "header.html"), []byte("{{"), 0600)).To(Succeed())
+				})
+
+				It("Should return an error when loading templates", func() {
+					t, err := loadTemplates(customDir)
+					Expect(err).To(MatchError(HavePrefix("could not add partials:")))
+					Expect(t).To(BeNil())
+				})
+			})
+
 			Context("With an invalid sign_in template", func() {
 				BeforeEach(func() {
 					signInFile := filepath.Join(customDir, signInTemplateName)
@@ -0,0 +1,110 @@
+package redirect
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// HostPlaceholder is replaced by the host of the request in a redirect URL
+// template.
+const HostPlaceholder = "{host}"
+
+// CallbackURLs selects the OAuth redirect URL for the host of a request, so
+// that a single deployment can serve several domains.
+// A redirect URL given for the host takes precedence over the template.
+type CallbackURLs struct {
+	hosts    []hostCallbackURL
+	template string
+}
+
+// hostCallbackURL is the redirect URL of the hosts that match the domain.
+type hostCallbackURL struct {
+	domain      string
+	callbackURL *url.URL
+}
+
+// NewCallbackURLs parses the redirect URLs of hosts, given as
+// `<domain>=<url>`, and the redirect URL template.
+// Domains prefixed with `.` or `*.` also match subdomains.
+func NewCallbackURLs(hosts []string, template string) (*CallbackURLs, error) {
+	c := &CallbackURLs{template: template}
+
+	for _, host := range hosts {
+		parts := strings.SplitN(host, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid redirect URL host %q: must be of the form <domain>=<url>", host)
+		}
+		callbackURL, err := parseCallbackURL(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect URL for host %q: %v", parts[0], err)
+		}
+		c.hosts = append(c.hosts, hostCallbackURL{domain: strings.ToLower(parts[0]), callbackURL: callbackURL})
+	}
+
+	if template != "" {
+		if !strings.Contains(template, HostPlaceholder) {
+			return nil, fmt.Errorf("invalid redirect URL template %q: must contain %s", template, HostPlaceholder)
+		}
+		if _, err := parseCallbackURL(strings.ReplaceAll(template, HostPlaceholder, "example.com")); err != nil {
+			return nil, fmt.Errorf("invalid redirect URL template %q: %v", template, err)
+		}
+	}
+	return c, nil
+}
+
+// parseCallbackURL parses an absolute redirect URL.
+func parseCallbackURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute http or https URL", raw)
+	}
+	return u, nil
+}
+
+// Get returns the redirect URL for the host of the request, which may include
+// a port. It returns false when no redirect URL is configured for the host.
+func (c *CallbackURLs) Get(host string) (*url.URL, bool) {
+	if c == nil || host == "" {
+		return nil, false
+	}
+
+	hostname := strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = h
+	}
+	for _, h := range c.hosts {
+		if matchesDomain(hostname, h.domain) {
+			u := *h.callbackURL
+			return &u, true
+		}
+	}
+
+	// The host may come from a forwarded header, so it must not be able to
+	// change any part of the URL other than the host
+	if c.template != "" && !strings.ContainsAny(host, "/\\?#@ ") {
+		u, err := parseCallbackURL(strings.ReplaceAll(c.template, HostPlaceholder, host))
+		if err != nil {
+			return nil, false
+		}
+		return u, true
+	}
+	return nil, false
+}
+
+// matchesDomain determines whether the hostname is the domain, or a subdomain
+// of a domain prefixed with `.` or `*.`.
+func matchesDomain(hostname, domain string) bool {
+	switch {
+	case strings.HasPrefix(domain, "*."):
+		return hostname == domain[2:] || strings.HasSuffix(hostname, domain[1:])
+	case strings.HasPrefix(domain, "."):
+		return hostname == domain[1:] || strings.HasSuffix(hostname, domain)
+	default:
+		return hostname == domain
+	}
+}
@@ -0,0 +1,48 @@
+package redirect
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CallbackURLs", func() {
+	hosts := []string{
+		"vanity.example.org=https://vanity.example.org/oauth2/callback",
+		"*.tenants.example.com=https://auth.tenants.example.com/oauth2/callback",
+	}
+
+	DescribeTable("Get",
+		func(template, host, expected string) {
+			c, err := NewCallbackURLs(hosts, template)
+			Expect(err).ToNot(HaveOccurred())
+
+			u, ok := c.Get(host)
+			if expected == "" {
+				Expect(ok).To(BeFalse())
+				return
+			}
+			Expect(ok).To(BeTrue())
+			Expect(u.String()).To(Equal(expected))
+		},
+		Entry("with a host", "", "vanity.example.org", "https://vanity.example.org/oauth2/callback"),
+		Entry("with a host and port", "", "Vanity.example.org:8443", "https://vanity.example.org/oauth2/callback"),
+		Entry("with a wildcard domain", "", "a.tenants.example.com", "https://auth.tenants.example.com/oauth2/callback"),
+		Entry("with an unknown host", "", "other.example.net", ""),
+		Entry("with the template", "https://{host}/oauth2/callback", "other.example.net", "https://other.example.net/oauth2/callback"),
+		Entry("with a host taking precedence over the template", "https://{host}/oauth2/callback", "vanity.example.org", "https://vanity.example.org/oauth2/callback"),
+		Entry("with a host that would change the path of the template", "https://{host}/oauth2/callback", "evil.example.net/path?", ""),
+		Entry("with a host that would change the user of the template", "https://{host}/oauth2/callback", "evil.example.net@other.example.net", ""),
+	)
+
+	DescribeTable("NewCallbackURLs returns errors",
+		func(hosts []string, template, expected string) {
+			_, err := NewCallbackURLs(hosts, template)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("without a URL", []string{"example.org"}, "", `invalid redirect URL host "example.org": must be of the form <domain>=<url>`),
+		Entry("with a relative URL", []string{"example.org=/oauth2/callback"}, "", `invalid redirect URL for host "example.org": "/oauth2/callback" is not an absolute http or https URL`),
+		Entry("with a template without the host", nil, "https://example.org/oauth2/callback", `invalid redirect URL template "https://example.org/oauth2/callback": must contain {host}`),
+		Entry("with a relative template", nil, "{host}/oauth2/callback", `invalid redirect URL template "{host}/oauth2/callback": "example.com/oauth2/callback" is not an absolute http or https URL`),
+	)
+})
@@ -18,6 +18,10 @@ type AppDirector interface {
 type AppDirectorOpts struct {
 	ProxyPrefix string
 	Validator   Validator
+
+	// Signer, when set, requires the `rd` parameter to be signed, unless it
+	// is an absolute URL in the allowlist of the validator.
+	Signer *Signer
 }
 
 // NewAppDirector constructs a new AppDirector for getting the application
@@ -31,6 +35,7 @@ func NewAppDirector(opts AppDirectorOpts) AppDirector {
 	return &appDirector{
 		proxyPrefix: prefix,
 		validator:   opts.Validator,
+		signer:      opts.Signer,
 	}
 }
 
@@ -38,6 +43,7 @@ func NewAppDirector(opts AppDirectorOpts) AppDirector {
 type appDirector struct {
 	proxyPrefix string
 	validator   Validator
+	signer      *Signer
 }
 
 // GetRedirect determines the full URL or URI path to redirect clients to once
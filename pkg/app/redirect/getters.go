@@ -3,7 +3,9 @@ package redirect
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 )
 
@@ -13,13 +15,27 @@ type redirectGetter func(req *http.Request) string
 
 // getRdQuerystringRedirect handles this getAppRedirect strategy:
 // - `rd` querysting parameter
+// When redirects are signed, unsigned redirects must be absolute URLs, which
+// are only valid when they are in the allowlist of the validator.
 func (a *appDirector) getRdQuerystringRedirect(req *http.Request) string {
+	redirect := req.Form.Get("rd")
+	if a.signer != nil && redirect != "" && !a.signer.Verify(redirect, req.Form.Get(SignatureParam)) && !isAbsoluteURL(redirect) {
+		logger.Errorf("Unsigned relative redirect provided in rd querystring parameter: %s", redirect)
+		return ""
+	}
+
 	return a.validateRedirect(
-		req.Form.Get("rd"),
+		redirect,
 		"Invalid redirect provided in rd querystring parameter: %s",
 	)
 }
 
+// isAbsoluteURL determines whether the redirect is an absolute http or https
+// URL.
+func isAbsoluteURL(redirect string) bool {
+	return strings.HasPrefix(redirect, "http://") || strings.HasPrefix(redirect, "https://")
+}
+
 // getXAuthRequestRedirect handles this getAppRedirect strategy:
 // - `X-Auth-Request-Redirect` Header
 func (a *appDirector) getXAuthRequestRedirect(req *http.Request) string {
@@ -0,0 +1,199 @@
+package redirect
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	util "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// NewPolicyValidator constructs a new redirect validator that also allows
+// redirects to absolute URLs matching the rules of the redirect policy.
+func NewPolicyValidator(allowedDomains []string, policy *options.RedirectPolicy) (Validator, error) {
+	v := &validator{
+		allowedDomains: allowedDomains,
+	}
+	if policy == nil {
+		return v, nil
+	}
+
+	for i, rule := range policy.Rules {
+		r, err := newRedirectRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect policy rule %d: %v", i, err)
+		}
+		v.rules = append(v.rules, r)
+	}
+	return v, nil
+}
+
+// portRange is an inclusive range of ports.
+type portRange struct {
+	from int
+	to   int
+}
+
+// redirectRule is a compiled options.RedirectRule.
+type redirectRule struct {
+	domain       string
+	schemes      []string
+	ports        []portRange
+	pathPrefixes []string
+	regex        *regexp.Regexp
+}
+
+// newRedirectRule compiles the redirect rule, checking that its conditions
+// are valid.
+func newRedirectRule(rule options.RedirectRule) (*redirectRule, error) {
+	if rule.Domain == "" && rule.Regex == "" {
+		return nil, fmt.Errorf("a domain or a regex is required")
+	}
+	if strings.Contains(rule.Domain, ":") {
+		return nil, fmt.Errorf("domain %q must not have a port, use ports instead", rule.Domain)
+	}
+
+	r := &redirectRule{
+		domain:       rule.Domain,
+		schemes:      rule.Schemes,
+		pathPrefixes: rule.PathPrefixes,
+	}
+	if len(r.schemes) == 0 {
+		r.schemes = []string{"http", "https"}
+	}
+	for _, scheme := range r.schemes {
+		if scheme != "http" && scheme != "https" {
+			return nil, fmt.Errorf("scheme %q must be http or https", scheme)
+		}
+	}
+
+	for _, port := range rule.Ports {
+		pr, err := parsePortRange(port)
+		if err != nil {
+			return nil, err
+		}
+		r.ports = append(r.ports, pr)
+	}
+
+	for _, prefix := range rule.PathPrefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix %q must start with /", prefix)
+		}
+	}
+
+	if rule.Regex != "" {
+		regex, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", rule.Regex, err)
+		}
+		r.regex = regex
+	}
+	return r, nil
+}
+
+// parsePortRange parses a port, eg `8443`, or an inclusive range of ports, eg
+// `8000-8999`.
+func parsePortRange(port string) (portRange, error) {
+	from, to := port, port
+	if i := strings.Index(port, "-"); i >= 0 {
+		from, to = port[:i], port[i+1:]
+	}
+
+	fromPort, fromErr := strconv.Atoi(from)
+	toPort, toErr := strconv.Atoi(to)
+	if fromErr != nil || toErr != nil || fromPort < 1 || toPort > 65535 || fromPort > toPort {
+		return portRange{}, fmt.Errorf("invalid port or port range %q", port)
+	}
+	return portRange{from: fromPort, to: toPort}, nil
+}
+
+// matches determines whether the redirect URL matches all of the conditions
+// of the rule.
+func (r *redirectRule) matches(redirect string, redirectURL *url.URL) bool {
+	if r.regex != nil && !r.regex.MatchString(redirect) {
+		return false
+	}
+	// The port of the redirect is matched separately, so only its hostname
+	// is matched against the domain
+	if r.domain != "" && !util.IsEndpointAllowed(&url.URL{Host: redirectURL.Hostname()}, []string{r.domain}) {
+		return false
+	}
+	return r.matchesScheme(redirectURL.Scheme) &&
+		r.matchesPort(redirectURL) &&
+		r.matchesPath(redirectURL.Path)
+}
+
+// matchesScheme determines whether the scheme is one of the rule's schemes.
+func (r *redirectRule) matchesScheme(scheme string) bool {
+	for _, s := range r.schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPort determines whether the port of the redirect URL, or the default
+// port of its scheme, is one of the rule's ports.
+func (r *redirectRule) matchesPort(redirectURL *url.URL) bool {
+	port := redirectURL.Port()
+	if len(r.ports) == 0 {
+		return port == "" || port == defaultPort(redirectURL.Scheme)
+	}
+	if port == "" {
+		port = defaultPort(redirectURL.Scheme)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, pr := range r.ports {
+		if p >= pr.from && p <= pr.to {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPath determines whether the cleaned path starts with one of the
+// rule's path prefixes.
+func (r *redirectRule) matchesPath(redirectPath string) bool {
+	if len(r.pathPrefixes) == 0 {
+		return true
+	}
+
+	cleaned := path.Clean("/" + redirectPath)
+	if strings.HasSuffix(redirectPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	for _, prefix := range r.pathPrefixes {
+		if strings.HasPrefix(cleaned, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPort returns the default port of the scheme.
+func defaultPort(scheme string) string {
+	if strings.EqualFold(scheme, "http") {
+		return "80"
+	}
+	return "443"
+}
+
+// isAllowedByPolicy determines whether the absolute redirect URL matches any
+// of the rules of the redirect policy.
+func (v *validator) isAllowedByPolicy(redirect string, redirectURL *url.URL) bool {
+	for _, rule := range v.rules {
+		if rule.matches(redirect, redirectURL) {
+			return true
+		}
+	}
+	return false
+}
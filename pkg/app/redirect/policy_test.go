@@ -0,0 +1,76 @@
+package redirect
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redirect Policy suite", func() {
+	policy := &options.RedirectPolicy{
+		Rules: []options.RedirectRule{
+			{
+				Domain:       "app.example.com",
+				Schemes:      []string{"https"},
+				PathPrefixes: []string{"/dashboard/", "/reports"},
+			},
+			{
+				Domain: ".dev.example.com",
+				Ports:  []string{"8443", "9000-9099"},
+			},
+			{
+				Regex: `https://[a-z0-9-]+\.apps\.example\.com/.*`,
+			},
+		},
+	}
+
+	DescribeTable("IsValidRedirect",
+		func(redirect string, expected bool) {
+			validator, err := NewPolicyValidator([]string{"whitelisted.example.com"}, policy)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validator.IsValidRedirect(redirect)).To(Equal(expected))
+		},
+		Entry("a whitelisted domain", "http://whitelisted.example.com/path", true),
+		Entry("a relative redirect", "/path", true),
+		Entry("a matching path prefix", "https://app.example.com/dashboard/1", true),
+		Entry("a matching path prefix without a trailing slash", "https://app.example.com/reports-2022", true),
+		Entry("a path prefix without its trailing slash", "https://app.example.com/dashboard", false),
+		Entry("a path escaping its prefix", "https://app.example.com/dashboard/../admin", false),
+		Entry("a path outside the prefixes", "https://app.example.com/admin", false),
+		Entry("a scheme that is not allowed", "http://app.example.com/dashboard/1", false),
+		Entry("the default port of the scheme", "https://app.example.com:443/dashboard/1", true),
+		Entry("a port of a rule without ports", "https://app.example.com:8443/dashboard/1", false),
+		Entry("a port of a rule", "http://api.dev.example.com:8443/", true),
+		Entry("a port in a range of a rule", "https://api.dev.example.com:9050/", true),
+		Entry("a port outside the ports of a rule", "https://api.dev.example.com:9100/", false),
+		Entry("the default port outside the ports of a rule", "https://api.dev.example.com/", false),
+		Entry("a matching regex", "https://team-1.apps.example.com/home", true),
+		Entry("a regex matching part of the URL", "https://evil.com/?https://team-1.apps.example.com/home", false),
+		Entry("a regex matching the start of the URL", "https://team-1.apps.example.com.evil.com/", false),
+	)
+
+	DescribeTable("NewPolicyValidator",
+		func(rule options.RedirectRule, expectedErr string) {
+			_, err := NewPolicyValidator(nil, &options.RedirectPolicy{Rules: []options.RedirectRule{rule}})
+			if expectedErr == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("a valid rule", options.RedirectRule{Domain: "example.com", Ports: []string{"8000-8999"}}, ""),
+		Entry("without a domain or regex", options.RedirectRule{Schemes: []string{"https"}},
+			"invalid redirect policy rule 0: a domain or a regex is required"),
+		Entry("with a port in the domain", options.RedirectRule{Domain: "example.com:8080"},
+			"invalid redirect policy rule 0: domain \"example.com:8080\" must not have a port, use ports instead"),
+		Entry("with an invalid scheme", options.RedirectRule{Domain: "example.com", Schemes: []string{"ftp"}},
+			"invalid redirect policy rule 0: scheme \"ftp\" must be http or https"),
+		Entry("with an invalid port range", options.RedirectRule{Domain: "example.com", Ports: []string{"9000-8000"}},
+			"invalid redirect policy rule 0: invalid port or port range \"9000-8000\""),
+		Entry("with a relative path prefix", options.RedirectRule{Domain: "example.com", PathPrefixes: []string{"app"}},
+			"invalid redirect policy rule 0: path prefix \"app\" must start with /"),
+		Entry("with an invalid regex", options.RedirectRule{Regex: "https://("},
+			"invalid redirect policy rule 0: invalid regex \"https://(\": error parsing regexp: missing closing ): `^(?:https://()$`"),
+	)
+})
@@ -0,0 +1,51 @@
+package redirect
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// SignatureParam is the parameter of the signature of the `rd` parameter of
+// the sign-in links generated by the proxy.
+const SignatureParam = "rd_sig"
+
+// Signer signs the redirects of the sign-in links generated by the proxy, so
+// that they cannot be tampered with before the user returns.
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner constructs a Signer of redirects with the secret, which is not
+// copied so that it is wiped with the options.
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Sign returns the signature of the redirect.
+// The fragment of the redirect is not signed, as it is added by the browser
+// of the user, from the URL of the page they signed in from.
+// It returns an empty signature when the signer is nil, so that signing can
+// be disabled.
+// NOTE: Error checking (G104) is purposefully skipped:
+// `hash.Hash` interface's `Write` has an error signature, but
+// `hmac.hmac.Write` does not use it.
+/* #nosec G104 */
+func (s *Signer) Sign(redirect string) string {
+	if s == nil {
+		return ""
+	}
+
+	if i := strings.Index(redirect, "#"); i >= 0 {
+		redirect = redirect[:i]
+	}
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte("rd|" + redirect))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks that the signature is the signature of the redirect.
+func (s *Signer) Verify(redirect, signature string) bool {
+	return signature != "" && hmac.Equal([]byte(signature), []byte(s.Sign(redirect)))
+}
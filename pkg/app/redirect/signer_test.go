@@ -0,0 +1,84 @@
+package redirect
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signer Suite", func() {
+	signer := NewSigner([]byte("secret"))
+
+	It("verifies signatures of the redirect without its fragment", func() {
+		signature := signer.Sign("/foo?bar")
+		Expect(signer.Verify("/foo?bar", signature)).To(BeTrue())
+		Expect(signer.Verify("/foo?bar#baz", signature)).To(BeTrue())
+		Expect(signer.Verify("/foo?baz", signature)).To(BeFalse())
+		Expect(signer.Verify("/foo?bar", "")).To(BeFalse())
+		Expect(NewSigner([]byte("other")).Verify("/foo?bar", signature)).To(BeFalse())
+	})
+
+	It("does not sign redirects when it is nil", func() {
+		var nilSigner *Signer
+		Expect(nilSigner.Sign("/foo")).To(BeEmpty())
+	})
+
+	type signedRedirectTableInput struct {
+		rd               string
+		signature        string
+		expectedRedirect string
+	}
+
+	DescribeTable("GetRedirect with signed redirects",
+		func(in signedRedirectTableInput) {
+			appDirector := NewAppDirector(AppDirectorOpts{
+				ProxyPrefix: testProxyPrefix,
+				Validator:   NewValidator([]string{"allowed.example.com"}),
+				Signer:      signer,
+			})
+
+			params := url.Values{"rd": {in.rd}}
+			if in.signature != "" {
+				params.Set(SignatureParam, in.signature)
+			}
+			req, _ := http.NewRequest("GET", testProxyPrefix+"/start?"+params.Encode(), nil)
+			req = middleware.AddRequestScope(req, &middleware.RequestScope{})
+
+			redirect, err := appDirector.GetRedirect(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(redirect).To(Equal(in.expectedRedirect))
+		},
+		Entry("with a signed relative redirect", signedRedirectTableInput{
+			rd:               "/foo/bar",
+			signature:        signer.Sign("/foo/bar"),
+			expectedRedirect: "/foo/bar",
+		}),
+		Entry("with a signed relative redirect and a fragment", signedRedirectTableInput{
+			rd:               "/foo/bar#baz",
+			signature:        signer.Sign("/foo/bar"),
+			expectedRedirect: "/foo/bar#baz",
+		}),
+		Entry("with an unsigned relative redirect", signedRedirectTableInput{
+			rd:               "/foo/bar",
+			expectedRedirect: "/",
+		}),
+		Entry("with a tampered relative redirect", signedRedirectTableInput{
+			rd:               "/foo/baz",
+			signature:        signer.Sign("/foo/bar"),
+			expectedRedirect: "/",
+		}),
+		Entry("with an unsigned allowlisted redirect", signedRedirectTableInput{
+			rd:               "https://allowed.example.com/foo",
+			expectedRedirect: "https://allowed.example.com/foo",
+		}),
+		Entry("with a signed redirect that is not allowlisted", signedRedirectTableInput{
+			rd:               "https://evil.example.com/foo",
+			signature:        signer.Sign("https://evil.example.com/foo"),
+			expectedRedirect: "/",
+		}),
+	)
+})
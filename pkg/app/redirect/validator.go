@@ -35,6 +35,7 @@ func NewValidator(allowedDomains []string) Validator {
 // of redirect URLs.
 type validator struct {
 	allowedDomains []string
+	rules          []*redirectRule
 }
 
 // IsValidRedirect checks whether the redirect URL is safe and allowed.
@@ -53,7 +54,7 @@ func (v *validator) IsValidRedirect(redirect string) bool {
 			return false
 		}
 
-		if util.IsEndpointAllowed(redirectURL, v.allowedDomains) {
+		if util.IsEndpointAllowed(redirectURL, v.allowedDomains) || v.isAllowedByPolicy(redirect, redirectURL) {
 			return true
 		}
 
@@ -0,0 +1,169 @@
+package assertion
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// Header is the request header of the assertions sent to upstreams
+	Header = "X-Auth-Request-Jwt-Assertion"
+
+	// Lifetime is how long an assertion is valid for after it is issued
+	Lifetime = 5 * time.Minute
+)
+
+// Signer signs assertions of the identity of the users of sessions, as JWTs
+// that upstreams verify with the keys published as a JWKS.
+type Signer struct {
+	signer jose.Signer
+	key    jose.JSONWebKey
+	issuer string
+}
+
+// claims are the claims of an assertion.
+type claims struct {
+	jwt.Claims
+	Email             string   `json:"email,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
+}
+
+// NewSigner creates a Signer of assertions with the PEM encoded private key,
+// an RSA, ECDSA or Ed25519 key, and the issuer.
+// The ID of the key is its JWK thumbprint (RFC 7638), so that it changes when
+// the key is rotated.
+func NewSigner(keyPEM []byte, issuer string) (*Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var alg jose.SignatureAlgorithm
+	var public crypto.PublicKey
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		alg, public = jose.RS256, k.Public()
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			alg = jose.ES256
+		case elliptic.P384():
+			alg = jose.ES384
+		case elliptic.P521():
+			alg = jose.ES512
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+		public = k.Public()
+	case ed25519.PrivateKey:
+		alg, public = jose.EdDSA, k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	jwk := jose.JSONWebKey{Key: public, Algorithm: string(alg), Use: "sig"}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute the key ID: %v", err)
+	}
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: jwk.KeyID}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create signer: %v", err)
+	}
+
+	return &Signer{
+		signer: signer,
+		key:    jwk,
+		issuer: issuer,
+	}, nil
+}
+
+// LoadSigner creates a Signer of assertions with the PEM encoded private key
+// of the file and the issuer.
+func LoadSigner(keyFile, issuer string) (*Signer, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %v", err)
+	}
+	return NewSigner(keyPEM, issuer)
+}
+
+// parsePrivateKey parses a PKCS #8, PKCS #1 or SEC 1 private key.
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("could not parse private key: must be a PKCS #8, PKCS #1 or SEC 1 key")
+}
+
+// Sign returns an assertion of the identity of the user of the session for
+// the audience, issued at now.
+func (s *Signer) Sign(session *sessions.SessionState, audience string, now time.Time) (string, error) {
+	c := claims{
+		Claims: jwt.Claims{
+			Issuer:   s.issuer,
+			Subject:  session.User,
+			Audience: jwt.Audience{audience},
+			IssuedAt: jwt.NewNumericDate(now),
+			// The assertion is not valid before it is issued
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(now.Add(Lifetime)),
+		},
+		Email:             session.Email,
+		PreferredUsername: session.PreferredUsername,
+		Groups:            session.Groups,
+	}
+	return jwt.Signed(s.signer).Claims(c).CompactSerialize()
+}
+
+// KeySet returns the public key of the assertions as a JWKS.
+func (s *Signer) KeySet() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{s.key}}
+}
+
+// JWKSHandler serves the public key of the assertions as a JWKS, which may be
+// cached for a short time so that rotated keys are picked up.
+func (s *Signer) JWKSHandler() http.Handler {
+	body, err := json.Marshal(s.KeySet())
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err != nil {
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "public, max-age=300")
+		_, _ = rw.Write(body)
+	})
+}
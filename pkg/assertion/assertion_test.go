@@ -0,0 +1,120 @@
+package assertion
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var _ = Describe("Signer", func() {
+	session := &sessions.SessionState{
+		User:              "123456789",
+		Email:             "john@example.com",
+		PreferredUsername: "john",
+		Groups:            []string{"admins", "developers"},
+	}
+	now := time.Unix(1609366421, 0)
+
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	DescribeTable("signs assertions that verify with the JWKS",
+		func(keyPEM func() []byte, alg jose.SignatureAlgorithm) {
+			signer, err := NewSigner(keyPEM(), "oauth2-proxy")
+			Expect(err).ToNot(HaveOccurred())
+
+			assertion, err := signer.Sign(session, "app", now)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Upstreams verify the assertions with the keys of the JWKS endpoint
+			rw := httptest.NewRecorder()
+			signer.JWKSHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/jwks", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
+			var keySet jose.JSONWebKeySet
+			Expect(json.Unmarshal(rw.Body.Bytes(), &keySet)).To(Succeed())
+			Expect(keySet.Keys).To(HaveLen(1))
+			Expect(keySet.Keys[0].IsPublic()).To(BeTrue())
+			Expect(keySet.Keys[0].Algorithm).To(Equal(string(alg)))
+
+			token, err := jwt.ParseSigned(assertion)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token.Headers).To(HaveLen(1))
+			Expect(token.Headers[0].Algorithm).To(Equal(string(alg)))
+			Expect(keySet.Key(token.Headers[0].KeyID)).To(HaveLen(1))
+
+			var c claims
+			Expect(token.Claims(keySet.Keys[0].Key, &c)).To(Succeed())
+			Expect(c.Claims.Validate(jwt.Expected{
+				Issuer:   "oauth2-proxy",
+				Audience: jwt.Audience{"app"},
+				Time:     now.Add(time.Minute),
+			})).To(Succeed())
+			Expect(c.Claims.Validate(jwt.Expected{Time: now.Add(Lifetime + 2*time.Minute)})).To(MatchError(jwt.ErrExpired))
+			Expect(c.Claims.Subject).To(Equal("123456789"))
+			Expect(c.Email).To(Equal("john@example.com"))
+			Expect(c.PreferredUsername).To(Equal("john"))
+			Expect(c.Groups).To(Equal([]string{"admins", "developers"}))
+		},
+		Entry("with an RSA key", func() []byte {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ToNot(HaveOccurred())
+			return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		}, jose.RS256),
+		Entry("with an ECDSA key", func() []byte {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			der, err := x509.MarshalECPrivateKey(key)
+			Expect(err).ToNot(HaveOccurred())
+			return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		}, jose.ES256),
+		Entry("with an Ed25519 key", func() []byte {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			return pkcs8(key)
+		}, jose.EdDSA),
+	)
+
+	It("identifies the key by its thumbprint", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		first, err := NewSigner(pkcs8(key), "oauth2-proxy")
+		Expect(err).ToNot(HaveOccurred())
+		second, err := NewSigner(pkcs8(key), "oauth2-proxy")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first.KeySet().Keys[0].KeyID).ToNot(BeEmpty())
+		Expect(first.KeySet().Keys[0].KeyID).To(Equal(second.KeySet().Keys[0].KeyID))
+
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		third, err := NewSigner(pkcs8(other), "oauth2-proxy")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(third.KeySet().Keys[0].KeyID).ToNot(Equal(first.KeySet().Keys[0].KeyID))
+	})
+
+	It("rejects data that is not a PEM encoded private key", func() {
+		_, err := NewSigner([]byte("not a key"), "oauth2-proxy")
+		Expect(err).To(MatchError("no PEM encoded private key found"))
+
+		_, err = NewSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")}), "oauth2-proxy")
+		Expect(err).To(MatchError("could not parse private key: must be a PKCS #8, PKCS #1 or SEC 1 key"))
+	})
+})
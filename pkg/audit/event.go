@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// jsonEvent is the JSON representation of an auth event, which is sent to the
+// webhook and is the value of the messages produced to Kafka.
+type jsonEvent struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	RequestID     string            `json:"request_id,omitempty"`
+	Client        string            `json:"client,omitempty"`
+	Host          string            `json:"host,omitempty"`
+	Protocol      string            `json:"protocol,omitempty"`
+	RequestMethod string            `json:"request_method,omitempty"`
+	RequestURI    string            `json:"request_uri,omitempty"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	User          string            `json:"user,omitempty"`
+	Provider      string            `json:"provider,omitempty"`
+	Status        logger.AuthStatus `json:"status"`
+	Event         string            `json:"event,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	ACR           string            `json:"acr,omitempty"`
+	AMR           []string          `json:"amr,omitempty"`
+	ErrorCode     string            `json:"error_code,omitempty"`
+	Message       string            `json:"message,omitempty"`
+}
+
+// newJSONEvent returns the JSON representation of the event.
+func newJSONEvent(event logger.AuthEvent) jsonEvent {
+	return jsonEvent{
+		Timestamp:     event.Timestamp,
+		RequestID:     event.RequestID,
+		Client:        event.Client,
+		Host:          event.Host,
+		Protocol:      event.Protocol,
+		RequestMethod: event.RequestMethod,
+		RequestURI:    event.RequestURI,
+		UserAgent:     event.UserAgent,
+		User:          event.Username,
+		Provider:      event.Provider,
+		Status:        event.Status,
+		Event:         event.Event,
+		Reason:        event.Reason,
+		ACR:           event.ACR,
+		AMR:           event.AMR,
+		ErrorCode:     event.ErrorCode,
+		Message:       event.Message,
+	}
+}
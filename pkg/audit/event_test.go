@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var testEvent = logger.AuthEvent{
+	Timestamp:     time.Date(2022, 3, 1, 12, 30, 0, 0, time.UTC),
+	Client:        "10.0.0.1:51234",
+	Host:          "app.example.com",
+	Protocol:      "HTTP/1.1",
+	Provider:      "oidc",
+	RequestID:     "11111111-2222-4333-8444-555555555555",
+	RequestMethod: "GET",
+	RequestURI:    "/oauth2/callback?code=a=b",
+	UserAgent:     "test-agent",
+	Username:      "user@example.com",
+	Status:        logger.AuthFailure,
+	Message:       "Invalid authentication via OAuth2: unauthorized",
+}
+
+var _ = Describe("JSON events", func() {
+	It("encodes the fields of the event", func() {
+		data, err := json.Marshal(newJSONEvent(testEvent))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"timestamp": "2022-03-01T12:30:00Z",
+			"request_id": "11111111-2222-4333-8444-555555555555",
+			"client": "10.0.0.1:51234",
+			"host": "app.example.com",
+			"protocol": "HTTP/1.1",
+			"request_method": "GET",
+			"request_uri": "/oauth2/callback?code=a=b",
+			"user_agent": "test-agent",
+			"user": "user@example.com",
+			"provider": "oidc",
+			"status": "AuthFailure",
+			"message": "Invalid authentication via OAuth2: unauthorized"
+		}`))
+	})
+
+	It("leaves out the fields that are not set", func() {
+		data, err := json.Marshal(newJSONEvent(logger.AuthEvent{Timestamp: testEvent.Timestamp, Status: logger.AuthSuccess}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"timestamp": "2022-03-01T12:30:00Z", "status": "AuthSuccess"}`))
+	})
+})
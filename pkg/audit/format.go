@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// CEFFormat formats events in the ArcSight Common Event Format
+	CEFFormat = "cef"
+	// LEEFFormat formats events in the QRadar Log Event Extended Format
+	LEEFFormat = "leef"
+
+	vendor  = "OAuth2 Proxy"
+	product = "oauth2-proxy"
+)
+
+// ProductVersion is the version of OAuth2 Proxy reported in events.
+var ProductVersion = "undefined"
+
+// attribute is a key and value of the extension of an event.
+type attribute struct {
+	key   string
+	value string
+}
+
+// formatEvent formats the event as a CEF or LEEF message.
+func formatEvent(format string, event logger.AuthEvent) string {
+	if format == LEEFFormat {
+		return formatLEEF(event)
+	}
+	return formatCEF(event)
+}
+
+// formatCEF formats the event as a CEF message.
+func formatCEF(event logger.AuthEvent) string {
+	header := []string{
+		"CEF:0",
+		escapeHeader(vendor),
+		escapeHeader(product),
+		escapeHeader(ProductVersion),
+		escapeHeader(string(event.Status)),
+		escapeHeader(eventName(event.Status)),
+		fmt.Sprintf("%d", cefSeverity(event.Status)),
+	}
+
+	attrs := []attribute{
+		{"rt", fmt.Sprintf("%d", event.Timestamp.UnixNano()/1e6)},
+		{"outcome", outcome(event.Status)},
+		{"src", clientIP(event.Client)},
+		{"suser", event.Username},
+		{"dhost", event.Host},
+		{"requestMethod", event.RequestMethod},
+		{"request", event.RequestURI},
+		{"requestClientApplication", event.UserAgent},
+		{"msg", event.Message},
+	}
+	if event.RequestID != "" {
+		attrs = append(attrs, attribute{"cs1Label", "requestId"}, attribute{"cs1", event.RequestID})
+	}
+	if event.Provider != "" {
+		attrs = append(attrs, attribute{"cs2Label", "provider"}, attribute{"cs2", event.Provider})
+	}
+	if event.Event != "" {
+		attrs = append(attrs, attribute{"cs3Label", "event"}, attribute{"cs3", event.Event})
+	}
+	if event.Reason != "" {
+		attrs = append(attrs, attribute{"reason", event.Reason})
+	}
+	if event.ACR != "" {
+		attrs = append(attrs, attribute{"cs4Label", "acr"}, attribute{"cs4", event.ACR})
+	}
+	if len(event.AMR) > 0 {
+		attrs = append(attrs, attribute{"cs5Label", "amr"}, attribute{"cs5", strings.Join(event.AMR, " ")})
+	}
+	if event.ErrorCode != "" {
+		attrs = append(attrs, attribute{"cs6Label", "errorCode"}, attribute{"cs6", event.ErrorCode})
+	}
+
+	var ext []string
+	for _, a := range attrs {
+		if a.value == "" {
+			continue
+		}
+		ext = append(ext, a.key+"="+escapeCEFValue(a.value))
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(ext, " ")
+}
+
+// formatLEEF formats the event as a LEEF 1.0 message, whose attributes are
+// separated by tabs.
+func formatLEEF(event logger.AuthEvent) string {
+	header := []string{
+		"LEEF:1.0",
+		escapeHeader(vendor),
+		escapeHeader(product),
+		escapeHeader(ProductVersion),
+		escapeHeader(string(event.Status)),
+	}
+
+	attrs := []attribute{
+		{"devTime", event.Timestamp.Format("2006-01-02T15:04:05.000-0700")},
+		{"devTimeFormat", "yyyy-MM-dd'T'HH:mm:ss.SSSZ"},
+		{"cat", "Authentication"},
+		{"sev", fmt.Sprintf("%d", cefSeverity(event.Status))},
+		{"outcome", outcome(event.Status)},
+		{"src", clientIP(event.Client)},
+		{"usrName", event.Username},
+		{"dstHost", event.Host},
+		{"requestMethod", event.RequestMethod},
+		{"url", event.RequestURI},
+		{"userAgent", event.UserAgent},
+		{"requestId", event.RequestID},
+		{"provider", event.Provider},
+		{"event", event.Event},
+		{"reason", event.Reason},
+		{"acr", event.ACR},
+		{"amr", strings.Join(event.AMR, " ")},
+		{"errorCode", event.ErrorCode},
+		{"msg", event.Message},
+	}
+
+	var ext []string
+	for _, a := range attrs {
+		if a.value == "" {
+			continue
+		}
+		ext = append(ext, a.key+"="+escapeLEEFValue(a.value))
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(ext, "\t")
+}
+
+// eventName returns the human readable name of an event with the status.
+func eventName(status logger.AuthStatus) string {
+	switch status {
+	case logger.AuthSuccess:
+		return "Authentication succeeded"
+	case logger.AuthFailure:
+		return "Authentication failed"
+	default:
+		return "Authentication error"
+	}
+}
+
+// cefSeverity returns the severity, from 0 to 10, of an event with the
+// status.
+func cefSeverity(status logger.AuthStatus) int {
+	switch status {
+	case logger.AuthSuccess:
+		return 3
+	case logger.AuthFailure:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func outcome(status logger.AuthStatus) string {
+	if status == logger.AuthSuccess {
+		return "success"
+	}
+	return "failure"
+}
+
+// clientIP strips the port from the address of the client, if it has one.
+func clientIP(client string) string {
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		return host
+	}
+	return client
+}
+
+var (
+	headerEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefValueEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+func escapeHeader(s string) string {
+	return headerEscaper.Replace(s)
+}
+
+func escapeCEFValue(s string) string {
+	return cefValueEscaper.Replace(s)
+}
+
+func escapeLEEFValue(s string) string {
+	return leefValueEscaper.Replace(s)
+}
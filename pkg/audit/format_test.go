@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var testSecurityEvent = logger.AuthEvent{
+	Timestamp: testEvent.Timestamp,
+	Username:  "user@example.com",
+	Status:    logger.AuthSuccess,
+	Message:   "Authenticated via OAuth2",
+	Event:     logger.EventLoginSuccess,
+	ACR:       "urn:example:loa:2",
+	AMR:       []string{"pwd", "otp"},
+}
+
+var _ = Describe("Event formats", func() {
+	DescribeTable("formatEvent",
+		func(format string, event logger.AuthEvent, expected string) {
+			Expect(formatEvent(format, event)).To(Equal(expected))
+		},
+		Entry("with CEF", CEFFormat, testEvent,
+			`CEF:0|OAuth2 Proxy|oauth2-proxy|undefined|AuthFailure|Authentication failed|6|rt=1646137800000 outcome=failure src=10.0.0.1 suser=user@example.com dhost=app.example.com requestMethod=GET request=/oauth2/callback?code\=a\=b requestClientApplication=test-agent msg=Invalid authentication via OAuth2: unauthorized cs1Label=requestId cs1=11111111-2222-4333-8444-555555555555 cs2Label=provider cs2=oidc`),
+		Entry("with CEF and characters to escape", CEFFormat, logger.AuthEvent{
+			Timestamp: testEvent.Timestamp,
+			Status:    logger.AuthError,
+			Message:   "a\\b\nc",
+		},
+			`CEF:0|OAuth2 Proxy|oauth2-proxy|undefined|AuthError|Authentication error|7|rt=1646137800000 outcome=failure msg=a\\b\nc`),
+		Entry("with CEF and a security event", CEFFormat, testSecurityEvent,
+			`CEF:0|OAuth2 Proxy|oauth2-proxy|undefined|AuthSuccess|Authentication succeeded|3|rt=1646137800000 outcome=success suser=user@example.com msg=Authenticated via OAuth2 cs3Label=event cs3=login_success cs4Label=acr cs4=urn:example:loa:2 cs5Label=amr cs5=pwd otp`),
+		Entry("with CEF and a denial", CEFFormat, logger.AuthEvent{
+			Timestamp: testEvent.Timestamp,
+			Status:    logger.AuthFailure,
+			Event:     logger.EventLoginDenied,
+			Reason:    logger.ReasonGroup,
+			ErrorCode: "group_denied",
+		},
+			`CEF:0|OAuth2 Proxy|oauth2-proxy|undefined|AuthFailure|Authentication failed|6|rt=1646137800000 outcome=failure cs3Label=event cs3=login_denied reason=group cs6Label=errorCode cs6=group_denied`),
+		Entry("with LEEF", LEEFFormat, testEvent,
+			"LEEF:1.0|OAuth2 Proxy|oauth2-proxy|undefined|AuthFailure|devTime=2022-03-01T12:30:00.000+0000\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSZ\tcat=Authentication\tsev=6\toutcome=failure\tsrc=10.0.0.1\tusrName=user@example.com\tdstHost=app.example.com\trequestMethod=GET\turl=/oauth2/callback?code=a=b\tuserAgent=test-agent\trequestId=11111111-2222-4333-8444-555555555555\tprovider=oidc\tmsg=Invalid authentication via OAuth2: unauthorized"),
+		Entry("with LEEF and a security event", LEEFFormat, testSecurityEvent,
+			"LEEF:1.0|OAuth2 Proxy|oauth2-proxy|undefined|AuthSuccess|devTime=2022-03-01T12:30:00.000+0000\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSZ\tcat=Authentication\tsev=3\toutcome=success\tusrName=user@example.com\tevent=login_success\tacr=urn:example:loa:2\tamr=pwd otp\tmsg=Authenticated via OAuth2"),
+		Entry("with LEEF and a denial", LEEFFormat, logger.AuthEvent{
+			Timestamp: testEvent.Timestamp,
+			Status:    logger.AuthFailure,
+			Event:     logger.EventLoginDenied,
+			Reason:    logger.ReasonGroup,
+			ErrorCode: "group_denied",
+		},
+			"LEEF:1.0|OAuth2 Proxy|oauth2-proxy|undefined|AuthFailure|devTime=2022-03-01T12:30:00.000+0000\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSZ\tcat=Authentication\tsev=6\toutcome=failure\tevent=login_denied\treason=group\terrorCode=group_denied"),
+		Entry("with LEEF and characters to escape", LEEFFormat, logger.AuthEvent{
+			Timestamp: testEvent.Timestamp,
+			Status:    logger.AuthSuccess,
+			Message:   "a\tb\nc",
+		},
+			"LEEF:1.0|OAuth2 Proxy|oauth2-proxy|undefined|AuthSuccess|devTime=2022-03-01T12:30:00.000+0000\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSZ\tcat=Authentication\tsev=3\toutcome=success\tmsg=a b c"),
+	)
+})
@@ -0,0 +1,407 @@
+package audit
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+const (
+	// kafkaQueueSize is the number of events that are queued while the
+	// brokers are slow or unavailable, after which events are dropped
+	kafkaQueueSize = 1024
+	// kafkaBatchSize is the maximum number of queued events produced at once
+	kafkaBatchSize = 100
+
+	// kafkaClientID identifies the sink in the logs and quotas of the brokers
+	kafkaClientID = "oauth2-proxy"
+
+	kafkaDialTimeout    = 10 * time.Second
+	kafkaRequestTimeout = 30 * time.Second
+	// kafkaProduceTimeout is how long the leader of a partition waits for the
+	// events to be replicated before it fails the request
+	kafkaProduceTimeout = 10 * time.Second
+)
+
+// kafkaCloseTimeout is how long closing the sink waits for the queued events
+// to be produced. It is a variable so that tests can shorten it.
+var kafkaCloseTimeout = 5 * time.Second
+
+// KafkaConfig configures a KafkaSink.
+type KafkaConfig struct {
+	// Brokers are the `host:port` addresses of the brokers the metadata of
+	// the topic is fetched from
+	Brokers []string
+	// Topic receives the events
+	Topic string
+	// TLS connects to the brokers with TLS, trusting the certificates in the
+	// CAFile, when it is given, as well as the system certificates
+	TLS    bool
+	CAFile string
+	// Username and Password authenticate to the brokers with SASL/PLAIN, when
+	// a username is given
+	Username string
+	Password string
+}
+
+// kafkaMessage is an event to be produced to the topic.
+type kafkaMessage struct {
+	key       []byte
+	value     []byte
+	timestamp time.Time
+}
+
+// KafkaSink produces auth events to a Kafka topic as JSON messages.
+// Messages are keyed by the user, and are assigned to partitions like the
+// default partitioner of the Java client, so that the events of a user are in
+// a single partition in the order they happened.
+// Events are produced in the background, so that slow or unavailable brokers
+// do not delay requests.
+type KafkaSink struct {
+	config    KafkaConfig
+	tlsConfig *tls.Config
+
+	events    chan kafkaMessage
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	// closeDeadline is when closing the sink stops waiting for the queued
+	// events to be produced, set before done is closed
+	closeDeadline time.Time
+
+	// The leader of each partition of the topic and the connections to the
+	// leaders, which are looked up for the first events and again after a
+	// failure
+	leaders []int32
+	brokers map[int32]string
+	conns   map[int32]*kafkaConn
+	// next is the partition of the next event without a user
+	next int
+	// deadline bounds the requests to the brokers once the sink is closed
+	deadline time.Time
+
+	worker      *workers.Worker
+	removeQueue func()
+}
+
+// NewKafkaSink creates a sink producing events to the topic of the brokers.
+func NewKafkaSink(config KafkaConfig) (*KafkaSink, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	s := &KafkaSink{
+		config:  config,
+		events:  make(chan kafkaMessage, kafkaQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		conns:   make(map[int32]*kafkaConn),
+	}
+	if config.TLS {
+		var err error
+		s.tlsConfig, err = clientTLSConfig("kafka", config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.worker = workers.New("audit_kafka", 0)
+	s.removeQueue = workers.RegisterQueue("audit_kafka", func() int { return len(s.events) })
+	go s.run()
+	return s, nil
+}
+
+// validate checks the configuration of a Kafka sink.
+func (c KafkaConfig) validate() error {
+	if len(c.Brokers) == 0 {
+		return errors.New("no kafka brokers given")
+	}
+	for _, broker := range c.Brokers {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			return fmt.Errorf("invalid kafka broker address %q: %v", broker, err)
+		}
+	}
+	if c.Topic == "" {
+		return errors.New("no kafka topic given")
+	}
+
+	if c.TLS {
+		if _, err := clientTLSConfig("kafka", c.CAFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAuthEvent queues the event to be produced to the topic, dropping it if
+// the queue is full.
+func (s *KafkaSink) WriteAuthEvent(event logger.AuthEvent) {
+	value, err := json.Marshal(newJSONEvent(event))
+	if err != nil {
+		logger.Errorf("Error encoding auth event for kafka: %v", err)
+		return
+	}
+	msg := kafkaMessage{value: value, timestamp: event.Timestamp}
+	if event.Username != "" {
+		msg.key = []byte(event.Username)
+	}
+
+	select {
+	case s.events <- msg:
+	default:
+	}
+}
+
+// Close stops producing events to the topic, and waits for the queued events
+// to be produced for up to the kafkaCloseTimeout, after which they are dropped.
+func (s *KafkaSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeDeadline = time.Now().Add(kafkaCloseTimeout)
+		close(s.done)
+		select {
+		case <-s.stopped:
+		case <-time.After(time.Until(s.closeDeadline)):
+			logger.Errorf("Timed out producing queued auth events to kafka topic %s", s.config.Topic)
+		}
+		s.removeQueue()
+	})
+	return nil
+}
+
+// run produces the queued events until the sink is closed, looking up the
+// leaders of the partitions again whenever producing fails.
+func (s *KafkaSink) run() {
+	defer close(s.stopped)
+	defer s.disconnect()
+
+	failing := false
+	for {
+		select {
+		case <-s.done:
+			s.flush()
+			return
+		case msg := <-s.events:
+			batch := s.dequeue(msg)
+
+			var err error
+			// Retry once with fresh metadata, as the leader of a partition
+			// may have moved to another broker
+			for attempt := 0; attempt < 2; attempt++ {
+				if err = s.produce(batch); err == nil {
+					break
+				}
+				s.disconnect()
+			}
+			s.worker.Record(err)
+
+			// Only log the first of consecutive failures, to avoid flooding
+			// the log while the brokers are unavailable
+			if err != nil && !failing {
+				logger.Errorf("Error producing %d auth events to kafka topic %s: %v", len(batch), s.config.Topic, err)
+			}
+			failing = err != nil
+		}
+	}
+}
+
+// flush produces the events remaining in the queue once the sink is closed,
+// dropping them when they cannot be produced before the close deadline.
+func (s *KafkaSink) flush() {
+	s.deadline = s.closeDeadline
+	for _, conn := range s.conns {
+		conn.deadline = s.deadline
+	}
+
+	for {
+		select {
+		case msg := <-s.events:
+			batch := s.dequeue(msg)
+			if err := s.produce(batch); err != nil {
+				logger.Errorf("Dropped %d auth events that could not be produced to kafka topic %s: %v", len(batch)+len(s.events), s.config.Topic, err)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// dequeue returns the message followed by up to a batch of further queued
+// messages.
+func (s *KafkaSink) dequeue(msg kafkaMessage) []kafkaMessage {
+	batch := []kafkaMessage{msg}
+	for len(batch) < kafkaBatchSize {
+		select {
+		case msg := <-s.events:
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// produce sends the messages to the leaders of their partitions.
+func (s *KafkaSink) produce(batch []kafkaMessage) error {
+	if s.leaders == nil {
+		if err := s.lookupLeaders(); err != nil {
+			return err
+		}
+	}
+
+	requests := make(map[int32]map[int32][]kafkaMessage)
+	for _, msg := range batch {
+		partition := s.partition(msg.key)
+		leader := s.leaders[partition]
+		if requests[leader] == nil {
+			requests[leader] = make(map[int32][]kafkaMessage)
+		}
+		requests[leader][partition] = append(requests[leader][partition], msg)
+	}
+
+	for leader, partitions := range requests {
+		conn, err := s.conn(leader)
+		if err != nil {
+			return err
+		}
+		if err := conn.produce(s.config.Topic, partitions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partition returns the partition of a message, which is given by the
+// murmur2 hash of its key, or is the next partition in turn for messages
+// without a key.
+func (s *KafkaSink) partition(key []byte) int32 {
+	if key == nil {
+		s.next = (s.next + 1) % len(s.leaders)
+		return int32(s.next)
+	}
+	return int32((murmur2(key) & 0x7fffffff) % uint32(len(s.leaders)))
+}
+
+// lookupLeaders fetches the metadata of the topic from the first of the
+// brokers that can be reached.
+func (s *KafkaSink) lookupLeaders() error {
+	var err error
+	for _, broker := range s.config.Brokers {
+		var conn *kafkaConn
+		conn, err = s.dial(broker)
+		if err != nil {
+			continue
+		}
+		s.brokers, s.leaders, err = conn.metadata(s.config.Topic)
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	s.leaders = nil
+	return err
+}
+
+// conn returns the connection to the broker, connecting to it when needed.
+func (s *KafkaSink) conn(node int32) (*kafkaConn, error) {
+	if conn, ok := s.conns[node]; ok {
+		return conn, nil
+	}
+	address, ok := s.brokers[node]
+	if !ok {
+		return nil, fmt.Errorf("unknown kafka broker %d", node)
+	}
+	conn, err := s.dial(address)
+	if err != nil {
+		return nil, err
+	}
+	s.conns[node] = conn
+	return conn, nil
+}
+
+// dial connects to the broker, authenticating when a username is given.
+func (s *KafkaSink) dial(address string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: kafkaDialTimeout, Deadline: s.deadline}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newKafkaConn(conn)
+	c.deadline = s.deadline
+	if s.config.Username != "" {
+		if err := c.authenticate(s.config.Username, s.config.Password); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to authenticate to kafka broker %s: %v", address, err)
+		}
+	}
+	return c, nil
+}
+
+// disconnect closes the connections to the brokers, and forgets the leaders
+// of the partitions.
+func (s *KafkaSink) disconnect() {
+	for node, conn := range s.conns {
+		conn.Close()
+		delete(s.conns, node)
+	}
+	s.leaders = nil
+}
+
+// brokerAddress returns the address of a broker given in the metadata.
+func brokerAddress(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// murmur2 is the hash used by the Java client to assign a message to a
+// partition by its key.
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
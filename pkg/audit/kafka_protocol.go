@@ -0,0 +1,417 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// The Kafka APIs used by the sink, and their versions, which are supported by
+// Kafka 1.0 and later.
+const (
+	kafkaAPIProduce          = 0
+	kafkaAPIMetadata         = 3
+	kafkaAPISaslHandshake    = 17
+	kafkaAPISaslAuthenticate = 36
+
+	kafkaProduceVersion          = 3
+	kafkaMetadataVersion         = 4
+	kafkaSaslHandshakeVersion    = 1
+	kafkaSaslAuthenticateVersion = 0
+
+	// kafkaMaxResponseSize bounds the responses read from the brokers
+	kafkaMaxResponseSize = 16 * 1024 * 1024
+)
+
+// kafkaErrorNames are the names of the error codes the brokers are expected
+// to return to the sink.
+var kafkaErrorNames = map[int16]string{
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+var (
+	errKafkaShortResponse = errors.New("kafka response is too short")
+
+	kafkaCRCTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// kafkaError returns the error of a Kafka error code.
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrorNames[code]; ok {
+		return fmt.Errorf("kafka error %d (%s)", code, name)
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+// kafkaConn is a connection to a broker.
+type kafkaConn struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+	// deadline, when set, bounds the requests on the connection
+	deadline time.Time
+}
+
+func newKafkaConn(conn net.Conn) *kafkaConn {
+	return &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Close closes the connection.
+func (c *kafkaConn) Close() error {
+	return c.conn.Close()
+}
+
+// roundTrip sends a request to the broker and returns its response.
+func (c *kafkaConn) roundTrip(apiKey, apiVersion int16, body []byte) (*kafkaDecoder, error) {
+	c.correlationID++
+
+	req := &kafkaEncoder{}
+	req.int32(0) // The size of the request, set below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(c.correlationID)
+	req.string(kafkaClientID)
+	req.Write(body)
+	data := req.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+
+	deadline := time.Now().Add(kafkaRequestTimeout)
+	if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+		deadline = c.deadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.reader, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("kafka response of %d bytes is too large", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.reader, resp); err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{data: resp}
+	if id := d.int32(); d.err == nil && id != c.correlationID {
+		return nil, fmt.Errorf("kafka response to request %d received for request %d", id, c.correlationID)
+	}
+	return d, d.err
+}
+
+// authenticate authenticates to the broker with SASL/PLAIN.
+func (c *kafkaConn) authenticate(username, password string) error {
+	req := &kafkaEncoder{}
+	req.string("PLAIN")
+	d, err := c.roundTrip(kafkaAPISaslHandshake, kafkaSaslHandshakeVersion, req.Bytes())
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); d.err == nil && code != 0 {
+		return kafkaError(code)
+	}
+
+	req = &kafkaEncoder{}
+	req.bytes([]byte("\x00" + username + "\x00" + password))
+	d, err = c.roundTrip(kafkaAPISaslAuthenticate, kafkaSaslAuthenticateVersion, req.Bytes())
+	if err != nil {
+		return err
+	}
+	code := d.int16()
+	message := d.string()
+	switch {
+	case d.err != nil:
+		return d.err
+	case code != 0 && message != "":
+		return fmt.Errorf("%v: %s", kafkaError(code), message)
+	case code != 0:
+		return kafkaError(code)
+	}
+	return nil
+}
+
+// metadata returns the addresses of the brokers, by their node ID, and the
+// node ID of the leader of each partition of the topic.
+func (c *kafkaConn) metadata(topic string) (map[int32]string, []int32, error) {
+	req := &kafkaEncoder{}
+	req.int32(1)
+	req.string(topic)
+	req.int8(0) // Do not create the topic
+	d, err := c.roundTrip(kafkaAPIMetadata, kafkaMetadataVersion, req.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.int32() // Throttle time
+	brokers := make(map[int32]string)
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		node := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // Rack
+		brokers[node] = brokerAddress(host, port)
+	}
+	d.string() // Cluster ID
+	d.int32()  // Controller ID
+
+	type partition struct {
+		index  int32
+		leader int32
+	}
+	var partitions []partition
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // Internal
+		if d.err == nil && name == topic && code != 0 {
+			return nil, nil, fmt.Errorf("failed to look up kafka topic %s: %v", topic, kafkaError(code))
+		}
+		for j := d.int32(); j > 0 && d.err == nil; j-- {
+			d.int16() // Error code, the leader is checked instead
+			p := partition{index: d.int32(), leader: d.int32()}
+			d.int32Array() // Replicas
+			d.int32Array() // In-sync replicas
+			if name == topic {
+				partitions = append(partitions, p)
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	if len(partitions) == 0 {
+		return nil, nil, fmt.Errorf("kafka topic %s has no partitions", topic)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].index < partitions[j].index })
+	leaders := make([]int32, len(partitions))
+	for i, p := range partitions {
+		if p.index != int32(i) {
+			return nil, nil, fmt.Errorf("kafka topic %s is missing partition %d", topic, i)
+		}
+		if p.leader < 0 {
+			return nil, nil, fmt.Errorf("kafka topic %s has no leader for partition %d", topic, i)
+		}
+		leaders[i] = p.leader
+	}
+	return brokers, leaders, nil
+}
+
+// produce sends the messages of each partition of the topic to the broker,
+// which must be the leader of the partitions, and waits for them to be
+// replicated.
+func (c *kafkaConn) produce(topic string, partitions map[int32][]kafkaMessage) error {
+	req := &kafkaEncoder{}
+	req.int16(-1) // No transactional ID
+	req.int16(-1) // Acknowledged by all the in-sync replicas
+	req.int32(int32(kafkaProduceTimeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(int32(len(partitions)))
+	for partition, messages := range partitions {
+		req.int32(partition)
+		req.bytes(encodeRecordBatch(messages))
+	}
+
+	d, err := c.roundTrip(kafkaAPIProduce, kafkaProduceVersion, req.Bytes())
+	if err != nil {
+		return err
+	}
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		d.string() // Topic
+		for j := d.int32(); j > 0 && d.err == nil; j-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // Base offset
+			d.int64() // Log append time
+			if d.err == nil && code != 0 {
+				return fmt.Errorf("failed to produce to partition %d: %v", partition, kafkaError(code))
+			}
+		}
+	}
+	return d.err
+}
+
+// encodeRecordBatch encodes the messages as a batch of records, in the format
+// of the v2 message format (magic byte 2) of Kafka 0.11 and later.
+func encodeRecordBatch(messages []kafkaMessage) []byte {
+	baseTimestamp := messages[0].timestamp.UnixNano() / int64(time.Millisecond)
+	maxTimestamp := baseTimestamp
+
+	records := &kafkaEncoder{}
+	for i, msg := range messages {
+		timestamp := msg.timestamp.UnixNano() / int64(time.Millisecond)
+		if timestamp > maxTimestamp {
+			maxTimestamp = timestamp
+		}
+
+		record := &kafkaEncoder{}
+		record.int8(0) // Attributes
+		record.varint(timestamp - baseTimestamp)
+		record.varint(int64(i))
+		record.varintBytes(msg.key)
+		record.varintBytes(msg.value)
+		record.varint(0) // Headers
+		records.varint(int64(record.Len()))
+		records.Write(record.Bytes())
+	}
+
+	// The part of the batch covered by its CRC
+	body := &kafkaEncoder{}
+	body.int16(0) // Attributes: no compression
+	body.int32(int32(len(messages) - 1))
+	body.int64(baseTimestamp)
+	body.int64(maxTimestamp)
+	body.int64(-1) // No producer ID
+	body.int16(-1) // No producer epoch
+	body.int32(-1) // No base sequence
+	body.int32(int32(len(messages)))
+	body.Write(records.Bytes())
+
+	batch := &kafkaEncoder{}
+	batch.int64(0) // Base offset, assigned by the broker
+	batch.int32(int32(4 + 1 + 4 + body.Len()))
+	batch.int32(-1) // Partition leader epoch
+	batch.int8(2)   // Magic
+	batch.int32(int32(crc32.Checksum(body.Bytes(), kafkaCRCTable)))
+	batch.Write(body.Bytes())
+	return batch.Bytes()
+}
+
+// kafkaEncoder encodes the fields of Kafka requests.
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.WriteByte(byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) string(v string) {
+	e.int16(int16(len(v)))
+	e.WriteString(v)
+}
+
+func (e *kafkaEncoder) bytes(v []byte) {
+	e.int32(int32(len(v)))
+	e.Write(v)
+}
+
+// varint encodes a zig-zag variable length integer, as used by records.
+func (e *kafkaEncoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+// varintBytes encodes bytes with a variable length, or -1 when they are nil.
+func (e *kafkaEncoder) varintBytes(v []byte) {
+	if v == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(v)))
+	e.Write(v)
+}
+
+// kafkaDecoder decodes the fields of Kafka responses. Once a field cannot be
+// decoded, the following fields are zero and err is set.
+type kafkaDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = errKafkaShortResponse
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string decodes a string, which is empty when it is null.
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		d.int32()
+	}
+}
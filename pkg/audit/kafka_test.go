@@ -0,0 +1,395 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// producedRecord is a record received by the fake broker.
+type producedRecord struct {
+	partition int32
+	key       []byte
+	value     []byte
+}
+
+// fakeKafkaBroker is a single broker leading every partition of the topic,
+// which answers the requests made by the sink.
+type fakeKafkaBroker struct {
+	listener   net.Listener
+	topic      string
+	partitions int32
+	username   string
+	password   string
+	records    chan producedRecord
+}
+
+func newFakeKafkaBroker(topic string, partitions int32) *fakeKafkaBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	b := &fakeKafkaBroker{
+		listener:   listener,
+		topic:      topic,
+		partitions: partitions,
+		records:    make(chan producedRecord, 100),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) address() string {
+	return b.listener.Addr().String()
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer GinkgoRecover()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+
+		req := &kafkaDecoder{data: data}
+		apiKey := req.int16()
+		apiVersion := req.int16()
+		correlationID := req.int32()
+		Expect(req.string()).To(Equal(kafkaClientID))
+
+		resp := &kafkaEncoder{}
+		resp.int32(correlationID)
+		switch apiKey {
+		case kafkaAPIMetadata:
+			Expect(apiVersion).To(BeEquivalentTo(kafkaMetadataVersion))
+			b.metadata(req, resp)
+		case kafkaAPISaslHandshake:
+			Expect(req.string()).To(Equal("PLAIN"))
+			resp.int16(0)
+			resp.int32(1)
+			resp.string("PLAIN")
+		case kafkaAPISaslAuthenticate:
+			if string(decodeTestBytes(req)) == "\x00"+b.username+"\x00"+b.password {
+				resp.int16(0)
+				resp.int16(-1)
+			} else {
+				resp.int16(58)
+				resp.string("Authentication failed: Invalid username or password")
+			}
+			resp.int32(0)
+		case kafkaAPIProduce:
+			Expect(apiVersion).To(BeEquivalentTo(kafkaProduceVersion))
+			b.produce(req, resp)
+		default:
+			Fail("unexpected kafka api " + strconv.Itoa(int(apiKey)))
+		}
+		Expect(req.err).ToNot(HaveOccurred())
+
+		data = resp.Bytes()
+		binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+		if _, err := conn.Write(append(size[:], data...)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeKafkaBroker) metadata(req *kafkaDecoder, resp *kafkaEncoder) {
+	Expect(req.int32()).To(BeEquivalentTo(1))
+	topic := req.string()
+	Expect(req.int8()).To(BeEquivalentTo(0))
+
+	host, port, err := net.SplitHostPort(b.address())
+	Expect(err).ToNot(HaveOccurred())
+	portNumber, err := strconv.Atoi(port)
+	Expect(err).ToNot(HaveOccurred())
+
+	resp.int32(0) // Throttle time
+	resp.int32(1)
+	resp.int32(1)
+	resp.string(host)
+	resp.int32(int32(portNumber))
+	resp.int16(-1) // No rack
+	resp.int16(-1) // No cluster ID
+	resp.int32(1)  // Controller
+	resp.int32(1)
+	if topic != b.topic {
+		resp.int16(3) // Unknown topic
+		resp.string(topic)
+		resp.int8(0)
+		resp.int32(0)
+		return
+	}
+	resp.int16(0)
+	resp.string(topic)
+	resp.int8(0)
+	resp.int32(b.partitions)
+	// The partitions are listed in reverse, as brokers do not sort them
+	for i := b.partitions - 1; i >= 0; i-- {
+		resp.int16(0)
+		resp.int32(i)
+		resp.int32(1) // Leader
+		resp.int32(1) // Replicas
+		resp.int32(1)
+		resp.int32(1) // In-sync replicas
+		resp.int32(1)
+	}
+}
+
+func (b *fakeKafkaBroker) produce(req *kafkaDecoder, resp *kafkaEncoder) {
+	Expect(req.int16()).To(BeEquivalentTo(-1))
+	Expect(req.int16()).To(BeEquivalentTo(-1))
+	Expect(req.int32()).To(BeEquivalentTo(10000))
+	Expect(req.int32()).To(BeEquivalentTo(1))
+	Expect(req.string()).To(Equal(b.topic))
+
+	var partitions []int32
+	for i := req.int32(); i > 0; i-- {
+		partition := req.int32()
+		partitions = append(partitions, partition)
+		for _, record := range decodeTestRecordBatch(decodeTestBytes(req)) {
+			record.partition = partition
+			b.records <- record
+		}
+	}
+
+	resp.int32(1)
+	resp.string(b.topic)
+	resp.int32(int32(len(partitions)))
+	for _, partition := range partitions {
+		resp.int32(partition)
+		resp.int16(0)
+		resp.int64(0)
+		resp.int64(-1)
+	}
+	resp.int32(0) // Throttle time
+}
+
+func (b *fakeKafkaBroker) Close() {
+	b.listener.Close()
+}
+
+func decodeTestBytes(d *kafkaDecoder) []byte {
+	return d.take(int(d.int32()))
+}
+
+func decodeTestVarint(d *kafkaDecoder) int64 {
+	v, n := binary.Varint(d.data)
+	Expect(n).To(BeNumerically(">", 0))
+	d.take(n)
+	return v
+}
+
+func decodeTestVarintBytes(d *kafkaDecoder) []byte {
+	n := decodeTestVarint(d)
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// decodeTestRecordBatch checks the record batch and returns its records.
+func decodeTestRecordBatch(data []byte) []producedRecord {
+	d := &kafkaDecoder{data: data}
+	Expect(d.int64()).To(BeEquivalentTo(0))
+	Expect(d.int32()).To(BeEquivalentTo(len(data) - 12))
+	Expect(d.int32()).To(BeEquivalentTo(-1))
+	Expect(d.int8()).To(BeEquivalentTo(2))
+	crc := uint32(d.int32())
+	Expect(crc32.Checksum(d.data, crc32.MakeTable(crc32.Castagnoli))).To(Equal(crc))
+
+	Expect(d.int16()).To(BeEquivalentTo(0))
+	lastOffsetDelta := d.int32()
+	d.int64() // Base timestamp
+	d.int64() // Max timestamp
+	Expect(d.int64()).To(BeEquivalentTo(-1))
+	Expect(d.int16()).To(BeEquivalentTo(-1))
+	Expect(d.int32()).To(BeEquivalentTo(-1))
+	count := d.int32()
+	Expect(lastOffsetDelta).To(Equal(count - 1))
+
+	var records []producedRecord
+	for i := int32(0); i < count; i++ {
+		length := decodeTestVarint(d)
+		record := &kafkaDecoder{data: d.take(int(length))}
+		Expect(record.int8()).To(BeEquivalentTo(0))
+		decodeTestVarint(record) // Timestamp delta
+		Expect(decodeTestVarint(record)).To(BeEquivalentTo(i))
+		key := decodeTestVarintBytes(record)
+		value := decodeTestVarintBytes(record)
+		Expect(decodeTestVarint(record)).To(BeEquivalentTo(0))
+		Expect(record.data).To(BeEmpty())
+		records = append(records, producedRecord{key: key, value: value})
+	}
+	Expect(d.err).ToNot(HaveOccurred())
+	Expect(d.data).To(BeEmpty())
+	return records
+}
+
+var _ = Describe("KafkaSink", func() {
+	var broker *fakeKafkaBroker
+
+	BeforeEach(func() {
+		broker = newFakeKafkaBroker("auth", 4)
+	})
+
+	AfterEach(func() {
+		broker.Close()
+	})
+
+	It("produces events as JSON messages keyed by the user", func() {
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{broker.address()}, Topic: "auth"})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		other := testEvent
+		other.Username = "other@example.com"
+		anonymous := testEvent
+		anonymous.Username = ""
+		sink.WriteAuthEvent(testEvent)
+		sink.WriteAuthEvent(other)
+		sink.WriteAuthEvent(testEvent)
+		sink.WriteAuthEvent(anonymous)
+
+		var records []producedRecord
+		for i := 0; i < 4; i++ {
+			var record producedRecord
+			Eventually(broker.records, 5*time.Second).Should(Receive(&record))
+			records = append(records, record)
+		}
+
+		byKey := make(map[string][]producedRecord)
+		for _, record := range records {
+			byKey[string(record.key)] = append(byKey[string(record.key)], record)
+		}
+		Expect(byKey).To(HaveLen(3))
+		Expect(byKey[""][0].key).To(BeNil())
+
+		users := byKey["user@example.com"]
+		Expect(users).To(HaveLen(2))
+		Expect(users[0].partition).To(Equal(users[1].partition))
+		Expect(users[0].partition).To(BeEquivalentTo((murmur2([]byte("user@example.com")) & 0x7fffffff) % 4))
+
+		var event jsonEvent
+		Expect(json.Unmarshal(users[0].value, &event)).To(Succeed())
+		Expect(event).To(Equal(newJSONEvent(testEvent)))
+		Expect(event.Status).To(Equal(logger.AuthFailure))
+	})
+
+	It("produces the queued events when closed", func() {
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{broker.address()}, Topic: "auth"})
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			sink.WriteAuthEvent(testEvent)
+		}
+		Expect(sink.Close()).To(Succeed())
+		Expect(broker.records).To(HaveLen(3))
+	})
+
+	It("stops waiting for unavailable brokers when closed", func() {
+		kafkaCloseTimeout = 100 * time.Millisecond
+		defer func() { kafkaCloseTimeout = 5 * time.Second }()
+
+		// A broker that accepts connections but never answers
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{listener.Addr().String()}, Topic: "auth"})
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.WriteAuthEvent(testEvent)
+		start := time.Now()
+		Expect(sink.Close()).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("authenticates with SASL/PLAIN", func() {
+		broker.username = "proxy"
+		broker.password = "secret"
+
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{broker.address()}, Topic: "auth", Username: "proxy", Password: "secret"})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testEvent)
+		Eventually(broker.records, 5*time.Second).Should(Receive())
+	})
+
+	It("fails to authenticate with the wrong password", func() {
+		broker.username = "proxy"
+		broker.password = "secret"
+
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{broker.address()}, Topic: "auth"})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		_, err = sink.dial(broker.address())
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.config.Username = "proxy"
+		sink.config.Password = "wrong"
+		_, err = sink.dial(broker.address())
+		Expect(err).To(MatchError("failed to authenticate to kafka broker " + broker.address() +
+			": kafka error 58 (SASL_AUTHENTICATION_FAILED): Authentication failed: Invalid username or password"))
+	})
+
+	It("fails to look up an unknown topic", func() {
+		sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{broker.address()}, Topic: "other"})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		conn, err := sink.dial(broker.address())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, _, err = conn.metadata("other")
+		Expect(err).To(MatchError("failed to look up kafka topic other: kafka error 3 (UNKNOWN_TOPIC_OR_PARTITION)"))
+	})
+
+	DescribeTable("NewKafkaSink returns errors",
+		func(config KafkaConfig, expected string) {
+			_, err := NewKafkaSink(config)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("without brokers", KafkaConfig{Topic: "auth"}, "no kafka brokers given"),
+		Entry("with a broker without a port", KafkaConfig{Brokers: []string{"kafka.example.com"}, Topic: "auth"}, `invalid kafka broker address "kafka.example.com": address kafka.example.com: missing port in address`),
+		Entry("without a topic", KafkaConfig{Brokers: []string{"kafka.example.com:9092"}}, "no kafka topic given"),
+		Entry("with a missing CA file", KafkaConfig{Brokers: []string{"kafka.example.com:9093"}, Topic: "auth", TLS: true, CAFile: "/nonexistent/ca.pem"}, `failed to load kafka CA file "/nonexistent/ca.pem": open /nonexistent/ca.pem: no such file or directory`),
+	)
+
+	DescribeTable("murmur2 matches the partitioner of the Java client",
+		func(key string, expected int32) {
+			Expect(int32(murmur2([]byte(key)))).To(Equal(expected))
+		},
+		Entry("21", "21", int32(-973932308)),
+		Entry("foobar", "foobar", int32(-790332482)),
+		Entry("a-little-bit-long-string", "a-little-bit-long-string", int32(-985981536)),
+		Entry("a-little-bit-longer-string", "a-little-bit-longer-string", int32(-1486304829)),
+		Entry("lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", "lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", int32(-58897971)),
+		Entry("abc", "abc", int32(479470107)),
+	)
+})
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"io"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// NewSinks creates the sinks of auth events configured in the logging
+// options, which start sending events in the background.
+// When a sink cannot be created, the sinks created before it are closed.
+func NewSinks(o options.Logging) ([]logger.AuthSink, error) {
+	var sinks []logger.AuthSink
+	if o.Syslog.Address != "" {
+		sink, err := NewSyslogSink(o.Syslog.Address, o.Syslog.Format, o.Syslog.CAFile)
+		if err != nil {
+			return nil, closeSinks(sinks, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if o.Webhook.URL != "" {
+		sink, err := NewWebhookSink(webhookConfig(o.Webhook))
+		if err != nil {
+			return nil, closeSinks(sinks, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(o.Kafka.Brokers) > 0 || o.Kafka.Topic != "" {
+		sink, err := NewKafkaSink(kafkaConfig(o.Kafka))
+		if err != nil {
+			return nil, closeSinks(sinks, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// ValidateSinks checks the options of the sinks of auth events configured in
+// the logging options without creating them, so that validating a
+// configuration does not send or take any events.
+func ValidateSinks(o options.Logging) []error {
+	var errs []error
+	if o.Syslog.Address != "" {
+		if _, _, _, err := parseSyslogConfig(o.Syslog.Address, o.Syslog.Format, o.Syslog.CAFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.Webhook.URL != "" {
+		if err := webhookConfig(o.Webhook).validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(o.Kafka.Brokers) > 0 || o.Kafka.Topic != "" {
+		if err := kafkaConfig(o.Kafka).validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func webhookConfig(o options.WebhookOptions) WebhookConfig {
+	return WebhookConfig{
+		URL:           o.URL,
+		Secret:        []byte(o.Secret),
+		BatchSize:     o.BatchSize,
+		FlushInterval: o.FlushInterval,
+		BufferFile:    o.BufferFile,
+	}
+}
+
+func kafkaConfig(o options.KafkaOptions) KafkaConfig {
+	return KafkaConfig{
+		Brokers:  o.Brokers,
+		Topic:    o.Topic,
+		TLS:      o.TLS,
+		CAFile:   o.CAFile,
+		Username: o.SASLUsername,
+		Password: o.SASLPassword,
+	}
+}
+
+// closeSinks closes the sinks that were created before the error.
+func closeSinks(sinks []logger.AuthSink, err error) error {
+	for _, sink := range sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return err
+}
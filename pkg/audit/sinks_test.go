@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sinks", func() {
+	It("creates no sinks without options", func() {
+		sinks, err := NewSinks(options.Logging{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sinks).To(BeEmpty())
+	})
+
+	It("validates the sinks without sending buffered events", func() {
+		dir, err := ioutil.TempDir("", "oauth2-proxy-sinks")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		bufferFile := filepath.Join(dir, "buffer")
+		Expect(ioutil.WriteFile(bufferFile, []byte(`{"status":"AuthSuccess"}`+"\n"), 0600)).To(Succeed())
+
+		errs := ValidateSinks(options.Logging{
+			Syslog: options.SyslogOptions{Address: "tcp://syslog.example.com:514", Format: CEFFormat},
+			Webhook: options.WebhookOptions{
+				URL:           "https://siem.example.com/events",
+				BatchSize:     1,
+				FlushInterval: time.Second,
+				BufferFile:    bufferFile,
+			},
+		})
+		Expect(errs).To(BeEmpty())
+
+		// Give a sink time to take the buffered events, had one been created
+		time.Sleep(50 * time.Millisecond)
+		Expect(ioutil.ReadFile(bufferFile)).To(Equal([]byte(`{"status":"AuthSuccess"}` + "\n")))
+	})
+
+	It("returns the errors of each sink", func() {
+		errs := ValidateSinks(options.Logging{
+			Syslog:  options.SyslogOptions{Address: "http://syslog.example.com:514", Format: CEFFormat},
+			Webhook: options.WebhookOptions{URL: "/events", BatchSize: 1, FlushInterval: time.Second},
+			Kafka:   options.KafkaOptions{Brokers: []string{"kafka.example.com:9092"}},
+		})
+		Expect(errs).To(ConsistOf(
+			MatchError(`invalid syslog address "http://syslog.example.com:514": scheme must be one of tcp, tls or udp`),
+			MatchError(`invalid webhook URL "/events": must be an absolute http or https URL`),
+			MatchError("no kafka topic given"),
+		))
+	})
+})
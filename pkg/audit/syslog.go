@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+const (
+	// syslogFacilityAuthPriv is the facility of security and authorization
+	// messages
+	syslogFacilityAuthPriv = 10
+
+	syslogSeverityError   = 3
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+
+	// syslogBufferSize is the number of events that are buffered while the
+	// syslog server is slow or unavailable, after which events are dropped
+	syslogBufferSize = 1024
+
+	syslogDialTimeout  = 10 * time.Second
+	syslogWriteTimeout = 10 * time.Second
+)
+
+// SyslogSink forwards auth events to a syslog server as RFC 5424 messages with
+// a CEF or LEEF payload.
+// Events are sent in the background, so that a slow or unavailable syslog
+// server does not delay requests.
+type SyslogSink struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	format    string
+	hostname  string
+
+	events    chan logger.AuthEvent
+	done      chan struct{}
+	closeOnce sync.Once
+
+	worker      *workers.Worker
+	removeQueue func()
+}
+
+// NewSyslogSink creates a sink sending events to the syslog server at the
+// address, given as `tcp://host:port`, `tls://host:port` or `udp://host:port`.
+// The caFile, if given, verifies the certificate of a TLS syslog server.
+func NewSyslogSink(address, format, caFile string) (*SyslogSink, error) {
+	network, host, tlsConfig, err := parseSyslogConfig(address, format, caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SyslogSink{
+		network:   network,
+		address:   host,
+		tlsConfig: tlsConfig,
+		format:    format,
+		events:    make(chan logger.AuthEvent, syslogBufferSize),
+		done:      make(chan struct{}),
+	}
+
+	s.hostname, err = os.Hostname()
+	if err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+
+	s.worker = workers.New("audit_syslog", 0)
+	s.removeQueue = workers.RegisterQueue("audit_syslog", func() int { return len(s.events) })
+	go s.run()
+	return s, nil
+}
+
+// parseSyslogConfig checks the address and format of a syslog sink, and
+// returns the network and host of the syslog server with the TLS configuration
+// of connections to a TLS syslog server.
+func parseSyslogConfig(address, format, caFile string) (network, host string, tlsConfig *tls.Config, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid syslog address %q: %v", address, err)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return "", "", nil, fmt.Errorf("invalid syslog address %q: %v", address, err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "udp":
+		network = u.Scheme
+	case "tls":
+		network = "tcp"
+		tlsConfig, err = clientTLSConfig("syslog", caFile)
+		if err != nil {
+			return "", "", nil, err
+		}
+	default:
+		return "", "", nil, fmt.Errorf("invalid syslog address %q: scheme must be one of tcp, tls or udp", address)
+	}
+
+	switch format {
+	case CEFFormat, LEEFFormat:
+	default:
+		return "", "", nil, fmt.Errorf("invalid syslog format %q: must be one of %s or %s", format, CEFFormat, LEEFFormat)
+	}
+	return network, u.Host, tlsConfig, nil
+}
+
+// WriteAuthEvent queues the event to be sent to the syslog server, dropping
+// it if the buffer of queued events is full.
+func (s *SyslogSink) WriteAuthEvent(event logger.AuthEvent) {
+	select {
+	case s.events <- event:
+	default:
+	}
+}
+
+// Close stops sending events to the syslog server.
+func (s *SyslogSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.removeQueue()
+		close(s.done)
+	})
+	return nil
+}
+
+// run sends the queued events to the syslog server until the sink is closed,
+// reconnecting whenever the connection fails.
+func (s *SyslogSink) run() {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	failing := false
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.events:
+			msg := s.message(event)
+
+			var err error
+			// Retry once on a new connection, as the server may have closed
+			// an idle connection
+			for attempt := 0; attempt < 2; attempt++ {
+				if conn == nil {
+					conn, err = s.dial()
+					if err != nil {
+						break
+					}
+				}
+				if err = s.send(conn, msg); err == nil {
+					break
+				}
+				conn.Close()
+				conn = nil
+			}
+			s.worker.Record(err)
+
+			// Only log the first of consecutive failures, to avoid flooding
+			// the log while the server is unavailable
+			if err != nil && !failing {
+				logger.Errorf("Error sending auth events to syslog server %s: %v", s.address, err)
+			}
+			failing = err != nil
+		}
+	}
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, s.network, s.address, s.tlsConfig)
+	}
+	return dialer.Dial(s.network, s.address)
+}
+
+// send writes the message to the connection, framed by octet counting
+// (RFC 6587) on stream connections.
+func (s *SyslogSink) send(conn net.Conn, msg string) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)); err != nil {
+		return err
+	}
+	if s.network == "udp" {
+		_, err := conn.Write([]byte(msg))
+		return err
+	}
+	_, err := fmt.Fprintf(conn, "%d %s", len(msg), msg)
+	return err
+}
+
+// message formats the event as an RFC 5424 syslog message.
+func (s *SyslogSink) message(event logger.AuthEvent) string {
+	severity := syslogSeverityError
+	switch event.Status {
+	case logger.AuthSuccess:
+		severity = syslogSeverityInfo
+	case logger.AuthFailure:
+		severity = syslogSeverityWarning
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		syslogFacilityAuthPriv*8+severity,
+		event.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname,
+		product,
+		os.Getpid(),
+		event.Status,
+		formatEvent(s.format, event),
+	)
+}
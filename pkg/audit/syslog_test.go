@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogSink", func() {
+	var listener net.Listener
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	// readMessage reads a message framed by octet counting from the connection
+	readMessage := func(r *bufio.Reader) string {
+		length, err := r.ReadString(' ')
+		Expect(err).ToNot(HaveOccurred())
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		Expect(err).ToNot(HaveOccurred())
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		Expect(err).ToNot(HaveOccurred())
+		return string(msg)
+	}
+
+	It("sends events as RFC 5424 messages over TCP", func() {
+		sink, err := NewSyslogSink("tcp://"+listener.Addr().String(), CEFFormat, "")
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testEvent)
+		sink.WriteAuthEvent(testEvent)
+
+		conn, err := listener.Accept()
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		hostname, err := os.Hostname()
+		Expect(err).ToNot(HaveOccurred())
+		expected := fmt.Sprintf("<84>1 2022-03-01T12:30:00.000000Z %s oauth2-proxy %d AuthFailure - %s", hostname, os.Getpid(), formatCEF(testEvent))
+		Expect(readMessage(r)).To(Equal(expected))
+		Expect(readMessage(r)).To(Equal(expected))
+	})
+
+	DescribeTable("NewSyslogSink returns errors",
+		func(address, format, caFile, expected string) {
+			_, err := NewSyslogSink(address, format, caFile)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("without a port", "tcp://syslog.example.com", CEFFormat, "", `invalid syslog address "tcp://syslog.example.com": address syslog.example.com: missing port in address`),
+		Entry("with an unknown scheme", "http://syslog.example.com:514", CEFFormat, "", `invalid syslog address "http://syslog.example.com:514": scheme must be one of tcp, tls or udp`),
+		Entry("with an unknown format", "udp://syslog.example.com:514", "json", "", `invalid syslog format "json": must be one of cef or leef`),
+		Entry("with a missing CA file", "tls://syslog.example.com:6514", CEFFormat, "/nonexistent/ca.pem", `failed to load syslog CA file "/nonexistent/ca.pem": open /nonexistent/ca.pem: no such file or directory`),
+	)
+})
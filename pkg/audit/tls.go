@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// clientTLSConfig creates the TLS configuration of connections to the servers
+// of a sink, trusting the certificates in the caFile as well as the system
+// certificates.
+func clientTLSConfig(sink, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return config, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	certs, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s CA file %q: %v", sink, caFile, err)
+	}
+	if !rootCAs.AppendCertsFromPEM(certs) {
+		return nil, fmt.Errorf("no certificates found in %s CA file %q", sink, caFile)
+	}
+	config.RootCAs = rootCAs
+	return config, nil
+}
@@ -0,0 +1,353 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+const (
+	// WebhookTimestampHeader is the header of the Unix time at which a
+	// batch of events was sent
+	WebhookTimestampHeader = "X-OAuth2-Proxy-Timestamp"
+	// WebhookSignatureHeader is the header of the HMAC-SHA256 signature of
+	// the timestamp and body of a batch of events
+	WebhookSignatureHeader = "X-OAuth2-Proxy-Signature"
+
+	// webhookQueueSize is the number of events queued in memory, after which
+	// events overflow to the buffer file, or are dropped without one
+	webhookQueueSize = 1024
+
+	webhookRequestTimeout = 30 * time.Second
+)
+
+// The delays between retries of a batch the webhook failed to receive, which
+// doubles with each retry. They are variables so that tests can shorten them.
+var (
+	webhookMinBackoff = time.Second
+	webhookMaxBackoff = time.Minute
+)
+
+// webhookBufferMutex serializes access to buffer files, which are shared by
+// the sink replaced on a reload of the configuration and its replacement.
+var webhookBufferMutex sync.Mutex
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	// URL receives batches of events as JSON POST requests
+	URL string
+	// Secret signs the batches, when it is given
+	Secret []byte
+	// BatchSize is the maximum number of events in a batch
+	BatchSize int
+	// FlushInterval is the longest that an event waits to be sent in a batch
+	FlushInterval time.Duration
+	// BufferFile, when given, holds the events that could not be sent while
+	// the webhook is unavailable, until they can be
+	BufferFile string
+}
+
+// webhookBatch is the body of a request to the webhook.
+type webhookBatch struct {
+	Events []jsonEvent `json:"events"`
+}
+
+// WebhookSink sends auth events to a webhook in batches, retrying batches the
+// webhook fails to receive with an exponential backoff.
+// Events that cannot be queued while the webhook is unavailable overflow to
+// the buffer file, and are sent once the webhook is available again.
+type WebhookSink struct {
+	config WebhookConfig
+	client *http.Client
+
+	events  chan jsonEvent
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	worker      *workers.Worker
+	removeQueue func()
+}
+
+// NewWebhookSink creates a sink sending events to the webhook.
+func NewWebhookSink(config WebhookConfig) (*WebhookSink, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookSink{
+		config:  config,
+		client:  &http.Client{Timeout: webhookRequestTimeout},
+		events:  make(chan jsonEvent, webhookQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+		worker:  workers.New("audit_webhook", 0),
+	}
+	s.removeQueue = workers.RegisterQueue("audit_webhook", func() int { return len(s.events) })
+	go s.run()
+	return s, nil
+}
+
+// validate checks the configuration of a webhook sink.
+func (c WebhookConfig) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL %q: %v", c.URL, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: must be an absolute http or https URL", c.URL)
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("invalid webhook batch size %d: must be at least 1", c.BatchSize)
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("invalid webhook flush interval %s: must be positive", c.FlushInterval)
+	}
+	return nil
+}
+
+// WriteAuthEvent queues the event to be sent to the webhook. When the queue is
+// full, the event overflows to the buffer file, or is dropped without one.
+func (s *WebhookSink) WriteAuthEvent(event logger.AuthEvent) {
+	e := newJSONEvent(event)
+	select {
+	case s.events <- e:
+	default:
+		s.overflow([]jsonEvent{e})
+	}
+}
+
+// Close stops sending events to the webhook, and waits for the events that
+// have not been sent to overflow to the buffer file.
+func (s *WebhookSink) Close() error {
+	s.cancel()
+	<-s.stopped
+	s.removeQueue()
+	return nil
+}
+
+// run batches the queued events and sends them to the webhook until the sink
+// is closed.
+func (s *WebhookSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []jsonEvent
+	s.sendBuffered()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.overflow(append(batch, s.dequeueAll()...))
+			return
+		case e := <-s.events:
+			batch = append(batch, e)
+			if len(batch) < s.config.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				s.sendBuffered()
+				continue
+			}
+		}
+
+		if !s.send(batch) {
+			s.overflow(append(batch, s.dequeueAll()...))
+			return
+		}
+		batch = nil
+		s.sendBuffered()
+	}
+}
+
+// dequeueAll returns the events remaining in the queue.
+func (s *WebhookSink) dequeueAll() []jsonEvent {
+	var events []jsonEvent
+	for {
+		select {
+		case e := <-s.events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// send sends the batch to the webhook, retrying until it is received or the
+// sink is closed. It returns false when the sink was closed first.
+func (s *WebhookSink) send(batch []jsonEvent) bool {
+	backoff := webhookMinBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.post(batch)
+		s.worker.Record(err)
+		if err == nil {
+			if attempt > 0 {
+				logger.Printf("Sent %d auth events to webhook after %d retries", len(batch), attempt)
+			}
+			return true
+		}
+		if attempt == 0 {
+			logger.Errorf("Error sending auth events to webhook, retrying: %v", err)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+	}
+}
+
+// post sends the batch to the webhook in a single request.
+func (s *WebhookSink) post(batch []jsonEvent) error {
+	body, err := json.Marshal(webhookBatch{Events: batch})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.config.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhookBatch(s.config.Secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, s.config.URL)
+	}
+	return nil
+}
+
+// signWebhookBatch returns the hex encoded HMAC-SHA256 of the timestamp and
+// body of a batch, separated by a `.`.
+func signWebhookBatch(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// overflow appends the events to the buffer file, one JSON object per line,
+// or drops them without a buffer file.
+func (s *WebhookSink) overflow(events []jsonEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if s.config.BufferFile == "" {
+		logger.Errorf("Dropped %d auth events that could not be sent to the webhook", len(events))
+		return
+	}
+
+	webhookBufferMutex.Lock()
+	defer webhookBufferMutex.Unlock()
+
+	f, err := os.OpenFile(s.config.BufferFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Errorf("Dropped %d auth events: error opening webhook buffer file: %v", len(events), err)
+		return
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			logger.Errorf("Error writing auth event to webhook buffer file: %v", err)
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		logger.Errorf("Error writing auth events to webhook buffer file: %v", err)
+	}
+}
+
+// sendBuffered sends the events in the buffer file to the webhook, returning
+// them to the buffer file if the sink is closed first.
+func (s *WebhookSink) sendBuffered() {
+	events := s.takeBuffered()
+	for len(events) > 0 {
+		n := s.config.BatchSize
+		if n > len(events) {
+			n = len(events)
+		}
+		if !s.send(events[:n]) {
+			s.overflow(events)
+			return
+		}
+		events = events[n:]
+	}
+}
+
+// takeBuffered reads and empties the buffer file.
+func (s *WebhookSink) takeBuffered() []jsonEvent {
+	if s.config.BufferFile == "" {
+		return nil
+	}
+
+	webhookBufferMutex.Lock()
+	defer webhookBufferMutex.Unlock()
+
+	f, err := os.Open(s.config.BufferFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("Error opening webhook buffer file: %v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var events []jsonEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e jsonEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logger.Errorf("Skipping invalid auth event in webhook buffer file: %v", err)
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		// Leave the buffer file as it is, to be read again later
+		logger.Errorf("Error reading webhook buffer file: %v", err)
+		return nil
+	}
+
+	if err := os.Truncate(s.config.BufferFile, 0); err != nil {
+		logger.Errorf("Error emptying webhook buffer file: %v", err)
+		return nil
+	}
+	return events
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebhookSink", func() {
+	var server *httptest.Server
+	var batches chan webhookBatch
+	var failures int32
+	var dir string
+
+	BeforeEach(func() {
+		webhookMinBackoff = 10 * time.Millisecond
+		batches = make(chan webhookBatch, 10)
+		atomic.StoreInt32(&failures, 0)
+
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).ToNot(HaveOccurred())
+			if timestamp := req.Header.Get(WebhookTimestampHeader); timestamp != "" {
+				Expect(req.Header.Get(WebhookSignatureHeader)).To(Equal("sha256=" + signWebhookBatch([]byte("secret"), timestamp, body)))
+			}
+
+			var batch webhookBatch
+			Expect(json.Unmarshal(body, &batch)).To(Succeed())
+			batches <- batch
+		}))
+
+		var err error
+		dir, err = ioutil.TempDir("", "oauth2-proxy-webhook")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		webhookMinBackoff = time.Second
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("sends signed batches of events", func() {
+		sink, err := NewWebhookSink(WebhookConfig{URL: server.URL, Secret: []byte("secret"), BatchSize: 2, FlushInterval: time.Hour})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testEvent)
+		sink.WriteAuthEvent(testEvent)
+
+		var batch webhookBatch
+		Eventually(batches).Should(Receive(&batch))
+		Expect(batch.Events).To(HaveLen(2))
+		Expect(batch.Events[0]).To(Equal(jsonEvent{
+			Timestamp:     testEvent.Timestamp,
+			RequestID:     testEvent.RequestID,
+			Client:        testEvent.Client,
+			Host:          testEvent.Host,
+			Protocol:      testEvent.Protocol,
+			RequestMethod: testEvent.RequestMethod,
+			RequestURI:    testEvent.RequestURI,
+			UserAgent:     testEvent.UserAgent,
+			User:          testEvent.Username,
+			Provider:      testEvent.Provider,
+			Status:        logger.AuthFailure,
+			Message:       testEvent.Message,
+		}))
+	})
+
+	It("sends the details of security events", func() {
+		sink, err := NewWebhookSink(WebhookConfig{URL: server.URL, BatchSize: 1, FlushInterval: time.Hour})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testSecurityEvent)
+
+		var batch webhookBatch
+		Eventually(batches).Should(Receive(&batch))
+		Expect(batch.Events).To(HaveLen(1))
+		Expect(batch.Events[0].Event).To(Equal(logger.EventLoginSuccess))
+		Expect(batch.Events[0].ACR).To(Equal(testSecurityEvent.ACR))
+		Expect(batch.Events[0].AMR).To(Equal(testSecurityEvent.AMR))
+	})
+
+	It("sends incomplete batches after the flush interval", func() {
+		sink, err := NewWebhookSink(WebhookConfig{URL: server.URL, BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testEvent)
+
+		var batch webhookBatch
+		Eventually(batches).Should(Receive(&batch))
+		Expect(batch.Events).To(HaveLen(1))
+	})
+
+	It("retries batches the webhook fails to receive", func() {
+		atomic.StoreInt32(&failures, 2)
+		sink, err := NewWebhookSink(WebhookConfig{URL: server.URL, BatchSize: 1, FlushInterval: time.Hour})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.WriteAuthEvent(testEvent)
+
+		var batch webhookBatch
+		Eventually(batches).Should(Receive(&batch))
+		Expect(batch.Events).To(HaveLen(1))
+	})
+
+	It("buffers the events that were not sent when it is closed", func() {
+		bufferFile := filepath.Join(dir, "buffer")
+		atomic.StoreInt32(&failures, 1000)
+		sink, err := NewWebhookSink(WebhookConfig{URL: server.URL, BatchSize: 1, FlushInterval: time.Hour, BufferFile: bufferFile})
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.WriteAuthEvent(testEvent)
+		sink.WriteAuthEvent(testEvent)
+		Eventually(func() int32 { return atomic.LoadInt32(&failures) }).Should(BeNumerically("<", 999))
+		Expect(sink.Close()).To(Succeed())
+
+		buffered, err := ioutil.ReadFile(bufferFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buffered).ToNot(BeEmpty())
+
+		atomic.StoreInt32(&failures, 0)
+		sink, err = NewWebhookSink(WebhookConfig{URL: server.URL, BatchSize: 10, FlushInterval: time.Hour, BufferFile: bufferFile})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		var batch webhookBatch
+		Eventually(batches).Should(Receive(&batch))
+		Expect(batch.Events).To(HaveLen(2))
+
+		buffered, err = ioutil.ReadFile(bufferFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buffered).To(BeEmpty())
+	})
+
+	DescribeTable("NewWebhookSink returns errors",
+		func(config WebhookConfig, expected string) {
+			_, err := NewWebhookSink(config)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("with a relative URL", WebhookConfig{URL: "/events", BatchSize: 1, FlushInterval: time.Second}, `invalid webhook URL "/events": must be an absolute http or https URL`),
+		Entry("without a batch size", WebhookConfig{URL: "https://siem.example.com/events", FlushInterval: time.Second}, "invalid webhook batch size 0: must be at least 1"),
+		Entry("without a flush interval", WebhookConfig{URL: "https://siem.example.com/events", BatchSize: 1}, "invalid webhook flush interval 0s: must be positive"),
+	)
+})
@@ -0,0 +1,349 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The environment variables used to configure the credentials and region.
+const (
+	AccessKeyIDEnv          = "AWS_ACCESS_KEY_ID"
+	SecretAccessKeyEnv      = "AWS_SECRET_ACCESS_KEY"
+	SessionTokenEnv         = "AWS_SESSION_TOKEN"
+	RegionEnv               = "AWS_REGION"
+	DefaultRegionEnv        = "AWS_DEFAULT_REGION"
+	WebIdentityTokenFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	RoleARNEnv              = "AWS_ROLE_ARN"
+	RoleSessionNameEnv      = "AWS_ROLE_SESSION_NAME"
+	STSEndpointEnv          = "AWS_ENDPOINT_URL_STS"
+
+	ContainerCredentialsFullURIEnv     = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	ContainerCredentialsRelativeURIEnv = "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"
+	ContainerAuthorizationTokenEnv     = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+	ContainerAuthorizationTokenFileEnv = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+
+	InstanceMetadataEndpointEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+)
+
+const (
+	containerCredentialsHost = "http://169.254.170.2"
+	instanceMetadataEndpoint = "http://169.254.169.254"
+
+	// expiryWindow is how long before they expire that credentials are
+	// refreshed, so that requests are never signed with expired credentials.
+	expiryWindow = 5 * time.Minute
+
+	// metadataTimeout limits requests to the container and instance metadata
+	// endpoints, which do not respond when not running in ECS or EC2.
+	metadataTimeout = 5 * time.Second
+)
+
+// Credentials are the AWS credentials used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Expires is when temporary credentials expire, and is zero for
+	// credentials that do not expire.
+	Expires time.Time
+}
+
+// expired determines whether the credentials have expired, or are about to.
+func (c *Credentials) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && now.Add(expiryWindow).After(c.Expires)
+}
+
+// CredentialsProvider retrieves AWS credentials from the environment of the
+// process, in the same order as the AWS SDKs: environment variables, a web
+// identity token (as used by EKS service accounts), the ECS container
+// credentials endpoint and finally the EC2 instance metadata service.
+// Temporary credentials are cached until shortly before they expire.
+type CredentialsProvider struct {
+	client *http.Client
+
+	mutex sync.Mutex
+	creds *Credentials
+}
+
+// NewCredentialsProvider creates a CredentialsProvider that uses the client
+// to retrieve temporary credentials.
+func NewCredentialsProvider(client *http.Client) *CredentialsProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &CredentialsProvider{client: client}
+}
+
+// Retrieve returns the current credentials, retrieving new credentials when
+// there are none or they are about to expire.
+func (p *CredentialsProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.creds != nil && !p.creds.expired(time.Now()) {
+		return p.creds, nil
+	}
+
+	creds, err := p.retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+// Sign signs the request for the AWS service in the region with the current
+// credentials. The body must be the body the request is sent with.
+func (p *CredentialsProvider) Sign(req *http.Request, region, service string, body []byte) error {
+	creds, err := p.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+	SignRequest(req, creds, region, service, body, time.Now())
+	return nil
+}
+
+func (p *CredentialsProvider) retrieve(ctx context.Context) (*Credentials, error) {
+	if accessKeyID := os.Getenv(AccessKeyIDEnv); accessKeyID != "" {
+		return &Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: os.Getenv(SecretAccessKeyEnv),
+			SessionToken:    os.Getenv(SessionTokenEnv),
+		}, nil
+	}
+
+	if os.Getenv(WebIdentityTokenFileEnv) != "" && os.Getenv(RoleARNEnv) != "" {
+		creds, err := p.assumeRoleWithWebIdentity(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not assume role with web identity: %v", err)
+		}
+		return creds, nil
+	}
+
+	if os.Getenv(ContainerCredentialsFullURIEnv) != "" || os.Getenv(ContainerCredentialsRelativeURIEnv) != "" {
+		creds, err := p.containerCredentials(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve container credentials: %v", err)
+		}
+		return creds, nil
+	}
+
+	creds, err := p.instanceCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials found in the environment, and could not retrieve instance credentials: %v", err)
+	}
+	return creds, nil
+}
+
+// Region returns the AWS region configured in the environment.
+func Region() string {
+	if region := os.Getenv(RegionEnv); region != "" {
+		return region
+	}
+	return os.Getenv(DefaultRegionEnv)
+}
+
+// assumeRoleWithWebIdentityResponse is the response of the STS
+// AssumeRoleWithWebIdentity action.
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// assumeRoleWithWebIdentity exchanges the web identity token for temporary
+// credentials of the role with STS.
+func (p *CredentialsProvider) assumeRoleWithWebIdentity(ctx context.Context) (*Credentials, error) {
+	token, err := ioutil.ReadFile(os.Getenv(WebIdentityTokenFileEnv))
+	if err != nil {
+		return nil, fmt.Errorf("could not read web identity token: %v", err)
+	}
+
+	sessionName := os.Getenv(RoleSessionNameEnv)
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("oauth2-proxy-%d", time.Now().Unix())
+	}
+
+	endpoint := os.Getenv(STSEndpointEnv)
+	if endpoint == "" {
+		endpoint = "https://sts.amazonaws.com"
+		if region := Region(); region != "" {
+			endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+		}
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv(RoleARNEnv)},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("could not parse response: %v", err)
+	}
+	if resp.Credentials.AccessKeyID == "" {
+		return nil, errors.New("response did not include credentials")
+	}
+	return &Credentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+		Expires:         resp.Credentials.Expiration,
+	}, nil
+}
+
+// metadataCredentials are the credentials returned by the container and
+// instance metadata endpoints.
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (m *metadataCredentials) credentials() (*Credentials, error) {
+	if m.AccessKeyID == "" {
+		return nil, errors.New("response did not include credentials")
+	}
+	return &Credentials{
+		AccessKeyID:     m.AccessKeyID,
+		SecretAccessKey: m.SecretAccessKey,
+		SessionToken:    m.Token,
+		Expires:         m.Expiration,
+	}, nil
+}
+
+// containerCredentials retrieves the credentials of the ECS task role from the
+// container credentials endpoint.
+func (p *CredentialsProvider) containerCredentials(ctx context.Context) (*Credentials, error) {
+	endpoint := os.Getenv(ContainerCredentialsFullURIEnv)
+	if endpoint == "" {
+		endpoint = containerCredentialsHost + os.Getenv(ContainerCredentialsRelativeURIEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token := os.Getenv(ContainerAuthorizationTokenEnv)
+	if tokenFile := os.Getenv(ContainerAuthorizationTokenFileEnv); tokenFile != "" {
+		data, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read authorization token: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	body, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds metadataCredentials
+	if err := json.Unmarshal(body, &creds); err != nil {
+		return nil, fmt.Errorf("could not parse response: %v", err)
+	}
+	return creds.credentials()
+}
+
+// instanceCredentials retrieves the credentials of the role of the EC2
+// instance profile from the instance metadata service, using IMDSv2.
+func (p *CredentialsProvider) instanceCredentials(ctx context.Context) (*Credentials, error) {
+	endpoint := os.Getenv(InstanceMetadataEndpointEnv)
+	if endpoint == "" {
+		endpoint = instanceMetadataEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve metadata token: %v", err)
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return p.do(req)
+	}
+
+	roles, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve instance role: %v", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("the instance has no role")
+	}
+
+	body, err := get("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return nil, err
+	}
+	var creds metadataCredentials
+	if err := json.Unmarshal(body, &creds); err != nil {
+		return nil, fmt.Errorf("could not parse response: %v", err)
+	}
+	return creds.credentials()
+}
+
+// do sends the request and returns the body of a successful response.
+func (p *CredentialsProvider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, req.URL.Redacted(), strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,159 @@
+package aws
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	envs := []string{
+		AccessKeyIDEnv, SecretAccessKeyEnv, SessionTokenEnv, RegionEnv, DefaultRegionEnv,
+		WebIdentityTokenFileEnv, RoleARNEnv, RoleSessionNameEnv, STSEndpointEnv,
+		ContainerCredentialsFullURIEnv, ContainerCredentialsRelativeURIEnv,
+		ContainerAuthorizationTokenEnv, ContainerAuthorizationTokenFileEnv,
+		InstanceMetadataEndpointEnv,
+	}
+	saved := map[string]string{}
+
+	BeforeEach(func() {
+		for _, env := range envs {
+			if value, ok := os.LookupEnv(env); ok {
+				saved[env] = value
+			}
+			Expect(os.Unsetenv(env)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		for _, env := range envs {
+			Expect(os.Unsetenv(env)).To(Succeed())
+		}
+		for env, value := range saved {
+			Expect(os.Setenv(env, value)).To(Succeed())
+		}
+	})
+
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	It("uses credentials from the environment", func() {
+		Expect(os.Setenv(AccessKeyIDEnv, "AKIDEXAMPLE")).To(Succeed())
+		Expect(os.Setenv(SecretAccessKeyEnv, "secret")).To(Succeed())
+		Expect(os.Setenv(SessionTokenEnv, "token")).To(Succeed())
+
+		creds, err := NewCredentialsProvider(nil).Retrieve(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(creds).To(Equal(&Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}))
+	})
+
+	It("assumes a role with a web identity token", func() {
+		var form map[string][]string
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			Expect(req.ParseForm()).To(Succeed())
+			form = req.PostForm
+			rw.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>` + expiration.Format(time.RFC3339) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+		}))
+		defer server.Close()
+
+		tokenFile, err := ioutil.TempFile("", "oauth2-proxy-test-web-identity-token")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(tokenFile.Name())
+		_, err = tokenFile.Write([]byte("web-identity-token\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tokenFile.Close()).To(Succeed())
+
+		Expect(os.Setenv(WebIdentityTokenFileEnv, tokenFile.Name())).To(Succeed())
+		Expect(os.Setenv(RoleARNEnv, "arn:aws:iam::123456789012:role/oauth2-proxy")).To(Succeed())
+		Expect(os.Setenv(STSEndpointEnv, server.URL)).To(Succeed())
+
+		creds, err := NewCredentialsProvider(server.Client()).Retrieve(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(creds).To(Equal(&Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", Expires: expiration}))
+		Expect(form["Action"]).To(ConsistOf("AssumeRoleWithWebIdentity"))
+		Expect(form["RoleArn"]).To(ConsistOf("arn:aws:iam::123456789012:role/oauth2-proxy"))
+		Expect(form["WebIdentityToken"]).To(ConsistOf("web-identity-token"))
+	})
+
+	It("uses the container credentials endpoint", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "container-token" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.Write([]byte(`{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"token","Expiration":"` + expiration.Format(time.RFC3339) + `"}`))
+		}))
+		defer server.Close()
+
+		Expect(os.Setenv(ContainerCredentialsFullURIEnv, server.URL+"/credentials")).To(Succeed())
+		Expect(os.Setenv(ContainerAuthorizationTokenEnv, "container-token")).To(Succeed())
+
+		creds, err := NewCredentialsProvider(server.Client()).Retrieve(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(creds).To(Equal(&Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", Expires: expiration}))
+	})
+
+	Context("with the instance metadata service", func() {
+		var server *httptest.Server
+		var requests int
+
+		BeforeEach(func() {
+			requests = 0
+			mux := http.NewServeMux()
+			mux.HandleFunc("/latest/api/token", func(rw http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal(http.MethodPut))
+				rw.Write([]byte("metadata-token"))
+			})
+			mux.HandleFunc("/latest/meta-data/iam/security-credentials/", func(rw http.ResponseWriter, req *http.Request) {
+				Expect(req.Header.Get("X-aws-ec2-metadata-token")).To(Equal("metadata-token"))
+				if req.URL.Path == "/latest/meta-data/iam/security-credentials/" {
+					rw.Write([]byte("oauth2-proxy\n"))
+					return
+				}
+				Expect(req.URL.Path).To(HaveSuffix("/oauth2-proxy"))
+				requests++
+				rw.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"token","Expiration":"` + expiration.Format(time.RFC3339) + `"}`))
+			})
+			server = httptest.NewServer(mux)
+			Expect(os.Setenv(InstanceMetadataEndpointEnv, server.URL)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("retrieves the credentials of the instance role, and caches them until they expire", func() {
+			provider := NewCredentialsProvider(server.Client())
+			creds, err := provider.Retrieve(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(creds).To(Equal(&Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", Expires: expiration}))
+
+			_, err = provider.Retrieve(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests).To(Equal(1))
+		})
+
+		It("signs requests with the credentials", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://secretsmanager.us-east-1.amazonaws.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(NewCredentialsProvider(server.Client()).Sign(req, "us-east-1", "secretsmanager", nil)).To(Succeed())
+			Expect(req.Header.Get("X-Amz-Security-Token")).To(Equal("token"))
+			Expect(req.Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/"))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+package aws
+
+import (
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SigV4", func() {
+	// The credentials, date and signatures are from the get-vanilla and
+	// get-vanilla-query-order-key-case tests of the AWS Signature Version 4
+	// test suite.
+	creds := &Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	It("signs a request", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		SignRequest(req, creds, "us-east-1", "service", nil, now)
+		Expect(req.Header.Get("X-Amz-Date")).To(Equal("20150830T123600Z"))
+		Expect(req.Header.Get("Authorization")).To(Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"))
+	})
+
+	It("signs a request with a query", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		SignRequest(req, creds, "us-east-1", "service", nil, now)
+		Expect(req.Header.Get("Authorization")).To(HaveSuffix("Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"))
+	})
+
+	It("includes the session token and the S3 payload hash", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://bucket.s3.us-east-1.amazonaws.com/config.yaml", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		SignRequest(req, &Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}, "us-east-1", "s3", nil, now)
+		Expect(req.Header.Get("X-Amz-Security-Token")).To(Equal("token"))
+		Expect(req.Header.Get("X-Amz-Content-Sha256")).To(Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+		Expect(req.Header.Get("Authorization")).To(ContainSubstring("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,"))
+	})
+})
@@ -0,0 +1,121 @@
+package brotli
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// The base lengths and extra bits of the insert and copy length codes.
+var (
+	insertBase      = [24]int{0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98, 130, 194, 322, 578, 1090, 2114, 6210, 22594}
+	insertExtraBits = [24]uint{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24}
+	copyBase        = [24]int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54, 70, 102, 134, 198, 326, 582, 1094, 2118}
+	copyExtraBits   = [24]uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24}
+)
+
+// commandBase is the first insert and copy length symbol of the cells of
+// insert and copy length codes in groups of 8, indexed by the group of the
+// insert length code and then by the group of the copy length code, of the
+// symbols that are followed by a distance code.
+var commandBase = [3][3]int{
+	{128, 192, 384},
+	{256, 320, 512},
+	{448, 576, 640},
+}
+
+// findCommands finds the commands that produce the data from start, with
+// copies of the data up to windowSize bytes before.
+func findCommands(data []byte, start int) []command {
+	var head [1 << hashBits]int32
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(data))
+	insert := func(i int) {
+		if i+minMatch <= len(data) {
+			h := hash(data[i:])
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+	for i := 0; i < start; i++ {
+		insert(i)
+	}
+
+	var commands []command
+	literals := start
+	for i := start; i < len(data); {
+		length, distance := 0, 0
+		if i+minMatch <= len(data) {
+			for j, n := int(head[hash(data[i:])]), 0; j >= 0 && i-j <= windowSize && n < maxChain; j, n = int(prev[j]), n+1 {
+				if l := matchLength(data[j:], data[i:]); l > length {
+					length, distance = l, i-j
+				}
+			}
+		}
+		if length < minMatch {
+			insert(i)
+			i++
+			continue
+		}
+
+		commands = append(commands, command{insertLen: i - literals, copyLen: length, distance: distance})
+		for end := i + length; i < end; i++ {
+			insert(i)
+		}
+		literals = i
+	}
+	if literals < len(data) {
+		commands = append(commands, command{insertLen: len(data) - literals})
+	}
+	return commands
+}
+
+// hash hashes the first minMatch bytes of b.
+func hash(b []byte) uint32 {
+	return (binary.LittleEndian.Uint32(b) * 0x1e35a7bd) >> (32 - hashBits)
+}
+
+// matchLength returns the length of the common prefix of a and b.
+func matchLength(a, b []byte) int {
+	n := 0
+	for n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// insertLengthCode returns the insert length code of the length.
+func insertLengthCode(length int) int {
+	code := 0
+	for code+1 < len(insertBase) && insertBase[code+1] <= length {
+		code++
+	}
+	return code
+}
+
+// copyLengthCode returns the copy length code of the length.
+func copyLengthCode(length int) int {
+	code := 0
+	for code+1 < len(copyBase) && copyBase[code+1] <= length {
+		code++
+	}
+	return code
+}
+
+// commandSymbol returns the insert and copy length symbol of the command.
+// The symbols used are always followed by a distance code, except for a last
+// command that only inserts literals, whose copy length is not read.
+func commandSymbol(cmd command) int {
+	insCode, copyCode := insertLengthCode(cmd.insertLen), copyLengthCode(cmd.copyLen)
+	return commandBase[insCode>>3][copyCode>>3] | (insCode&7)<<3 | copyCode&7
+}
+
+// distanceCode returns the distance code of the distance, with its extra
+// bits, without the last distances, NPOSTFIX and NDIRECT being used.
+func distanceCode(distance int) (code int, extraBits uint, extra int) {
+	x := distance - 1 + 4
+	extraBits = uint(bits.Len(uint(x)) - 2)
+	high := x >> extraBits
+	return 16 + 2*(int(extraBits)-1) + high - 2, extraBits, x - high<<extraBits
+}
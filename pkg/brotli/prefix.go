@@ -0,0 +1,292 @@
+package brotli
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// bitWriter packs bits from the least significant bit of each byte.
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nbits uint
+}
+
+// writeBits writes the n lowest bits of v.
+func (bw *bitWriter) writeBits(n uint, v uint64) {
+	bw.bits |= v << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.bits))
+		bw.bits >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// alignToByte pads the last byte with zero bits.
+func (bw *bitWriter) alignToByte() {
+	if bw.nbits > 0 {
+		bw.writeBits(8-bw.nbits, 0)
+	}
+}
+
+// prefixCode is a canonical prefix code, whose codes are bit reversed to be
+// written from their first bit.
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+// write writes the code of the symbol.
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	bw.writeBits(uint(c.lengths[symbol]), uint64(c.codes[symbol]))
+}
+
+// newPrefixCode creates the canonical prefix code of the code lengths.
+func newPrefixCode(lengths []uint8) prefixCode {
+	var count, next [16]int
+	for _, length := range lengths {
+		count[length]++
+	}
+	count[0] = 0
+	code := 0
+	for length := 1; length < 16; length++ {
+		code = (code + count[length-1]) << 1
+		next[length] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			codes[symbol] = bits.Reverse16(uint16(next[length])) >> (16 - length)
+			next[length]++
+		}
+	}
+	return prefixCode{lengths: lengths, codes: codes}
+}
+
+// codeLengthOrder is the order of the code lengths of the code length code.
+var codeLengthOrder = [18]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// The static code of the code lengths of the code length code.
+var (
+	staticCodes   = [6]uint64{0, 7, 3, 2, 1, 15}
+	staticLengths = [6]uint{2, 4, 3, 2, 2, 4}
+)
+
+// writePrefixCode writes the prefix code of the symbols of the histogram,
+// from an alphabet whose symbols are written with alphabetBits, and returns
+// it.
+func writePrefixCode(bw *bitWriter, histogram []int, alphabetBits uint) prefixCode {
+	lengths := huffmanLengths(histogram, 15)
+	var symbols []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) <= 4 {
+		writeSimplePrefixCode(bw, lengths, symbols, alphabetBits)
+		if len(symbols) == 1 {
+			// The single symbol of a code is written with no bits
+			lengths[symbols[0]] = 0
+		}
+	} else {
+		writeComplexPrefixCode(bw, lengths)
+	}
+	return newPrefixCode(lengths)
+}
+
+// writeSimplePrefixCode writes a prefix code of up to 4 symbols, which lists
+// the symbols in the order of their code lengths.
+func writeSimplePrefixCode(bw *bitWriter, lengths []uint8, symbols []int, alphabetBits uint) {
+	if len(symbols) == 0 {
+		// The code of an unused alphabet has a single symbol
+		symbols = []int{0}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if lengths[symbols[i]] != lengths[symbols[j]] {
+			return lengths[symbols[i]] < lengths[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	bw.writeBits(2, 1)
+	bw.writeBits(2, uint64(len(symbols)-1))
+	for _, symbol := range symbols {
+		bw.writeBits(alphabetBits, uint64(symbol))
+	}
+	if len(symbols) == 4 {
+		// The tree-select bit is set for code lengths 1, 2, 3 and 3
+		if lengths[symbols[0]] == 1 {
+			bw.writeBits(1, 1)
+		} else {
+			bw.writeBits(1, 0)
+		}
+	}
+}
+
+// writeComplexPrefixCode writes the code lengths of a prefix code, with a
+// code length code.
+func writeComplexPrefixCode(bw *bitWriter, lengths []uint8) {
+	last := len(lengths) - 1
+	for lengths[last] == 0 {
+		last--
+	}
+
+	// The code lengths are written as is, and runs of zeros with the code
+	// length 17 repeating zeros with 3 extra bits
+	type token struct {
+		length uint8
+		extra  uint64
+	}
+	var tokens []token
+	for i := 0; i <= last; {
+		if lengths[i] != 0 {
+			tokens = append(tokens, token{length: lengths[i]})
+			i++
+			continue
+		}
+		run := 0
+		for ; lengths[i] == 0; i++ {
+			run++
+		}
+		if run == 11 {
+			tokens = append(tokens, token{})
+			run--
+		}
+		if run < 3 {
+			for ; run > 0; run-- {
+				tokens = append(tokens, token{})
+			}
+			continue
+		}
+		// Consecutive repeat codes repeat zeros (previous repeat - 2) * 8
+		// times, plus 3 and their extra bits
+		first := len(tokens)
+		for run -= 3; ; run-- {
+			tokens = append(tokens, token{length: 17, extra: uint64(run & 7)})
+			run >>= 3
+			if run == 0 {
+				break
+			}
+		}
+		for j, k := first, len(tokens)-1; j < k; j, k = j+1, k-1 {
+			tokens[j], tokens[k] = tokens[k], tokens[j]
+		}
+	}
+
+	var histogram [18]int
+	for _, t := range tokens {
+		histogram[t.length]++
+	}
+	codeLengthLengths := huffmanLengths(histogram[:], 5)
+	used := 0
+	for _, length := range codeLengthLengths {
+		if length > 0 {
+			used++
+		}
+	}
+
+	// HSKIP, then the code lengths of the code length code, without the
+	// trailing zeros unless a single code length is used
+	bw.writeBits(2, 0)
+	n := len(codeLengthOrder)
+	if used > 1 {
+		for codeLengthLengths[codeLengthOrder[n-1]] == 0 {
+			n--
+		}
+	}
+	for _, symbol := range codeLengthOrder[:n] {
+		length := codeLengthLengths[symbol]
+		bw.writeBits(staticLengths[length], staticCodes[length])
+	}
+	if used == 1 {
+		// The single code length used is written with no bits
+		for symbol := range codeLengthLengths {
+			codeLengthLengths[symbol] = 0
+		}
+	}
+
+	code := newPrefixCode(codeLengthLengths)
+	for _, t := range tokens {
+		code.write(bw, int(t.length))
+		if t.length == 17 {
+			bw.writeBits(3, t.extra)
+		}
+	}
+}
+
+// huffmanLengths returns the code lengths of a Huffman code of the symbols
+// of the histogram, limited to maxLength. The counts are raised to a minimum
+// count until the code fits, which flattens the code.
+func huffmanLengths(histogram []int, maxLength int) []uint8 {
+	lengths := make([]uint8, len(histogram))
+	var symbols []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	for minCount := 1; ; minCount *= 2 {
+		count := func(symbol int) int {
+			if histogram[symbol] < minCount {
+				return minCount
+			}
+			return histogram[symbol]
+		}
+		sort.Slice(symbols, func(i, j int) bool {
+			if count(symbols[i]) != count(symbols[j]) {
+				return count(symbols[i]) < count(symbols[j])
+			}
+			return symbols[i] < symbols[j]
+		})
+
+		// The leaves are sorted by count, and the nodes created after them
+		// have increasing counts, so the two nodes with the lowest counts
+		// are always at the front of either
+		n := len(symbols)
+		counts := make([]int, n, 2*n-1)
+		for i, symbol := range symbols {
+			counts[i] = count(symbol)
+		}
+		parents := make([]int, 2*n-1)
+		leaf, node := 0, n
+		lowest := func() int {
+			if leaf < n && (node >= len(counts) || counts[leaf] <= counts[node]) {
+				leaf++
+				return leaf - 1
+			}
+			node++
+			return node - 1
+		}
+		for len(counts) < 2*n-1 {
+			a, b := lowest(), lowest()
+			parents[a], parents[b] = len(counts), len(counts)
+			counts = append(counts, counts[a]+counts[b])
+		}
+
+		depths := make([]int, 2*n-1)
+		fits := true
+		for i := 2*n - 3; i >= 0; i-- {
+			depths[i] = depths[parents[i]] + 1
+			if depths[i] > maxLength {
+				fits = false
+			}
+		}
+		if fits {
+			for i, symbol := range symbols {
+				lengths[symbol] = uint8(depths[i])
+			}
+			return lengths
+		}
+	}
+}
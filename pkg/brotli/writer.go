@@ -0,0 +1,212 @@
+// Package brotli implements a brotli (RFC 7932) encoder, to compress
+// responses with the "br" content coding.
+//
+// The encoder finds repeated data with LZ77 matching over a 64KiB window and
+// encodes each meta-block with a prefix code per alphabet. It trades some
+// compression ratio for simplicity: it does not use the static dictionary,
+// block switching or context modelling.
+package brotli
+
+import (
+	"io"
+)
+
+const (
+	// windowSize is the size of the sliding window of WBITS 16, which is
+	// encoded in the stream header as a single 0 bit.
+	windowSize = 1<<16 - 16
+
+	// blockSize is the size of the data compressed in each meta-block,
+	// unless the writer is flushed before.
+	blockSize = 1 << 16
+
+	// minMatch is the minimum length of the copies.
+	minMatch = 4
+	// maxChain is the maximum number of earlier positions compared when
+	// looking for a copy.
+	maxChain = 32
+
+	hashBits = 15
+)
+
+// Writer compresses the data written to it in the brotli format.
+type Writer struct {
+	w   io.Writer
+	bw  bitWriter
+	err error
+
+	// history holds the last data compressed, which copies can refer to.
+	history []byte
+	// pending holds the data written that has not been compressed yet.
+	pending []byte
+	closed  bool
+}
+
+// NewWriter returns a Writer that writes the data written to it compressed
+// to w. The compressed data is only complete once the Writer is closed.
+func NewWriter(w io.Writer) *Writer {
+	zw := &Writer{w: w}
+	// WBITS 16
+	zw.bw.writeBits(1, 0)
+	return zw
+}
+
+// Write writes compressed data to the underlying writer. The data may be
+// buffered until a meta-block is complete, or the Writer is flushed.
+func (zw *Writer) Write(p []byte) (int, error) {
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	zw.pending = append(zw.pending, p...)
+	for len(zw.pending) >= blockSize {
+		zw.writeMetaBlock(zw.pending[:blockSize])
+		zw.pending = zw.pending[:copy(zw.pending, zw.pending[blockSize:])]
+	}
+	if err := zw.writeOut(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush compresses the pending data and writes it to the underlying writer,
+// so that a reader can decompress all of the data written so far.
+func (zw *Writer) Flush() error {
+	if zw.err != nil {
+		return zw.err
+	}
+	if len(zw.pending) > 0 {
+		zw.writeMetaBlock(zw.pending)
+		zw.pending = nil
+	}
+	if zw.bw.nbits > 0 {
+		// An empty metadata meta-block pads the stream to a byte boundary,
+		// so that the last bits of the data are written out
+		zw.bw.writeBits(1, 0) // ISLAST
+		zw.bw.writeBits(2, 3) // MNIBBLES of metadata
+		zw.bw.writeBits(1, 0) // reserved
+		zw.bw.writeBits(2, 0) // MSKIPBYTES
+		zw.bw.alignToByte()
+	}
+	return zw.writeOut()
+}
+
+// Close compresses the pending data and completes the stream. It does not
+// close the underlying writer.
+func (zw *Writer) Close() error {
+	if zw.err != nil || zw.closed {
+		return zw.err
+	}
+	zw.closed = true
+	if len(zw.pending) > 0 {
+		zw.writeMetaBlock(zw.pending)
+		zw.pending = nil
+	}
+	zw.bw.writeBits(1, 1) // ISLAST
+	zw.bw.writeBits(1, 1) // ISLASTEMPTY
+	zw.bw.alignToByte()
+	return zw.writeOut()
+}
+
+// writeOut writes the complete bytes of the stream to the underlying writer.
+func (zw *Writer) writeOut() error {
+	if len(zw.bw.buf) == 0 {
+		return nil
+	}
+	_, zw.err = zw.w.Write(zw.bw.buf)
+	zw.bw.buf = zw.bw.buf[:0]
+	return zw.err
+}
+
+// command inserts literals, and then copies data from an earlier position.
+// The last command of a meta-block may only insert literals.
+type command struct {
+	insertLen int
+	copyLen   int
+	distance  int
+}
+
+// writeMetaBlock compresses the block in a meta-block, and adds it to the
+// history.
+func (zw *Writer) writeMetaBlock(block []byte) {
+	data := make([]byte, 0, len(zw.history)+len(block))
+	data = append(append(data, zw.history...), block...)
+	start := len(zw.history)
+	commands := findCommands(data, start)
+
+	var literalHistogram [256]int
+	var commandHistogram [704]int
+	var distanceHistogram [64]int
+	pos := start
+	for _, cmd := range commands {
+		for _, b := range data[pos : pos+cmd.insertLen] {
+			literalHistogram[b]++
+		}
+		pos += cmd.insertLen + cmd.copyLen
+		commandHistogram[commandSymbol(cmd)]++
+		if cmd.copyLen > 0 {
+			code, _, _ := distanceCode(cmd.distance)
+			distanceHistogram[code]++
+		}
+	}
+
+	bw := &zw.bw
+	size, bits, nbits := len(bw.buf), bw.bits, bw.nbits
+	writeMetaBlockHeader(bw, len(block))
+	bw.writeBits(1, 0) // ISUNCOMPRESSED
+	bw.writeBits(1, 0) // NBLTYPESL = 1
+	bw.writeBits(1, 0) // NBLTYPESI = 1
+	bw.writeBits(1, 0) // NBLTYPESD = 1
+	bw.writeBits(2, 0) // NPOSTFIX
+	bw.writeBits(4, 0) // NDIRECT
+	bw.writeBits(2, 0) // context mode of the literals
+	bw.writeBits(1, 0) // NTREESL = 1
+	bw.writeBits(1, 0) // NTREESD = 1
+	literals := writePrefixCode(bw, literalHistogram[:], 8)
+	lengths := writePrefixCode(bw, commandHistogram[:], 10)
+	distances := writePrefixCode(bw, distanceHistogram[:], 6)
+
+	pos = start
+	for _, cmd := range commands {
+		insCode, copyCode := insertLengthCode(cmd.insertLen), copyLengthCode(cmd.copyLen)
+		lengths.write(bw, commandSymbol(cmd))
+		bw.writeBits(insertExtraBits[insCode], uint64(cmd.insertLen-insertBase[insCode]))
+		if cmd.copyLen > 0 {
+			bw.writeBits(copyExtraBits[copyCode], uint64(cmd.copyLen-copyBase[copyCode]))
+		}
+		for _, b := range data[pos : pos+cmd.insertLen] {
+			literals.write(bw, int(b))
+		}
+		pos += cmd.insertLen + cmd.copyLen
+		if cmd.copyLen > 0 {
+			code, extraBits, extra := distanceCode(cmd.distance)
+			distances.write(bw, code)
+			bw.writeBits(extraBits, uint64(extra))
+		}
+	}
+
+	if len(bw.buf)-size > len(block) {
+		// Data that does not compress is stored uncompressed instead
+		bw.buf, bw.bits, bw.nbits = bw.buf[:size], bits, nbits
+		writeMetaBlockHeader(bw, len(block))
+		bw.writeBits(1, 1) // ISUNCOMPRESSED
+		bw.alignToByte()
+		bw.buf = append(bw.buf, block...)
+	}
+
+	if len(data) > windowSize {
+		data = data[len(data)-windowSize:]
+	}
+	zw.history = append(zw.history[:0], data...)
+}
+
+// writeMetaBlockHeader writes the header of a meta-block of length bytes,
+// which is not the last one.
+func writeMetaBlockHeader(bw *bitWriter, length int) {
+	bw.writeBits(1, 0) // ISLAST
+	nibbles := uint(4)
+	for length-1 >= 1<<(4*nibbles) {
+		nibbles++
+	}
+	bw.writeBits(2, uint64(nibbles-4))
+	bw.writeBits(4*nibbles, uint64(length-1))
+}
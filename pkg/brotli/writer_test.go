@@ -0,0 +1,67 @@
+package brotli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compress compresses the chunks, flushing the writer between them.
+func compress(t *testing.T, chunks ...string) []byte {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	for i, chunk := range chunks {
+		if i > 0 {
+			assert.NoError(t, w.Flush())
+		}
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+// The expected streams decompress to the input with the reference decoder.
+func TestWriter(t *testing.T) {
+	testCases := map[string]struct {
+		chunks   []string
+		expected string
+	}{
+		"empty": {
+			expected: "06",
+		},
+		"single literal": {
+			chunks:   []string{"a"},
+			expected: "0000106103",
+		},
+		"literals and copies": {
+			chunks:   []string{"hello, hello, hello world"},
+			expected: "80010000806e2cd5d3ad51c13038bcbc608927a4fd8147a23aec",
+		},
+		"flushed": {
+			chunks:   []string{"hello, hello, hello world", ""},
+			expected: "80010000806e2cd5d3ad51c13038bcbc608927a4fd8147a23aac0103",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, hex.EncodeToString(compress(t, tc.chunks...)))
+		})
+	}
+}
+
+func TestWriterCompressesRepeatedData(t *testing.T) {
+	data := string(bytes.Repeat([]byte("abcdefgh"), 50000))
+	assert.Less(t, len(compress(t, data)), 100)
+}
+
+func TestWriterStoresIncompressibleData(t *testing.T) {
+	data := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(data)
+	// Each meta-block of 64KiB adds a header of a few bytes
+	assert.LessOrEqual(t, len(compress(t, string(data))), len(data)+16)
+}
@@ -0,0 +1,92 @@
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+const (
+	hCaptchaVerifyURL     = "https://api.hcaptcha.com/siteverify"
+	hCaptchaResponseField = "h-captcha-response"
+
+	turnstileVerifyURL     = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	turnstileResponseField = "cf-turnstile-response"
+)
+
+// captcha is a challenge verified by a CAPTCHA service, hCaptcha or
+// Cloudflare Turnstile, whose widget adds its response to the sign-in forms.
+type captcha struct {
+	challengeType string
+	siteKey       string
+	secretKey     string
+	verifyURL     string
+	responseField string
+}
+
+// captchaVerification is the response of the verify endpoint of the CAPTCHA
+// service.
+type captchaVerification struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// newCaptcha creates the CAPTCHA challenge verified with the verify URL.
+func newCaptcha(opts options.SignInChallenge, verifyURL, responseField string) (*captcha, error) {
+	if opts.SiteKey == "" {
+		return nil, errors.New("a site key is required")
+	}
+	if opts.SecretKey == "" {
+		return nil, errors.New("a secret key is required")
+	}
+
+	return &captcha{
+		challengeType: opts.Type,
+		siteKey:       opts.SiteKey,
+		secretKey:     opts.SecretKey,
+		verifyURL:     verifyURL,
+		responseField: responseField,
+	}, nil
+}
+
+// Issue returns the widget of the CAPTCHA service.
+func (c *captcha) Issue() (*Page, error) {
+	return &Page{
+		Type:    c.challengeType,
+		SiteKey: c.siteKey,
+	}, nil
+}
+
+// Verify checks the response of the widget with the CAPTCHA service.
+// Responses can only be verified once.
+func (c *captcha) Verify(req *http.Request) error {
+	response := req.FormValue(c.responseField)
+	if response == "" {
+		return ErrNoResponse
+	}
+
+	params := url.Values{}
+	params.Set("secret", c.secretKey)
+	params.Set("response", response)
+
+	var verification captchaVerification
+	err := requests.New(c.verifyURL).
+		WithContext(req.Context()).
+		WithMethod(http.MethodPost).
+		WithBody(strings.NewReader(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do().
+		UnmarshalInto(&verification)
+	if err != nil {
+		return fmt.Errorf("could not verify %s response: %v", c.challengeType, err)
+	}
+	if !verification.Success {
+		return fmt.Errorf("%s response was rejected: %s", c.challengeType, strings.Join(verification.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package challenge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CAPTCHA Challenge", func() {
+	var server *httptest.Server
+	var verified url.Values
+	var c Challenge
+
+	BeforeEach(func() {
+		verified = nil
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			Expect(req.Method).To(Equal(http.MethodPost))
+			Expect(req.ParseForm()).To(Succeed())
+			verified = req.PostForm
+
+			rw.Header().Set("Content-Type", "application/json")
+			if req.PostForm.Get("response") == "valid" {
+				_, _ = rw.Write([]byte(`{"success": true}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}))
+
+		var err error
+		c, err = New(options.SignInChallenge{
+			Type:      options.SignInChallengeTurnstile,
+			SiteKey:   "site-key",
+			SecretKey: "secret-key",
+		}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		c.(*captcha).verifyURL = server.URL
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	signInRequest := func(response string) *http.Request {
+		form := url.Values{}
+		form.Set(turnstileResponseField, response)
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	It("issues the widget of the site key", func() {
+		page, err := c.Issue()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page).To(Equal(&Page{Type: options.SignInChallengeTurnstile, SiteKey: "site-key"}))
+	})
+
+	It("verifies valid responses with the service", func() {
+		Expect(c.Verify(signInRequest("valid"))).To(Succeed())
+		Expect(verified.Get("secret")).To(Equal("secret-key"))
+		Expect(verified.Get("response")).To(Equal("valid"))
+	})
+
+	It("fails responses rejected by the service", func() {
+		Expect(c.Verify(signInRequest("invalid"))).To(MatchError("turnstile response was rejected: invalid-input-response"))
+	})
+
+	It("fails requests without a response", func() {
+		Expect(c.Verify(signInRequest(""))).To(Equal(ErrNoResponse))
+		Expect(verified).To(BeNil())
+	})
+
+	It("requires a site key and a secret key", func() {
+		_, err := New(options.SignInChallenge{Type: options.SignInChallengeHCaptcha, SecretKey: "secret-key"}, nil, nil)
+		Expect(err).To(MatchError("a site key is required"))
+		_, err = New(options.SignInChallenge{Type: options.SignInChallengeHCaptcha, SiteKey: "site-key"}, nil, nil)
+		Expect(err).To(MatchError("a secret key is required"))
+	})
+})
@@ -0,0 +1,62 @@
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// ErrNoResponse is returned by Verify when the request has no response to
+// the challenge, eg because it was not submitted from the sign-in page.
+var ErrNoResponse = errors.New("no response to the sign-in challenge")
+
+// Challenge is the challenge that users pass on the sign-in page before
+// signing in, to slow automated abuse of the login endpoints.
+type Challenge interface {
+	// Issue returns the challenge rendered by the sign-in page.
+	Issue() (*Page, error)
+
+	// Verify checks the response to the challenge submitted with a sign-in
+	// form.
+	Verify(req *http.Request) error
+}
+
+// Page is the challenge rendered by the sign-in page.
+type Page struct {
+	// Type is the type of the challenge.
+	Type string
+
+	// SiteKey is the site key of the hCaptcha or Turnstile widget.
+	SiteKey string
+
+	// Token is the proof-of-work puzzle solved by the browser.
+	Token string
+
+	// Difficulty is the number of leading zero bits of the hash of
+	// proof-of-work solutions.
+	Difficulty int
+}
+
+// New creates the sign-in challenge from the options.
+// Proof-of-work puzzles are signed with the secret, which is not copied so
+// that it is wiped with the options, and are only accepted once when a replay
+// cache is given.
+// It returns nil when no challenge is configured.
+func New(opts options.SignInChallenge, secret []byte, replayCache sessions.ReplayCache) (Challenge, error) {
+	switch opts.Type {
+	case "":
+		return nil, nil
+	case options.SignInChallengeHCaptcha:
+		return newCaptcha(opts, hCaptchaVerifyURL, hCaptchaResponseField)
+	case options.SignInChallengeTurnstile:
+		return newCaptcha(opts, turnstileVerifyURL, turnstileResponseField)
+	case options.SignInChallengeProofOfWork:
+		return newProofOfWork(opts, secret, replayCache)
+	default:
+		return nil, fmt.Errorf("unknown sign-in challenge %q, must be %s, %s or %s", opts.Type,
+			options.SignInChallengeHCaptcha, options.SignInChallengeTurnstile, options.SignInChallengeProofOfWork)
+	}
+}
@@ -0,0 +1,137 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+const (
+	// ProofOfWorkLifetime is how long a proof-of-work puzzle can be solved
+	// and submitted for after it is issued
+	ProofOfWorkLifetime = 10 * time.Minute
+
+	// MaxProofOfWorkDifficulty is the highest difficulty of proof-of-work
+	// puzzles, which browsers take minutes to solve
+	MaxProofOfWorkDifficulty = 32
+
+	proofOfWorkTokenField = "pow_token"
+	proofOfWorkNonceField = "pow_nonce"
+)
+
+// proofOfWork is a challenge solved by the browser: finding a nonce such
+// that the SHA-256 hash of the puzzle token, a colon and the nonce has a
+// number of leading zero bits.
+// Tokens are signed so that they cannot be made up, and expire so that they
+// cannot be solved in advance.
+type proofOfWork struct {
+	secret      []byte
+	difficulty  int
+	replayCache sessions.ReplayCache
+	clock       clock.Clock
+}
+
+// newProofOfWork creates the proof-of-work challenge.
+func newProofOfWork(opts options.SignInChallenge, secret []byte, replayCache sessions.ReplayCache) (*proofOfWork, error) {
+	if opts.ProofOfWorkDifficulty < 1 || opts.ProofOfWorkDifficulty > MaxProofOfWorkDifficulty {
+		return nil, fmt.Errorf("proof-of-work difficulty %d must be between 1 and %d", opts.ProofOfWorkDifficulty, MaxProofOfWorkDifficulty)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("a secret is required to sign proof-of-work puzzles")
+	}
+
+	return &proofOfWork{
+		secret:      secret,
+		difficulty:  opts.ProofOfWorkDifficulty,
+		replayCache: replayCache,
+	}, nil
+}
+
+// Issue returns a new puzzle token, made of its expiry, a random nonce and
+// their signature.
+func (p *proofOfWork) Issue() (*Page, error) {
+	nonce, err := encryption.Nonce(16)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate proof-of-work nonce: %v", err)
+	}
+
+	payload := fmt.Sprintf("%d.%s", p.clock.Now().Add(ProofOfWorkLifetime).Unix(), base64.RawURLEncoding.EncodeToString(nonce))
+	return &Page{
+		Type:       options.SignInChallengeProofOfWork,
+		Token:      payload + "." + p.sign(payload),
+		Difficulty: p.difficulty,
+	}, nil
+}
+
+// Verify checks the puzzle token is valid and that the nonce solves it.
+// When there is a replay cache, each puzzle is only accepted once.
+func (p *proofOfWork) Verify(req *http.Request) error {
+	token := req.FormValue(proofOfWorkTokenField)
+	nonce := req.FormValue(proofOfWorkNonceField)
+	if token == "" || nonce == "" {
+		return ErrNoResponse
+	}
+
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(p.sign(token[:i]))) {
+		return errors.New("proof-of-work token has an invalid signature")
+	}
+	expiry, err := strconv.ParseInt(strings.SplitN(token, ".", 2)[0], 10, 64)
+	if err != nil {
+		return errors.New("proof-of-work token has an invalid expiry")
+	}
+	remaining := time.Unix(expiry, 0).Sub(p.clock.Now())
+	if remaining <= 0 {
+		return errors.New("proof-of-work token has expired")
+	}
+
+	if leadingZeroBits(sha256.Sum256([]byte(token+":"+nonce))) < p.difficulty {
+		return errors.New("proof-of-work nonce does not solve the puzzle")
+	}
+
+	if p.replayCache != nil {
+		consumed, err := p.replayCache.Consume(req.Context(), "pow-"+token[:i], remaining)
+		if err != nil {
+			return fmt.Errorf("could not check proof-of-work token replay: %v", err)
+		}
+		if !consumed {
+			return errors.New("proof-of-work token was already used")
+		}
+	}
+	return nil
+}
+
+// sign returns the signature of the puzzle token payload.
+// NOTE: Error checking (G104) is purposefully skipped:
+// `hash.Hash` interface's `Write` has an error signature, but
+// `hmac.hmac.Write` does not use it.
+/* #nosec G104 */
+func (p *proofOfWork) sign(payload string) string {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte("proof-of-work|" + payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// leadingZeroBits returns the number of leading zero bits of the hash.
+func leadingZeroBits(hash [sha256.Size]byte) int {
+	n := 0
+	for _, b := range hash {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,120 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testReplayCache is a sessions.ReplayCache that records the used keys in a
+// map, without expiring them.
+type testReplayCache map[string]bool
+
+func (c testReplayCache) Consume(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if c[key] {
+		return false, nil
+	}
+	c[key] = true
+	return true, nil
+}
+
+var _ = Describe("Proof-of-Work Challenge", func() {
+	const difficulty = 8
+
+	var pow *proofOfWork
+
+	BeforeEach(func() {
+		c, err := New(options.SignInChallenge{
+			Type:                  options.SignInChallengeProofOfWork,
+			ProofOfWorkDifficulty: difficulty,
+		}, []byte("secret"), testReplayCache{})
+		Expect(err).ToNot(HaveOccurred())
+		pow = c.(*proofOfWork)
+	})
+
+	AfterEach(func() {
+		pow.clock.Reset()
+	})
+
+	// solve finds the nonce that solves the puzzle token
+	solve := func(token string) string {
+		for i := 0; ; i++ {
+			nonce := strconv.Itoa(i)
+			if leadingZeroBits(sha256.Sum256([]byte(token+":"+nonce))) >= difficulty {
+				return nonce
+			}
+		}
+	}
+
+	signInRequest := func(token, nonce string) *http.Request {
+		form := url.Values{}
+		form.Set(proofOfWorkTokenField, token)
+		form.Set(proofOfWorkNonceField, nonce)
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	issue := func() string {
+		page, err := pow.Issue()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Type).To(Equal(options.SignInChallengeProofOfWork))
+		Expect(page.Difficulty).To(Equal(difficulty))
+		return page.Token
+	}
+
+	It("accepts solved puzzles once", func() {
+		token := issue()
+		nonce := solve(token)
+		Expect(pow.Verify(signInRequest(token, nonce))).To(Succeed())
+		Expect(pow.Verify(signInRequest(token, nonce))).To(MatchError("proof-of-work token was already used"))
+	})
+
+	It("fails unsolved puzzles", func() {
+		token := issue()
+		var nonce string
+		for i := 0; ; i++ {
+			wrong := strconv.Itoa(i)
+			if leadingZeroBits(sha256.Sum256([]byte(token+":"+wrong))) < difficulty {
+				nonce = wrong
+				break
+			}
+		}
+		Expect(pow.Verify(signInRequest(token, nonce))).To(MatchError("proof-of-work nonce does not solve the puzzle"))
+	})
+
+	It("fails tokens with an invalid signature", func() {
+		token := issue()
+		parts := strings.Split(token, ".")
+		forged := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + "." + parts[1] + "." + parts[2]
+		Expect(pow.Verify(signInRequest(forged, solve(forged)))).To(MatchError("proof-of-work token has an invalid signature"))
+	})
+
+	It("fails expired tokens", func() {
+		pow.clock.Set(time.Now())
+		token := issue()
+		Expect(pow.clock.Add(ProofOfWorkLifetime)).To(Succeed())
+		Expect(pow.Verify(signInRequest(token, solve(token)))).To(MatchError("proof-of-work token has expired"))
+	})
+
+	It("fails requests without a response", func() {
+		Expect(pow.Verify(signInRequest(issue(), ""))).To(Equal(ErrNoResponse))
+	})
+
+	It("requires a valid difficulty", func() {
+		_, err := New(options.SignInChallenge{
+			Type:                  options.SignInChallengeProofOfWork,
+			ProofOfWorkDifficulty: MaxProofOfWorkDifficulty + 1,
+		}, []byte("secret"), nil)
+		Expect(err).To(MatchError("proof-of-work difficulty 33 must be between 1 and 32"))
+	})
+})
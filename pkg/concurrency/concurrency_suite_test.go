@@ -0,0 +1,13 @@
+package concurrency
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestConcurrencySuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Concurrency")
+}
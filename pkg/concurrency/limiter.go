@@ -0,0 +1,135 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The stages whose concurrency can be limited.
+const (
+	// StageRequests is the requests served by the proxy
+	StageRequests = "requests"
+	// StageRefresh is the refreshes of sessions
+	StageRefresh = "refresh"
+	// StageProvider is the requests made to the providers
+	StageProvider = "provider"
+)
+
+// ErrOverloaded is returned when a stage is at its concurrency limit and no
+// room was made for the work within the queue timeout.
+var ErrOverloaded = errors.New("overloaded: too many concurrent requests")
+
+var rejectionsCounter = registerRejectionsCounter(prometheus.DefaultRegisterer)
+
+// Limiter limits the work done concurrently by a stage of the proxy. Work
+// over the limit waits for room up to the queue timeout, and is then rejected
+// so that an overloaded proxy fails quickly rather than queueing work until
+// it times out.
+// A nil Limiter does not limit anything.
+type Limiter struct {
+	stage        string
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewLimiter creates a limiter allowing the stage to do at most limit pieces
+// of work at once. It returns nil, which does not limit anything, when the
+// limit is not positive.
+func NewLimiter(stage string, limit int, queueTimeout time.Duration) *Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &Limiter{
+		stage:        stage,
+		slots:        make(chan struct{}, limit),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire waits for room for a piece of work, up to the queue timeout or
+// until the context is done, and returns a function to call once the work is
+// done. It returns ErrOverloaded when there is no room, or the error of the
+// context when it is done first, which is not counted as a rejection.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaser(), nil
+	default:
+	}
+
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case l.slots <- struct{}{}:
+			return l.releaser(), nil
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	rejectionsCounter.WithLabelValues(l.stage).Inc()
+	if rejected, ok := ctx.Value(rejectionsKey{}).(*int32); ok {
+		atomic.StoreInt32(rejected, 1)
+	}
+	return nil, ErrOverloaded
+}
+
+// releaser returns a function that frees the slot taken by a piece of work
+// the first time it is called.
+func (l *Limiter) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-l.slots })
+	}
+}
+
+// rejectionsKey is the key of the context value recording whether work was
+// rejected by a limiter.
+type rejectionsKey struct{}
+
+// TrackRejections returns a context that records whether any work done with
+// it was rejected by a limiter, and a function reporting it.
+// This tells work that failed because a stage was overloaded from work that
+// failed for other reasons, when the error of the limiter is not returned
+// by the code in between.
+func TrackRejections(ctx context.Context) (context.Context, func() bool) {
+	rejected := new(int32)
+	return context.WithValue(ctx, rejectionsKey{}, rejected), func() bool {
+		return atomic.LoadInt32(rejected) == 1
+	}
+}
+
+// registerRejectionsCounter registers the
+// 'oauth2_proxy_concurrency_rejections_total' metric.
+// This keeps a tally of the work rejected by the limiters, by their stage.
+func registerRejectionsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_concurrency_rejections_total",
+			Help: "Total number of requests rejected because a stage was at its concurrency limit by stage.",
+		},
+		[]string{"stage"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
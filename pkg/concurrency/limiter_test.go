@@ -0,0 +1,90 @@
+package concurrency
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Limiter", func() {
+	It("does not limit anything without a limit", func() {
+		limiter := NewLimiter(StageRequests, 0, time.Second)
+		Expect(limiter).To(BeNil())
+
+		for i := 0; i < 3; i++ {
+			release, err := limiter.Acquire(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			release()
+		}
+	})
+
+	It("rejects work over the limit once the queue timeout passes", func() {
+		limiter := NewLimiter("test-timeout", 1, 10*time.Millisecond)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		start := time.Now()
+		_, err = limiter.Acquire(context.Background())
+		Expect(err).To(MatchError(ErrOverloaded))
+		Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(testutil.ToFloat64(rejectionsCounter.WithLabelValues("test-timeout"))).To(Equal(1.0))
+
+		// Releasing twice frees a single slot
+		release()
+		release()
+		release, err = limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		_, err = limiter.Acquire(context.Background())
+		Expect(err).To(MatchError(ErrOverloaded))
+		release()
+	})
+
+	It("rejects work over the limit immediately without a queue timeout", func() {
+		limiter := NewLimiter(StageRefresh, 1, 0)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer release()
+
+		ctx, rejected := TrackRejections(context.Background())
+		Expect(rejected()).To(BeFalse())
+		_, err = limiter.Acquire(ctx)
+		Expect(err).To(MatchError(ErrOverloaded))
+		Expect(rejected()).To(BeTrue())
+	})
+
+	It("queues work until there is room for it", func() {
+		limiter := NewLimiter(StageProvider, 1, time.Second)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}()
+
+		next, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		next()
+	})
+
+	It("stops queueing work without rejecting it when its context is done", func() {
+		limiter := NewLimiter("test-context", 1, time.Minute)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		ctx, rejected := TrackRejections(ctx)
+		_, err = limiter.Acquire(ctx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(rejected()).To(BeFalse())
+		Expect(testutil.ToFloat64(rejectionsCounter.WithLabelValues("test-context"))).To(Equal(0.0))
+	})
+})
@@ -0,0 +1,71 @@
+package configsource
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// loadPublicKey loads a PEM encoded PKIX public key from the file.
+// Ed25519, ECDSA and RSA keys are supported.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("could not decode public key %q: no PEM data found", path)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key %q: %v", path, err)
+	}
+
+	switch publicKey.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T in %q", publicKey, path)
+	}
+}
+
+// verifySignature verifies the base64 encoded signature of the data.
+// Ed25519 signatures are of the data itself, while ECDSA (ASN.1 encoded) and
+// RSA (PKCS #1 v1.5) signatures are of the SHA-256 digest of the data, as
+// created by `openssl dgst -sha256 -sign`.
+func verifySignature(publicKey crypto.PublicKey, data, encodedSignature []byte) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encodedSignature)), ""))
+	if err != nil {
+		return fmt.Errorf("could not decode signature: %v", err)
+	}
+
+	digest := sha256.Sum256(data)
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
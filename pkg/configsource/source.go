@@ -0,0 +1,331 @@
+package configsource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/aws"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// S3EndpointEnv overrides the endpoint of S3, for S3 compatible storage.
+	// Objects are addressed with path style URLs on the endpoint.
+	S3EndpointEnv = "AWS_ENDPOINT_URL_S3"
+
+	// signatureSuffix is appended to the URL of the configuration to give the
+	// URL of its signature.
+	signatureSuffix = ".sig"
+
+	gcsEndpoint      = "https://storage.googleapis.com"
+	gcsReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+	// maxConfigSize limits the size of the configuration that is loaded.
+	maxConfigSize = 10 << 20
+
+	fetchTimeout = 30 * time.Second
+)
+
+var (
+	sourcesMutex sync.Mutex
+	sources      = map[Options]*Source{}
+)
+
+// IsRemote determines whether the location of the configuration is a URL of
+// a remote source, rather than a path to a file.
+func IsRemote(location string) bool {
+	for _, scheme := range []string{"https://", "s3://", "gs://"} {
+		if strings.HasPrefix(location, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configure a remote configuration Source.
+type Options struct {
+	// URL is the location of the configuration: an https:// URL, an
+	// s3://bucket/key URL or a gs://bucket/object URL.
+	URL string
+
+	// PublicKeyFile is the path to a PEM encoded public key. When set, the
+	// configuration must be signed by the private key of the key pair.
+	PublicKeyFile string
+
+	// Client is the HTTP client used to fetch the configuration.
+	// When nil, a client is created for the scheme of the URL.
+	Client *http.Client
+}
+
+// Source fetches the configuration from a remote location, using the ETag of
+// the last response to avoid downloading it again when it has not changed.
+type Source struct {
+	location      string
+	endpoint      *url.URL
+	client        *http.Client
+	sign          func(*http.Request) error
+	publicKeyFile string
+
+	mutex sync.Mutex
+	etag  string
+	data  []byte
+}
+
+// GetSource returns the Source shared by all loads of the configuration with
+// the options given, creating it on first use, so that reloads can use the
+// configuration already fetched when it has not changed.
+func GetSource(opts Options) (*Source, error) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	if source, ok := sources[opts]; ok {
+		return source, nil
+	}
+	source, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	sources[opts] = source
+	return source, nil
+}
+
+// New creates a new Source for the configuration at the URL of the options.
+func New(opts Options) (*Source, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config URL: %v", err)
+	}
+
+	s := &Source{
+		location:      redact(u),
+		client:        opts.Client,
+		publicKeyFile: opts.PublicKeyFile,
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: fetchTimeout}
+	}
+
+	switch u.Scheme {
+	case "https":
+		s.endpoint = u
+	case "s3":
+		if err := s.configureS3(u); err != nil {
+			return nil, err
+		}
+	case "gs":
+		if err := s.configureGCS(u, opts.Client == nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config URL scheme %q: must be one of https, s3 or gs", u.Scheme)
+	}
+
+	if s.publicKeyFile != "" {
+		if _, err := loadPublicKey(s.publicKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// configureS3 fetches the object from S3, signing requests with the AWS
+// credentials of the environment.
+func (s *Source) configureS3(u *url.URL) error {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return fmt.Errorf("invalid S3 URL %q: must be of the form s3://bucket/key", s.location)
+	}
+
+	region := aws.Region()
+	if region == "" {
+		return fmt.Errorf("no AWS region configured for %s: set the %s environment variable", s.location, aws.RegionEnv)
+	}
+
+	var err error
+	if endpoint := os.Getenv(S3EndpointEnv); endpoint != "" {
+		s.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + key)
+	} else {
+		s.endpoint, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid S3 URL %q: %v", s.location, err)
+	}
+
+	credentials := aws.NewCredentialsProvider(s.client)
+	s.sign = func(req *http.Request) error {
+		return credentials.Sign(req, region, "s3", nil)
+	}
+	return nil
+}
+
+// configureGCS fetches the object from Google Cloud Storage, authenticating
+// with the application default credentials unless a client was provided.
+func (s *Source) configureGCS(u *url.URL, defaultClient bool) error {
+	bucket, object := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return fmt.Errorf("invalid GCS URL %q: must be of the form gs://bucket/object", s.location)
+	}
+
+	var err error
+	s.endpoint, err = url.Parse(gcsEndpoint + "/" + bucket + "/" + object)
+	if err != nil {
+		return fmt.Errorf("invalid GCS URL %q: %v", s.location, err)
+	}
+
+	if defaultClient {
+		s.client, err = google.DefaultClient(context.Background(), gcsReadOnlyScope)
+		if err != nil {
+			return fmt.Errorf("could not create GCS client: %v", err)
+		}
+		s.client.Timeout = fetchTimeout
+	}
+	return nil
+}
+
+// Name returns the file name of the configuration, which is used to resolve
+// relative includes from the working directory.
+func (s *Source) Name() string {
+	return path.Base(s.endpoint.Path)
+}
+
+// Fetch returns the configuration, and whether it has changed since it was
+// last fetched. The request is conditional on the ETag of the last response,
+// so an unchanged configuration is not downloaded again.
+// When a public key is configured, the signature of a changed configuration
+// is verified before it is returned.
+func (s *Source) Fetch(ctx context.Context) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	header := http.Header{}
+	if s.etag != "" && s.data != nil {
+		header.Set("If-None-Match", s.etag)
+	}
+	data, status, respHeader, err := s.get(ctx, s.endpoint, header)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not fetch config from %s: %v", s.location, err)
+	}
+	if status == http.StatusNotModified {
+		return s.data, false, nil
+	}
+
+	if s.publicKeyFile != "" {
+		if err := s.verify(ctx, data); err != nil {
+			return nil, false, fmt.Errorf("could not verify config from %s: %v", s.location, err)
+		}
+	}
+
+	changed := s.data == nil || !bytes.Equal(s.data, data)
+	s.data = data
+	s.etag = respHeader.Get("ETag")
+	return data, changed, nil
+}
+
+// verify fetches the signature of the configuration and verifies it with the
+// public key. The key is loaded for each verification so that it can be
+// rotated without restarting.
+func (s *Source) verify(ctx context.Context, data []byte) error {
+	publicKey, err := loadPublicKey(s.publicKeyFile)
+	if err != nil {
+		return err
+	}
+
+	sigURL := *s.endpoint
+	sigURL.Path += signatureSuffix
+	sigURL.RawPath = ""
+	signature, _, _, err := s.get(ctx, &sigURL, http.Header{})
+	if err != nil {
+		return fmt.Errorf("could not fetch signature: %v", err)
+	}
+
+	return verifySignature(publicKey, data, signature)
+}
+
+// Poll fetches the configuration every interval until the context is done,
+// calling onChange whenever it has changed. Errors are logged, and the
+// configuration is fetched again at the next interval.
+func (s *Source) Poll(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	worker := workers.New("config_source", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, changed, err := s.Fetch(ctx)
+		worker.Record(err)
+		if err != nil {
+			logger.Errorf("ERROR: %v", err)
+			continue
+		}
+		if changed {
+			logger.Printf("Config at %s has changed", s.location)
+			onChange()
+		}
+	}
+}
+
+// get sends a GET request to the URL, returning the body of a successful
+// response, or the status of a not modified response.
+func (s *Source) get(ctx context.Context, u *url.URL, header http.Header) ([]byte, int, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.sign != nil {
+		if err := s.sign(req); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.StatusCode, resp.Header, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if len(body) > maxConfigSize {
+		return nil, 0, nil, errors.New("config exceeds the maximum size of 10MiB")
+	}
+	return body, resp.StatusCode, resp.Header, nil
+}
+
+// redact removes the credentials and query, which may contain a presigned
+// signature, from the URL so that it can be logged.
+func redact(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	return redacted.String()
+}
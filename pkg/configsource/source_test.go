@@ -0,0 +1,233 @@
+package configsource
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// configServer serves a configuration, and its signature, with an ETag
+// derived from the content.
+type configServer struct {
+	mutex     sync.Mutex
+	data      []byte
+	signature []byte
+	requests  []*http.Request
+}
+
+func (c *configServer) set(data, signature []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data, c.signature = data, signature
+}
+
+func (c *configServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, req)
+
+	if c.data == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if strings.HasSuffix(req.URL.Path, signatureSuffix) {
+		if c.signature == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write(c.signature)
+		return
+	}
+
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sha256Sum(c.data)) + `"`
+	if req.Header.Get("If-None-Match") == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rw.Header().Set("ETag", etag)
+	rw.Write(c.data)
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func writePublicKey(publicKey interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	keyFile, err := ioutil.TempFile("", "oauth2-proxy-test-public-key")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(pem.Encode(keyFile, &pem.Block{Type: "PUBLIC KEY", Bytes: der})).To(Succeed())
+	Expect(keyFile.Close()).To(Succeed())
+	return keyFile.Name()
+}
+
+var _ = Describe("Source", func() {
+	var server *httptest.Server
+	var config *configServer
+
+	BeforeEach(func() {
+		config = &configServer{data: []byte("upstreams: []\n")}
+		server = httptest.NewTLSServer(config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("IsRemote",
+		func(location string, expected bool) {
+			Expect(IsRemote(location)).To(Equal(expected))
+		},
+		Entry("with an https URL", "https://config.example.com/oauth2-proxy.yaml", true),
+		Entry("with an S3 URL", "s3://bucket/oauth2-proxy.yaml", true),
+		Entry("with a GCS URL", "gs://bucket/oauth2-proxy.yaml", true),
+		Entry("with an http URL", "http://config.example.com/oauth2-proxy.yaml", false),
+		Entry("with a file path", "/etc/oauth2-proxy/alpha.yaml", false),
+	)
+
+	It("rejects unsupported URLs", func() {
+		_, err := New(Options{URL: "ftp://config.example.com/oauth2-proxy.yaml"})
+		Expect(err).To(MatchError("unsupported config URL scheme \"ftp\": must be one of https, s3 or gs"))
+	})
+
+	It("fetches the config, and only reports changes when the ETag changes", func() {
+		source, err := New(Options{URL: server.URL + "/config/oauth2-proxy.yaml", Client: server.Client()})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(source.Name()).To(Equal("oauth2-proxy.yaml"))
+
+		data, changed, err := source.Fetch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(string(data)).To(Equal("upstreams: []\n"))
+
+		data, changed, err = source.Fetch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(string(data)).To(Equal("upstreams: []\n"))
+		Expect(config.requests[1].Header.Get("If-None-Match")).ToNot(BeEmpty())
+
+		config.set([]byte("providers: []\n"), nil)
+		data, changed, err = source.Fetch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(string(data)).To(Equal("providers: []\n"))
+	})
+
+	It("returns an error for unsuccessful responses", func() {
+		config.set(nil, nil)
+		source, err := New(Options{URL: server.URL + "/oauth2-proxy.yaml", Client: server.Client()})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, err = source.Fetch(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("unexpected status 404")))
+	})
+
+	Context("with a public key", func() {
+		It("verifies Ed25519 signatures", func() {
+			publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			keyFile := writePublicKey(publicKey)
+			defer os.Remove(keyFile)
+
+			signature := ed25519.Sign(privateKey, config.data)
+			config.set(config.data, []byte(base64.StdEncoding.EncodeToString(signature)+"\n"))
+
+			source, err := New(Options{URL: server.URL + "/oauth2-proxy.yaml", PublicKeyFile: keyFile, Client: server.Client()})
+			Expect(err).ToNot(HaveOccurred())
+			_, changed, err := source.Fetch(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+
+			By("Rejecting a config that does not match the signature")
+			config.set([]byte("providers: []\n"), config.signature)
+			_, _, err = source.Fetch(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("invalid signature")))
+		})
+
+		It("verifies ECDSA signatures", func() {
+			privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			keyFile := writePublicKey(&privateKey.PublicKey)
+			defer os.Remove(keyFile)
+
+			signature, err := ecdsa.SignASN1(rand.Reader, privateKey, sha256Sum(config.data))
+			Expect(err).ToNot(HaveOccurred())
+			config.set(config.data, []byte(base64.StdEncoding.EncodeToString(signature)))
+
+			source, err := New(Options{URL: server.URL + "/oauth2-proxy.yaml", PublicKeyFile: keyFile, Client: server.Client()})
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = source.Fetch(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the signature is missing", func() {
+			publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			keyFile := writePublicKey(publicKey)
+			defer os.Remove(keyFile)
+
+			source, err := New(Options{URL: server.URL + "/oauth2-proxy.yaml", PublicKeyFile: keyFile, Client: server.Client()})
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = source.Fetch(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("could not fetch signature: unexpected status 404")))
+		})
+	})
+
+	It("fetches S3 objects with signed requests", func() {
+		for k, v := range map[string]string{
+			S3EndpointEnv:           server.URL,
+			"AWS_REGION":            "eu-west-1",
+			"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+			"AWS_SECRET_ACCESS_KEY": "secret",
+		} {
+			Expect(os.Setenv(k, v)).To(Succeed())
+			defer os.Unsetenv(k)
+		}
+
+		source, err := New(Options{URL: "s3://bucket/config/oauth2-proxy.yaml", Client: server.Client()})
+		Expect(err).ToNot(HaveOccurred())
+		data, _, err := source.Fetch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("upstreams: []\n"))
+
+		Expect(config.requests[0].URL.Path).To(Equal("/bucket/config/oauth2-proxy.yaml"))
+		Expect(config.requests[0].Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		Expect(config.requests[0].Header.Get("Authorization")).To(ContainSubstring("/eu-west-1/s3/aws4_request"))
+	})
+
+	It("polls the config and calls onChange when it changes", func() {
+		source, err := New(Options{URL: server.URL + "/oauth2-proxy.yaml", Client: server.Client()})
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = source.Fetch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		changes := make(chan struct{}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go source.Poll(ctx, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+		Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+		config.set([]byte("providers: []\n"), nil)
+		Eventually(changes, time.Second).Should(Receive())
+	})
+})
@@ -0,0 +1,436 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+const (
+	// Group is the API group of the custom resources.
+	Group = "oauth2-proxy.github.io"
+	// Version is the API version of the custom resources.
+	Version = "v1alpha1"
+
+	configResource = "oauth2proxyconfigs"
+	routeResource  = "protectedroutes"
+
+	defaultConfigName = "oauth2-proxy"
+
+	// serviceAccountDir is where Kubernetes mounts the service account
+	// credentials within a pod.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	requestTimeout = 10 * time.Second
+
+	// watchTimeout is how long the API server holds a watch open before it is
+	// closed and the resources are listed again.
+	watchTimeout = 5 * time.Minute
+
+	minRetryInterval = time.Second
+	maxRetryInterval = 30 * time.Second
+)
+
+var (
+	controllersMutex sync.Mutex
+	controllers      = map[options.KubernetesController]*Controller{}
+)
+
+// State is the configuration loaded from the custom resources.
+type State struct {
+	// Providers are the providers of the OAuth2ProxyConfig resource, and are
+	// nil when it does not exist.
+	Providers options.Providers
+
+	// Routes are the upstreams of the ProtectedRoute resources, sorted by the
+	// name of the resource.
+	Routes []options.Upstream
+}
+
+// Controller loads routes and providers from the OAuth2ProxyConfig and
+// ProtectedRoute custom resources of a namespace, and watches the resources
+// for changes.
+type Controller struct {
+	client        *http.Client
+	watchClient   *http.Client
+	apiServer     string
+	tokenFile     string
+	namespace     string
+	configName    string
+	routeSelector string
+
+	mutex            sync.RWMutex
+	state            State
+	resourceVersions map[string]string
+}
+
+// Get returns the Controller shared by all loads of the configuration with
+// the options given, creating it and loading the resources on first use.
+func Get(opts options.KubernetesController) (*Controller, error) {
+	controllersMutex.Lock()
+	defer controllersMutex.Unlock()
+
+	if c, ok := controllers[opts]; ok {
+		return c, nil
+	}
+	c, err := newInClusterController(opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Sync(context.Background()); err != nil {
+		return nil, err
+	}
+	controllers[opts] = c
+	return c, nil
+}
+
+// newInClusterController creates a Controller that connects to the API
+// server using the service account of the pod.
+func newInClusterController(opts options.KubernetesController) (*Controller, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("the kubernetes controller is only supported within a Kubernetes cluster")
+	}
+
+	rootCAs, err := util.GetCertPool([]string{filepath.Join(serviceAccountDir, "ca.crt")})
+	if err != nil {
+		return nil, fmt.Errorf("could not load service account CA: %v", err)
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		data, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("could not determine namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	}
+
+	return newController(opts, &http.Client{Transport: transport}, "https://"+net.JoinHostPort(host, port),
+		filepath.Join(serviceAccountDir, "token"), namespace), nil
+}
+
+// newController creates a Controller that uses the client to connect to the
+// API server.
+func newController(opts options.KubernetesController, client *http.Client, apiServer, tokenFile, namespace string) *Controller {
+	configName := opts.ConfigName
+	if configName == "" {
+		configName = defaultConfigName
+	}
+
+	// Requests are limited by a timeout, except for watches which are held
+	// open by the API server.
+	requestClient := *client
+	requestClient.Timeout = requestTimeout
+
+	return &Controller{
+		client:        &requestClient,
+		watchClient:   client,
+		apiServer:     apiServer,
+		tokenFile:     tokenFile,
+		namespace:     namespace,
+		configName:    configName,
+		routeSelector: opts.RouteSelector,
+	}
+}
+
+// State returns the configuration last loaded from the resources.
+func (c *Controller) State() State {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.state
+}
+
+// Apply overlays the configuration loaded from the resources on the options:
+// the providers of the OAuth2ProxyConfig replace the configured providers,
+// and the routes are served after the configured upstreams.
+func (c *Controller) Apply(opts *options.Options) {
+	state := c.State()
+	if state.Providers != nil {
+		// The client secrets are copied, as they are wiped with the options
+		// once the configuration is replaced
+		opts.Providers = make(options.Providers, len(state.Providers))
+		for i, provider := range state.Providers {
+			provider.ClientSecret = append(options.Secret(nil), provider.ClientSecret...)
+			opts.Providers[i] = provider
+		}
+	}
+	upstreams := make([]options.Upstream, 0, len(opts.UpstreamServers.Upstreams)+len(state.Routes))
+	upstreams = append(upstreams, opts.UpstreamServers.Upstreams...)
+	opts.UpstreamServers.Upstreams = append(upstreams, state.Routes...)
+}
+
+// Run watches the resources until the context is done, calling onChange
+// whenever the configuration loaded from them has changed.
+// Each change to the resources lists them again, so that events that are
+// missed while a watch is re-established are never lost.
+func (c *Controller) Run(ctx context.Context, onChange func()) {
+	retryInterval := minRetryInterval
+	worker := workers.New("kubernetes_controller", 0)
+	for {
+		err := c.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			worker.Record(err)
+			logger.Errorf("ERROR: could not watch kubernetes resources: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+			retryInterval *= 2
+			if retryInterval > maxRetryInterval {
+				retryInterval = maxRetryInterval
+			}
+		}
+
+		changed, err := c.Sync(ctx)
+		worker.Record(err)
+		if err != nil {
+			logger.Errorf("ERROR: could not load kubernetes resources: %v", err)
+			continue
+		}
+		retryInterval = minRetryInterval
+		if changed {
+			logger.Printf("Kubernetes resources in namespace %s have changed", c.namespace)
+			onChange()
+		}
+	}
+}
+
+// objectMeta is the subset of the metadata of a resource used by the
+// controller.
+type objectMeta struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// configResourceObject is an OAuth2ProxyConfig resource.
+type configResourceObject struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Providers options.Providers `json:"providers"`
+	} `json:"spec"`
+}
+
+// routeResourceList is a list of ProtectedRoute resources. The spec of each
+// route is an upstream.
+type routeResourceList struct {
+	Metadata objectMeta `json:"metadata"`
+	Items    []struct {
+		Metadata objectMeta      `json:"metadata"`
+		Spec     json.RawMessage `json:"spec"`
+	} `json:"items"`
+}
+
+// configResourceList is a list of OAuth2ProxyConfig resources.
+type configResourceList struct {
+	Metadata objectMeta             `json:"metadata"`
+	Items    []configResourceObject `json:"items"`
+}
+
+// Sync lists the resources and loads the configuration from them, returning
+// whether it has changed.
+func (c *Controller) Sync(ctx context.Context) (bool, error) {
+	var configs configResourceList
+	query := url.Values{"fieldSelector": {"metadata.name=" + c.configName}}
+	if err := c.list(ctx, configResource, query, &configs); err != nil {
+		return false, err
+	}
+
+	var routes routeResourceList
+	query = url.Values{}
+	if c.routeSelector != "" {
+		query.Set("labelSelector", c.routeSelector)
+	}
+	if err := c.list(ctx, routeResource, query, &routes); err != nil {
+		return false, err
+	}
+
+	state := State{}
+	for _, config := range configs.Items {
+		state.Providers = config.Spec.Providers
+		if state.Providers == nil {
+			state.Providers = options.Providers{}
+		}
+	}
+
+	sort.Slice(routes.Items, func(i, j int) bool {
+		return routes.Items[i].Metadata.Name < routes.Items[j].Metadata.Name
+	})
+	for _, route := range routes.Items {
+		upstream, err := decodeRoute(route.Spec)
+		if err != nil {
+			// An invalid route must not prevent changes to the other routes
+			logger.Errorf("ERROR: ignoring ProtectedRoute %s/%s: %v", c.namespace, route.Metadata.Name, err)
+			continue
+		}
+		if upstream.ID == "" {
+			upstream.ID = route.Metadata.Name
+		}
+		state.Routes = append(state.Routes, upstream)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.resourceVersions = map[string]string{
+		configResource: configs.Metadata.ResourceVersion,
+		routeResource:  routes.Metadata.ResourceVersion,
+	}
+	changed := !reflect.DeepEqual(c.state, state)
+	c.state = state
+	return changed, nil
+}
+
+// decodeRoute decodes the spec of a ProtectedRoute as an upstream, rejecting
+// any unknown fields.
+func decodeRoute(spec json.RawMessage) (options.Upstream, error) {
+	var upstream options.Upstream
+	decoder := json.NewDecoder(strings.NewReader(string(spec)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&upstream); err != nil {
+		return options.Upstream{}, fmt.Errorf("invalid spec: %v", err)
+	}
+	return upstream, nil
+}
+
+// list lists the resources of the namespace into the list given.
+func (c *Controller) list(ctx context.Context, resource string, query url.Values, into interface{}) error {
+	req, err := c.newRequest(ctx, resource, query)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not list %s: %v", resource, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not list %s: unexpected status code %d", resource, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("could not decode %s: %v", resource, err)
+	}
+	return nil
+}
+
+// watch watches both resources from the versions last listed, and returns
+// when either has changed or its watch has ended.
+func (c *Controller) watch(ctx context.Context) error {
+	c.mutex.RLock()
+	versions := c.resourceVersions
+	c.mutex.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	for _, resource := range []string{configResource, routeResource} {
+		query := url.Values{
+			"watch":           {"true"},
+			"resourceVersion": {versions[resource]},
+			"timeoutSeconds":  {fmt.Sprint(int(watchTimeout.Seconds()))},
+		}
+		if resource == configResource {
+			query.Set("fieldSelector", "metadata.name="+c.configName)
+		} else if c.routeSelector != "" {
+			query.Set("labelSelector", c.routeSelector)
+		}
+		go func(resource string) {
+			errs <- c.watchResource(ctx, resource, query)
+		}(resource)
+	}
+	return <-errs
+}
+
+// watchEvent is an event received from a watch.
+type watchEvent struct {
+	Type   string `json:"type"`
+	Object struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"object"`
+}
+
+// watchResource watches the resource until the first event is received, or
+// the watch ends.
+func (c *Controller) watchResource(ctx context.Context, resource string, query url.Values) error {
+	req, err := c.newRequest(ctx, resource, query)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.watchClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not watch %s: %v", resource, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not watch %s: unexpected status code %d", resource, resp.StatusCode)
+	}
+
+	var event watchEvent
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		// The API server closes watches after their timeout
+		return nil
+	}
+	if event.Type == "ERROR" {
+		// Expired resource versions (410 Gone) are handled by listing again
+		if event.Object.Code == http.StatusGone {
+			return nil
+		}
+		return fmt.Errorf("could not watch %s: %s", resource, event.Object.Message)
+	}
+	return nil
+}
+
+// newRequest creates a request for the resources of the namespace,
+// authenticated with the service account token.
+func (c *Controller) newRequest(ctx context.Context, resource string, query url.Values) (*http.Request, error) {
+	// Service account tokens are rotated, so the token is read for each request
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/apis/%s/%s/namespaces/%s/%s", c.apiServer, Group, Version, url.PathEscape(c.namespace), resource)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
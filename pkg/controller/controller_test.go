@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeAPIServer serves lists and watches of the custom resources.
+type fakeAPIServer struct {
+	mutex    sync.Mutex
+	configs  string
+	routes   string
+	version  int
+	changed  chan struct{}
+	requests []*http.Request
+}
+
+func (f *fakeAPIServer) set(configs, routes string) {
+	f.mutex.Lock()
+	f.configs, f.routes = configs, routes
+	f.version++
+	changed := f.changed
+	f.changed = make(chan struct{})
+	f.mutex.Unlock()
+	close(changed)
+}
+
+func (f *fakeAPIServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, req)
+	items := f.routes
+	if req.URL.Path == "/apis/oauth2-proxy.github.io/v1alpha1/namespaces/proxies/oauth2proxyconfigs" {
+		items = f.configs
+	}
+	version, changed := f.version, f.changed
+	f.mutex.Unlock()
+
+	if req.Header.Get("Authorization") != "Bearer service-account-token" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if req.URL.Query().Get("watch") == "true" {
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		select {
+		case <-changed:
+			fmt.Fprint(rw, `{"type":"MODIFIED","object":{}}`)
+		case <-req.Context().Done():
+		}
+		return
+	}
+	fmt.Fprintf(rw, `{"metadata":{"resourceVersion":"%d"},"items":[%s]}`, version, items)
+}
+
+var _ = Describe("Controller", func() {
+	var server *httptest.Server
+	var api *fakeAPIServer
+	var tokenFile string
+
+	BeforeEach(func() {
+		api = &fakeAPIServer{changed: make(chan struct{})}
+		server = httptest.NewServer(api)
+
+		f, err := ioutil.TempFile("", "oauth2-proxy-test-service-account-token")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.Write([]byte("service-account-token\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		tokenFile = f.Name()
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.Remove(tokenFile)).To(Succeed())
+	})
+
+	const config = `{"metadata":{"name":"oauth2-proxy"},"spec":{"providers":[{"id":"oidc","provider":"oidc","clientID":"client","allowedGroups":["admins"]}]}}`
+	const routes = `{"metadata":{"name":"grafana"},"spec":{"path":"/grafana/","uri":"http://grafana:3000/grafana/"}},` +
+		`{"metadata":{"name":"app"},"spec":{"id":"application","path":"/","uri":"http://app:8080"}},` +
+		`{"metadata":{"name":"invalid"},"spec":{"path":"/invalid/","unknown":true}}`
+
+	It("loads the providers and routes from the resources", func() {
+		api.set(config, routes)
+		c := newController(options.KubernetesController{RouteSelector: "team=platform"}, server.Client(), server.URL, tokenFile, "proxies")
+
+		changed, err := c.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(c.State()).To(Equal(State{
+			Providers: options.Providers{{ID: "oidc", Type: "oidc", ClientID: "client", AllowedGroups: []string{"admins"}}},
+			Routes: []options.Upstream{
+				{ID: "application", Path: "/", URI: "http://app:8080"},
+				{ID: "grafana", Path: "/grafana/", URI: "http://grafana:3000/grafana/"},
+			},
+		}))
+
+		Expect(api.requests[0].URL.Query().Get("fieldSelector")).To(Equal("metadata.name=oauth2-proxy"))
+		Expect(api.requests[1].URL.Query().Get("labelSelector")).To(Equal("team=platform"))
+
+		changed, err = c.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+	})
+
+	It("applies the providers and routes to the options", func() {
+		api.set(config, routes)
+		c := newController(options.KubernetesController{}, server.Client(), server.URL, tokenFile, "proxies")
+		_, err := c.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		opts := options.NewOptions()
+		opts.UpstreamServers.Upstreams = []options.Upstream{{ID: "static", Path: "/static/", Static: true}}
+		c.Apply(opts)
+
+		Expect(opts.Providers).To(HaveLen(1))
+		Expect(opts.Providers[0].AllowedGroups).To(ConsistOf("admins"))
+		Expect(opts.UpstreamServers.Upstreams).To(HaveLen(3))
+		Expect(opts.UpstreamServers.Upstreams[0].ID).To(Equal("static"))
+	})
+
+	It("keeps the configured providers without an OAuth2ProxyConfig", func() {
+		api.set("", routes)
+		c := newController(options.KubernetesController{}, server.Client(), server.URL, tokenFile, "proxies")
+		_, err := c.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		opts := options.NewOptions()
+		providers := opts.Providers
+		c.Apply(opts)
+		Expect(opts.Providers).To(Equal(providers))
+	})
+
+	It("calls onChange when the resources change", func() {
+		api.set(config, "")
+		c := newController(options.KubernetesController{}, server.Client(), server.URL, tokenFile, "proxies")
+		_, err := c.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		changes := make(chan struct{}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go c.Run(ctx, func() { changes <- struct{}{} })
+
+		Consistently(changes, 100*time.Millisecond).ShouldNot(Receive())
+		api.set(config, routes)
+		Eventually(changes, 5*time.Second).Should(Receive())
+		Expect(c.State().Routes).To(HaveLen(2))
+	})
+})
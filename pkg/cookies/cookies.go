@@ -25,6 +25,16 @@ func MakeCookieFromOptions(req *http.Request, name string, value string, opts *o
 		domain = opts.Domains[len(opts.Domains)-1]
 	}
 
+	sameSite, secure := opts.SameSite, opts.Secure
+	if policy := matchCookiePolicy(req, opts.Policies); policy != nil {
+		if policy.SameSite != "" {
+			sameSite = policy.SameSite
+		}
+		if policy.Secure != nil {
+			secure = *policy.Secure
+		}
+	}
+
 	c := &http.Cookie{
 		Name:     name,
 		Value:    value,
@@ -32,8 +42,8 @@ func MakeCookieFromOptions(req *http.Request, name string, value string, opts *o
 		Domain:   domain,
 		Expires:  now.Add(expiration),
 		HttpOnly: opts.HTTPOnly,
-		Secure:   opts.Secure,
-		SameSite: ParseSameSite(opts.SameSite),
+		Secure:   secure,
+		SameSite: ParseSameSite(sameSite),
 	}
 
 	warnInvalidDomain(c, req)
@@ -53,6 +63,37 @@ func GetCookieDomain(req *http.Request, cookieDomains []string) string {
 	return ""
 }
 
+// matchCookiePolicy returns the cookie policy for the host of the request,
+// preferring policies for the exact host over those for its subdomains, or
+// nil when no policy matches.
+func matchCookiePolicy(req *http.Request, policies []options.CookiePolicy) *options.CookiePolicy {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	host := requestutil.GetRequestHost(req)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for i, policy := range policies {
+		for _, pattern := range policy.Hosts {
+			if strings.ToLower(pattern) == host {
+				return &policies[i]
+			}
+		}
+	}
+	for i, policy := range policies {
+		for _, pattern := range policy.Hosts {
+			if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, strings.ToLower(pattern[1:])) {
+				return &policies[i]
+			}
+		}
+	}
+	return nil
+}
+
 // Parse a valid http.SameSite value from a user supplied string for use of making cookies.
 func ParseSameSite(v string) http.SameSite {
 	switch v {
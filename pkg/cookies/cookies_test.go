@@ -3,8 +3,10 @@ package cookies
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -76,4 +78,57 @@ var _ = Describe("Cookie Tests", func() {
 			}),
 		)
 	})
+
+	Context("MakeCookieFromOptions with cookie policies", func() {
+		insecure := false
+		opts := &options.Cookie{
+			Path:     "/",
+			Secure:   true,
+			HTTPOnly: true,
+			SameSite: "lax",
+			Policies: []options.CookiePolicy{
+				{Hosts: []string{"*.embedded.test"}, SameSite: "strict"},
+				{Hosts: []string{"app.embedded.test"}, SameSite: "none"},
+				{Hosts: []string{"dev.cookies.test"}, Secure: &insecure},
+			},
+		}
+
+		type policyTableInput struct {
+			host             string
+			expectedSameSite http.SameSite
+			expectedSecure   bool
+		}
+
+		DescribeTable("should override the attributes for the host",
+			func(in policyTableInput) {
+				req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/", in.host), nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				c := MakeCookieFromOptions(req, "_oauth2_proxy", "value", opts, time.Hour, time.Now())
+				Expect(c.SameSite).To(Equal(in.expectedSameSite))
+				Expect(c.Secure).To(Equal(in.expectedSecure))
+				Expect(c.HttpOnly).To(BeTrue())
+			},
+			Entry("without a matching policy", policyTableInput{
+				host:             "www.cookies.test",
+				expectedSameSite: http.SameSiteLaxMode,
+				expectedSecure:   true,
+			}),
+			Entry("with a policy for the exact host", policyTableInput{
+				host:             "app.embedded.test:8443",
+				expectedSameSite: http.SameSiteNoneMode,
+				expectedSecure:   true,
+			}),
+			Entry("with a policy for the subdomains of the host", policyTableInput{
+				host:             "other.embedded.test",
+				expectedSameSite: http.SameSiteStrictMode,
+				expectedSecure:   true,
+			}),
+			Entry("with a policy that only overrides the secure attribute", policyTableInput{
+				host:             "dev.cookies.test",
+				expectedSameSite: http.SameSiteLaxMode,
+				expectedSecure:   false,
+			}),
+		)
+	})
 })
@@ -21,6 +21,12 @@ type CSRF interface {
 	CheckOAuthState(string) bool
 	CheckOIDCNonce(string) bool
 	GetCodeVerifier() string
+	GetRedirect() string
+	SetRedirect(string)
+	GetSilentOrigin() string
+	SetSilentOrigin(string)
+	GetBrowserSession() bool
+	SetBrowserSession(bool)
 
 	SetSessionNonce(s *sessions.SessionState)
 
@@ -44,6 +50,19 @@ type csrf struct {
 	// authentication code.
 	CodeVerifier string `msgpack:"cv,omitempty"`
 
+	// Redirect holds the URL the user is redirected to after the
+	// authentication, so that it cannot be tampered with in the state
+	// parameter mirrored back by the IdP.
+	Redirect string `msgpack:"rd,omitempty"`
+
+	// SilentOrigin holds the origin of the page the result of a silent
+	// authentication is posted to, if the authentication is silent.
+	SilentOrigin string `msgpack:"so,omitempty"`
+
+	// BrowserSession holds whether the session of the user should only last
+	// until the browser is closed, when they did not choose to stay signed in.
+	BrowserSession bool `msgpack:"bs,omitempty"`
+
 	cookieOpts *options.Cookie
 	time       clock.Clock
 }
@@ -73,6 +92,10 @@ func NewCSRF(opts *options.Cookie, codeVerifier string) (CSRF, error) {
 
 // LoadCSRFCookie loads a CSRF object from a request's CSRF cookie
 func LoadCSRFCookie(req *http.Request, opts *options.Cookie) (CSRF, error) {
+	if isCombined(opts) {
+		var clk clock.Clock
+		return loadCombinedCSRFCookie(req, opts, clk.Now())
+	}
 
 	cookieName := GenerateCookieName(req, opts)
 
@@ -100,6 +123,41 @@ func (c *csrf) GetCodeVerifier() string {
 	return c.CodeVerifier
 }
 
+// GetRedirect returns the URL the user is redirected to after the
+// authentication
+func (c *csrf) GetRedirect() string {
+	return c.Redirect
+}
+
+// SetRedirect sets the URL the user is redirected to after the authentication
+func (c *csrf) SetRedirect(redirect string) {
+	c.Redirect = redirect
+}
+
+// GetSilentOrigin returns the origin the result of a silent authentication
+// is posted to, or an empty string if the authentication is not silent.
+func (c *csrf) GetSilentOrigin() string {
+	return c.SilentOrigin
+}
+
+// SetSilentOrigin marks the authentication as silent, with the origin its
+// result is posted to
+func (c *csrf) SetSilentOrigin(origin string) {
+	c.SilentOrigin = origin
+}
+
+// GetBrowserSession returns whether the session cookie should expire when the
+// browser is closed, rather than after the cookie expiry
+func (c *csrf) GetBrowserSession() bool {
+	return c.BrowserSession
+}
+
+// SetBrowserSession sets whether the session cookie should expire when the
+// browser is closed
+func (c *csrf) SetBrowserSession(browserSession bool) {
+	c.BrowserSession = browserSession
+}
+
 // HashOAuthState returns the hash of the OAuth state nonce
 func (c *csrf) HashOAuthState() string {
 	return encryption.HashNonce(c.OAuthState)
@@ -126,8 +184,17 @@ func (c *csrf) SetSessionNonce(s *sessions.SessionState) {
 	s.Nonce = c.OIDCNonce
 }
 
-// SetCookie encodes the CSRF to a signed cookie and sets it on the ResponseWriter
+// SetCookie encodes the CSRF to an encrypted cookie and sets it on the ResponseWriter.
+// Per-request CSRFs are added to the combined CSRF cookie when it is enabled,
+// and otherwise evict the oldest per-request CSRF cookies over the limit.
 func (c *csrf) SetCookie(rw http.ResponseWriter, req *http.Request) (*http.Cookie, error) {
+	if isCombined(c.cookieOpts) {
+		return c.setCombinedCookie(rw, req)
+	}
+	if c.cookieOpts.CSRFPerRequest && c.cookieOpts.CSRFPerRequestLimit > 0 {
+		evictCSRFCookies(rw, req, c.cookieOpts, c.cookieName(), c.time.Now())
+	}
+
 	encoded, err := c.encodeCookie()
 	if err != nil {
 		return nil, err
@@ -148,6 +215,10 @@ func (c *csrf) SetCookie(rw http.ResponseWriter, req *http.Request) (*http.Cooki
 
 // ClearCookie removes the CSRF cookie
 func (c *csrf) ClearCookie(rw http.ResponseWriter, req *http.Request) {
+	if isCombined(c.cookieOpts) {
+		c.clearCombinedCookie(rw, req)
+		return
+	}
 	http.SetCookie(rw, MakeCookieFromOptions(
 		req,
 		c.cookieName(),
@@ -158,25 +229,20 @@ func (c *csrf) ClearCookie(rw http.ResponseWriter, req *http.Request) {
 	))
 }
 
-// encodeCookie MessagePack encodes and encrypts the CSRF and then creates a
-// signed cookie value
+// encodeCookie encodes the CSRF as a compact encrypted JWT
 func (c *csrf) encodeCookie() (string, error) {
-	packed, err := msgpack.Marshal(c)
-	if err != nil {
-		return "", fmt.Errorf("error marshalling CSRF to msgpack: %v", err)
-	}
-
-	encrypted, err := encrypt(packed, c.cookieOpts)
-	if err != nil {
-		return "", err
-	}
-
-	return encryption.SignedValue(c.cookieOpts.Secret, c.cookieName(), encrypted, c.time.Now())
+	return encodeCSRFJWT(c)
 }
 
-// decodeCSRFCookie validates the signature then decrypts and decodes a CSRF
-// cookie into a CSRF struct
+// decodeCSRFCookie decrypts and decodes a CSRF cookie into a CSRF struct.
+// CSRF cookies of the legacy format are validated, decrypted and decoded from
+// MessagePack, so that sign-ins started before an upgrade can complete.
 func decodeCSRFCookie(cookie *http.Cookie, opts *options.Cookie) (*csrf, error) {
+	var clk clock.Clock
+	if isCSRFJWT(cookie.Value) {
+		return decodeCSRFJWT(cookie.Value, opts, clk.Now())
+	}
+
 	val, _, ok := encryption.Validate(cookie, opts.Secret, opts.Expire)
 	if !ok {
 		return nil, errors.New("CSRF cookie failed validation")
@@ -201,11 +267,17 @@ func decodeCSRFCookie(cookie *http.Cookie, opts *options.Cookie) (*csrf, error)
 func (c *csrf) cookieName() string {
 	stateSubstring := ""
 	if c.cookieOpts.CSRFPerRequest {
-		stateSubstring = encryption.HashNonce(c.OAuthState)[0 : csrfStateLength-1]
+		stateSubstring = c.stateSubstring()
 	}
 	return csrfCookieName(c.cookieOpts, stateSubstring)
 }
 
+// stateSubstring returns the initial characters of the hashed OAuth state,
+// which identify the CSRF of a request
+func (c *csrf) stateSubstring() string {
+	return encryption.HashNonce(c.OAuthState)[0 : csrfStateLength-1]
+}
+
 func csrfCookieName(opts *options.Cookie, stateSubstring string) string {
 	if stateSubstring == "" {
 		return fmt.Sprintf("%v_csrf", opts.Name)
@@ -245,5 +317,7 @@ func decrypt(data []byte, opts *options.Cookie) ([]byte, error) {
 }
 
 func makeCipher(opts *options.Cookie) (encryption.Cipher, error) {
-	return encryption.NewCFBCipher(encryption.SecretBytes(opts.Secret))
+	secret := encryption.SecretBytes(opts.Secret)
+	defer encryption.Wipe(secret)
+	return encryption.NewCipher(opts.Cipher, secret, opts.CipherRejectLegacy)
 }
@@ -0,0 +1,151 @@
+package cookies
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"golang.org/x/crypto/hkdf"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// CSRFClaims are the claims of the JWT of a CSRF cookie.
+// The claim names are short to keep the cookie small.
+type CSRFClaims struct {
+	// State is the base64url encoded nonce of the OAuth state parameter
+	State string `json:"st"`
+	// Nonce is the base64url encoded OIDC nonce
+	Nonce string `json:"nn"`
+	// CodeVerifier is the PKCE code verifier
+	CodeVerifier string `json:"cv,omitempty"`
+	// Redirect is the URL the user is redirected to after signing in
+	Redirect string `json:"rd,omitempty"`
+	// SilentOrigin is the origin the result of a silent authentication is
+	// posted to
+	SilentOrigin string `json:"so,omitempty"`
+	// BrowserSession is whether the session cookie expires when the browser
+	// is closed
+	BrowserSession bool `json:"bs,omitempty"`
+	// Expiry is when the CSRF cookie expires
+	Expiry *jwt.NumericDate `json:"exp,omitempty"`
+}
+
+// csrfJWTParts is the number of parts of a compact serialized JWE.
+const csrfJWTParts = 5
+
+// encodeCSRFJWT encrypts the CSRF into a compact JWE (RFC 7516) with the
+// A256GCM content encryption and a key derived from the cookie secret.
+func encodeCSRFJWT(c *csrf) (string, error) {
+	key := csrfJWTKey(c.cookieOpts.Secret)
+	defer encryption.Wipe(key)
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: jose.DIRECT, Key: key}, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating CSRF JWT encrypter: %v", err)
+	}
+
+	claims := CSRFClaims{
+		State:          base64.RawURLEncoding.EncodeToString(c.OAuthState),
+		Nonce:          base64.RawURLEncoding.EncodeToString(c.OIDCNonce),
+		CodeVerifier:   c.CodeVerifier,
+		Redirect:       c.Redirect,
+		SilentOrigin:   c.SilentOrigin,
+		BrowserSession: c.BrowserSession,
+	}
+	if lifetime := csrfLifetime(c.cookieOpts); lifetime > 0 {
+		claims.Expiry = jwt.NewNumericDate(c.time.Now().Add(lifetime))
+	}
+
+	token, err := jwt.Encrypted(encrypter).Claims(claims).CompactSerialize()
+	if err != nil {
+		return "", fmt.Errorf("error encrypting CSRF JWT: %v", err)
+	}
+	return token, nil
+}
+
+// decodeCSRFJWT decrypts the JWT of a CSRF cookie and checks it has not
+// expired.
+func decodeCSRFJWT(value string, opts *options.Cookie, now time.Time) (*csrf, error) {
+	claims, err := DecodeCSRFClaims(value, opts.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Expiry != nil && !now.Before(claims.Expiry.Time()) {
+		return nil, errors.New("CSRF cookie has expired")
+	}
+
+	state, err := base64.RawURLEncoding.DecodeString(claims.State)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding CSRF state: %v", err)
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(claims.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding CSRF nonce: %v", err)
+	}
+
+	return &csrf{
+		OAuthState:     state,
+		OIDCNonce:      nonce,
+		CodeVerifier:   claims.CodeVerifier,
+		Redirect:       claims.Redirect,
+		SilentOrigin:   claims.SilentOrigin,
+		BrowserSession: claims.BrowserSession,
+		cookieOpts:     opts,
+	}, nil
+}
+
+// DecodeCSRFClaims decrypts the JWT of a CSRF cookie with the cookie secret,
+// without checking its expiry, so that the state of sign-ins can be
+// inspected when debugging them.
+func DecodeCSRFClaims(value string, secret []byte) (*CSRFClaims, error) {
+	token, err := jwt.ParseEncrypted(value)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CSRF JWT: %v", err)
+	}
+
+	key := csrfJWTKey(secret)
+	defer encryption.Wipe(key)
+
+	claims := &CSRFClaims{}
+	if err := token.Claims(key, claims); err != nil {
+		return nil, fmt.Errorf("error decrypting CSRF JWT: %v", err)
+	}
+	return claims, nil
+}
+
+// isCSRFJWT returns whether the value of a CSRF cookie is a JWT, rather than
+// a signed value of the legacy format, whose parts are separated by pipes.
+func isCSRFJWT(value string) bool {
+	return strings.Count(value, ".") == csrfJWTParts-1
+}
+
+// csrfJWTKey derives the key of the JWTs of CSRF cookies from the cookie
+// secret, so that the secret is not used directly by two algorithms.
+func csrfJWTKey(secret []byte) []byte {
+	secretBytes := encryption.SecretBytes(secret)
+	defer encryption.Wipe(secretBytes)
+
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, secretBytes, nil, []byte("oauth2-proxy csrf jwt"))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		// HKDF can derive up to 255 times the size of the hash
+		panic(err)
+	}
+	return key
+}
+
+// csrfLifetime returns how long CSRF cookies are valid for: the CSRF
+// expiry, or the cookie expiry when it is not set, as legacy CSRF cookies.
+func csrfLifetime(opts *options.Cookie) time.Duration {
+	if opts.CSRFExpire > 0 {
+		return opts.CSRFExpire
+	}
+	return opts.Expire
+}
@@ -0,0 +1,219 @@
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+const (
+	// defaultCSRFCombinedLimit is the number of CSRF states kept in the
+	// combined CSRF cookie when no limit is configured
+	defaultCSRFCombinedLimit = 5
+
+	// MaxCSRFCombinedLimit is the highest number of CSRF states that may be
+	// kept in the combined CSRF cookie, so that it stays under the 4096 bytes
+	// browsers allow a cookie, with PKCE code verifiers
+	MaxCSRFCombinedLimit = 10
+)
+
+// csrfEntry is a CSRF state of the combined CSRF cookie, keyed by the state
+// substring that would otherwise be part of the name of its cookie.
+type csrfEntry struct {
+	Key     string `msgpack:"k"`
+	Created int64  `msgpack:"t"`
+	CSRF    *csrf  `msgpack:"c"`
+}
+
+// isCombined determines whether the per-request CSRF states are kept in the
+// combined CSRF cookie rather than in a cookie each.
+func isCombined(opts *options.Cookie) bool {
+	return opts.CSRFPerRequest && opts.CSRFCombined
+}
+
+// combinedLimit returns the number of CSRF states kept in the combined CSRF
+// cookie.
+func combinedLimit(opts *options.Cookie) int {
+	if opts.CSRFPerRequestLimit > 0 {
+		return opts.CSRFPerRequestLimit
+	}
+	return defaultCSRFCombinedLimit
+}
+
+// loadCombinedCSRFCookie loads the CSRF of the request's state from the
+// combined CSRF cookie, or from its own cookie when it was created before the
+// combined cookie was enabled.
+func loadCombinedCSRFCookie(req *http.Request, opts *options.Cookie, now time.Time) (*csrf, error) {
+	key := ExtractStateSubstring(req)
+	entries, err := loadCSRFEntries(req, opts, now)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.Key == key {
+				return entry.CSRF, nil
+			}
+		}
+		err = errors.New("CSRF cookie has no state for the request")
+	}
+
+	cookie, cookieErr := req.Cookie(csrfCookieName(opts, key))
+	if cookieErr != nil {
+		return nil, err
+	}
+	return decodeCSRFCookie(cookie, opts)
+}
+
+// loadCSRFEntries returns the unexpired CSRF states of the request's combined
+// CSRF cookie, oldest first.
+func loadCSRFEntries(req *http.Request, opts *options.Cookie, now time.Time) ([]csrfEntry, error) {
+	cookie, err := req.Cookie(csrfCookieName(opts, ""))
+	if err != nil {
+		return nil, err
+	}
+	val, _, ok := encryption.Validate(cookie, opts.Secret, opts.Expire)
+	if !ok {
+		return nil, errors.New("CSRF cookie failed validation")
+	}
+	decrypted, err := decrypt(val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []csrfEntry
+	if err := msgpack.Unmarshal(decrypted, &entries); err != nil {
+		return nil, fmt.Errorf("error unmarshalling data to CSRF states: %v", err)
+	}
+
+	live := entries[:0]
+	for _, entry := range entries {
+		if entry.CSRF == nil || now.Sub(time.Unix(entry.Created, 0)) >= opts.CSRFExpire {
+			continue
+		}
+		entry.CSRF.cookieOpts = opts
+		live = append(live, entry)
+	}
+	return live, nil
+}
+
+// setCombinedCookie adds the CSRF to the states of the request's combined
+// CSRF cookie, evicting the expired states and the oldest over the limit.
+// A combined cookie that cannot be loaded is replaced.
+func (c *csrf) setCombinedCookie(rw http.ResponseWriter, req *http.Request) (*http.Cookie, error) {
+	now := c.time.Now()
+	entries, _ := loadCSRFEntries(req, c.cookieOpts, now)
+	entries = append(removeCSRFEntry(entries, c.stateSubstring()), csrfEntry{
+		Key:     c.stateSubstring(),
+		Created: now.Unix(),
+		CSRF:    c,
+	})
+	if limit := combinedLimit(c.cookieOpts); len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return c.writeCombinedCookie(rw, req, entries, now)
+}
+
+// clearCombinedCookie removes the CSRF from the states of the request's
+// combined CSRF cookie, clearing the cookie when no states are left.
+func (c *csrf) clearCombinedCookie(rw http.ResponseWriter, req *http.Request) {
+	now := c.time.Now()
+	entries, _ := loadCSRFEntries(req, c.cookieOpts, now)
+	if _, err := c.writeCombinedCookie(rw, req, removeCSRFEntry(entries, c.stateSubstring()), now); err != nil {
+		clearCSRFCookie(rw, req, csrfCookieName(c.cookieOpts, ""), c.cookieOpts, now)
+	}
+}
+
+// writeCombinedCookie encodes the states to the signed combined CSRF cookie
+// and sets it on the ResponseWriter, or clears it when there are no states.
+func (c *csrf) writeCombinedCookie(rw http.ResponseWriter, req *http.Request, entries []csrfEntry, now time.Time) (*http.Cookie, error) {
+	name := csrfCookieName(c.cookieOpts, "")
+	if len(entries) == 0 {
+		clearCSRFCookie(rw, req, name, c.cookieOpts, now)
+		return nil, nil
+	}
+
+	packed, err := msgpack.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling CSRF states to msgpack: %v", err)
+	}
+	encrypted, err := encrypt(packed, c.cookieOpts)
+	if err != nil {
+		return nil, err
+	}
+	value, err := encryption.SignedValue(c.cookieOpts.Secret, name, encrypted, now)
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := MakeCookieFromOptions(req, name, value, c.cookieOpts, c.cookieOpts.CSRFExpire, now)
+	http.SetCookie(rw, cookie)
+	return cookie, nil
+}
+
+// removeCSRFEntry returns the states without the state of the key.
+func removeCSRFEntry(entries []csrfEntry, key string) []csrfEntry {
+	kept := make([]csrfEntry, 0, len(entries)+1)
+	for _, entry := range entries {
+		if entry.Key != key {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// evictCSRFCookies clears the per-request CSRF cookies of the request that
+// are invalid or expired and, oldest first, those that leave no room for the
+// new cookie under the limit.
+func evictCSRFCookies(rw http.ResponseWriter, req *http.Request, opts *options.Cookie, newName string, now time.Time) {
+	type csrfCookie struct {
+		name    string
+		expires time.Time
+	}
+
+	prefix := csrfCookieName(opts, "") + "_"
+	var live []csrfCookie
+	for _, cookie := range req.Cookies() {
+		if !strings.HasPrefix(cookie.Name, prefix) || cookie.Name == newName {
+			continue
+		}
+		expires, ok := csrfCookieExpiry(cookie, opts)
+		if !ok || !now.Before(expires) {
+			clearCSRFCookie(rw, req, cookie.Name, opts, now)
+			continue
+		}
+		live = append(live, csrfCookie{name: cookie.Name, expires: expires})
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].expires.Before(live[j].expires)
+	})
+	for len(live) > 0 && len(live) >= opts.CSRFPerRequestLimit {
+		clearCSRFCookie(rw, req, live[0].name, opts, now)
+		live = live[1:]
+	}
+}
+
+// csrfCookieExpiry returns when a per-request CSRF cookie expires, and
+// whether it is valid.
+func csrfCookieExpiry(cookie *http.Cookie, opts *options.Cookie) (time.Time, bool) {
+	if isCSRFJWT(cookie.Value) {
+		claims, err := DecodeCSRFClaims(cookie.Value, opts.Secret)
+		if err != nil || claims.Expiry == nil {
+			return time.Time{}, false
+		}
+		return claims.Expiry.Time(), true
+	}
+
+	_, created, ok := encryption.Validate(cookie, opts.Secret, opts.Expire)
+	return created.Add(opts.CSRFExpire), ok
+}
+
+// clearCSRFCookie sets a cookie of the name with an empty value in the past.
+func clearCSRFCookie(rw http.ResponseWriter, req *http.Request, name string, opts *options.Cookie, now time.Time) {
+	http.SetCookie(rw, MakeCookieFromOptions(req, name, "", opts, time.Hour*-1, now))
+}
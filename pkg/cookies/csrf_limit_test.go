@@ -0,0 +1,220 @@
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CSRF Cookie limit Tests", func() {
+	var cookieOpts *options.Cookie
+
+	BeforeEach(func() {
+		cookieOpts = &options.Cookie{
+			Name:           cookieName,
+			Secret:         []byte(cookieSecret),
+			Path:           "/",
+			Expire:         time.Hour,
+			Secure:         true,
+			HTTPOnly:       true,
+			CSRFPerRequest: true,
+			CSRFExpire:     5 * time.Minute,
+		}
+	})
+
+	// setCookies returns the cookies set by the response, by name
+	setCookies := func(rw *httptest.ResponseRecorder) map[string]*http.Cookie {
+		cookies := make(map[string]*http.Cookie)
+		for _, cookie := range rw.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		return cookies
+	}
+
+	// callbackRequest returns a callback request with the state of the CSRF
+	// and the cookies
+	callbackRequest := func(c CSRF, cookies ...*http.Cookie) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/callback?state="+c.HashOAuthState()+":/", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		return req
+	}
+
+	Context("with per-request CSRF cookies", func() {
+		BeforeEach(func() {
+			cookieOpts.CSRFPerRequestLimit = 2
+		})
+
+		signedCookie := func(name string, created time.Time) *http.Cookie {
+			value, err := encryption.SignedValue(cookieOpts.Secret, name, []byte("csrf"), created)
+			Expect(err).ToNot(HaveOccurred())
+			return &http.Cookie{Name: name, Value: value}
+		}
+
+		It("evicts the oldest and expired CSRF cookies over the limit", func() {
+			now := time.Now()
+			req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/start", nil)
+			req.AddCookie(signedCookie(cookieName+"_csrf_aaaaaaaa", now.Add(-2*time.Minute)))
+			req.AddCookie(signedCookie(cookieName+"_csrf_bbbbbbbb", now.Add(-time.Minute)))
+			req.AddCookie(signedCookie(cookieName+"_csrf_cccccccc", now.Add(-10*time.Minute)))
+			req.AddCookie(&http.Cookie{Name: cookieName + "_csrf_dddddddd", Value: "invalid"})
+			req.AddCookie(signedCookie(cookieName, now))
+
+			c, err := NewCSRF(cookieOpts, "verifier")
+			Expect(err).ToNot(HaveOccurred())
+			rw := httptest.NewRecorder()
+			_, err = c.SetCookie(rw, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			cookies := setCookies(rw)
+			Expect(cookies).To(HaveLen(4))
+			for _, name := range []string{"aaaaaaaa", "cccccccc", "dddddddd"} {
+				Expect(cookies).To(HaveKey(cookieName + "_csrf_" + name))
+				Expect(cookies[cookieName+"_csrf_"+name].Value).To(BeEmpty())
+			}
+			Expect(cookies[c.(*csrf).cookieName()].Value).ToNot(BeEmpty())
+		})
+
+		It("evicts the CSRF cookies encoded as JWTs that expire first", func() {
+			req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/start", nil)
+			for i, age := range []time.Duration{time.Minute, 2 * time.Minute} {
+				old, err := NewCSRF(cookieOpts, "verifier")
+				Expect(err).ToNot(HaveOccurred())
+				old.(*csrf).time.Set(time.Now().Add(-age))
+				encoded, err := old.(*csrf).encodeCookie()
+				Expect(err).ToNot(HaveOccurred())
+				req.AddCookie(&http.Cookie{Name: fmt.Sprintf("%s_csrf_%08d", cookieName, i), Value: encoded})
+			}
+
+			c, err := NewCSRF(cookieOpts, "verifier")
+			Expect(err).ToNot(HaveOccurred())
+			rw := httptest.NewRecorder()
+			_, err = c.SetCookie(rw, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			cookies := setCookies(rw)
+			Expect(cookies).To(HaveLen(2))
+			Expect(cookies).To(HaveKey(cookieName + "_csrf_00000001"))
+			Expect(cookies[cookieName+"_csrf_00000001"].Value).To(BeEmpty())
+		})
+
+		It("does not evict CSRF cookies under the limit", func() {
+			req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/start", nil)
+			req.AddCookie(signedCookie(cookieName+"_csrf_aaaaaaaa", time.Now()))
+
+			c, err := NewCSRF(cookieOpts, "verifier")
+			Expect(err).ToNot(HaveOccurred())
+			rw := httptest.NewRecorder()
+			_, err = c.SetCookie(rw, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			cookies := setCookies(rw)
+			Expect(cookies).To(HaveLen(1))
+			Expect(cookies).To(HaveKey(c.(*csrf).cookieName()))
+		})
+	})
+
+	Context("with the combined CSRF cookie", func() {
+		var csrfs []CSRF
+		var combined *http.Cookie
+
+		BeforeEach(func() {
+			cookieOpts.CSRFCombined = true
+			cookieOpts.CSRFPerRequestLimit = 2
+
+			// Start three logins, each carrying the cookie of the last
+			csrfs = nil
+			combined = nil
+			for i := 0; i < 3; i++ {
+				c, err := NewCSRF(cookieOpts, "verifier")
+				Expect(err).ToNot(HaveOccurred())
+				req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/start", nil)
+				if combined != nil {
+					req.AddCookie(&http.Cookie{Name: combined.Name, Value: combined.Value})
+				}
+
+				rw := httptest.NewRecorder()
+				combined, err = c.SetCookie(rw, req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(combined.Name).To(Equal(cookieName + "_csrf"))
+				Expect(setCookies(rw)).To(HaveLen(1))
+				csrfs = append(csrfs, c)
+			}
+		})
+
+		It("loads the CSRFs of the states under the limit", func() {
+			for _, c := range csrfs[1:] {
+				loaded, err := LoadCSRFCookie(callbackRequest(c, combined), cookieOpts)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loaded.(*csrf).OAuthState).To(Equal(c.(*csrf).OAuthState))
+				Expect(loaded.(*csrf).OIDCNonce).To(Equal(c.(*csrf).OIDCNonce))
+				Expect(loaded.GetCodeVerifier()).To(Equal("verifier"))
+			}
+		})
+
+		It("evicts the oldest state over the limit", func() {
+			_, err := LoadCSRFCookie(callbackRequest(csrfs[0], combined), cookieOpts)
+			Expect(err).To(MatchError("CSRF cookie has no state for the request"))
+		})
+
+		It("evicts expired states", func() {
+			cookieOpts.CSRFExpire = 0
+			_, err := LoadCSRFCookie(callbackRequest(csrfs[2], combined), cookieOpts)
+			Expect(err).To(MatchError("CSRF cookie has no state for the request"))
+		})
+
+		It("loads a CSRF from its own cookie when it has no state", func() {
+			c, err := NewCSRF(cookieOpts, "verifier")
+			Expect(err).ToNot(HaveOccurred())
+			encoded, err := c.(*csrf).encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			loaded, err := LoadCSRFCookie(callbackRequest(c, combined, &http.Cookie{
+				Name:  csrfCookieName(cookieOpts, c.(*csrf).stateSubstring()),
+				Value: encoded,
+			}), cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.(*csrf).OAuthState).To(Equal(c.(*csrf).OAuthState))
+		})
+
+		It("removes the state of a CSRF when it is cleared", func() {
+			rw := httptest.NewRecorder()
+			csrfs[2].ClearCookie(rw, callbackRequest(csrfs[2], combined))
+			remaining := setCookies(rw)[cookieName+"_csrf"]
+			Expect(remaining.Value).ToNot(BeEmpty())
+
+			_, err := LoadCSRFCookie(callbackRequest(csrfs[2], remaining), cookieOpts)
+			Expect(err).To(HaveOccurred())
+			_, err = LoadCSRFCookie(callbackRequest(csrfs[1], remaining), cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+
+			rw = httptest.NewRecorder()
+			csrfs[1].ClearCookie(rw, callbackRequest(csrfs[1], remaining))
+			Expect(setCookies(rw)[cookieName+"_csrf"].Value).To(BeEmpty())
+			Expect(setCookies(rw)[cookieName+"_csrf"].Expires).To(BeTemporally("<", time.Now()))
+		})
+
+		It("fits the most states in a cookie", func() {
+			cookieOpts.CSRFPerRequestLimit = MaxCSRFCombinedLimit
+			var cookie *http.Cookie
+			for i := 0; i < MaxCSRFCombinedLimit+1; i++ {
+				c, err := NewCSRF(cookieOpts, string(make([]byte, 128)))
+				Expect(err).ToNot(HaveOccurred())
+				req := httptest.NewRequest(http.MethodGet, "https://"+cookieDomain+"/oauth2/start", nil)
+				if cookie != nil {
+					req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+				}
+				cookie, err = c.SetCookie(httptest.NewRecorder(), req)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(len(cookie.String())).To(BeNumerically("<", 4096))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package cookies
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -24,7 +25,7 @@ var _ = Describe("CSRF Cookie with non-fixed name Tests", func() {
 	BeforeEach(func() {
 		cookieOpts = &options.Cookie{
 			Name:           cookieName,
-			Secret:         cookieSecret,
+			Secret:         []byte(cookieSecret),
 			Domains:        []string{cookieDomain},
 			Path:           cookiePath,
 			Expire:         time.Hour,
@@ -108,17 +109,17 @@ var _ = Describe("CSRF Cookie with non-fixed name Tests", func() {
 			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
 		})
 
-		It("signs the encoded cookie value", func() {
+		It("encrypts the encoded cookie value as a JWT", func() {
 			encoded, err := privateCSRF.encodeCookie()
 			Expect(err).ToNot(HaveOccurred())
 
-			cookie := &http.Cookie{
-				Name:  privateCSRF.cookieName(),
-				Value: encoded,
-			}
+			claims, err := DecodeCSRFClaims(encoded, cookieOpts.Secret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.State).To(Equal(base64.RawURLEncoding.EncodeToString(privateCSRF.OAuthState)))
+			Expect(claims.CodeVerifier).To(Equal("verifier"))
 
-			_, _, valid := encryption.Validate(cookie, cookieOpts.Secret, cookieOpts.Expire)
-			Expect(valid).To(BeTrue())
+			_, err = DecodeCSRFClaims(encoded, []byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).To(HaveOccurred())
 		})
 	})
 
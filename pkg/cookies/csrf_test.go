@@ -1,10 +1,12 @@
 package cookies
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -12,6 +14,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/vmihailenco/msgpack/v4"
 )
 
 var _ = Describe("CSRF Cookie Tests", func() {
@@ -24,7 +27,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 	BeforeEach(func() {
 		cookieOpts = &options.Cookie{
 			Name:           cookieName,
-			Secret:         cookieSecret,
+			Secret:         []byte(cookieSecret),
 			Domains:        []string{cookieDomain},
 			Path:           cookiePath,
 			Expire:         time.Hour,
@@ -107,17 +110,92 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
 		})
 
-		It("signs the encoded cookie value", func() {
+		It("encrypts the encoded cookie value as a JWT", func() {
 			encoded, err := privateCSRF.encodeCookie()
 			Expect(err).ToNot(HaveOccurred())
 
-			cookie := &http.Cookie{
-				Name:  privateCSRF.cookieName(),
-				Value: encoded,
-			}
+			claims, err := DecodeCSRFClaims(encoded, cookieOpts.Secret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.State).To(Equal(base64.RawURLEncoding.EncodeToString(privateCSRF.OAuthState)))
+			Expect(claims.CodeVerifier).To(Equal("verifier"))
+
+			_, err = DecodeCSRFClaims(encoded, []byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("encodes and decodes the redirect", func() {
+			publicCSRF.SetRedirect("/foo?bar")
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: encoded}, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.GetRedirect()).To(Equal("/foo?bar"))
+			Expect(decoded.GetCodeVerifier()).To(Equal("verifier"))
+		})
+
+		It("encodes and decodes the silent origin", func() {
+			Expect(publicCSRF.GetSilentOrigin()).To(BeEmpty())
+			publicCSRF.SetSilentOrigin("https://app.example.com")
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: encoded}, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.GetSilentOrigin()).To(Equal("https://app.example.com"))
+		})
+
+		It("encodes and decodes whether the session is a browser session", func() {
+			Expect(publicCSRF.GetBrowserSession()).To(BeFalse())
+			publicCSRF.SetBrowserSession(true)
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
 
-			_, _, valid := encryption.Validate(cookie, cookieOpts.Secret, cookieOpts.Expire)
-			Expect(valid).To(BeTrue())
+			decoded, err := decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: encoded}, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.GetBrowserSession()).To(BeTrue())
+		})
+
+		It("fails tampered cookie values", func() {
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			parts := strings.Split(encoded, ".")
+			parts[3] = parts[3][1:] + parts[3][:1]
+			_, err = decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: strings.Join(parts, ".")}, cookieOpts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails expired cookie values", func() {
+			privateCSRF.time.Set(time.Now().Add(-2 * cookieOpts.Expire))
+			defer privateCSRF.time.Reset()
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: encoded}, cookieOpts)
+			Expect(err).To(MatchError("CSRF cookie has expired"))
+		})
+
+		It("decodes cookie values of the legacy format", func() {
+			privateCSRF.OAuthState = []byte(csrfState)
+			privateCSRF.OIDCNonce = []byte(csrfNonce)
+
+			packed, err := msgpack.Marshal(privateCSRF)
+			Expect(err).ToNot(HaveOccurred())
+			encrypted, err := encrypt(packed, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			encoded, err := encryption.SignedValue(cookieOpts.Secret, privateCSRF.cookieName(), encrypted, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := decodeCSRFCookie(&http.Cookie{Name: privateCSRF.cookieName(), Value: encoded}, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.OAuthState).To(Equal([]byte(csrfState)))
+			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
+			Expect(decoded.GetCodeVerifier()).To(Equal("verifier"))
 		})
 	})
 
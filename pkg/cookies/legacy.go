@@ -0,0 +1,53 @@
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// ReadNames returns the names the cookie is read from, in order of
+// preference: its name and then its legacy names.
+func ReadNames(opts *options.Cookie) []string {
+	return append([]string{opts.Name}, opts.LegacyNames...)
+}
+
+// ClearLegacyCookies clears the cookies of the request named with any of the
+// legacy names of the cookie, including the chunks of split cookies, so that
+// they are no longer read once the cookie is saved with its name.
+// The cookies are cleared with the current attributes of the cookie, so a
+// legacy cookie set for another domain or path is left to expire.
+func ClearLegacyCookies(rw http.ResponseWriter, req *http.Request, opts *options.Cookie) {
+	if len(opts.LegacyNames) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, name := range opts.LegacyNames {
+		nameRegex := legacyNameRegex(name)
+		for _, c := range req.Cookies() {
+			if nameRegex.MatchString(c.Name) {
+				http.SetCookie(rw, MakeCookieFromOptions(req, c.Name, "", opts, time.Hour*-1, now))
+			}
+		}
+	}
+}
+
+// legacyNameRegexes holds the compiled regexp of each legacy name, as the
+// legacy names only change when the configuration is reloaded.
+var legacyNameRegexes sync.Map
+
+// legacyNameRegex returns the regexp matching the cookies of a legacy name:
+// name and name_<number>.
+func legacyNameRegex(name string) *regexp.Regexp {
+	if re, ok := legacyNameRegexes.Load(name); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(fmt.Sprintf("^%s(_\\d+)?$", regexp.QuoteMeta(name)))
+	legacyNameRegexes.Store(name, re)
+	return re
+}
@@ -0,0 +1,257 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The algorithms of the ciphers created by NewCipher.
+const (
+	// AESCFBAlgorithm is the legacy cipher, AES CFB without authentication,
+	// whose values have no header
+	AESCFBAlgorithm = "aes-cfb"
+
+	// AESGCMAlgorithm is AES-256-GCM
+	AESGCMAlgorithm = "aes-gcm"
+
+	// XChaCha20Poly1305Algorithm is XChaCha20-Poly1305
+	XChaCha20Poly1305Algorithm = "xchacha20-poly1305"
+
+	// AESGCMSIVAlgorithm is AES-256-GCM-SIV (RFC 8452)
+	AESGCMSIVAlgorithm = "aes-gcm-siv"
+)
+
+// CipherAlgorithms lists the algorithms of the ciphers created by NewCipher.
+var CipherAlgorithms = []string{
+	AESCFBAlgorithm,
+	AESGCMAlgorithm,
+	XChaCha20Poly1305Algorithm,
+	AESGCMSIVAlgorithm,
+}
+
+// headerVersion is the first byte of the header of values encrypted by an
+// algorithm other than the legacy one. The second byte is the ID of the
+// algorithm.
+const headerVersion = 0x01
+
+// algorithm is an authenticated algorithm of NewCipher.
+type algorithm struct {
+	name string
+	id   byte
+	new  func(key []byte) (cipher.AEAD, error)
+	// fipsApproved algorithms are the only ones available in FIPS mode
+	fipsApproved bool
+}
+
+// algorithms are the authenticated algorithms of NewCipher. Their IDs are
+// part of the values they encrypt, so must never change or be reused.
+var algorithms = []algorithm{
+	{name: AESGCMAlgorithm, id: 1, new: newAESGCM, fipsApproved: true},
+	{name: XChaCha20Poly1305Algorithm, id: 2, new: chacha20poly1305.NewX},
+	{name: AESGCMSIVAlgorithm, id: 3, new: newGCMSIV},
+}
+
+// fipsMode restricts NewCipher to the FIPS approved algorithms. It is set on
+// startup, before any cipher is created.
+var fipsMode bool
+
+// SetFIPSMode restricts the ciphers created by NewCipher to FIPS approved
+// algorithms: values encrypted by other authenticated algorithms can then
+// neither be encrypted nor decrypted.
+func SetFIPSMode(enabled bool) {
+	fipsMode = enabled
+}
+
+// IsFIPSApprovedCipher determines whether the algorithm of NewCipher is FIPS
+// approved.
+func IsFIPSApprovedCipher(name string) bool {
+	switch name {
+	case "", AESCFBAlgorithm:
+		return true
+	}
+	for _, a := range algorithms {
+		if a.name == name {
+			return a.fipsApproved
+		}
+	}
+	return false
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type agileCipher struct {
+	// encrypt encrypts new values with the configured algorithm, including
+	// its header
+	encrypt func(value []byte) ([]byte, error)
+
+	// legacy decrypts values without a header, unless they are rejected
+	legacy Cipher
+	aeads  map[byte]Cipher
+}
+
+// NewCipher returns a Cipher that encrypts values with the algorithm, one of
+// the CipherAlgorithms, and decrypts values encrypted by any of them, so that
+// the algorithm can be changed without breaking the values that have already
+// been encrypted.
+// Values encrypted by the authenticated algorithms start with a header that
+// identifies the algorithm, and are encrypted with a key derived from the
+// secret for the algorithm. Values encrypted by the legacy AES CFB algorithm
+// have no header, and are encrypted with the secret, which must be 16, 24 or
+// 32 bytes long.
+// An empty algorithm is the legacy algorithm. In FIPS mode, only the FIPS
+// approved algorithms are available.
+// With rejectLegacy, values without a header are not decrypted, as the legacy
+// algorithm does not authenticate them.
+func NewCipher(name string, secret []byte, rejectLegacy bool) (Cipher, error) {
+	switch name {
+	case "", AESCFBAlgorithm, AESGCMAlgorithm, XChaCha20Poly1305Algorithm, AESGCMSIVAlgorithm:
+	default:
+		return nil, fmt.Errorf("unknown cipher algorithm %q", name)
+	}
+	if fipsMode && !IsFIPSApprovedCipher(name) {
+		return nil, fmt.Errorf("cipher algorithm %q is not FIPS approved", name)
+	}
+
+	legacy, err := NewCFBCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &agileCipher{
+		encrypt: legacy.Encrypt,
+		legacy:  legacy,
+		aeads:   make(map[byte]Cipher, len(algorithms)),
+	}
+	if rejectLegacy {
+		if name == "" || name == AESCFBAlgorithm {
+			return nil, fmt.Errorf("cannot reject the values of the %s algorithm it encrypts with", AESCFBAlgorithm)
+		}
+		c.legacy = nil
+	}
+	for _, a := range algorithms {
+		if fipsMode && !a.fipsApproved {
+			continue
+		}
+		key := deriveKey(secret, a.name)
+		aead, err := a.new(key)
+		// The AEADs keep their own copy of the key
+		Wipe(key)
+		if err != nil {
+			return nil, fmt.Errorf("could not create %s cipher: %v", a.name, err)
+		}
+		ac := &aeadCipher{AEAD: aead}
+		c.aeads[a.id] = ac
+
+		if a.name == name {
+			header := []byte{headerVersion, a.id}
+			c.encrypt = func(value []byte) ([]byte, error) {
+				encrypted, err := ac.Encrypt(value)
+				if err != nil {
+					return nil, err
+				}
+				return append(header, encrypted...), nil
+			}
+		}
+	}
+	return c, nil
+}
+
+// deriveKey derives the 256 bit key of the algorithm from the secret, so
+// that every algorithm has its own key whatever the size of the secret.
+func deriveKey(secret []byte, name string) []byte {
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, secret, nil, []byte("oauth2-proxy cipher "+name))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		// HKDF can derive up to 255 times the size of the hash
+		panic(err)
+	}
+	return key
+}
+
+// Encrypt encrypts the value with the configured algorithm.
+func (c *agileCipher) Encrypt(value []byte) ([]byte, error) {
+	return c.encrypt(value)
+}
+
+// Decrypt decrypts a value encrypted by any of the algorithms.
+// Values with the header of an authenticated algorithm are only decrypted by
+// that algorithm, so that a value failing authentication is rejected rather
+// than decrypted by the unauthenticated legacy algorithm. Legacy values have
+// no header, but start with a random IV, so the few that look like a header
+// can no longer be decrypted, and their users sign in again.
+func (c *agileCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) > 2 && ciphertext[0] == headerVersion {
+		for _, a := range algorithms {
+			if a.id != ciphertext[1] {
+				continue
+			}
+			aead, ok := c.aeads[a.id]
+			if !ok {
+				return nil, fmt.Errorf("cipher algorithm %q is not FIPS approved", a.name)
+			}
+			return aead.Decrypt(ciphertext[2:])
+		}
+	}
+	if c.legacy == nil {
+		return nil, fmt.Errorf("value without the header of an authenticated cipher algorithm is rejected")
+	}
+	return c.legacy.Decrypt(ciphertext)
+}
+
+// aeadCipher encrypts values with an AEAD and a random nonce, which is the
+// start of the encrypted values.
+type aeadCipher struct {
+	cipher.AEAD
+}
+
+// NewXChaCha20Poly1305Cipher returns a new XChaCha20-Poly1305 Cipher, whose
+// secret must be 32 bytes long.
+func NewXChaCha20Poly1305Cipher(secret []byte) (Cipher, error) {
+	aead, err := chacha20poly1305.NewX(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadCipher{AEAD: aead}, nil
+}
+
+// NewGCMSIVCipher returns a new AES-GCM-SIV Cipher, whose secret must be 16
+// or 32 bytes long.
+func NewGCMSIVCipher(secret []byte) (Cipher, error) {
+	aead, err := newGCMSIV(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadCipher{AEAD: aead}, nil
+}
+
+// Encrypt with the AEAD and a random nonce
+func (c *aeadCipher) Encrypt(value []byte) ([]byte, error) {
+	nonce := make([]byte, c.NonceSize(), c.NonceSize()+len(value)+c.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to create nonce %s", err)
+	}
+	return c.Seal(nonce, nonce, value, nil), nil
+}
+
+// Decrypt a ciphertext of the AEAD
+func (c *aeadCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.NonceSize()+c.Overhead() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := ciphertext[:c.NonceSize()], ciphertext[c.NonceSize():]
+	return c.Open(nil, nonce, ciphertext, nil)
+}
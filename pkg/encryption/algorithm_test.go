@@ -0,0 +1,200 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCipherEncryptAndDecrypt(t *testing.T) {
+	for _, algorithm := range CipherAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			for _, secretSize := range []int{16, 24, 32} {
+				t.Run(fmt.Sprintf("%d", secretSize), func(t *testing.T) {
+					secret := make([]byte, secretSize)
+					_, err := io.ReadFull(rand.Reader, secret)
+					assert.Equal(t, nil, err)
+
+					c, err := NewCipher(algorithm, secret, false)
+					assert.Equal(t, nil, err)
+
+					for _, dataSize := range []int{10, 100, 1000, 5000, 10000} {
+						t.Run(fmt.Sprintf("%d", dataSize), func(t *testing.T) {
+							runEncryptAndDecrypt(t, c, dataSize)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestNewCipherHeader(t *testing.T) {
+	secret := []byte("0123456789abcdefghijklmnopqrstuv")
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	for _, a := range algorithms {
+		t.Run(a.name, func(t *testing.T) {
+			c, err := NewCipher(a.name, secret, false)
+			assert.Equal(t, nil, err)
+
+			encrypted, err := c.Encrypt(data)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []byte{headerVersion, a.id}, encrypted[:2])
+		})
+	}
+
+	t.Run(AESCFBAlgorithm, func(t *testing.T) {
+		c, err := NewCipher(AESCFBAlgorithm, secret, false)
+		assert.Equal(t, nil, err)
+		cfb, err := NewCFBCipher(secret)
+		assert.Equal(t, nil, err)
+
+		// Legacy values have no header, so they are still decrypted by the
+		// CFB cipher
+		encrypted, err := c.Encrypt(data)
+		assert.Equal(t, nil, err)
+		decrypted, err := cfb.Decrypt(encrypted)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, data, decrypted)
+	})
+}
+
+// Values encrypted with any algorithm are decrypted whatever the configured
+// algorithm, so that the algorithm can be changed without breaking sessions
+func TestNewCipherMigration(t *testing.T) {
+	secret := []byte("0123456789abcdefghijklmnopqrstuv")
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	for _, from := range CipherAlgorithms {
+		for _, to := range CipherAlgorithms {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				fromCipher, err := NewCipher(from, secret, false)
+				assert.Equal(t, nil, err)
+				toCipher, err := NewCipher(to, secret, false)
+				assert.Equal(t, nil, err)
+
+				encrypted, err := fromCipher.Encrypt(data)
+				assert.Equal(t, nil, err)
+				decrypted, err := toCipher.Decrypt(encrypted)
+				assert.Equal(t, nil, err)
+				assert.Equal(t, data, decrypted)
+			})
+		}
+	}
+}
+
+func TestNewCipherTampered(t *testing.T) {
+	secret := []byte("0123456789abcdefghijklmnopqrstuv")
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	for _, a := range algorithms {
+		t.Run(a.name, func(t *testing.T) {
+			c, err := NewCipher(a.name, secret, false)
+			assert.Equal(t, nil, err)
+
+			encrypted, err := c.Encrypt(data)
+			assert.Equal(t, nil, err)
+			encrypted[len(encrypted)-1] ^= 0x01
+
+			_, err = c.Decrypt(encrypted)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewCipherWrongSecret(t *testing.T) {
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	c1, err := NewCipher(XChaCha20Poly1305Algorithm, []byte("0123456789abcdefghijklmnopqrstuv"), false)
+	assert.Equal(t, nil, err)
+	c2, err := NewCipher(XChaCha20Poly1305Algorithm, []byte("9876543210abcdefghijklmnopqrstuv"), false)
+	assert.Equal(t, nil, err)
+
+	encrypted, err := c1.Encrypt(data)
+	assert.Equal(t, nil, err)
+	_, err = c2.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestNewCipherUnknownAlgorithm(t *testing.T) {
+	_, err := NewCipher("rot13", []byte("0123456789abcdefghijklmnopqrstuv"), false)
+	assert.EqualError(t, err, "unknown cipher algorithm \"rot13\"")
+}
+
+func TestNewCipherFIPSMode(t *testing.T) {
+	secret := []byte("0123456789abcdefghijklmnopqrstuv")
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	chacha, err := NewCipher(XChaCha20Poly1305Algorithm, secret, false)
+	assert.Equal(t, nil, err)
+	encrypted, err := chacha.Encrypt(data)
+	assert.Equal(t, nil, err)
+
+	SetFIPSMode(true)
+	defer SetFIPSMode(false)
+
+	for _, algorithm := range []string{XChaCha20Poly1305Algorithm, AESGCMSIVAlgorithm} {
+		_, err := NewCipher(algorithm, secret, false)
+		assert.EqualError(t, err, fmt.Sprintf("cipher algorithm %q is not FIPS approved", algorithm))
+	}
+
+	c, err := NewCipher(AESGCMAlgorithm, secret, false)
+	assert.Equal(t, nil, err)
+	for _, a := range algorithms {
+		_, ok := c.(*agileCipher).aeads[a.id]
+		assert.Equal(t, a.fipsApproved, ok, a.name)
+	}
+	_, err = c.Decrypt(encrypted)
+	assert.EqualError(t, err, fmt.Sprintf("cipher algorithm %q is not FIPS approved", XChaCha20Poly1305Algorithm))
+}
+
+func TestNewCipherRejectLegacy(t *testing.T) {
+	secret := []byte("0123456789abcdefghijklmnopqrstuv")
+	data := []byte("f3928pufm982374dj02y485dsl34890u2t9nd4028s94dm58y2394087dhmsyt29h8df")
+
+	for _, algorithm := range []string{"", AESCFBAlgorithm} {
+		_, err := NewCipher(algorithm, secret, true)
+		assert.EqualError(t, err, "cannot reject the values of the aes-cfb algorithm it encrypts with")
+	}
+
+	legacy, err := NewCipher(AESCFBAlgorithm, secret, false)
+	assert.Equal(t, nil, err)
+	legacyEncrypted, err := legacy.Encrypt(data)
+	assert.Equal(t, nil, err)
+
+	c, err := NewCipher(AESGCMAlgorithm, secret, true)
+	assert.Equal(t, nil, err)
+	_, err = c.Decrypt(legacyEncrypted)
+	assert.Error(t, err)
+
+	encrypted, err := c.Encrypt(data)
+	assert.Equal(t, nil, err)
+	decrypted, err := c.Decrypt(encrypted)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, data, decrypted)
+}
+
+func TestAEADCiphers(t *testing.T) {
+	cipherInits := map[string]func([]byte) (Cipher, error){
+		"XChaCha20Poly1305": NewXChaCha20Poly1305Cipher,
+		"GCMSIV":            NewGCMSIVCipher,
+	}
+	for name, initCipher := range cipherInits {
+		t.Run(name, func(t *testing.T) {
+			secret := make([]byte, 32)
+			_, err := io.ReadFull(rand.Reader, secret)
+			assert.Equal(t, nil, err)
+
+			c, err := initCipher(secret)
+			assert.Equal(t, nil, err)
+			runEncryptAndDecrypt(t, c, 1000)
+
+			_, err = c.Decrypt([]byte("too short"))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+
+	// gcmSIVMaxLength is the longest plaintext or additional data that may be
+	// sealed, 2^36 bytes
+	gcmSIVMaxLength = 1 << 36
+)
+
+var errGCMSIVOpen = errors.New("cipher: message authentication failed")
+
+// gcmSIV implements AES-GCM-SIV (RFC 8452), a nonce misuse resistant AEAD:
+// repeating a nonce only reveals whether the same message was sealed twice.
+type gcmSIV struct {
+	block   cipher.Block
+	keySize int
+}
+
+// newGCMSIV returns AES-GCM-SIV with the key generating key, which must be
+// 16 or 32 bytes long for AES-128-GCM-SIV or AES-256-GCM-SIV.
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 16 && len(key) != 32 {
+		return nil, aes.KeySizeError(len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{block: block, keySize: len(key)}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open.
+func (g *gcmSIV) NonceSize() int {
+	return gcmSIVNonceSize
+}
+
+// Overhead returns the difference between the lengths of a plaintext and its
+// ciphertext, which is the size of the tag.
+func (g *gcmSIV) Overhead() int {
+	return gcmSIVTagSize
+}
+
+// Seal encrypts and authenticates the plaintext and additional data, and
+// appends the result to dst.
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("cipher: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLength || uint64(len(additionalData)) > gcmSIVMaxLength {
+		panic("cipher: message too large for AES-GCM-SIV")
+	}
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	tag := g.tag(authKey, encBlock, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	gcmSIVCTR(encBlock, tag, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+// Open decrypts and authenticates the ciphertext and additional data, and
+// appends the plaintext to dst.
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("cipher: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if len(ciphertext) < gcmSIVTagSize || uint64(len(ciphertext)) > gcmSIVMaxLength+gcmSIVTagSize ||
+		uint64(len(additionalData)) > gcmSIVMaxLength {
+		return nil, errGCMSIVOpen
+	}
+
+	var tag [gcmSIVTagSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	gcmSIVCTR(encBlock, tag, out, ciphertext)
+
+	expected := g.tag(authKey, encBlock, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errGCMSIVOpen
+	}
+	return ret, nil
+}
+
+// deriveKeys derives the message authentication key and the message
+// encryption key of the nonce from the key generating key.
+func (g *gcmSIV) deriveKeys(nonce []byte) ([16]byte, cipher.Block) {
+	var input, output [16]byte
+	copy(input[4:], nonce)
+
+	derive := func(counter uint32) []byte {
+		binary.LittleEndian.PutUint32(input[:4], counter)
+		g.block.Encrypt(output[:], input[:])
+		return output[:8]
+	}
+
+	var authKey [16]byte
+	copy(authKey[:8], derive(0))
+	copy(authKey[8:], derive(1))
+
+	// The message encryption key is as long as the key generating key
+	encKey := make([]byte, 0, 32)
+	for counter := uint32(2); len(encKey) < g.keySize; counter++ {
+		encKey = append(encKey, derive(counter)...)
+	}
+	encBlock, err := aes.NewCipher(encKey)
+	if err != nil {
+		// The key is always 16 or 32 bytes long
+		panic(err)
+	}
+	return authKey, encBlock
+}
+
+// tag computes the tag of the plaintext and additional data.
+func (g *gcmSIV) tag(authKey [16]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+
+	var tag [16]byte
+	encBlock.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// gcmSIVCTR encrypts or decrypts the input to the output with AES in the
+// counter mode of AES-GCM-SIV, whose initial counter is the tag with its most
+// significant bit set, and which increments the first 32 bits of the counter
+// as a little endian integer.
+func gcmSIVCTR(block cipher.Block, tag [16]byte, out, in []byte) {
+	counter := tag
+	counter[15] |= 0x80
+
+	var keystream [16]byte
+	for len(in) > 0 {
+		block.Encrypt(keystream[:], counter[:])
+		binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+
+		n := len(in)
+		if n > 16 {
+			n = 16
+		}
+		for i := 0; i < n; i++ {
+			out[i] = in[i] ^ keystream[i]
+		}
+		out, in = out[n:], in[n:]
+	}
+}
+
+// sliceForAppend extends the slice by n bytes, returning the extended slice
+// and the n bytes that were added.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolyval(t *testing.T) {
+	// The example of RFC 8452 Appendix A
+	var key [16]byte
+	copy(key[:], mustDecodeHex(t, "25629347589242761d31f826ba4b757b"))
+
+	p := newPolyval(key)
+	p.update(mustDecodeHex(t, "4f4f95668c83dfb6401762bb2d01a262d1a24ddd2721d006bbe45f20d3c9f362"))
+	sum := p.sum()
+	assert.Equal(t, "f7a3b47b846119fae5b7866cf5e5b77e", hex.EncodeToString(sum[:]))
+}
+
+func TestGCMSIV(t *testing.T) {
+	// Test vectors of RFC 8452 Appendix C
+	testCases := map[string]struct {
+		key        string
+		nonce      string
+		plaintext  string
+		aad        string
+		ciphertext string
+	}{
+		"AES-128 empty plaintext": {
+			key:        "01000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			ciphertext: "dc20e2d83f25705bb49e439eca56de25",
+		},
+		"AES-128 8 bytes": {
+			key:        "01000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			plaintext:  "0100000000000000",
+			ciphertext: "b5d839330ac7b786578782fff6013b815b287c22493a364c",
+		},
+		"AES-128 12 bytes": {
+			key:        "01000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			plaintext:  "010000000000000000000000",
+			ciphertext: "7323ea61d05932260047d942a4978db357391a0bc4fdec8b0d106639",
+		},
+		"AES-128 with additional data": {
+			key:        "01000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			plaintext:  "0200000000000000",
+			aad:        "01",
+			ciphertext: "1e6daba35669f4273b0a1a2560969cdf790d99759abd1508",
+		},
+		"AES-256 empty plaintext": {
+			key:        "0100000000000000000000000000000000000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			ciphertext: "07f5f4169bbf55a8400cd47ea6fd400f",
+		},
+		"AES-256 8 bytes": {
+			key:        "0100000000000000000000000000000000000000000000000000000000000000",
+			nonce:      "030000000000000000000000",
+			plaintext:  "0100000000000000",
+			ciphertext: "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			aead, err := newGCMSIV(mustDecodeHex(t, tc.key))
+			assert.NoError(t, err)
+
+			nonce := mustDecodeHex(t, tc.nonce)
+			aad := mustDecodeHex(t, tc.aad)
+			sealed := aead.Seal(nil, nonce, mustDecodeHex(t, tc.plaintext), aad)
+			assert.Equal(t, tc.ciphertext, hex.EncodeToString(sealed))
+
+			opened, err := aead.Open(nil, nonce, sealed, aad)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.plaintext, hex.EncodeToString(opened))
+
+			sealed[0] ^= 0x01
+			_, err = aead.Open(nil, nonce, sealed, aad)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGCMSIVKeySize(t *testing.T) {
+	_, err := newGCMSIV(make([]byte, 24))
+	assert.Error(t, err)
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+	return b
+}
@@ -0,0 +1,74 @@
+package encryption
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// NewJWEEncrypter creates an encrypter of JWEs (RFC 7516) for the PEM encoded
+// public key, an RSA or ECDSA key or a certificate of one.
+// The content is encrypted with A256GCM and the content key is encrypted
+// with RSA-OAEP-256 for RSA keys and ECDH-ES+A256KW for ECDSA keys.
+// The key ID is set as the kid of the JWEs when given, so that the recipient
+// can select its decryption key.
+func NewJWEEncrypter(keyPEM []byte, keyID string) (jose.Encrypter, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate: %v", err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse RSA public key: %v", err)
+		}
+		key = rsaKey
+	default:
+		pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key: %v", err)
+		}
+		key = pubKey
+	}
+
+	var alg jose.KeyAlgorithm
+	switch key.(type) {
+	case *rsa.PublicKey:
+		alg = jose.RSA_OAEP_256
+	case *ecdsa.PublicKey:
+		alg = jose.ECDH_ES_A256KW
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T, must be RSA or ECDSA", key)
+	}
+
+	return jose.NewEncrypter(jose.A256GCM, jose.Recipient{
+		Algorithm: alg,
+		Key:       key,
+		KeyID:     keyID,
+	}, nil)
+}
+
+// LoadJWEEncrypter creates an encrypter of JWEs for the PEM encoded public
+// key in the file.
+func LoadJWEEncrypter(keyFile string, keyID string) (jose.Encrypter, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key file: %v", err)
+	}
+	return NewJWEEncrypter(keyPEM, keyID)
+}
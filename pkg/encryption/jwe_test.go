@@ -0,0 +1,93 @@
+package encryption
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestNewJWEEncrypter(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Equal(t, nil, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Equal(t, nil, err)
+
+	testCases := []struct {
+		name       string
+		privateKey crypto.Signer
+		pemType    string
+		algorithm  string
+	}{
+		{
+			name:       "with an RSA key",
+			privateKey: rsaKey,
+			pemType:    "PUBLIC KEY",
+			algorithm:  string(jose.RSA_OAEP_256),
+		},
+		{
+			name:       "with a PKCS1 RSA key",
+			privateKey: rsaKey,
+			pemType:    "RSA PUBLIC KEY",
+			algorithm:  string(jose.RSA_OAEP_256),
+		},
+		{
+			name:       "with an ECDSA key",
+			privateKey: ecKey,
+			pemType:    "PUBLIC KEY",
+			algorithm:  string(jose.ECDH_ES_A256KW),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var der []byte
+			if tc.pemType == "RSA PUBLIC KEY" {
+				der = x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+			} else {
+				der, err = x509.MarshalPKIXPublicKey(tc.privateKey.Public())
+				assert.Equal(t, nil, err)
+			}
+
+			encrypter, err := NewJWEEncrypter(pem.EncodeToMemory(&pem.Block{Type: tc.pemType, Bytes: der}), "key-1")
+			assert.Equal(t, nil, err)
+
+			jwe, err := encrypter.Encrypt([]byte("access-token"))
+			assert.Equal(t, nil, err)
+			serialized, err := jwe.CompactSerialize()
+			assert.Equal(t, nil, err)
+
+			parsed, err := jose.ParseEncrypted(serialized)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tc.algorithm, parsed.Header.Algorithm)
+			assert.Equal(t, "key-1", parsed.Header.KeyID)
+
+			decrypted, err := parsed.Decrypt(tc.privateKey)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []byte("access-token"), decrypted)
+		})
+	}
+
+	t.Run("with an unsupported key", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.Equal(t, nil, err)
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		assert.Equal(t, nil, err)
+
+		_, err = NewJWEEncrypter(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "")
+		assert.EqualError(t, err, "unsupported public key type ed25519.PublicKey, must be RSA or ECDSA")
+	})
+
+	t.Run("without a PEM encoded key", func(t *testing.T) {
+		_, err := NewJWEEncrypter([]byte("not a key"), "")
+		assert.EqualError(t, err, "no PEM encoded public key found")
+	})
+}
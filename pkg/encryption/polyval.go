@@ -0,0 +1,93 @@
+package encryption
+
+import "encoding/binary"
+
+// fieldElement is an element of the field of POLYVAL, GF(2^128) defined by
+// the polynomial x^128 + x^127 + x^126 + x^121 + 1, whose coefficient of x^i
+// is bit i of the little endian 128 bit integer {hi, lo}.
+type fieldElement struct {
+	lo, hi uint64
+}
+
+func loadFieldElement(b []byte) fieldElement {
+	return fieldElement{
+		lo: binary.LittleEndian.Uint64(b[:8]),
+		hi: binary.LittleEndian.Uint64(b[8:16]),
+	}
+}
+
+func (e fieldElement) xor(o fieldElement) fieldElement {
+	return fieldElement{lo: e.lo ^ o.lo, hi: e.hi ^ o.hi}
+}
+
+// mulX multiplies the element by x.
+func (e fieldElement) mulX() fieldElement {
+	overflow := e.hi >> 63
+	e.hi = e.hi<<1 | e.lo>>63
+	e.lo <<= 1
+	// x^128 = x^127 + x^126 + x^121 + 1
+	e.hi ^= overflow * (1<<63 | 1<<62 | 1<<57)
+	e.lo ^= overflow
+	return e
+}
+
+// divX divides the element by x.
+func (e fieldElement) divX() fieldElement {
+	carry := e.lo & 1
+	e.lo = e.lo>>1 | e.hi<<63
+	e.hi >>= 1
+	// x^-1 = x^127 + x^126 + x^125 + x^120
+	e.hi ^= carry * (1<<63 | 1<<62 | 1<<61 | 1<<56)
+	return e
+}
+
+// mul multiplies the elements.
+func (e fieldElement) mul(o fieldElement) fieldElement {
+	var product fieldElement
+	for _, word := range []uint64{o.lo, o.hi} {
+		for i := 0; i < 64; i++ {
+			mask := -(word >> i & 1)
+			product.lo ^= e.lo & mask
+			product.hi ^= e.hi & mask
+			e = e.mulX()
+		}
+	}
+	return product
+}
+
+// polyval computes POLYVAL (RFC 8452), the universal hash of AES-GCM-SIV.
+type polyval struct {
+	// h is the key multiplied by x^-128, so that multiplying by it computes
+	// the dot operation of POLYVAL
+	h fieldElement
+	s fieldElement
+}
+
+func newPolyval(key [16]byte) *polyval {
+	h := loadFieldElement(key[:])
+	for i := 0; i < 128; i++ {
+		h = h.divX()
+	}
+	return &polyval{h: h}
+}
+
+// update hashes the data, padded with zeros to a multiple of 16 bytes.
+func (p *polyval) update(data []byte) {
+	var block [16]byte
+	for len(data) > 0 {
+		n := copy(block[:], data)
+		for i := n; i < 16; i++ {
+			block[i] = 0
+		}
+		data = data[n:]
+		p.s = p.s.xor(loadFieldElement(block[:])).mul(p.h)
+	}
+}
+
+// sum returns the hash of the data.
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	binary.LittleEndian.PutUint64(out[:8], p.s.lo)
+	binary.LittleEndian.PutUint64(out[8:], p.s.hi)
+	return out
+}
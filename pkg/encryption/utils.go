@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -17,29 +18,33 @@ const (
 	CodeChallengeMethodS256  = "S256"
 )
 
-// SecretBytes attempts to base64 decode the secret, if that fails it treats the secret as binary
-func SecretBytes(secret string) []byte {
-	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(secret, "="))
+// SecretBytes attempts to base64 decode the secret, if that fails it treats the secret as binary.
+// It returns a new buffer, which the caller should Wipe once it is no longer needed.
+func SecretBytes(secret []byte) []byte {
+	trimmed := bytes.TrimRight(secret, "=")
+	b := make([]byte, base64.RawURLEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.RawURLEncoding.Decode(b, trimmed)
 	if err == nil {
 		// Only return decoded form if a valid AES length
 		// Don't want unintentional decoding resulting in invalid lengths confusing a user
 		// that thought they used a 16, 24, 32 length string
 		for _, i := range []int{16, 24, 32} {
-			if len(b) == i {
-				return b
+			if n == i {
+				return b[:n]
 			}
 		}
 	}
+	Wipe(b)
 	// If decoding didn't work or resulted in non-AES compliant length,
 	// assume the raw string was the intended secret
-	return []byte(secret)
+	return append([]byte(nil), secret...)
 }
 
 // cookies are stored in a 3 part (value + timestamp + signature) to enforce that the values are as originally set.
 // additionally, the 'value' is encrypted so it's opaque to the browser
 
 // Validate ensures a cookie is properly signed
-func Validate(cookie *http.Cookie, seed string, expiration time.Duration) (value []byte, t time.Time, ok bool) {
+func Validate(cookie *http.Cookie, seed []byte, expiration time.Duration) (value []byte, t time.Time, ok bool) {
 	// value, timestamp, sig
 	parts := strings.Split(cookie.Value, "|")
 	if len(parts) != 3 {
@@ -69,7 +74,7 @@ func Validate(cookie *http.Cookie, seed string, expiration time.Duration) (value
 }
 
 // SignedValue returns a cookie that is signed and can later be checked with Validate
-func SignedValue(seed string, key string, value []byte, now time.Time) (string, error) {
+func SignedValue(seed []byte, key string, value []byte, now time.Time) (string, error) {
 	encodedValue := base64.URLEncoding.EncodeToString(value)
 	timeStr := fmt.Sprintf("%d", now.Unix())
 	sig, err := cookieSignature(sha256.New, seed, key, encodedValue, timeStr)
@@ -92,9 +97,9 @@ func GenerateCodeChallenge(method, codeVerifier string) (string, error) {
 	}
 }
 
-func cookieSignature(signer func() hash.Hash, args ...string) (string, error) {
-	h := hmac.New(signer, []byte(args[0]))
-	for _, arg := range args[1:] {
+func cookieSignature(signer func() hash.Hash, seed []byte, args ...string) (string, error) {
+	h := hmac.New(signer, seed)
+	for _, arg := range args {
 		_, err := h.Write([]byte(arg))
 		if err != nil {
 			return "", err
@@ -105,8 +110,8 @@ func cookieSignature(signer func() hash.Hash, args ...string) (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func checkSignature(signature string, args ...string) bool {
-	checkSig, err := cookieSignature(sha256.New, args...)
+func checkSignature(signature string, seed []byte, args ...string) bool {
+	checkSig, err := cookieSignature(sha256.New, seed, args...)
 	if err != nil {
 		return false
 	}
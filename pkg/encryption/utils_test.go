@@ -22,12 +22,12 @@ func TestSecretBytesEncoded(t *testing.T) {
 			// We test both padded & raw Base64 to ensure we handle both
 			// potential user input routes for Base64
 			base64Padded := base64.URLEncoding.EncodeToString(secret)
-			sb := SecretBytes(base64Padded)
+			sb := SecretBytes([]byte(base64Padded))
 			assert.Equal(t, secret, sb)
 			assert.Equal(t, len(sb), secretSize)
 
 			base64Raw := base64.RawURLEncoding.EncodeToString(secret)
-			sb = SecretBytes(base64Raw)
+			sb = SecretBytes([]byte(base64Raw))
 			assert.Equal(t, secret, sb)
 			assert.Equal(t, len(sb), secretSize)
 		})
@@ -46,14 +46,14 @@ func TestSecretBytesEncodedWrongSize(t *testing.T) {
 			// We test both padded & raw Base64 to ensure we handle both
 			// potential user input routes for Base64
 			base64Padded := base64.URLEncoding.EncodeToString(secret)
-			sb := SecretBytes(base64Padded)
+			sb := SecretBytes([]byte(base64Padded))
 			assert.NotEqual(t, secret, sb)
 			assert.NotEqual(t, len(sb), secretSize)
 			// The given secret is returned as []byte
 			assert.Equal(t, base64Padded, string(sb))
 
 			base64Raw := base64.RawURLEncoding.EncodeToString(secret)
-			sb = SecretBytes(base64Raw)
+			sb = SecretBytes([]byte(base64Raw))
 			assert.NotEqual(t, secret, sb)
 			assert.NotEqual(t, len(sb), secretSize)
 			// The given secret is returned as []byte
@@ -64,26 +64,34 @@ func TestSecretBytesEncodedWrongSize(t *testing.T) {
 
 func TestSecretBytesNonBase64(t *testing.T) {
 	trailer := "equals=========="
-	assert.Equal(t, trailer, string(SecretBytes(trailer)))
+	assert.Equal(t, trailer, string(SecretBytes([]byte(trailer))))
 
 	raw16 := "asdflkjhqwer)(*&"
-	sb16 := SecretBytes(raw16)
+	sb16 := SecretBytes([]byte(raw16))
 	assert.Equal(t, raw16, string(sb16))
 	assert.Equal(t, 16, len(sb16))
 
 	raw24 := "asdflkjhqwer)(*&CJEN#$%^"
-	sb24 := SecretBytes(raw24)
+	sb24 := SecretBytes([]byte(raw24))
 	assert.Equal(t, raw24, string(sb24))
 	assert.Equal(t, 24, len(sb24))
 
 	raw32 := "asdflkjhqwer)(*&1234lkjhqwer)(*&"
-	sb32 := SecretBytes(raw32)
+	sb32 := SecretBytes([]byte(raw32))
 	assert.Equal(t, raw32, string(sb32))
 	assert.Equal(t, 32, len(sb32))
 }
 
+func TestSecretBytesReturnsNewBuffer(t *testing.T) {
+	for _, secret := range []string{"asdflkjhqwer)(*&", base64.URLEncoding.EncodeToString([]byte("asdflkjhqwer)(*&"))} {
+		given := []byte(secret)
+		Wipe(SecretBytes(given))
+		assert.Equal(t, secret, string(given))
+	}
+}
+
 func TestSignAndValidate(t *testing.T) {
-	seed := "0123456789abcdef"
+	seed := []byte("0123456789abcdef")
 	key := "cookie-name"
 	value := base64.URLEncoding.EncodeToString([]byte("I am soooo encoded"))
 	epoch := "123456789"
@@ -0,0 +1,17 @@
+package encryption
+
+import "runtime"
+
+// Wipe overwrites the secret bytes with zeros once they are no longer needed,
+// so that they are not left in memory, where they could be read from core
+// dumps or heap profiles, until the garbage collector reuses it.
+// This is best effort: the runtime may have copied the bytes when growing
+// or moving them, and strings cannot be wiped.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	// Keep the bytes alive until they are wiped, so that the writes are not
+	// optimised away
+	runtime.KeepAlive(b)
+}
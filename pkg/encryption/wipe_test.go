@@ -0,0 +1,17 @@
+package encryption
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWipe(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	Wipe(secret)
+	assert.Equal(t, make([]byte, 16), secret)
+
+	// Wiping nil or empty secrets is a no-op
+	Wipe(nil)
+	Wipe([]byte{})
+}
@@ -0,0 +1,113 @@
+package errcode
+
+import (
+	"net/http"
+	"strings"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The machine-readable codes of the errors that requests fail with. They are
+// stable, so that alerts and dashboards can rely on them rather than on the
+// messages of errors.
+const (
+	// InternalError is an unexpected error of the proxy
+	InternalError = "internal_error"
+	// InvalidRedirect is a redirect that could not be determined
+	InvalidRedirect = "invalid_redirect"
+
+	// ChallengeFailed is a sign-in whose response to the sign-in challenge
+	// was rejected
+	ChallengeFailed = "challenge_failed"
+
+	// ProviderError is an error returned by the provider to the callback
+	ProviderError = "provider_error"
+	// CSRFMissing is a callback without a valid CSRF cookie
+	CSRFMissing = "csrf_missing"
+	// CSRFMismatch is a callback whose state does not match its CSRF cookie
+	CSRFMismatch = "csrf_mismatch"
+	// InvalidState is a callback whose state could not be decoded
+	InvalidState = "invalid_state"
+	// CallbackReplayed is a callback whose authorization code or OIDC nonce
+	// was already used
+	CallbackReplayed = "callback_replayed"
+	// RedeemFailed is a code that could not be redeemed with the provider
+	RedeemFailed = "redeem_failed"
+	// EnrichFailed is a session whose details could not be fetched from the
+	// provider
+	EnrichFailed = "enrich_failed"
+
+	// EmailDomainDenied is a user whose email domain is not allowed
+	EmailDomainDenied = "email_domain_denied"
+	// EmailListDenied is a user who is not in the authenticated emails file
+	EmailListDenied = "email_list_denied"
+	// EmailDenied is a user whose email address is not allowed
+	EmailDenied = "email_denied"
+	// GroupDenied is a user who is not in an allowed group
+	GroupDenied = "group_denied"
+
+	// SessionInvalid is a session that failed validation with the provider
+	SessionInvalid = "session_invalid"
+	// SessionExpired is a session whose tokens have expired
+	SessionExpired = "session_expired"
+	// SessionSaveFailed is a session that could not be saved
+	SessionSaveFailed = "session_save_failed"
+	// RefreshFailed is a session that could not be refreshed
+	RefreshFailed = "refresh_failed"
+	// RefreshFailedInvalidGrant is a session whose refresh token was
+	// rejected by the provider, usually because it was revoked or expired
+	RefreshFailedInvalidGrant = "refresh_failed_invalid_grant"
+
+	// UpstreamError is an upstream that could not be reached
+	UpstreamError = "upstream_error"
+
+	// Overloaded is a request rejected because the proxy was at one of its
+	// concurrency limits
+	Overloaded = "overloaded"
+)
+
+var errorsCounter = registerErrorsCounter(prometheus.DefaultRegisterer)
+
+// Record records the code of the error that the request failed with in its
+// scope, so that it is written to the request and auth logs, and counts it
+// in the `oauth2_proxy_errors_total` metric.
+// Record should be called before the error is logged.
+func Record(req *http.Request, code string) {
+	if scope := middlewareapi.GetRequestScope(req); scope != nil {
+		scope.ErrorCode = code
+	}
+	errorsCounter.WithLabelValues(code).Inc()
+}
+
+// Refresh returns the code of the error of a refresh of a session.
+// An `invalid_grant` error, which the provider returns for refresh tokens
+// that are revoked or expired, has its own code.
+func Refresh(err error) string {
+	if strings.Contains(err.Error(), "invalid_grant") {
+		return RefreshFailedInvalidGrant
+	}
+	return RefreshFailed
+}
+
+// registerErrorsCounter registers the 'oauth2_proxy_errors_total' metric.
+// This keeps a tally of the errors that requests failed with, by their code.
+func registerErrorsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_errors_total",
+			Help: "Total number of errors that requests failed with by error code.",
+		},
+		[]string{"code"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
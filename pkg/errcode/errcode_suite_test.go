@@ -0,0 +1,13 @@
+package errcode
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestErrCodeSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Error Codes")
+}
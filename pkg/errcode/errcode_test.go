@@ -0,0 +1,43 @@
+package errcode
+
+import (
+	"errors"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Error codes", func() {
+	Context("Record", func() {
+		It("sets the code in the scope of the request and counts it", func() {
+			before := testutil.ToFloat64(errorsCounter.WithLabelValues(CSRFMismatch))
+
+			req := httptest.NewRequest("", "/oauth2/callback", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			Record(req, CSRFMismatch)
+
+			Expect(middlewareapi.GetRequestScope(req).ErrorCode).To(Equal(CSRFMismatch))
+			Expect(testutil.ToFloat64(errorsCounter.WithLabelValues(CSRFMismatch))).To(Equal(before + 1))
+		})
+
+		It("counts the code of a request without a scope", func() {
+			before := testutil.ToFloat64(errorsCounter.WithLabelValues(InternalError))
+
+			Record(httptest.NewRequest("", "/", nil), InternalError)
+
+			Expect(testutil.ToFloat64(errorsCounter.WithLabelValues(InternalError))).To(Equal(before + 1))
+		})
+	})
+
+	DescribeTable("Refresh",
+		func(err error, expected string) {
+			Expect(Refresh(err)).To(Equal(expected))
+		},
+		Entry("with an invalid grant", errors.New("oauth2: cannot fetch token: 400 Bad Request\nResponse: {\"error\":\"invalid_grant\"}"), RefreshFailedInvalidGrant),
+		Entry("with another error", errors.New("dial tcp: connection refused"), RefreshFailed),
+	)
+})
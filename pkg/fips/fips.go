@@ -0,0 +1,75 @@
+// Package fips restricts the cryptography of the proxy to the algorithms
+// approved by FIPS 140-3, for regulated deployments.
+//
+// Builds using a FIPS validated cryptographic module, either BoringCrypto
+// (GOEXPERIMENT=boringcrypto) or the Go Cryptographic Module (GOFIPS140),
+// always run in FIPS mode. Other builds can be restricted to the approved
+// algorithms at runtime with the fips_mode option.
+package fips
+
+import (
+	"crypto"
+	"crypto/tls"
+)
+
+// ApprovedCipherSuites are the FIPS approved TLS 1.2 cipher suites.
+// TLS 1.3 cipher suites are not configurable and are restricted by the
+// validated cryptographic module.
+var ApprovedCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// ApprovedCurves are the FIPS approved elliptic curves for TLS key exchange.
+var ApprovedCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+	tls.CurveP521,
+}
+
+// approvedHMACHashes are the FIPS approved hashes of HMACs.
+var approvedHMACHashes = map[crypto.Hash]bool{
+	crypto.SHA1:   true,
+	crypto.SHA224: true,
+	crypto.SHA256: true,
+	crypto.SHA384: true,
+	crypto.SHA512: true,
+}
+
+// Enabled determines whether the proxy runs in FIPS mode, because it was
+// built with a FIPS validated cryptographic module or because FIPS mode was
+// requested.
+func Enabled(requested bool) bool {
+	return requested || ModuleEnabled()
+}
+
+// IsApprovedCipherSuite determines whether the named TLS cipher suite is
+// FIPS approved.
+func IsApprovedCipherSuite(name string) bool {
+	for _, id := range ApprovedCipherSuites {
+		if tls.CipherSuiteName(id) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsApprovedHMACHash determines whether the hash is FIPS approved for HMACs.
+func IsApprovedHMACHash(hash crypto.Hash) bool {
+	return approvedHMACHashes[hash]
+}
+
+// ApplyTLSConfig restricts the cipher suites and curves of the TLS config to
+// those that are FIPS approved, unless cipher suites are configured, which
+// are validated instead.
+func ApplyTLSConfig(config *tls.Config) {
+	if len(config.CipherSuites) == 0 {
+		config.CipherSuites = ApprovedCipherSuites
+	}
+	config.CurvePreferences = ApprovedCurves
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+}
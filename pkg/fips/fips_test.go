@@ -0,0 +1,57 @@
+package fips
+
+import (
+	"crypto"
+	"crypto/tls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FIPS", func() {
+	DescribeTable("IsApprovedCipherSuite",
+		func(name string, approved bool) {
+			Expect(IsApprovedCipherSuite(name)).To(Equal(approved))
+		},
+		Entry("with an AES-GCM ECDHE suite", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", true),
+		Entry("with a ChaCha20-Poly1305 suite", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", false),
+		Entry("with an RC4 suite", "TLS_RSA_WITH_RC4_128_SHA", false),
+		Entry("with an RSA key exchange suite", "TLS_RSA_WITH_AES_256_GCM_SHA384", false),
+		Entry("with an unknown suite", "TLS_UNKNOWN", false),
+	)
+
+	DescribeTable("IsApprovedHMACHash",
+		func(hash crypto.Hash, approved bool) {
+			Expect(IsApprovedHMACHash(hash)).To(Equal(approved))
+		},
+		Entry("with SHA-256", crypto.SHA256, true),
+		Entry("with SHA-1", crypto.SHA1, true),
+		Entry("with MD5", crypto.MD5, false),
+	)
+
+	Context("ApplyTLSConfig", func() {
+		It("restricts the cipher suites and curves", func() {
+			config := &tls.Config{}
+			ApplyTLSConfig(config)
+			Expect(config.CipherSuites).To(Equal(ApprovedCipherSuites))
+			Expect(config.CurvePreferences).To(Equal(ApprovedCurves))
+			Expect(config.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+		})
+
+		It("keeps the configured cipher suites and minimum version", func() {
+			config := &tls.Config{
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				MinVersion:   tls.VersionTLS13,
+			}
+			ApplyTLSConfig(config)
+			Expect(config.CipherSuites).To(Equal([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}))
+			Expect(config.MinVersion).To(Equal(uint16(tls.VersionTLS13)))
+		})
+	})
+
+	It("is enabled when requested", func() {
+		Expect(Enabled(true)).To(BeTrue())
+		Expect(Enabled(false)).To(Equal(ModuleEnabled()))
+	})
+})
@@ -0,0 +1,18 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package fips
+
+import (
+	// Restrict crypto/tls to FIPS approved settings
+	_ "crypto/tls/fipsonly"
+)
+
+// Module is the FIPS validated cryptographic module the proxy was built with.
+const Module = "BoringCrypto"
+
+// ModuleEnabled determines whether the proxy uses a FIPS validated
+// cryptographic module.
+func ModuleEnabled() bool {
+	return true
+}
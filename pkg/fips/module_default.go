@@ -0,0 +1,14 @@
+//go:build !go1.24 && !boringcrypto
+// +build !go1.24,!boringcrypto
+
+package fips
+
+// Module is the FIPS validated cryptographic module the proxy was built
+// with, none for this build.
+const Module = ""
+
+// ModuleEnabled determines whether the proxy uses a FIPS validated
+// cryptographic module.
+func ModuleEnabled() bool {
+	return false
+}
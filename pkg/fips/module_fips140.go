@@ -0,0 +1,16 @@
+//go:build go1.24 && !boringcrypto
+// +build go1.24,!boringcrypto
+
+package fips
+
+import "crypto/fips140"
+
+// Module is the FIPS validated cryptographic module the proxy can be run
+// with, by setting GOFIPS140 when building or GODEBUG=fips140=on.
+const Module = "Go Cryptographic Module"
+
+// ModuleEnabled determines whether the proxy uses a FIPS validated
+// cryptographic module.
+func ModuleEnabled() bool {
+	return fips140.Enabled()
+}
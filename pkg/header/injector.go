@@ -1,9 +1,12 @@
 package header
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strings"
+	"text/template"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
@@ -45,10 +48,12 @@ type valueInjector interface {
 
 func newValueinjector(name string, value options.HeaderValue) (valueInjector, error) {
 	switch {
-	case value.SecretSource != nil && value.ClaimSource == nil:
+	case value.SecretSource != nil && value.ClaimSource == nil && value.TemplateSource == nil:
 		return newSecretInjector(name, value.SecretSource)
-	case value.SecretSource == nil && value.ClaimSource != nil:
+	case value.SecretSource == nil && value.ClaimSource != nil && value.TemplateSource == nil:
 		return newClaimInjector(name, value.ClaimSource)
+	case value.SecretSource == nil && value.ClaimSource == nil && value.TemplateSource != nil:
+		return newTemplateInjector(name, value.TemplateSource)
 	default:
 		return nil, fmt.Errorf("header %q value has multiple entries: only one entry per value is allowed", name)
 	}
@@ -94,6 +99,18 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 				header.Add(name, "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
 			}
 		}), nil
+	case source.Join != "":
+		return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+			claimValues := []string{}
+			for _, claim := range session.GetClaim(source.Claim) {
+				if claim != "" {
+					claimValues = append(claimValues, claim)
+				}
+			}
+			if len(claimValues) > 0 {
+				header.Add(name, source.Prefix+strings.Join(claimValues, source.Join))
+			}
+		}), nil
 	case source.Prefix != "":
 		return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
 			claimValues := session.GetClaim(source.Claim)
@@ -116,3 +133,36 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 		}), nil
 	}
 }
+
+// ParseValueTemplate parses a header value template.
+// The `join` function is available to join lists of values, such as groups,
+// with a separator.
+func ParseValueTemplate(text string) (*template.Template, error) {
+	return template.New("header").Funcs(template.FuncMap{
+		"join": func(sep string, values []string) string {
+			return strings.Join(values, sep)
+		},
+	}).Option("missingkey=zero").Parse(text)
+}
+
+func newTemplateInjector(name string, source *options.TemplateSource) (valueInjector, error) {
+	tmpl, err := ParseValueTemplate(source.Template)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+
+	return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+		if session == nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, session); err != nil {
+			// Execution errors are the result of the session contents so the
+			// header is omitted rather than sending a partial value
+			return
+		}
+		if value := buf.String(); value != "" {
+			header.Add(name, value)
+		}
+	}), nil
+}
@@ -112,7 +112,7 @@ var _ = Describe("Injector Suite", func() {
 					"foo": []string{"bar", "baz"},
 				},
 				session: &sessionsapi.SessionState{
-					IDToken: "IDToken-1234",
+					IDToken: []byte("IDToken-1234"),
 				},
 				expectedHeaders: http.Header{
 					"foo":   []string{"bar", "baz"},
@@ -160,7 +160,7 @@ var _ = Describe("Injector Suite", func() {
 					"foo": []string{"bar", "baz"},
 				},
 				session: &sessionsapi.SessionState{
-					IDToken: "IDToken-1234",
+					IDToken: []byte("IDToken-1234"),
 				},
 				expectedHeaders: http.Header{
 					"foo":   []string{"bar", "baz"},
@@ -288,7 +288,7 @@ var _ = Describe("Injector Suite", func() {
 					"foo": []string{"bar", "baz"},
 				},
 				session: &sessionsapi.SessionState{
-					IDToken: "IDToken-1234",
+					IDToken: []byte("IDToken-1234"),
 				},
 				expectedHeaders: nil,
 				expectedErr:     errors.New("error building injector for header \"Claim\": header \"Claim\" value has multiple entries: only one entry per value is allowed"),
@@ -340,6 +340,124 @@ var _ = Describe("Injector Suite", func() {
 				expectedHeaders: nil,
 				expectedErr:     errors.New("error building injector for header \"X-Auth-Request-Authorization\": error loading basicAuthPassword: secret source is invalid: exactly one entry required, specify either value, fromEnv or fromFile"),
 			}),
+			Entry("with a joined claim valued header", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "X-Auth-Request-Groups",
+						Values: []options.HeaderValue{
+							{
+								ClaimSource: &options.ClaimSource{
+									Claim:  "groups",
+									Prefix: "groups=",
+									Join:   ";",
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session: &sessionsapi.SessionState{
+					Groups: []string{"admins", "", "users"},
+				},
+				expectedHeaders: http.Header{
+					"foo":                   []string{"bar", "baz"},
+					"X-Auth-Request-Groups": []string{"groups=admins;users"},
+				},
+				expectedErr: nil,
+			}),
+			Entry("with a joined claim valued header without claim values", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "X-Auth-Request-Groups",
+						Values: []options.HeaderValue{
+							{
+								ClaimSource: &options.ClaimSource{
+									Claim: "groups",
+									Join:  ",",
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session: &sessionsapi.SessionState{},
+				expectedHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				expectedErr: nil,
+			}),
+			Entry("with a template valued header", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "X-Auth-Request-Identity",
+						Values: []options.HeaderValue{
+							{
+								TemplateSource: &options.TemplateSource{
+									Template: `user={{ .User }}; groups={{ join "," (.GetClaim "groups") }}`,
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session: &sessionsapi.SessionState{
+					User:   "user-123",
+					Groups: []string{"admins", "users"},
+				},
+				expectedHeaders: http.Header{
+					"foo":                     []string{"bar", "baz"},
+					"X-Auth-Request-Identity": []string{"user=user-123; groups=admins,users"},
+				},
+				expectedErr: nil,
+			}),
+			Entry("with a template valued header that renders an empty value", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "X-Auth-Request-Email",
+						Values: []options.HeaderValue{
+							{
+								TemplateSource: &options.TemplateSource{
+									Template: "{{ .Email }}",
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session: &sessionsapi.SessionState{},
+				expectedHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				expectedErr: nil,
+			}),
+			Entry("with an invalid template valued header", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "X-Auth-Request-User",
+						Values: []options.HeaderValue{
+							{
+								TemplateSource: &options.TemplateSource{
+									Template: "{{ .User ",
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session:         &sessionsapi.SessionState{},
+				expectedHeaders: nil,
+				expectedErr:     errors.New("error building injector for header \"X-Auth-Request-User\": error parsing template: template: header:1: unclosed action"),
+			}),
 			Entry("with a mix of configured headers", newInjectorTableInput{
 				headers: []options.Header{
 					{
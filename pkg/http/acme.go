@@ -0,0 +1,29 @@
+package http
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager creates a manager that requests certificates for the
+// configured domains on demand during the TLS handshake and renews them
+// before they expire.
+func newACMEManager(opts *options.ACME, cache autocert.Cache) *autocert.Manager {
+	if cache == nil && opts.CacheDir != "" {
+		cache = autocert.DirCache(opts.CacheDir)
+	}
+
+	manager := &autocert.Manager{
+		Cache:      cache,
+		HostPolicy: autocert.HostWhitelist(opts.Domains...),
+		Email:      opts.Email,
+	}
+	if opts.AcceptTOS {
+		manager.Prompt = autocert.AcceptTOS
+	}
+	if opts.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: opts.DirectoryURL}
+	}
+	return manager
+}
@@ -3,19 +3,31 @@ package http
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/fips"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/systemd"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/sync/errgroup"
 )
 
+// systemdNetwork is the scheme of addresses that use a listener passed by
+// systemd socket activation, eg. fd://oauth2-proxy.socket.
+const systemdNetwork = "fd"
+
 // Server represents an HTTP or HTTPS server.
 type Server interface {
 	// Start blocks and runs the server.
@@ -35,12 +47,33 @@ type Opts struct {
 
 	// TLS is the TLS configuration for the server.
 	TLS *options.TLS
+
+	// EnableHTTP2 allows clients to use HTTP/2.
+	// Secure connections negotiate HTTP/2 using ALPN, insecure connections
+	// accept cleartext HTTP/2 (h2c).
+	EnableHTTP2 bool
+
+	// ACME is the configuration for requesting the certificate for the
+	// HTTPS server from an ACME certificate authority.
+	ACME *options.ACME
+
+	// ACMECache stores the ACME account key and certificates.
+	// When nil, the ACME CacheDir is used.
+	ACMECache autocert.Cache
+
+	// FIPS restricts the TLS server to FIPS 140-3 approved cipher suites
+	// and curves.
+	FIPS bool
 }
 
 // NewServer creates a new Server from the options given.
 func NewServer(opts Opts) (Server, error) {
 	s := &server{
-		handler: opts.Handler,
+		handler:         opts.Handler,
+		insecureHandler: opts.Handler,
+	}
+	if opts.EnableHTTP2 {
+		s.insecureHandler = h2c.NewHandler(opts.Handler, &http2.Server{})
 	}
 	if err := s.setupListener(opts); err != nil {
 		return nil, fmt.Errorf("error setting up listener: %v", err)
@@ -54,10 +87,24 @@ func NewServer(opts Opts) (Server, error) {
 
 // server is an implementation of the Server interface.
 type server struct {
-	handler http.Handler
+	handler         http.Handler
+	insecureHandler http.Handler
 
 	listener    net.Listener
 	tlsListener net.Listener
+
+	// certificates are the certificates of the TLS listener, when they are
+	// not requested with ACME.
+	certificates *certificateStore
+}
+
+// CertificateReloader is implemented by servers that can replace the
+// certificates of their HTTPS listener while they are running.
+type CertificateReloader interface {
+	// ReloadCertificates loads the certificates of the TLS options and serves
+	// them for new connections. If they cannot be loaded, the error is
+	// returned and the previous certificates continue to be served.
+	ReloadCertificates(opts *options.TLS) error
 }
 
 // setupListener sets the server listener if the HTTP server is enabled.
@@ -72,7 +119,7 @@ func (s *server) setupListener(opts Opts) error {
 	networkType := getNetworkScheme(opts.BindAddress)
 	listenAddr := getListenAddress(opts.BindAddress)
 
-	listener, err := net.Listen(networkType, listenAddr)
+	listener, err := listen(networkType, listenAddr)
 	if err != nil {
 		return fmt.Errorf("listen (%s, %s) failed: %v", networkType, listenAddr, err)
 	}
@@ -81,6 +128,16 @@ func (s *server) setupListener(opts Opts) error {
 	return nil
 }
 
+// listen creates the listener for the address.
+// The "fd" network uses the listener passed by systemd socket activation with
+// the address as its name.
+func listen(networkType, listenAddr string) (net.Listener, error) {
+	if networkType == systemdNetwork {
+		return systemd.Listener(listenAddr)
+	}
+	return net.Listen(networkType, listenAddr)
+}
+
 func parseCipherSuites(names []string) ([]uint16, error) {
 	cipherNameMap := make(map[string]uint16)
 
@@ -116,25 +173,68 @@ func (s *server) setupTLSListener(opts Opts) error {
 		MaxVersion: tls.VersionTLS13,
 		NextProtos: []string{"http/1.1"},
 	}
-	if opts.TLS == nil {
-		return errors.New("no TLS config provided")
+	if opts.EnableHTTP2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if opts.ACME != nil {
+		manager := newACMEManager(opts.ACME, opts.ACMECache)
+		config.GetCertificate = manager.GetCertificate
+		config.NextProtos = append(config.NextProtos, acme.ALPNProto)
+		s.insecureHandler = manager.HTTPHandler(s.insecureHandler)
+	} else {
+		s.certificates = &certificateStore{}
+		if err := s.certificates.load(opts.TLS); err != nil {
+			return err
+		}
+		config.GetCertificate = s.certificates.GetCertificate
 	}
-	cert, err := getCertificate(opts.TLS)
+
+	if err := applyTLSVersions(config, opts.TLS); err != nil {
+		return err
+	}
+	if opts.FIPS {
+		fips.ApplyTLSConfig(config)
+	}
+	if err := applyClientAuth(config, opts.TLS); err != nil {
+		return fmt.Errorf("could not configure client certificates: %v", err)
+	}
+
+	networkType := "tcp"
+	if getNetworkScheme(opts.SecureBindAddress) == systemdNetwork {
+		networkType = systemdNetwork
+	}
+	listenAddr := getListenAddress(opts.SecureBindAddress)
+
+	listener, err := listen(networkType, listenAddr)
 	if err != nil {
-		return fmt.Errorf("could not load certificate: %v", err)
+		return fmt.Errorf("listen (%s) failed: %v", listenAddr, err)
+	}
+	if tcpListener, ok := listener.(*net.TCPListener); ok {
+		listener = tcpKeepAliveListener{tcpListener}
+	}
+
+	s.tlsListener = tls.NewListener(listener, config)
+	return nil
+}
+
+// applyTLSVersions restricts the TLS versions and cipher suites of the
+// config to those allowed by the TLS options.
+func applyTLSVersions(config *tls.Config, opts *options.TLS) error {
+	if opts == nil {
+		return nil
 	}
-	config.Certificates = []tls.Certificate{cert}
 
-	if len(opts.TLS.CipherSuites) > 0 {
-		cipherSuites, err := parseCipherSuites(opts.TLS.CipherSuites)
+	if len(opts.CipherSuites) > 0 {
+		cipherSuites, err := parseCipherSuites(opts.CipherSuites)
 		if err != nil {
 			return fmt.Errorf("could not parse cipher suites: %v", err)
 		}
 		config.CipherSuites = cipherSuites
 	}
 
-	if len(opts.TLS.MinVersion) > 0 {
-		switch opts.TLS.MinVersion {
+	if len(opts.MinVersion) > 0 {
+		switch opts.MinVersion {
 		case "TLS1.2":
 			config.MinVersion = tls.VersionTLS12
 		case "TLS1.3":
@@ -143,15 +243,6 @@ func (s *server) setupTLSListener(opts Opts) error {
 			return errors.New("unknown TLS MinVersion config provided")
 		}
 	}
-
-	listenAddr := getListenAddress(opts.SecureBindAddress)
-
-	listener, err := net.Listen("tcp", listenAddr)
-	if err != nil {
-		return fmt.Errorf("listen (%s) failed: %v", listenAddr, err)
-	}
-
-	s.tlsListener = tls.NewListener(tcpKeepAliveListener{listener.(*net.TCPListener)}, config)
 	return nil
 }
 
@@ -163,7 +254,7 @@ func (s *server) Start(ctx context.Context) error {
 
 	if s.listener != nil {
 		g.Go(func() error {
-			if err := s.startServer(groupCtx, s.listener); err != nil {
+			if err := s.startServer(groupCtx, s.listener, s.insecureHandler); err != nil {
 				return fmt.Errorf("error starting insecure server: %v", err)
 			}
 			return nil
@@ -172,7 +263,7 @@ func (s *server) Start(ctx context.Context) error {
 
 	if s.tlsListener != nil {
 		g.Go(func() error {
-			if err := s.startServer(groupCtx, s.tlsListener); err != nil {
+			if err := s.startServer(groupCtx, s.tlsListener, s.handler); err != nil {
 				return fmt.Errorf("error starting secure server: %v", err)
 			}
 			return nil
@@ -185,8 +276,8 @@ func (s *server) Start(ctx context.Context) error {
 // startServer creates and starts a new server with the given listener.
 // When the given context is cancelled the server will be shutdown.
 // If any errors occur, only the first error will be returned.
-func (s *server) startServer(ctx context.Context, listener net.Listener) error {
-	srv := &http.Server{Handler: s.handler}
+func (s *server) startServer(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	srv := &http.Server{Handler: handler}
 	g, groupCtx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
@@ -230,24 +321,121 @@ func getListenAddress(addr string) string {
 	return slice[len(slice)-1]
 }
 
-// getCertificate loads the certificate data from the TLS config.
-func getCertificate(opts *options.TLS) (tls.Certificate, error) {
-	keyData, err := getSecretValue(opts.Key)
+// applyClientAuth configures the verification of client certificates when
+// a client CA is given.
+func applyClientAuth(config *tls.Config, opts *options.TLS) error {
+	if opts == nil || opts.ClientCA == nil {
+		return nil
+	}
+
+	caData, err := getSecretValue(opts.ClientCA)
+	if err != nil {
+		return fmt.Errorf("could not load client CA data: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return errors.New("no certificates found in client CA data")
+	}
+	config.ClientCAs = pool
+
+	switch opts.ClientAuth {
+	case "", options.TLSClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case options.TLSClientAuthRequired:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("unknown TLS ClientAuth config provided %q", opts.ClientAuth)
+	}
+	return nil
+}
+
+// getCertificate loads the certificate data from the key and cert sources.
+// ReloadCertificates replaces the certificates of the TLS listener.
+// Servers without a TLS listener, or that request their certificate with ACME,
+// are not changed.
+func (s *server) ReloadCertificates(opts *options.TLS) error {
+	if s.certificates == nil {
+		return nil
+	}
+	return s.certificates.load(opts)
+}
+
+// certificateStore holds the certificates of the TLS listener so that they
+// can be replaced while the server is running.
+type certificateStore struct {
+	// certificates is a []tls.Certificate, the first of which is the default
+	certificates atomic.Value
+}
+
+// load loads the default and additional certificates of the TLS options.
+func (c *certificateStore) load(opts *options.TLS) error {
+	if opts == nil {
+		return errors.New("no TLS config provided")
+	}
+	cert, err := getCertificate(opts.Key, opts.Cert)
+	if err != nil {
+		return fmt.Errorf("could not load certificate: %v", err)
+	}
+	certificates := []tls.Certificate{cert}
+
+	for i, additional := range opts.AdditionalCertificates {
+		cert, err := getCertificate(additional.Key, additional.Cert)
+		if err != nil {
+			return fmt.Errorf("could not load additional certificate %d: %v", i, err)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	c.certificates.Store(certificates)
+	return nil
+}
+
+// GetCertificate selects the certificate to serve for the connection.
+func (c *certificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return sniCertificateSelector(c.certificates.Load().([]tls.Certificate))(hello)
+}
+
+func getCertificate(key, cert *options.SecretSource) (tls.Certificate, error) {
+	keyData, err := getSecretValue(key)
 	if err != nil {
 		return tls.Certificate{}, fmt.Errorf("could not load key data: %v", err)
 	}
 
-	certData, err := getSecretValue(opts.Cert)
+	certData, err := getSecretValue(cert)
 	if err != nil {
 		return tls.Certificate{}, fmt.Errorf("could not load cert data: %v", err)
 	}
 
-	cert, err := tls.X509KeyPair(certData, keyData)
+	certificate, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not parse certificate data: %v", err)
+	}
+
+	// Parse the leaf once so that it is not parsed on every handshake when
+	// selecting a certificate by server name
+	certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0])
 	if err != nil {
 		return tls.Certificate{}, fmt.Errorf("could not parse certificate data: %v", err)
 	}
 
-	return cert, nil
+	return certificate, nil
+}
+
+// sniCertificateSelector selects the certificate to serve based on the server
+// name requested by the client.
+// The default certificate, the first, is checked last so that additional
+// certificates that match are preferred, and is served when none match.
+func sniCertificateSelector(certificates []tls.Certificate) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName != "" {
+			for i := 1; i < len(certificates); i++ {
+				if hello.SupportsCertificate(&certificates[i]) == nil {
+					return &certificates[i], nil
+				}
+			}
+		}
+		return &certificates[0], nil
+	}
 }
 
 // getSecretValue wraps util.GetSecretValue so that we can return an error if no
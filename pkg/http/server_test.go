@@ -2,10 +2,20 @@ package http
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo"
@@ -95,6 +105,66 @@ var _ = Describe("Server", func() {
 				expectHTTPListener: true,
 				expectTLSListener:  true,
 			}),
+			Entry("with an ipv4 valid https bind address, and valid TLS config with a client CA", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:        &ipv4KeyDataSource,
+						Cert:       &ipv4CertDataSource,
+						ClientCA:   &ipv4CertDataSource,
+						ClientAuth: options.TLSClientAuthRequired,
+					},
+				},
+				expectedErr:        nil,
+				expectHTTPListener: false,
+				expectTLSListener:  true,
+			}),
+			Entry("with an ipv4 valid https bind address, and invalid TLS config with an invalid client CA", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:  &ipv4KeyDataSource,
+						Cert: &ipv4CertDataSource,
+						ClientCA: &options.SecretSource{
+							Value: []byte("invalid"),
+						},
+					},
+				},
+				expectedErr:        errors.New("error setting up TLS listener: could not configure client certificates: no certificates found in client CA data"),
+				expectHTTPListener: false,
+				expectTLSListener:  false,
+			}),
+			Entry("with an ipv4 valid https bind address, and ACME config", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					ACME: &options.ACME{
+						Domains:   []string{"example.com"},
+						AcceptTOS: true,
+					},
+				},
+				expectedErr:        nil,
+				expectHTTPListener: false,
+				expectTLSListener:  true,
+			}),
+			Entry("with an ipv4 valid https bind address, and ACME config with unknown MinVersion", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						MinVersion: "TLS1.1",
+					},
+					ACME: &options.ACME{
+						Domains:   []string{"example.com"},
+						AcceptTOS: true,
+					},
+				},
+				expectedErr:        errors.New("error setting up TLS listener: unknown TLS MinVersion config provided"),
+				expectHTTPListener: false,
+				expectTLSListener:  false,
+			}),
 			Entry("with a \"-\" for the bind address", &newServerTableInput{
 				opts: Opts{
 					Handler:     handler,
@@ -525,6 +595,114 @@ var _ = Describe("Server", func() {
 		)
 	})
 
+	Context("with ACME", func() {
+		It("answers HTTP-01 challenges on the insecure server", func() {
+			srv, err := NewServer(Opts{
+				Handler:           handler,
+				BindAddress:       "127.0.0.1:0",
+				SecureBindAddress: "127.0.0.1:0",
+				ACME: &options.ACME{
+					Domains:   []string{"example.com"},
+					AcceptTOS: true,
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			s := srv.(*server)
+			defer s.listener.Close()
+			defer s.tlsListener.Close()
+
+			rw := httptest.NewRecorder()
+			s.insecureHandler.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/", nil))
+			Expect(rw.Body.String()).To(Equal(hello))
+
+			// Unknown challenge tokens are rejected by the ACME manager
+			rw = httptest.NewRecorder()
+			s.insecureHandler.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/token", nil))
+			Expect(rw.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("with SNI certificates", func() {
+		var s *server
+
+		BeforeEach(func() {
+			wildcardKey, wildcardCert := generateDNSCert("*.example.com")
+			srv, err := NewServer(Opts{
+				Handler:           handler,
+				SecureBindAddress: "127.0.0.1:0",
+				TLS: &options.TLS{
+					Key:  &ipv4KeyDataSource,
+					Cert: &ipv4CertDataSource,
+					AdditionalCertificates: []options.TLSCertificate{
+						{
+							Key:  &options.SecretSource{Value: wildcardKey},
+							Cert: &options.SecretSource{Value: wildcardCert},
+						},
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			s = srv.(*server)
+
+			go func() {
+				for {
+					conn, err := s.tlsListener.Accept()
+					if err != nil {
+						return
+					}
+					_ = conn.(*tls.Conn).Handshake()
+					conn.Close()
+				}
+			}()
+		})
+
+		AfterEach(func() {
+			Expect(s.tlsListener.Close()).To(Succeed())
+		})
+
+		DescribeTable("serves the certificate matching the server name",
+			func(serverName string, expectedDNSNames []string) {
+				/* #nosec G402 */
+				conn, err := tls.Dial("tcp", s.tlsListener.Addr().String(), &tls.Config{
+					ServerName:         serverName,
+					InsecureSkipVerify: true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				Expect(conn.ConnectionState().PeerCertificates[0].DNSNames).To(Equal(expectedDNSNames))
+			},
+			Entry("with a wildcard match", "app.example.com", []string{"*.example.com"}),
+			Entry("with no match", "example.org", []string(nil)),
+			Entry("with no server name", "", []string(nil)),
+		)
+
+		It("serves reloaded certificates for new connections", func() {
+			rotatedKey, rotatedCert := generateDNSCert("rotated.example.com")
+			Expect(s.ReloadCertificates(&options.TLS{
+				Key:  &options.SecretSource{Value: rotatedKey},
+				Cert: &options.SecretSource{Value: rotatedCert},
+			})).To(Succeed())
+
+			/* #nosec G402 */
+			conn, err := tls.Dial("tcp", s.tlsListener.Addr().String(), &tls.Config{
+				ServerName:         "app.example.com",
+				InsecureSkipVerify: true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			Expect(conn.ConnectionState().PeerCertificates[0].DNSNames).To(Equal([]string{"rotated.example.com"}))
+
+			By("Keeping the certificates when the new certificates are invalid")
+			err = s.ReloadCertificates(&options.TLS{
+				Key:  &options.SecretSource{Value: []byte("invalid")},
+				Cert: &options.SecretSource{Value: rotatedCert},
+			})
+			Expect(err).To(MatchError(ContainSubstring("could not load certificate")))
+			Expect(s.certificates.certificates.Load().([]tls.Certificate)).To(HaveLen(1))
+		})
+	})
+
 	Context("Start", func() {
 		var srv Server
 		var ctx context.Context
@@ -965,3 +1143,27 @@ var _ = Describe("Server", func() {
 		)
 	})
 })
+
+// generateDNSCert generates a PEM encoded self-signed certificate and key for
+// the DNS name.
+func generateDNSCert(name string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"OAuth2 Proxy Test Suite"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{name},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+}
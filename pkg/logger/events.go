@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"io"
+	"time"
+)
+
+// AuthEvent is an authentication or authorization event, as written to the
+// auth log.
+type AuthEvent struct {
+	Timestamp     time.Time
+	Client        string
+	Host          string
+	Protocol      string
+	Provider      string
+	RequestID     string
+	RequestMethod string
+	RequestURI    string
+	UserAgent     string
+	Username      string
+	Status        AuthStatus
+	ErrorCode     string
+	Message       string
+
+	// The details of security events, which are empty for other auth events
+	Event  string
+	Reason string
+	ACR    string
+	AMR    []string
+}
+
+// The security events emitted when users sign in and out, for account security
+// monitoring.
+const (
+	// EventLoginSuccess is a user signing in
+	EventLoginSuccess = "login_success"
+	// EventLoginDenied is a user that authenticated with the provider being
+	// denied a session
+	EventLoginDenied = "login_denied"
+	// EventLogout is a user signing out
+	EventLogout = "logout"
+	// EventForcedLogout is the session of a user that is no longer authorized
+	// being removed
+	EventForcedLogout = "forced_logout"
+	// EventSessionRevoked is a session that expired or that the provider no
+	// longer considers valid being removed
+	EventSessionRevoked = "session_revoked"
+)
+
+// The reasons of security events that deny or remove sessions.
+const (
+	// ReasonEmailDomain is an email address outside the allowed domains
+	ReasonEmailDomain = "email_domain"
+	// ReasonEmailList is an email address missing from the authenticated
+	// emails file
+	ReasonEmailList = "email_list"
+	// ReasonEmail is an email address neither in the allowed domains nor in
+	// the authenticated emails file, when both are configured
+	ReasonEmail = "email"
+	// ReasonGroup is a user in none of the allowed groups
+	ReasonGroup = "group"
+	// ReasonCSRF is a sign in whose state does not match its CSRF cookie
+	ReasonCSRF = "csrf"
+	// ReasonSessionExpired is a session that has expired
+	ReasonSessionExpired = "session_expired"
+	// ReasonSessionInvalid is a session that the provider does not consider
+	// valid
+	ReasonSessionInvalid = "session_invalid"
+)
+
+// SecurityEvent details an auth event that is a security event.
+type SecurityEvent struct {
+	// Event is one of the Event constants
+	Event string
+	// Reason is one of the Reason constants, for events that deny or remove
+	// sessions
+	Reason string
+	// ACR and AMR are the authentication context class reference and the
+	// authentication methods references reported by the IdP
+	ACR string
+	AMR []string
+}
+
+// AuthSink receives every AuthEvent, whether or not auth logging is enabled,
+// for example to forward it to a SIEM.
+// WriteAuthEvent must not block on the delivery of the event.
+type AuthSink interface {
+	WriteAuthEvent(event AuthEvent)
+}
+
+// SetAuthSinks sets the sinks that receive auth events, closing any sinks
+// that are replaced.
+func (l *Logger) SetAuthSinks(sinks ...AuthSink) {
+	l.mu.Lock()
+	previous := l.authSinks
+	l.authSinks = sinks
+	l.mu.Unlock()
+
+	for _, s := range previous {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				l.Output(ERROR, 2, "Error closing auth sink: "+err.Error())
+			}
+		}
+	}
+}
+
+// SetAuthSinks sets the sinks that receive auth events for the standard
+// logger.
+func SetAuthSinks(sinks ...AuthSink) {
+	std.SetAuthSinks(sinks...)
+}
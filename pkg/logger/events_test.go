@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	events []AuthEvent
+}
+
+func (r *recordingSink) WriteAuthEvent(event AuthEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestPrintSecurityEventf(t *testing.T) {
+	security := SecurityEvent{
+		Event:  EventLoginDenied,
+		Reason: ReasonGroup,
+		ACR:    "urn:example:loa:2",
+		AMR:    []string{"pwd", "otp"},
+	}
+
+	t.Run("as JSON", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := New(0)
+		l.writer = buf
+		l.SetFormat(JSONFormat)
+		l.SetFields([]string{FieldType, FieldUser, FieldStatus, FieldEvent, FieldReason, FieldACR, FieldAMR, FieldMessage}, nil)
+
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/oauth2/callback", nil), &middlewareapi.RequestScope{})
+		l.PrintSecurityEventf("user@example.com", req, AuthFailure, security, "unauthorized")
+
+		assert.Equal(t, `{"type":"security","user":"user@example.com","status":"AuthFailure","event":"login_denied","reason":"group","acr":"urn:example:loa:2","amr":["pwd","otp"],"message":"unauthorized"}`+"\n", buf.String())
+	})
+
+	t.Run("as JSON without the details of security events", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := New(0)
+		l.writer = buf
+		l.SetFormat(JSONFormat)
+		l.SetFields([]string{FieldType, FieldStatus, FieldEvent, FieldAMR, FieldMessage}, nil)
+
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil), &middlewareapi.RequestScope{})
+		l.PrintAuthf("user@example.com", req, AuthSuccess, "authenticated")
+
+		assert.Equal(t, `{"type":"auth","status":"AuthSuccess","message":"authenticated"}`+"\n", buf.String())
+	})
+
+	t.Run("as JSON with the error code of the request", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := New(0)
+		l.writer = buf
+		l.SetFormat(JSONFormat)
+		l.SetFields([]string{FieldType, FieldEvent, FieldReason, FieldErrorCode}, nil)
+
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/oauth2/callback", nil), &middlewareapi.RequestScope{ErrorCode: "group_denied"})
+		l.PrintSecurityEventf("user@example.com", req, AuthFailure, security, "unauthorized")
+
+		assert.Equal(t, `{"type":"security","event":"login_denied","reason":"group","error_code":"group_denied"}`+"\n", buf.String())
+	})
+
+	t.Run("with a template", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := New(0)
+		l.writer = buf
+		l.SetAuthTemplate("[{{.Status}}] {{.Event}} {{.Reason}} acr={{.ACR}} amr={{.AMR}} {{.Message}}")
+
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil), &middlewareapi.RequestScope{})
+		l.PrintSecurityEventf("user@example.com", req, AuthFailure, security, "unauthorized")
+
+		assert.Equal(t, "[AuthFailure] login_denied group acr=urn:example:loa:2 amr=pwd otp unauthorized\n", buf.String())
+	})
+
+	t.Run("to sinks", func(t *testing.T) {
+		sink := &recordingSink{}
+		l := New(0)
+		l.SetAuthEnabled(false)
+		l.SetAuthSinks(sink)
+
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil), &middlewareapi.RequestScope{})
+		l.PrintSecurityEventf("user@example.com", req, AuthFailure, security, "unauthorized")
+
+		assert.Len(t, sink.events, 1)
+		assert.Equal(t, EventLoginDenied, sink.events[0].Event)
+		assert.Equal(t, ReasonGroup, sink.events[0].Reason)
+		assert.Equal(t, security.ACR, sink.events[0].ACR)
+		assert.Equal(t, security.AMR, sink.events[0].AMR)
+	})
+}
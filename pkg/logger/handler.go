@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// levelSettings is the JSON representation of the level and debug components
+// of a logger.
+type levelSettings struct {
+	Level           *string  `json:"level,omitempty"`
+	DebugComponents []string `json:"debug_components"`
+}
+
+// LevelHandler serves the level and debug components of the standard logger
+// as JSON on GET requests, and changes them on PUT requests with a JSON body
+// of the settings to change, such as `{"debug_components":["sessions"]}`.
+func LevelHandler() http.Handler {
+	return std.levelHandler()
+}
+
+func (l *Logger) levelHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if err := l.updateLevel(rw, req); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			rw.Header().Set("Allow", "GET, PUT")
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		level := l.Level().String()
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(levelSettings{
+			Level:           &level,
+			DebugComponents: l.DebugComponents(),
+		}); err != nil {
+			l.Output(ERROR, 1, "Error encoding logging level: "+err.Error())
+		}
+	})
+}
+
+// updateLevel changes the level and debug components given in the body of
+// the request, leaving those that are not given as they are.
+func (l *Logger) updateLevel(rw http.ResponseWriter, req *http.Request) error {
+	var settings levelSettings
+	if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, 1<<16)).Decode(&settings); err != nil {
+		return err
+	}
+
+	var lvl Level
+	if settings.Level != nil {
+		var err error
+		lvl, err = ParseLevel(*settings.Level)
+		if err != nil {
+			return err
+		}
+	}
+	if err := ValidateComponents(settings.DebugComponents); err != nil {
+		return err
+	}
+
+	if settings.Level != nil {
+		l.SetLevel(lvl)
+	}
+	if settings.DebugComponents != nil {
+		l.SetDebugComponents(settings.DebugComponents)
+	}
+	l.Output(DEFAULT, 1, "Logging level set to "+l.Level().String()+" with debug logging for components: "+formatComponents(l.DebugComponents()))
+	return nil
+}
+
+func formatComponents(components []string) string {
+	if len(components) == 0 {
+		return "none"
+	}
+	b, _ := json.Marshal(components)
+	return string(b)
+}
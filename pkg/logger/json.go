@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// TextFormat writes each log line with the template of its type of logging
+	TextFormat = "text"
+	// JSONFormat writes each log line as a JSON object with a consistent set
+	// of fields
+	JSONFormat = "json"
+)
+
+// The fields of JSON log lines. Fields without a value for a log line are
+// omitted from it.
+const (
+	FieldType          = "type"
+	FieldTimestamp     = "timestamp"
+	FieldLevel         = "level"
+	FieldFile          = "file"
+	FieldMessage       = "message"
+	FieldRequestID     = "request_id"
+	FieldClient        = "client"
+	FieldHost          = "host"
+	FieldProtocol      = "protocol"
+	FieldRequestMethod = "request_method"
+	FieldRequestURI    = "request_uri"
+	FieldUserAgent     = "user_agent"
+	FieldUser          = "user"
+	FieldProvider      = "provider"
+	FieldUpstream      = "upstream"
+	FieldStatus        = "status"
+	FieldEvent         = "event"
+	FieldReason        = "reason"
+	FieldACR           = "acr"
+	FieldAMR           = "amr"
+	FieldErrorCode     = "error_code"
+	FieldStatusCode    = "status_code"
+	FieldResponseSize  = "response_size"
+	FieldLatency       = "latency"
+)
+
+// Fields lists every field of JSON log lines.
+var Fields = []string{
+	FieldType,
+	FieldTimestamp,
+	FieldLevel,
+	FieldFile,
+	FieldMessage,
+	FieldRequestID,
+	FieldClient,
+	FieldHost,
+	FieldProtocol,
+	FieldRequestMethod,
+	FieldRequestURI,
+	FieldUserAgent,
+	FieldUser,
+	FieldProvider,
+	FieldUpstream,
+	FieldStatus,
+	FieldEvent,
+	FieldReason,
+	FieldACR,
+	FieldAMR,
+	FieldErrorCode,
+	FieldStatusCode,
+	FieldResponseSize,
+	FieldLatency,
+}
+
+// jsonField is a field of a JSON log line.
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// fieldSet determines which fields are written to JSON log lines.
+type fieldSet struct {
+	include map[string]struct{}
+	exclude map[string]struct{}
+}
+
+// newFieldSet creates a fieldSet writing only the included fields, or every
+// field when none are included, except for the excluded fields.
+func newFieldSet(include, exclude []string) fieldSet {
+	fs := fieldSet{}
+	if len(include) > 0 {
+		fs.include = make(map[string]struct{})
+		for _, f := range include {
+			fs.include[f] = struct{}{}
+		}
+	}
+	if len(exclude) > 0 {
+		fs.exclude = make(map[string]struct{})
+		for _, f := range exclude {
+			fs.exclude[f] = struct{}{}
+		}
+	}
+	return fs
+}
+
+func (fs fieldSet) contains(name string) bool {
+	if fs.include != nil {
+		if _, ok := fs.include[name]; !ok {
+			return false
+		}
+	}
+	_, excluded := fs.exclude[name]
+	return !excluded
+}
+
+// formatJSON encodes the fields of a log line, in order, as a JSON object
+// followed by a newline.
+func (l *Logger) formatJSON(fields []jsonField) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	first := true
+	for _, f := range fields {
+		if f.value == "" || !l.fields.contains(f.name) {
+			continue
+		}
+		// Values are redacted before they are escaped, so that the patterns
+		// match them as they are
+		switch v := f.value.(type) {
+		case string:
+			f.value = l.redactor.redactString(v)
+		case []string:
+			if len(v) == 0 {
+				continue
+			}
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			panic(err)
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		fmt.Fprintf(buf, "%q:", f.name)
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// formatJSONTimestamp returns the timestamp of a JSON log line.
+func (l *Logger) formatJSONTimestamp(ts time.Time) string {
+	if l.flag&LUTC != 0 {
+		ts = ts.UTC()
+	}
+	return ts.Format(time.RFC3339Nano)
+}
+
+// ValidateFields returns an error naming the first field that JSON log lines
+// do not have.
+func ValidateFields(fields []string) error {
+	for _, f := range fields {
+		valid := false
+		for _, known := range Fields {
+			if f == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown logging field %q, must be one of: %s", f, strings.Join(Fields, ", "))
+		}
+	}
+	return nil
+}
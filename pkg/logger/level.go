@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The components whose debug logging can be enabled on its own.
+const (
+	ComponentSessions  = "sessions"
+	ComponentProviders = "providers"
+	ComponentUpstream  = "upstream"
+)
+
+// Components lists every component whose debug logging can be enabled.
+var Components = []string{
+	ComponentSessions,
+	ComponentProviders,
+	ComponentUpstream,
+}
+
+// String returns the name of the level: `debug`, `info` or `error`.
+func (lvl Level) String() string {
+	switch lvl {
+	case DEBUG:
+		return "debug"
+	case ERROR:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// severity orders the levels from debug to error.
+func (lvl Level) severity() int {
+	switch lvl {
+	case DEBUG:
+		return 0
+	case ERROR:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ParseLevel returns the level with the name, or the info level when the
+// name is empty.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return DEBUG, nil
+	case "", "info":
+		return DEFAULT, nil
+	case "error":
+		return ERROR, nil
+	default:
+		return DEFAULT, fmt.Errorf("unknown logging level %q, must be one of: debug, info, error", name)
+	}
+}
+
+// ValidateComponents returns an error naming the first component that is not
+// one of the Components.
+func ValidateComponents(components []string) error {
+	for _, c := range components {
+		valid := false
+		for _, known := range Components {
+			if c == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown logging component %q, must be one of: %s", c, strings.Join(Components, ", "))
+		}
+	}
+	return nil
+}
+
+// levelState is the level and debug components of a logger, to be restored
+// when debug logging is toggled off.
+type levelState struct {
+	level           Level
+	debugComponents map[string]struct{}
+}
+
+// enabled determines whether messages of the level, from the component if
+// they are debug messages, are written.
+func (l *Logger) enabled(lvl Level, component string) bool {
+	if lvl == DEBUG && l.level != DEBUG {
+		_, ok := l.debugComponents[component]
+		return ok && component != ""
+	}
+	return lvl.severity() >= l.level.severity()
+}
+
+// SetLevel sets the lowest level of standard log lines that are written.
+func (l *Logger) SetLevel(lvl Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = lvl
+	l.debugToggle = nil
+}
+
+// Level returns the lowest level of standard log lines that are written.
+func (l *Logger) Level() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// SetDebugComponents enables debug logging for the components only, whatever
+// the level of the logger.
+func (l *Logger) SetDebugComponents(components []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugComponents = make(map[string]struct{}, len(components))
+	for _, c := range components {
+		l.debugComponents[c] = struct{}{}
+	}
+	l.debugToggle = nil
+}
+
+// DebugComponents returns the sorted components with debug logging enabled.
+func (l *Logger) DebugComponents() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	components := make([]string, 0, len(l.debugComponents))
+	for c := range l.debugComponents {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// ToggleDebug enables debug logging for every component or, when it was
+// enabled by ToggleDebug, restores the level and debug components that were
+// set before. It returns whether debug logging is now enabled.
+func (l *Logger) ToggleDebug() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.debugToggle != nil {
+		l.level = l.debugToggle.level
+		l.debugComponents = l.debugToggle.debugComponents
+		l.debugToggle = nil
+		return false
+	}
+	l.debugToggle = &levelState{level: l.level, debugComponents: l.debugComponents}
+	l.level = DEBUG
+	return true
+}
+
+// Debugf writes a debug message of the component when debug logging is
+// enabled for it. Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Debugf(component string, format string, v ...interface{}) {
+	l.output(DEBUG, component, 2, fmt.Sprintf(format, v...))
+}
+
+// SetLevel sets the lowest level of standard log lines that are written by
+// the standard logger.
+func SetLevel(lvl Level) {
+	std.SetLevel(lvl)
+}
+
+// GetLevel returns the lowest level of standard log lines that are written
+// by the standard logger.
+func GetLevel() Level {
+	return std.Level()
+}
+
+// SetDebugComponents enables debug logging for the components only for the
+// standard logger.
+func SetDebugComponents(components []string) {
+	std.SetDebugComponents(components)
+}
+
+// DebugComponents returns the components with debug logging enabled for the
+// standard logger.
+func DebugComponents() []string {
+	return std.DebugComponents()
+}
+
+// ToggleDebug toggles debug logging for every component of the standard
+// logger.
+func ToggleDebug() bool {
+	return std.ToggleDebug()
+}
+
+// Debugf writes a debug message of the component to the standard logger when
+// debug logging is enabled for it.
+// Arguments are handled in the manner of fmt.Printf.
+func Debugf(component string, format string, v ...interface{}) {
+	std.output(DEBUG, component, 2, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLevelTestLogger() (*Logger, *bytes.Buffer) {
+	buf := new(bytes.Buffer)
+	l := New(0)
+	l.writer = buf
+	l.errWriter = buf
+	l.SetStandardTemplate("{{.Message}}")
+	return l, buf
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]Level{"debug": DEBUG, "INFO": DEFAULT, "error": ERROR} {
+		lvl, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, lvl)
+	}
+
+	_, err := ParseLevel("trace")
+	assert.EqualError(t, err, `unknown logging level "trace", must be one of: debug, info, error`)
+}
+
+func TestValidateComponents(t *testing.T) {
+	assert.NoError(t, ValidateComponents([]string{ComponentSessions, ComponentUpstream}))
+	assert.EqualError(t, ValidateComponents([]string{ComponentSessions, "cookies"}),
+		`unknown logging component "cookies", must be one of: sessions, providers, upstream`)
+}
+
+func TestLevels(t *testing.T) {
+	t.Run("at the info level", func(t *testing.T) {
+		l, buf := newLevelTestLogger()
+		l.Debugf(ComponentSessions, "debug")
+		l.Output(DEFAULT, 1, "info")
+		l.Output(ERROR, 1, "error")
+		assert.Equal(t, "info\nerror\n", buf.String())
+	})
+
+	t.Run("at the error level", func(t *testing.T) {
+		l, buf := newLevelTestLogger()
+		l.SetLevel(ERROR)
+		l.Output(DEFAULT, 1, "info")
+		l.Output(ERROR, 1, "error")
+		assert.Equal(t, "error\n", buf.String())
+	})
+
+	t.Run("at the debug level", func(t *testing.T) {
+		l, buf := newLevelTestLogger()
+		l.SetLevel(DEBUG)
+		l.Debugf(ComponentSessions, "loaded session %d", 1)
+		l.Debugf(ComponentUpstream, "served")
+		assert.Equal(t, "[sessions] loaded session 1\n[upstream] served\n", buf.String())
+	})
+
+	t.Run("with debug components", func(t *testing.T) {
+		l, buf := newLevelTestLogger()
+		l.SetDebugComponents([]string{ComponentUpstream, ComponentProviders})
+		l.Debugf(ComponentSessions, "loaded session")
+		l.Debugf(ComponentUpstream, "served")
+		assert.Equal(t, "[upstream] served\n", buf.String())
+		assert.Equal(t, []string{ComponentProviders, ComponentUpstream}, l.DebugComponents())
+	})
+}
+
+func TestToggleDebug(t *testing.T) {
+	l, buf := newLevelTestLogger()
+	l.SetLevel(ERROR)
+	l.SetDebugComponents([]string{ComponentSessions})
+
+	assert.True(t, l.ToggleDebug())
+	assert.Equal(t, DEBUG, l.Level())
+	l.Debugf(ComponentProviders, "redeemed")
+
+	assert.False(t, l.ToggleDebug())
+	assert.Equal(t, ERROR, l.Level())
+	assert.Equal(t, []string{ComponentSessions}, l.DebugComponents())
+	l.Debugf(ComponentProviders, "refreshed")
+	l.Debugf(ComponentSessions, "loaded session")
+
+	assert.Equal(t, "[providers] redeemed\n[sessions] loaded session\n", buf.String())
+}
+
+func TestLevelHandler(t *testing.T) {
+	testCases := map[string]struct {
+		method         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedLevel  Level
+		expectedComps  []string
+	}{
+		"getting the level": {
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"level":"info","debug_components":["sessions"]}` + "\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{ComponentSessions},
+		},
+		"setting the level": {
+			method:         http.MethodPut,
+			body:           `{"level":"debug"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"level":"debug","debug_components":["sessions"]}` + "\n",
+			expectedLevel:  DEBUG,
+			expectedComps:  []string{ComponentSessions},
+		},
+		"setting the debug components": {
+			method:         http.MethodPut,
+			body:           `{"debug_components":["upstream","providers"]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"level":"info","debug_components":["providers","upstream"]}` + "\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{ComponentProviders, ComponentUpstream},
+		},
+		"clearing the debug components": {
+			method:         http.MethodPut,
+			body:           `{"debug_components":[]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"level":"info","debug_components":[]}` + "\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{},
+		},
+		"with an unknown level": {
+			method:         http.MethodPut,
+			body:           `{"level":"trace","debug_components":["upstream"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `unknown logging level "trace", must be one of: debug, info, error` + "\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{ComponentSessions},
+		},
+		"with an unknown component": {
+			method:         http.MethodPut,
+			body:           `{"level":"debug","debug_components":["cookies"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `unknown logging component "cookies", must be one of: sessions, providers, upstream` + "\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{ComponentSessions},
+		},
+		"with another method": {
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "Method Not Allowed\n",
+			expectedLevel:  DEFAULT,
+			expectedComps:  []string{ComponentSessions},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			l, _ := newLevelTestLogger()
+			l.SetDebugComponents([]string{ComponentSessions})
+
+			rw := httptest.NewRecorder()
+			l.levelHandler().ServeHTTP(rw, httptest.NewRequest(tc.method, "/log-level", strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Equal(t, tc.expectedBody, rw.Body.String())
+			assert.Equal(t, tc.expectedLevel, l.Level())
+			assert.Equal(t, tc.expectedComps, l.DebugComponents())
+		})
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"runtime"
+	"strings"
 	"sync"
 	"text/template"
 	"time"
@@ -50,6 +53,8 @@ const (
 	DEFAULT Level = iota
 	// ERROR is for error-level logging
 	ERROR
+	// DEBUG is for debug-level logging, which is only written when enabled
+	DEBUG
 )
 
 // These are the containers for all values that are available as variables in the logging formats.
@@ -64,19 +69,26 @@ type authLogMessageData struct {
 	Client,
 	Host,
 	Protocol,
+	Provider,
 	RequestID,
 	RequestMethod,
 	Timestamp,
 	UserAgent,
 	Username,
 	Status,
-	Message string
+	Message,
+	Event,
+	Reason,
+	ACR,
+	AMR,
+	ErrorCode string
 }
 
 type reqLogMessageData struct {
 	Client,
 	Host,
 	Protocol,
+	Provider,
 	RequestID,
 	RequestDuration,
 	RequestMethod,
@@ -86,7 +98,8 @@ type reqLogMessageData struct {
 	Timestamp,
 	Upstream,
 	UserAgent,
-	Username string
+	Username,
+	ErrorCode string
 }
 
 // Returns the apparent "real client IP" as a string.
@@ -107,9 +120,20 @@ type Logger struct {
 	reqEnabled     bool
 	getClientFunc  GetClientFunc
 	excludePaths   map[string]struct{}
+	format         string
+	fields         fieldSet
+	authSinks      []AuthSink
+	redactor       *redactor
 	stdLogTemplate *template.Template
 	authTemplate   *template.Template
 	reqTemplate    *template.Template
+
+	excludePathRegexes []*regexp.Regexp
+	reqSampleRates     *SampleRates
+	random             func() float64
+	level              Level
+	debugComponents    map[string]struct{}
+	debugToggle        *levelState
 }
 
 // New creates a new Standarderr Logger.
@@ -123,15 +147,22 @@ func New(flag int) *Logger {
 		reqEnabled:     true,
 		getClientFunc:  func(r *http.Request) string { return r.RemoteAddr },
 		excludePaths:   nil,
+		format:         TextFormat,
+		redactor:       newRedactor(true, nil),
 		stdLogTemplate: template.Must(template.New("std-log").Parse(DefaultStandardLoggingFormat)),
 		authTemplate:   template.Must(template.New("auth-log").Parse(DefaultAuthLoggingFormat)),
 		reqTemplate:    template.Must(template.New("req-log").Parse(DefaultRequestLoggingFormat)),
+
+		// Sampling does not require a secure random source
+		/* #nosec G404 */
+		random: rand.Float64,
+		level:  DEFAULT,
 	}
 }
 
 var std = New(LstdFlags)
 
-func (l *Logger) formatLogMessage(calldepth int, message string) []byte {
+func (l *Logger) formatLogMessage(lvl Level, calldepth int, message string) []byte {
 	now := time.Now()
 	file := "???:0"
 
@@ -139,6 +170,16 @@ func (l *Logger) formatLogMessage(calldepth int, message string) []byte {
 		file = l.GetFileLineString(calldepth + 1)
 	}
 
+	if l.format == JSONFormat {
+		return l.formatJSON([]jsonField{
+			{FieldType, "standard"},
+			{FieldTimestamp, l.formatJSONTimestamp(now)},
+			{FieldLevel, lvl.String()},
+			{FieldFile, file},
+			{FieldMessage, strings.TrimSuffix(message, "\n")},
+		})
+	}
+
 	var logBuff = new(bytes.Buffer)
 	err := l.stdLogTemplate.Execute(logBuff, stdLogMessageData{
 		Timestamp: FormatTimestamp(now),
@@ -154,18 +195,28 @@ func (l *Logger) formatLogMessage(calldepth int, message string) []byte {
 		panic(err)
 	}
 
-	return logBuff.Bytes()
+	return l.redactor.redact(logBuff.Bytes())
 }
 
 // Output a standard log template with a simple message to default output channel.
 // Write a final newline at the end of every message.
 func (l *Logger) Output(lvl Level, calldepth int, message string) {
+	l.output(lvl, "", calldepth+1, message)
+}
+
+// output writes a standard log line of the level, unless the level is
+// disabled. Debug messages of a component are prefixed with its name and are
+// written when debug logging is enabled for the component.
+func (l *Logger) output(lvl Level, component string, calldepth int, message string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if !l.stdEnabled {
+	if !l.stdEnabled || !l.enabled(lvl, component) {
 		return
 	}
-	msg := l.formatLogMessage(calldepth+1, message)
+	if component != "" {
+		message = "[" + component + "] " + message
+	}
+	msg := l.formatLogMessage(lvl, calldepth+1, message)
 
 	var err error
 	switch lvl {
@@ -183,39 +234,117 @@ func (l *Logger) Output(lvl Level, calldepth int, message string) {
 // log request details. Remaining arguments are handled in the manner of
 // fmt.Sprintf. Writes a final newline to the end of every message.
 func (l *Logger) PrintAuthf(username string, req *http.Request, status AuthStatus, format string, a ...interface{}) {
-	if !l.authEnabled {
-		return
-	}
+	l.printAuthEvent(username, req, status, SecurityEvent{}, format, a...)
+}
 
-	now := time.Now()
+// PrintSecurityEventf writes a security event, such as a user signing in or
+// out, to the auth log and auth sinks.
+func (l *Logger) PrintSecurityEventf(username string, req *http.Request, status AuthStatus, security SecurityEvent, format string, a ...interface{}) {
+	l.printAuthEvent(username, req, status, security, format, a...)
+}
 
-	if username == "" {
-		username = "-"
+func (l *Logger) printAuthEvent(username string, req *http.Request, status AuthStatus, security SecurityEvent, format string, a ...interface{}) {
+	if !l.authEnabled && len(l.authSinks) == 0 {
+		return
 	}
 
-	client := l.getClientFunc(req)
-
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	scope := middlewareapi.GetRequestScope(req)
-	err := l.authTemplate.Execute(l.writer, authLogMessageData{
-		Client:        client,
+	event := AuthEvent{
+		Timestamp:     time.Now(),
+		Client:        l.getClientFunc(req),
 		Host:          requestutil.GetRequestHost(req),
 		Protocol:      req.Proto,
+		Provider:      scope.Provider,
 		RequestID:     scope.RequestID,
 		RequestMethod: req.Method,
-		Timestamp:     FormatTimestamp(now),
-		UserAgent:     fmt.Sprintf("%q", req.UserAgent()),
+		RequestURI:    req.URL.RequestURI(),
+		UserAgent:     req.UserAgent(),
 		Username:      username,
-		Status:        string(status),
+		Status:        status,
+		ErrorCode:     scope.ErrorCode,
 		Message:       fmt.Sprintf(format, a...),
+		Event:         security.Event,
+		Reason:        security.Reason,
+		ACR:           security.ACR,
+		AMR:           security.AMR,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.authSinks) > 0 {
+		redacted := event
+		redacted.RequestURI = l.redactor.redactString(event.RequestURI)
+		redacted.UserAgent = l.redactor.redactString(event.UserAgent)
+		redacted.Username = l.redactor.redactString(event.Username)
+		redacted.Message = l.redactor.redactString(event.Message)
+		for _, sink := range l.authSinks {
+			sink.WriteAuthEvent(redacted)
+		}
+	}
+	if !l.authEnabled {
+		return
+	}
+
+	if l.format == JSONFormat {
+		logType := "auth"
+		if event.Event != "" {
+			logType = "security"
+		}
+		_, err := l.writer.Write(l.formatJSON([]jsonField{
+			{FieldType, logType},
+			{FieldTimestamp, l.formatJSONTimestamp(event.Timestamp)},
+			{FieldRequestID, event.RequestID},
+			{FieldClient, event.Client},
+			{FieldHost, event.Host},
+			{FieldProtocol, event.Protocol},
+			{FieldRequestMethod, event.RequestMethod},
+			{FieldUserAgent, event.UserAgent},
+			{FieldUser, event.Username},
+			{FieldProvider, event.Provider},
+			{FieldStatus, string(event.Status)},
+			{FieldEvent, event.Event},
+			{FieldReason, event.Reason},
+			{FieldACR, event.ACR},
+			{FieldAMR, event.AMR},
+			{FieldErrorCode, event.ErrorCode},
+			{FieldMessage, event.Message},
+		}))
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if username == "" {
+		username = "-"
+	}
+
+	logBuff := new(bytes.Buffer)
+	err := l.authTemplate.Execute(logBuff, authLogMessageData{
+		Client:        event.Client,
+		Host:          event.Host,
+		Protocol:      event.Protocol,
+		Provider:      event.Provider,
+		RequestID:     event.RequestID,
+		RequestMethod: event.RequestMethod,
+		Timestamp:     FormatTimestamp(event.Timestamp),
+		UserAgent:     fmt.Sprintf("%q", event.UserAgent),
+		Username:      username,
+		Status:        string(event.Status),
+		Message:       event.Message,
+		Event:         event.Event,
+		Reason:        event.Reason,
+		ACR:           event.ACR,
+		AMR:           strings.Join(event.AMR, " "),
+		ErrorCode:     event.ErrorCode,
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	_, err = l.writer.Write([]byte("\n"))
+	logBuff.WriteByte('\n')
+	_, err = l.writer.Write(l.redactor.redact(logBuff.Bytes()))
 	if err != nil {
 		panic(err)
 	}
@@ -229,21 +358,13 @@ func (l *Logger) PrintReq(username, upstream string, req *http.Request, url url.
 		return
 	}
 
-	if _, ok := l.excludePaths[url.Path]; ok {
+	if l.excludedPath(url.Path) || !l.sampled(status) {
 		return
 	}
 
 	duration := float64(time.Since(ts)) / float64(time.Second)
 
-	if username == "" {
-		username = "-"
-	}
-
-	if upstream == "" {
-		upstream = "-"
-	}
-
-	if url.User != nil && username == "-" {
+	if url.User != nil && username == "" {
 		if name := url.User.Username(); name != "" {
 			username = name
 		}
@@ -255,10 +376,45 @@ func (l *Logger) PrintReq(username, upstream string, req *http.Request, url url.
 	defer l.mu.Unlock()
 
 	scope := middlewareapi.GetRequestScope(req)
-	err := l.reqTemplate.Execute(l.writer, reqLogMessageData{
+	if l.format == JSONFormat {
+		_, err := l.writer.Write(l.formatJSON([]jsonField{
+			{FieldType, "request"},
+			{FieldTimestamp, l.formatJSONTimestamp(ts)},
+			{FieldRequestID, scope.RequestID},
+			{FieldClient, client},
+			{FieldHost, requestutil.GetRequestHost(req)},
+			{FieldProtocol, req.Proto},
+			{FieldRequestMethod, req.Method},
+			{FieldRequestURI, url.RequestURI()},
+			{FieldUserAgent, req.UserAgent()},
+			{FieldUser, username},
+			{FieldProvider, scope.Provider},
+			{FieldUpstream, upstream},
+			{FieldStatusCode, status},
+			{FieldErrorCode, scope.ErrorCode},
+			{FieldResponseSize, size},
+			{FieldLatency, duration},
+		}))
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if username == "" {
+		username = "-"
+	}
+
+	if upstream == "" {
+		upstream = "-"
+	}
+
+	logBuff := new(bytes.Buffer)
+	err := l.reqTemplate.Execute(logBuff, reqLogMessageData{
 		Client:          client,
 		Host:            requestutil.GetRequestHost(req),
 		Protocol:        req.Proto,
+		Provider:        scope.Provider,
 		RequestID:       scope.RequestID,
 		RequestDuration: fmt.Sprintf("%0.3f", duration),
 		RequestMethod:   req.Method,
@@ -269,12 +425,14 @@ func (l *Logger) PrintReq(username, upstream string, req *http.Request, url url.
 		Upstream:        upstream,
 		UserAgent:       fmt.Sprintf("%q", req.UserAgent()),
 		Username:        username,
+		ErrorCode:       scope.ErrorCode,
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	_, err = l.writer.Write([]byte("\n"))
+	logBuff.WriteByte('\n')
+	_, err = l.writer.Write(l.redactor.redact(logBuff.Bytes()))
 	if err != nil {
 		panic(err)
 	}
@@ -401,6 +559,22 @@ func (l *Logger) SetReqTemplate(t string) {
 	l.reqTemplate = template.Must(template.New("req-log").Parse(t))
 }
 
+// SetFormat sets the format of log lines, either TextFormat or JSONFormat.
+func (l *Logger) SetFormat(format string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+}
+
+// SetFields sets the fields written to JSON log lines. When fields are
+// included only those are written, otherwise all fields are written, except
+// for those excluded.
+func (l *Logger) SetFields(include, exclude []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fields = newFieldSet(include, exclude)
+}
+
 // These functions utilize the standard logger.
 
 // FormatTimestamp returns a formatted timestamp for the standard logger.
@@ -485,6 +659,17 @@ func SetReqTemplate(t string) {
 	std.SetReqTemplate(t)
 }
 
+// SetFormat sets the format of log lines for the standard logger.
+func SetFormat(format string) {
+	std.SetFormat(format)
+}
+
+// SetFields sets the fields written to JSON log lines for the standard
+// logger.
+func SetFields(include, exclude []string) {
+	std.SetFields(include, exclude)
+}
+
 // Print calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
@@ -566,6 +751,11 @@ func PrintAuthf(username string, req *http.Request, status AuthStatus, format st
 	std.PrintAuthf(username, req, status, format, a...)
 }
 
+// PrintSecurityEventf writes a security event to the standard logger.
+func PrintSecurityEventf(username string, req *http.Request, status AuthStatus, security SecurityEvent, format string, a ...interface{}) {
+	std.PrintSecurityEventf(username, req, status, security, format, a...)
+}
+
 // PrintReq writes request details to the standard logger.
 func PrintReq(username, upstream string, req *http.Request, url url.URL, ts time.Time, status int, size int) {
 	std.PrintReq(username, upstream, req, url, ts, status, size)
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"regexp"
+)
+
+// Redacted replaces the redacted parts of log lines.
+const Redacted = "[REDACTED]"
+
+// tokenPatterns match tokens and credentials. The first group of a pattern,
+// if it has one, is kept so that the log line still shows what was redacted.
+var tokenPatterns = []*regexp.Regexp{
+	// JWTs, such as ID tokens and most access tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// Authorization headers
+	regexp.MustCompile(`(?i)(\b(?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]+`),
+	// Tokens and secrets in form bodies, query strings and JSON
+	regexp.MustCompile(`(?i)("?\b(?:access_token|refresh_token|id_token|client_secret|password|assertion)"?\s*[:=]\s*"?)[^"&\s,;}]+`),
+	// Authorization codes in query strings
+	regexp.MustCompile(`(?i)([?&]code=)[^&\s"]+`),
+}
+
+// redactor masks tokens and the matches of patterns in log lines.
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// newRedactor creates a redactor masking tokens, when redactTokens is set, and
+// the matches of the patterns.
+func newRedactor(redactTokens bool, patterns []*regexp.Regexp) *redactor {
+	r := &redactor{}
+	if redactTokens {
+		r.patterns = append(r.patterns, tokenPatterns...)
+	}
+	r.patterns = append(r.patterns, patterns...)
+	return r
+}
+
+// redact masks the log line.
+func (r *redactor) redact(line []byte) []byte {
+	if r == nil {
+		return line
+	}
+	for _, p := range r.patterns {
+		line = p.ReplaceAllFunc(line, func(match []byte) []byte {
+			if p.NumSubexp() == 0 {
+				return []byte(Redacted)
+			}
+			// Keep the first group, which precedes the redacted value
+			loc := p.FindSubmatchIndex(match)
+			if loc[2] < 0 {
+				return []byte(Redacted)
+			}
+			return append(append([]byte{}, match[loc[2]:loc[3]]...), Redacted...)
+		})
+	}
+	return line
+}
+
+// redactString masks the string.
+func (r *redactor) redactString(s string) string {
+	if r == nil {
+		return s
+	}
+	return string(r.redact([]byte(s)))
+}
+
+// SetRedaction sets what is masked in log lines and auth events: tokens, when
+// redactTokens is set, and the matches of the patterns.
+func (l *Logger) SetRedaction(redactTokens bool, patterns []*regexp.Regexp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = newRedactor(redactTokens, patterns)
+}
+
+// SetRedaction sets what is masked in log lines and auth events for the
+// standard logger.
+func SetRedaction(redactTokens bool, patterns []*regexp.Regexp) {
+	std.SetRedaction(redactTokens, patterns)
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SampleRates are the rates, between 0 and 1, at which requests are logged by
+// the status code of their response. Requests with a status code without a
+// rate are always logged.
+type SampleRates struct {
+	codes   map[int]float64
+	classes map[int]float64
+}
+
+// ParseSampleRates parses rates given as `<status>=<rate>`, where the status
+// is either a status code, such as `404`, or a class of status codes, such as
+// `2xx`. The rate of a status code takes precedence over that of its class.
+func ParseSampleRates(rates []string) (*SampleRates, error) {
+	r := &SampleRates{
+		codes:   make(map[int]float64),
+		classes: make(map[int]float64),
+	}
+	for _, s := range rates {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid sample rate %q: must be of the form <status>=<rate>", s)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid sample rate %q: rate must be between 0 and 1", s)
+		}
+
+		status := strings.ToLower(parts[0])
+		if len(status) == 3 && strings.HasSuffix(status, "xx") && status[0] >= '1' && status[0] <= '5' {
+			r.classes[int(status[0]-'0')] = rate
+			continue
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid sample rate %q: status must be a status code or a class such as 2xx", s)
+		}
+		r.codes[code] = rate
+	}
+	return r, nil
+}
+
+// rate returns the rate at which requests with the status code are logged.
+func (r *SampleRates) rate(status int) float64 {
+	if r == nil {
+		return 1
+	}
+	if rate, ok := r.codes[status]; ok {
+		return rate
+	}
+	if rate, ok := r.classes[status/100]; ok {
+		return rate
+	}
+	return 1
+}
+
+// sampled determines whether a request with the status code is logged.
+func (l *Logger) sampled(status int) bool {
+	rate := l.reqSampleRates.rate(status)
+	return rate >= 1 || l.random() < rate
+}
+
+// excludedPath determines whether requests to the path are not logged.
+func (l *Logger) excludedPath(path string) bool {
+	if _, ok := l.excludePaths[path]; ok {
+		return true
+	}
+	for _, re := range l.excludePathRegexes {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReqSampleRates sets the rates at which requests are logged by their
+// status code.
+func (l *Logger) SetReqSampleRates(r *SampleRates) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reqSampleRates = r
+}
+
+// SetExcludePathRegexes sets the regular expressions matching paths to
+// exclude from logging.
+func (l *Logger) SetExcludePathRegexes(res []*regexp.Regexp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.excludePathRegexes = res
+}
+
+// SetReqSampleRates sets the rates at which requests are logged by their
+// status code for the standard logger.
+func SetReqSampleRates(r *SampleRates) {
+	std.SetReqSampleRates(r)
+}
+
+// SetExcludePathRegexes sets the regular expressions matching paths to
+// exclude from logging for the standard logger.
+func SetExcludePathRegexes(res []*regexp.Regexp) {
+	std.SetExcludePathRegexes(res)
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleRates(t *testing.T) {
+	testCases := map[string]struct {
+		rates    []string
+		status   int
+		expected float64
+	}{
+		"without rates":                              {nil, 200, 1},
+		"with the rate of the class":                 {[]string{"2xx=0.01"}, 204, 0.01},
+		"with the rate of another class":             {[]string{"2xx=0.01"}, 500, 1},
+		"with the rate of the status code":           {[]string{"404=0"}, 404, 0},
+		"with the rate of the status code preferred": {[]string{"4XX=0.5", "404=0.1"}, 404, 0.1},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r, err := ParseSampleRates(tc.rates)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, r.rate(tc.status))
+		})
+	}
+}
+
+func TestParseSampleRatesErrors(t *testing.T) {
+	testCases := map[string]struct {
+		rate     string
+		expected string
+	}{
+		"without a rate":              {"2xx", `invalid sample rate "2xx": must be of the form <status>=<rate>`},
+		"with a rate above 1":         {"2xx=10", `invalid sample rate "2xx=10": rate must be between 0 and 1`},
+		"with an invalid class":       {"6xx=0.1", `invalid sample rate "6xx=0.1": status must be a status code or a class such as 2xx`},
+		"with an invalid status code": {"20=0.1", `invalid sample rate "20=0.1": status must be a status code or a class such as 2xx`},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSampleRates([]string{tc.rate})
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestSampled(t *testing.T) {
+	l := New(0)
+	r, err := ParseSampleRates([]string{"2xx=0.25"})
+	require.NoError(t, err)
+	l.SetReqSampleRates(r)
+
+	l.random = func() float64 { return 0.2 }
+	assert.True(t, l.sampled(200))
+	assert.True(t, l.sampled(500))
+
+	l.random = func() float64 { return 0.3 }
+	assert.False(t, l.sampled(200))
+	assert.True(t, l.sampled(500))
+}
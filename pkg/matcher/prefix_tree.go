@@ -0,0 +1,94 @@
+package matcher
+
+import "strings"
+
+// PrefixTree is a radix tree of string keys, which finds the keys that are
+// prefixes of a string in a single pass over the string, however many keys
+// are in the tree.
+// Each key holds the values inserted with it, such as indexes into a slice
+// held by the caller.
+type PrefixTree struct {
+	root prefixNode
+}
+
+// prefixNode is a node of the tree. Its prefix is the part of its key that
+// follows the key of its parent.
+type prefixNode struct {
+	prefix   string
+	values   []int
+	children map[byte]*prefixNode
+}
+
+// Insert adds the value to the values of the key.
+func (t *PrefixTree) Insert(key string, value int) {
+	n := &t.root
+	for key != "" {
+		child, ok := n.children[key[0]]
+		if !ok {
+			n.addChild(&prefixNode{prefix: key, values: []int{value}})
+			return
+		}
+
+		common := commonPrefixLen(key, child.prefix)
+		if common < len(child.prefix) {
+			// Split the child so that the key ends at, or branches from, a node
+			split := &prefixNode{prefix: child.prefix[:common]}
+			child.prefix = child.prefix[common:]
+			split.addChild(child)
+			n.children[split.prefix[0]] = split
+			child = split
+		}
+		key = key[common:]
+		n = child
+	}
+	n.values = append(n.values, value)
+}
+
+// WalkPrefixes calls the function with the values of each key that is a
+// prefix of the string, from the shortest key to the longest, until it
+// returns false.
+func (t *PrefixTree) WalkPrefixes(s string, fn func(values []int) bool) {
+	n := &t.root
+	for {
+		if len(n.values) > 0 && !fn(n.values) {
+			return
+		}
+		if s == "" {
+			return
+		}
+		child, ok := n.children[s[0]]
+		if !ok || !strings.HasPrefix(s, child.prefix) {
+			return
+		}
+		s = s[len(child.prefix):]
+		n = child
+	}
+}
+
+// LongestPrefix returns the values of the longest key that is a prefix of the
+// string, and whether there is one.
+func (t *PrefixTree) LongestPrefix(s string) ([]int, bool) {
+	var longest []int
+	t.WalkPrefixes(s, func(values []int) bool {
+		longest = values
+		return true
+	})
+	return longest, longest != nil
+}
+
+func (n *prefixNode) addChild(child *prefixNode) {
+	if n.children == nil {
+		n.children = make(map[byte]*prefixNode)
+	}
+	n.children[child.prefix[0]] = child
+}
+
+// commonPrefixLen returns the length of the longest common prefix of the
+// strings.
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,55 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixTree(t *testing.T) {
+	tree := &PrefixTree{}
+	tree.Insert("/api/", 0)
+	tree.Insert("/api/v1/", 1)
+	tree.Insert("/apps/", 2)
+	tree.Insert("/", 3)
+	tree.Insert("/api/", 4)
+
+	testCases := []struct {
+		str      string
+		prefixes [][]int
+	}{
+		{str: "", prefixes: nil},
+		{str: "/", prefixes: [][]int{{3}}},
+		{str: "/api", prefixes: [][]int{{3}}},
+		{str: "/api/", prefixes: [][]int{{3}, {0, 4}}},
+		{str: "/api/v1/users", prefixes: [][]int{{3}, {0, 4}, {1}}},
+		{str: "/api/v2/users", prefixes: [][]int{{3}, {0, 4}}},
+		{str: "/apps/1", prefixes: [][]int{{3}, {2}}},
+		{str: "/ap", prefixes: [][]int{{3}}},
+	}
+	for _, tc := range testCases {
+		var prefixes [][]int
+		tree.WalkPrefixes(tc.str, func(values []int) bool {
+			prefixes = append(prefixes, values)
+			return true
+		})
+		assert.Equalf(t, tc.prefixes, prefixes, "prefixes of %q", tc.str)
+	}
+}
+
+func TestPrefixTreeLongestPrefix(t *testing.T) {
+	tree := &PrefixTree{}
+	tree.Insert("/api/", 0)
+	tree.Insert("/api/v1/", 1)
+
+	values, ok := tree.LongestPrefix("/api/v1/users")
+	assert.True(t, ok)
+	assert.Equal(t, []int{1}, values)
+
+	values, ok = tree.LongestPrefix("/api/v2/users")
+	assert.True(t, ok)
+	assert.Equal(t, []int{0}, values)
+
+	_, ok = tree.LongestPrefix("/apps/")
+	assert.False(t, ok)
+}
@@ -0,0 +1,105 @@
+package matcher
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// RegexpSet matches strings against a set of regular expressions.
+//
+// Expressions anchored to the start of the string with a literal prefix, such
+// as `^/api/`, are indexed by their prefix, so that only the expressions whose
+// prefix the string starts with are run. The other expressions are run for
+// every string. Sets of path expressions therefore match in time proportional
+// to the length of the path rather than to the number of expressions.
+type RegexpSet struct {
+	exprs []*regexp.Regexp
+
+	// prefixes indexes the expressions with a literal prefix by the prefix.
+	prefixes PrefixTree
+
+	// unprefixed are the indexes of the expressions without a literal prefix.
+	unprefixed []int
+}
+
+// NewRegexpSet creates a set of the regular expressions.
+// An empty set matches nothing.
+func NewRegexpSet(exprs ...*regexp.Regexp) *RegexpSet {
+	s := &RegexpSet{exprs: exprs}
+	for i, expr := range exprs {
+		if prefix := anchoredLiteralPrefix(expr); prefix != "" {
+			s.prefixes.Insert(prefix, i)
+		} else {
+			s.unprefixed = append(s.unprefixed, i)
+		}
+	}
+	return s
+}
+
+// MatchString reports whether the string matches any expression of the set.
+func (s *RegexpSet) MatchString(str string) bool {
+	matched := false
+	s.prefixes.WalkPrefixes(str, func(candidates []int) bool {
+		for _, i := range candidates {
+			if s.exprs[i].MatchString(str) {
+				matched = true
+				return false
+			}
+		}
+		return true
+	})
+	if matched {
+		return true
+	}
+	for _, i := range s.unprefixed {
+		if s.exprs[i].MatchString(str) {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstMatch returns the index of the first expression of the set that
+// matches the string, or -1 when none matches.
+func (s *RegexpSet) FirstMatch(str string) int {
+	first := -1
+	try := func(i int) {
+		if (first < 0 || i < first) && s.exprs[i].MatchString(str) {
+			first = i
+		}
+	}
+	s.prefixes.WalkPrefixes(str, func(candidates []int) bool {
+		for _, i := range candidates {
+			try(i)
+		}
+		return true
+	})
+	for _, i := range s.unprefixed {
+		try(i)
+	}
+	return first
+}
+
+// anchoredLiteralPrefix returns the literal that every string matching the
+// expression starts with, or an empty string when the expression is not
+// anchored to the start of the string or does not start with a literal.
+func anchoredLiteralPrefix(expr *regexp.Regexp) string {
+	re, err := syntax.Parse(expr.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	var prefix strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix.WriteString(string(sub.Rune))
+	}
+	return prefix.String()
+}
@@ -0,0 +1,124 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexpSet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exprs    []string
+		matches  []string
+		excludes []string
+	}{
+		{
+			name:     "Empty set",
+			exprs:    []string{},
+			excludes: []string{"", "/", "/foo"},
+		},
+		{
+			name:     "Anchored expressions",
+			exprs:    []string{"^/foo$", "^/bar/"},
+			matches:  []string{"/foo", "/bar/", "/bar/baz"},
+			excludes: []string{"/foo/", "/bar", "/baz/bar/"},
+		},
+		{
+			name:     "Unanchored expressions",
+			exprs:    []string{"foo", "[0-9]+$"},
+			matches:  []string{"/foo", "/a/foo/b", "/a/123"},
+			excludes: []string{"/fo", "/123/a"},
+		},
+		{
+			name:     "Expressions sharing prefixes",
+			exprs:    []string{"^/api/", "^/api/v1/[a-z]+$", "^/apps"},
+			matches:  []string{"/api/", "/api/v2", "/api/v1/users", "/apps/1"},
+			excludes: []string{"/api", "/ap", "/app", "/v1/users"},
+		},
+		{
+			name:     "Flags only apply to their own expression",
+			exprs:    []string{"(?i)^/foo", "^/bar"},
+			matches:  []string{"/FOO", "/foo", "/bar"},
+			excludes: []string{"/BAR"},
+		},
+		{
+			name:     "Quoted expressions",
+			exprs:    []string{`^\Q/a|b\E`, "^/c$"},
+			matches:  []string{"/a|b", "/c"},
+			excludes: []string{"/a", "b", "/c/d"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs := make([]*regexp.Regexp, 0, len(tc.exprs))
+			for _, expr := range tc.exprs {
+				exprs = append(exprs, regexp.MustCompile(expr))
+			}
+			set := NewRegexpSet(exprs...)
+
+			for _, str := range tc.matches {
+				assert.Truef(t, set.MatchString(str), "%q should match", str)
+			}
+			for _, str := range tc.excludes {
+				assert.Falsef(t, set.MatchString(str), "%q should not match", str)
+			}
+		})
+	}
+}
+
+func TestRegexpSetFirstMatch(t *testing.T) {
+	set := NewRegexpSet(
+		regexp.MustCompile("^/api/v1/"),
+		regexp.MustCompile("users"),
+		regexp.MustCompile("^/api/"),
+	)
+	assert.Equal(t, 0, set.FirstMatch("/api/v1/users"))
+	assert.Equal(t, 1, set.FirstMatch("/api/users"))
+	assert.Equal(t, 2, set.FirstMatch("/api/groups"))
+	assert.Equal(t, 1, set.FirstMatch("/users"))
+	assert.Equal(t, -1, set.FirstMatch("/groups"))
+}
+
+func TestAnchoredLiteralPrefix(t *testing.T) {
+	testCases := map[string]string{
+		"^/foo/bar":    "/foo/bar",
+		"^/foo/[0-9]+": "/foo/",
+		"^/foo*":       "/fo",
+		"/foo":         "",
+		"^(/foo)":      "",
+		"(?i)^/foo":    "",
+		"^/a|^/b":      "",
+		"(?m)^/foo":    "",
+		"^":            "",
+	}
+	for expr, prefix := range testCases {
+		assert.Equalf(t, prefix, anchoredLiteralPrefix(regexp.MustCompile(expr)), "prefix of %q", expr)
+	}
+}
+
+func BenchmarkRegexpSet(b *testing.B) {
+	exprs := make([]*regexp.Regexp, 0, 200)
+	for i := 0; i < 200; i++ {
+		exprs = append(exprs, regexp.MustCompile(fmt.Sprintf("^/service-%d/public/", i)))
+	}
+	set := NewRegexpSet(exprs...)
+
+	b.Run("set", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			set.MatchString("/service-199/private/resource")
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, expr := range exprs {
+				if expr.MatchString("/service-199/private/resource") {
+					break
+				}
+			}
+		}
+	})
+}
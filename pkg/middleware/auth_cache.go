@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// AuthCacheOptions contains the requirements to construct an auth cache.
+type AuthCacheOptions struct {
+	// CookieName is the name of the session cookie.
+	// Split session cookies use this name with a numbered suffix.
+	CookieName string
+
+	// TTL is how long an allow decision is cached.
+	TTL time.Duration
+
+	// MaxEntries is the maximum number of cached decisions.
+	MaxEntries int
+}
+
+// NewAuthCache creates a new middleware that caches the allow decisions of
+// the auth endpoint.
+// Decisions are keyed by the session cookie, the auth request query (which
+// holds any group, email or domain requirements) and the first segment of
+// the forwarded request path.
+// This avoids loading and validating the session on every subrequest from
+// ingress controllers at the cost of revoked sessions being allowed until
+// their cached decision expires.
+func NewAuthCache(opts *AuthCacheOptions) alice.Constructor {
+	cache := newAuthCache(opts)
+	return cache.middleware
+}
+
+// authCache holds the response headers of allowed auth requests.
+type authCache struct {
+	cookieName string
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]authCacheEntry
+}
+
+// authCacheEntry is a cached allow decision.
+type authCacheEntry struct {
+	header  http.Header
+	expires time.Time
+}
+
+func newAuthCache(opts *AuthCacheOptions) *authCache {
+	return &authCache{
+		cookieName: opts.CookieName,
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		now:        time.Now,
+		entries:    make(map[string]authCacheEntry),
+	}
+}
+
+func (c *authCache) middleware(next http.Handler) http.Handler {
+	if c.ttl <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		key := c.requestKey(req)
+		if key == "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		if header, ok := c.get(key); ok {
+			for name, values := range header {
+				// Headers set earlier in the chain, such as the request ID,
+				// belong to this request
+				if _, ok := rw.Header()[name]; !ok {
+					rw.Header()[name] = append([]string(nil), values...)
+				}
+			}
+			rw.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		writer := &authCacheResponseWriter{ResponseWriter: rw}
+		next.ServeHTTP(writer, req)
+
+		// Only allow decisions for a session are cached. Responses that update
+		// the session cookie are not cached as the cookie would not be
+		// updated for subsequent requests.
+		scope := middlewareapi.GetRequestScope(req)
+		if writer.status != http.StatusAccepted || writer.header == nil ||
+			len(writer.header.Values("Set-Cookie")) > 0 || scope == nil || scope.Session == nil {
+			return
+		}
+
+		expires := c.now().Add(c.ttl)
+		if scope.Session.ExpiresOn != nil && scope.Session.ExpiresOn.Before(expires) {
+			expires = *scope.Session.ExpiresOn
+		}
+		c.store(key, authCacheEntry{header: writer.header, expires: expires})
+	})
+}
+
+// requestKey computes the cache key for the request.
+// Requests without a session cookie, or with an Authorization header that
+// takes precedence over the cookie, are not cached.
+func (c *authCache) requestKey(req *http.Request) string {
+	if req.Header.Get("Authorization") != "" {
+		return ""
+	}
+
+	hash := sha256.New()
+	found := false
+	for _, cookie := range req.Cookies() {
+		if cookie.Name == c.cookieName || strings.HasPrefix(cookie.Name, c.cookieName+"_") {
+			found = true
+			hash.Write([]byte(cookie.Name + "=" + cookie.Value + "\n"))
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	hash.Write([]byte(req.URL.RawQuery + "\n"))
+	hash.Write([]byte(forwardedPathPrefix(req)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// forwardedPathPrefix returns the first segment of the path of the request
+// being authenticated.
+func forwardedPathPrefix(req *http.Request) string {
+	uri, err := url.ParseRequestURI(requestutil.GetRequestURI(req))
+	if err != nil {
+		return ""
+	}
+	segments := strings.SplitN(strings.TrimPrefix(uri.Path, "/"), "/", 2)
+	return "/" + segments[0]
+}
+
+// get returns the cached response headers if they have not expired.
+func (c *authCache) get(key string) (http.Header, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.header, true
+}
+
+// store caches the entry, removing expired entries when the cache is full.
+func (c *authCache) store(key string, entry authCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := c.now()
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		// Drop an arbitrary entry, it will be cached again on its next request
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = entry
+}
+
+// authCacheResponseWriter records the status and a copy of the headers
+// when the response headers are written.
+type authCacheResponseWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+}
+
+// WriteHeader records the response and writes the response headers.
+func (w *authCacheResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response headers if they have not been written and then
+// writes the data to the client.
+func (w *authCacheResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auth Cache Suite", func() {
+	var cache *authCache
+	var now time.Time
+	var calls int
+	var status int
+	var setCookie bool
+	var session *sessionsapi.SessionState
+	var handler http.Handler
+
+	BeforeEach(func() {
+		now = time.Now()
+		calls = 0
+		status = http.StatusAccepted
+		setCookie = false
+		session = &sessionsapi.SessionState{User: "user"}
+
+		cache = newAuthCache(&AuthCacheOptions{
+			CookieName: "_oauth2_proxy",
+			TTL:        10 * time.Second,
+			MaxEntries: 2,
+		})
+		cache.now = func() time.Time { return now }
+
+		handler = cache.middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			calls++
+			middlewareapi.GetRequestScope(req).Session = session
+			rw.Header().Set("X-Auth-Request-User", session.User)
+			if setCookie {
+				rw.Header().Set("Set-Cookie", "_oauth2_proxy=refreshed")
+			}
+			rw.WriteHeader(status)
+		}))
+	})
+
+	serve := func(cookie, forwardedURI string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/oauth2/auth?allowed_groups=admins", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "_oauth2_proxy", Value: cookie})
+		}
+		req.Header.Set("X-Forwarded-Uri", forwardedURI)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{ReverseProxy: true})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	It("serves cached decisions for the same cookie and path prefix", func() {
+		Expect(serve("ticket", "/app/one").Code).To(Equal(http.StatusAccepted))
+		rw := serve("ticket", "/app/two")
+		Expect(rw.Code).To(Equal(http.StatusAccepted))
+		Expect(rw.Header().Get("X-Auth-Request-User")).To(Equal("user"))
+		Expect(calls).To(Equal(1))
+
+		serve("ticket", "/other")
+		serve("other-ticket", "/app/one")
+		Expect(calls).To(Equal(3))
+	})
+
+	It("expires cached decisions", func() {
+		serve("ticket", "/app")
+		now = now.Add(10 * time.Second)
+		serve("ticket", "/app")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("expires cached decisions with the session", func() {
+		expires := now.Add(time.Second)
+		session.ExpiresOn = &expires
+		serve("ticket", "/app")
+		now = now.Add(time.Second)
+		serve("ticket", "/app")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not cache denied requests", func() {
+		status = http.StatusForbidden
+		serve("ticket", "/app")
+		serve("ticket", "/app")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not cache responses that update the session cookie", func() {
+		setCookie = true
+		serve("ticket", "/app")
+		serve("ticket", "/app")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not cache requests without a session cookie", func() {
+		serve("", "/app")
+		serve("", "/app")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("limits the number of cached decisions", func() {
+		serve("one", "/app")
+		serve("two", "/app")
+		serve("three", "/app")
+		Expect(cache.entries).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/justinas/alice"
+)
+
+// NewBearerTokenAuthorization returns a middleware that only serves requests
+// presenting the token in their Authorization header, as `Bearer <token>`.
+// Other requests are rejected as unauthorized.
+func NewBearerTokenAuthorization(token string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return bearerTokenAuthorization(token, next)
+	}
+}
+
+func bearerTokenAuthorization(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !hasBearerToken(req, token) {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// hasBearerToken determines whether the Authorization header of the request
+// is the bearer token, comparing them in constant time.
+func hasBearerToken(req *http.Request, token string) bool {
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(parts[1])), []byte(token)) == 1
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bearer Token Authorization Suite", func() {
+	DescribeTable("when serving a request",
+		func(authorization string, expectedStatus int) {
+			req := httptest.NewRequest("", "/metrics", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rw := httptest.NewRecorder()
+
+			handler := NewBearerTokenAuthorization("s3cr3t")(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(expectedStatus))
+			if expectedStatus == http.StatusUnauthorized {
+				Expect(rw.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+			}
+		},
+		Entry("with the token", "Bearer s3cr3t", http.StatusOK),
+		Entry("with a lower case scheme", "bearer s3cr3t", http.StatusOK),
+		Entry("without an Authorization header", "", http.StatusUnauthorized),
+		Entry("with another token", "Bearer other", http.StatusUnauthorized),
+		Entry("with a prefix of the token", "Bearer s3cr", http.StatusUnauthorized),
+		Entry("with basic auth", "Basic czNjcjN0", http.StatusUnauthorized),
+		Entry("with the token and no scheme", "s3cr3t", http.StatusUnauthorized),
+	)
+})
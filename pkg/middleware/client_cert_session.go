@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// NewClientCertificateSessionLoader creates a new middleware that creates a
+// session from the client certificate verified by the HTTPS server.
+// The user is taken from the given certificate attribute, the email from the
+// first email SAN and the groups from the organizational units of the subject.
+func NewClientCertificateSessionLoader(userAttribute string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return loadClientCertificateSession(userAttribute, next)
+	}
+}
+
+// loadClientCertificateSession attempts to load a session from the verified
+// client certificate of the request.
+// Client certificates are only available when the request was received by
+// the HTTPS server of the proxy; they are not forwarded by reverse proxies.
+// If a session was loaded by a previous handler, it will not be replaced.
+func loadClientCertificateSession(userAttribute string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		scope := middlewareapi.GetRequestScope(req)
+		// If scope is nil, this will panic.
+		// A scope should always be injected before this handler is called.
+		if scope.Session != nil {
+			// The session was already loaded, pass to the next handler
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		scope.Session = getClientCertificateSession(userAttribute, req)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// getClientCertificateSession creates a session from the leaf of the first
+// verified certificate chain.
+// Unverified certificates are never used as the chains are only populated
+// once the certificate has been verified against the client CA.
+func getClientCertificateSession(userAttribute string, req *http.Request) *sessionsapi.SessionState {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+
+	user := clientCertificateUser(cert, userAttribute)
+	if user == "" {
+		logger.PrintAuthf(cert.Subject.String(), req, logger.AuthFailure, "Invalid authentication via client certificate: no %s attribute", userAttribute)
+		return nil
+	}
+
+	session := &sessionsapi.SessionState{
+		User:   user,
+		Groups: cert.Subject.OrganizationalUnit,
+	}
+	if len(cert.EmailAddresses) > 0 {
+		session.Email = cert.EmailAddresses[0]
+	}
+	session.CreatedAtNow()
+	expires := cert.NotAfter
+	session.ExpiresOn = &expires
+
+	logger.PrintAuthf(user, req, logger.AuthSuccess, "Authenticated via client certificate")
+	return session
+}
+
+// clientCertificateUser returns the value of the attribute of the certificate.
+// For SAN attributes the first SAN of the type is used.
+func clientCertificateUser(cert *x509.Certificate, attribute string) string {
+	switch attribute {
+	case options.ClientCertificateUserCN:
+		return cert.Subject.CommonName
+	case options.ClientCertificateUserDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case options.ClientCertificateUserURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case options.ClientCertificateUserEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	}
+	return ""
+}
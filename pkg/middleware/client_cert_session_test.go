@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Certificate Session Suite", func() {
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	cert := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "build-agent",
+			OrganizationalUnit: []string{"ci", "deployers"},
+		},
+		DNSNames:       []string{"agent.example.com"},
+		EmailAddresses: []string{"ci@example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/ci"}},
+		NotAfter:       notAfter,
+	}
+
+	type clientCertificateSessionTableInput struct {
+		connectionState *tls.ConnectionState
+		userAttribute   string
+		existingSession *sessionsapi.SessionState
+		expectedSession *sessionsapi.SessionState
+	}
+
+	DescribeTable("ClientCertificateSessionLoader",
+		func(in clientCertificateSessionTableInput) {
+			req := httptest.NewRequest("", "/", nil)
+			req.TLS = in.connectionState
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+				Session: in.existingSession,
+			})
+
+			var gotSession *sessionsapi.SessionState
+			handler := NewClientCertificateSessionLoader(in.userAttribute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotSession = middlewareapi.GetRequestScope(r).Session
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if in.expectedSession == nil {
+				Expect(gotSession).To(BeNil())
+				return
+			}
+			Expect(gotSession).ToNot(BeNil())
+			Expect(gotSession.User).To(Equal(in.expectedSession.User))
+			Expect(gotSession.Email).To(Equal(in.expectedSession.Email))
+			Expect(gotSession.Groups).To(Equal(in.expectedSession.Groups))
+			Expect(gotSession.ExpiresOn).To(Equal(in.expectedSession.ExpiresOn))
+		},
+		Entry("without TLS", clientCertificateSessionTableInput{
+			userAttribute:   options.ClientCertificateUserCN,
+			expectedSession: nil,
+		}),
+		Entry("without a verified certificate", clientCertificateSessionTableInput{
+			connectionState: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			userAttribute:   options.ClientCertificateUserCN,
+			expectedSession: nil,
+		}),
+		Entry("with the common name as the user", clientCertificateSessionTableInput{
+			connectionState: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			userAttribute:   options.ClientCertificateUserCN,
+			expectedSession: &sessionsapi.SessionState{
+				User:      "build-agent",
+				Email:     "ci@example.com",
+				Groups:    []string{"ci", "deployers"},
+				ExpiresOn: &notAfter,
+			},
+		}),
+		Entry("with the URI SAN as the user", clientCertificateSessionTableInput{
+			connectionState: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			userAttribute:   options.ClientCertificateUserURI,
+			expectedSession: &sessionsapi.SessionState{
+				User:      "spiffe://example.com/ci",
+				Email:     "ci@example.com",
+				Groups:    []string{"ci", "deployers"},
+				ExpiresOn: &notAfter,
+			},
+		}),
+		Entry("with a missing user attribute", clientCertificateSessionTableInput{
+			connectionState: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "build-agent"}}}}},
+			userAttribute:   options.ClientCertificateUserDNS,
+			expectedSession: nil,
+		}),
+		Entry("with an existing session", clientCertificateSessionTableInput{
+			connectionState: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			userAttribute:   options.ClientCertificateUserCN,
+			existingSession: &sessionsapi.SessionState{User: "user"},
+			expectedSession: &sessionsapi.SessionState{User: "user"},
+		}),
+	)
+})
@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// defaultCORSAllowedMethods are the methods allowed when no methods are
+// configured.
+var defaultCORSAllowedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// corsRoute is the CORS policy for the requests matched by an upstream path.
+type corsRoute struct {
+	path    string
+	matches func(string) bool
+	policy  *options.CORS
+}
+
+// NewCORS creates a new middleware that answers CORS preflight requests and
+// adds CORS headers to responses for upstreams that have CORS configured.
+// This must run before authentication as browsers do not send credentials
+// with preflight requests.
+func NewCORS(upstreams options.UpstreamConfig) (alice.Constructor, error) {
+	routes := []corsRoute{}
+	for _, upstream := range upstreams.Upstreams {
+		if upstream.CORS == nil {
+			continue
+		}
+		route, err := newCORSRoute(upstream)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(routes) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			policy := matchCORSRoute(routes, req.URL.Path)
+			origin := req.Header.Get("Origin")
+			if policy == nil || origin == "" {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				servePreflight(rw, req, policy)
+				return
+			}
+
+			next.ServeHTTP(&corsResponseWriter{
+				ResponseWriter: rw,
+				policy:         policy,
+				origin:         origin,
+			}, req)
+		})
+	}, nil
+}
+
+// newCORSRoute creates the corsRoute for the upstream.
+// Upstreams with rewrites have regular expression paths, and otherwise paths
+// ending in `/` match all paths under that prefix.
+func newCORSRoute(upstream options.Upstream) (corsRoute, error) {
+	route := corsRoute{path: upstream.Path, policy: upstream.CORS}
+
+	switch {
+	case upstream.RewriteTarget != "" || len(upstream.RewriteRules) > 0:
+		re, err := regexp.Compile(upstream.Path)
+		if err != nil {
+			return corsRoute{}, fmt.Errorf("invalid path %q for upstream %q: %v", upstream.Path, upstream.ID, err)
+		}
+		route.matches = re.MatchString
+	case strings.HasSuffix(upstream.Path, "/"):
+		route.matches = func(p string) bool { return strings.HasPrefix(p, upstream.Path) }
+	default:
+		route.matches = func(p string) bool { return p == upstream.Path }
+	}
+
+	return route, nil
+}
+
+// matchCORSRoute returns the CORS policy of the route with the longest path
+// that matches the request path.
+func matchCORSRoute(routes []corsRoute, reqPath string) *options.CORS {
+	var matched *corsRoute
+	for i := range routes {
+		if routes[i].matches(reqPath) && (matched == nil || len(routes[i].path) > len(matched.path)) {
+			matched = &routes[i]
+		}
+	}
+	if matched == nil {
+		return nil
+	}
+	return matched.policy
+}
+
+// servePreflight answers a preflight request.
+// CORS headers are only added if the origin and method are allowed, which
+// causes the browser to block the cross-origin request.
+func servePreflight(rw http.ResponseWriter, req *http.Request, policy *options.CORS) {
+	header := rw.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := req.Header.Get("Origin")
+	method := req.Header.Get("Access-Control-Request-Method")
+	if !util.IsOriginAllowed(origin, policy.AllowedOrigins) || !corsMethodAllowed(policy, method) {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	setCORSOriginHeaders(header, policy, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods(policy), ", "))
+	if len(policy.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+	} else if requested := req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if policy.MaxAge != nil {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Duration().Seconds())))
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// corsAllowedMethods returns the configured methods or the defaults.
+func corsAllowedMethods(policy *options.CORS) []string {
+	if len(policy.AllowedMethods) > 0 {
+		return policy.AllowedMethods
+	}
+	return defaultCORSAllowedMethods
+}
+
+// corsMethodAllowed determines whether the method is allowed.
+func corsMethodAllowed(policy *options.CORS, method string) bool {
+	for _, allowed := range corsAllowedMethods(policy) {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCORSOriginHeaders sets the headers that allow the origin to read the
+// response.
+// The origin is echoed rather than using `*` when credentials are allowed,
+// as browsers reject credentialed responses with a wildcard origin.
+func setCORSOriginHeaders(header http.Header, policy *options.CORS, origin string) {
+	allowOrigin := origin
+	if !policy.AllowCredentials {
+		for _, allowed := range policy.AllowedOrigins {
+			if allowed == "*" {
+				allowOrigin = "*"
+			}
+		}
+	}
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// corsResponseWriter replaces any CORS headers in the response with those
+// of the policy when the response headers are written.
+type corsResponseWriter struct {
+	http.ResponseWriter
+	policy      *options.CORS
+	origin      string
+	wroteHeader bool
+}
+
+// WriteHeader sets the CORS headers and writes the response headers.
+func (w *corsResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		header := w.Header()
+		for name := range header {
+			if strings.HasPrefix(name, "Access-Control-") {
+				header.Del(name)
+			}
+		}
+		header.Add("Vary", "Origin")
+		if util.IsOriginAllowed(w.origin, w.policy.AllowedOrigins) {
+			setCORSOriginHeaders(header, w.policy, w.origin)
+			if len(w.policy.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(w.policy.ExposedHeaders, ", "))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response headers if they have not been written and then
+// writes the data to the client.
+func (w *corsResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *corsResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows WebSocket connections to take over the connection.
+func (w *corsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
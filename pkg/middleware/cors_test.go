@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CORS Suite", func() {
+	maxAge := options.Duration(10 * time.Minute)
+	upstreams := options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:   "api",
+				Path: "/api/",
+				URI:  "http://localhost:8080",
+				CORS: &options.CORS{
+					AllowedOrigins:   []string{"https://*.example.com"},
+					AllowedMethods:   []string{"GET", "POST"},
+					ExposedHeaders:   []string{"X-Request-Id"},
+					AllowCredentials: true,
+					MaxAge:           &maxAge,
+				},
+			},
+			{
+				ID:   "public",
+				Path: "/api/public/",
+				URI:  "http://localhost:8080",
+				CORS: &options.CORS{
+					AllowedOrigins: []string{"*"},
+				},
+			},
+			{
+				ID:   "app",
+				Path: "/",
+				URI:  "http://localhost:8080",
+			},
+		},
+	}
+
+	type corsTableInput struct {
+		method          string
+		path            string
+		requestHeaders  map[string]string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}
+
+	DescribeTable("when serving a request",
+		func(in corsTableInput) {
+			cors, err := NewCORS(upstreams)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest(in.method, in.path, nil)
+			for name, value := range in.requestHeaders {
+				req.Header.Set(name, value)
+			}
+			rw := httptest.NewRecorder()
+			cors(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Access-Control-Allow-Origin", "https://upstream.example.com")
+				rw.WriteHeader(http.StatusUnauthorized)
+			})).ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedStatus))
+			for name, value := range in.expectedHeaders {
+				Expect(rw.Header().Get(name)).To(Equal(value), name)
+			}
+		},
+		Entry("with an allowed preflight request", corsTableInput{
+			method: "OPTIONS",
+			path:   "/api/items",
+			requestHeaders: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Content-Type",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+			},
+		}),
+		Entry("with a preflight request from a disallowed origin", corsTableInput{
+			method: "OPTIONS",
+			path:   "/api/items",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://evil.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		}),
+		Entry("with a preflight request for a disallowed method", corsTableInput{
+			method: "OPTIONS",
+			path:   "/api/items",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		}),
+		Entry("with a cross-origin request", corsTableInput{
+			method: "GET",
+			path:   "/api/items",
+			requestHeaders: map[string]string{
+				"Origin": "https://app.example.com",
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+				"Vary":                             "Origin",
+			},
+		}),
+		Entry("with a cross-origin request to the longest matching route", corsTableInput{
+			method: "GET",
+			path:   "/api/public/items",
+			requestHeaders: map[string]string{
+				"Origin": "https://other.com",
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		}),
+		Entry("with a route without CORS", corsTableInput{
+			method: "OPTIONS",
+			path:   "/index.html",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://upstream.example.com",
+			},
+		}),
+	)
+})
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/justinas/alice"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// gRPC status codes as defined in
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcStatusUnknown          = 2
+	grpcStatusPermissionDenied = 7
+	grpcStatusUnimplemented    = 12
+	grpcStatusInternal         = 13
+	grpcStatusUnavailable      = 14
+	grpcStatusUnauthenticated  = 16
+)
+
+// NewGRPCStatus creates a new middleware that converts non-200 responses to
+// gRPC requests into gRPC status responses.
+// gRPC clients cannot interpret HTTP error pages, so any error rendered by
+// OAuth2 Proxy, or returned by an upstream that is not gRPC aware, is
+// replaced by a trailers-only response carrying the equivalent grpc-status.
+func NewGRPCStatus() alice.Constructor {
+	return grpcStatus
+}
+
+func grpcStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !requestutil.IsGRPCRequest(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(&grpcResponseWriter{ResponseWriter: rw}, req)
+	})
+}
+
+// grpcResponseWriter rewrites non-200 responses to gRPC status responses.
+// Successful responses are passed through untouched so that streaming
+// and trailers work as normal.
+type grpcResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	discard     bool
+}
+
+// WriteHeader replaces any non-200 status with a trailers-only gRPC response.
+func (g *grpcResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	if code == http.StatusOK {
+		g.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	header := g.Header()
+	header.Del("Content-Length")
+	header.Del("Location")
+	header.Set("Content-Type", "application/grpc")
+	header.Set("Grpc-Status", strconv.Itoa(grpcStatusFromHTTP(code)))
+	header.Set("Grpc-Message", url.PathEscape(http.StatusText(code)))
+	g.discard = true
+	g.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+// Write discards the body of any response that has been converted to a gRPC
+// status, it would otherwise be interpreted as a malformed message.
+func (g *grpcResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.discard {
+		return len(b), nil
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// ReadFrom copies the data to the client, using the ReadFrom of the wrapped
+// writer when it has one, unless the response has been converted to a gRPC
+// status.
+func (g *grpcResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.discard {
+		return io.Copy(ioutil.Discard, r)
+	}
+	return io.Copy(g.ResponseWriter, r)
+}
+
+// Flush sends any buffered data to the client, this is required for
+// streaming gRPC responses.
+func (g *grpcResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows the connection to be taken over by the wrapped writer.
+func (g *grpcResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := g.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
+
+// grpcStatusFromHTTP maps HTTP status codes to gRPC status codes following
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func grpcStatusFromHTTP(code int) int {
+	switch code {
+	case http.StatusBadRequest:
+		return grpcStatusInternal
+	case http.StatusUnauthorized:
+		return grpcStatusUnauthenticated
+	case http.StatusForbidden:
+		return grpcStatusPermissionDenied
+	case http.StatusNotFound:
+		return grpcStatusUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcStatusUnavailable
+	default:
+		return grpcStatusUnknown
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gRPC Status Suite", func() {
+	type grpcStatusTableInput struct {
+		contentType        string
+		status             int
+		expectedStatus     int
+		expectedGRPCStatus string
+		expectedBody       string
+	}
+
+	DescribeTable("when serving a request",
+		func(in *grpcStatusTableInput) {
+			req := httptest.NewRequest("POST", "http://example.com/service.Foo/Bar", nil)
+			req.Header.Set("Content-Type", in.contentType)
+
+			rw := httptest.NewRecorder()
+
+			handler := NewGRPCStatus()(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/html")
+				rw.WriteHeader(in.status)
+				rw.Write([]byte("body"))
+			}))
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedStatus))
+			Expect(rw.Header().Get("Grpc-Status")).To(Equal(in.expectedGRPCStatus))
+			Expect(rw.Body.String()).To(Equal(in.expectedBody))
+		},
+		Entry("with a non gRPC request", &grpcStatusTableInput{
+			contentType:        "text/html",
+			status:             http.StatusUnauthorized,
+			expectedStatus:     http.StatusUnauthorized,
+			expectedGRPCStatus: "",
+			expectedBody:       "body",
+		}),
+		Entry("with a successful gRPC request", &grpcStatusTableInput{
+			contentType:        "application/grpc",
+			status:             http.StatusOK,
+			expectedStatus:     http.StatusOK,
+			expectedGRPCStatus: "",
+			expectedBody:       "body",
+		}),
+		Entry("with an unauthenticated gRPC request", &grpcStatusTableInput{
+			contentType:        "application/grpc",
+			status:             http.StatusUnauthorized,
+			expectedStatus:     http.StatusOK,
+			expectedGRPCStatus: "16",
+			expectedBody:       "",
+		}),
+		Entry("with a forbidden gRPC request", &grpcStatusTableInput{
+			contentType:        "application/grpc+proto",
+			status:             http.StatusForbidden,
+			expectedStatus:     http.StatusOK,
+			expectedGRPCStatus: "7",
+			expectedBody:       "",
+		}),
+		Entry("with an upstream error for a gRPC request", &grpcStatusTableInput{
+			contentType:        "application/grpc",
+			status:             http.StatusBadGateway,
+			expectedStatus:     http.StatusOK,
+			expectedGRPCStatus: "14",
+			expectedBody:       "",
+		}),
+		Entry("with an internal error for a gRPC request", &grpcStatusTableInput{
+			contentType:        "application/grpc",
+			status:             http.StatusInternalServerError,
+			expectedStatus:     http.StatusOK,
+			expectedGRPCStatus: "2",
+			expectedBody:       "",
+		}),
+	)
+
+	It("forwards the optional interfaces of the response writer", func() {
+		req := httptest.NewRequest("POST", "http://example.com/service.Foo/Bar", nil)
+		req.Header.Set("Content-Type", "application/grpc")
+
+		rw := httptest.NewRecorder()
+		handler := NewGRPCStatus()(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			Expect(rw).To(BeAssignableToTypeOf(&grpcResponseWriter{}))
+			_, isFlusher := rw.(http.Flusher)
+			Expect(isFlusher).To(BeTrue())
+			_, isHijacker := rw.(http.Hijacker)
+			Expect(isHijacker).To(BeTrue())
+			_, isReaderFrom := rw.(io.ReaderFrom)
+			Expect(isReaderFrom).To(BeTrue())
+
+			rw.WriteHeader(http.StatusUnauthorized)
+			n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("body"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(int64(4)))
+		}))
+		handler.ServeHTTP(rw, req)
+
+		Expect(rw.Header().Get("Grpc-Status")).To(Equal("16"))
+		Expect(rw.Body.String()).To(BeEmpty())
+	})
+})
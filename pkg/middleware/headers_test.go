@@ -80,7 +80,7 @@ var _ = Describe("Headers Suite", func() {
 				"Foo": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Foo":   []string{"bar,baz"},
@@ -105,7 +105,7 @@ var _ = Describe("Headers Suite", func() {
 				"Claim": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Claim": []string{"IDToken-1234"},
@@ -130,7 +130,7 @@ var _ = Describe("Headers Suite", func() {
 				"Claim": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Claim": []string{"bar,baz,IDToken-1234"},
@@ -306,7 +306,7 @@ var _ = Describe("Headers Suite", func() {
 				"Foo": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Foo":   []string{"bar,baz"},
@@ -331,7 +331,7 @@ var _ = Describe("Headers Suite", func() {
 				"Claim": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Claim": []string{"bar,baz,IDToken-1234"},
@@ -356,7 +356,7 @@ var _ = Describe("Headers Suite", func() {
 				"Claim": []string{"bar", "baz"},
 			},
 			session: &sessionsapi.SessionState{
-				IDToken: "IDToken-1234",
+				IDToken: []byte("IDToken-1234"),
 			},
 			expectedHeaders: http.Header{
 				"Claim": []string{"bar,baz,IDToken-1234"},
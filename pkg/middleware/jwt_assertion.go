@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/assertion"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// NewJWTAssertion creates a middleware that sends upstreams an assertion of
+// the identity of the user of the session, signed by the signer, for the
+// audience of the request.
+// The assertion header of the client is always removed, so that upstreams
+// can only receive assertions made by the proxy.
+func NewJWTAssertion(signer *assertion.Signer, audience func(*http.Request) string) alice.Constructor {
+	var clk clock.Clock
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			req.Header.Del(assertion.Header)
+
+			scope := middlewareapi.GetRequestScope(req)
+			if scope != nil && scope.Session != nil {
+				jwt, err := signer.Sign(scope.Session, audience(req), clk.Now())
+				if err != nil {
+					logger.Errorf("Error signing the JWT assertion: %v", err)
+				} else {
+					req.Header.Set(assertion.Header, jwt)
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
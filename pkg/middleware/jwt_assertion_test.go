@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/assertion"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var _ = Describe("JWT Assertion Suite", func() {
+	var signer *assertion.Signer
+
+	BeforeEach(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		der, err := x509.MarshalECPrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		signer, err = assertion.NewSigner(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), "oauth2-proxy")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	// serve returns the assertion header received by the upstream
+	serve := func(session *sessionsapi.SessionState) string {
+		req := httptest.NewRequest(http.MethodGet, "/app", nil)
+		req.Header.Set(assertion.Header, "forged")
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: session})
+
+		var received string
+		handler := NewJWTAssertion(signer, func(req *http.Request) string {
+			return "audience" + req.URL.Path
+		})(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			received = req.Header.Get(assertion.Header)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return received
+	}
+
+	It("sends an assertion of the session for the audience of the request", func() {
+		received := serve(&sessionsapi.SessionState{User: "john", Email: "john@example.com"})
+		Expect(received).ToNot(Equal("forged"))
+
+		token, err := jwt.ParseSigned(received)
+		Expect(err).ToNot(HaveOccurred())
+		claims := jwt.Claims{}
+		Expect(token.Claims(signer.KeySet().Keys[0].Key, &claims)).To(Succeed())
+		Expect(claims.Subject).To(Equal("john"))
+		Expect(claims.Audience).To(Equal(jwt.Audience{"audience/app"}))
+	})
+
+	It("removes the assertion of the client without a session", func() {
+		Expect(serve(nil)).To(BeEmpty())
+	})
+})
@@ -62,8 +62,8 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 
 	var verifiedSessionExpiry = time.Unix(1912151821, 0)
 	var verifiedSession = &sessionsapi.SessionState{
-		AccessToken: verifiedToken,
-		IDToken:     verifiedToken,
+		AccessToken: []byte(verifiedToken),
+		IDToken:     []byte(verifiedToken),
 		Email:       "john@example.com",
 		User:        "1234567890",
 		ExpiresOn:   &verifiedSessionExpiry,
@@ -441,8 +441,8 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				}
 
 				Expect(err).ToNot(HaveOccurred())
-				Expect(session.AccessToken).To(Equal(rawIDToken))
-				Expect(session.IDToken).To(Equal(rawIDToken))
+				Expect(session.AccessToken).To(Equal([]byte(rawIDToken)))
+				Expect(session.IDToken).To(Equal([]byte(rawIDToken)))
 				Expect(session.User).To(Equal(in.expectedUser))
 				Expect(session.Email).To(Equal(in.expectedEmail))
 				Expect(session.ExpiresOn.Unix()).To(Equal(in.expectedExpires.Unix()))
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
+)
+
+// NewLoadShedding creates a middleware that limits the requests served at
+// once with the limiter. Requests over the limit that are not given room
+// within the queue timeout of the limiter are rejected with a 503 Service
+// Unavailable response. Requests whose context is done while they are queued
+// are dropped.
+func NewLoadShedding(limiter *concurrency.Limiter) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			release, err := limiter.Acquire(req.Context())
+			if errors.Is(err, concurrency.ErrOverloaded) {
+				writeOverloaded(rw, req)
+				return
+			}
+			if err != nil {
+				// The client went away while the request was queued
+				return
+			}
+			defer release()
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// writeOverloaded rejects a request because the proxy is at one of its
+// concurrency limits, asking the client to retry it later.
+func writeOverloaded(rw http.ResponseWriter, req *http.Request) {
+	errcode.Record(req, errcode.Overloaded)
+	rw.Header().Set("Retry-After", "1")
+	http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
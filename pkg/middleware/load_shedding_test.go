@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load Shedding Suite", func() {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	It("serves every request without a limiter", func() {
+		handler := NewLoadShedding(nil)(next)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+		Expect(rw.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects requests over the limit with a 503", func() {
+		limiter := concurrency.NewLimiter(concurrency.StageRequests, 1, 10*time.Millisecond)
+		handler := NewLoadShedding(limiter)(next)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		scope := &middlewareapi.RequestScope{}
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil), scope)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(rw.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(scope.ErrorCode).To(Equal(errcode.Overloaded))
+
+		// Requests are served once there is room for them, and free it
+		// when they are done
+		release()
+		for i := 0; i < 2; i++ {
+			rw = httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+		}
+	})
+
+	It("drops requests whose context is done while they are queued", func() {
+		limiter := concurrency.NewLimiter(concurrency.StageRequests, 1, time.Minute)
+		handler := NewLoadShedding(limiter)(next)
+
+		release, err := limiter.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		scope := &middlewareapi.RequestScope{}
+		req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil).WithContext(ctx), scope)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		Expect(rw.Body.Len()).To(Equal(0))
+		Expect(scope.ErrorCode).To(BeEmpty())
+	})
+})
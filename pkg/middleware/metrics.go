@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -22,7 +25,10 @@ func NewMetricsHandlerWithDefaultRegistry() http.Handler {
 // provided prometheus.Registerer and prometheus.Gatherer
 func NewMetricsHandler(registerer prometheus.Registerer, gatherer prometheus.Gatherer) http.Handler {
 	return promhttp.InstrumentMetricHandler(
-		registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+		registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+			// OpenMetrics is required to expose the exemplars of histograms
+			EnableOpenMetrics: true,
+		}),
 	)
 }
 
@@ -47,15 +53,36 @@ func NewRequestMetrics(registerer prometheus.Registerer) alice.Constructor {
 			return promhttp.InstrumentHandlerInFlight(registerInflightRequestsGauge(registerer), next)
 		}
 
-		// The latency of all requests bucketed by HTTP method
+		// The latency of all requests bucketed by HTTP method, with the trace
+		// of the request as an exemplar
 		durationHandler := func(next http.Handler) http.Handler {
-			return promhttp.InstrumentHandlerDuration(registerRequestsLatencyHistogram(registerer), next)
+			return instrumentHandlerDuration(registerRequestsLatencyHistogram(registerer), next)
 		}
 
 		return alice.New(counterHandler, inFlightHandler, durationHandler).Then(next)
 	}
 }
 
+// instrumentHandlerDuration observes the latency of requests by their method,
+// like promhttp.InstrumentHandlerDuration, adding the ID of the trace of the
+// request as an exemplar.
+func instrumentHandlerDuration(histogram *prometheus.HistogramVec, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(rw, req)
+
+		observer := histogram.WithLabelValues(strings.ToLower(req.Method))
+		duration := time.Since(start).Seconds()
+
+		scope := middlewareapi.GetRequestScope(req)
+		if eo, ok := observer.(prometheus.ExemplarObserver); ok && scope != nil && scope.TraceID != "" {
+			eo.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": scope.TraceID})
+			return
+		}
+		observer.Observe(duration)
+	})
+}
+
 // registerRequestsCounter registers the 'oauth2_proxy_requests_total' metric
 // This keeps a tally of all received requests bucket by their HTTP response
 // status code
@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
@@ -64,4 +65,20 @@ var _ = Describe("Instrumentation suite", func() {
 			expectedResultsFile: "testdata/metrics/notfoundrequest.txt",
 		}),
 	)
+
+	It("adds the trace of the request as an exemplar of its latency", func() {
+		registry := prometheus.NewRegistry()
+		req := httptest.NewRequest("", "http://example.com/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"})
+
+		handler := NewRequestMetrics(registry)(http.NotFoundHandler())
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		metricsReq := httptest.NewRequest("", "http://example.com/metrics", nil)
+		metricsReq.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+		rw := httptest.NewRecorder()
+		NewMetricsHandler(registry, registry).ServeHTTP(rw, metricsReq)
+
+		Expect(rw.Body.String()).To(MatchRegexp(`oauth2_proxy_response_duration_seconds_bucket\{method="get",le="[0-9.]+"\} 1 # \{trace_id="4bf92f3577b34da6a3ce929d0e0e4736"\}`))
+	})
 })
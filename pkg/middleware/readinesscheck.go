@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// Verifiable is an interface for an object that depends on external services
+// and exports a function to validate that they are available.
+type Verifiable interface {
+	VerifyConnection(context.Context) error
+}
+
+// NewReadinessCheck returns a middleware that performs deep health checks
+// (verifies that the services the proxy depends on are available) on a
+// specific `path`.
+func NewReadinessCheck(path string, verifiable Verifiable) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return readinessCheck(path, verifiable, next)
+	}
+}
+
+func readinessCheck(path string, verifiable Verifiable, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if path != "" && req.URL.EscapedPath() == path {
+			if err := verifiable.VerifyConnection(req.Context()); err != nil {
+				logger.Errorf("Unsuccessful readiness check: %v", err)
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(rw, "error: %v", err)
+				return
+			}
+
+			rw.WriteHeader(http.StatusOK)
+			fmt.Fprintf(rw, "OK")
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type fakeVerifiable struct {
+	err error
+}
+
+func (f fakeVerifiable) VerifyConnection(_ context.Context) error {
+	return f.err
+}
+
+var _ = Describe("ReadinessCheck suite", func() {
+	type requestTableInput struct {
+		readyPath      string
+		verifyErr      error
+		requestString  string
+		expectedStatus int
+		expectedBody   string
+	}
+
+	DescribeTable("when serving a request",
+		func(in *requestTableInput) {
+			req := httptest.NewRequest("", in.requestString, nil)
+			rw := httptest.NewRecorder()
+
+			handler := NewReadinessCheck(in.readyPath, fakeVerifiable{err: in.verifyErr})(http.NotFoundHandler())
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedStatus))
+			Expect(rw.Body.String()).To(Equal(in.expectedBody))
+		},
+		Entry("when no ready path is configured", &requestTableInput{
+			readyPath:      "",
+			requestString:  "http://example.com/ready",
+			expectedStatus: 404,
+			expectedBody:   "404 page not found\n",
+		}),
+		Entry("when requesting the ready path and all checks pass", &requestTableInput{
+			readyPath:      "/ready",
+			requestString:  "http://example.com/ready",
+			expectedStatus: 200,
+			expectedBody:   "OK",
+		}),
+		Entry("when requesting the ready path and a check fails", &requestTableInput{
+			readyPath:      "/ready",
+			verifyErr:      errors.New("upstream \"foo\" has no healthy endpoints"),
+			requestString:  "http://example.com/ready",
+			expectedStatus: 503,
+			expectedBody:   "error: upstream \"foo\" has no healthy endpoints",
+		}),
+		Entry("when requesting a different path", &requestTableInput{
+			readyPath:      "/ready",
+			verifyErr:      errors.New("failed"),
+			requestString:  "http://example.com/different",
+			expectedStatus: 404,
+			expectedBody:   "404 page not found\n",
+		}),
+	)
+})
@@ -26,11 +26,7 @@ func NewRedirectToHTTPS(httpsPort string) alice.Constructor {
 // to the port from the httpsAddress given.
 func redirectToHTTPS(httpsPort string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		proto := requestutil.GetRequestProto(req)
-		if strings.EqualFold(proto, httpsScheme) || (req.TLS != nil && proto == req.URL.Scheme) {
-			// Only care about the connection to us being HTTPS if the proto wasn't
-			// from a trusted `X-Forwarded-Proto` (proto == req.URL.Scheme).
-			// Otherwise the proto is source of truth
+		if isHTTPSRequest(req) {
 			next.ServeHTTP(rw, req)
 			return
 		}
@@ -54,3 +50,12 @@ func redirectToHTTPS(httpsPort string, next http.Handler) http.Handler {
 		http.Redirect(rw, req, targetURL.String(), http.StatusPermanentRedirect)
 	})
 }
+
+// isHTTPSRequest determines whether the request was made over HTTPS.
+func isHTTPSRequest(req *http.Request) bool {
+	proto := requestutil.GetRequestProto(req)
+	// Only care about the connection to us being HTTPS if the proto wasn't
+	// from a trusted `X-Forwarded-Proto` (proto == req.URL.Scheme).
+	// Otherwise the proto is source of truth
+	return strings.EqualFold(proto, httpsScheme) || (req.TLS != nil && proto == req.URL.Scheme)
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"bufio"
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	"time"
@@ -72,6 +73,18 @@ func (r *loggingResponse) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// ReadFrom copies the response to the ResponseWriter, so that its ReadFrom,
+// which sends files with sendfile, is used when it has one
+func (r *loggingResponse) ReadFrom(src io.Reader) (int64, error) {
+	if r.status == 0 {
+		// The status will be StatusOK if WriteHeader has not been called yet
+		r.status = http.StatusOK
+	}
+	size, err := io.Copy(r.ResponseWriter, src)
+	r.size += int(size)
+	return size, err
+}
+
 // WriteHeader writes the status code for the Response
 func (r *loggingResponse) WriteHeader(s int) {
 	r.ResponseWriter.WriteHeader(s)
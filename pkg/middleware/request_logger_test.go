@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -121,4 +123,84 @@ var _ = Describe("Request logger suite", func() {
 			ExcludePaths:       []string{"/ping"},
 		}),
 	)
+
+	Context("with sampling and excluded path regexes", func() {
+		AfterEach(func() {
+			logger.SetReqSampleRates(nil)
+			logger.SetExcludePathRegexes(nil)
+		})
+
+		DescribeTable("when service a request",
+			func(path string, status int, expectLogged bool) {
+				buf := bytes.NewBuffer(nil)
+				logger.SetOutput(buf)
+				logger.SetReqTemplate("{{.RequestURI}} {{.StatusCode}}")
+				logger.SetExcludePaths(nil)
+				logger.SetExcludePathRegexes([]*regexp.Regexp{regexp.MustCompile("^/static/")})
+				rates, err := logger.ParseSampleRates([]string{"2xx=0", "204=1"})
+				Expect(err).ToNot(HaveOccurred())
+				logger.SetReqSampleRates(rates)
+
+				req, err := http.NewRequest("GET", path, nil)
+				Expect(err).ToNot(HaveOccurred())
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+				handler := NewRequestLogger()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.WriteHeader(status)
+				}))
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+
+				if expectLogged {
+					Expect(buf.String()).To(Equal(fmt.Sprintf("%q %d\n", path, status)))
+				} else {
+					Expect(buf.String()).To(BeEmpty())
+				}
+			},
+			Entry("with a status code that is not sampled", "/foo", 200, false),
+			Entry("with a status code that is sampled", "/foo", 204, true),
+			Entry("with a status code without a sample rate", "/foo", 500, true),
+			Entry("with a path matching an excluded regex", "/static/app.js", 500, false),
+		)
+	})
+
+	Context("with the JSON format", func() {
+		AfterEach(func() {
+			logger.SetFormat(logger.TextFormat)
+			logger.SetFields(nil, nil)
+		})
+
+		DescribeTable("when service a request",
+			func(include, exclude []string, expectedLogMessage string) {
+				buf := bytes.NewBuffer(nil)
+				logger.SetOutput(buf)
+				logger.SetFormat(logger.JSONFormat)
+				logger.SetFields(include, exclude)
+				logger.SetExcludePaths(nil)
+
+				req, err := http.NewRequest("GET", "/foo/bar?baz=1", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.RemoteAddr = "127.0.0.1"
+				req.Host = "test-server"
+				req.Header.Set("User-Agent", "test-agent")
+
+				scope := &middlewareapi.RequestScope{
+					RequestID: "11111111-2222-4333-8444-555555555555",
+					Session:   &sessions.SessionState{Email: "json.user@example.com"},
+					Provider:  "oidc",
+				}
+				req = middlewareapi.AddRequestScope(req, scope)
+
+				handler := NewRequestLogger()(testUpstreamHandler("json"))
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+
+				Expect(buf.String()).To(Equal(expectedLogMessage))
+			},
+			Entry("with all fields but the time", nil, []string{logger.FieldTimestamp, logger.FieldLatency},
+				`{"type":"request","request_id":"11111111-2222-4333-8444-555555555555","client":"127.0.0.1","host":"test-server","protocol":"HTTP/1.1","request_method":"GET","request_uri":"/foo/bar?baz=1","user_agent":"test-agent","user":"json.user@example.com","provider":"oidc","upstream":"json","status_code":200,"response_size":4}`+"\n"),
+			Entry("with included fields", []string{logger.FieldUser, logger.FieldStatusCode, logger.FieldRequestURI}, nil,
+				`{"request_uri":"/foo/bar?baz=1","user":"json.user@example.com","status_code":200}`+"\n"),
+			Entry("with included and excluded fields", []string{logger.FieldUser, logger.FieldStatusCode}, []string{logger.FieldUser},
+				`{"status_code":200}`+"\n"),
+		)
+	})
 })
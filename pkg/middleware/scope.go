@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/hex"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/justinas/alice"
@@ -14,6 +17,7 @@ func NewScope(reverseProxy bool, idHeader string) alice.Constructor {
 			scope := &middlewareapi.RequestScope{
 				ReverseProxy: reverseProxy,
 				RequestID:    genRequestID(req, idHeader),
+				TraceID:      getTraceID(req),
 			}
 			req = middlewareapi.AddRequestScope(req, scope)
 			next.ServeHTTP(rw, req)
@@ -21,6 +25,19 @@ func NewScope(reverseProxy bool, idHeader string) alice.Constructor {
 	}
 }
 
+// NewProviderScope records the ID of the provider that authenticates requests
+// in their request scope.
+func NewProviderScope(providerID string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if scope := middlewareapi.GetRequestScope(req); scope != nil {
+				scope.Provider = providerID
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
 // genRequestID sets a request-wide ID for use in logging or error pages.
 // If a RequestID header is set, it uses that. Otherwise, it generates a random
 // UUID for the lifespan of the request.
@@ -31,3 +48,26 @@ func genRequestID(req *http.Request, idHeader string) string {
 	}
 	return uuid.New().String()
 }
+
+// getTraceID returns the trace ID of the W3C Trace Context `traceparent`
+// header of the request, formatted as `00-<trace-id>-<parent-id>-<flags>`,
+// when the trace is sampled.
+func getTraceID(req *http.Request) string {
+	parts := strings.Split(req.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || bytes.Equal(traceID, make([]byte, 16)) {
+		return ""
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || flags[0]&0x01 == 0 {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
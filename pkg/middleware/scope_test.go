@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -115,6 +116,43 @@ var _ = Describe("Scope Suite", func() {
 			})
 		})
 	})
+
+	DescribeTable("sets the TraceID from the traceparent header",
+		func(traceparent, expected string) {
+			req := httptest.NewRequest("", "http://127.0.0.1/", nil)
+			req.Header.Set("traceparent", traceparent)
+
+			var scope *middlewareapi.RequestScope
+			handler := NewScope(false, testRequestHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				scope = middlewareapi.GetRequestScope(r)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(scope.TraceID).To(Equal(expected))
+		},
+		Entry("with a sampled trace", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		Entry("with a trace that is not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", ""),
+		Entry("with a future version with more fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		Entry("with an invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""),
+		Entry("with an invalid trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""),
+		Entry("with a malformed header", "00-4bf92f3577b34da6a3ce929d0e0e4736-01", ""),
+		Entry("without the header", "", ""),
+	)
+
+	Context("NewProviderScope", func() {
+		It("sets the Provider of the request scope", func() {
+			req := httptest.NewRequest("", "http://127.0.0.1/", nil)
+			scope := &middlewareapi.RequestScope{}
+			req = middlewareapi.AddRequestScope(req, scope)
+
+			handler := NewProviderScope("oidc")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(scope.Provider).To(Equal("oidc"))
+		})
+	})
 })
 
 type mockRand struct{}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// NewSecurityHeaders creates a new middleware that adds the configured
+// security headers to the responses generated by the proxy itself.
+// Responses of upstreams are identified by the upstream of the request
+// scope, and keep their own headers.
+func NewSecurityHeaders(opts options.SecurityHeaders) alice.Constructor {
+	headers := securityHeaders(opts)
+	hsts := strictTransportSecurity(opts)
+
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 && hsts == "" {
+			return next
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			w := &securityHeadersResponseWriter{
+				ResponseWriter: rw,
+				scope:          middlewareapi.GetRequestScope(req),
+				headers:        headers,
+			}
+			// Browsers ignore the header on insecure responses
+			if hsts != "" && isHTTPSRequest(req) {
+				w.headers = append(w.headers, [2]string{"Strict-Transport-Security", hsts})
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// securityHeaders returns the names and values of the configured security
+// headers, other than Strict-Transport-Security.
+func securityHeaders(opts options.SecurityHeaders) [][2]string {
+	headers := [][2]string{}
+	if opts.ContentTypeNosniff {
+		headers = append(headers, [2]string{"X-Content-Type-Options", "nosniff"})
+	}
+	if opts.CrossOriginOpenerPolicy != "" {
+		headers = append(headers, [2]string{"Cross-Origin-Opener-Policy", opts.CrossOriginOpenerPolicy})
+	}
+	if opts.CrossOriginEmbedderPolicy != "" {
+		headers = append(headers, [2]string{"Cross-Origin-Embedder-Policy", opts.CrossOriginEmbedderPolicy})
+	}
+	if opts.PermissionsPolicy != "" {
+		headers = append(headers, [2]string{"Permissions-Policy", opts.PermissionsPolicy})
+	}
+	return headers
+}
+
+// strictTransportSecurity returns the value of the Strict-Transport-Security
+// header, or an empty value when it is disabled.
+func strictTransportSecurity(opts options.SecurityHeaders) string {
+	if opts.HSTSMaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", int64(opts.HSTSMaxAge.Seconds()))
+	if opts.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if opts.HSTSPreload {
+		value += "; preload"
+	}
+	return value
+}
+
+// securityHeadersResponseWriter sets the security headers when the response
+// headers are written, unless the response is from an upstream.
+type securityHeadersResponseWriter struct {
+	http.ResponseWriter
+	scope       *middlewareapi.RequestScope
+	headers     [][2]string
+	wroteHeader bool
+}
+
+// WriteHeader sets the security headers and writes the response headers.
+func (w *securityHeadersResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		if w.scope == nil || w.scope.Upstream == "" {
+			header := w.Header()
+			for _, h := range w.headers {
+				header.Set(h[0], h[1])
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response headers if they have not been written and then
+// writes the data to the client.
+func (w *securityHeadersResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *securityHeadersResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows WebSocket connections to take over the connection.
+func (w *securityHeadersResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Security Headers Suite", func() {
+	opts := options.SecurityHeaders{
+		HSTSMaxAge:                365 * 24 * time.Hour,
+		HSTSIncludeSubdomains:     true,
+		HSTSPreload:               true,
+		ContentTypeNosniff:        true,
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginEmbedderPolicy: "require-corp",
+		PermissionsPolicy:         "camera=(), microphone=()",
+	}
+
+	type securityHeadersTableInput struct {
+		opts            options.SecurityHeaders
+		requestString   string
+		upstream        string
+		expectedHeaders map[string]string
+	}
+
+	DescribeTable("when serving a request",
+		func(in securityHeadersTableInput) {
+			req := httptest.NewRequest("", in.requestString, nil)
+			scope := &middlewareapi.RequestScope{}
+			req = middlewareapi.AddRequestScope(req, scope)
+
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				// Upstreams record themselves in the scope and may set their
+				// own security headers
+				scope.Upstream = in.upstream
+				if in.upstream != "" {
+					rw.Header().Set("Cross-Origin-Opener-Policy", "unsafe-none")
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			rw := httptest.NewRecorder()
+			NewSecurityHeaders(in.opts)(handler).ServeHTTP(rw, req)
+
+			for _, name := range []string{
+				"Strict-Transport-Security",
+				"X-Content-Type-Options",
+				"Cross-Origin-Opener-Policy",
+				"Cross-Origin-Embedder-Policy",
+				"Permissions-Policy",
+			} {
+				Expect(rw.Header().Get(name)).To(Equal(in.expectedHeaders[name]), name)
+			}
+		},
+		Entry("without security headers", securityHeadersTableInput{
+			opts:            options.SecurityHeaders{},
+			requestString:   "https://example.com/oauth2/sign_in",
+			expectedHeaders: map[string]string{},
+		}),
+		Entry("with an HTTPS request", securityHeadersTableInput{
+			opts:          opts,
+			requestString: "https://example.com/oauth2/sign_in",
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security":    "max-age=31536000; includeSubDomains; preload",
+				"X-Content-Type-Options":       "nosniff",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"Permissions-Policy":           "camera=(), microphone=()",
+			},
+		}),
+		Entry("with an HTTP request", securityHeadersTableInput{
+			opts:          opts,
+			requestString: "http://example.com/oauth2/sign_in",
+			expectedHeaders: map[string]string{
+				"X-Content-Type-Options":       "nosniff",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"Permissions-Policy":           "camera=(), microphone=()",
+			},
+		}),
+		Entry("with only HSTS", securityHeadersTableInput{
+			opts:          options.SecurityHeaders{HSTSMaxAge: time.Hour},
+			requestString: "https://example.com/ping",
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=3600",
+			},
+		}),
+		Entry("with an upstream response", securityHeadersTableInput{
+			opts:          opts,
+			requestString: "https://example.com/app",
+			upstream:      "app",
+			expectedHeaders: map[string]string{
+				"Cross-Origin-Opener-Policy": "unsafe-none",
+			},
+		}),
+	)
+})
@@ -2,14 +2,19 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/justinas/alice"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
@@ -48,6 +53,30 @@ type StoredSessionLoaderOptions struct {
 	// If the sesssion is older than `RefreshPeriod` but the provider doesn't
 	// refresh it, we must re-validate using this validation.
 	ValidateSession func(context.Context, *sessionsapi.SessionState) bool
+
+	// How often should sessions that are not refreshed be validated.
+	// Optional, sessions are only validated when they are refreshed when
+	// this is zero.
+	ValidationInterval time.Duration
+
+	// RefreshPolicy returns the intervals to use for the request, allowing
+	// them to be overridden for particular routes.
+	// Optional, the RefreshPeriod and ValidationInterval are used when this
+	// is nil or returns nil.
+	RefreshPolicy func(*http.Request) *SessionRefreshPolicy
+
+	// RefreshLimiter limits the sessions refreshed at once.
+	// Optional, refreshes are not limited when this is nil. Sessions that
+	// have not expired are used without being refreshed when there is no
+	// room to refresh them.
+	RefreshLimiter *concurrency.Limiter
+}
+
+// SessionRefreshPolicy determines how often sessions are refreshed and
+// validated for a request.
+type SessionRefreshPolicy struct {
+	RefreshPeriod      time.Duration
+	ValidationInterval time.Duration
 }
 
 // NewStoredSessionLoader creates a new storedSessionLoader which loads
@@ -60,6 +89,11 @@ func NewStoredSessionLoader(opts *StoredSessionLoaderOptions) alice.Constructor
 		refreshPeriod:    opts.RefreshPeriod,
 		sessionRefresher: opts.RefreshSession,
 		sessionValidator: opts.ValidateSession,
+
+		validationInterval: opts.ValidationInterval,
+		refreshPolicy:      opts.RefreshPolicy,
+		refreshLimiter:     opts.RefreshLimiter,
+		validations:        newSessionValidations(),
 	}
 	return ss.loadSession
 }
@@ -71,6 +105,11 @@ type storedSessionLoader struct {
 	refreshPeriod    time.Duration
 	sessionRefresher func(context.Context, *sessionsapi.SessionState) (bool, error)
 	sessionValidator func(context.Context, *sessionsapi.SessionState) bool
+
+	validationInterval time.Duration
+	refreshPolicy      func(*http.Request) *SessionRefreshPolicy
+	refreshLimiter     *concurrency.Limiter
+	validations        *sessionValidations
 }
 
 // loadSession attempts to load a session as identified by the request cookies.
@@ -88,6 +127,19 @@ func (s *storedSessionLoader) loadSession(next http.Handler) http.Handler {
 		}
 
 		session, err := s.getValidatedSession(rw, req)
+		if errors.Is(err, concurrency.ErrOverloaded) {
+			// The session is kept, so that it can be used once the proxy is
+			// no longer overloaded
+			logger.Errorf("Error loading cookied session: %v", err)
+			writeOverloaded(rw, req)
+			return
+		}
+		if err != nil && req.Context().Err() != nil {
+			// The request was cancelled while the session was refreshed,
+			// which says nothing about whether the session is still valid
+			logger.Errorf("Error loading cookied session: %v", err)
+			return
+		}
 		if err != nil && !errors.Is(err, http.ErrNoCookie) {
 			// In the case when there was an error loading the session,
 			// we should clear the session
@@ -101,6 +153,10 @@ func (s *storedSessionLoader) loadSession(next http.Handler) http.Handler {
 		// Add the session to the scope if it was found
 		scope.Session = session
 		next.ServeHTTP(rw, req)
+
+		// The tokens of the session are no longer used once the request is
+		// served
+		session.Wipe()
 	})
 }
 
@@ -112,22 +168,77 @@ func (s *storedSessionLoader) getValidatedSession(rw http.ResponseWriter, req *h
 		// No session was found in the storage or error occurred, nothing more to do
 		return nil, err
 	}
+	logger.Debugf(logger.ComponentSessions, "Loaded session %s", session)
 
-	err = s.refreshSessionIfNeeded(rw, req, session)
+	ctx, rejected := concurrency.TrackRejections(req.Context())
+	err = s.refreshSessionIfNeeded(rw, req.WithContext(ctx), session)
 	if err != nil {
+		if rejected() {
+			// The session could not be refreshed or validated because the
+			// proxy is overloaded, rather than because it is no longer valid
+			return nil, fmt.Errorf("%w: could not refresh session (%s): %v", concurrency.ErrOverloaded, session, err)
+		}
+		if reason := revocationReason(err); reason != "" {
+			errcode.Record(req, revocationCodes[reason])
+			acr, amr := session.AuthenticationContext()
+			logger.PrintSecurityEventf(session.Email, req, logger.AuthFailure, logger.SecurityEvent{
+				Event:  logger.EventSessionRevoked,
+				Reason: reason,
+				ACR:    acr,
+				AMR:    amr,
+			}, "Session revoked: %v", err)
+		}
 		return nil, fmt.Errorf("error refreshing access token for session (%s): %v", session, err)
 	}
 
 	return session, nil
 }
 
+// policyFor returns the refresh period and validation interval for the
+// request.
+func (s *storedSessionLoader) policyFor(req *http.Request) SessionRefreshPolicy {
+	if s.refreshPolicy != nil {
+		if policy := s.refreshPolicy(req); policy != nil {
+			return *policy
+		}
+	}
+	return SessionRefreshPolicy{
+		RefreshPeriod:      s.refreshPeriod,
+		ValidationInterval: s.validationInterval,
+	}
+}
+
 // refreshSessionIfNeeded will attempt to refresh a session if the session
-// is older than the refresh period.
+// is older than the refresh period, or if the request scope forces it.
 // Success or fail, we will then validate the session.
+// Sessions that are not refreshed are validated if they have not been
+// validated within the validation interval.
 func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
-	if !needsRefresh(s.refreshPeriod, session) {
-		// Refresh is disabled or the session is not old enough, do nothing
-		return nil
+	policy := s.policyFor(req)
+	refreshPeriod := policy.RefreshPeriod
+	scope := middlewareapi.GetRequestScope(req)
+	force := scope != nil && scope.ForceSessionRefresh
+	if !force && !needsRefresh(refreshPeriod, session) {
+		// Refresh is disabled or the session is not old enough
+		return s.validateSessionIfNeeded(req.Context(), session, policy.ValidationInterval)
+	}
+
+	release, err := s.refreshLimiter.Acquire(req.Context())
+	if err != nil {
+		if !force && !session.IsExpired() {
+			logger.Debugf(logger.ComponentSessions, "Too many concurrent refreshes, using session without refreshing it - User: %s", session.User)
+			return nil
+		}
+		return fmt.Errorf("could not refresh session: %v", err)
+	}
+	defer release()
+
+	// A session that was locked when it was loaded is being refreshed by
+	// another request, so wait before trying to obtain its lock. Session
+	// stores load the state of the lock with the session, so this does not
+	// need another round trip.
+	if locked, err := session.PeekLock(req.Context()); err == nil && locked {
+		time.Sleep(sessionRefreshRetryPeriod)
 	}
 
 	var lockObtained bool
@@ -163,6 +274,7 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	}()
 
 	// Reload the session in case it was changed underneath us.
+	loadedCreatedAt := session.CreatedAt
 	freshSession, err := s.store.Load(req)
 	if err != nil {
 		return fmt.Errorf("could not load session: %v", err)
@@ -179,9 +291,10 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	// Loading from the session store creates a new lock in the session.
 	session.Lock = lock
 
-	if !needsRefresh(s.refreshPeriod, session) {
+	if (force && refreshedSince(loadedCreatedAt, session)) || (!force && !needsRefresh(refreshPeriod, session)) {
 		// The session must have already been refreshed while we were waiting to
 		// obtain the lock.
+		logger.Debugf(logger.ComponentSessions, "Session was refreshed by another request - User: %s", session.User)
 		return nil
 	}
 
@@ -194,7 +307,27 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	}
 
 	// Validate all sessions after any Redeem/Refresh operation (fail or success)
-	return s.validateSession(req.Context(), session)
+	if err := s.validateSession(req.Context(), session); err != nil {
+		return err
+	}
+	s.validations.record(session, policy.ValidationInterval)
+	return nil
+}
+
+// validateSessionIfNeeded validates a session that was not refreshed if it
+// is older than the validation interval and has not been validated within
+// the interval.
+func (s *storedSessionLoader) validateSessionIfNeeded(ctx context.Context, session *sessionsapi.SessionState, interval time.Duration) error {
+	if interval <= time.Duration(0) || session.Age() <= interval || s.validations.recent(session) {
+		return nil
+	}
+	logger.Debugf(logger.ComponentSessions, "Validating session - User: %s; SessionAge: %s", session.User, session.Age())
+
+	if err := s.validateSession(ctx, session); err != nil {
+		return err
+	}
+	s.validations.record(session, interval)
+	return nil
 }
 
 // needsRefresh determines whether we should attempt to refresh a session or not.
@@ -202,11 +335,18 @@ func needsRefresh(refreshPeriod time.Duration, session *sessionsapi.SessionState
 	return refreshPeriod > time.Duration(0) && session.Age() > refreshPeriod
 }
 
+// refreshedSince determines whether the session was refreshed after it was
+// created at the given time, by another request.
+func refreshedSince(createdAt *time.Time, session *sessionsapi.SessionState) bool {
+	return createdAt != nil && session.CreatedAt != nil && session.CreatedAt.After(*createdAt)
+}
+
 // refreshSession attempts to refresh the session with the provider
 // and will save the session if it was updated.
 func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
 	refreshed, err := s.sessionRefresher(req.Context(), session)
 	if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
+		errcode.Record(req, errcode.Refresh(err))
 		return fmt.Errorf("error refreshing tokens: %v", err)
 	}
 
@@ -232,23 +372,133 @@ func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.R
 	// Because the session was refreshed, make sure to save it
 	err = s.store.Save(rw, req, session)
 	if err != nil {
+		errcode.Record(req, errcode.SessionSaveFailed)
 		logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
 		return fmt.Errorf("error saving session: %v", err)
 	}
 	return nil
 }
 
+var (
+	errSessionExpired = errors.New("session is expired")
+	errSessionInvalid = errors.New("session is invalid")
+)
+
+// revocationReason returns the reason of the security event for a session
+// that failed validation, or "" for other errors.
+func revocationReason(err error) string {
+	switch {
+	case errors.Is(err, errSessionExpired):
+		return logger.ReasonSessionExpired
+	case errors.Is(err, errSessionInvalid):
+		return logger.ReasonSessionInvalid
+	default:
+		return ""
+	}
+}
+
+// revocationCodes are the codes of the errors of sessions that failed
+// validation, for each reason.
+var revocationCodes = map[string]string{
+	logger.ReasonSessionExpired: errcode.SessionExpired,
+	logger.ReasonSessionInvalid: errcode.SessionInvalid,
+}
+
 // validateSession checks whether the session has expired and performs
 // provider validation on the session.
 // An error implies the session is not longer valid.
 func (s *storedSessionLoader) validateSession(ctx context.Context, session *sessionsapi.SessionState) error {
 	if session.IsExpired() {
-		return errors.New("session is expired")
+		return errSessionExpired
 	}
 
 	if !s.sessionValidator(ctx, session) {
-		return errors.New("session is invalid")
+		return errSessionInvalid
 	}
 
 	return nil
 }
+
+// maxSessionValidations is the maximum number of recent session validations
+// that are remembered.
+const maxSessionValidations = 10000
+
+// sessionValidations remembers which sessions were validated recently so that
+// sessions that are not refreshed are only validated once per interval.
+// A nil sessionValidations remembers nothing.
+type sessionValidations struct {
+	mutex   sync.Mutex
+	now     func() time.Time
+	entries map[string]time.Time
+}
+
+func newSessionValidations() *sessionValidations {
+	return &sessionValidations{
+		now:     time.Now,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// recent determines whether the session was validated within the interval
+// it was recorded with.
+func (v *sessionValidations) recent(session *sessionsapi.SessionState) bool {
+	if v == nil {
+		return false
+	}
+	key := sessionValidationKey(session)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	expires, ok := v.entries[key]
+	if !ok {
+		return false
+	}
+	if !v.now().Before(expires) {
+		delete(v.entries, key)
+		return false
+	}
+	return true
+}
+
+// record remembers that the session was validated for the interval.
+func (v *sessionValidations) record(session *sessionsapi.SessionState, interval time.Duration) {
+	if v == nil || interval <= time.Duration(0) {
+		return
+	}
+	key := sessionValidationKey(session)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := v.now()
+	if len(v.entries) >= maxSessionValidations {
+		for k, expires := range v.entries {
+			if !now.Before(expires) {
+				delete(v.entries, k)
+			}
+		}
+	}
+	if len(v.entries) >= maxSessionValidations {
+		// Drop an arbitrary entry, the session will be validated again on its
+		// next request
+		for k := range v.entries {
+			delete(v.entries, k)
+			break
+		}
+	}
+	v.entries[key] = now.Add(interval)
+}
+
+// sessionValidationKey identifies a session by its user, access token and
+// creation time, so that refreshed sessions are tracked separately.
+func sessionValidationKey(session *sessionsapi.SessionState) string {
+	hash := sha256.New()
+	hash.Write([]byte(session.User + "\n"))
+	hash.Write(session.AccessToken)
+	hash.Write([]byte("\n"))
+	if session.CreatedAt != nil {
+		hash.Write([]byte(session.CreatedAt.String()))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -12,6 +12,9 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -82,6 +85,19 @@ func (l *testLockConcurrent) Release(_ context.Context) error {
 	return nil
 }
 
+// copySession copies a session passed to the next handler, as its tokens are
+// wiped once the request is served.
+func copySession(session *sessionsapi.SessionState) *sessionsapi.SessionState {
+	if session == nil {
+		return nil
+	}
+	copied := *session
+	copied.AccessToken = append([]byte(nil), session.AccessToken...)
+	copied.IDToken = append([]byte(nil), session.IDToken...)
+	copied.RefreshToken = append([]byte(nil), session.RefreshToken...)
+	return &copied
+}
+
 var _ = Describe("Stored Session Suite", func() {
 	const (
 		refresh        = "Refresh"
@@ -98,9 +114,9 @@ var _ = Describe("Stored Session Suite", func() {
 		createdFuture := now.Add(5 * time.Minute)
 
 		var defaultRefreshFunc = func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
-			switch ss.RefreshToken {
+			switch string(ss.RefreshToken) {
 			case refresh:
-				ss.RefreshToken = refreshed
+				ss.RefreshToken = []byte(refreshed)
 				return true, nil
 			case noRefresh:
 				return false, nil
@@ -110,7 +126,7 @@ var _ = Describe("Stored Session Suite", func() {
 		}
 
 		var defaultValidateFunc = func(_ context.Context, ss *sessionsapi.SessionState) bool {
-			return ss.AccessToken != "Invalid"
+			return string(ss.AccessToken) != "Invalid"
 		}
 
 		var defaultSessionStore = &fakeSessionStore{
@@ -118,32 +134,32 @@ var _ = Describe("Stored Session Suite", func() {
 				switch req.Header.Get("Cookie") {
 				case "_oauth2_proxy=NoRefreshSession":
 					return &sessionsapi.SessionState{
-						RefreshToken: noRefresh,
+						RefreshToken: []byte(noRefresh),
 						CreatedAt:    &createdPast,
 						ExpiresOn:    &createdFuture,
 					}, nil
 				case "_oauth2_proxy=InvalidNoRefreshSession":
 					return &sessionsapi.SessionState{
-						AccessToken:  "Invalid",
-						RefreshToken: noRefresh,
+						AccessToken:  []byte("Invalid"),
+						RefreshToken: []byte(noRefresh),
 						CreatedAt:    &createdPast,
 						ExpiresOn:    &createdFuture,
 					}, nil
 				case "_oauth2_proxy=ExpiredNoRefreshSession":
 					return &sessionsapi.SessionState{
-						RefreshToken: noRefresh,
+						RefreshToken: []byte(noRefresh),
 						CreatedAt:    &createdPast,
 						ExpiresOn:    &createdPast,
 					}, nil
 				case "_oauth2_proxy=RefreshSession":
 					return &sessionsapi.SessionState{
-						RefreshToken: refresh,
+						RefreshToken: []byte(refresh),
 						CreatedAt:    &createdPast,
 						ExpiresOn:    &createdFuture,
 					}, nil
 				case "_oauth2_proxy=RefreshError":
 					return &sessionsapi.SessionState{
-						RefreshToken: "RefreshError",
+						RefreshToken: []byte("RefreshError"),
 						CreatedAt:    &createdPast,
 						ExpiresOn:    &createdFuture,
 					}, nil
@@ -197,7 +213,7 @@ var _ = Describe("Stored Session Suite", func() {
 				// from the scope
 				var gotSession *sessionsapi.SessionState
 				handler := NewStoredSessionLoader(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					gotSession = middlewareapi.GetRequestScope(r).Session
+					gotSession = copySession(middlewareapi.GetRequestScope(r).Session)
 				}))
 				handler.ServeHTTP(rw, req)
 
@@ -228,10 +244,10 @@ var _ = Describe("Stored Session Suite", func() {
 					"Cookie": []string{"_oauth2_proxy=RefreshSession"},
 				},
 				existingSession: &sessionsapi.SessionState{
-					RefreshToken: "Existing",
+					RefreshToken: []byte("Existing"),
 				},
 				expectedSession: &sessionsapi.SessionState{
-					RefreshToken: "Existing",
+					RefreshToken: []byte("Existing"),
 				},
 				store:           defaultSessionStore,
 				refreshPeriod:   1 * time.Minute,
@@ -244,7 +260,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				existingSession: nil,
 				expectedSession: &sessionsapi.SessionState{
-					RefreshToken: noRefresh,
+					RefreshToken: []byte(noRefresh),
 					CreatedAt:    &createdPast,
 					ExpiresOn:    &createdFuture,
 					Lock:         &sessionsapi.NoOpLock{},
@@ -271,7 +287,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				existingSession: nil,
 				expectedSession: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 					ExpiresOn:    &createdFuture,
 				},
@@ -286,7 +302,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				existingSession: nil,
 				expectedSession: &sessionsapi.SessionState{
-					RefreshToken: "Refreshed",
+					RefreshToken: []byte("Refreshed"),
 					CreatedAt:    &now,
 					ExpiresOn:    &createdFuture,
 					Lock:         &sessionsapi.NoOpLock{},
@@ -302,7 +318,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				existingSession: nil,
 				expectedSession: &sessionsapi.SessionState{
-					RefreshToken: "RefreshError",
+					RefreshToken: []byte("RefreshError"),
 					CreatedAt:    &createdPast,
 					ExpiresOn:    &createdFuture,
 					Lock:         &sessionsapi.NoOpLock{},
@@ -411,7 +427,7 @@ var _ = Describe("Stored Session Suite", func() {
 			},
 			Entry("with two concurrent requests", storedSessionLoaderConcurrentTableInput{
 				existingSession: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 				},
 				numConcReqs:   2,
@@ -419,7 +435,7 @@ var _ = Describe("Stored Session Suite", func() {
 			}),
 			Entry("with 5 concurrent requests", storedSessionLoaderConcurrentTableInput{
 				existingSession: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 				},
 				numConcReqs:   5,
@@ -427,20 +443,175 @@ var _ = Describe("Stored Session Suite", func() {
 			}),
 			Entry("with one request", storedSessionLoaderConcurrentTableInput{
 				existingSession: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 				},
 				numConcReqs:   1,
 				refreshPeriod: 1 * time.Minute,
 			}),
 		)
+
+		Context("when a session fails validation", func() {
+			var sink *recordingAuthSink
+
+			BeforeEach(func() {
+				sink = &recordingAuthSink{}
+				logger.SetAuthSinks(sink)
+			})
+
+			AfterEach(func() {
+				logger.SetAuthSinks()
+			})
+
+			DescribeTable("emits a session revoked event",
+				func(cookie, reason, code string) {
+					req := httptest.NewRequest("", "/", nil)
+					req.Header.Set("Cookie", cookie)
+					req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+					handler := NewStoredSessionLoader(&StoredSessionLoaderOptions{
+						SessionStore:    defaultSessionStore,
+						RefreshPeriod:   1 * time.Minute,
+						RefreshSession:  defaultRefreshFunc,
+						ValidateSession: defaultValidateFunc,
+					})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+					handler.ServeHTTP(httptest.NewRecorder(), req)
+
+					Expect(sink.events).To(HaveLen(1))
+					Expect(sink.events[0].Status).To(Equal(logger.AuthFailure))
+					Expect(sink.events[0].Event).To(Equal(logger.EventSessionRevoked))
+					Expect(sink.events[0].Reason).To(Equal(reason))
+					Expect(sink.events[0].ErrorCode).To(Equal(code))
+					Expect(middlewareapi.GetRequestScope(req).ErrorCode).To(Equal(code))
+				},
+				Entry("when it has expired", "_oauth2_proxy=ExpiredNoRefreshSession", logger.ReasonSessionExpired, "session_expired"),
+				Entry("when the provider considers it invalid", "_oauth2_proxy=InvalidNoRefreshSession", logger.ReasonSessionInvalid, "session_invalid"),
+			)
+		})
+
+		It("wipes the tokens of the session once the request is served", func() {
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Cookie", "_oauth2_proxy=NoRefreshSession")
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+			var session *sessionsapi.SessionState
+			var refreshToken []byte
+			NewStoredSessionLoader(&StoredSessionLoaderOptions{
+				SessionStore:    defaultSessionStore,
+				RefreshPeriod:   1 * time.Minute,
+				RefreshSession:  defaultRefreshFunc,
+				ValidateSession: defaultValidateFunc,
+			})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				session = middlewareapi.GetRequestScope(r).Session
+				refreshToken = append([]byte(nil), session.RefreshToken...)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(refreshToken).To(Equal([]byte(noRefresh)))
+			Expect(session.RefreshToken).To(Equal(make([]byte, len(noRefresh))))
+		})
+
+		Context("when the proxy is overloaded", func() {
+			var cleared bool
+			var store *fakeSessionStore
+
+			BeforeEach(func() {
+				cleared = false
+				store = &fakeSessionStore{
+					LoadFunc: defaultSessionStore.LoadFunc,
+					ClearFunc: func(http.ResponseWriter, *http.Request) error {
+						cleared = true
+						return nil
+					},
+				}
+			})
+
+			// serve serves a request with the cookie, returning the response,
+			// the session passed to the next handler, if it was called, and
+			// the scope of the request
+			serve := func(opts *StoredSessionLoaderOptions, cookie string) (*httptest.ResponseRecorder, *sessionsapi.SessionState, *middlewareapi.RequestScope) {
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Cookie", cookie)
+				scope := &middlewareapi.RequestScope{}
+				req = middlewareapi.AddRequestScope(req, scope)
+
+				var gotSession *sessionsapi.SessionState
+				rw := httptest.NewRecorder()
+				NewStoredSessionLoader(opts)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+					gotSession = copySession(middlewareapi.GetRequestScope(r).Session)
+				})).ServeHTTP(rw, req)
+				return rw, gotSession, scope
+			}
+
+			// fullLimiter returns a limiter without room for any work
+			fullLimiter := func() *concurrency.Limiter {
+				limiter := concurrency.NewLimiter(concurrency.StageRefresh, 1, 0)
+				_, err := limiter.Acquire(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				return limiter
+			}
+
+			It("uses sessions that have not expired without refreshing them", func() {
+				rw, session, _ := serve(&StoredSessionLoaderOptions{
+					SessionStore:    store,
+					RefreshPeriod:   1 * time.Minute,
+					RefreshSession:  defaultRefreshFunc,
+					ValidateSession: defaultValidateFunc,
+					RefreshLimiter:  fullLimiter(),
+				}, "_oauth2_proxy=RefreshSession")
+
+				Expect(rw.Code).To(Equal(http.StatusOK))
+				Expect(session).ToNot(BeNil())
+				Expect(session.RefreshToken).To(Equal([]byte(refresh)))
+				Expect(cleared).To(BeFalse())
+			})
+
+			It("rejects requests with sessions that have expired without clearing them", func() {
+				rw, session, scope := serve(&StoredSessionLoaderOptions{
+					SessionStore:    store,
+					RefreshPeriod:   1 * time.Minute,
+					RefreshSession:  defaultRefreshFunc,
+					ValidateSession: defaultValidateFunc,
+					RefreshLimiter:  fullLimiter(),
+				}, "_oauth2_proxy=ExpiredNoRefreshSession")
+
+				Expect(rw.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(rw.Header().Get("Retry-After")).To(Equal("1"))
+				Expect(session).To(BeNil())
+				Expect(scope.ErrorCode).To(Equal(errcode.Overloaded))
+				Expect(cleared).To(BeFalse())
+			})
+
+			It("rejects requests with sessions that the overloaded provider could not validate", func() {
+				providerLimiter := fullLimiter()
+				rw, session, scope := serve(&StoredSessionLoaderOptions{
+					SessionStore:  store,
+					RefreshPeriod: 1 * time.Minute,
+					RefreshSession: func(ctx context.Context, _ *sessionsapi.SessionState) (bool, error) {
+						_, err := providerLimiter.Acquire(ctx)
+						return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+					},
+					ValidateSession: func(ctx context.Context, _ *sessionsapi.SessionState) bool {
+						_, err := providerLimiter.Acquire(ctx)
+						return err == nil
+					},
+				}, "_oauth2_proxy=RefreshSession")
+
+				Expect(rw.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(session).To(BeNil())
+				Expect(scope.ErrorCode).To(Equal(errcode.Overloaded))
+				Expect(cleared).To(BeFalse())
+			})
+		})
 	})
 
 	Context("refreshSessionIfNeeded", func() {
 		type refreshSessionIfNeededTableInput struct {
 			refreshPeriod            time.Duration
+			validationInterval       time.Duration
+			refreshPolicy            *SessionRefreshPolicy
 			session                  *sessionsapi.SessionState
 			concurrentSessionRefresh bool
+			forceRefresh             bool
 			expectedErr              error
 			expectRefreshed          bool
 			expectValidated          bool
@@ -475,11 +646,16 @@ var _ = Describe("Stored Session Suite", func() {
 				}
 
 				s := &storedSessionLoader{
-					refreshPeriod: in.refreshPeriod,
-					store:         store,
+					refreshPeriod:      in.refreshPeriod,
+					validationInterval: in.validationInterval,
+					refreshPolicy: func(*http.Request) *SessionRefreshPolicy {
+						return in.refreshPolicy
+					},
+					validations: newSessionValidations(),
+					store:       store,
 					sessionRefresher: func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
 						refreshed = true
-						switch ss.RefreshToken {
+						switch string(ss.RefreshToken) {
 						case refresh:
 							return true, nil
 						case noRefresh:
@@ -492,11 +668,12 @@ var _ = Describe("Stored Session Suite", func() {
 					},
 					sessionValidator: func(_ context.Context, ss *sessionsapi.SessionState) bool {
 						validated = true
-						return ss.AccessToken != "Invalid"
+						return string(ss.AccessToken) != "Invalid"
 					},
 				}
 
 				req := httptest.NewRequest("", "/", nil)
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{ForceSessionRefresh: in.forceRefresh})
 				err := s.refreshSessionIfNeeded(nil, req, in.session)
 				if in.expectedErr != nil {
 					Expect(err).To(MatchError(in.expectedErr))
@@ -516,7 +693,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the refresh period is 0, and the session does not need refreshing", refreshSessionIfNeededTableInput{
 				refreshPeriod: time.Duration(0),
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdFuture,
 					Lock:         &testLock{},
 				},
@@ -528,7 +705,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the refresh period is 0, and the session needs refreshing", refreshSessionIfNeededTableInput{
 				refreshPeriod: time.Duration(0),
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 					Lock:         &testLock{},
 				},
@@ -540,7 +717,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the session does not need refreshing", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdFuture,
 					Lock:         &testLock{},
 				},
@@ -552,7 +729,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the session is refreshed by the provider", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 					CreatedAt:    &createdPast,
 					Lock:         &testLock{},
 				},
@@ -564,7 +741,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when obtaining lock failed, but concurrent request refreshed", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: noRefresh,
+					RefreshToken: []byte(noRefresh),
 					CreatedAt:    &createdPast,
 					Lock: &testLock{
 						obtainOnAttempt: 4,
@@ -579,7 +756,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when obtaining lock failed with a valid session", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: noRefresh,
+					RefreshToken: []byte(noRefresh),
 					CreatedAt:    &createdPast,
 					Lock: &testLock{
 						obtainError: sessionsapi.ErrLockNotObtained,
@@ -593,7 +770,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the session is not refreshed by the provider", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: noRefresh,
+					RefreshToken: []byte(noRefresh),
 					CreatedAt:    &createdPast,
 					ExpiresOn:    &createdFuture,
 					Lock:         &testLock{},
@@ -606,7 +783,7 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the provider doesn't implement refresh", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					RefreshToken: notImplemented,
+					RefreshToken: []byte(notImplemented),
 					CreatedAt:    &createdPast,
 					Lock:         &testLock{},
 				},
@@ -618,8 +795,8 @@ var _ = Describe("Stored Session Suite", func() {
 			Entry("when the session is not refreshed by the provider", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
-					AccessToken:  "Invalid",
-					RefreshToken: noRefresh,
+					AccessToken:  []byte("Invalid"),
+					RefreshToken: []byte(noRefresh),
 					CreatedAt:    &createdPast,
 					ExpiresOn:    &createdFuture,
 					Lock:         &testLock{},
@@ -629,7 +806,123 @@ var _ = Describe("Stored Session Suite", func() {
 				expectValidated:      true,
 				expectedLockObtained: true,
 			}),
+			Entry("when the session does not need refreshing and is older than the validation interval", refreshSessionIfNeededTableInput{
+				refreshPeriod:      time.Duration(0),
+				validationInterval: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      false,
+				expectValidated:      true,
+				expectedLockObtained: false,
+			}),
+			Entry("when the session does not need refreshing and is no longer valid", refreshSessionIfNeededTableInput{
+				refreshPeriod:      time.Duration(0),
+				validationInterval: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					AccessToken:  []byte("Invalid"),
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				expectedErr:          errors.New("session is invalid"),
+				expectRefreshed:      false,
+				expectValidated:      true,
+				expectedLockObtained: false,
+			}),
+			Entry("when the session is younger than the validation interval", refreshSessionIfNeededTableInput{
+				refreshPeriod:      time.Duration(0),
+				validationInterval: 10 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      false,
+				expectValidated:      false,
+				expectedLockObtained: false,
+			}),
+			Entry("when the route disables refreshing", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				refreshPolicy: &SessionRefreshPolicy{},
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      false,
+				expectValidated:      false,
+				expectedLockObtained: false,
+			}),
+			Entry("when the route has a shorter refresh period", refreshSessionIfNeededTableInput{
+				refreshPeriod: 10 * time.Minute,
+				refreshPolicy: &SessionRefreshPolicy{RefreshPeriod: 1 * time.Minute},
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      true,
+				expectValidated:      true,
+				expectedLockObtained: true,
+			}),
+			Entry("when the refresh is forced and the session does not need refreshing", refreshSessionIfNeededTableInput{
+				refreshPeriod: time.Duration(0),
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock:         &testLock{},
+				},
+				forceRefresh:         true,
+				expectedErr:          nil,
+				expectRefreshed:      true,
+				expectValidated:      true,
+				expectedLockObtained: true,
+			}),
+			Entry("when the refresh is forced, but concurrent request refreshed", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: []byte(refresh),
+					CreatedAt:    &createdPast,
+					Lock: &testLock{
+						obtainOnAttempt: 4,
+					},
+				},
+				concurrentSessionRefresh: true,
+				forceRefresh:             true,
+				expectedErr:              nil,
+				expectRefreshed:          false,
+				expectValidated:          false,
+				expectedLockObtained:     true,
+			}),
 		)
+
+		It("validates sessions that are not refreshed once per validation interval", func() {
+			validations := 0
+			s := &storedSessionLoader{
+				validationInterval: 1 * time.Minute,
+				validations:        newSessionValidations(),
+				sessionValidator: func(context.Context, *sessionsapi.SessionState) bool {
+					validations++
+					return true
+				},
+			}
+
+			session := &sessionsapi.SessionState{
+				AccessToken: []byte("AccessToken"),
+				CreatedAt:   &createdPast,
+			}
+			req := httptest.NewRequest("", "/", nil)
+			Expect(s.refreshSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(s.refreshSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(validations).To(Equal(1))
+		})
 	})
 
 	Context("refreshSession", func() {
@@ -649,14 +942,14 @@ var _ = Describe("Stored Session Suite", func() {
 					store: &fakeSessionStore{
 						SaveFunc: func(_ http.ResponseWriter, _ *http.Request, ss *sessionsapi.SessionState) error {
 							saved = true
-							if ss.AccessToken == "NoSave" {
+							if string(ss.AccessToken) == "NoSave" {
 								return errors.New("unable to save session")
 							}
 							return nil
 						},
 					},
 					sessionRefresher: func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
-						switch ss.RefreshToken {
+						switch string(ss.RefreshToken) {
 						case refresh:
 							return true, nil
 						case noRefresh:
@@ -681,28 +974,28 @@ var _ = Describe("Stored Session Suite", func() {
 			},
 			Entry("when the provider does not refresh the session", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
-					RefreshToken: noRefresh,
+					RefreshToken: []byte(noRefresh),
 				},
 				expectedErr: nil,
 				expectSaved: false,
 			}),
 			Entry("when the provider refreshes the session", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
+					RefreshToken: []byte(refresh),
 				},
 				expectedErr: nil,
 				expectSaved: true,
 			}),
 			Entry("when the provider doesn't implement refresh", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
-					RefreshToken: notImplemented,
+					RefreshToken: []byte(notImplemented),
 				},
 				expectedErr: nil,
 				expectSaved: true,
 			}),
 			Entry("when the provider returns an error", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
-					RefreshToken: "RefreshError",
+					RefreshToken: []byte("RefreshError"),
 					CreatedAt:    &now,
 					ExpiresOn:    &now,
 				},
@@ -711,8 +1004,8 @@ var _ = Describe("Stored Session Suite", func() {
 			}),
 			Entry("when the saving the session returns an error", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
-					RefreshToken: refresh,
-					AccessToken:  "NoSave",
+					RefreshToken: []byte(refresh),
+					AccessToken:  []byte("NoSave"),
 				},
 				expectedErr: errors.New("error saving session: unable to save session"),
 				expectSaved: true,
@@ -726,7 +1019,7 @@ var _ = Describe("Stored Session Suite", func() {
 		BeforeEach(func() {
 			s = &storedSessionLoader{
 				sessionValidator: func(_ context.Context, ss *sessionsapi.SessionState) bool {
-					return ss.AccessToken == "Valid"
+					return string(ss.AccessToken) == "Valid"
 				},
 			}
 		})
@@ -735,7 +1028,7 @@ var _ = Describe("Stored Session Suite", func() {
 			It("does not return an error", func() {
 				expires := time.Now().Add(1 * time.Minute)
 				session := &sessionsapi.SessionState{
-					AccessToken: "Valid",
+					AccessToken: []byte("Valid"),
 					ExpiresOn:   &expires,
 				}
 				Expect(s.validateSession(ctx, session)).To(Succeed())
@@ -747,7 +1040,7 @@ var _ = Describe("Stored Session Suite", func() {
 				created := time.Now().Add(-5 * time.Minute)
 				expires := time.Now().Add(-1 * time.Minute)
 				session := &sessionsapi.SessionState{
-					AccessToken: "Valid",
+					AccessToken: []byte("Valid"),
 					CreatedAt:   &created,
 					ExpiresOn:   &expires,
 				}
@@ -759,7 +1052,7 @@ var _ = Describe("Stored Session Suite", func() {
 			It("returns an error", func() {
 				expires := time.Now().Add(1 * time.Minute)
 				session := &sessionsapi.SessionState{
-					AccessToken: "Invalid",
+					AccessToken: []byte("Invalid"),
 					ExpiresOn:   &expires,
 				}
 				Expect(s.validateSession(ctx, session)).To(MatchError("session is invalid"))
@@ -768,6 +1061,18 @@ var _ = Describe("Stored Session Suite", func() {
 	})
 })
 
+// recordingAuthSink records the auth events it receives.
+type recordingAuthSink struct {
+	mutex  sync.Mutex
+	events []logger.AuthEvent
+}
+
+func (r *recordingAuthSink) WriteAuthEvent(event logger.AuthEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
 type fakeSessionStore struct {
 	SaveFunc  func(http.ResponseWriter, *http.Request, *sessionsapi.SessionState) error
 	LoadFunc  func(req *http.Request) (*sessionsapi.SessionState, error)
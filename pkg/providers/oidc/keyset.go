@@ -0,0 +1,280 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// keysRefreshJitter is the fraction of the refresh interval by which each
+	// refresh is moved earlier or later, so that proxies started together do
+	// not all fetch the keys of the provider at the same time.
+	keysRefreshJitter = 0.1
+
+	// keysRetryInterval is the longest time before a failed refresh of the
+	// keys is retried.
+	keysRetryInterval = 30 * time.Second
+
+	// keysFetchTimeout is the longest time a fetch of the discovery document
+	// and the keys may take.
+	keysFetchTimeout = 30 * time.Second
+
+	// unknownKeyFetchInterval is the shortest time between fetches of the
+	// keys for tokens signed with an unknown key, so that such tokens cannot
+	// be used to flood the provider with requests.
+	unknownKeyFetchInterval = 5 * time.Second
+)
+
+// keySet is an oidc.KeySet that keeps the keys of the provider, and fetches
+// them again in the background every interval, following changes of the JWKs
+// URL of the discovery document.
+// When the provider cannot be reached the keys last fetched are used, so an
+// outage of the provider does not fail the verification of tokens signed with
+// known keys. The keys are only fetched while verifying a token when it is
+// signed with an unknown key, eg just after the keys were rotated.
+type keySet struct {
+	// ctx is the context of the requests to the provider.
+	ctx context.Context
+
+	// discover fetches the discovery document again and returns its JWKs URL,
+	// or is nil when discovery is skipped.
+	discover func(context.Context) (string, error)
+
+	// fetches ensures that only one fetch of the keys runs at a time, and
+	// that concurrent verifications of tokens share it.
+	fetches singleflight.Group
+
+	// jwks is the keys document, only used within fetches.
+	jwks *document
+
+	mutex     sync.RWMutex
+	keys      []jose.JSONWebKey
+	lastFetch time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// newKeySet creates a key set for the keys at the JWKs URL. When the interval
+// is not 0, the keys are fetched in the background every interval and the
+// health of the refreshes is recorded in the metrics of the worker.
+func newKeySet(ctx context.Context, jwksURL string, discover func(context.Context) (string, error), interval time.Duration, workerName string) *keySet {
+	k := &keySet{
+		ctx:      requests.WithEndpoint(ctx, requests.ProviderEndpointJWKS),
+		discover: discover,
+		jwks:     &document{url: jwksURL},
+		stop:     make(chan struct{}),
+	}
+	if interval > 0 {
+		go k.run(interval, workers.New(workerName, interval))
+	}
+	return k
+}
+
+// VerifySignature verifies the signature of the JWT with the keys of the
+// provider, and returns its payload.
+func (k *keySet) VerifySignature(_ context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+
+	if payload, ok := verifyWithKeys(jws, k.cachedKeys()); ok {
+		return payload, nil
+	}
+
+	// The token may be signed with a key that the provider added since the
+	// keys were last fetched
+	keys, err := k.fetchUnknownKey()
+	if err != nil {
+		return nil, fmt.Errorf("fetching keys %v", err)
+	}
+	if payload, ok := verifyWithKeys(jws, keys); ok {
+		return payload, nil
+	}
+	return nil, errors.New("failed to verify id token signature")
+}
+
+// Close stops fetching the keys in the background.
+func (k *keySet) Close() {
+	k.closeOnce.Do(func() { close(k.stop) })
+}
+
+// run fetches the keys immediately, and then again every interval, until the
+// key set is closed. Failed fetches are retried sooner, and the previous keys
+// are used until they succeed.
+func (k *keySet) run(interval time.Duration, worker *workers.Worker) {
+	// The discovery document was fetched when the key set was created
+	discover := false
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-timer.C:
+		}
+
+		_, err := k.fetch(discover)
+		worker.Record(err)
+		discover = true
+
+		next := interval
+		if err != nil {
+			logger.Errorf("Error refreshing OIDC provider keys, the previous keys are used until the refresh succeeds: %v", err)
+			if keysRetryInterval < next {
+				next = keysRetryInterval
+			}
+		}
+		timer.Reset(jitter(next))
+	}
+}
+
+// cachedKeys returns the keys last fetched.
+func (k *keySet) cachedKeys() []jose.JSONWebKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.keys
+}
+
+// fetchUnknownKey fetches the keys for a token signed with an unknown key,
+// unless they were fetched very recently.
+func (k *keySet) fetchUnknownKey() ([]jose.JSONWebKey, error) {
+	k.mutex.RLock()
+	recent := time.Since(k.lastFetch) < unknownKeyFetchInterval
+	keys := k.keys
+	k.mutex.RUnlock()
+
+	if recent {
+		return keys, nil
+	}
+	return k.fetch(false)
+}
+
+// fetch fetches the keys, after the discovery document if discover is true,
+// and returns the current keys.
+// The keys are kept when they have not changed, or cannot be fetched.
+func (k *keySet) fetch(discover bool) ([]jose.JSONWebKey, error) {
+	keys, err, _ := k.fetches.Do("keys", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(k.ctx, keysFetchTimeout)
+		defer cancel()
+
+		err := k.fetchKeys(ctx, discover)
+
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		k.lastFetch = time.Now()
+		return k.keys, err
+	})
+	return keys.([]jose.JSONWebKey), err
+}
+
+// fetchKeys fetches the discovery document when discover is true, and then
+// the keys when they changed.
+func (k *keySet) fetchKeys(ctx context.Context, discover bool) error {
+	if discover && k.discover != nil {
+		jwksURL, err := k.discover(requests.WithEndpoint(ctx, requests.ProviderEndpointDiscovery))
+		if err != nil {
+			return fmt.Errorf("failed to refresh OIDC discovery: %v", err)
+		}
+		if jwksURL != k.jwks.url {
+			logger.Printf("OIDC provider JWKs URL changed to %s", jwksURL)
+			k.jwks = &document{url: jwksURL}
+		}
+	}
+
+	_, err := k.jwks.fetch(ctx, func(body []byte) error {
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(body, &jwks); err != nil {
+			return fmt.Errorf("error unmarshalling keys: %v", err)
+		}
+
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		k.keys = jwks.Keys
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch OIDC provider keys: %v", err)
+	}
+	return nil
+}
+
+// verifyWithKeys verifies the signature of the JWS with the keys matching its
+// key ID, or all keys when it has none.
+func verifyWithKeys(jws *jose.JSONWebSignature, keys []jose.JSONWebKey) ([]byte, bool) {
+	keyID := ""
+	if len(jws.Signatures) > 0 {
+		keyID = jws.Signatures[0].Header.KeyID
+	}
+	for i := range keys {
+		if keyID != "" && keys[i].KeyID != keyID {
+			continue
+		}
+		if payload, err := jws.Verify(&keys[i]); err == nil {
+			return payload, true
+		}
+	}
+	return nil, false
+}
+
+// jitter returns the interval moved earlier or later by up to the refresh
+// jitter.
+func jitter(interval time.Duration) time.Duration {
+	return interval + time.Duration((rand.Float64()*2-1)*keysRefreshJitter*float64(interval))
+}
+
+// document is a document of the provider that is only downloaded again when
+// it changed, using the validators of the last response in conditional
+// requests.
+type document struct {
+	url          string
+	etag         string
+	lastModified string
+}
+
+// fetch requests the document, and parses its body when it changed since it
+// was last fetched. The validators of the response are only kept when its body
+// was parsed, so that a document that could not be parsed is downloaded again.
+// Returns whether the document changed.
+func (d *document) fetch(ctx context.Context, parse func([]byte) error) (bool, error) {
+	header := http.Header{}
+	if d.etag != "" {
+		header.Set("If-None-Match", d.etag)
+	}
+	if d.lastModified != "" {
+		header.Set("If-Modified-Since", d.lastModified)
+	}
+
+	result := requests.New(d.url).WithContext(ctx).WithHeaders(header).Do()
+	if result.Error() != nil {
+		return false, result.Error()
+	}
+	switch result.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status \"%d\": %s", result.StatusCode(), result.Body())
+	}
+
+	if err := parse(result.Body()); err != nil {
+		return false, err
+	}
+	d.etag = result.Headers().Get("ETag")
+	d.lastModified = result.Headers().Get("Last-Modified")
+	return true, nil
+}
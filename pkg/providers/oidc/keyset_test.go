@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// testKeysServer serves a JWKs document with an ETag, and records the
+// requests for it.
+type testKeysServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	keys     []jose.JSONWebKey
+	etag     string
+	failing  bool
+	requests []*http.Request
+}
+
+func newTestKeysServer() *testKeysServer {
+	s := &testKeysServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests = append(s.requests, req)
+
+		switch {
+		case s.failing:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case req.Header.Get("If-None-Match") == s.etag:
+			rw.WriteHeader(http.StatusNotModified)
+		default:
+			rw.Header().Set("ETag", s.etag)
+			Expect(json.NewEncoder(rw).Encode(jose.JSONWebKeySet{Keys: s.keys})).To(Succeed())
+		}
+	}))
+	return s
+}
+
+// setKeys replaces the keys served with the public keys of the signing keys.
+func (s *testKeysServer) setKeys(etag string, keys ...jose.JSONWebKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.etag = etag
+	s.keys = nil
+	for _, key := range keys {
+		s.keys = append(s.keys, key.Public())
+	}
+}
+
+func (s *testKeysServer) setFailing(failing bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failing = failing
+}
+
+func (s *testKeysServer) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+func (s *testKeysServer) lastRequest() *http.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+// newTestSigningKey generates an RSA signing key with the key ID.
+func newTestSigningKey(keyID string) jose.JSONWebKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return jose.JSONWebKey{Key: privateKey, Algorithm: string(jose.RS256), KeyID: keyID, Use: "sig"}
+}
+
+// signTestToken returns a JWT signed with the key.
+func signTestToken(key jose.JSONWebKey) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	Expect(err).ToNot(HaveOccurred())
+	jws, err := signer.Sign([]byte(`{"iss":"https://issuer.example.com"}`))
+	Expect(err).ToNot(HaveOccurred())
+	token, err := jws.CompactSerialize()
+	Expect(err).ToNot(HaveOccurred())
+	return token
+}
+
+var _ = Describe("KeySet", func() {
+	ctx := context.Background()
+
+	var server *testKeysServer
+	var firstKey, secondKey jose.JSONWebKey
+
+	BeforeEach(func() {
+		server = newTestKeysServer()
+		firstKey = newTestSigningKey("first")
+		secondKey = newTestSigningKey("second")
+		server.setKeys("v1", firstKey)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches the keys to verify the first token", func() {
+		k := newKeySet(ctx, server.URL, nil, 0, "")
+		defer k.Close()
+
+		payload, err := k.VerifySignature(ctx, signTestToken(firstKey))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(payload)).To(Equal(`{"iss":"https://issuer.example.com"}`))
+
+		_, err = k.VerifySignature(ctx, signTestToken(firstKey))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.requestCount()).To(Equal(1))
+	})
+
+	It("fetches the keys again with a conditional request", func() {
+		k := newKeySet(ctx, server.URL, nil, 0, "")
+		defer k.Close()
+
+		_, err := k.fetch(false)
+		Expect(err).ToNot(HaveOccurred())
+		keys, err := k.fetch(false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+		Expect(server.requestCount()).To(Equal(2))
+		Expect(server.lastRequest().Header.Get("If-None-Match")).To(Equal("v1"))
+	})
+
+	It("keeps the keys while the provider is failing", func() {
+		k := newKeySet(ctx, server.URL, nil, 0, "")
+		defer k.Close()
+
+		_, err := k.fetch(false)
+		Expect(err).ToNot(HaveOccurred())
+
+		server.setFailing(true)
+		keys, err := k.fetch(false)
+		Expect(err).To(MatchError(HavePrefix("failed to fetch OIDC provider keys: unexpected status \"503\"")))
+		Expect(keys).To(HaveLen(1))
+
+		_, err = k.VerifySignature(ctx, signTestToken(firstKey))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("fetches the keys for a token signed with an unknown key", func() {
+		k := newKeySet(ctx, server.URL, nil, 0, "")
+		defer k.Close()
+
+		_, err := k.fetch(false)
+		Expect(err).ToNot(HaveOccurred())
+		server.setKeys("v2", firstKey, secondKey)
+
+		By("not fetching the keys again just after they were fetched")
+		_, err = k.VerifySignature(ctx, signTestToken(secondKey))
+		Expect(err).To(MatchError("failed to verify id token signature"))
+		Expect(server.requestCount()).To(Equal(1))
+
+		By("fetching the keys once they were not fetched recently")
+		k.lastFetch = time.Now().Add(-unknownKeyFetchInterval)
+		_, err = k.VerifySignature(ctx, signTestToken(secondKey))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.requestCount()).To(Equal(2))
+	})
+
+	It("follows changes of the JWKs URL of the discovery document", func() {
+		otherServer := newTestKeysServer()
+		defer otherServer.Close()
+		otherServer.setKeys("v1", secondKey)
+
+		jwksURL := server.URL
+		k := newKeySet(ctx, server.URL, func(context.Context) (string, error) { return jwksURL, nil }, 0, "")
+		defer k.Close()
+
+		_, err := k.fetch(true)
+		Expect(err).ToNot(HaveOccurred())
+
+		jwksURL = otherServer.URL
+		keys, err := k.fetch(true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+		Expect(keys[0].KeyID).To(Equal("second"))
+		Expect(otherServer.lastRequest().Header.Get("If-None-Match")).To(BeEmpty())
+	})
+
+	It("refreshes the keys in the background until it is closed", func() {
+		k := newKeySet(ctx, server.URL, nil, 10*time.Millisecond, "oidc_keys:test")
+
+		Eventually(server.requestCount).Should(BeNumerically(">=", 2))
+		Expect(k.cachedKeys()).To(HaveLen(1))
+
+		k.Close()
+		// Wait for a refresh that may be running when the key set is closed
+		time.Sleep(50 * time.Millisecond)
+		count := server.requestCount()
+		Consistently(server.requestCount, 100*time.Millisecond).Should(Equal(count))
+	})
+})
@@ -2,6 +2,8 @@ package oidc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -16,6 +18,8 @@ type providerJSON struct {
 	TokenURL             string   `json:"token_endpoint"`
 	JWKsURL              string   `json:"jwks_uri"`
 	UserInfoURL          string   `json:"userinfo_endpoint"`
+	EndSessionURL        string   `json:"end_session_endpoint"`
+	RevocationURL        string   `json:"revocation_endpoint"`
 	CodeChallengeAlgs    []string `json:"code_challenge_methods_supported"`
 	SupportedSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
 }
@@ -23,10 +27,12 @@ type providerJSON struct {
 // Endpoints represents the endpoints discovered as part of the OIDC discovery process
 // that will be used by the authentication providers.
 type Endpoints struct {
-	AuthURL     string
-	TokenURL    string
-	JWKsURL     string
-	UserInfoURL string
+	AuthURL       string
+	TokenURL      string
+	JWKsURL       string
+	UserInfoURL   string
+	EndSessionURL string
+	RevocationURL string
 }
 
 // PKCE holds information relevant to the PKCE (code challenge) support of the
@@ -55,13 +61,12 @@ func NewProvider(ctx context.Context, issuerURL string, skipIssuerVerification b
 	logger.Printf("Performing OIDC Discovery...")
 
 	var p providerJSON
-	requestURL := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
-	if err := requests.New(requestURL).WithContext(ctx).Do().UnmarshalInto(&p); err != nil {
+	if err := requests.New(discoveryURL(issuerURL)).WithContext(ctx).Do().UnmarshalInto(&p); err != nil {
 		return nil, fmt.Errorf("failed to discover OIDC configuration: %v", err)
 	}
 
-	if !skipIssuerVerification && p.Issuer != issuerURL {
-		return nil, fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuerURL, p.Issuer)
+	if err := p.verifyIssuer(issuerURL, skipIssuerVerification); err != nil {
+		return nil, err
 	}
 
 	return &discoveryProvider{
@@ -69,17 +74,59 @@ func NewProvider(ctx context.Context, issuerURL string, skipIssuerVerification b
 		tokenURL:             p.TokenURL,
 		jwksURL:              p.JWKsURL,
 		userInfoURL:          p.UserInfoURL,
+		endSessionURL:        p.EndSessionURL,
+		revocationURL:        p.RevocationURL,
 		codeChallengeAlgs:    p.CodeChallengeAlgs,
 		supportedSigningAlgs: p.SupportedSigningAlgs,
 	}, nil
 }
 
+// newJWKsURLDiscovery returns a function that fetches the discovery document
+// of the issuer again, when it changed, and returns its JWKs URL.
+// The document is expected to have been discovered with the JWKs URL already.
+func newJWKsURLDiscovery(issuerURL string, skipIssuerVerification bool, jwksURL string) func(context.Context) (string, error) {
+	discovery := &document{url: discoveryURL(issuerURL)}
+	return func(ctx context.Context) (string, error) {
+		_, err := discovery.fetch(ctx, func(body []byte) error {
+			var p providerJSON
+			if err := json.Unmarshal(body, &p); err != nil {
+				return fmt.Errorf("error unmarshalling discovery document: %v", err)
+			}
+			if err := p.verifyIssuer(issuerURL, skipIssuerVerification); err != nil {
+				return err
+			}
+			if p.JWKsURL == "" {
+				return errors.New("discovery document has no jwks_uri")
+			}
+			jwksURL = p.JWKsURL
+			return nil
+		})
+		return jwksURL, err
+	}
+}
+
+// discoveryURL returns the URL of the discovery document of the issuer.
+func discoveryURL(issuerURL string) string {
+	return strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+}
+
+// verifyIssuer checks that the discovered issuer is the issuer URL, unless
+// issuer verification is skipped.
+func (p *providerJSON) verifyIssuer(issuerURL string, skipIssuerVerification bool) error {
+	if !skipIssuerVerification && p.Issuer != issuerURL {
+		return fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuerURL, p.Issuer)
+	}
+	return nil
+}
+
 // discoveryProvider holds the discovered endpoints
 type discoveryProvider struct {
 	authURL              string
 	tokenURL             string
 	jwksURL              string
 	userInfoURL          string
+	endSessionURL        string
+	revocationURL        string
 	codeChallengeAlgs    []string
 	supportedSigningAlgs []string
 }
@@ -87,10 +134,12 @@ type discoveryProvider struct {
 // Endpoints returns the discovered endpoints needed for an authentication provider.
 func (p *discoveryProvider) Endpoints() Endpoints {
 	return Endpoints{
-		AuthURL:     p.authURL,
-		TokenURL:    p.tokenURL,
-		JWKsURL:     p.jwksURL,
-		UserInfoURL: p.userInfoURL,
+		AuthURL:       p.authURL,
+		TokenURL:      p.tokenURL,
+		JWKsURL:       p.jwksURL,
+		UserInfoURL:   p.userInfoURL,
+		EndSessionURL: p.endSessionURL,
+		RevocationURL: p.revocationURL,
 	}
 }
 
@@ -122,6 +122,26 @@ var _ = Describe("Provider", func() {
 
 		Expect(provider.SupportedSigningAlgs()).To(ConsistOf("RS256", "HS256"))
 	})
+
+	It("with end session and revocation endpoints on the provider, should populate their URLs", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newLogoutIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().EndSessionURL).To(Equal(m.Issuer() + "/logout"))
+		Expect(provider.Endpoints().RevocationURL).To(Equal(m.Issuer() + "/revoke"))
+	})
 })
 
 func newInvalidIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
@@ -183,6 +203,27 @@ func newSigningAlgsIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) htt
 	}
 }
 
+func newLogoutIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:        m.Issuer(),
+				AuthURL:       m.AuthorizationEndpoint(),
+				TokenURL:      m.TokenEndpoint(),
+				JWKsURL:       m.JWKSEndpoint(),
+				UserInfoURL:   m.UserinfoEndpoint(),
+				EndSessionURL: m.Issuer() + "/logout",
+				RevocationURL: m.Issuer() + "/revoke",
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
+
 func newBadRequestMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
@@ -14,6 +15,7 @@ type ProviderVerifier interface {
 	DiscoveryEnabled() bool
 	Provider() DiscoveryProvider
 	Verifier() IDTokenVerifier
+	Close()
 }
 
 // ProviderVerifierOptions allows you to configure a ProviderVerifier
@@ -37,6 +39,15 @@ type ProviderVerifierOptions struct {
 	// eg: https://www.googleapis.com/oauth2/v3/certs
 	JWKsURL string
 
+	// KeysRefreshInterval is the interval at which the discovery document and
+	// the keys are fetched again in the background. When 0, the keys are only
+	// fetched when a token is signed with an unknown key.
+	KeysRefreshInterval time.Duration
+
+	// ProviderID identifies the provider in the metrics of the refreshes of
+	// its keys.
+	ProviderID string
+
 	// SkipDiscovery allows to skip OIDC discovery and use manually supplied Endpoints
 	SkipDiscovery bool
 
@@ -93,7 +104,7 @@ func NewProviderVerifier(ctx context.Context, opts ProviderVerifierOptions) (Pro
 		return nil, fmt.Errorf("invalid provider verifier options: %v", err)
 	}
 
-	verifierBuilder, provider, err := getVerifierBuilder(ctx, opts)
+	verifierBuilder, provider, keySet, err := getVerifierBuilder(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not get verifier builder: %v", err)
 	}
@@ -109,28 +120,33 @@ func NewProviderVerifier(ctx context.Context, opts ProviderVerifierOptions) (Pro
 		discoveryEnabled: !opts.SkipDiscovery,
 		provider:         provider,
 		verifier:         verifier,
+		keySet:           keySet,
 	}, nil
 }
 
 type verifierBuilder func(*oidc.Config) *oidc.IDTokenVerifier
 
-func getVerifierBuilder(ctx context.Context, opts ProviderVerifierOptions) (verifierBuilder, DiscoveryProvider, error) {
+func getVerifierBuilder(ctx context.Context, opts ProviderVerifierOptions) (verifierBuilder, DiscoveryProvider, *keySet, error) {
+	workerName := "oidc_keys:" + opts.ProviderID
 	if opts.SkipDiscovery {
 		// Instead of discovering the JWKs URK, it needs to be specified in the opts already
-		return newVerifierBuilder(ctx, opts.IssuerURL, opts.JWKsURL, opts.SupportedSigningAlgs), nil, nil
+		keySet := newKeySet(ctx, opts.JWKsURL, nil, opts.KeysRefreshInterval, workerName)
+		return newVerifierBuilder(opts.IssuerURL, keySet, opts.SupportedSigningAlgs), nil, keySet, nil
 	}
 
 	provider, err := NewProvider(ctx, opts.IssuerURL, opts.SkipIssuerVerification)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error while discovery OIDC configuration: %v", err)
+		return nil, nil, nil, fmt.Errorf("error while discovery OIDC configuration: %v", err)
 	}
-	verifierBuilder := newVerifierBuilder(ctx, opts.IssuerURL, provider.Endpoints().JWKsURL, provider.SupportedSigningAlgs())
-	return verifierBuilder, provider, nil
+	jwksURL := provider.Endpoints().JWKsURL
+	discover := newJWKsURLDiscovery(opts.IssuerURL, opts.SkipIssuerVerification, jwksURL)
+	keySet := newKeySet(ctx, jwksURL, discover, opts.KeysRefreshInterval, workerName)
+	verifierBuilder := newVerifierBuilder(opts.IssuerURL, keySet, provider.SupportedSigningAlgs())
+	return verifierBuilder, provider, keySet, nil
 }
 
 // newVerifierBuilder returns a function to create a IDToken verifier from an OIDC config.
-func newVerifierBuilder(ctx context.Context, issuerURL, jwksURL string, supportedSigningAlgs []string) verifierBuilder {
-	keySet := oidc.NewRemoteKeySet(ctx, jwksURL)
+func newVerifierBuilder(issuerURL string, keySet oidc.KeySet, supportedSigningAlgs []string) verifierBuilder {
 	return func(oidcConfig *oidc.Config) *oidc.IDTokenVerifier {
 		if len(supportedSigningAlgs) > 0 {
 			oidcConfig.SupportedSigningAlgs = supportedSigningAlgs
@@ -145,6 +161,7 @@ type providerVerifier struct {
 	discoveryEnabled bool
 	provider         DiscoveryProvider
 	verifier         IDTokenVerifier
+	keySet           *keySet
 }
 
 // DiscoveryEnabled returns whether the provider verifier was constructed
@@ -162,3 +179,8 @@ func (p *providerVerifier) Provider() DiscoveryProvider {
 func (p *providerVerifier) Verifier() IDTokenVerifier {
 	return p.verifier
 }
+
+// Close stops refreshing the keys of the provider in the background
+func (p *providerVerifier) Close() {
+	p.keySet.Close()
+}
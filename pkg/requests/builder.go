@@ -90,8 +90,8 @@ func (r *builder) Do() Result {
 	return r.do()
 }
 
-// do creates the request, executes it with the default client and extracts the
-// the body into the response
+// do creates the request, executes it with the client of the context, or the
+// default client, and extracts the the body into the response
 func (r *builder) do() Result {
 	req, err := http.NewRequestWithContext(r.context, r.method, r.endpoint, r.body)
 	if err != nil {
@@ -100,7 +100,7 @@ func (r *builder) do() Result {
 	}
 	req.Header = r.header
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := clientFromContext(r.context).Do(req)
 	if err != nil {
 		r.result = &result{err: fmt.Errorf("error performing request: %v", err)}
 		return r.result
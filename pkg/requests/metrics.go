@@ -0,0 +1,159 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The endpoints of the provider that outbound requests are labelled with.
+const (
+	ProviderEndpointDiscovery = "discovery"
+	ProviderEndpointJWKS      = "jwks"
+	ProviderEndpointRedeem    = "redeem"
+	ProviderEndpointRefresh   = "refresh"
+	ProviderEndpointValidate  = "validate"
+	ProviderEndpointProfile   = "profile"
+	ProviderEndpointRevoke    = "revoke"
+)
+
+type providerLabelsKey struct{}
+
+// providerLabels are the labels of the provider metrics of a request.
+type providerLabels struct {
+	provider string
+	endpoint string
+}
+
+// WithProviderEndpoint returns a context whose outbound requests are recorded
+// in the provider metrics as requests to the endpoint of the provider.
+func WithProviderEndpoint(ctx context.Context, provider, endpoint string) context.Context {
+	return context.WithValue(ctx, providerLabelsKey{}, providerLabels{provider: provider, endpoint: endpoint})
+}
+
+// WithEndpoint replaces the endpoint of the provider metrics of the context.
+// Contexts without a provider are returned unchanged.
+func WithEndpoint(ctx context.Context, endpoint string) context.Context {
+	labels, ok := ctx.Value(providerLabelsKey{}).(providerLabels)
+	if !ok {
+		return ctx
+	}
+	return WithProviderEndpoint(ctx, labels.provider, endpoint)
+}
+
+// instrumentedTransport records the latency and errors of requests made with
+// a context given by WithProviderEndpoint.
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewInstrumentedTransport wraps the transport to record the provider metrics
+// of requests to the registerer.
+func NewInstrumentedTransport(next http.RoundTripper, registerer prometheus.Registerer) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{
+		next:     next,
+		duration: registerProviderRequestsLatencyHistogram(registerer),
+		errors:   registerProviderRequestErrorsCounter(registerer),
+	}
+}
+
+// RoundTrip performs the request, recording its metrics when it is a request
+// to a provider.
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	labels, ok := req.Context().Value(providerLabelsKey{}).(providerLabels)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	observer := t.duration.WithLabelValues(labels.provider, labels.endpoint)
+	duration := time.Since(start).Seconds()
+
+	// Requests made on behalf of a request to the proxy have its trace as an
+	// exemplar
+	scope := middlewareapi.GetRequestScope(req)
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && scope != nil && scope.TraceID != "" {
+		eo.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": scope.TraceID})
+	} else {
+		observer.Observe(duration)
+	}
+
+	switch {
+	case err != nil:
+		t.errors.WithLabelValues(labels.provider, labels.endpoint, "error").Inc()
+		logger.Debugf(logger.ComponentProviders, "%s request to provider %q failed after %.3fs: %v", labels.endpoint, labels.provider, duration, err)
+		return resp, err
+	case resp.StatusCode >= http.StatusBadRequest:
+		t.errors.WithLabelValues(labels.provider, labels.endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	logger.Debugf(logger.ComponentProviders, "%s request to provider %q: %s %s %d in %.3fs", labels.endpoint, labels.provider, req.Method, req.URL, resp.StatusCode, duration)
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the transport it wraps.
+func (t *instrumentedTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// registerProviderRequestsLatencyHistogram registers the
+// 'oauth2_proxy_provider_request_duration_seconds' metric.
+// This keeps tally of the requests to each provider endpoint bucketed by the
+// time taken to respond.
+func registerProviderRequestsLatencyHistogram(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "oauth2_proxy_provider_request_duration_seconds",
+			Help:    "A histogram of the latencies of requests to the provider.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"provider", "endpoint"},
+	)
+
+	if err := registerer.Register(histogram); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			histogram = are.ExistingCollector.(*prometheus.HistogramVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return histogram
+}
+
+// registerProviderRequestErrorsCounter registers the
+// 'oauth2_proxy_provider_request_errors_total' metric.
+// This keeps a tally of the requests to each provider endpoint that failed,
+// by their HTTP response status code, or `error` when no response was
+// received.
+func registerProviderRequestErrorsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_provider_request_errors_total",
+			Help: "Total number of failed requests to the provider by HTTP status code.",
+		},
+		[]string{"provider", "endpoint", "code"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
@@ -0,0 +1,65 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Provider metrics", func() {
+	var registry *prometheus.Registry
+	var client *http.Client
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		client = &http.Client{Transport: NewInstrumentedTransport(nil, registry)}
+	})
+
+	get := func(ctx context.Context, path string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverAddr+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	It("records the latency and errors of requests to the provider", func() {
+		ctx := WithProviderEndpoint(context.Background(), "oidc", ProviderEndpointRedeem)
+		get(ctx, "/string/")
+		get(ctx, "/missing")
+		get(WithEndpoint(ctx, ProviderEndpointJWKS), "/string/")
+
+		Expect(testutil.GatherAndCount(registry, "oauth2_proxy_provider_request_duration_seconds")).To(Equal(2))
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP oauth2_proxy_provider_request_errors_total Total number of failed requests to the provider by HTTP status code.
+# TYPE oauth2_proxy_provider_request_errors_total counter
+oauth2_proxy_provider_request_errors_total{code="404",endpoint="redeem",provider="oidc"} 1
+`), "oauth2_proxy_provider_request_errors_total")).To(Succeed())
+	})
+
+	It("adds the trace of the request to the proxy as an exemplar", func() {
+		req := httptest.NewRequest("", "http://example.com/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"})
+		get(WithProviderEndpoint(req.Context(), "oidc", ProviderEndpointRedeem), "/string/")
+
+		metricsReq := httptest.NewRequest("", "http://example.com/metrics", nil)
+		metricsReq.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+		rw := httptest.NewRecorder()
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(rw, metricsReq)
+
+		Expect(rw.Body.String()).To(MatchRegexp(`oauth2_proxy_provider_request_duration_seconds_bucket\{endpoint="redeem",provider="oidc",le="[0-9.]+"\} 1 # \{trace_id="4bf92f3577b34da6a3ce929d0e0e4736"\}`))
+	})
+
+	It("does not record requests that are not to a provider", func() {
+		get(WithEndpoint(context.Background(), ProviderEndpointJWKS), "/string/")
+		Expect(testutil.GatherAndCount(registry, "oauth2_proxy_provider_request_duration_seconds")).To(Equal(0))
+	})
+})
@@ -0,0 +1,197 @@
+package requests
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
+)
+
+// TransportConfig configures the connections of the requests to the
+// providers.
+type TransportConfig struct {
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	KeepAlive             time.Duration
+	TLSSessionCacheSize   int
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	RequestTimeout        time.Duration
+
+	// MaxConcurrentRequests limits the requests made to all the providers at
+	// once, or is zero for no limit. Requests over the limit wait for up to
+	// the QueueTimeout and then fail.
+	MaxConcurrentRequests int
+	QueueTimeout          time.Duration
+}
+
+// providerTransports sends the requests to each provider with a transport of
+// its own, created from the base transport, so that a slow provider cannot
+// take all the connections and delay the requests to the others.
+// Requests made without a context given by WithProviderEndpoint share a
+// transport.
+type providerTransports struct {
+	base    *http.Transport
+	config  TransportConfig
+	limiter *concurrency.Limiter
+
+	mutex      sync.RWMutex
+	transports map[string]*http.Transport
+}
+
+// newProviderTransports creates the transports of the providers from the
+// base transport and the config.
+func newProviderTransports(base *http.Transport, config TransportConfig) *providerTransports {
+	return &providerTransports{
+		base:       base,
+		config:     config,
+		limiter:    concurrency.NewLimiter(concurrency.StageProvider, config.MaxConcurrentRequests, config.QueueTimeout),
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+// RoundTrip performs the request with the transport of its provider.
+// Requests over the concurrency limit fail with concurrency.ErrOverloaded.
+// The room taken by a request is freed once the body of its response is
+// closed.
+func (t *providerTransports) RoundTrip(req *http.Request) (*http.Response, error) {
+	labels, _ := req.Context().Value(providerLabelsKey{}).(providerLabels)
+	if t.limiter == nil {
+		return t.transport(labels.provider).RoundTrip(req)
+	}
+
+	release, err := t.limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.transport(labels.provider).RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnClose is a response body that frees the room taken by its request
+// when it is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body and frees the room taken by its request.
+func (b *releaseOnClose) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// CloseIdleConnections closes the idle connections of the transports of all
+// the providers.
+func (t *providerTransports) CloseIdleConnections() {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// transport returns the transport of the provider, creating it for the first
+// request to the provider.
+func (t *providerTransports) transport(provider string) *http.Transport {
+	t.mutex.RLock()
+	transport, ok := t.transports[provider]
+	t.mutex.RUnlock()
+	if ok {
+		return transport
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if transport, ok := t.transports[provider]; ok {
+		return transport
+	}
+	transport = newTransport(t.base, t.config)
+	t.transports[provider] = transport
+	return transport
+}
+
+// newTransport creates a transport with the proxy and TLS configuration of
+// the base transport, and the connections configured by the config.
+func newTransport(base *http.Transport, config TransportConfig) *http.Transport {
+	transport := base.Clone()
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+
+	if config.TLSSessionCacheSize > 0 {
+		if transport.TLSClientConfig == nil {
+			/* #nosec G402 */
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(config.TLSSessionCacheSize)
+	}
+	return transport
+}
+
+// NewProviderClient creates the client of the requests to the providers,
+// which sends the requests to each provider with a transport of its own
+// configured by the config, and records their provider metrics to the
+// default prometheus.Registry.
+// The transports are created from the transport of http.DefaultClient, which
+// holds the TLS configuration of the providers.
+func NewProviderClient(config TransportConfig) *http.Client {
+	var next http.RoundTripper
+	switch transport := http.DefaultClient.Transport.(type) {
+	case nil:
+		next = newProviderTransports(http.DefaultTransport.(*http.Transport), config)
+	case *http.Transport:
+		next = newProviderTransports(transport, config)
+	default:
+		// The transport is not ours to configure
+		next = transport
+	}
+
+	return &http.Client{
+		Transport: NewInstrumentedTransport(next, prometheus.DefaultRegisterer),
+		Timeout:   config.RequestTimeout,
+	}
+}
+
+// WithClient returns a context whose requests are made with the client.
+// The client is used by the requests of this package, as well as by the
+// OAuth2 and OIDC libraries, which read it from the same context value.
+// The context is returned unchanged when the client is nil.
+func WithClient(ctx context.Context, client *http.Client) context.Context {
+	if client == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
+
+// clientFromContext returns the client of the context given by WithClient,
+// or http.DefaultClient.
+func clientFromContext(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,172 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/concurrency"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+)
+
+var _ = Describe("Provider Transports", func() {
+	config := TransportConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       1,
+		IdleConnTimeout:       90 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSSessionCacheSize:   64,
+		DialTimeout:           30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+		RequestTimeout:        time.Minute,
+	}
+
+	It("configures the transport of each provider", func() {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		t := newProviderTransports(base, config)
+
+		transport := t.transport("provider")
+		Expect(t.transport("provider")).To(BeIdenticalTo(transport))
+		Expect(t.transport("other")).ToNot(BeIdenticalTo(transport))
+		Expect(t.transport("")).ToNot(BeIdenticalTo(transport))
+
+		Expect(transport.MaxIdleConns).To(Equal(100))
+		Expect(transport.MaxIdleConnsPerHost).To(Equal(10))
+		Expect(transport.MaxConnsPerHost).To(Equal(1))
+		Expect(transport.IdleConnTimeout).To(Equal(90 * time.Second))
+		Expect(transport.TLSHandshakeTimeout).To(Equal(10 * time.Second))
+		Expect(transport.ResponseHeaderTimeout).To(Equal(5 * time.Second))
+		Expect(transport.TLSClientConfig.ClientSessionCache).ToNot(BeNil())
+		// Cloning the base may give it a TLS config for HTTP/2, which must be
+		// left without the session cache
+		if base.TLSClientConfig != nil {
+			Expect(base.TLSClientConfig.ClientSessionCache).To(BeNil())
+		}
+	})
+
+	It("does not delay the requests of a provider behind a slow provider", func() {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/slow" {
+				<-release
+			}
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer slow.Close()
+		defer close(release)
+
+		t := newProviderTransports(http.DefaultTransport.(*http.Transport).Clone(), config)
+		client := &http.Client{Transport: t}
+
+		go func() {
+			defer GinkgoRecover()
+			ctx := WithProviderEndpoint(context.Background(), "slow", ProviderEndpointRedeem)
+			req, err := http.NewRequestWithContext(ctx, "GET", slow.URL+"/slow", nil)
+			Expect(err).ToNot(HaveOccurred())
+			resp, err := client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		// Wait for the slow request to take the only connection of its provider
+		Eventually(func() int {
+			t.mutex.RLock()
+			defer t.mutex.RUnlock()
+			return len(t.transports)
+		}).Should(Equal(1))
+
+		ctx, cancel := context.WithTimeout(WithProviderEndpoint(context.Background(), "fast", ProviderEndpointRedeem), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", slow.URL+"/fast", nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		resp.Body.Close()
+	})
+
+	It("limits the requests made to the providers at once", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		t := newProviderTransports(http.DefaultTransport.(*http.Transport).Clone(), TransportConfig{MaxConcurrentRequests: 1})
+		client := &http.Client{Transport: t}
+
+		// The room taken by a request is freed once its response body is closed
+		first, err := client.Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.Get(server.URL)
+		Expect(errors.Is(err, concurrency.ErrOverloaded)).To(BeTrue())
+
+		Expect(first.Body.Close()).To(Succeed())
+		second, err := client.Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second.Body.Close()).To(Succeed())
+	})
+
+	Context("NewProviderClient", func() {
+		var defaultClient *http.Client
+
+		BeforeEach(func() {
+			defaultClient = http.DefaultClient
+		})
+
+		AfterEach(func() {
+			http.DefaultClient = defaultClient
+		})
+
+		It("creates the transports from the transport of the default client", func() {
+			base := http.DefaultTransport.(*http.Transport).Clone()
+			http.DefaultClient = &http.Client{Transport: base}
+
+			client := NewProviderClient(config)
+			Expect(client.Timeout).To(Equal(time.Minute))
+			transports := client.Transport.(*instrumentedTransport).next.(*providerTransports)
+			Expect(transports.base).To(BeIdenticalTo(base))
+			Expect(transports.transport("provider").MaxConnsPerHost).To(Equal(1))
+
+			// The default client is left as it is
+			Expect(http.DefaultClient.Transport).To(BeIdenticalTo(base))
+		})
+
+		It("does not replace transports of other kinds", func() {
+			transport := &testRoundTripper{}
+			http.DefaultClient = &http.Client{Transport: transport}
+
+			client := NewProviderClient(config)
+			Expect(client.Transport.(*instrumentedTransport).next).To(BeIdenticalTo(transport))
+		})
+	})
+
+	Context("WithClient", func() {
+		It("makes the requests of the context with the client", func() {
+			client := &http.Client{Transport: &testRoundTripper{}}
+			ctx := WithClient(context.Background(), client)
+			Expect(clientFromContext(ctx)).To(BeIdenticalTo(client))
+			Expect(ctx.Value(oauth2.HTTPClient)).To(BeIdenticalTo(client))
+
+			err := New("http://example.com/").WithContext(ctx).Do().Error()
+			Expect(err).To(MatchError(ContainSubstring(http.ErrNotSupported.Error())))
+		})
+
+		It("uses the default client otherwise", func() {
+			Expect(WithClient(context.Background(), nil)).To(Equal(context.Background()))
+			Expect(clientFromContext(context.Background())).To(BeIdenticalTo(http.DefaultClient))
+		})
+	})
+})
+
+// testRoundTripper is a transport that is not an *http.Transport.
+type testRoundTripper struct{}
+
+func (*testRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, http.ErrNotSupported
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"net/http"
+	"strings"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 )
@@ -10,6 +11,8 @@ const (
 	XForwardedProto = "X-Forwarded-Proto"
 	XForwardedHost  = "X-Forwarded-Host"
 	XForwardedURI   = "X-Forwarded-Uri"
+
+	grpcContentType = "application/grpc"
 )
 
 // GetRequestProto returns the request scheme or X-Forwarded-Proto if present
@@ -57,3 +60,30 @@ func IsForwardedRequest(req *http.Request) bool {
 	return IsProxied(req) &&
 		req.Host != GetRequestHost(req)
 }
+
+// IsGRPCRequest determines if the request is a gRPC request based on its
+// content type.
+// gRPC-Web requests are not considered gRPC requests as they may be served
+// over HTTP/1.1.
+func IsGRPCRequest(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	return contentType == grpcContentType ||
+		strings.HasPrefix(contentType, grpcContentType+"+") ||
+		strings.HasPrefix(contentType, grpcContentType+";")
+}
+
+// IsWebSocketRequest determines if the request is a WebSocket upgrade
+// request.
+func IsWebSocketRequest(req *http.Request) bool {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -128,4 +128,43 @@ var _ = Describe("Util Suite", func() {
 			})
 		})
 	})
+
+	Context("IsGRPCRequest", func() {
+		It("returns false without a content type", func() {
+			Expect(util.IsGRPCRequest(req)).To(BeFalse())
+		})
+
+		It("returns true for gRPC requests", func() {
+			req.Header.Set("Content-Type", "application/grpc")
+			Expect(util.IsGRPCRequest(req)).To(BeTrue())
+		})
+
+		It("returns true for gRPC requests with a message encoding", func() {
+			req.Header.Set("Content-Type", "application/grpc+proto")
+			Expect(util.IsGRPCRequest(req)).To(BeTrue())
+		})
+
+		It("returns false for gRPC-Web requests", func() {
+			req.Header.Set("Content-Type", "application/grpc-web+proto")
+			Expect(util.IsGRPCRequest(req)).To(BeFalse())
+		})
+	})
+
+	Context("IsWebSocketRequest", func() {
+		It("returns false without an upgrade", func() {
+			Expect(util.IsWebSocketRequest(req)).To(BeFalse())
+		})
+
+		It("returns true for WebSocket upgrades", func() {
+			req.Header.Set("Connection", "keep-alive, Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			Expect(util.IsWebSocketRequest(req)).To(BeTrue())
+		})
+
+		It("returns false for other upgrades", func() {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "h2c")
+			Expect(util.IsWebSocketRequest(req)).To(BeFalse())
+		})
+	})
 })
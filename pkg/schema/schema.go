@@ -0,0 +1,172 @@
+// Package schema generates schemas for configuration structures from their Go
+// types, so that the schemas cannot drift from the structures they describe.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect of the generated JSON schemas.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema or OpenAPI v3 schema object.
+type Schema map[string]interface{}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JSONSchema generates a JSON Schema for the JSON encoding of the value given.
+// Unknown properties are not allowed, matching the strict loading of the
+// configuration.
+func JSONSchema(v interface{}) Schema {
+	g := &generator{visiting: make(map[reflect.Type]bool)}
+	s := g.schema(reflect.TypeOf(v))
+	s["$schema"] = JSONSchemaDraft
+	return s
+}
+
+// OpenAPISchema generates an OpenAPI v3 schema for the JSON encoding of the
+// value given, in the structural form required by the openAPIV3Schema of a
+// Kubernetes CustomResourceDefinition.
+func OpenAPISchema(v interface{}) Schema {
+	g := &generator{openAPI: true, visiting: make(map[reflect.Type]bool)}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// generator builds the schemas of types.
+type generator struct {
+	// openAPI generates OpenAPI v3 schemas rather than JSON Schemas.
+	openAPI bool
+
+	// visiting holds the struct types being generated, to stop recursive
+	// types from being expanded infinitely.
+	visiting map[reflect.Type]bool
+}
+
+// schema returns the schema of the JSON encoding of the type.
+func (g *generator) schema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Types with custom encodings, such as durations, are encoded as strings
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.integer(t, false)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return g.integer(t, true)
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.object(t)
+	default:
+		return g.anyValue()
+	}
+}
+
+// integer returns the schema of an integer type.
+func (g *generator) integer(t reflect.Type, unsigned bool) Schema {
+	s := Schema{"type": "integer"}
+	if unsigned {
+		s["minimum"] = 0
+	}
+	if g.openAPI {
+		if t.Bits() > 32 {
+			s["format"] = "int64"
+		} else {
+			s["format"] = "int32"
+		}
+	}
+	return s
+}
+
+// anyValue returns the schema that accepts any value.
+func (g *generator) anyValue() Schema {
+	if g.openAPI {
+		return Schema{"x-kubernetes-preserve-unknown-fields": true}
+	}
+	return Schema{}
+}
+
+// object returns the schema of a struct type.
+func (g *generator) object(t reflect.Type) Schema {
+	if g.visiting[t] {
+		return g.anyValue()
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := Schema{}
+	g.addProperties(t, properties)
+
+	s := Schema{"type": "object", "properties": properties}
+	if !g.openAPI {
+		s["additionalProperties"] = false
+	}
+	return s
+}
+
+// addProperties adds the schema of each field encoded in the JSON of the
+// struct type, including the fields of embedded structs. As in the JSON
+// encoding, the fields of the struct take precedence over embedded fields.
+func (g *generator) addProperties(t reflect.Type, properties Schema) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+
+	for _, e := range embedded {
+		embeddedProperties := Schema{}
+		g.addProperties(e, embeddedProperties)
+		for name, property := range embeddedProperties {
+			if _, ok := properties[name]; !ok {
+				properties[name] = property
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testEmbedded struct {
+	Embedded string `json:"embedded,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+type testRecursive struct {
+	Children []testRecursive `json:"children,omitempty"`
+}
+
+type testOptions struct {
+	testEmbedded `json:",omitempty"`
+
+	Name      string            `json:"name,omitempty"`
+	Enabled   *bool             `json:"enabled,omitempty"`
+	Count     int64             `json:"count,omitempty"`
+	Port      uint16            `json:"port,omitempty"`
+	Ratio     float64           `json:"ratio,omitempty"`
+	Values    []string          `json:"values,omitempty"`
+	Data      []byte            `json:"data,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timeout   options.Duration  `json:"timeout,omitempty"`
+	Recursive testRecursive     `json:"recursive,omitempty"`
+	Ignored   string            `json:"-"`
+}
+
+// toJSON round trips the schema through JSON so that it can be compared
+// with the expected JSON.
+func toJSON(s Schema) string {
+	data, err := json.Marshal(s)
+	Expect(err).ToNot(HaveOccurred())
+	return string(data)
+}
+
+var _ = Describe("Schema Suite", func() {
+	It("generates a JSON Schema", func() {
+		Expect(toJSON(JSONSchema(testOptions{}))).To(MatchJSON(`{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"embedded": {"type": "string"},
+				"name": {"type": "string"},
+				"enabled": {"type": "boolean"},
+				"count": {"type": "integer"},
+				"port": {"type": "integer", "minimum": 0},
+				"ratio": {"type": "number"},
+				"values": {"type": "array", "items": {"type": "string"}},
+				"data": {"type": "string", "format": "byte"},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"timeout": {"type": "string"},
+				"recursive": {
+					"type": "object",
+					"additionalProperties": false,
+					"properties": {
+						"children": {"type": "array", "items": {}}
+					}
+				}
+			}
+		}`))
+	})
+
+	It("generates an OpenAPI schema", func() {
+		Expect(toJSON(OpenAPISchema(testOptions{}))).To(MatchJSON(`{
+			"type": "object",
+			"properties": {
+				"embedded": {"type": "string"},
+				"name": {"type": "string"},
+				"enabled": {"type": "boolean"},
+				"count": {"type": "integer", "format": "int64"},
+				"port": {"type": "integer", "format": "int32", "minimum": 0},
+				"ratio": {"type": "number"},
+				"values": {"type": "array", "items": {"type": "string"}},
+				"data": {"type": "string", "format": "byte"},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"timeout": {"type": "string"},
+				"recursive": {
+					"type": "object",
+					"properties": {
+						"children": {"type": "array", "items": {"x-kubernetes-preserve-unknown-fields": true}}
+					}
+				}
+			}
+		}`))
+	})
+
+	It("describes the alpha options", func() {
+		s := JSONSchema(options.AlphaOptions{})
+		properties := s["properties"].(Schema)
+		for _, property := range []string{"upstreamConfig", "injectRequestHeaders", "injectResponseHeaders", "server", "metricsServer", "providers"} {
+			Expect(properties).To(HaveKey(property))
+		}
+
+		upstreams := properties["upstreamConfig"].(Schema)["properties"].(Schema)["upstreams"].(Schema)
+		upstream := upstreams["items"].(Schema)["properties"].(Schema)
+		Expect(upstream["flushInterval"]).To(Equal(Schema{"type": "string"}))
+	})
+})
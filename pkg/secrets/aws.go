@@ -0,0 +1,232 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/aws"
+)
+
+const (
+	awsSecretsManagerScheme  = "aws-sm"
+	awsParameterStoreScheme  = "aws-ssm"
+	awsRequestTimeout        = 10 * time.Second
+	awsJSONContentType       = "application/x-amz-json-1.1"
+	awsSecretsManagerService = "secretsmanager"
+	awsParameterStoreService = "ssm"
+)
+
+// The environment variables that override the endpoints of the AWS services,
+// following the conventions of the AWS SDKs.
+const (
+	AWSSecretsManagerEndpointEnv = "AWS_ENDPOINT_URL_SECRETS_MANAGER"
+	AWSParameterStoreEndpointEnv = "AWS_ENDPOINT_URL_SSM"
+)
+
+// awsClient calls the JSON API of an AWS service, signing requests with the
+// credentials of the ambient IAM role.
+type awsClient struct {
+	client      *http.Client
+	credentials *aws.CredentialsProvider
+	service     string
+
+	// endpoint overrides the regional endpoint of the service when set.
+	endpoint string
+}
+
+func newAWSClient(service, endpointEnv string) *awsClient {
+	client := &http.Client{Timeout: awsRequestTimeout}
+	return &awsClient{
+		client:      client,
+		credentials: aws.NewCredentialsProvider(client),
+		service:     service,
+		endpoint:    os.Getenv(endpointEnv),
+	}
+}
+
+// call calls the action of the service in the region, given by its target,
+// and decodes the response.
+func (c *awsClient) call(ctx context.Context, region, target string, input, output interface{}) error {
+	if region == "" {
+		return fmt.Errorf("no region given, set the %s environment variable", aws.RegionEnv)
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", c.service, region)
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", awsJSONContentType)
+	req.Header.Set("X-Amz-Target", target)
+	if err := c.credentials.Sign(req, region, c.service, body); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Type         string `json:"__type"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Type != "" {
+			// The type may be prefixed with a namespace, eg. `com.amazon.coral.service#`
+			errType := errResp.Type[strings.LastIndex(errResp.Type, "#")+1:]
+			return fmt.Errorf("unexpected status %d: %s: %s", resp.StatusCode, errType, errResp.Message+errResp.MessageUpper)
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(respBody, output); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	return nil
+}
+
+// regionFor returns the region of the ARN of a resource, or the region of the
+// environment when the resource is given by its name.
+func regionFor(id string) string {
+	if strings.HasPrefix(id, "arn:") {
+		if parts := strings.SplitN(id, ":", 6); len(parts) == 6 && parts[3] != "" {
+			return parts[3]
+		}
+	}
+	return aws.Region()
+}
+
+// splitField splits the field from the end of the reference.
+func splitField(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// selectJSONField returns the field of a secret whose value is a JSON object,
+// or the value itself when no field is given.
+func selectJSONField(value, field string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return "", fmt.Errorf("field %q given but the secret is not a JSON object", field)
+	}
+	return selectField(data, field)
+}
+
+// secretsManagerResolver resolves `aws-sm:<secret-id>#<field>` references
+// with AWS Secrets Manager. The secret ID is the name or ARN of the secret,
+// and the field selects a key of a secret stored as a JSON object.
+type secretsManagerResolver struct {
+	*awsClient
+}
+
+func newSecretsManagerResolverFromEnv() (Resolver, error) {
+	return &secretsManagerResolver{newAWSClient(awsSecretsManagerService, AWSSecretsManagerEndpointEnv)}, nil
+}
+
+// Resolve reads the current version of the secret, or the version given by a
+// `?version-stage=<stage>` or `?version-id=<id>` suffix on the secret ID.
+func (r *secretsManagerResolver) Resolve(ctx context.Context, ref string) (*Secret, error) {
+	id, field := splitField(ref)
+	input := map[string]string{}
+	if i := strings.Index(id, "?"); i >= 0 {
+		query, err := url.ParseQuery(id[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %v", err)
+		}
+		id = id[:i]
+		if stage := query.Get("version-stage"); stage != "" {
+			input["VersionStage"] = stage
+		}
+		if versionID := query.Get("version-id"); versionID != "" {
+			input["VersionId"] = versionID
+		}
+	}
+	if id == "" {
+		return nil, errors.New("the reference must be of the form aws-sm:<secret-id>#<field>")
+	}
+	input["SecretId"] = id
+
+	var output struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+	}
+	if err := r.call(ctx, regionFor(id), "secretsmanager.GetSecretValue", input, &output); err != nil {
+		return nil, err
+	}
+
+	value := string(output.SecretBinary)
+	if output.SecretString != nil {
+		value = *output.SecretString
+	}
+	value, err := selectJSONField(value, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	return &Secret{Value: value}, nil
+}
+
+// parameterStoreResolver resolves `aws-ssm:<parameter>#<field>` references
+// with AWS Systems Manager Parameter Store. The parameter is the name or ARN
+// of the parameter, optionally with a `:<version>` or `:<label>` selector.
+// SecureString parameters are decrypted.
+type parameterStoreResolver struct {
+	*awsClient
+}
+
+func newParameterStoreResolverFromEnv() (Resolver, error) {
+	return &parameterStoreResolver{newAWSClient(awsParameterStoreService, AWSParameterStoreEndpointEnv)}, nil
+}
+
+// Resolve reads the value of the parameter.
+func (r *parameterStoreResolver) Resolve(ctx context.Context, ref string) (*Secret, error) {
+	name, field := splitField(ref)
+	if name == "" {
+		return nil, errors.New("the reference must be of the form aws-ssm:<parameter>")
+	}
+
+	input := map[string]interface{}{
+		"Name":           name,
+		"WithDecryption": true,
+	}
+	var output struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := r.call(ctx, regionFor(name), "AmazonSSM.GetParameter", input, &output); err != nil {
+		return nil, err
+	}
+
+	value, err := selectJSONField(output.Parameter.Value, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &Secret{Value: value}, nil
+}
@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/aws"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AWS", func() {
+	envs := []string{aws.AccessKeyIDEnv, aws.SecretAccessKeyEnv, aws.RegionEnv}
+	saved := map[string]string{}
+
+	var server *httptest.Server
+	var inputs []map[string]interface{}
+
+	BeforeEach(func() {
+		for _, env := range envs {
+			if value, ok := os.LookupEnv(env); ok {
+				saved[env] = value
+			}
+		}
+		Expect(os.Setenv(aws.AccessKeyIDEnv, "AKIDEXAMPLE")).To(Succeed())
+		Expect(os.Setenv(aws.SecretAccessKeyEnv, "secret")).To(Succeed())
+		Expect(os.Setenv(aws.RegionEnv, "eu-west-1")).To(Succeed())
+
+		inputs = nil
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			Expect(req.Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+			var input map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&input)).To(Succeed())
+			inputs = append(inputs, input)
+
+			switch req.Header.Get("X-Amz-Target") {
+			case "secretsmanager.GetSecretValue":
+				switch input["SecretId"] {
+				case "oauth2-proxy":
+					rw.Write([]byte(`{"Name":"oauth2-proxy","SecretString":"{\"client_secret\":\"sm-client-secret\",\"port\":6379}"}`))
+				case "binary":
+					rw.Write([]byte(`{"Name":"binary","SecretBinary":"YmluYXJ5LXNlY3JldA=="}`))
+				default:
+					rw.WriteHeader(http.StatusBadRequest)
+					rw.Write([]byte(`{"__type":"ResourceNotFoundException","Message":"Secrets Manager can't find the specified secret."}`))
+				}
+			case "AmazonSSM.GetParameter":
+				Expect(input["WithDecryption"]).To(BeTrue())
+				rw.Write([]byte(`{"Parameter":{"Name":"/oauth2-proxy/cookie-secret","Type":"SecureString","Value":"ssm-cookie-secret"}}`))
+			default:
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		for _, env := range envs {
+			Expect(os.Unsetenv(env)).To(Succeed())
+		}
+		for env, value := range saved {
+			Expect(os.Setenv(env, value)).To(Succeed())
+		}
+	})
+
+	newClient := func(service string) *awsClient {
+		return &awsClient{
+			client:      server.Client(),
+			credentials: aws.NewCredentialsProvider(server.Client()),
+			service:     service,
+			endpoint:    server.URL,
+		}
+	}
+
+	DescribeTable("resolves Secrets Manager references",
+		func(ref, expected string) {
+			r := &secretsManagerResolver{newClient(awsSecretsManagerService)}
+			secret, err := r.Resolve(context.Background(), ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*secret).To(Equal(Secret{Value: expected}))
+		},
+		Entry("with a field", "oauth2-proxy#client_secret", "sm-client-secret"),
+		Entry("with a non-string field", "oauth2-proxy#port", "6379"),
+		Entry("without a field", "oauth2-proxy", `{"client_secret":"sm-client-secret","port":6379}`),
+		Entry("with a binary secret", "binary", "binary-secret"),
+	)
+
+	It("passes the version stage of Secrets Manager references", func() {
+		r := &secretsManagerResolver{newClient(awsSecretsManagerService)}
+		_, err := r.Resolve(context.Background(), "oauth2-proxy?version-stage=AWSPREVIOUS#client_secret")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(inputs).To(ConsistOf(map[string]interface{}{"SecretId": "oauth2-proxy", "VersionStage": "AWSPREVIOUS"}))
+	})
+
+	It("returns the error of Secrets Manager", func() {
+		r := &secretsManagerResolver{newClient(awsSecretsManagerService)}
+		_, err := r.Resolve(context.Background(), "missing#client_secret")
+		Expect(err).To(MatchError("unexpected status 400: ResourceNotFoundException: Secrets Manager can't find the specified secret."))
+	})
+
+	It("resolves Parameter Store references", func() {
+		r := &parameterStoreResolver{newClient(awsParameterStoreService)}
+		secret, err := r.Resolve(context.Background(), "/oauth2-proxy/cookie-secret")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*secret).To(Equal(Secret{Value: "ssm-cookie-secret"}))
+		Expect(inputs[0]["Name"]).To(Equal("/oauth2-proxy/cookie-secret"))
+	})
+
+	It("takes the region from ARNs", func() {
+		Expect(regionFor("arn:aws:secretsmanager:us-east-2:123456789012:secret:oauth2-proxy-AbCdEf")).To(Equal("us-east-2"))
+		Expect(regionFor("oauth2-proxy")).To(Equal("eu-west-1"))
+	})
+})
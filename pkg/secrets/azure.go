@@ -0,0 +1,287 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	azureKeyVaultScheme     = "azure-kv"
+	azureKeyVaultResource   = "https://vault.azure.net"
+	azureKeyVaultAPIVersion = "7.4"
+	azureKeyVaultDNSSuffix  = ".vault.azure.net"
+	azureRequestTimeout     = 10 * time.Second
+
+	azureInstanceMetadataEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureDefaultAuthorityHost     = "https://login.microsoftonline.com/"
+
+	// azureTokenExpiryWindow is how long before they expire that access
+	// tokens are refreshed.
+	azureTokenExpiryWindow = 5 * time.Minute
+)
+
+// The environment variables used to select the managed identity, following
+// the conventions of the Azure SDKs and the Azure workload identity webhook.
+const (
+	AzureClientIDEnv           = "AZURE_CLIENT_ID"
+	AzureTenantIDEnv           = "AZURE_TENANT_ID"
+	AzureFederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+	AzureAuthorityHostEnv      = "AZURE_AUTHORITY_HOST"
+	AzureIdentityEndpointEnv   = "IDENTITY_ENDPOINT"
+	AzureIdentityHeaderEnv     = "IDENTITY_HEADER"
+)
+
+// azureKeyVaultResolver resolves `azure-kv:<vault>/<secret>[/<version>]#<field>`
+// references with Azure Key Vault, authenticated with a managed identity.
+type azureKeyVaultResolver struct {
+	client *http.Client
+
+	// vaultEndpoint overrides the endpoint of the vaults when set.
+	vaultEndpoint string
+
+	clientID string
+
+	// federatedTokenFile, tenantID and authorityHost configure workload
+	// identity, which exchanges the service account token of the pod for an
+	// access token.
+	federatedTokenFile string
+	tenantID           string
+	authorityHost      string
+
+	// identityEndpoint and identityHeader configure the managed identity
+	// endpoint of App Service and Container Apps.
+	identityEndpoint string
+	identityHeader   string
+
+	// metadataEndpoint is the managed identity endpoint of the instance
+	// metadata service, used by virtual machines and AKS nodes.
+	metadataEndpoint string
+
+	mutex        sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+// newAzureKeyVaultResolverFromEnv creates an azureKeyVaultResolver that
+// authenticates with the managed identity found in the environment: a
+// workload identity, the identity endpoint of App Service, or the instance
+// metadata service. AZURE_CLIENT_ID selects a user assigned identity.
+func newAzureKeyVaultResolverFromEnv() (Resolver, error) {
+	r := newAzureKeyVaultResolver(&http.Client{Timeout: azureRequestTimeout})
+	r.clientID = os.Getenv(AzureClientIDEnv)
+	r.tenantID = os.Getenv(AzureTenantIDEnv)
+	r.federatedTokenFile = os.Getenv(AzureFederatedTokenFileEnv)
+	if host := os.Getenv(AzureAuthorityHostEnv); host != "" {
+		r.authorityHost = host
+	}
+	r.identityEndpoint = os.Getenv(AzureIdentityEndpointEnv)
+	r.identityHeader = os.Getenv(AzureIdentityHeaderEnv)
+	if r.federatedTokenFile != "" && (r.clientID == "" || r.tenantID == "") {
+		return nil, fmt.Errorf("the %s and %s environment variables must be set for workload identity", AzureClientIDEnv, AzureTenantIDEnv)
+	}
+	return r, nil
+}
+
+func newAzureKeyVaultResolver(client *http.Client) *azureKeyVaultResolver {
+	return &azureKeyVaultResolver{
+		client:           client,
+		authorityHost:    azureDefaultAuthorityHost,
+		metadataEndpoint: azureInstanceMetadataEndpoint,
+	}
+}
+
+// Resolve reads the secret from the vault. The vault is given by its name,
+// or by its host name for vaults outside of the public cloud. The latest
+// version of the secret is read unless the reference pins a version. The
+// field selects a key of a secret stored as a JSON object, and may be
+// omitted to use the whole secret.
+func (r *azureKeyVaultResolver) Resolve(ctx context.Context, ref string) (*Secret, error) {
+	name, field := splitField(ref)
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("the reference must be of the form azure-kv:<vault>/<secret>[/<version>]")
+	}
+
+	endpoint := r.vaultEndpoint
+	if endpoint == "" {
+		host := parts[0]
+		if !strings.Contains(host, ".") {
+			host += azureKeyVaultDNSSuffix
+		}
+		endpoint = "https://" + host
+	}
+	secretURL := strings.TrimSuffix(endpoint, "/") + "/secrets/" + strings.Join(parts[1:], "/") + "?api-version=" + azureKeyVaultAPIVersion
+
+	token, err := r.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := r.do(req, &secret); err != nil {
+		return nil, err
+	}
+
+	value, err := selectJSONField(secret.Value, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &Secret{Value: value}, nil
+}
+
+// azureToken is an access token response. The expiry is given as a number
+// or a string depending on the endpoint.
+type azureToken struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+// expires returns when the token expires.
+func (t *azureToken) expires(now time.Time) time.Time {
+	if expiresOn, err := strconv.ParseInt(string(t.ExpiresOn), 10, 64); err == nil {
+		return time.Unix(expiresOn, 0)
+	}
+	if expiresIn, err := strconv.ParseInt(string(t.ExpiresIn), 10, 64); err == nil {
+		return now.Add(time.Duration(expiresIn) * time.Second)
+	}
+	return now
+}
+
+// getToken returns an access token for Key Vault, requesting a new token
+// when there is none or it is about to expire.
+func (r *azureKeyVaultResolver) getToken(ctx context.Context) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.token != "" && time.Until(r.tokenExpires) > azureTokenExpiryWindow {
+		return r.token, nil
+	}
+
+	req, err := r.tokenRequest(ctx)
+	if err != nil {
+		return "", err
+	}
+	token := &azureToken{}
+	if err := r.do(req, token); err != nil {
+		return "", fmt.Errorf("could not get managed identity token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("could not get managed identity token: no token returned")
+	}
+
+	r.token = token.AccessToken
+	r.tokenExpires = token.expires(time.Now())
+	return r.token, nil
+}
+
+// tokenRequest creates the request for an access token from the managed
+// identity configured in the environment.
+func (r *azureKeyVaultResolver) tokenRequest(ctx context.Context) (*http.Request, error) {
+	switch {
+	case r.federatedTokenFile != "":
+		// Federated tokens are rotated, so the token is read for each request
+		assertion, err := ioutil.ReadFile(r.federatedTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read federated token: %v", err)
+		}
+		form := url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_id":             {r.clientID},
+			"scope":                 {azureKeyVaultResource + "/.default"},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+		}
+		tokenURL := strings.TrimSuffix(r.authorityHost, "/") + "/" + url.PathEscape(r.tenantID) + "/oauth2/v2.0/token"
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+
+	case r.identityEndpoint != "" && r.identityHeader != "":
+		req, err := r.managedIdentityRequest(ctx, r.identityEndpoint, "2019-08-01")
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-IDENTITY-HEADER", r.identityHeader)
+		return req, nil
+
+	default:
+		req, err := r.managedIdentityRequest(ctx, r.metadataEndpoint, "2018-02-01")
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+		return req, nil
+	}
+}
+
+func (r *azureKeyVaultResolver) managedIdentityRequest(ctx context.Context, endpoint, apiVersion string) (*http.Request, error) {
+	query := url.Values{
+		"api-version": {apiVersion},
+		"resource":    {azureKeyVaultResource},
+	}
+	if r.clientID != "" {
+		query.Set("client_id", r.clientID)
+	}
+	return http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+}
+
+// do sends the request and decodes the response.
+func (r *azureKeyVaultResolver) do(req *http.Request, into interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error            json.RawMessage `json:"error"`
+			ErrorDescription string          `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errResp) == nil {
+			// Key Vault returns an error object, the identity endpoints an
+			// error code with a description
+			var vaultErr struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(errResp.Error, &vaultErr) == nil && vaultErr.Code != "" {
+				return fmt.Errorf("unexpected status %d: %s: %s", resp.StatusCode, vaultErr.Code, vaultErr.Message)
+			}
+			if errResp.ErrorDescription != "" {
+				return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, errResp.ErrorDescription)
+			}
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, into); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	return nil
+}
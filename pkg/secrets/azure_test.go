@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Azure Key Vault", func() {
+	var server *httptest.Server
+	var tokenRequests []*http.Request
+
+	BeforeEach(func() {
+		tokenRequests = nil
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metadata/identity/oauth2/token", func(rw http.ResponseWriter, req *http.Request) {
+			tokenRequests = append(tokenRequests, req)
+			if req.Header.Get("Metadata") != "true" {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.Write([]byte(`{"access_token":"imds-token","expires_in":"3599","expires_on":"4102444800","resource":"https://vault.azure.net","token_type":"Bearer"}`))
+		})
+		mux.HandleFunc("/tenant/oauth2/v2.0/token", func(rw http.ResponseWriter, req *http.Request) {
+			tokenRequests = append(tokenRequests, req)
+			Expect(req.ParseForm()).To(Succeed())
+			Expect(req.PostForm.Get("client_assertion")).To(Equal("federated-token"))
+			Expect(req.PostForm.Get("scope")).To(Equal("https://vault.azure.net/.default"))
+			rw.Write([]byte(`{"access_token":"workload-token","expires_in":3599,"token_type":"Bearer"}`))
+		})
+		mux.HandleFunc("/secrets/", func(rw http.ResponseWriter, req *http.Request) {
+			Expect(req.URL.Query().Get("api-version")).To(Equal("7.4"))
+			if auth := req.Header.Get("Authorization"); auth != "Bearer imds-token" && auth != "Bearer workload-token" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch req.URL.Path {
+			case "/secrets/client-secret":
+				rw.Write([]byte(`{"value":"azure-client-secret","id":"https://my-vault.vault.azure.net/secrets/client-secret/abc"}`))
+			case "/secrets/client-secret/1234":
+				rw.Write([]byte(`{"value":"old-client-secret","id":"https://my-vault.vault.azure.net/secrets/client-secret/1234"}`))
+			case "/secrets/credentials":
+				rw.Write([]byte(`{"value":"{\"password\":\"azure-password\"}"}`))
+			default:
+				rw.WriteHeader(http.StatusNotFound)
+				rw.Write([]byte(`{"error":{"code":"SecretNotFound","message":"A secret with (name/id) missing was not found in this key vault."}}`))
+			}
+		})
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newResolver := func() *azureKeyVaultResolver {
+		r := newAzureKeyVaultResolver(server.Client())
+		r.vaultEndpoint = server.URL
+		r.metadataEndpoint = server.URL + "/metadata/identity/oauth2/token"
+		return r
+	}
+
+	DescribeTable("resolves references",
+		func(ref, expected string) {
+			secret, err := newResolver().Resolve(context.Background(), ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*secret).To(Equal(Secret{Value: expected}))
+		},
+		Entry("with the latest version", "my-vault/client-secret", "azure-client-secret"),
+		Entry("with a pinned version", "my-vault/client-secret/1234", "old-client-secret"),
+		Entry("with a field", "my-vault/credentials#password", "azure-password"),
+	)
+
+	DescribeTable("returns errors",
+		func(ref, expected string) {
+			_, err := newResolver().Resolve(context.Background(), ref)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("with an invalid reference", "client-secret",
+			"the reference must be of the form azure-kv:<vault>/<secret>[/<version>]"),
+		Entry("with a missing secret", "my-vault/missing",
+			"unexpected status 404: SecretNotFound: A secret with (name/id) missing was not found in this key vault."),
+	)
+
+	It("caches the managed identity token, and requests the user assigned identity", func() {
+		r := newResolver()
+		r.clientID = "client-id"
+		_, err := r.Resolve(context.Background(), "my-vault/client-secret")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = r.Resolve(context.Background(), "my-vault/credentials#password")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(tokenRequests).To(HaveLen(1))
+		Expect(tokenRequests[0].URL.Query().Get("client_id")).To(Equal("client-id"))
+		Expect(tokenRequests[0].URL.Query().Get("resource")).To(Equal("https://vault.azure.net"))
+	})
+
+	It("exchanges the federated token of a workload identity", func() {
+		tokenFile, err := ioutil.TempFile("", "oauth2-proxy-test-federated-token")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(tokenFile.Name())
+		_, err = tokenFile.Write([]byte("federated-token\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tokenFile.Close()).To(Succeed())
+
+		r := newResolver()
+		r.clientID = "client-id"
+		r.tenantID = "tenant"
+		r.federatedTokenFile = tokenFile.Name()
+		r.authorityHost = server.URL + "/"
+
+		secret, err := r.Resolve(context.Background(), "my-vault/client-secret")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secret.Value).To(Equal("azure-client-secret"))
+		Expect(tokenRequests).To(HaveLen(1))
+		Expect(tokenRequests[0].URL.Path).To(Equal("/tenant/oauth2/v2.0/token"))
+	})
+})
@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcpSecretManagerScheme   = "gcp-sm"
+	gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	gcpCloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+	gcpRequestTimeout        = 10 * time.Second
+)
+
+// gcpSecretManagerResolver resolves
+// `gcp-sm:projects/<project>/secrets/<secret>[/versions/<version>]#<field>`
+// references with GCP Secret Manager.
+type gcpSecretManagerResolver struct {
+	client   *http.Client
+	endpoint string
+}
+
+// newGCPSecretManagerResolverFromEnv creates a gcpSecretManagerResolver
+// authenticated with the application default credentials, such as those of
+// the GKE workload identity of the pod.
+func newGCPSecretManagerResolverFromEnv() (Resolver, error) {
+	client, err := google.DefaultClient(context.Background(), gcpCloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("could not create Secret Manager client: %v", err)
+	}
+	client.Timeout = gcpRequestTimeout
+	return &gcpSecretManagerResolver{client: client, endpoint: gcpSecretManagerEndpoint}, nil
+}
+
+// Resolve accesses the version of the secret, which is the latest version
+// unless the reference pins one. The field selects a key of a secret stored
+// as a JSON object, and may be omitted to use the whole secret.
+func (r *gcpSecretManagerResolver) Resolve(ctx context.Context, ref string) (*Secret, error) {
+	name, field := splitField(ref)
+	name, err := gcpSecretVersionName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(r.endpoint, "/")+"/v1/"+name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var access struct {
+		Payload struct {
+			Data       []byte `json:"data"`
+			DataCrc32c string `json:"dataCrc32c"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &access); err != nil {
+		return nil, fmt.Errorf("could not decode response: %v", err)
+	}
+	if access.Payload.DataCrc32c != "" {
+		checksum, err := strconv.ParseUint(access.Payload.DataCrc32c, 10, 32)
+		if err != nil || uint32(checksum) != crc32.Checksum(access.Payload.Data, crc32.MakeTable(crc32.Castagnoli)) {
+			return nil, fmt.Errorf("%s: the checksum of the secret does not match", name)
+		}
+	}
+
+	value, err := selectJSONField(string(access.Payload.Data), field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &Secret{Value: value}, nil
+}
+
+// gcpSecretVersionName returns the resource name of the secret version,
+// defaulting to the latest version.
+func gcpSecretVersionName(name string) (string, error) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets" && parts[1] != "" && parts[3] != "":
+		return strings.Join(append(parts, "versions", "latest"), "/"), nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions" && parts[1] != "" && parts[3] != "" && parts[5] != "":
+		return strings.Join(parts, "/"), nil
+	default:
+		return "", errors.New("the reference must be of the form gcp-sm:projects/<project>/secrets/<secret>[/versions/<version>]")
+	}
+}
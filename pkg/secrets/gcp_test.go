@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GCP Secret Manager", func() {
+	var server *httptest.Server
+	var paths []string
+
+	payload := func(data string, checksum uint32) string {
+		return fmt.Sprintf(`{"name":"projects/123/secrets/oauth2-proxy/versions/2","payload":{"data":"%s","dataCrc32c":"%d"}}`,
+			base64.StdEncoding.EncodeToString([]byte(data)), checksum)
+	}
+	checksum := func(data string) uint32 {
+		return crc32.Checksum([]byte(data), crc32.MakeTable(crc32.Castagnoli))
+	}
+
+	BeforeEach(func() {
+		paths = nil
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			paths = append(paths, req.URL.Path)
+			switch req.URL.Path {
+			case "/v1/projects/my-project/secrets/oauth2-proxy/versions/latest:access":
+				data := `{"client_secret":"gcp-client-secret"}`
+				rw.Write([]byte(payload(data, checksum(data))))
+			case "/v1/projects/my-project/secrets/oauth2-proxy/versions/1:access":
+				rw.Write([]byte(payload("old-secret", checksum("old-secret"))))
+			case "/v1/projects/my-project/secrets/corrupt/versions/latest:access":
+				rw.Write([]byte(payload("corrupt", checksum("secret"))))
+			default:
+				rw.WriteHeader(http.StatusNotFound)
+				rw.Write([]byte(`{"error":{"code":404,"message":"Secret [projects/my-project/secrets/missing] not found.","status":"NOT_FOUND"}}`))
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newResolver := func() *gcpSecretManagerResolver {
+		return &gcpSecretManagerResolver{client: server.Client(), endpoint: server.URL}
+	}
+
+	DescribeTable("resolves references",
+		func(ref, expected string) {
+			secret, err := newResolver().Resolve(context.Background(), ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*secret).To(Equal(Secret{Value: expected}))
+		},
+		Entry("with the latest version", "projects/my-project/secrets/oauth2-proxy", `{"client_secret":"gcp-client-secret"}`),
+		Entry("with a field", "projects/my-project/secrets/oauth2-proxy#client_secret", "gcp-client-secret"),
+		Entry("with a pinned version", "projects/my-project/secrets/oauth2-proxy/versions/1", "old-secret"),
+	)
+
+	DescribeTable("returns errors",
+		func(ref, expected string) {
+			_, err := newResolver().Resolve(context.Background(), ref)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("with an invalid reference", "my-project/oauth2-proxy",
+			"the reference must be of the form gcp-sm:projects/<project>/secrets/<secret>[/versions/<version>]"),
+		Entry("with a missing secret", "projects/my-project/secrets/missing",
+			"unexpected status 404: Secret [projects/my-project/secrets/missing] not found."),
+		Entry("with a mismatched checksum", "projects/my-project/secrets/corrupt",
+			"projects/my-project/secrets/corrupt/versions/latest: the checksum of the secret does not match"),
+	)
+})
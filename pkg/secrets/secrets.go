@@ -0,0 +1,407 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+const (
+	// renewFraction is the fraction of the lease duration after which leased
+	// secrets are renewed.
+	renewFraction = 2.0 / 3.0
+
+	// minLeaseDuration is the shortest lease that a renewal may return before
+	// the secret is resolved again, as leases cannot be renewed beyond their
+	// maximum duration.
+	minLeaseDuration = time.Minute
+
+	// retryInterval is how long to wait before retrying a secret that could
+	// not be refreshed.
+	retryInterval = 30 * time.Second
+
+	// idleInterval is how long Run waits when no secrets are due to be
+	// refreshed.
+	idleInterval = time.Hour
+)
+
+// Secret is a secret resolved from a secret store.
+type Secret struct {
+	// Value is the value of the secret.
+	Value string
+
+	// LeaseID identifies the lease of a secret that must be renewed, and is
+	// empty for secrets without a lease.
+	LeaseID string
+
+	// LeaseDuration is how long the secret is valid for after it was
+	// resolved or renewed.
+	LeaseDuration time.Duration
+
+	// Renewable is whether the lease of the secret may be renewed.
+	Renewable bool
+}
+
+// Resolver resolves references to the secrets of a secret store.
+type Resolver interface {
+	// Resolve returns the secret the reference refers to. The reference does
+	// not include the scheme of the store.
+	Resolve(ctx context.Context, ref string) (*Secret, error)
+}
+
+// Renewer is implemented by resolvers of secrets with leases that can be
+// renewed.
+type Renewer interface {
+	// Renew renews the lease of the secret, returning the renewed secret.
+	Renew(ctx context.Context, secret *Secret) (*Secret, error)
+}
+
+// ResolverFactory creates the resolver for a scheme of references, which is
+// configured from the environment of the process.
+type ResolverFactory func() (Resolver, error)
+
+// resolverFactories are the factories of the resolvers of each scheme of
+// references.
+var resolverFactories = map[string]ResolverFactory{
+	vaultScheme:             newVaultResolverFromEnv,
+	awsSecretsManagerScheme: newSecretsManagerResolverFromEnv,
+	awsParameterStoreScheme: newParameterStoreResolverFromEnv,
+	gcpSecretManagerScheme:  newGCPSecretManagerResolverFromEnv,
+	azureKeyVaultScheme:     newAzureKeyVaultResolverFromEnv,
+}
+
+var defaultManager = NewManager(resolverFactories)
+
+// ResolveReferences replaces the references to secrets in the string values
+// of the options with the values of the secrets, using the resolvers of the
+// default Manager.
+func ResolveReferences(ctx context.Context, into interface{}) error {
+	return defaultManager.ResolveReferences(ctx, into)
+}
+
+// HasReferences determines whether any references have been resolved by the
+// default Manager.
+func HasReferences() bool {
+	return defaultManager.HasReferences()
+}
+
+// Run refreshes the secrets resolved by the default Manager until the
+// context is done.
+func Run(ctx context.Context, interval time.Duration, onChange func()) {
+	defaultManager.Run(ctx, interval, onChange)
+}
+
+// entry is a resolved reference.
+type entry struct {
+	resolver Resolver
+	ref      string
+	secret   *Secret
+
+	// refreshAt is when the secret must next be refreshed: renewed if it has a
+	// lease, or resolved again otherwise.
+	refreshAt time.Time
+}
+
+// Manager resolves references to secrets, of the form `<scheme>:<reference>`,
+// and caches the secrets so that they are only resolved again when they are
+// refreshed.
+type Manager struct {
+	factories map[string]ResolverFactory
+
+	mutex     sync.Mutex
+	resolvers map[string]Resolver
+	entries   map[string]*entry
+	interval  time.Duration
+}
+
+// NewManager creates a Manager that resolves references with the resolvers
+// created by the factories, keyed by the scheme of the references.
+func NewManager(factories map[string]ResolverFactory) *Manager {
+	return &Manager{
+		factories: factories,
+		resolvers: map[string]Resolver{},
+		entries:   map[string]*entry{},
+	}
+}
+
+// ResolveReferences replaces the references to secrets in the string and
+// byte slice values of the options with the values of the secrets.
+// Secrets that have already been resolved are taken from the cache.
+func (m *Manager) ResolveReferences(ctx context.Context, into interface{}) error {
+	return m.resolveValue(ctx, reflect.ValueOf(into), "")
+}
+
+// HasReferences determines whether any references have been resolved.
+func (m *Manager) HasReferences() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.entries) > 0
+}
+
+// resolveValue walks the value, resolving any references found within it.
+func (m *Manager) resolveValue(ctx context.Context, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return m.resolveValue(ctx, v.Elem(), path)
+	case reflect.Struct:
+		if v.CanAddr() {
+			if source, ok := v.Addr().Interface().(*options.SecretSource); ok {
+				return m.resolveSecretSource(ctx, source, path)
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				// Unexported fields are set internally and never hold references
+				continue
+			}
+			if err := m.resolveValue(ctx, v.Field(i), joinPath(path, field.Name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			value, ok, err := m.resolveString(ctx, string(v.Bytes()), path)
+			if err != nil {
+				return err
+			}
+			if ok && v.CanSet() {
+				v.SetBytes([]byte(value))
+			}
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := m.resolveValue(ctx, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		value, ok, err := m.resolveString(ctx, v.String(), path)
+		if err != nil {
+			return err
+		}
+		if ok && v.CanSet() {
+			v.SetString(value)
+		}
+	}
+	return nil
+}
+
+// resolveSecretSource resolves a reference given as the file or environment
+// variable of a SecretSource, such as a TLS key given by --tls-key-file, into
+// the value of the source.
+func (m *Manager) resolveSecretSource(ctx context.Context, source *options.SecretSource, path string) error {
+	for _, from := range []*string{&source.FromFile, &source.FromEnv} {
+		value, ok, err := m.resolveString(ctx, *from, path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			source.Value = []byte(value)
+			*from = ""
+			return nil
+		}
+	}
+
+	value, ok, err := m.resolveString(ctx, string(source.Value), path)
+	if err != nil {
+		return err
+	}
+	if ok {
+		source.Value = []byte(value)
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// resolveString resolves the value when it is a reference to a secret,
+// returning whether it was a reference.
+func (m *Manager) resolveString(ctx context.Context, value, path string) (string, bool, error) {
+	scheme, ref, ok := m.parseReference(value)
+	if !ok {
+		return "", false, nil
+	}
+
+	secret, err := m.resolve(ctx, scheme, ref, value)
+	if err != nil {
+		return "", false, fmt.Errorf("could not resolve %s for %s: %v", value, path, err)
+	}
+	return secret.Value, true, nil
+}
+
+// parseReference splits the value into the scheme and reference, when it is
+// a reference to a secret of a known scheme.
+func (m *Manager) parseReference(value string) (string, string, bool) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	scheme := value[:i]
+	if _, ok := m.factories[scheme]; !ok {
+		return "", "", false
+	}
+	return scheme, value[i+1:], true
+}
+
+// resolve returns the cached secret for the reference, resolving it when it
+// has not been resolved before.
+func (m *Manager) resolve(ctx context.Context, scheme, ref, value string) (*Secret, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if e, ok := m.entries[value]; ok {
+		return e.secret, nil
+	}
+
+	resolver, ok := m.resolvers[scheme]
+	if !ok {
+		var err error
+		resolver, err = m.factories[scheme]()
+		if err != nil {
+			return nil, fmt.Errorf("could not configure %s: %v", scheme, err)
+		}
+		m.resolvers[scheme] = resolver
+	}
+
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{resolver: resolver, ref: ref, secret: secret}
+	m.schedule(e, time.Now())
+	m.entries[value] = e
+	return secret, nil
+}
+
+// schedule sets when the secret of the entry must next be refreshed.
+// Leased secrets are renewed before their lease expires. Secrets without a
+// lease are resolved again after the refresh interval, if there is one.
+func (m *Manager) schedule(e *entry, now time.Time) {
+	switch {
+	case e.secret.LeaseID != "":
+		e.refreshAt = now.Add(time.Duration(float64(e.secret.LeaseDuration) * renewFraction))
+	case m.interval > 0:
+		e.refreshAt = now.Add(m.interval)
+	default:
+		e.refreshAt = time.Time{}
+	}
+}
+
+// Run refreshes the secrets until the context is done, calling onChange
+// whenever the value of a secret has changed so that the configuration can
+// be loaded again.
+// Leased secrets are renewed before they expire, and resolved again when
+// their lease cannot be renewed. Other secrets are resolved again every
+// interval, unless the interval is 0.
+func (m *Manager) Run(ctx context.Context, interval time.Duration, onChange func()) {
+	m.mutex.Lock()
+	m.interval = interval
+	now := time.Now()
+	for _, e := range m.entries {
+		m.schedule(e, now)
+	}
+	m.mutex.Unlock()
+
+	worker := workers.New("secrets", interval)
+	for {
+		timer := time.NewTimer(m.nextRefresh(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		changed, err := m.refresh(ctx, time.Now())
+		worker.Record(err)
+		if changed {
+			onChange()
+		}
+	}
+}
+
+// nextRefresh returns how long until the next secret must be refreshed.
+func (m *Manager) nextRefresh(now time.Time) time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	next := idleInterval
+	for _, e := range m.entries {
+		if e.refreshAt.IsZero() {
+			continue
+		}
+		if wait := e.refreshAt.Sub(now); wait < next {
+			next = wait
+		}
+	}
+	if next < 0 {
+		next = 0
+	}
+	return next
+}
+
+// refresh refreshes the secrets that are due, returning whether the value of
+// any secret has changed, and the first error of the secrets that could not
+// be refreshed.
+func (m *Manager) refresh(ctx context.Context, now time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	changed := false
+	var firstErr error
+	for value, e := range m.entries {
+		if e.refreshAt.IsZero() || e.refreshAt.After(now) {
+			continue
+		}
+
+		secret, err := m.refreshEntry(ctx, e)
+		if err != nil {
+			logger.Errorf("ERROR: could not refresh secret %s: %v", value, err)
+			e.refreshAt = now.Add(retryInterval)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("could not refresh secret %s: %v", value, err)
+			}
+			continue
+		}
+		if secret.Value != e.secret.Value {
+			logger.Printf("Secret %s has changed", value)
+			changed = true
+		}
+		e.secret = secret
+		m.schedule(e, now)
+	}
+	return changed, firstErr
+}
+
+// refreshEntry renews the lease of the secret when it can be renewed, and
+// otherwise resolves the secret again.
+func (m *Manager) refreshEntry(ctx context.Context, e *entry) (*Secret, error) {
+	if renewer, ok := e.resolver.(Renewer); ok && e.secret.LeaseID != "" && e.secret.Renewable {
+		secret, err := renewer.Renew(ctx, e.secret)
+		switch {
+		case err != nil:
+			logger.Printf("Could not renew the lease of a secret, resolving it again: %v", err)
+		case secret.LeaseDuration < minLeaseDuration:
+			logger.Printf("The lease of a secret has reached its maximum duration, resolving it again")
+		default:
+			return secret, nil
+		}
+	}
+	return e.resolver.Resolve(ctx, e.ref)
+}
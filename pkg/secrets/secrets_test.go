@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeResolver resolves references to the secrets in its map.
+type fakeResolver struct {
+	mutex    sync.Mutex
+	secrets  map[string]*Secret
+	resolved int
+	renewed  int
+	renewErr error
+}
+
+func (f *fakeResolver) set(ref string, secret *Secret) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.secrets[ref] = secret
+}
+
+func (f *fakeResolver) Resolve(_ context.Context, ref string) (*Secret, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.resolved++
+	secret, ok := f.secrets[ref]
+	if !ok {
+		return nil, errors.New("secret not found")
+	}
+	copied := *secret
+	return &copied, nil
+}
+
+func (f *fakeResolver) Renew(_ context.Context, secret *Secret) (*Secret, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.renewed++
+	if f.renewErr != nil {
+		return nil, f.renewErr
+	}
+	return secret, nil
+}
+
+type testSecretOptions struct {
+	CookieSecret string
+	Providers    []testSecretProvider
+	Password     *testSecretSource
+	Plain        string
+	internal     string
+}
+
+type testSecretProvider struct {
+	ClientSecret string
+}
+
+type testSecretSource struct {
+	Value []byte
+}
+
+var _ = Describe("Secrets", func() {
+	var resolver *fakeResolver
+	var manager *Manager
+
+	BeforeEach(func() {
+		resolver = &fakeResolver{secrets: map[string]*Secret{
+			"cookie":   {Value: "cookie-secret"},
+			"client":   {Value: "client-secret"},
+			"password": {Value: "redis-password", LeaseID: "lease", LeaseDuration: time.Hour, Renewable: true},
+		}}
+		manager = NewManager(map[string]ResolverFactory{
+			"fake": func() (Resolver, error) { return resolver, nil },
+		})
+	})
+
+	It("resolves references in the options", func() {
+		opts := &testSecretOptions{
+			CookieSecret: "fake:cookie",
+			Providers:    []testSecretProvider{{ClientSecret: "fake:client"}, {ClientSecret: "literal"}},
+			Password:     &testSecretSource{Value: []byte("fake:password")},
+			Plain:        "unknown:scheme",
+			internal:     "fake:cookie",
+		}
+		Expect(manager.ResolveReferences(context.Background(), opts)).To(Succeed())
+		Expect(opts).To(Equal(&testSecretOptions{
+			CookieSecret: "cookie-secret",
+			Providers:    []testSecretProvider{{ClientSecret: "client-secret"}, {ClientSecret: "literal"}},
+			Password:     &testSecretSource{Value: []byte("redis-password")},
+			Plain:        "unknown:scheme",
+			internal:     "fake:cookie",
+		}))
+		Expect(manager.HasReferences()).To(BeTrue())
+
+		By("Using the cached secrets when the options are loaded again")
+		Expect(manager.ResolveReferences(context.Background(), &testSecretOptions{CookieSecret: "fake:cookie"})).To(Succeed())
+		Expect(resolver.resolved).To(Equal(3))
+	})
+
+	It("resolves references given as the file or environment variable of a SecretSource", func() {
+		opts := &options.Server{TLS: &options.TLS{
+			Key:  &options.SecretSource{FromFile: "fake:cookie"},
+			Cert: &options.SecretSource{FromFile: "/etc/tls/cert.pem"},
+		}}
+		Expect(manager.ResolveReferences(context.Background(), opts)).To(Succeed())
+		Expect(opts.TLS.Key).To(Equal(&options.SecretSource{Value: []byte("cookie-secret")}))
+		Expect(opts.TLS.Cert).To(Equal(&options.SecretSource{FromFile: "/etc/tls/cert.pem"}))
+	})
+
+	It("returns an error for secrets that cannot be resolved", func() {
+		err := manager.ResolveReferences(context.Background(), &testSecretOptions{Providers: []testSecretProvider{{ClientSecret: "fake:missing"}}})
+		Expect(err).To(MatchError("could not resolve fake:missing for Providers[0].ClientSecret: secret not found"))
+	})
+
+	It("resolves secrets again after the interval, and calls onChange when they change", func() {
+		Expect(manager.ResolveReferences(context.Background(), &testSecretOptions{CookieSecret: "fake:cookie"})).To(Succeed())
+
+		changes := make(chan struct{}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go manager.Run(ctx, 20*time.Millisecond, func() { changes <- struct{}{} })
+
+		Consistently(changes, 100*time.Millisecond).ShouldNot(Receive())
+		resolver.set("cookie", &Secret{Value: "rotated-cookie-secret"})
+		Eventually(changes, time.Second).Should(Receive())
+
+		opts := &testSecretOptions{CookieSecret: "fake:cookie"}
+		Expect(manager.ResolveReferences(context.Background(), opts)).To(Succeed())
+		Expect(opts.CookieSecret).To(Equal("rotated-cookie-secret"))
+	})
+
+	Context("with leased secrets", func() {
+		var e *entry
+
+		BeforeEach(func() {
+			Expect(manager.ResolveReferences(context.Background(), &testSecretOptions{CookieSecret: "fake:password"})).To(Succeed())
+			e = manager.entries["fake:password"]
+			Expect(e.refreshAt).To(BeTemporally("~", time.Now().Add(40*time.Minute), time.Second))
+		})
+
+		It("renews the lease before it expires", func() {
+			Expect(manager.refresh(context.Background(), time.Now().Add(41*time.Minute))).To(BeFalse())
+			Expect(resolver.renewed).To(Equal(1))
+			Expect(resolver.resolved).To(Equal(1))
+		})
+
+		It("resolves the secret again when the lease cannot be renewed", func() {
+			resolver.renewErr = errors.New("lease expired")
+			resolver.set("password", &Secret{Value: "new-redis-password", LeaseID: "new-lease", LeaseDuration: time.Hour})
+
+			Expect(manager.refresh(context.Background(), time.Now().Add(41*time.Minute))).To(BeTrue())
+			Expect(resolver.resolved).To(Equal(2))
+			Expect(e.secret.Value).To(Equal("new-redis-password"))
+		})
+
+		It("does not resolve leased secrets again at the interval", func() {
+			manager.interval = time.Minute
+			Expect(manager.refresh(context.Background(), time.Now().Add(2*time.Minute))).To(BeFalse())
+			Expect(resolver.renewed).To(Equal(0))
+			Expect(resolver.resolved).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,347 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+const vaultScheme = "vault"
+
+// The environment variables used to configure the Vault resolver. The
+// address, token, namespace and CA certificate follow the conventions of the
+// Vault CLI.
+const (
+	VaultAddrEnv                = "VAULT_ADDR"
+	VaultTokenEnv               = "VAULT_TOKEN"
+	VaultNamespaceEnv           = "VAULT_NAMESPACE"
+	VaultCACertEnv              = "VAULT_CACERT"
+	VaultKubernetesRoleEnv      = "VAULT_KUBERNETES_ROLE"
+	VaultKubernetesMountPathEnv = "VAULT_KUBERNETES_MOUNT_PATH"
+)
+
+const (
+	defaultVaultKubernetesMountPath = "kubernetes"
+	serviceAccountTokenFile         = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	vaultRequestTimeout             = 10 * time.Second
+)
+
+// vaultResolver resolves `vault:<path>#<field>` references with the Vault
+// HTTP API. The path is the API path of the secret, without the `v1/`
+// prefix, eg. `secret/data/oauth2-proxy` for a KV version 2 secret or
+// `database/creds/redis` for a dynamic secret.
+type vaultResolver struct {
+	client    *http.Client
+	address   string
+	namespace string
+
+	// token is used when set, otherwise the resolver logs in with the
+	// Kubernetes auth method.
+	token          string
+	kubernetesRole string
+	kubernetesPath string
+	jwtFile        string
+
+	mutex        sync.Mutex
+	loginToken   string
+	loginExpires time.Time
+
+	// responses are the responses of leased secrets, keyed by path, so that
+	// the fields of a dynamic secret, such as a username and password, are
+	// taken from the same lease.
+	responses map[string]*vaultResponse
+}
+
+// vaultResponse is a response from the Vault API.
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+
+	expires time.Time
+}
+
+// newVaultResolverFromEnv creates a vaultResolver configured by the
+// environment variables of the Vault CLI. Without a token, the resolver logs
+// in with the Kubernetes auth method, using the role given by
+// VAULT_KUBERNETES_ROLE and the service account token of the pod.
+func newVaultResolverFromEnv() (Resolver, error) {
+	address := os.Getenv(VaultAddrEnv)
+	if address == "" {
+		return nil, fmt.Errorf("the %s environment variable must be set", VaultAddrEnv)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caCert := os.Getenv(VaultCACertEnv); caCert != "" {
+		rootCAs, err := util.GetCertPool([]string{caCert})
+		if err != nil {
+			return nil, fmt.Errorf("could not load %s: %v", VaultCACertEnv, err)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    rootCAs,
+		}
+	}
+
+	r := newVaultResolver(&http.Client{Transport: transport, Timeout: vaultRequestTimeout}, address)
+	r.namespace = os.Getenv(VaultNamespaceEnv)
+	r.token = os.Getenv(VaultTokenEnv)
+	r.kubernetesRole = os.Getenv(VaultKubernetesRoleEnv)
+	if path := os.Getenv(VaultKubernetesMountPathEnv); path != "" {
+		r.kubernetesPath = path
+	}
+	if r.token == "" && r.kubernetesRole == "" {
+		return nil, fmt.Errorf("either the %s or the %s environment variable must be set", VaultTokenEnv, VaultKubernetesRoleEnv)
+	}
+	return r, nil
+}
+
+func newVaultResolver(client *http.Client, address string) *vaultResolver {
+	return &vaultResolver{
+		client:         client,
+		address:        strings.TrimSuffix(address, "/"),
+		kubernetesPath: defaultVaultKubernetesMountPath,
+		jwtFile:        serviceAccountTokenFile,
+		responses:      map[string]*vaultResponse{},
+	}
+}
+
+// Resolve reads the secret at the path of the reference and returns the
+// field of the reference. The field may be omitted for secrets with a single
+// field. KV version 2 secrets are detected by their response, and may be
+// pinned to a version with a `?version=<n>` suffix on the path.
+func (r *vaultResolver) Resolve(ctx context.Context, ref string) (*Secret, error) {
+	path, field := ref, ""
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		path, field = ref[:i], ref[i+1:]
+	}
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil, errors.New("the reference must be of the form vault:<path>#<field>")
+	}
+
+	resp, err := r.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := resp.Data
+	if isKVv2(data) {
+		data, _ = data["data"].(map[string]interface{})
+	}
+	value, err := selectField(data, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &Secret{
+		Value:         value,
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+	}, nil
+}
+
+// read reads the secret at the path, reusing the response of a leased secret
+// while its lease is valid.
+func (r *vaultResolver) read(ctx context.Context, path string) (*vaultResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if resp, ok := r.responses[path]; ok && time.Until(resp.expires) > minLeaseDuration {
+		return resp, nil
+	}
+
+	resp := &vaultResponse{}
+	if err := r.do(ctx, http.MethodGet, path, nil, resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, errors.New("no secret found at " + path)
+	}
+	if resp.LeaseID != "" {
+		resp.expires = time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+		r.responses[path] = resp
+	}
+	return resp, nil
+}
+
+// Renew renews the lease of the secret for its lease duration.
+func (r *vaultResolver) Renew(ctx context.Context, secret *Secret) (*Secret, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	body := map[string]interface{}{
+		"lease_id":  secret.LeaseID,
+		"increment": int(secret.LeaseDuration.Seconds()),
+	}
+	resp := &vaultResponse{}
+	if err := r.do(ctx, http.MethodPut, "sys/leases/renew", body, resp); err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(resp.LeaseDuration) * time.Second
+	for _, cached := range r.responses {
+		if cached.LeaseID == secret.LeaseID {
+			cached.expires = time.Now().Add(duration)
+		}
+	}
+
+	renewed := *secret
+	renewed.LeaseDuration = duration
+	renewed.Renewable = resp.Renewable
+	return &renewed, nil
+}
+
+// do sends an authenticated request to the Vault API and decodes the
+// response. The caller must hold the mutex.
+func (r *vaultResolver) do(ctx context.Context, method, path string, body interface{}, into interface{}) error {
+	token, err := r.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	return r.request(ctx, method, path, token, body, into)
+}
+
+// getToken returns the configured token, or logs in with the Kubernetes auth
+// method when there is no token or the login token is about to expire.
+// The caller must hold the mutex.
+func (r *vaultResolver) getToken(ctx context.Context) (string, error) {
+	if r.token != "" {
+		return r.token, nil
+	}
+	if r.loginToken != "" && time.Until(r.loginExpires) > minLeaseDuration {
+		return r.loginToken, nil
+	}
+
+	// Service account tokens are rotated, so the token is read for each login
+	jwt, err := ioutil.ReadFile(r.jwtFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read service account token: %v", err)
+	}
+	body := map[string]string{
+		"role": r.kubernetesRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}
+	resp := &vaultResponse{}
+	if err := r.request(ctx, http.MethodPost, "auth/"+strings.Trim(r.kubernetesPath, "/")+"/login", "", body, resp); err != nil {
+		return "", fmt.Errorf("could not log in to vault: %v", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", errors.New("could not log in to vault: no token returned")
+	}
+
+	r.loginToken = resp.Auth.ClientToken
+	r.loginExpires = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	return r.loginToken, nil
+}
+
+// request sends a request to the Vault API and decodes the response.
+func (r *vaultResolver) request(ctx context.Context, method, path, token string, body interface{}, into interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.address+"/v1/"+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if r.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && len(errResp.Errors) > 0 {
+			return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.Join(errResp.Errors, ", "))
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(respBody, into); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	return nil
+}
+
+// isKVv2 determines whether the data of a response is that of a KV version 2
+// secret, which nests the fields of the secret within its data.
+func isKVv2(data map[string]interface{}) bool {
+	_, hasData := data["data"].(map[string]interface{})
+	_, hasMetadata := data["metadata"].(map[string]interface{})
+	return hasData && hasMetadata && len(data) == 2
+}
+
+// selectField returns the value of the field of the secret, which may be
+// omitted when the secret has a single field.
+func selectField(data map[string]interface{}, field string) (string, error) {
+	if field == "" {
+		if len(data) != 1 {
+			fields := make([]string, 0, len(data))
+			for name := range data {
+				fields = append(fields, name)
+			}
+			sort.Strings(fields)
+			return "", fmt.Errorf("a field must be given for secrets with several fields: %s", strings.Join(fields, ", "))
+		}
+		for name := range data {
+			field = name
+		}
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found", field)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("field %q is empty", field)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vault", func() {
+	var server *httptest.Server
+	var reads map[string]int
+	var tokens []string
+
+	BeforeEach(func() {
+		reads = map[string]int{}
+		tokens = nil
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/secret/data/oauth2-proxy", func(rw http.ResponseWriter, req *http.Request) {
+			reads[req.URL.Path]++
+			tokens = append(tokens, req.Header.Get("X-Vault-Token"))
+			version := req.URL.Query().Get("version")
+			if version == "" {
+				version = "2"
+			}
+			rw.Write([]byte(`{"lease_id":"","lease_duration":0,"renewable":false,"data":{"data":{"client_secret":"client-secret-v` + version + `","cookie_secret":"cookie"},"metadata":{"version":` + version + `}}}`))
+		})
+		mux.HandleFunc("/v1/database/creds/redis", func(rw http.ResponseWriter, req *http.Request) {
+			reads[req.URL.Path]++
+			rw.Write([]byte(`{"lease_id":"database/creds/redis/abc","lease_duration":3600,"renewable":true,"data":{"username":"v-redis-abc","password":"dynamic-password"}}`))
+		})
+		mux.HandleFunc("/v1/sys/leases/renew", func(rw http.ResponseWriter, req *http.Request) {
+			Expect(req.Method).To(Equal(http.MethodPut))
+			var body map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			Expect(body["lease_id"]).To(Equal("database/creds/redis/abc"))
+			rw.Write([]byte(`{"lease_id":"database/creds/redis/abc","lease_duration":1800,"renewable":true}`))
+		})
+		mux.HandleFunc("/v1/auth/kubernetes/login", func(rw http.ResponseWriter, req *http.Request) {
+			var body map[string]string
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			Expect(body).To(Equal(map[string]string{"role": "oauth2-proxy", "jwt": "service-account-token"}))
+			rw.Write([]byte(`{"auth":{"client_token":"login-token","lease_duration":3600}}`))
+		})
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newResolver := func() *vaultResolver {
+		r := newVaultResolver(server.Client(), server.URL+"/")
+		r.token = "root-token"
+		return r
+	}
+
+	DescribeTable("resolves references",
+		func(ref string, expected Secret) {
+			secret, err := newResolver().Resolve(context.Background(), ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*secret).To(Equal(expected))
+		},
+		Entry("with a KV version 2 secret", "secret/data/oauth2-proxy#client_secret", Secret{Value: "client-secret-v2"}),
+		Entry("with a pinned version", "secret/data/oauth2-proxy?version=1#client_secret", Secret{Value: "client-secret-v1"}),
+		Entry("with a dynamic secret", "database/creds/redis#password", Secret{
+			Value: "dynamic-password", LeaseID: "database/creds/redis/abc", LeaseDuration: time.Hour, Renewable: true,
+		}),
+	)
+
+	It("returns an error without a field for secrets with several fields", func() {
+		_, err := newResolver().Resolve(context.Background(), "secret/data/oauth2-proxy")
+		Expect(err).To(MatchError("secret/data/oauth2-proxy: a field must be given for secrets with several fields: client_secret, cookie_secret"))
+	})
+
+	It("returns an error for missing secrets", func() {
+		_, err := newResolver().Resolve(context.Background(), "secret/data/missing#client_secret")
+		Expect(err).To(MatchError("unexpected status 404"))
+	})
+
+	It("reads the fields of a dynamic secret from the same lease", func() {
+		r := newResolver()
+		username, err := r.Resolve(context.Background(), "database/creds/redis#username")
+		Expect(err).ToNot(HaveOccurred())
+		password, err := r.Resolve(context.Background(), "database/creds/redis#password")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(username.LeaseID).To(Equal(password.LeaseID))
+		Expect(reads["/v1/database/creds/redis"]).To(Equal(1))
+	})
+
+	It("renews the lease of dynamic secrets", func() {
+		r := newResolver()
+		secret, err := r.Resolve(context.Background(), "database/creds/redis#password")
+		Expect(err).ToNot(HaveOccurred())
+
+		renewed, err := r.Renew(context.Background(), secret)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renewed.Value).To(Equal("dynamic-password"))
+		Expect(renewed.LeaseDuration).To(Equal(30 * time.Minute))
+	})
+
+	It("logs in with the Kubernetes auth method", func() {
+		jwtFile, err := ioutil.TempFile("", "oauth2-proxy-test-service-account-token")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(jwtFile.Name())
+		_, err = jwtFile.Write([]byte("service-account-token\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jwtFile.Close()).To(Succeed())
+
+		r := newVaultResolver(server.Client(), server.URL)
+		r.kubernetesRole = "oauth2-proxy"
+		r.jwtFile = jwtFile.Name()
+
+		_, err = r.Resolve(context.Background(), "secret/data/oauth2-proxy#cookie_secret")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tokens).To(ConsistOf("login-token"))
+	})
+})
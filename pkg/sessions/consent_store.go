@@ -0,0 +1,25 @@
+package sessions
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+)
+
+// NewConsentStore creates a ConsentStore in the session store from the
+// provided configuration, storing the versions under keys with the prefix.
+// The cookie session store has no server side storage to persist them in.
+func NewConsentStore(opts *options.SessionOptions, prefix string) (sessions.ConsentStore, error) {
+	switch opts.Type {
+	case options.RedisSessionStoreType:
+		client, err := redis.NewRedisClient(opts.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing redis client: %v", err)
+		}
+		return redis.NewConsentStore(client, prefix), nil
+	default:
+		return nil, fmt.Errorf("session store type '%s' cannot persist consents", opts.Type)
+	}
+}
@@ -0,0 +1,43 @@
+package sessions_test
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewConsentStore", func() {
+	var opts *options.SessionOptions
+
+	BeforeEach(func() {
+		opts = &options.SessionOptions{}
+	})
+
+	Context("with type 'redis'", func() {
+		BeforeEach(func() {
+			opts.Type = options.RedisSessionStoreType
+			opts.Redis.ConnectionURL = "redis://"
+		})
+
+		It("creates a redis.ConsentStore", func() {
+			store, err := sessions.NewConsentStore(opts, "_oauth2_proxy-consent-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store).To(BeAssignableToTypeOf(&redis.ConsentStore{}))
+			Expect(store.(*redis.ConsentStore).Prefix).To(Equal("_oauth2_proxy-consent-"))
+		})
+	})
+
+	Context("with type 'cookie'", func() {
+		BeforeEach(func() {
+			opts.Type = options.CookieSessionStoreType
+		})
+
+		It("returns an error", func() {
+			store, err := sessions.NewConsentStore(opts, "_oauth2_proxy-consent-")
+			Expect(err).To(MatchError("session store type 'cookie' cannot persist consents"))
+			Expect(store).To(BeNil())
+		})
+	})
+})
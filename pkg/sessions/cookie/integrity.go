@@ -0,0 +1,121 @@
+package cookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// integrityHeaderPrefix starts the integrity header of a split session
+	// cookie. It cannot start a signed cookie value, which is base64 encoded.
+	integrityHeaderPrefix = "~"
+
+	// The reasons that split session cookies fail their integrity check.
+	integrityReasonMalformed = "malformed"
+	integrityReasonTruncated = "truncated"
+	integrityReasonMAC       = "mac"
+)
+
+// integrityError is the error of a split session cookie whose chunks do not
+// match its integrity header.
+type integrityError struct {
+	reason string
+	msg    string
+}
+
+// Error returns the message of the integrity error.
+func (e *integrityError) Error() string {
+	return "session cookie integrity check failed: " + e.msg
+}
+
+// addIntegrityHeader prepends the integrity header to the value of a session
+// cookie that is about to be split.
+// The header holds the length of the value and its MAC, so that missing,
+// truncated or reordered chunks are detected when the cookie is joined.
+func addIntegrityHeader(c *http.Cookie, secret []byte) *http.Cookie {
+	headed := copyCookie(c)
+	headed.Value = fmt.Sprintf("%s%d|%s|%s", integrityHeaderPrefix, len(c.Value), integrityMAC(secret, c.Name, c.Value), c.Value)
+	return headed
+}
+
+// checkIntegrityHeader checks the value of a joined session cookie against
+// its integrity header and returns the cookie without the header.
+// Chunks joined after the length of the value, left over from a larger
+// session, are dropped.
+// Cookies without an integrity header, which were not split or were split
+// before the header was added, are returned unchanged.
+func checkIntegrityHeader(c *http.Cookie, secret []byte) (*http.Cookie, error) {
+	if !strings.HasPrefix(c.Value, integrityHeaderPrefix) {
+		return c, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(c.Value, integrityHeaderPrefix), "|", 3)
+	if len(parts) != 3 {
+		return nil, &integrityError{reason: integrityReasonMalformed, msg: "malformed integrity header"}
+	}
+	length, err := strconv.Atoi(parts[0])
+	if err != nil || length < 0 {
+		return nil, &integrityError{reason: integrityReasonMalformed, msg: fmt.Sprintf("invalid length %q in integrity header", parts[0])}
+	}
+
+	value := parts[2]
+	if len(value) < length {
+		return nil, &integrityError{
+			reason: integrityReasonTruncated,
+			msg:    fmt.Sprintf("chunks have %d of %d bytes, some chunks are missing or truncated", len(value), length),
+		}
+	}
+	value = value[:length]
+
+	if !hmac.Equal([]byte(parts[1]), []byte(integrityMAC(secret, c.Name, value))) {
+		return nil, &integrityError{reason: integrityReasonMAC, msg: "MAC does not match, chunks are reordered or modified"}
+	}
+
+	joined := copyCookie(c)
+	joined.Value = value
+	return joined, nil
+}
+
+// integrityMAC returns the HMAC-SHA256 of the name, length and value of a
+// session cookie.
+// NOTE: Error checking (G104) is purposefully skipped:
+// `hash.Hash` interface's `Write` has an error signature, but
+// `hmac.hmac.Write` does not use it.
+/* #nosec G104 */
+func integrityMAC(secret []byte, name, value string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(fmt.Sprintf("%s|%d|", name, len(value))))
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// registerIntegrityErrorsCounter registers the
+// 'oauth2_proxy_session_cookie_integrity_errors_total' metric.
+// This counts the split session cookies that failed their integrity check,
+// by reason.
+func registerIntegrityErrorsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_session_cookie_integrity_errors_total",
+			Help: "Total number of split session cookies that failed their integrity check by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
@@ -12,6 +12,8 @@ import (
 	pkgcookies "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -30,6 +32,14 @@ type SessionStore struct {
 	Cookie       *options.Cookie
 	CookieCipher encryption.Cipher
 	Minimal      bool
+
+	// Overflow is the server side store of the sessions that are too large
+	// for a cookie, instead of splitting them into several cookies, if any
+	Overflow *persistence.Manager
+
+	// integrityErrors counts the split session cookies that failed their
+	// integrity check, by reason
+	integrityErrors *prometheus.CounterVec
 }
 
 // Save takes a sessions.SessionState and stores the information from it
@@ -38,21 +48,55 @@ func (s *SessionStore) Save(rw http.ResponseWriter, req *http.Request, ss *sessi
 	if ss.CreatedAt == nil || ss.CreatedAt.IsZero() {
 		ss.CreatedAtNow()
 	}
+	if s.hasTicket(req) {
+		// Sessions that overflowed remain in the server side store, so
+		// that their ticket is reused when they are refreshed
+		return s.Overflow.Save(rw, req, ss)
+	}
+
 	value, err := s.cookieForSession(ss)
 	if err != nil {
 		return err
 	}
-	return s.setSessionCookie(rw, req, value, *ss.CreatedAt)
+	cookies, err := s.makeSessionCookie(req, value, *ss.CreatedAt, ss.BrowserSession)
+	if err != nil {
+		return err
+	}
+	if len(cookies) > 1 && s.Overflow != nil {
+		logger.Printf("Session exceeds the 4kb cookie limit, storing it in the server side session store")
+		s.clearSplitCookies(rw, req)
+		return s.Overflow.Save(rw, req, ss)
+	}
+	return s.setSessionCookies(rw, req, cookies)
 }
 
 // Load reads sessions.SessionState information from Cookies within the
-// HTTP request object
+// HTTP request object, with the cookie name or one of its legacy names
 func (s *SessionStore) Load(req *http.Request) (*sessions.SessionState, error) {
-	c, err := loadCookie(req, s.Cookie.Name)
+	if s.hasTicket(req) {
+		return s.Overflow.Load(req)
+	}
+
+	var c *http.Cookie
+	err := http.ErrNoCookie
+	for _, name := range pkgcookies.ReadNames(s.Cookie) {
+		c, err = loadCookie(req, name)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		// always http.ErrNoCookie
 		return nil, err
 	}
+	c, err = checkIntegrityHeader(c, s.Cookie.Secret)
+	if err != nil {
+		var ie *integrityError
+		if errors.As(err, &ie) && s.integrityErrors != nil {
+			s.integrityErrors.WithLabelValues(ie.reason).Inc()
+		}
+		return nil, err
+	}
 	val, _, ok := encryption.Validate(c, s.Cookie.Secret, s.Cookie.Expire)
 	if !ok {
 		return nil, errors.New("cookie signature not valid")
@@ -68,6 +112,10 @@ func (s *SessionStore) Load(req *http.Request) (*sessions.SessionState, error) {
 // Clear clears any saved session information by writing a cookie to
 // clear the session
 func (s *SessionStore) Clear(rw http.ResponseWriter, req *http.Request) error {
+	if s.hasTicket(req) {
+		return s.Overflow.Clear(rw, req)
+	}
+
 	// matches CookieName, CookieName_<number>
 	var cookieNameRegex = regexp.MustCompile(fmt.Sprintf("^%s(_\\d+)?$", s.Cookie.Name))
 
@@ -78,17 +126,26 @@ func (s *SessionStore) Clear(rw http.ResponseWriter, req *http.Request) error {
 			http.SetCookie(rw, clearCookie)
 		}
 	}
+	pkgcookies.ClearLegacyCookies(rw, req, s.Cookie)
 
 	return nil
 }
 
+// Close closes the server side store of the overflow, if any
+func (s *SessionStore) Close() error {
+	if s.Overflow != nil {
+		return s.Overflow.Close()
+	}
+	return nil
+}
+
 // cookieForSession serializes a session state for storage in a cookie
 func (s *SessionStore) cookieForSession(ss *sessions.SessionState) ([]byte, error) {
-	if s.Minimal && (ss.AccessToken != "" || ss.IDToken != "" || ss.RefreshToken != "") {
+	if s.Minimal && (len(ss.AccessToken) > 0 || len(ss.IDToken) > 0 || len(ss.RefreshToken) > 0) {
 		minimal := *ss
-		minimal.AccessToken = ""
-		minimal.IDToken = ""
-		minimal.RefreshToken = ""
+		minimal.AccessToken = nil
+		minimal.IDToken = nil
+		minimal.RefreshToken = nil
 
 		return minimal.EncodeSessionState(s.CookieCipher, true)
 	}
@@ -96,21 +153,41 @@ func (s *SessionStore) cookieForSession(ss *sessions.SessionState) ([]byte, erro
 	return ss.EncodeSessionState(s.CookieCipher, true)
 }
 
-// setSessionCookie adds the user's session cookie to the response
-func (s *SessionStore) setSessionCookie(rw http.ResponseWriter, req *http.Request, val []byte, created time.Time) error {
-	cookies, err := s.makeSessionCookie(req, val, created)
-	if err != nil {
-		return err
+// setSessionCookies adds the user's session cookies to the response
+func (s *SessionStore) setSessionCookies(rw http.ResponseWriter, req *http.Request, cookies []*http.Cookie) error {
+	if len(cookies) > 1 {
+		logger.Errorf("WARNING: Multiple cookies are required for this session as it exceeds the 4kb cookie limit. Please use server side session storage (eg. Redis) instead.")
 	}
 	for _, c := range cookies {
 		http.SetCookie(rw, c)
 	}
+	pkgcookies.ClearLegacyCookies(rw, req, s.Cookie)
 	return nil
 }
 
+// clearSplitCookies clears the cookies of a session that was split into
+// several cookies
+func (s *SessionStore) clearSplitCookies(rw http.ResponseWriter, req *http.Request) {
+	// matches CookieName_<number>
+	var splitCookieNameRegex = regexp.MustCompile(fmt.Sprintf("^%s_\\d+$", regexp.QuoteMeta(s.Cookie.Name)))
+
+	for _, c := range req.Cookies() {
+		if splitCookieNameRegex.MatchString(c.Name) {
+			http.SetCookie(rw, s.makeCookie(req, c.Name, "", time.Hour*-1, time.Now()))
+		}
+	}
+}
+
+// hasTicket returns whether the session of the request overflowed to the
+// server side store, and has a ticket in its cookie
+func (s *SessionStore) hasTicket(req *http.Request) bool {
+	return s.Overflow != nil && s.Overflow.HasTicket(req)
+}
+
 // makeSessionCookie creates an http.Cookie containing the authenticated user's
-// authentication details
-func (s *SessionStore) makeSessionCookie(req *http.Request, value []byte, now time.Time) ([]*http.Cookie, error) {
+// authentication details, which expires when the browser is closed for browser
+// sessions
+func (s *SessionStore) makeSessionCookie(req *http.Request, value []byte, now time.Time, browserSession bool) ([]*http.Cookie, error) {
 	strValue := string(value)
 	if strValue != "" {
 		var err error
@@ -120,8 +197,11 @@ func (s *SessionStore) makeSessionCookie(req *http.Request, value []byte, now ti
 		}
 	}
 	c := s.makeCookie(req, s.Cookie.Name, strValue, s.Cookie.Expire, now)
+	if browserSession {
+		c.Expires = time.Time{}
+	}
 	if len(c.String()) > maxCookieLength {
-		return splitCookie(c), nil
+		return splitCookie(addIntegrityHeader(c, s.Cookie.Secret)), nil
 	}
 	return []*http.Cookie{c}, nil
 }
@@ -140,15 +220,27 @@ func (s *SessionStore) makeCookie(req *http.Request, name string, value string,
 // NewCookieSessionStore initialises a new instance of the SessionStore from
 // the configuration given
 func NewCookieSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
-	cipher, err := encryption.NewCFBCipher(encryption.SecretBytes(cookieOpts.Secret))
+	return NewCookieSessionStoreWithOverflow(opts, cookieOpts, nil)
+}
+
+// NewCookieSessionStoreWithOverflow initialises a new instance of the
+// SessionStore that stores the sessions that are too large for a cookie in
+// the server side store of the overflow, keeping only their ticket in the
+// cookie
+func NewCookieSessionStoreWithOverflow(opts *options.SessionOptions, cookieOpts *options.Cookie, overflow *persistence.Manager) (sessions.SessionStore, error) {
+	secret := encryption.SecretBytes(cookieOpts.Secret)
+	defer encryption.Wipe(secret)
+	cipher, err := encryption.NewCipher(cookieOpts.Cipher, secret, cookieOpts.CipherRejectLegacy)
 	if err != nil {
 		return nil, fmt.Errorf("error initialising cipher: %v", err)
 	}
 
 	return &SessionStore{
-		CookieCipher: cipher,
-		Cookie:       cookieOpts,
-		Minimal:      opts.Cookie.Minimal,
+		CookieCipher:    cipher,
+		Cookie:          cookieOpts,
+		Minimal:         opts.Cookie.Minimal,
+		Overflow:        overflow,
+		integrityErrors: registerIntegrityErrorsCounter(prometheus.DefaultRegisterer),
 	}, nil
 }
 
@@ -160,8 +252,6 @@ func splitCookie(c *http.Cookie) []*http.Cookie {
 		return []*http.Cookie{c}
 	}
 
-	logger.Errorf("WARNING: Multiple cookies are required for this session as it exceeds the 4kb cookie limit. Please use server side session storage (eg. Redis) instead.")
-
 	cookies := []*http.Cookie{}
 	valueBytes := []byte(c.Value)
 	count := 0
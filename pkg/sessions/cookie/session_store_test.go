@@ -4,13 +4,16 @@ import (
 	"fmt"
 	mathrand "math/rand"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -34,6 +37,27 @@ var _ = Describe("Cookie SessionStore Tests", func() {
 		}, nil)
 })
 
+var _ = Describe("Cookie SessionStore Tests with an authenticated cipher", func() {
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.CookieSessionStoreType
+			cookieOpts.Cipher = encryption.XChaCha20Poly1305Algorithm
+			return NewCookieSessionStore(opts, cookieOpts)
+		}, nil)
+})
+
+var _ = Describe("Cookie SessionStore Tests with an overflow store", func() {
+	var ms *tests.MockStore
+	BeforeEach(func() {
+		ms = tests.NewMockStore()
+	})
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.CookieSessionStoreType
+			return NewCookieSessionStoreWithOverflow(opts, cookieOpts, persistence.NewManager(ms, cookieOpts))
+		}, nil)
+})
+
 func Test_copyCookie(t *testing.T) {
 	expire, _ := time.Parse(time.RFC3339, "2020-03-17T00:00:00Z")
 	c := &http.Cookie{
@@ -211,3 +235,187 @@ func Test_joinCookies_withUnderlineSuffix(t *testing.T) {
 		})
 	}
 }
+
+func Test_integrityHeader(t *testing.T) {
+	const secret = "0123456789abcdefghijklmnopqrstuv"
+
+	cookie := &http.Cookie{
+		Name:  "_oauth2_proxy",
+		Value: strings.Repeat("abcdefghij", 1000),
+	}
+	splitCookies := splitCookie(addIntegrityHeader(cookie, []byte(secret)))
+	assert.Equal(t, 3, len(splitCookies))
+
+	testCases := map[string]struct {
+		chunks         []*http.Cookie
+		expectedReason string
+	}{
+		"With all chunks": {
+			chunks: splitCookies,
+		},
+		"With a stale chunk of a larger session": {
+			chunks: append(append([]*http.Cookie{}, splitCookies...), &http.Cookie{Name: "_oauth2_proxy_3", Value: "stale"}),
+		},
+		"With a missing chunk": {
+			chunks:         splitCookies[:2],
+			expectedReason: integrityReasonTruncated,
+		},
+		"With a truncated chunk": {
+			chunks:         []*http.Cookie{splitCookies[0], splitCookies[1], {Name: "_oauth2_proxy_2", Value: splitCookies[2].Value[1:]}},
+			expectedReason: integrityReasonTruncated,
+		},
+		"With reordered chunks": {
+			chunks:         []*http.Cookie{splitCookies[0], {Name: "_oauth2_proxy_1", Value: splitCookies[2].Value}, {Name: "_oauth2_proxy_2", Value: splitCookies[1].Value}},
+			expectedReason: integrityReasonMAC,
+		},
+		"With a malformed header": {
+			chunks:         []*http.Cookie{{Name: "_oauth2_proxy_0", Value: "~abc|"}},
+			expectedReason: integrityReasonMalformed,
+		},
+	}
+	for testName, tc := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			joinedCookie, err := joinCookies(tc.chunks, cookie.Name)
+			assert.NoError(t, err)
+
+			checkedCookie, err := checkIntegrityHeader(joinedCookie, []byte(secret))
+			if tc.expectedReason == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, cookie.Value, checkedCookie.Value)
+				return
+			}
+			ie, ok := err.(*integrityError)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedReason, ie.reason)
+		})
+	}
+
+	t.Run("With a cookie split without a header", func(t *testing.T) {
+		joinedCookie, err := joinCookies(splitCookie(cookie), cookie.Name)
+		assert.NoError(t, err)
+
+		checkedCookie, err := checkIntegrityHeader(joinedCookie, []byte(secret))
+		assert.NoError(t, err)
+		assert.Equal(t, cookie.Value, checkedCookie.Value)
+	})
+}
+
+func TestSessionStore_LegacyNames(t *testing.T) {
+	cookieOpts := &options.Cookie{
+		Name:   "_oauth2_proxy",
+		Secret: []byte("0123456789abcdef"),
+		Path:   "/",
+		Expire: time.Hour,
+	}
+	legacyStore, err := NewCookieSessionStore(&options.SessionOptions{}, cookieOpts)
+	assert.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.NoError(t, legacyStore.Save(rw, req, &sessionsapi.SessionState{Email: "john@example.com"}))
+
+	renamedOpts := *cookieOpts
+	renamedOpts.Name = "__Host-oauth2_proxy"
+	renamedOpts.LegacyNames = []string{"_oauth2_proxy"}
+	store, err := NewCookieSessionStore(&options.SessionOptions{}, &renamedOpts)
+	assert.NoError(t, err)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	session, err := store.Load(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", session.Email)
+
+	rw = httptest.NewRecorder()
+	assert.NoError(t, store.Save(rw, req, session))
+	cookies := rw.Result().Cookies()
+	assert.Equal(t, 2, len(cookies))
+	assert.Equal(t, "__Host-oauth2_proxy", cookies[0].Name)
+	assert.NotEqual(t, "", cookies[0].Value)
+	assert.Equal(t, "_oauth2_proxy", cookies[1].Name)
+	assert.Equal(t, "", cookies[1].Value)
+}
+
+func TestSessionStore_Overflow(t *testing.T) {
+	cookieOpts := &options.Cookie{
+		Name:   "_oauth2_proxy",
+		Secret: []byte("0123456789abcdef"),
+		Path:   "/",
+		Expire: time.Hour,
+	}
+	ms := tests.NewMockStore()
+	store, err := NewCookieSessionStoreWithOverflow(&options.SessionOptions{}, cookieOpts, persistence.NewManager(ms, cookieOpts))
+	assert.NoError(t, err)
+
+	requestWith := func(cookies []*http.Cookie) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			if c.Value != "" {
+				req.AddCookie(c)
+			}
+		}
+		return req
+	}
+
+	t.Run("small sessions are stored in the cookie", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		assert.NoError(t, store.Save(rw, httptest.NewRequest("GET", "/", nil), &sessionsapi.SessionState{Email: "john@example.com"}))
+		cookies := rw.Result().Cookies()
+		assert.Equal(t, 1, len(cookies))
+		assert.False(t, store.(*SessionStore).hasTicket(requestWith(cookies)))
+	})
+
+	t.Run("large sessions are stored in the overflow store", func(t *testing.T) {
+		const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		token := make([]byte, 6000)
+		for i := range token {
+			token[i] = charset[mathrand.Intn(len(charset))]
+		}
+		session := &sessionsapi.SessionState{Email: "john@example.com", AccessToken: token}
+
+		// A session previously split into several cookies
+		splitStore, err := NewCookieSessionStore(&options.SessionOptions{}, cookieOpts)
+		assert.NoError(t, err)
+		rw := httptest.NewRecorder()
+		assert.NoError(t, splitStore.Save(rw, httptest.NewRequest("GET", "/", nil), session))
+		splitCookies := rw.Result().Cookies()
+		assert.True(t, len(splitCookies) > 1)
+
+		rw = httptest.NewRecorder()
+		assert.NoError(t, store.Save(rw, requestWith(splitCookies), session))
+		cookies := rw.Result().Cookies()
+		assert.Equal(t, len(splitCookies)+1, len(cookies))
+		var ticketCookie *http.Cookie
+		for _, c := range cookies {
+			if c.Name == cookieOpts.Name {
+				ticketCookie = c
+			} else {
+				assert.Equal(t, "", c.Value)
+			}
+		}
+		assert.True(t, len(ticketCookie.Value) < 200)
+
+		req := requestWith(cookies)
+		assert.True(t, store.(*SessionStore).hasTicket(req))
+		loaded, err := store.Load(req)
+		assert.NoError(t, err)
+		assert.Equal(t, session.AccessToken, loaded.AccessToken)
+
+		// The session remains in the overflow store when it is saved again
+		rw = httptest.NewRecorder()
+		assert.NoError(t, store.Save(rw, req, &sessionsapi.SessionState{Email: "john@example.com"}))
+		assert.Equal(t, 1, len(rw.Result().Cookies()))
+		for _, r := range []*http.Request{req, requestWith(rw.Result().Cookies())} {
+			loaded, err = store.Load(r)
+			assert.NoError(t, err)
+			assert.Empty(t, loaded.AccessToken)
+		}
+
+		// Clearing the session removes it from the overflow store
+		assert.NoError(t, store.Clear(httptest.NewRecorder(), req))
+		_, err = store.Load(req)
+		assert.Error(t, err)
+	})
+}
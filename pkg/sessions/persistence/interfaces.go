@@ -16,3 +16,27 @@ type Store interface {
 	Clear(context.Context, string) error
 	Lock(key string) sessions.Lock
 }
+
+// LockLoaderStore is a Store that loads a session along with the state of its
+// lock in a single round trip, so that checking the lock of a loaded session
+// does not need another request to the store.
+type LockLoaderStore interface {
+	Store
+	// LoadWithLock returns the value of a key with its lock.
+	LoadWithLock(context.Context, string) ([]byte, sessions.Lock, error)
+}
+
+// IndexStore is a Store that also indexes the keys of the sessions of each
+// user, so that the persistence.Manager can clear all of them at once.
+type IndexStore interface {
+	Store
+	// AddToIndex adds a key to an index with a value, and renews the
+	// expiration of the index.
+	AddToIndex(ctx context.Context, index string, key string, value []byte, exp time.Duration) error
+	// LoadIndex returns the keys of an index with their values.
+	LoadIndex(ctx context.Context, index string) (map[string][]byte, error)
+	// RemoveFromIndex removes a key from an index.
+	RemoveFromIndex(ctx context.Context, index string, key string) error
+	// ClearIndex deletes an index.
+	ClearIndex(ctx context.Context, index string) error
+}
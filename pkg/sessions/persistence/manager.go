@@ -1,12 +1,18 @@
 package persistence
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 )
 
 // Manager wraps a Store and handles the implementation details of the
@@ -14,8 +20,15 @@ import (
 type Manager struct {
 	Store   Store
 	Options *options.Cookie
+
+	// index indexes the sessions of each user, if they are indexed
+	index IndexStore
+	// indexCipher encrypts the secrets of the tickets in the index
+	indexCipher encryption.Cipher
 }
 
+var _ sessions.UserSessionStore = (*Manager)(nil)
+
 // NewManager creates a Manager that can wrap a Store and manage the
 // sessions.SessionStore implementation details
 func NewManager(store Store, cookieOpts *options.Cookie) *Manager {
@@ -25,6 +38,32 @@ func NewManager(store Store, cookieOpts *options.Cookie) *Manager {
 	}
 }
 
+// NewIndexedManager creates a Manager that also indexes the sessions of each
+// user in the IndexStore, so that all of them can be cleared with
+// ClearUserSessions. The index holds the secrets of the tickets, encrypted
+// with the cookie secret, to load the sessions it clears.
+func NewIndexedManager(store IndexStore, cookieOpts *options.Cookie) (*Manager, error) {
+	secret := encryption.SecretBytes(cookieOpts.Secret)
+	defer encryption.Wipe(secret)
+	cipher, err := encryption.NewCipher(cookieOpts.Cipher, secret, cookieOpts.CipherRejectLegacy)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising the session index cipher: %v", err)
+	}
+
+	m := NewManager(store, cookieOpts)
+	m.index = store
+	m.indexCipher = cipher
+	return m, nil
+}
+
+// Close closes the Store, when it holds connections to close.
+func (m *Manager) Close() error {
+	if closer, ok := m.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 // Save saves a session in a persistent Store. Save will generate (or reuse an
 // existing) ticket which manages unique per session encryption & retrieval
 // from the persistent data store.
@@ -40,6 +79,7 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 			return fmt.Errorf("error creating a session ticket: %v", err)
 		}
 	}
+	defer tckt.wipe()
 
 	err = tckt.saveSession(s, func(key string, val []byte, exp time.Duration) error {
 		return m.Store.Save(req.Context(), key, val, exp)
@@ -47,6 +87,9 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 	if err != nil {
 		return err
 	}
+	if err := m.indexSession(req.Context(), tckt, s); err != nil {
+		return err
+	}
 
 	return tckt.setCookie(rw, req, s)
 }
@@ -58,17 +101,51 @@ func (m *Manager) Load(req *http.Request) (*sessions.SessionState, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer tckt.wipe()
+
+	return tckt.loadSession(m.sessionLoader(req.Context()))
+}
+
+// sessionLoader returns the functions that load a session and its lock from
+// the Store. When the Store loads the lock along with the session, the lock
+// loaded is returned for the session.
+func (m *Manager) sessionLoader(ctx context.Context) (loadFunc, initLockFunc) {
+	store, ok := m.Store.(LockLoaderStore)
+	if !ok {
+		return func(key string) ([]byte, error) {
+			return m.Store.Load(ctx, key)
+		}, m.Store.Lock
+	}
+
+	var lock sessions.Lock
+	loader := func(key string) ([]byte, error) {
+		value, loadedLock, err := store.LoadWithLock(ctx, key)
+		lock = loadedLock
+		return value, err
+	}
+	initLock := func(key string) sessions.Lock {
+		if lock != nil {
+			return lock
+		}
+		return m.Store.Lock(key)
+	}
+	return loader, initLock
+}
 
-	return tckt.loadSession(
-		func(key string) ([]byte, error) {
-			return m.Store.Load(req.Context(), key)
-		},
-		m.Store.Lock,
-	)
+// HasTicket returns whether the request has a valid session ticket cookie,
+// rather than a session cookie of the cookie session store.
+func (m *Manager) HasTicket(req *http.Request) bool {
+	tckt, err := decodeTicketFromRequest(req, m.Options)
+	if err != nil {
+		return false
+	}
+	defer tckt.wipe()
+	return tckt.isValid()
 }
 
 // Clear clears any saved session information for a given ticket cookie.
-// Then it clears all session data for that ticket in the Store.
+// Then it clears all session data for that ticket in the Store, and removes
+// the ticket from the index of the sessions of its user.
 func (m *Manager) Clear(rw http.ResponseWriter, req *http.Request) error {
 	tckt, err := decodeTicketFromRequest(req, m.Options)
 	if err != nil {
@@ -85,8 +162,117 @@ func (m *Manager) Clear(rw http.ResponseWriter, req *http.Request) error {
 		return fmt.Errorf("error decoding ticket to clear session: %v", err)
 	}
 
+	defer tckt.wipe()
+
+	// The session is loaded for the user whose index holds the ticket
+	user := ""
+	if m.index != nil {
+		if s, err := tckt.loadSession(m.sessionLoader(req.Context())); err == nil {
+			user = indexUser(s)
+			s.Wipe()
+		}
+	}
+
 	tckt.clearCookie(rw, req)
-	return tckt.clearSession(func(key string) error {
+	err = tckt.clearSession(func(key string) error {
 		return m.Store.Clear(req.Context(), key)
 	})
+	if err != nil || user == "" {
+		return err
+	}
+	return m.index.RemoveFromIndex(req.Context(), m.indexKey(user), tckt.id)
+}
+
+// ClearUserSessions clears all of the indexed sessions of the user of the
+// session from the Store, and returns the sessions that had not expired.
+// The ticket cookies left on the other devices of the user no longer load a
+// session.
+func (m *Manager) ClearUserSessions(ctx context.Context, s *sessions.SessionState) ([]*sessions.SessionState, error) {
+	if m.index == nil {
+		return nil, errors.New("the sessions of users are not indexed")
+	}
+	user := indexUser(s)
+	if user == "" {
+		return nil, errors.New("the session has no user to clear the sessions of")
+	}
+
+	index := m.indexKey(user)
+	entries, err := m.index.LoadIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	cleared := []*sessions.SessionState{}
+	for _, id := range ids {
+		session, err := m.clearIndexedSession(ctx, id, entries[id])
+		if err != nil {
+			return cleared, err
+		}
+		if session != nil {
+			cleared = append(cleared, session)
+		}
+	}
+	return cleared, m.index.ClearIndex(ctx, index)
+}
+
+// indexSession adds the ticket of the session to the index of the sessions
+// of its user, when sessions are indexed.
+func (m *Manager) indexSession(ctx context.Context, tckt *ticket, s *sessions.SessionState) error {
+	user := indexUser(s)
+	if m.index == nil || user == "" {
+		return nil
+	}
+
+	secret, err := m.indexCipher.Encrypt(tckt.secret)
+	if err != nil {
+		return fmt.Errorf("error encrypting the session ticket for the index: %v", err)
+	}
+	return m.index.AddToIndex(ctx, m.indexKey(user), tckt.id, secret, m.Options.Expire)
+}
+
+// clearIndexedSession clears a session of the index, and returns it unless
+// it could not be loaded, eg when it expired or its user signed out of it.
+func (m *Manager) clearIndexedSession(ctx context.Context, id string, encryptedSecret []byte) (*sessions.SessionState, error) {
+	secret, err := m.indexCipher.Decrypt(encryptedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting the session ticket from the index: %v", err)
+	}
+	tckt := &ticket{
+		id:      id,
+		secret:  secret,
+		options: m.Options,
+	}
+	defer tckt.wipe()
+
+	session, err := tckt.loadSession(m.sessionLoader(ctx))
+	if err != nil {
+		session = nil
+	}
+
+	err = tckt.clearSession(func(key string) error {
+		return m.Store.Clear(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// indexKey returns the key of the index of the sessions of a user, which
+// hashes the user so that it is not stored in the clear.
+func (m *Manager) indexKey(user string) string {
+	return fmt.Sprintf("%s-index-%x", m.Options.Name, sha256.Sum256([]byte(user)))
+}
+
+// indexUser returns the user whose sessions a session is indexed with.
+func indexUser(s *sessions.SessionState) string {
+	if s.Email != "" {
+		return s.Email
+	}
+	return s.User
 }
@@ -1,12 +1,16 @@
 package persistence
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Persistence Manager Tests", func() {
@@ -23,3 +27,105 @@ var _ = Describe("Persistence Manager Tests", func() {
 			return nil
 		})
 })
+
+var _ = Describe("Indexed Persistence Manager Tests", func() {
+	var ms *tests.MockStore
+	BeforeEach(func() {
+		ms = tests.NewMockStore()
+	})
+	tests.RunSessionStoreTests(
+		func(_ *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			return NewIndexedManager(ms, cookieOpts)
+		},
+		func(d time.Duration) error {
+			ms.FastForward(d)
+			return nil
+		})
+
+	Context("ClearUserSessions", func() {
+		var m *Manager
+
+		BeforeEach(func() {
+			var err error
+			m, err = NewIndexedManager(ms, &options.Cookie{
+				Name:   "_oauth2_proxy",
+				Secret: []byte("0123456789abcdef"),
+				Path:   "/",
+				Expire: time.Hour,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		// save saves a session on a new device, returning its request
+		save := func(s *sessionsapi.SessionState) *http.Request {
+			rw := httptest.NewRecorder()
+			Expect(m.Save(rw, httptest.NewRequest("GET", "/", nil), s)).To(Succeed())
+
+			req := httptest.NewRequest("GET", "/", nil)
+			for _, c := range rw.Result().Cookies() {
+				req.AddCookie(c)
+			}
+			return req
+		}
+
+		It("clears the sessions of the user on every device", func() {
+			laptop := save(&sessionsapi.SessionState{Email: "john.doe@example.com", RefreshToken: []byte("laptop")})
+			phone := save(&sessionsapi.SessionState{Email: "john.doe@example.com", RefreshToken: []byte("phone")})
+			other := save(&sessionsapi.SessionState{Email: "jane.doe@example.com", RefreshToken: []byte("other")})
+
+			session, err := m.Load(laptop)
+			Expect(err).ToNot(HaveOccurred())
+
+			cleared, err := m.ClearUserSessions(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			refreshTokens := []string{}
+			for _, s := range cleared {
+				refreshTokens = append(refreshTokens, string(s.RefreshToken))
+			}
+			Expect(refreshTokens).To(ConsistOf("laptop", "phone"))
+
+			_, err = m.Load(laptop)
+			Expect(err).To(HaveOccurred())
+			_, err = m.Load(phone)
+			Expect(err).To(HaveOccurred())
+			_, err = m.Load(other)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("skips the sessions that were already cleared", func() {
+			laptop := save(&sessionsapi.SessionState{Email: "john.doe@example.com"})
+			phone := save(&sessionsapi.SessionState{Email: "john.doe@example.com"})
+			Expect(m.Clear(httptest.NewRecorder(), phone)).To(Succeed())
+
+			session, err := m.Load(laptop)
+			Expect(err).ToNot(HaveOccurred())
+			cleared, err := m.ClearUserSessions(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cleared).To(HaveLen(1))
+
+			cleared, err = m.ClearUserSessions(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cleared).To(BeEmpty())
+		})
+
+		It("removes the sessions that are cleared from the index", func() {
+			laptop := save(&sessionsapi.SessionState{Email: "john.doe@example.com"})
+			save(&sessionsapi.SessionState{Email: "john.doe@example.com"})
+			index := m.indexKey("john.doe@example.com")
+
+			entries, err := ms.LoadIndex(context.Background(), index)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+
+			Expect(m.Clear(httptest.NewRecorder(), laptop)).To(Succeed())
+			entries, err = ms.LoadIndex(context.Background(), index)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+
+		It("errors when the sessions are not indexed", func() {
+			_, err := NewManager(ms, m.Options).ClearUserSessions(context.Background(), &sessionsapi.SessionState{Email: "john.doe@example.com"})
+			Expect(err).To(MatchError("the sessions of users are not indexed"))
+		})
+	})
+})
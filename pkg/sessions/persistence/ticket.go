@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -90,10 +91,27 @@ func decodeTicket(encTicket string, cookieOpts *options.Cookie) (*ticket, error)
 	}, nil
 }
 
-// decodeTicketFromRequest retrieves a potential ticket cookie from a request
-// and decodes it to a ticket.
+// ticketIDPattern matches the IDs of tickets: the cookie name when the ticket
+// was created and the hex encoded random ID
+var ticketIDPattern = regexp.MustCompile(`-[0-9a-f]{32}$`)
+
+// isValid returns whether the ticket has the format of the tickets created by
+// newTicket, so that tickets can be told apart from session cookies.
+func (t *ticket) isValid() bool {
+	return ticketIDPattern.MatchString(t.id) && len(t.secret) == aes.BlockSize
+}
+
+// decodeTicketFromRequest retrieves a potential ticket cookie from a request,
+// with the cookie name or one of its legacy names, and decodes it to a ticket.
 func decodeTicketFromRequest(req *http.Request, cookieOpts *options.Cookie) (*ticket, error) {
-	requestCookie, err := req.Cookie(cookieOpts.Name)
+	var requestCookie *http.Cookie
+	err := http.ErrNoCookie
+	for _, name := range cookies.ReadNames(cookieOpts) {
+		requestCookie, err = req.Cookie(name)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		// Don't wrap this error to allow `err == http.ErrNoCookie` checks
 		return nil, err
@@ -152,7 +170,8 @@ func (t *ticket) clearSession(clearer clearFunc) error {
 	return clearer(t.id)
 }
 
-// setCookie sets the encoded ticket as a cookie
+// setCookie sets the encoded ticket as a cookie, which expires when the
+// browser is closed for browser sessions
 func (t *ticket) setCookie(rw http.ResponseWriter, req *http.Request, s *sessions.SessionState) error {
 	ticketCookie, err := t.makeCookie(
 		req,
@@ -163,8 +182,12 @@ func (t *ticket) setCookie(rw http.ResponseWriter, req *http.Request, s *session
 	if err != nil {
 		return err
 	}
+	if s.BrowserSession {
+		ticketCookie.Expires = time.Time{}
+	}
 
 	http.SetCookie(rw, ticketCookie)
+	cookies.ClearLegacyCookies(rw, req, t.options)
 	return nil
 }
 
@@ -179,6 +202,7 @@ func (t *ticket) clearCookie(rw http.ResponseWriter, req *http.Request) {
 		time.Hour*-1,
 		time.Now(),
 	))
+	cookies.ClearLegacyCookies(rw, req, t.options)
 }
 
 // makeCookie makes a cookie, signing the value if present
@@ -200,6 +224,12 @@ func (t *ticket) makeCookie(req *http.Request, value string, expires time.Durati
 	), nil
 }
 
+// wipe overwrites the ticket's secret once the ticket has been used, as the
+// secret decrypts the session in the store.
+func (t *ticket) wipe() {
+	encryption.Wipe(t.secret)
+}
+
 // makeCipher makes a AES-GCM cipher out of the ticket's secret
 func (t *ticket) makeCipher() (encryption.Cipher, error) {
 	c, err := encryption.NewGCMCipher(t.secret)
@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -158,4 +160,99 @@ var _ = Describe("Session Ticket Tests", func() {
 			Expect(err).To(MatchError(errors.New("clear error")))
 		})
 	})
+
+	Context("isValid", func() {
+		It("tells tickets apart from other values", func() {
+			t, err := newTicket(&options.Cookie{Name: "_oauth2_proxy"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.isValid()).To(BeTrue())
+
+			dec, err := decodeTicket("session.data", &options.Cookie{Name: "_oauth2_proxy"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dec.isValid()).To(BeFalse())
+		})
+	})
+
+	Context("setCookie", func() {
+		var t *ticket
+
+		BeforeEach(func() {
+			var err error
+			t, err = newTicket(&options.Cookie{
+				Name:   "_oauth2_proxy",
+				Secret: []byte("0123456789abcdef"),
+				Path:   "/",
+				Expire: time.Hour,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("sets a cookie expiring after the cookie expiry", func() {
+			rw := httptest.NewRecorder()
+			created := time.Now()
+			Expect(t.setCookie(rw, httptest.NewRequest("GET", "/", nil), &sessions.SessionState{CreatedAt: &created})).To(Succeed())
+
+			cookies := rw.Result().Cookies()
+			Expect(cookies).To(HaveLen(1))
+			Expect(cookies[0].Expires).To(BeTemporally("~", created.Add(time.Hour), time.Second))
+		})
+
+		It("sets a cookie expiring when the browser is closed for browser sessions", func() {
+			rw := httptest.NewRecorder()
+			created := time.Now()
+			Expect(t.setCookie(rw, httptest.NewRequest("GET", "/", nil), &sessions.SessionState{CreatedAt: &created, BrowserSession: true})).To(Succeed())
+
+			cookies := rw.Result().Cookies()
+			Expect(cookies).To(HaveLen(1))
+			Expect(cookies[0].Value).ToNot(BeEmpty())
+			Expect(cookies[0].Expires.IsZero()).To(BeTrue())
+		})
+	})
+
+	Context("decodeTicketFromRequest", func() {
+		var cookieOpts *options.Cookie
+
+		BeforeEach(func() {
+			cookieOpts = &options.Cookie{
+				Name:        "__Host-oauth2_proxy",
+				LegacyNames: []string{"_oauth2_proxy"},
+				Secret:      []byte("0123456789abcdef"),
+				Path:        "/",
+				Expire:      time.Hour,
+			}
+		})
+
+		It("decodes the ticket of a cookie with a legacy name", func() {
+			legacyOpts := *cookieOpts
+			legacyOpts.Name = "_oauth2_proxy"
+			t, err := newTicket(&legacyOpts)
+			Expect(err).ToNot(HaveOccurred())
+			legacyCookie, err := t.makeCookie(httptest.NewRequest("GET", "/", nil), t.encodeTicket(), time.Hour, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(legacyCookie)
+			dec, err := decodeTicketFromRequest(req, cookieOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dec.id).To(Equal(t.id))
+			Expect(dec.secret).To(Equal(t.secret))
+
+			By("clearing the legacy cookie when the ticket is saved with the cookie name")
+			rw := httptest.NewRecorder()
+			created := time.Now()
+			Expect(dec.setCookie(rw, req, &sessions.SessionState{CreatedAt: &created})).To(Succeed())
+
+			cookies := rw.Result().Cookies()
+			Expect(cookies).To(HaveLen(2))
+			Expect(cookies[0].Name).To(Equal("__Host-oauth2_proxy"))
+			Expect(cookies[0].Value).ToNot(BeEmpty())
+			Expect(cookies[1].Name).To(Equal("_oauth2_proxy"))
+			Expect(cookies[1].Value).To(BeEmpty())
+		})
+
+		It("returns http.ErrNoCookie without a cookie", func() {
+			_, err := decodeTicketFromRequest(httptest.NewRequest("GET", "/", nil), cookieOpts)
+			Expect(err).To(Equal(http.ErrNoCookie))
+		})
+	})
 })
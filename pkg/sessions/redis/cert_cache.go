@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// CertCache is an autocert.Cache that stores ACME certificates and the
+// account key in redis so that they are shared by all replicas of the proxy.
+type CertCache struct {
+	Client Client
+	Prefix string
+}
+
+var _ autocert.Cache = (*CertCache)(nil)
+
+// NewCertCache creates a CertCache storing entries under keys with the prefix.
+func NewCertCache(client Client, prefix string) *CertCache {
+	return &CertCache{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+// Get returns the cached data, or autocert.ErrCacheMiss if it is not cached.
+func (c *CertCache) Get(ctx context.Context, name string) ([]byte, error) {
+	data, err := c.Client.Get(ctx, c.Prefix+name)
+	if errors.Is(err, redis.Nil) {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, err
+}
+
+// Put stores the data without an expiry, autocert renews certificates before
+// they expire.
+func (c *CertCache) Put(ctx context.Context, name string, data []byte) error {
+	return c.Client.Set(ctx, c.Prefix+name, data, 0)
+}
+
+// Delete removes the data from the cache.
+func (c *CertCache) Delete(ctx context.Context, name string) error {
+	return c.Client.Del(ctx, c.Prefix+name)
+}
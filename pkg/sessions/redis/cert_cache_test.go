@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var _ = Describe("Redis CertCache Tests", func() {
+	var mr *miniredis.Miniredis
+	var cache *CertCache
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		client, err := NewRedisClient(options.RedisStoreOptions{
+			ConnectionURL: "redis://" + mr.Addr(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		cache = NewCertCache(client, "_oauth2_proxy-acme-")
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("stores, loads and deletes entries", func() {
+		ctx := context.Background()
+		Expect(cache.Put(ctx, "example.com", []byte("certificate"))).To(Succeed())
+		Expect(mr.Exists("_oauth2_proxy-acme-example.com")).To(BeTrue())
+
+		data, err := cache.Get(ctx, "example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("certificate")))
+
+		Expect(cache.Delete(ctx, "example.com")).To(Succeed())
+		_, err = cache.Get(ctx, "example.com")
+		Expect(err).To(Equal(autocert.ErrCacheMiss))
+	})
+})
@@ -11,9 +11,15 @@ import (
 // Client is wrapper interface for redis.Client and redis.ClusterClient.
 type Client interface {
 	Get(ctx context.Context, key string) ([]byte, error)
+	GetWithLock(ctx context.Context, key string) ([]byte, sessions.Lock, error)
 	Lock(key string) sessions.Lock
 	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error)
 	Del(ctx context.Context, key string) error
+	HSet(ctx context.Context, key, field string, value []byte, expiration time.Duration) error
+	HGetAll(ctx context.Context, key string) (map[string][]byte, error)
+	HDel(ctx context.Context, key, field string) error
+	Close() error
 }
 
 var _ Client = (*client)(nil)
@@ -32,14 +38,40 @@ func (c *client) Get(ctx context.Context, key string) ([]byte, error) {
 	return c.Client.Get(ctx, key).Bytes()
 }
 
+func (c *client) GetWithLock(ctx context.Context, key string) ([]byte, sessions.Lock, error) {
+	return getWithLock(ctx, c.Client, key)
+}
+
 func (c *client) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
 	return c.Client.Set(ctx, key, value, expiration).Err()
 }
 
+func (c *client) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	return c.Client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (c *client) Del(ctx context.Context, key string) error {
 	return c.Client.Del(ctx, key).Err()
 }
 
+func (c *client) HSet(ctx context.Context, key, field string, value []byte, expiration time.Duration) error {
+	_, err := c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, value)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *client) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := c.Client.HGetAll(ctx, key).Result()
+	return hashBytes(values), err
+}
+
+func (c *client) HDel(ctx context.Context, key, field string) error {
+	return c.Client.HDel(ctx, key, field).Err()
+}
+
 func (c *client) Lock(key string) sessions.Lock {
 	return NewLock(c.Client, key)
 }
@@ -60,14 +92,69 @@ func (c *clusterClient) Get(ctx context.Context, key string) ([]byte, error) {
 	return c.ClusterClient.Get(ctx, key).Bytes()
 }
 
+func (c *clusterClient) GetWithLock(ctx context.Context, key string) ([]byte, sessions.Lock, error) {
+	return getWithLock(ctx, c.ClusterClient, key)
+}
+
 func (c *clusterClient) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
 	return c.ClusterClient.Set(ctx, key, value, expiration).Err()
 }
 
+func (c *clusterClient) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	return c.ClusterClient.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (c *clusterClient) Del(ctx context.Context, key string) error {
 	return c.ClusterClient.Del(ctx, key).Err()
 }
 
+func (c *clusterClient) HSet(ctx context.Context, key, field string, value []byte, expiration time.Duration) error {
+	_, err := c.ClusterClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, value)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *clusterClient) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := c.ClusterClient.HGetAll(ctx, key).Result()
+	return hashBytes(values), err
+}
+
+func (c *clusterClient) HDel(ctx context.Context, key, field string) error {
+	return c.ClusterClient.HDel(ctx, key, field).Err()
+}
+
 func (c *clusterClient) Lock(key string) sessions.Lock {
 	return NewLock(c.ClusterClient, key)
 }
+
+// getWithLock gets the value of the key and checks whether its lock is held
+// in a single pipeline, and returns the value with its lock.
+func getWithLock(ctx context.Context, c redis.Cmdable, key string) ([]byte, sessions.Lock, error) {
+	var get *redis.StringCmd
+	var exists *redis.IntCmd
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		exists = pipe.Exists(ctx, lockKey(key))
+		return nil
+	})
+	value, getErr := get.Bytes()
+	if getErr != nil {
+		return nil, nil, getErr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, newLoadedLock(c, key, exists.Val() > 0), nil
+}
+
+// hashBytes converts the values of a redis hash to bytes
+func hashBytes(values map[string]string) map[string][]byte {
+	hash := make(map[string][]byte, len(values))
+	for field, value := range values {
+		hash[field] = []byte(value)
+	}
+	return hash
+}
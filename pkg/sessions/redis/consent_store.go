@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// ConsentStore is a sessions.ConsentStore that records the version of the
+// consent terms each user accepted in redis, without an expiry, so that it is
+// shared by all replicas of the proxy and outlives the sessions of the user.
+type ConsentStore struct {
+	Client Client
+	Prefix string
+}
+
+var _ sessions.ConsentStore = (*ConsentStore)(nil)
+
+// NewConsentStore creates a ConsentStore storing the versions under keys with
+// the prefix.
+func NewConsentStore(client Client, prefix string) *ConsentStore {
+	return &ConsentStore{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+// AcceptedVersion returns the version of the terms the user accepted, or an
+// empty string when they have not accepted any.
+func (c *ConsentStore) AcceptedVersion(ctx context.Context, user string) (string, error) {
+	version, err := c.Client.Get(ctx, c.Prefix+user)
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return string(version), err
+}
+
+// Accept records that the user accepted the version of the terms.
+func (c *ConsentStore) Accept(ctx context.Context, user string, version string) error {
+	return c.Client.Set(ctx, c.Prefix+user, []byte(version), 0)
+}
+
+// Close closes the connections of the client to redis.
+func (c *ConsentStore) Close() error {
+	return c.Client.Close()
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redis ConsentStore Tests", func() {
+	var mr *miniredis.Miniredis
+	var store *ConsentStore
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		client, err := NewRedisClient(options.RedisStoreOptions{
+			ConnectionURL: "redis://" + mr.Addr(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		store = NewConsentStore(client, "_oauth2_proxy-consent-")
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("returns no version for users who have not accepted the terms", func() {
+		version, err := store.AcceptedVersion(context.Background(), "john.doe@example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).To(BeEmpty())
+	})
+
+	It("returns the version of the terms the user last accepted", func() {
+		ctx := context.Background()
+		Expect(store.Accept(ctx, "john.doe@example.com", "1")).To(Succeed())
+		Expect(store.Accept(ctx, "john.doe@example.com", "2")).To(Succeed())
+		Expect(mr.TTL("_oauth2_proxy-consent-john.doe@example.com")).To(BeZero())
+
+		version, err := store.AcceptedVersion(ctx, "john.doe@example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).To(Equal("2"))
+	})
+})
@@ -18,6 +18,11 @@ type Lock struct {
 	locker *redislock.Client
 	lock   *redislock.Lock
 	key    string
+
+	// loadedLocked is whether the lock was held when the session was loaded
+	// along with it. It answers the next Peek without a round trip to Redis,
+	// unless the lock is used first.
+	loadedLocked *bool
 }
 
 // NewLock instantiate a new lock instance. This will not yet apply a lock on Redis side.
@@ -30,8 +35,20 @@ func NewLock(client redis.Cmdable, key string) sessions.Lock {
 	}
 }
 
+// newLoadedLock instantiates a lock for a session that was loaded along with
+// the state of the lock.
+func newLoadedLock(client redis.Cmdable, key string, locked bool) sessions.Lock {
+	return &Lock{
+		client:       client,
+		locker:       redislock.New(client),
+		key:          key,
+		loadedLocked: &locked,
+	}
+}
+
 // Obtain obtains a distributed lock on Redis for the configured key.
 func (l *Lock) Obtain(ctx context.Context, expiration time.Duration) error {
+	l.loadedLocked = nil
 	lock, err := l.locker.Obtain(ctx, l.lockKey(), expiration, nil)
 	if errors.Is(err, redislock.ErrNotObtained) {
 		return sessions.ErrLockNotObtained
@@ -45,6 +62,7 @@ func (l *Lock) Obtain(ctx context.Context, expiration time.Duration) error {
 
 // Refresh refreshes an already existing lock.
 func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
+	l.loadedLocked = nil
 	if l.lock == nil {
 		return sessions.ErrNotLocked
 	}
@@ -56,7 +74,14 @@ func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
 }
 
 // Peek returns true, if the lock is still applied.
+// The first Peek of a lock loaded with its session returns whether the lock
+// was applied when the session was loaded.
 func (l *Lock) Peek(ctx context.Context) (bool, error) {
+	if l.loadedLocked != nil {
+		locked := *l.loadedLocked
+		l.loadedLocked = nil
+		return locked, nil
+	}
 	v, err := l.client.Exists(ctx, l.lockKey()).Result()
 	if err != nil {
 		return false, err
@@ -69,6 +94,7 @@ func (l *Lock) Peek(ctx context.Context) (bool, error) {
 
 // Release releases the lock on Redis side.
 func (l *Lock) Release(ctx context.Context) error {
+	l.loadedLocked = nil
 	if l.lock == nil {
 		return sessions.ErrNotLocked
 	}
@@ -80,5 +106,10 @@ func (l *Lock) Release(ctx context.Context) error {
 }
 
 func (l *Lock) lockKey() string {
-	return fmt.Sprintf("%s.%s", l.key, LockSuffix)
+	return lockKey(l.key)
+}
+
+// lockKey returns the Redis key of the lock of a key.
+func lockKey(key string) string {
+	return fmt.Sprintf("%s.%s", key, LockSuffix)
 }
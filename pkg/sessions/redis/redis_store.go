@@ -13,6 +13,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/spiffe"
 )
 
 // SessionStore is an implementation of the persistence.Store
@@ -21,8 +22,12 @@ type SessionStore struct {
 	Client Client
 }
 
+var _ persistence.IndexStore = (*SessionStore)(nil)
+var _ persistence.LockLoaderStore = (*SessionStore)(nil)
+
 // NewRedisSessionStore initialises a new instance of the SessionStore and wraps
-// it in a persistence.Manager
+// it in a persistence.Manager, which indexes the sessions of each user when
+// users can sign out everywhere
 func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
 	client, err := NewRedisClient(opts.Redis)
 	if err != nil {
@@ -32,6 +37,9 @@ func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cook
 	rs := &SessionStore{
 		Client: client,
 	}
+	if opts.SignOutEverywhere {
+		return persistence.NewIndexedManager(rs, cookieOpts)
+	}
 	return persistence.NewManager(rs, cookieOpts), nil
 }
 
@@ -55,6 +63,16 @@ func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error)
 	return value, nil
 }
 
+// LoadWithLock reads the session information of a persistence cookie along
+// with whether its lock is held, in a single round trip to redis
+func (store *SessionStore) LoadWithLock(ctx context.Context, key string) ([]byte, sessions.Lock, error) {
+	value, lock, err := store.Client.GetWithLock(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading redis session: %v", err)
+	}
+	return value, lock, nil
+}
+
 // Clear clears any saved session information for a given persistence cookie
 // from redis, and then clears the session
 func (store *SessionStore) Clear(ctx context.Context, key string) error {
@@ -65,11 +83,67 @@ func (store *SessionStore) Clear(ctx context.Context, key string) error {
 	return nil
 }
 
+// AddToIndex adds the key of a session to the index of the sessions of a user
+// in redis, and renews the expiration of the index
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, key string, value []byte, exp time.Duration) error {
+	err := store.Client.HSet(ctx, index, key, value, exp)
+	if err != nil {
+		return fmt.Errorf("error indexing the redis session: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads the keys of the sessions of a user from redis
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) (map[string][]byte, error) {
+	keys, err := store.Client.HGetAll(ctx, index)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the redis session index: %v", err)
+	}
+	return keys, nil
+}
+
+// RemoveFromIndex removes the key of a session from the index of the sessions
+// of a user in redis
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, key string) error {
+	err := store.Client.HDel(ctx, index, key)
+	if err != nil {
+		return fmt.Errorf("error removing the redis session from the index: %v", err)
+	}
+	return nil
+}
+
+// ClearIndex clears the index of the sessions of a user from redis
+func (store *SessionStore) ClearIndex(ctx context.Context, index string) error {
+	err := store.Client.Del(ctx, index)
+	if err != nil {
+		return fmt.Errorf("error clearing the session index from redis: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connections of the client to redis
+func (store *SessionStore) Close() error {
+	return store.Client.Close()
+}
+
 // Lock creates a lock object for sessions.SessionState
 func (store *SessionStore) Lock(key string) sessions.Lock {
 	return store.Client.Lock(key)
 }
 
+// HasConnection returns whether a connection to Redis is configured by the
+// options.
+func HasConnection(opts options.RedisStoreOptions) bool {
+	switch {
+	case opts.UseSentinel:
+		return len(opts.SentinelConnectionURLs) > 0
+	case opts.UseCluster:
+		return len(opts.ClusterConnectionURLs) > 0
+	default:
+		return opts.ConnectionURL != ""
+	}
+}
+
 // NewRedisClient makes a redis.Client (either standalone, sentinel aware, or
 // redis cluster)
 func NewRedisClient(opts options.RedisStoreOptions) (Client, error) {
@@ -105,6 +179,12 @@ func buildSentinelClient(opts options.RedisStoreOptions) (Client, error) {
 		Password:         opts.Password,
 		TLSConfig:        opt.TLSConfig,
 		IdleTimeout:      time.Duration(opts.IdleTimeout) * time.Second,
+		PoolSize:         opts.PoolSize,
+		MinIdleConns:     opts.MinIdleConns,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolTimeout:      opts.PoolTimeout,
 	})
 	return newClient(client), nil
 }
@@ -121,10 +201,16 @@ func buildClusterClient(opts options.RedisStoreOptions) (Client, error) {
 	}
 
 	client := redis.NewClusterClient(&redis.ClusterOptions{
-		Addrs:       addrs,
-		Password:    opts.Password,
-		TLSConfig:   opt.TLSConfig,
-		IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+		Addrs:        addrs,
+		Password:     opts.Password,
+		TLSConfig:    opt.TLSConfig,
+		IdleTimeout:  time.Duration(opts.IdleTimeout) * time.Second,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolTimeout:  opts.PoolTimeout,
 	})
 	return newClusterClient(client), nil
 }
@@ -146,6 +232,12 @@ func buildStandaloneClient(opts options.RedisStoreOptions) (Client, error) {
 	}
 
 	opt.IdleTimeout = time.Duration(opts.IdleTimeout) * time.Second
+	opt.PoolSize = opts.PoolSize
+	opt.MinIdleConns = opts.MinIdleConns
+	opt.DialTimeout = opts.DialTimeout
+	opt.ReadTimeout = opts.ReadTimeout
+	opt.WriteTimeout = opts.WriteTimeout
+	opt.PoolTimeout = opts.PoolTimeout
 
 	client := redis.NewClient(opt)
 	return newClient(client), nil
@@ -187,6 +279,17 @@ func setupTLSConfig(opts options.RedisStoreOptions, opt *redis.Options) error {
 
 		opt.TLSConfig.RootCAs = rootCAs
 	}
+
+	if opts.UseSPIFFE {
+		source, err := spiffe.GetSource(opts.SPIFFEEndpointSocket)
+		if err != nil {
+			return fmt.Errorf("could not create SPIFFE source for redis connection: %v", err)
+		}
+		if opt.TLSConfig == nil {
+			opt.TLSConfig = &tls.Config{}
+		}
+		source.ConfigureClientTLS(opt.TLSConfig, opts.SPIFFEIDs)
+	}
 	return nil
 }
 
@@ -122,6 +122,130 @@ var _ = Describe("Redis SessionStore Tests", func() {
 		},
 	)
 
+	Context("with sign out everywhere", func() {
+		tests.RunSessionStoreTests(
+			func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+				opts.Type = options.RedisSessionStoreType
+				opts.Redis.ConnectionURL = "redis://" + mr.Addr()
+				opts.SignOutEverywhere = true
+
+				var err error
+				ss, err = NewRedisSessionStore(opts, cookieOpts)
+				return ss, err
+			},
+			func(d time.Duration) error {
+				mr.FastForward(d)
+				return nil
+			},
+		)
+
+		It("indexes the sessions of each user until they expire", func() {
+			var err error
+			ss, err = NewRedisSessionStore(&options.SessionOptions{
+				Redis:             options.RedisStoreOptions{ConnectionURL: "redis://" + mr.Addr()},
+				SignOutEverywhere: true,
+			}, &options.Cookie{Name: "_oauth2_proxy", Secret: []byte("0123456789abcdef")})
+			Expect(err).ToNot(HaveOccurred())
+			store := ss.(*persistence.Manager).Store.(*SessionStore)
+			ctx := context.Background()
+
+			Expect(store.AddToIndex(ctx, "index", "laptop", []byte("secret1"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "phone", []byte("secret2"), time.Hour)).To(Succeed())
+			keys, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(Equal(map[string][]byte{"laptop": []byte("secret1"), "phone": []byte("secret2")}))
+
+			mr.FastForward(2 * time.Hour)
+			keys, err = store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(BeEmpty())
+
+			Expect(store.AddToIndex(ctx, "index", "laptop", []byte("secret1"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "phone", []byte("secret2"), time.Hour)).To(Succeed())
+			Expect(store.RemoveFromIndex(ctx, "index", "laptop")).To(Succeed())
+			keys, err = store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(Equal(map[string][]byte{"phone": []byte("secret2")}))
+
+			Expect(store.ClearIndex(ctx, "index")).To(Succeed())
+			Expect(mr.Exists("index")).To(BeFalse())
+		})
+	})
+
+	Context("loading a session with its lock", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			var err error
+			ss, err = NewRedisSessionStore(&options.SessionOptions{
+				Redis: options.RedisStoreOptions{ConnectionURL: "redis://" + mr.Addr()},
+			}, &options.Cookie{Name: "_oauth2_proxy", Secret: []byte("0123456789abcdef")})
+			Expect(err).ToNot(HaveOccurred())
+			store = ss.(*persistence.Manager).Store.(*SessionStore)
+		})
+
+		It("answers the first peek of the lock from the load", func() {
+			Expect(store.Save(ctx, "session", []byte("value"), time.Hour)).To(Succeed())
+			Expect(store.Lock("session").Obtain(ctx, time.Minute)).To(Succeed())
+
+			value, lock, err := store.LoadWithLock(ctx, "session")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			mr.Close()
+			locked, err := lock.Peek(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeTrue())
+
+			_, err = lock.Peek(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("peeks the lock again once it is used", func() {
+			Expect(store.Save(ctx, "session", []byte("value"), time.Hour)).To(Succeed())
+
+			_, lock, err := store.LoadWithLock(ctx, "session")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+
+			locked, err := lock.Peek(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeTrue())
+		})
+
+		It("fails to load a missing session", func() {
+			_, _, err := store.LoadWithLock(ctx, "missing")
+			Expect(err).To(MatchError("error loading redis session: redis: nil"))
+		})
+	})
+
+	Context("with connection pool options", func() {
+		It("configures the pool of the client", func() {
+			var err error
+			ss, err = NewRedisSessionStore(&options.SessionOptions{
+				Redis: options.RedisStoreOptions{
+					ConnectionURL: "redis://" + mr.Addr(),
+					PoolSize:      50,
+					MinIdleConns:  5,
+					DialTimeout:   time.Second,
+					ReadTimeout:   2 * time.Second,
+					WriteTimeout:  3 * time.Second,
+					PoolTimeout:   4 * time.Second,
+				},
+			}, &options.Cookie{Name: "_oauth2_proxy", Secret: []byte("0123456789abcdef")})
+			Expect(err).ToNot(HaveOccurred())
+
+			opts := ss.(*persistence.Manager).Store.(*SessionStore).Client.(*client).Client.Options()
+			Expect(opts.PoolSize).To(Equal(50))
+			Expect(opts.MinIdleConns).To(Equal(5))
+			Expect(opts.DialTimeout).To(Equal(time.Second))
+			Expect(opts.ReadTimeout).To(Equal(2 * time.Second))
+			Expect(opts.WriteTimeout).To(Equal(3 * time.Second))
+			Expect(opts.PoolTimeout).To(Equal(4 * time.Second))
+		})
+	})
+
 	Context("with sentinel", func() {
 		var ms *minisentinel.Sentinel
 
@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// ReplayCache is a sessions.ReplayCache that records the used keys in redis
+// so that they are shared by all replicas of the proxy.
+type ReplayCache struct {
+	Client Client
+	Prefix string
+}
+
+var _ sessions.ReplayCache = (*ReplayCache)(nil)
+
+// NewReplayCache creates a ReplayCache storing keys with the prefix.
+func NewReplayCache(client Client, prefix string) *ReplayCache {
+	return &ReplayCache{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+// Consume records the key as used until the expiration, atomically, so that
+// only one of the requests using a key at the same time succeeds.
+func (c *ReplayCache) Consume(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	return c.Client.SetNX(ctx, c.Prefix+key, []byte{1}, expiration)
+}
+
+// Close closes the connections of the client to redis.
+func (c *ReplayCache) Close() error {
+	return c.Client.Close()
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redis ReplayCache Tests", func() {
+	var mr *miniredis.Miniredis
+	var cache *ReplayCache
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		client, err := NewRedisClient(options.RedisStoreOptions{
+			ConnectionURL: "redis://" + mr.Addr(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		cache = NewReplayCache(client, "_oauth2_proxy-replay-")
+	})
+
+	AfterEach(func() {
+		mr.Close()
+	})
+
+	It("consumes keys once until they expire", func() {
+		ctx := context.Background()
+		consumed, err := cache.Consume(ctx, "code-abc", time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(consumed).To(BeTrue())
+		Expect(mr.Exists("_oauth2_proxy-replay-code-abc")).To(BeTrue())
+
+		consumed, err = cache.Consume(ctx, "code-abc", time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(consumed).To(BeFalse())
+
+		mr.FastForward(time.Minute)
+		consumed, err = cache.Consume(ctx, "code-abc", time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(consumed).To(BeTrue())
+	})
+})
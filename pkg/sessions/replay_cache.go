@@ -0,0 +1,59 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+)
+
+// NewReplayCache creates a ReplayCache in the session store from the provided
+// configuration, storing keys with the prefix.
+// The cookie session store has no server side storage, so its keys are kept
+// in memory and are not shared between replicas of the proxy.
+func NewReplayCache(opts *options.SessionOptions, prefix string) (sessions.ReplayCache, error) {
+	switch opts.Type {
+	case options.CookieSessionStoreType:
+		return &memoryReplayCache{expiries: make(map[string]time.Time)}, nil
+	case options.RedisSessionStoreType:
+		client, err := redis.NewRedisClient(opts.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing redis client: %v", err)
+		}
+		return redis.NewReplayCache(client, prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown session store type '%s'", opts.Type)
+	}
+}
+
+// memoryReplayCache is a ReplayCache that records the used keys in memory.
+type memoryReplayCache struct {
+	mu       sync.Mutex
+	expiries map[string]time.Time
+	clock    clock.Clock
+}
+
+// Consume records the key as used until the expiration.
+func (c *memoryReplayCache) Consume(_ context.Context, key string, expiration time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Remove the expired keys, so that only the keys in use are kept
+	now := c.clock.Now()
+	for k, expiry := range c.expiries {
+		if !now.Before(expiry) {
+			delete(c.expiries, k)
+		}
+	}
+
+	if _, ok := c.expiries[key]; ok {
+		return false, nil
+	}
+	c.expiries[key] = now.Add(expiration)
+	return true, nil
+}
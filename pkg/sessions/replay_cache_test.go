@@ -0,0 +1,81 @@
+package sessions_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewReplayCache", func() {
+	var opts *options.SessionOptions
+
+	BeforeEach(func() {
+		opts = &options.SessionOptions{}
+	})
+
+	Context("with type 'cookie'", func() {
+		BeforeEach(func() {
+			opts.Type = options.CookieSessionStoreType
+			clock.Set(time.Unix(1609366421, 0))
+		})
+
+		AfterEach(func() {
+			clock.Reset()
+		})
+
+		It("consumes keys once until they expire", func() {
+			ctx := context.Background()
+			cache, err := sessions.NewReplayCache(opts, "_oauth2_proxy-replay-")
+			Expect(err).NotTo(HaveOccurred())
+
+			consumed, err := cache.Consume(ctx, "code-abc", time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(BeTrue())
+
+			consumed, err = cache.Consume(ctx, "code-abc", time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(BeFalse())
+
+			consumed, err = cache.Consume(ctx, "nonce-abc", time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(BeTrue())
+
+			Expect(clock.Add(time.Minute)).To(Succeed())
+			consumed, err = cache.Consume(ctx, "code-abc", time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(BeTrue())
+		})
+	})
+
+	Context("with type 'redis'", func() {
+		BeforeEach(func() {
+			opts.Type = options.RedisSessionStoreType
+			opts.Redis.ConnectionURL = "redis://"
+		})
+
+		It("creates a redis.ReplayCache", func() {
+			cache, err := sessions.NewReplayCache(opts, "_oauth2_proxy-replay-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cache).To(BeAssignableToTypeOf(&redis.ReplayCache{}))
+			Expect(cache.(*redis.ReplayCache).Prefix).To(Equal("_oauth2_proxy-replay-"))
+		})
+	})
+
+	Context("with an invalid type", func() {
+		BeforeEach(func() {
+			opts.Type = "invalid-type"
+		})
+
+		It("returns an error", func() {
+			cache, err := sessions.NewReplayCache(opts, "_oauth2_proxy-replay-")
+			Expect(err).To(MatchError("unknown session store type 'invalid-type'"))
+			Expect(cache).To(BeNil())
+		})
+	})
+})
@@ -6,6 +6,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
 )
 
@@ -13,10 +14,26 @@ import (
 func NewSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
 	switch opts.Type {
 	case options.CookieSessionStoreType:
-		return cookie.NewCookieSessionStore(opts, cookieOpts)
+		return newCookieSessionStore(opts, cookieOpts)
 	case options.RedisSessionStoreType:
 		return redis.NewRedisSessionStore(opts, cookieOpts)
 	default:
 		return nil, fmt.Errorf("unknown session store type '%s'", opts.Type)
 	}
 }
+
+// newCookieSessionStore creates a cookie SessionStore, which falls back to
+// Redis for the sessions that are too large for a cookie when the overflow is
+// set to Redis.
+func newCookieSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	if opts.Cookie.Overflow != options.RedisCookieOverflow {
+		return cookie.NewCookieSessionStore(opts, cookieOpts)
+	}
+
+	client, err := redis.NewRedisClient(opts.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing redis client: %v", err)
+	}
+	overflow := persistence.NewManager(&redis.SessionStore{Client: client}, cookieOpts)
+	return cookie.NewCookieSessionStoreWithOverflow(opts, cookieOpts, overflow)
+}
@@ -40,7 +40,7 @@ var _ = Describe("NewSessionStore", func() {
 		// Set default options in CookieOptions
 		cookieOpts = &options.Cookie{
 			Name:     "_oauth2_proxy",
-			Secret:   base64.URLEncoding.EncodeToString(secret),
+			Secret:   []byte(base64.URLEncoding.EncodeToString(secret)),
 			Path:     "/",
 			Expire:   time.Duration(168) * time.Hour,
 			Refresh:  time.Duration(1) * time.Hour,
@@ -59,6 +59,28 @@ var _ = Describe("NewSessionStore", func() {
 			ss, err := sessions.NewSessionStore(opts, cookieOpts)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(ss).To(BeAssignableToTypeOf(&sessionscookie.SessionStore{}))
+			Expect(ss.(*sessionscookie.SessionStore).Overflow).To(BeNil())
+		})
+
+		It("does not fall back to redis only because a redis connection is configured", func() {
+			opts.Redis.ConnectionURL = "redis://"
+
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&sessionscookie.SessionStore{}))
+			Expect(ss.(*sessionscookie.SessionStore).Overflow).To(BeNil())
+		})
+
+		It("falls back to redis when the overflow is set to redis", func() {
+			opts.Cookie.Overflow = options.RedisCookieOverflow
+			opts.Redis.ConnectionURL = "redis://"
+
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&sessionscookie.SessionStore{}))
+			overflow := ss.(*sessionscookie.SessionStore).Overflow
+			Expect(overflow).ToNot(BeNil())
+			Expect(overflow.Store).To(BeAssignableToTypeOf(&redis.SessionStore{}))
 		})
 	})
 
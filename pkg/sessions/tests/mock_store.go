@@ -14,20 +14,22 @@ type entry struct {
 	expiration time.Duration
 }
 
-// MockStore is a generic in-memory implementation of persistence.Store
+// MockStore is a generic in-memory implementation of persistence.IndexStore
 // for mocking in tests
 type MockStore struct {
-	cache     map[string]entry
-	lockCache map[string]*MockLock
-	elapsed   time.Duration
+	cache      map[string]entry
+	indexCache map[string]map[string][]byte
+	lockCache  map[string]*MockLock
+	elapsed    time.Duration
 }
 
 // NewMockStore creates a MockStore
 func NewMockStore() *MockStore {
 	return &MockStore{
-		cache:     map[string]entry{},
-		lockCache: map[string]*MockLock{},
-		elapsed:   0 * time.Second,
+		cache:      map[string]entry{},
+		indexCache: map[string]map[string][]byte{},
+		lockCache:  map[string]*MockLock{},
+		elapsed:    0 * time.Second,
 	}
 }
 
@@ -56,6 +58,36 @@ func (s *MockStore) Clear(_ context.Context, key string) error {
 	return nil
 }
 
+// AddToIndex adds a key with a value to an index in the memory cache
+func (s *MockStore) AddToIndex(_ context.Context, index string, key string, value []byte, _ time.Duration) error {
+	if s.indexCache[index] == nil {
+		s.indexCache[index] = map[string][]byte{}
+	}
+	s.indexCache[index][key] = value
+	return nil
+}
+
+// LoadIndex gets the keys of an index from the memory cache
+func (s *MockStore) LoadIndex(_ context.Context, index string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for key, value := range s.indexCache[index] {
+		keys[key] = value
+	}
+	return keys, nil
+}
+
+// RemoveFromIndex removes a key from an index in the memory cache
+func (s *MockStore) RemoveFromIndex(_ context.Context, index string, key string) error {
+	delete(s.indexCache[index], key)
+	return nil
+}
+
+// ClearIndex deletes an index from the memory cache
+func (s *MockStore) ClearIndex(_ context.Context, index string) error {
+	delete(s.indexCache, index)
+	return nil
+}
+
 func (s *MockStore) Lock(key string) sessions.Lock {
 	if s.lockCache[key] != nil {
 		return s.lockCache[key]
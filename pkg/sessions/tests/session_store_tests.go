@@ -70,15 +70,15 @@ func RunSessionStoreTests(newSS NewSessionStoreFunc, persistentFastForward Persi
 				Secure:   true,
 				HTTPOnly: true,
 				SameSite: "",
-				Secret:   string(cookieSecret),
+				Secret:   cookieSecret,
 			}
 
 			expires := time.Now().Add(1 * time.Hour)
 			session := &sessionsapi.SessionState{
-				AccessToken:  "AccessToken",
-				IDToken:      "IDToken",
+				AccessToken:  []byte("AccessToken"),
+				IDToken:      []byte("IDToken"),
 				ExpiresOn:    &expires,
-				RefreshToken: "RefreshToken",
+				RefreshToken: []byte("RefreshToken"),
 				Email:        "john.doe@example.com",
 				User:         "john.doe",
 			}
@@ -120,7 +120,7 @@ func RunSessionStoreTests(newSS NewSessionStoreFunc, persistentFastForward Persi
 					HTTPOnly: false,
 					Domains:  []string{"example.com"},
 					SameSite: "strict",
-					Secret:   string(cookieSecret),
+					Secret:   cookieSecret,
 				}
 
 				var err error
@@ -376,6 +376,30 @@ func SessionStoreInterfaceTests(in *testInput) {
 				Expect(in.session.CreatedAt.IsZero()).To(BeFalse())
 			})
 
+			It("sets cookies expiring after the cookie expiry", func() {
+				for _, cookie := range in.response.Result().Cookies() {
+					Expect(cookie.Expires.IsZero()).To(BeFalse())
+				}
+			})
+
+			CheckCookieOptions(in)
+		})
+
+		Context("with a browser session", func() {
+			BeforeEach(func() {
+				in.session.BrowserSession = true
+				err := in.ss().Save(in.response, in.request, in.session)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("sets cookies expiring when the browser is closed", func() {
+				cookies := in.response.Result().Cookies()
+				Expect(cookies).ToNot(BeEmpty())
+				for _, cookie := range cookies {
+					Expect(cookie.Expires.IsZero()).To(BeTrue())
+				}
+			})
+
 			CheckCookieOptions(in)
 		})
 
@@ -0,0 +1,243 @@
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = 30 * time.Second
+
+	// readyTimeout is how long connections wait for the first SVID to be
+	// received from the Workload API.
+	readyTimeout = 10 * time.Second
+)
+
+var (
+	sourcesMutex sync.Mutex
+	sources      = map[string]*Source{}
+)
+
+// Source holds the X.509 SVID of the workload, which is kept up to date by
+// watching the SPIFFE Workload API so that rotated SVIDs and trust bundles
+// are used for new connections.
+type Source struct {
+	addr   string
+	cancel context.CancelFunc
+	ready  chan struct{}
+
+	mutex sync.RWMutex
+	svid  *x509SVID
+}
+
+// GetSource returns the Source shared by all connections that use the
+// Workload API address, starting it on first use.
+// When the address is empty, the SPIFFE_ENDPOINT_SOCKET environment variable
+// is used.
+func GetSource(addr string) (*Source, error) {
+	if addr == "" {
+		addr = os.Getenv(EndpointSocketEnv)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("no workload API address configured: set the address or the %s environment variable", EndpointSocketEnv)
+	}
+
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	if source, ok := sources[addr]; ok {
+		return source, nil
+	}
+	source, err := NewSource(addr)
+	if err != nil {
+		return nil, err
+	}
+	sources[addr] = source
+	return source, nil
+}
+
+// NewSource creates a new Source and starts watching the Workload API at the
+// address for SVIDs.
+func NewSource(addr string) (*Source, error) {
+	client, err := newWorkloadAPIClient(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Source{
+		addr:   addr,
+		cancel: cancel,
+		ready:  make(chan struct{}),
+	}
+	go s.watch(ctx, client)
+	return s, nil
+}
+
+// Close stops watching the Workload API.
+func (s *Source) Close() {
+	s.cancel()
+}
+
+// watch streams SVIDs from the Workload API, reconnecting with a backoff
+// when the stream fails.
+func (s *Source) watch(ctx context.Context, client *http.Client) {
+	retryInterval := minRetryInterval
+	for {
+		err := fetchX509SVIDs(ctx, client, func(svid *x509SVID) {
+			s.setSVID(svid)
+			retryInterval = minRetryInterval
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Errorf("Error watching SPIFFE workload API %s: %v", s.addr, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+		retryInterval *= 2
+		if retryInterval > maxRetryInterval {
+			retryInterval = maxRetryInterval
+		}
+	}
+}
+
+func (s *Source) setSVID(svid *x509SVID) {
+	s.mutex.Lock()
+	first := s.svid == nil
+	s.svid = svid
+	s.mutex.Unlock()
+
+	if first {
+		close(s.ready)
+	}
+	logger.Printf("Received X.509 SVID %s from SPIFFE workload API", svid.id)
+}
+
+// current returns the current SVID, waiting for the first SVID to be
+// received if required.
+func (s *Source) current() (*x509SVID, error) {
+	select {
+	case <-s.ready:
+	case <-time.After(readyTimeout):
+		return nil, fmt.Errorf("no X.509 SVID received from SPIFFE workload API %s", s.addr)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.svid, nil
+}
+
+// GetClientCertificate returns the current SVID to be presented as the client
+// certificate.
+func (s *Source) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	svid, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return &svid.certificate, nil
+}
+
+// ConfigureClientTLS configures the TLS config to present the SVID of the
+// workload and to verify that the server presents an SVID with one of the
+// authorized SPIFFE IDs.
+// When no IDs are authorized, any SVID from the trust domain of the workload
+// is accepted.
+func (s *Source) ConfigureClientTLS(cfg *tls.Config, authorizedIDs []string) {
+	// SPIFFE IDs are verified in place of host names
+	/* #nosec G402 */
+	cfg.InsecureSkipVerify = true
+	cfg.GetClientCertificate = s.GetClientCertificate
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return s.verifyPeerCertificate(rawCerts, authorizedIDs)
+	}
+}
+
+// verifyPeerCertificate verifies the peer certificate chain against the
+// current trust bundle and checks the SPIFFE ID of the peer is authorized.
+func (s *Source) verifyPeerCertificate(rawCerts [][]byte, authorizedIDs []string) error {
+	svid, err := s.current()
+	if err != nil {
+		return err
+	}
+	if len(rawCerts) == 0 {
+		return errors.New("peer presented no certificates")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid peer certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         svid.bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("peer SVID could not be verified: %v", err)
+	}
+
+	id := spiffeID(certs[0])
+	if id == "" {
+		return errors.New("peer certificate has no SPIFFE ID")
+	}
+	if !isAuthorized(id, authorizedIDs, svid.id) {
+		return fmt.Errorf("peer SPIFFE ID %q is not authorized", id)
+	}
+	return nil
+}
+
+// spiffeID returns the SPIFFE ID from the URI SAN of the certificate.
+func spiffeID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+// isAuthorized determines whether the peer ID is one of the authorized IDs,
+// or is in the same trust domain as the workload when no IDs are authorized.
+func isAuthorized(id string, authorizedIDs []string, workloadID string) bool {
+	if len(authorizedIDs) == 0 {
+		return trustDomain(id) != "" && trustDomain(id) == trustDomain(workloadID)
+	}
+	for _, authorized := range authorizedIDs {
+		if id == authorized {
+			return true
+		}
+	}
+	return false
+}
+
+// trustDomain returns the trust domain of the SPIFFE ID.
+func trustDomain(id string) string {
+	u, err := url.Parse(id)
+	if err != nil || u.Scheme != "spiffe" {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,183 @@
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+)
+
+// testCertificate is a certificate and its key for the tests.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertificate(id string, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	uri, err := url.Parse(id)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{Organization: []string{"SPIFFE"}},
+		URIs:         []*url.URL{uri},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return testCertificate{cert: cert, key: key}
+}
+
+// appendProtoBytes appends a length-delimited protobuf field.
+func appendProtoBytes(b []byte, field int, value []byte) []byte {
+	varint := make([]byte, binary.MaxVarintLen64)
+	b = append(b, varint[:binary.PutUvarint(varint, uint64(field<<3|2))]...)
+	b = append(b, varint[:binary.PutUvarint(varint, uint64(len(value)))]...)
+	return append(b, value...)
+}
+
+// newX509SVIDResponse encodes an X509SVIDResponse gRPC message.
+func newX509SVIDResponse(svid testCertificate, ca testCertificate) []byte {
+	key, err := x509.MarshalPKCS8PrivateKey(svid.key)
+	Expect(err).ToNot(HaveOccurred())
+
+	msg := appendProtoBytes(nil, 1, []byte(svid.cert.URIs[0].String()))
+	msg = appendProtoBytes(msg, 2, svid.cert.Raw)
+	msg = appendProtoBytes(msg, 3, key)
+	msg = appendProtoBytes(msg, 4, ca.cert.Raw)
+	response := appendProtoBytes(nil, 1, msg)
+
+	frame := make([]byte, 5, 5+len(response))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(response)))
+	return append(frame, response...)
+}
+
+var _ = Describe("Source Suite", func() {
+	var ca, workload testCertificate
+	var socketDir string
+	var listener net.Listener
+	var requests chan *http.Request
+
+	BeforeEach(func() {
+		ca = newTestCertificate("spiffe://example.org", nil)
+		workload = newTestCertificate("spiffe://example.org/oauth2-proxy", &ca)
+
+		var err error
+		socketDir, err = ioutil.TempDir("", "spiffe")
+		Expect(err).ToNot(HaveOccurred())
+		listener, err = net.Listen("unix", filepath.Join(socketDir, "api.sock"))
+		Expect(err).ToNot(HaveOccurred())
+
+		requests = make(chan *http.Request, 1)
+		server := &http2.Server{}
+		handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requests <- req
+			rw.Header().Set("Content-Type", "application/grpc")
+			rw.Write(newX509SVIDResponse(workload, ca))
+			rw.(http.Flusher).Flush()
+			<-req.Context().Done()
+		})
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+			}
+		}()
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		os.RemoveAll(socketDir)
+	})
+
+	It("receives the SVID from the workload API", func() {
+		source, err := NewSource("unix://" + filepath.Join(socketDir, "api.sock"))
+		Expect(err).ToNot(HaveOccurred())
+		defer source.Close()
+
+		cert, err := source.GetClientCertificate(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cert.Leaf.URIs[0].String()).To(Equal("spiffe://example.org/oauth2-proxy"))
+
+		var req *http.Request
+		Eventually(requests).Should(Receive(&req))
+		Expect(req.URL.Path).To(Equal("/SpiffeWorkloadAPI/FetchX509SVID"))
+		Expect(req.Header.Get("workload.spiffe.io")).To(Equal("true"))
+	})
+
+	DescribeTable("verifies peer certificates",
+		func(peerID string, trusted bool, authorizedIDs []string, expectedErr string) {
+			source, err := NewSource("unix://" + filepath.Join(socketDir, "api.sock"))
+			Expect(err).ToNot(HaveOccurred())
+			defer source.Close()
+
+			issuer := ca
+			if !trusted {
+				issuer = newTestCertificate("spiffe://example.org", nil)
+			}
+			peer := newTestCertificate(peerID, &issuer)
+
+			err = source.verifyPeerCertificate([][]byte{peer.cert.Raw}, authorizedIDs)
+			if expectedErr == "" {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			}
+		},
+		Entry("with a peer in the trust domain", "spiffe://example.org/redis", true, nil, ""),
+		Entry("with a peer in another trust domain", "spiffe://other.org/redis", true, nil, "is not authorized"),
+		Entry("with an authorized peer", "spiffe://example.org/redis", true, []string{"spiffe://example.org/redis"}, ""),
+		Entry("with an unauthorized peer", "spiffe://example.org/api", true, []string{"spiffe://example.org/redis"}, "is not authorized"),
+		Entry("with a peer from an untrusted issuer", "spiffe://example.org/redis", false, nil, "could not be verified"),
+	)
+
+	DescribeTable("parseEndpoint",
+		func(addr, expectedNetwork, expectedAddress string, expectErr bool) {
+			network, address, err := parseEndpoint(addr)
+			if expectErr {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(network).To(Equal(expectedNetwork))
+			Expect(address).To(Equal(expectedAddress))
+		},
+		Entry("with a unix socket", "unix:///run/spire/agent.sock", "unix", "/run/spire/agent.sock", false),
+		Entry("with a tcp address", "tcp://127.0.0.1:8081", "tcp", "127.0.0.1:8081", false),
+		Entry("with a path", "/run/spire/agent.sock", "", "", true),
+		Entry("with a relative unix socket", "unix://agent.sock", "", "", true),
+	)
+})
@@ -0,0 +1,283 @@
+package spiffe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// EndpointSocketEnv is the environment variable that holds the address of
+	// the Workload API when no address is configured.
+	EndpointSocketEnv = "SPIFFE_ENDPOINT_SOCKET"
+
+	fetchX509SVIDPath = "/SpiffeWorkloadAPI/FetchX509SVID"
+
+	// The Workload API rejects requests without this header to prevent
+	// requests being forwarded to it by a confused deputy.
+	workloadAPIHeader = "workload.spiffe.io"
+
+	dialTimeout    = 5 * time.Second
+	maxMessageSize = 4 << 20
+)
+
+var errInvalidMessage = errors.New("invalid protobuf message")
+
+// x509SVID is an X.509 SVID received from the Workload API along with the
+// trust bundle of its trust domain.
+type x509SVID struct {
+	id          string
+	certificate tls.Certificate
+	bundle      *x509.CertPool
+}
+
+// parseEndpoint parses a Workload API address of the form `unix:///path` or
+// `tcp://ip:port` into the network and address to dial.
+func parseEndpoint(addr string) (string, string, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid workload API address %q: %v", addr, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" || u.Host != "" {
+			return "", "", fmt.Errorf("invalid workload API address %q: unix addresses must be of the form unix:///path", addr)
+		}
+		return "unix", u.Path, nil
+	case "tcp":
+		if u.Host == "" || u.Path != "" {
+			return "", "", fmt.Errorf("invalid workload API address %q: tcp addresses must be of the form tcp://ip:port", addr)
+		}
+		return "tcp", u.Host, nil
+	default:
+		return "", "", fmt.Errorf("invalid workload API address %q: scheme must be unix or tcp", addr)
+	}
+}
+
+// newWorkloadAPIClient creates a client that speaks cleartext HTTP/2, as
+// required by gRPC, to the Workload API.
+func newWorkloadAPIClient(addr string) (*http.Client, error) {
+	network, address, err := parseEndpoint(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(_, _ string, _ *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, address, dialTimeout)
+			},
+		},
+	}, nil
+}
+
+// fetchX509SVIDs calls the FetchX509SVID streaming RPC and passes each SVID
+// received to update until the stream ends or the context is cancelled.
+func fetchX509SVIDs(ctx context.Context, client *http.Client, update func(*x509SVID)) error {
+	// An X509SVIDRequest has no fields so is sent as an empty gRPC message
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+fetchX509SVIDPath, bytes.NewReader(make([]byte, 5)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set(workloadAPIHeader, "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		msg, err := readGRPCMessage(reader)
+		if err == io.EOF {
+			return grpcStatusError(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		svid, err := parseX509SVIDResponse(msg)
+		if err != nil {
+			return fmt.Errorf("error parsing X.509 SVID response: %v", err)
+		}
+		update(svid)
+	}
+}
+
+// readGRPCMessage reads a single length-prefixed gRPC message.
+func readGRPCMessage(reader io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed gRPC messages are not supported")
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("gRPC message of %d bytes exceeds the maximum size", length)
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(reader, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// grpcStatusError returns the error reported in the gRPC status of the
+// response.
+// Responses without messages report the status in the headers instead of
+// the trailers.
+func grpcStatusError(resp *http.Response) error {
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+
+	if status == "" || status == "0" {
+		return errors.New("workload API stream closed")
+	}
+	return fmt.Errorf("workload API returned gRPC status %s: %s", status, message)
+}
+
+// parseX509SVIDResponse parses an X509SVIDResponse, returning its first SVID
+// which is the default SVID for the workload.
+func parseX509SVIDResponse(msg []byte) (*x509SVID, error) {
+	var svid *x509SVID
+	err := parseProtoFields(msg, func(field int, value []byte) error {
+		// Field 1 is the repeated svids field
+		if field != 1 || svid != nil {
+			return nil
+		}
+		var err error
+		svid, err = parseX509SVID(value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if svid == nil {
+		return nil, errors.New("response contains no SVIDs")
+	}
+	return svid, nil
+}
+
+// parseX509SVID parses an X509SVID message.
+func parseX509SVID(msg []byte) (*x509SVID, error) {
+	var id string
+	var certs, key, bundle []byte
+	err := parseProtoFields(msg, func(field int, value []byte) error {
+		switch field {
+		case 1:
+			id = string(value)
+		case 2:
+			certs = value
+		case 3:
+			key = value
+		case 4:
+			bundle = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificates and bundles are sent as concatenated ASN.1 DER
+	chain, err := x509.ParseCertificates(certs)
+	if err != nil || len(chain) == 0 {
+		return nil, fmt.Errorf("invalid certificates for %q: %v", id, err)
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key for %q: %v", id, err)
+	}
+	cas, err := x509.ParseCertificates(bundle)
+	if err != nil || len(cas) == 0 {
+		return nil, fmt.Errorf("invalid trust bundle for %q: %v", id, err)
+	}
+
+	svid := &x509SVID{
+		id: id,
+		certificate: tls.Certificate{
+			PrivateKey: privateKey,
+			Leaf:       chain[0],
+		},
+		bundle: x509.NewCertPool(),
+	}
+	for _, cert := range chain {
+		svid.certificate.Certificate = append(svid.certificate.Certificate, cert.Raw)
+	}
+	for _, ca := range cas {
+		svid.bundle.AddCert(ca)
+	}
+	return svid, nil
+}
+
+// parseProtoFields walks the fields of a protobuf message, passing the
+// length-delimited fields to fn.
+// Other fields are skipped as the Workload API messages only use
+// length-delimited fields for the values we need.
+func parseProtoFields(msg []byte, fn func(field int, value []byte) error) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errInvalidMessage
+		}
+		msg = msg[n:]
+
+		switch key & 7 {
+		case 0:
+			if _, n = binary.Uvarint(msg); n <= 0 {
+				return errInvalidMessage
+			}
+			msg = msg[n:]
+		case 1:
+			if len(msg) < 8 {
+				return errInvalidMessage
+			}
+			msg = msg[8:]
+		case 2:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || length > uint64(len(msg)-n) {
+				return errInvalidMessage
+			}
+			value := msg[n : n+int(length)]
+			msg = msg[n+int(length):]
+			if err := fn(int(key>>3), value); err != nil {
+				return err
+			}
+		case 5:
+			if len(msg) < 4 {
+				return errInvalidMessage
+			}
+			msg = msg[4:]
+		default:
+			return errInvalidMessage
+		}
+	}
+	return nil
+}
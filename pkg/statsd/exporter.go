@@ -0,0 +1,278 @@
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxPacketSize is the largest datagram sent to the agent, which is the size
+// the DogStatsD client libraries use to avoid fragmentation over UDP
+const maxPacketSize = 1432
+
+// Config configures an Exporter.
+type Config struct {
+	// Address of the DogStatsD agent, as `host:port` or `udp://host:port`
+	// for UDP, or `unix:///path/to/dsd.socket` for a Unix domain socket
+	Address string
+	// Prefix is prepended to the name of every metric, eg. `oauth2_proxy.`
+	Prefix string
+	// Tags are added to every metric, as `key:value` or `key`
+	Tags []string
+	// FlushInterval is the interval at which the metrics are sent
+	FlushInterval time.Duration
+}
+
+// Exporter sends the metrics gathered from a Prometheus registry to a
+// DogStatsD agent, such as the Datadog agent, at an interval.
+// Counters are sent as the counts of their increase since the last flush,
+// gauges as gauges, and the count, sum and buckets of histograms as counts,
+// with the labels of each metric as tags.
+type Exporter struct {
+	conn     net.Conn
+	gatherer prometheus.Gatherer
+	prefix   string
+	tags     []string
+	interval time.Duration
+
+	// previous holds the last values of the counters, by series, to send
+	// their increase
+	previous map[string]float64
+
+	worker *workers.Worker
+}
+
+// NewExporter creates an exporter sending the metrics of the gatherer to the
+// agent at the address of the config.
+func NewExporter(config Config, gatherer prometheus.Gatherer) (*Exporter, error) {
+	network, address, err := ParseAddress(config.Address)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range config.Tags {
+		if err := ValidateTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dialing a datagram socket does not wait for the agent, which may
+	// start after the proxy
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the statsd agent at %q: %v", config.Address, err)
+	}
+
+	return &Exporter{
+		conn:     conn,
+		gatherer: gatherer,
+		prefix:   config.Prefix,
+		tags:     config.Tags,
+		interval: config.FlushInterval,
+		previous: make(map[string]float64),
+		worker:   workers.New("statsd", config.FlushInterval),
+	}, nil
+}
+
+// ParseAddress returns the network and address of the DogStatsD agent given
+// as `host:port`, `udp://host:port` or `unix:///path/to/dsd.socket`.
+func ParseAddress(address string) (string, string, error) {
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid statsd address %q: %v", address, err)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			return "", "", fmt.Errorf("invalid statsd address %q: %v", address, err)
+		}
+		return "udp", u.Host, nil
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid statsd address %q: missing socket path", address)
+		}
+		return "unixgram", u.Path, nil
+	default:
+		return "", "", fmt.Errorf("invalid statsd address %q: scheme must be udp or unix", address)
+	}
+}
+
+// ValidateTag returns an error when the tag contains characters that are
+// reserved by the DogStatsD protocol.
+func ValidateTag(tag string) error {
+	if tag == "" || strings.ContainsAny(tag, ",|#\n") {
+		return fmt.Errorf("invalid statsd tag %q: must be key:value or key, without ',', '|' or '#'", tag)
+	}
+	return nil
+}
+
+// Run sends the metrics at the flush interval until the context is done,
+// when they are sent a last time.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	defer e.conn.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.flushAndLog()
+			return
+		case <-ticker.C:
+			e.flushAndLog()
+		}
+	}
+}
+
+func (e *Exporter) flushAndLog() {
+	err := e.Flush()
+	e.worker.Record(err)
+	if err != nil {
+		logger.Errorf("Error sending metrics to statsd: %v", err)
+	}
+}
+
+// Flush gathers the metrics and sends them to the agent.
+func (e *Exporter) Flush() error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %v", err)
+	}
+
+	var lines []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			lines = append(lines, e.format(family, metric)...)
+		}
+	}
+	return e.send(lines)
+}
+
+// format returns the DogStatsD lines of a metric of the family.
+func (e *Exporter) format(family *dto.MetricFamily, metric *dto.Metric) []string {
+	name := family.GetName()
+	tags := e.metricTags(metric)
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		return e.count(name, metric.GetCounter().GetValue(), tags)
+	case dto.MetricType_GAUGE:
+		return []string{e.line(name, metric.GetGauge().GetValue(), "g", tags)}
+	case dto.MetricType_UNTYPED:
+		return []string{e.line(name, metric.GetUntyped().GetValue(), "g", tags)}
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		lines := e.count(name+"_count", float64(histogram.GetSampleCount()), tags)
+		lines = append(lines, e.count(name+"_sum", histogram.GetSampleSum(), tags)...)
+		for _, bucket := range histogram.GetBucket() {
+			le := "le:" + strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)
+			lines = append(lines, e.count(name+"_bucket", float64(bucket.GetCumulativeCount()), append(tags, le))...)
+		}
+		return lines
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		lines := e.count(name+"_count", float64(summary.GetSampleCount()), tags)
+		lines = append(lines, e.count(name+"_sum", summary.GetSampleSum(), tags)...)
+		for _, quantile := range summary.GetQuantile() {
+			// Quantiles without observations are not a number
+			if math.IsNaN(quantile.GetValue()) {
+				continue
+			}
+			q := "quantile:" + strconv.FormatFloat(quantile.GetQuantile(), 'g', -1, 64)
+			lines = append(lines, e.line(name, quantile.GetValue(), "g", append(tags, q)))
+		}
+		return lines
+	default:
+		return nil
+	}
+}
+
+// count returns the line of the increase of a counter since the last flush,
+// or no line when it has not increased. A counter that has decreased was
+// reset, so its whole value is its increase.
+func (e *Exporter) count(name string, value float64, tags []string) []string {
+	key := name + "|" + strings.Join(tags, ",")
+	increase := value
+	if previous, ok := e.previous[key]; ok && value >= previous {
+		increase = value - previous
+	}
+	e.previous[key] = value
+
+	if increase == 0 {
+		return nil
+	}
+	return []string{e.line(name, increase, "c", tags)}
+}
+
+// line formats a DogStatsD line: `name:value|type|#tag,tag`.
+func (e *Exporter) line(name string, value float64, metricType string, tags []string) string {
+	line := e.prefix + name + ":" + strconv.FormatFloat(value, 'g', -1, 64) + "|" + metricType
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// metricTags returns the configured tags and the labels of the metric as
+// sorted `key:value` tags. The tags are copied so that callers may append to
+// them.
+func (e *Exporter) metricTags(metric *dto.Metric) []string {
+	tags := make([]string, 0, len(e.tags)+len(metric.GetLabel())+1)
+	tags = append(tags, e.tags...)
+	for _, label := range metric.GetLabel() {
+		tags = append(tags, label.GetName()+":"+sanitizeTagValue(label.GetValue()))
+	}
+	sort.Strings(tags[len(e.tags):])
+	return tags[:len(tags):len(tags)]
+}
+
+// tagValueReplacer replaces the characters of label values that are reserved
+// by the DogStatsD protocol.
+var tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// sanitizeTagValue replaces the characters of a label value that are reserved
+// by the DogStatsD protocol.
+func sanitizeTagValue(value string) string {
+	return tagValueReplacer.Replace(value)
+}
+
+// send writes the lines to the agent in as few datagrams as they fit in.
+func (e *Exporter) send(lines []string) error {
+	var packet bytes.Buffer
+	write := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+			if err := write(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return write()
+}
@@ -0,0 +1,146 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ = Describe("Exporter", func() {
+	var (
+		agent    net.PacketConn
+		registry *prometheus.Registry
+		exporter *Exporter
+		requests *prometheus.CounterVec
+	)
+
+	// receive returns the lines of the datagrams received by the agent
+	// until none is received for a moment
+	receive := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		for {
+			Expect(agent.SetReadDeadline(time.Now().Add(100 * time.Millisecond))).To(Succeed())
+			n, _, err := agent.ReadFrom(buf)
+			if err != nil {
+				return lines
+			}
+			Expect(n).To(BeNumerically("<=", maxPacketSize))
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		agent, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		registry = prometheus.NewRegistry()
+		requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oauth2_proxy_requests_total",
+			Help: "Total number of requests by HTTP status code.",
+		}, []string{"code"})
+		registry.MustRegister(requests)
+
+		exporter, err = NewExporter(Config{
+			Address:       agent.LocalAddr().String(),
+			Prefix:        "proxy.",
+			Tags:          []string{"env:test"},
+			FlushInterval: time.Second,
+		}, registry)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(exporter.conn.Close()).To(Succeed())
+		Expect(agent.Close()).To(Succeed())
+	})
+
+	It("sends the increase of counters since the last flush", func() {
+		requests.WithLabelValues("200").Add(3)
+		requests.WithLabelValues("404").Inc()
+		Expect(exporter.Flush()).To(Succeed())
+		Expect(receive()).To(ConsistOf(
+			"proxy.oauth2_proxy_requests_total:3|c|#env:test,code:200",
+			"proxy.oauth2_proxy_requests_total:1|c|#env:test,code:404",
+		))
+
+		requests.WithLabelValues("200").Add(2)
+		Expect(exporter.Flush()).To(Succeed())
+		Expect(receive()).To(ConsistOf("proxy.oauth2_proxy_requests_total:2|c|#env:test,code:200"))
+	})
+
+	It("sends gauges", func() {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "oauth2_proxy_in_flight", Help: "In flight."})
+		registry.MustRegister(gauge)
+		gauge.Set(2.5)
+
+		Expect(exporter.Flush()).To(Succeed())
+		Expect(receive()).To(ConsistOf("proxy.oauth2_proxy_in_flight:2.5|g|#env:test"))
+	})
+
+	It("sends the count, sum and buckets of histograms", func() {
+		histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oauth2_proxy_duration_seconds",
+			Help:    "Duration.",
+			Buckets: []float64{0.1, 1},
+		}, []string{"upstream"})
+		registry.MustRegister(histogram)
+		histogram.WithLabelValues("app").Observe(0.5)
+		histogram.WithLabelValues("app").Observe(2)
+
+		Expect(exporter.Flush()).To(Succeed())
+		Expect(receive()).To(ConsistOf(
+			"proxy.oauth2_proxy_duration_seconds_count:2|c|#env:test,upstream:app",
+			"proxy.oauth2_proxy_duration_seconds_sum:2.5|c|#env:test,upstream:app",
+			"proxy.oauth2_proxy_duration_seconds_bucket:1|c|#env:test,upstream:app,le:1",
+		))
+	})
+
+	It("splits the metrics across datagrams", func() {
+		for i := 0; i < 100; i++ {
+			requests.WithLabelValues(strings.Repeat("x", i)).Inc()
+		}
+
+		Expect(exporter.Flush()).To(Succeed())
+		Expect(receive()).To(HaveLen(100))
+	})
+
+	DescribeTable("ParseAddress",
+		func(address, expectedNetwork, expectedAddress, expectedErr string) {
+			network, addr, err := ParseAddress(address)
+			if expectedErr != "" {
+				Expect(err).To(MatchError(expectedErr))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(network).To(Equal(expectedNetwork))
+			Expect(addr).To(Equal(expectedAddress))
+		},
+		Entry("with a host and port", "localhost:8125", "udp", "localhost:8125", ""),
+		Entry("with a UDP URL", "udp://10.0.0.1:8125", "udp", "10.0.0.1:8125", ""),
+		Entry("with a Unix socket", "unix:///var/run/datadog/dsd.socket", "unixgram", "/var/run/datadog/dsd.socket", ""),
+		Entry("without a port", "localhost", "", "", `invalid statsd address "udp://localhost": address localhost: missing port in address`),
+		Entry("with a TCP URL", "tcp://localhost:8125", "", "", `invalid statsd address "tcp://localhost:8125": scheme must be udp or unix`),
+	)
+
+	DescribeTable("ValidateTag",
+		func(tag string, valid bool) {
+			if valid {
+				Expect(ValidateTag(tag)).To(Succeed())
+			} else {
+				Expect(ValidateTag(tag)).ToNot(Succeed())
+			}
+		},
+		Entry("with a key and value", "env:prod", true),
+		Entry("with a key", "canary", true),
+		Entry("when empty", "", false),
+		Entry("with a comma", "env:prod,team:iam", false),
+		Entry("with a pipe", "env|prod", false),
+	)
+})
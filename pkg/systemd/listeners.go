@@ -0,0 +1,87 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+var (
+	listenersOnce sync.Once
+	listeners     map[string]net.Listener
+	listenersErr  error
+)
+
+// Listener returns the listener passed by systemd socket activation with the
+// name given by the FileDescriptorName= option of the socket unit, which
+// defaults to the name of the socket unit.
+// Each listener may only be used once.
+func Listener(name string) (net.Listener, error) {
+	listenersOnce.Do(func() {
+		listeners, listenersErr = listenersFromEnv(os.Getenv, listenFdsStart)
+
+		// The environment must not be inherited by child processes, they
+		// would otherwise attempt to use the listeners
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if listenersErr != nil {
+		return nil, listenersErr
+	}
+
+	listener, ok := listeners[name]
+	if !ok {
+		return nil, fmt.Errorf("no socket activated listener named %q", name)
+	}
+	delete(listeners, name)
+	return listener, nil
+}
+
+// listenersFromEnv creates listeners from the file descriptors passed with
+// the socket activation protocol.
+// Listeners are only created when LISTEN_PID matches this process.
+func listenersFromEnv(getenv func(string) string, start int) (map[string]net.Listener, error) {
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return map[string]net.Listener{}, nil
+	}
+
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+
+	var names []string
+	if fdNames := getenv("LISTEN_FDNAMES"); fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+
+	result := make(map[string]net.Listener, count)
+	for i := 0; i < count; i++ {
+		// systemd names file descriptors "unknown" when no names are given
+		name := "unknown"
+		if i < len(names) {
+			name = names[i]
+		}
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("multiple socket activated listeners named %q: set FileDescriptorName= for each socket", name)
+		}
+
+		file := os.NewFile(uintptr(start+i), name)
+		listener, err := net.FileListener(file)
+		// FileListener duplicates the file descriptor
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not create listener from socket activated file descriptor %q: %v", name, err)
+		}
+		result[name] = listener
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listeners Suite", func() {
+	var file *os.File
+	var env map[string]string
+
+	BeforeEach(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		file, err = listener.(*net.TCPListener).File()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listener.Close()).To(Succeed())
+
+		env = map[string]string{
+			"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+			"LISTEN_FDS":     "1",
+			"LISTEN_FDNAMES": "http",
+		}
+	})
+
+	AfterEach(func() {
+		file.Close()
+	})
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	It("creates listeners from the passed file descriptors", func() {
+		listeners, err := listenersFromEnv(getenv, int(file.Fd()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listeners).To(HaveKey("http"))
+		defer listeners["http"].Close()
+
+		conn, err := net.Dial("tcp", listeners["http"].Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		conn.Close()
+	})
+
+	It("names listeners unknown when no names are passed", func() {
+		delete(env, "LISTEN_FDNAMES")
+		listeners, err := listenersFromEnv(getenv, int(file.Fd()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listeners).To(HaveKey("unknown"))
+		listeners["unknown"].Close()
+	})
+
+	It("ignores file descriptors passed to another process", func() {
+		env["LISTEN_PID"] = strconv.Itoa(os.Getpid() + 1)
+		listeners, err := listenersFromEnv(getenv, int(file.Fd()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listeners).To(BeEmpty())
+	})
+
+	It("rejects invalid file descriptor counts", func() {
+		env["LISTEN_FDS"] = "many"
+		_, err := listenersFromEnv(getenv, int(file.Fd()))
+		Expect(err).To(MatchError("invalid LISTEN_FDS \"many\""))
+	})
+})
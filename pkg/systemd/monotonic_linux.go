@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package systemd
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// clockMonotonic is CLOCK_MONOTONIC from linux/time.h.
+const clockMonotonic = 1
+
+// monotonicMicroseconds reads CLOCK_MONOTONIC, which systemd uses to match
+// reload notifications to reload requests.
+func monotonicMicroseconds() (int64, bool) {
+	var ts syscall.Timespec
+	_, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return 0, false
+	}
+	return ts.Nano() / 1000, true
+}
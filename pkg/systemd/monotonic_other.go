@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package systemd
+
+// monotonicMicroseconds is only supported on linux, where systemd runs.
+func monotonicMicroseconds() (int64, bool) {
+	return 0, false
+}
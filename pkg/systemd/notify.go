@@ -0,0 +1,58 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+const (
+	// Ready tells systemd that start up has finished and the proxy is
+	// serving requests.
+	Ready = "READY=1"
+
+	// Stopping tells systemd that the proxy has begun shutting down.
+	Stopping = "STOPPING=1"
+
+	notifySocketEnv = "NOTIFY_SOCKET"
+)
+
+// Notify sends the state to the service manager when the proxy is run as a
+// systemd service with Type=notify or Type=notify-reload.
+// It does nothing when NOTIFY_SOCKET is not set.
+func Notify(state string) error {
+	socket := os.Getenv(notifySocketEnv)
+	if socket == "" {
+		return nil
+	}
+
+	// Names starting with @ are in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	if socket[0] != '/' && socket[0] != '\x00' {
+		return errors.New("unsupported NOTIFY_SOCKET address: only unix sockets are supported")
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("could not connect to NOTIFY_SOCKET: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("could not notify systemd: %v", err)
+	}
+	return nil
+}
+
+// Reloading returns the state that tells systemd that the configuration is
+// being reloaded. Ready must be sent once the reload has finished.
+func Reloading() string {
+	usec, ok := monotonicMicroseconds()
+	if !ok {
+		return "RELOADING=1"
+	}
+	return fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", usec)
+}
@@ -0,0 +1,56 @@
+package systemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Notify Suite", func() {
+	var dir string
+	var conn *net.UnixConn
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "systemd")
+		Expect(err).ToNot(HaveOccurred())
+
+		socket := filepath.Join(dir, "notify.sock")
+		conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		Expect(err).ToNot(HaveOccurred())
+		os.Setenv(notifySocketEnv, socket)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(notifySocketEnv)
+		conn.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("sends the state to the notify socket", func() {
+		Expect(Notify(Ready)).To(Succeed())
+
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal("READY=1"))
+	})
+
+	It("sends the monotonic time when reloading", func() {
+		Expect(Notify(Reloading())).To(Succeed())
+
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf[:n])).To(MatchRegexp("^RELOADING=1\nMONOTONIC_USEC=[0-9]+$"))
+	})
+
+	It("does nothing without a notify socket", func() {
+		os.Unsetenv(notifySocketEnv)
+		Expect(Notify(Ready)).To(Succeed())
+	})
+})
@@ -0,0 +1,313 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/workers"
+)
+
+// errNoAvailableEndpoints is returned when every endpoint of a load balanced
+// upstream is unavailable.
+var errNoAvailableEndpoints = errors.New("no upstream endpoints are available")
+
+// endpoint is a single upstream server within a load balanced upstream.
+type endpoint struct {
+	uri   string
+	host  string
+	proxy *httpUpstreamProxy
+
+	// inFlight is the number of requests currently being served by the
+	// endpoint. It must be accessed atomically.
+	inFlight int64
+}
+
+// available determines whether the endpoint may receive requests.
+func (e *endpoint) available() bool {
+	if e.proxy.health != nil && !e.proxy.health.healthy() {
+		return false
+	}
+	return e.proxy.outlier == nil || e.proxy.outlier.available(e.host)
+}
+
+// loadBalancer distributes requests across multiple upstream servers.
+type loadBalancer struct {
+	upstream     options.Upstream
+	sigData      *options.SignatureData
+	policy       options.LoadBalancingPolicy
+	sticky       bool
+	errorHandler ProxyErrorHandler
+
+	// endpoints may be replaced when discovery is configured.
+	endpoints []*endpoint
+	mutex     sync.RWMutex
+
+	// next is the round robin counter. It must be accessed atomically.
+	next uint64
+
+	// stop is closed to stop service discovery.
+	stop chan struct{}
+}
+
+// newLoadBalancer creates a loadBalancer with an httpUpstreamProxy for each
+// of the upstream URIs.
+// When discovery is configured, the discovered servers are added to the URIs
+// and are refreshed in the background.
+func newLoadBalancer(upstream options.Upstream, sigData *options.SignatureData, errorHandler ProxyErrorHandler) (http.Handler, error) {
+	l := &loadBalancer{
+		upstream:     upstream,
+		sigData:      sigData,
+		policy:       options.RoundRobinPolicy,
+		errorHandler: errorHandler,
+		stop:         make(chan struct{}),
+	}
+	if upstream.LoadBalancing != nil {
+		if upstream.LoadBalancing.Policy != "" {
+			l.policy = upstream.LoadBalancing.Policy
+		}
+		l.sticky = upstream.LoadBalancing.StickySessions
+	}
+
+	for _, uri := range upstream.URIs {
+		e, err := l.newEndpoint(uri)
+		if err != nil {
+			return nil, err
+		}
+		l.endpoints = append(l.endpoints, e)
+	}
+
+	if upstream.Discovery != nil {
+		r, err := newResolver(upstream.Discovery)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure discovery: %v", err)
+		}
+		interval := options.DefaultDiscoveryRefreshInterval
+		if upstream.Discovery.RefreshInterval != nil {
+			interval = upstream.Discovery.RefreshInterval.Duration()
+		}
+		l.startDiscovery(r, interval)
+	}
+
+	return l, nil
+}
+
+// newEndpoint creates an endpoint proxying to the given URI.
+func (l *loadBalancer) newEndpoint(uri string) (*endpoint, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URI %q: %w", uri, err)
+	}
+
+	handler, err := newHTTPUpstreamProxy(l.upstream, u, l.sigData, l.errorHandler)
+	if err != nil {
+		return nil, fmt.Errorf("could not create proxy for URI %q: %v", uri, err)
+	}
+
+	return &endpoint{
+		uri:   uri,
+		host:  u.Host,
+		proxy: handler.(*httpUpstreamProxy),
+	}, nil
+}
+
+// startDiscovery resolves the upstream servers and then refreshes them in the
+// background every interval.
+// Failures are logged and the existing servers are kept until the next
+// successful refresh.
+func (l *loadBalancer) startDiscovery(r resolver, interval time.Duration) {
+	worker := workers.New("upstream_discovery:"+l.upstream.ID, interval)
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+
+		uris, err := r.resolve(ctx)
+		worker.Record(err)
+		if err != nil {
+			logger.Errorf("Error discovering servers for upstream %q: %v", l.upstream.ID, err)
+			return
+		}
+		l.setEndpoints(append(append([]string{}, l.upstream.URIs...), uris...))
+	}
+
+	refresh()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// close stops service discovery and the health checks of the endpoints.
+func (l *loadBalancer) close() {
+	close(l.stop)
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for _, e := range l.endpoints {
+		if e.proxy.health != nil {
+			e.proxy.health.close()
+		}
+	}
+}
+
+// setEndpoints replaces the endpoints with one for each URI.
+// Existing endpoints are kept so that their state is not lost.
+func (l *loadBalancer) setEndpoints(uris []string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	existing := make(map[string]*endpoint, len(l.endpoints))
+	for _, e := range l.endpoints {
+		existing[e.uri] = e
+	}
+
+	endpoints := make([]*endpoint, 0, len(uris))
+	seen := make(map[string]struct{}, len(uris))
+	for _, uri := range uris {
+		if _, ok := seen[uri]; ok {
+			continue
+		}
+		seen[uri] = struct{}{}
+
+		e, ok := existing[uri]
+		if !ok {
+			var err error
+			e, err = l.newEndpoint(uri)
+			if err != nil {
+				logger.Errorf("Error adding server to upstream %q: %v", l.upstream.ID, err)
+				continue
+			}
+			logger.Printf("Added server %q to upstream %q", uri, l.upstream.ID)
+		}
+		delete(existing, uri)
+		endpoints = append(endpoints, e)
+	}
+
+	for uri, e := range existing {
+		logger.Printf("Removed server %q from upstream %q", uri, l.upstream.ID)
+		if e.proxy.health != nil {
+			e.proxy.health.close()
+		}
+	}
+
+	l.endpoints = endpoints
+}
+
+// healthCheckers returns the health checks of the current endpoints.
+func (l *loadBalancer) healthCheckers() []*healthChecker {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	checkers := []*healthChecker{}
+	for _, e := range l.endpoints {
+		if e.proxy.health != nil {
+			checkers = append(checkers, e.proxy.health)
+		}
+	}
+	return checkers
+}
+
+// ServeHTTP proxies the request to one of the available endpoints.
+func (l *loadBalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	e := l.pick(req)
+	if e == nil {
+		middleware.GetRequestScope(req).Upstream = l.upstream.ID
+		if l.errorHandler != nil {
+			l.errorHandler(rw, req, errNoAvailableEndpoints)
+			return
+		}
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	atomic.AddInt64(&e.inFlight, 1)
+	defer atomic.AddInt64(&e.inFlight, -1)
+
+	e.proxy.ServeHTTP(rw, req)
+}
+
+// pick chooses the endpoint for the request, or nil if no endpoints are
+// available.
+func (l *loadBalancer) pick(req *http.Request) *endpoint {
+	l.mutex.RLock()
+	candidates := make([]*endpoint, 0, len(l.endpoints))
+	for _, e := range l.endpoints {
+		if e.available() {
+			candidates = append(candidates, e)
+		}
+	}
+	l.mutex.RUnlock()
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if l.sticky {
+		if key := userKey(req); key != "" {
+			return pickByHash(candidates, key)
+		}
+	}
+
+	// Start from the round robin position so that ties are spread evenly
+	start := int((atomic.AddUint64(&l.next, 1) - 1) % uint64(len(candidates)))
+	if l.policy != options.LeastConnectionsPolicy {
+		return candidates[start]
+	}
+
+	chosen := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		e := candidates[(start+i)%len(candidates)]
+		if atomic.LoadInt64(&e.inFlight) < atomic.LoadInt64(&chosen.inFlight) {
+			chosen = e
+		}
+	}
+	return chosen
+}
+
+// userKey returns a key identifying the authenticated user, or an empty
+// string if the request is not authenticated.
+func userKey(req *http.Request) string {
+	scope := middleware.GetRequestScope(req)
+	if scope == nil || scope.Session == nil {
+		return ""
+	}
+	if scope.Session.User != "" {
+		return scope.Session.User
+	}
+	return scope.Session.Email
+}
+
+// pickByHash uses rendezvous hashing to choose an endpoint for the key.
+// Only keys assigned to an endpoint that becomes unavailable are moved to
+// other endpoints.
+func pickByHash(candidates []*endpoint, key string) *endpoint {
+	var chosen *endpoint
+	var highest uint64
+	for _, e := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte(e.host))
+		if score := h.Sum64(); chosen == nil || score > highest {
+			chosen = e
+			highest = score
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,102 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load Balancer Suite", func() {
+	var servers []*httptest.Server
+
+	BeforeEach(func() {
+		servers = nil
+		for _, name := range []string{"a", "b", "c"} {
+			name := name
+			servers = append(servers, httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Write([]byte(name))
+			})))
+		}
+	})
+
+	AfterEach(func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	})
+
+	newBalancer := func(lb *options.LoadBalancing) *loadBalancer {
+		uris := []string{}
+		for _, server := range servers {
+			uris = append(uris, server.URL)
+		}
+
+		handler, err := newLoadBalancer(options.Upstream{
+			ID:            "balanced",
+			URIs:          uris,
+			LoadBalancing: lb,
+		}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		return handler.(*loadBalancer)
+	}
+
+	serve := func(handler http.Handler, session *sessionsapi.SessionState) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: session})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(middlewareapi.GetRequestScope(req).Upstream).To(Equal("balanced"))
+		return rw.Body.String()
+	}
+
+	It("balances requests using round robin by default", func() {
+		balancer := newBalancer(nil)
+
+		responses := []string{}
+		for i := 0; i < 6; i++ {
+			responses = append(responses, serve(balancer, nil))
+		}
+		Expect(responses).To(Equal([]string{"a", "b", "c", "a", "b", "c"}))
+	})
+
+	It("chooses the endpoint with the least connections", func() {
+		balancer := newBalancer(&options.LoadBalancing{Policy: options.LeastConnectionsPolicy})
+		balancer.endpoints[0].inFlight = 2
+		balancer.endpoints[1].inFlight = 1
+		balancer.endpoints[2].inFlight = 3
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		for i := 0; i < 3; i++ {
+			Expect(balancer.pick(req)).To(Equal(balancer.endpoints[1]))
+		}
+	})
+
+	It("routes authenticated users to the same endpoint with sticky sessions", func() {
+		balancer := newBalancer(&options.LoadBalancing{StickySessions: true})
+		session := &sessionsapi.SessionState{User: "john"}
+
+		first := serve(balancer, session)
+		for i := 0; i < 5; i++ {
+			Expect(serve(balancer, session)).To(Equal(first))
+		}
+	})
+
+	It("skips ejected endpoints", func() {
+		balancer := newBalancer(nil)
+		for _, e := range balancer.endpoints[:2] {
+			e.proxy.outlier = newOutlierDetector(&options.OutlierDetection{ConsecutiveFailures: 1})
+			e.proxy.outlier.failure(e.host)
+		}
+
+		for i := 0; i < 3; i++ {
+			Expect(serve(balancer, nil)).To(Equal("c"))
+		}
+	})
+})
@@ -0,0 +1,63 @@
+package upstream
+
+import (
+	"io"
+	"sync"
+)
+
+// copyBufferSize is the size of the buffers used to copy bodies between the
+// client and upstream servers, the same as the buffers of io.Copy.
+const copyBufferSize = 32 * 1024
+
+// copyBuffers is the pool of buffers shared by all upstreams, so that
+// proxying a response does not allocate a new buffer.
+var copyBuffers = newBufferPool(copyBufferSize)
+
+// bufferPool is a pool of byte slices of the same size. It implements
+// httputil.BufferPool so that reverse proxies reuse the buffers of previous
+// responses.
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// newBufferPool creates a pool of buffers of the size.
+func newBufferPool(size int) *bufferPool {
+	p := &bufferPool{size: size}
+	p.pool.New = func() interface{} {
+		buf := make([]byte, size)
+		return &buf
+	}
+	return p
+}
+
+// Get returns a buffer from the pool, or a new buffer when it is empty.
+func (p *bufferPool) Get() []byte {
+	return *p.pool.Get().(*[]byte)
+}
+
+// Put returns the buffer to the pool. Buffers of other sizes are dropped.
+func (p *bufferPool) Put(buf []byte) {
+	if cap(buf) != p.size {
+		return
+	}
+	buf = buf[:p.size]
+	p.pool.Put(&buf)
+}
+
+// copyBuffer copies from src to dst with a buffer from the pool.
+// Like io.Copy, the WriteTo of src or the ReadFrom of dst are used when they
+// are available, so that files are sent to the client with sendfile, and a
+// buffer is only taken from the pool when neither is.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	if wt, ok := src.(io.WriterTo); ok {
+		return wt.WriteTo(dst)
+	}
+	if rf, ok := dst.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+
+	buf := copyBuffers.Get()
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(dst, src, buf)
+}
@@ -0,0 +1,155 @@
+package upstream
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// readerFromResponseWriter records whether the data was copied to it with
+// ReadFrom.
+type readerFromResponseWriter struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder.Body, r)
+}
+
+// onlyReader hides the WriteTo of a reader, so that copies from it need a
+// buffer.
+type onlyReader struct {
+	io.Reader
+}
+
+var _ = Describe("Buffers", func() {
+	Context("bufferPool", func() {
+		It("returns buffers of its size", func() {
+			pool := newBufferPool(1024)
+			buf := pool.Get()
+			Expect(buf).To(HaveLen(1024))
+
+			pool.Put(buf[:10])
+			Expect(pool.Get()).To(HaveLen(1024))
+		})
+
+		It("drops buffers of other sizes", func() {
+			pool := newBufferPool(1024)
+			pool.Put(make([]byte, 10))
+			Expect(pool.Get()).To(HaveLen(1024))
+		})
+	})
+
+	Context("copyBuffer", func() {
+		data := bytes.Repeat([]byte("data"), 3*copyBufferSize)
+
+		It("copies with a buffer when neither fast path is available", func() {
+			var dst bytes.Buffer
+			n, err := copyBuffer(struct{ io.Writer }{&dst}, onlyReader{bytes.NewReader(data)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(len(data)))
+			Expect(dst.Bytes()).To(Equal(data))
+		})
+
+		It("does not allocate a buffer for each copy", func() {
+			var dst bytes.Buffer
+			allocs := testing.AllocsPerRun(100, func() {
+				dst.Reset()
+				_, err := copyBuffer(struct{ io.Writer }{&dst}, onlyReader{bytes.NewReader(data)})
+				Expect(err).ToNot(HaveOccurred())
+			})
+			// The reader and the pooled buffer pointer are the only allocations
+			Expect(allocs).To(BeNumerically("<=", 4))
+		})
+
+		It("uses the ReadFrom of the response writers", func() {
+			rw := &readerFromResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+			metrics := &metricsResponseWriter{ResponseWriter: &headerPolicyResponseWriter{
+				ResponseWriter: rw,
+				policy:         &options.ResponseHeaderPolicy{Remove: []string{"Server"}},
+			}}
+			rw.Header().Set("Server", "upstream")
+
+			n, err := copyBuffer(metrics, onlyReader{bytes.NewReader(data)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(len(data)))
+			Expect(rw.readFrom).To(BeTrue())
+			Expect(rw.Body.Bytes()).To(Equal(data))
+			Expect(metrics.status).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Server")).To(BeEmpty())
+		})
+	})
+})
+
+// benchmarkBodySize is the size of the responses of the benchmarks.
+const benchmarkBodySize = 1024 * 1024
+
+func BenchmarkHTTPUpstreamProxy(b *testing.B) {
+	body := bytes.Repeat([]byte("a"), benchmarkBodySize)
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write(body)
+	}))
+	defer backend.Close()
+
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler, err := newHTTPUpstreamProxy(options.Upstream{ID: "benchmark"}, u, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		handler.ServeHTTP(rw, middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{}))
+	}))
+	defer proxy.Close()
+
+	b.ReportAllocs()
+	b.SetBytes(benchmarkBodySize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := http.Get(proxy.URL + "/")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkCopyBuffer(b *testing.B) {
+	body := bytes.Repeat([]byte("a"), benchmarkBodySize)
+	rw := &metricsResponseWriter{ResponseWriter: &headerPolicyResponseWriter{
+		ResponseWriter: discardResponseWriter{header: http.Header{}},
+		policy:         &options.ResponseHeaderPolicy{},
+	}}
+
+	b.ReportAllocs()
+	b.SetBytes(benchmarkBodySize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := copyBuffer(rw, onlyReader{bytes.NewReader(body)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// discardResponseWriter discards the responses written to it.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header         { return w.header }
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
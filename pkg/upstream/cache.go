@@ -0,0 +1,366 @@
+package upstream
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// cacheFileSuffix is the suffix of response bodies stored on disk.
+const cacheFileSuffix = ".cache"
+
+// cacheEntry is a cached upstream response.
+type cacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	vary    http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// responseCache is a least recently used cache of upstream responses.
+// Response bodies are held in memory, or stored in a directory when one is
+// configured.
+type responseCache struct {
+	upstream     string
+	shared       bool
+	directory    string
+	maxEntries   int
+	maxEntrySize int64
+	now          func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// newResponseCache creates a responseCache from the cache options.
+// Any bodies left in the cache directory by a previous run are removed.
+func newResponseCache(upstreamID string, opts *options.ResponseCache) (*responseCache, error) {
+	c := &responseCache{
+		upstream:     upstreamID,
+		shared:       opts.Shared,
+		directory:    opts.Directory,
+		maxEntries:   opts.MaxEntries,
+		maxEntrySize: opts.MaxEntrySize,
+		now:          time.Now,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = options.DefaultCacheMaxEntries
+	}
+	if c.maxEntrySize <= 0 {
+		c.maxEntrySize = options.DefaultCacheMaxEntrySize
+	}
+
+	if c.directory != "" {
+		if err := os.MkdirAll(c.directory, 0700); err != nil {
+			return nil, err
+		}
+		files, err := filepath.Glob(filepath.Join(c.directory, "*"+cacheFileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// middleware creates a middleware that serves cached responses and caches
+// responses from the next handler.
+func (c *responseCache) middleware() alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			key, ok := c.requestKey(req)
+			if !ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			requestDirectives := parseCacheControl(req.Header.Values("Cache-Control"))
+			_, noCache := requestDirectives["no-cache"]
+			if !noCache && req.Header.Get("Pragma") != "no-cache" {
+				if entry := c.get(key, req); entry != nil {
+					c.serve(rw, entry)
+					return
+				}
+			}
+
+			cw := &cacheResponseWriter{
+				ResponseWriter: rw,
+				maxSize:        c.maxEntrySize,
+			}
+			next.ServeHTTP(cw, req)
+
+			if _, noStore := requestDirectives["no-store"]; noStore || !cw.cacheable {
+				return
+			}
+			c.store(key, req, cw)
+		})
+	}
+}
+
+// requestKey returns the cache key for the request and whether the request
+// may be served from the cache.
+func (c *responseCache) requestKey(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || req.Header.Get("Upgrade") != "" {
+		return "", false
+	}
+
+	key := req.Host + req.URL.RequestURI()
+	if !c.shared {
+		user := userKey(req)
+		if user == "" {
+			return "", false
+		}
+		key = user + "\x00" + key
+	}
+	return key, true
+}
+
+// get returns the fresh cached response matching the request, if any.
+func (c *responseCache) get(key string, req *http.Request) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil
+	}
+	for name, values := range entry.vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return nil
+		}
+	}
+
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// serve writes the cached response to the client.
+func (c *responseCache) serve(rw http.ResponseWriter, entry *cacheEntry) {
+	body := entry.body
+	if c.directory != "" {
+		var err error
+		body, err = ioutil.ReadFile(c.bodyPath(entry.key))
+		if err != nil {
+			logger.Errorf("Error reading cached response for upstream %q: %v", c.upstream, err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := rw.Header()
+	for name, values := range entry.header {
+		header[name] = values
+	}
+	header.Set("Age", strconv.Itoa(int(c.now().Sub(entry.stored).Seconds())))
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	rw.WriteHeader(entry.status)
+	_, _ = rw.Write(body)
+}
+
+// store caches the response if the response headers allow it.
+func (c *responseCache) store(key string, req *http.Request, cw *cacheResponseWriter) {
+	ttl, ok := c.responseTTL(cw.status, cw.header)
+	if !ok {
+		return
+	}
+
+	vary := http.Header{}
+	for _, value := range cw.header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				vary[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+			}
+		}
+	}
+
+	now := c.now()
+	entry := &cacheEntry{
+		key:     key,
+		status:  cw.status,
+		header:  cw.header,
+		vary:    vary,
+		body:    cw.body.Bytes(),
+		stored:  now,
+		expires: now.Add(ttl),
+	}
+	entry.header.Del("Content-Length")
+
+	if c.directory != "" {
+		if err := ioutil.WriteFile(c.bodyPath(key), entry.body, 0600); err != nil {
+			logger.Errorf("Error caching response for upstream %q: %v", c.upstream, err)
+			return
+		}
+		entry.body = nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove evicts the entry from the cache.
+// The cache mutex must be held by the caller.
+func (c *responseCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+
+	if c.directory != "" {
+		if err := os.Remove(c.bodyPath(entry.key)); err != nil && !os.IsNotExist(err) {
+			logger.Errorf("Error removing cached response for upstream %q: %v", c.upstream, err)
+		}
+	}
+}
+
+// bodyPath returns the path at which the body for the key is stored.
+func (c *responseCache) bodyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.directory, hex.EncodeToString(sum[:])+cacheFileSuffix)
+}
+
+// responseTTL determines how long the response may be cached for based on
+// its Cache-Control header, and whether it may be cached at all.
+func (c *responseCache) responseTTL(status int, header http.Header) (time.Duration, bool) {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	if _, ok := directives["private"]; ok && c.shared {
+		return 0, false
+	}
+
+	maxAge, ok := directives["max-age"]
+	if sMaxAge, found := directives["s-maxage"]; found && c.shared {
+		maxAge, ok = sMaxAge, true
+	}
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(maxAge)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// parseCacheControl parses the Cache-Control header values into a map of
+// directive names to their values.
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+// cacheResponseWriter records the response while it is written to the
+// client so that it can be cached.
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	maxSize     int64
+	status      int
+	header      http.Header
+	wroteHeader bool
+	cacheable   bool
+	body        bytes.Buffer
+}
+
+// WriteHeader records the status code and response headers and then writes
+// the response headers.
+// The headers are copied before they are written as outer middlewares, such
+// as compression, may modify them for this response only.
+func (w *cacheResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		w.header = w.Header().Clone()
+		w.cacheable = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the data, unless it exceeds the maximum cacheable size, and
+// writes it to the client.
+func (w *cacheResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.cacheable {
+		if int64(w.body.Len()+len(b)) > w.maxSize {
+			w.cacheable = false
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		w.cacheable = false
+	}
+	return n, err
+}
+
+// Flush sends any buffered data to the client.
+func (w *cacheResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
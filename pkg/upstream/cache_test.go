@@ -0,0 +1,142 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response Cache Suite", func() {
+	var requests int
+	var cacheControl string
+	var now time.Time
+	var handler http.Handler
+	var cache *responseCache
+
+	newCache := func(opts *options.ResponseCache) {
+		var err error
+		cache, err = newResponseCache("foo", opts)
+		Expect(err).ToNot(HaveOccurred())
+		cache.now = func() time.Time { return now }
+
+		handler = cache.middleware()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requests++
+			rw.Header().Set("Cache-Control", cacheControl)
+			rw.Write([]byte(req.URL.Path))
+		}))
+	}
+
+	serve := func(path string, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		scope := &middlewareapi.RequestScope{}
+		if user != "" {
+			scope.Session = &sessionsapi.SessionState{User: user}
+		}
+		req = middlewareapi.AddRequestScope(req, scope)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	BeforeEach(func() {
+		requests = 0
+		cacheControl = "max-age=60"
+		now = time.Now()
+	})
+
+	It("serves cached responses until they expire", func() {
+		newCache(&options.ResponseCache{})
+
+		Expect(serve("/app.js", "john").Body.String()).To(Equal("/app.js"))
+		rw := serve("/app.js", "john")
+		Expect(rw.Body.String()).To(Equal("/app.js"))
+		Expect(rw.Header().Get("Age")).To(Equal("0"))
+		Expect(requests).To(Equal(1))
+
+		now = now.Add(time.Minute)
+		serve("/app.js", "john")
+		Expect(requests).To(Equal(2))
+	})
+
+	It("caches responses per user unless shared", func() {
+		newCache(&options.ResponseCache{})
+		serve("/app.js", "john")
+		serve("/app.js", "jane")
+		serve("/app.js", "")
+		serve("/app.js", "")
+		Expect(requests).To(Equal(4))
+
+		newCache(&options.ResponseCache{Shared: true})
+		requests = 0
+		serve("/app.js", "john")
+		serve("/app.js", "jane")
+		Expect(requests).To(Equal(1))
+	})
+
+	It("does not cache responses that forbid it", func() {
+		newCache(&options.ResponseCache{Shared: true})
+
+		for _, cc := range []string{"", "no-store, max-age=60", "no-cache", "private, max-age=60"} {
+			cacheControl = cc
+			requests = 0
+			serve("/app.js", "john")
+			serve("/app.js", "john")
+			Expect(requests).To(Equal(2), "Cache-Control: %s", cc)
+		}
+	})
+
+	It("evicts the least recently used response", func() {
+		newCache(&options.ResponseCache{MaxEntries: 2})
+
+		serve("/a.js", "john")
+		serve("/b.js", "john")
+		serve("/a.js", "john")
+		serve("/c.js", "john")
+		Expect(requests).To(Equal(3))
+
+		serve("/a.js", "john")
+		Expect(requests).To(Equal(3))
+		serve("/b.js", "john")
+		Expect(requests).To(Equal(4))
+	})
+
+	Context("with a cache directory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "oauth2-proxy-cache")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("stores response bodies on disk", func() {
+			stale := filepath.Join(dir, "stale"+cacheFileSuffix)
+			Expect(ioutil.WriteFile(stale, []byte("stale"), 0600)).To(Succeed())
+
+			newCache(&options.ResponseCache{Directory: dir})
+			Expect(stale).ToNot(BeAnExistingFile())
+
+			serve("/app.js", "john")
+			Expect(serve("/app.js", "john").Body.String()).To(Equal("/app.js"))
+			Expect(requests).To(Equal(1))
+
+			files, err := filepath.Glob(filepath.Join(dir, "*"+cacheFileSuffix))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,241 @@
+package upstream
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/brotli"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// defaultCompressionContentTypes are the media types compressed when no
+// content types are configured.
+// Already compressed formats such as images, video and archives are
+// deliberately excluded.
+var defaultCompressionContentTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+	"text/css",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+	"text/xml",
+}
+
+// compressionEncodings are the supported content codings, in order of
+// preference when the client accepts several of them equally.
+var compressionEncodings = []string{"br", "gzip"}
+
+// newCompression creates a new middleware that will compress responses with
+// brotli or gzip encoding, whichever the client prefers.
+func newCompression(opts *options.Compression) alice.Constructor {
+	contentTypes := opts.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = defaultCompressionContentTypes
+	}
+	minSize := opts.MinSize
+	if minSize <= 0 {
+		minSize = options.DefaultCompressionMinSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			encoding := compressionEncoding(req)
+			if encoding == "" {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: rw,
+				encoding:       encoding,
+				contentTypes:   contentTypes,
+				minSize:        minSize,
+			}
+			defer cw.close()
+
+			rw.Header().Add("Vary", "Accept-Encoding")
+			next.ServeHTTP(cw, req)
+		})
+	}
+}
+
+// compressionEncoding determines the content coding the response to the
+// request should be compressed with, if it may be compressed.
+func compressionEncoding(req *http.Request) string {
+	if req.Method == http.MethodHead || req.Header.Get("Range") != "" {
+		return ""
+	}
+	if req.Header.Get("Upgrade") != "" || requestutil.IsGRPCRequest(req) {
+		return ""
+	}
+	return negotiateEncoding(req.Header.Values("Accept-Encoding"))
+}
+
+// negotiateEncoding returns the supported content coding with the highest
+// quality value in the Accept-Encoding header values, or an empty string if
+// the client does not accept any of them.
+// A coding that is not listed takes the quality value of "*", if present.
+func negotiateEncoding(values []string) string {
+	qualities := map[string]float64{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || strings.TrimSpace(name) != "q" {
+					continue
+				}
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+			qualities[coding] = q
+		}
+	}
+
+	encoding, best := "", 0.0
+	for _, coding := range compressionEncodings {
+		q, found := qualities[coding]
+		if !found {
+			q = qualities["*"]
+		}
+		if q > best {
+			encoding, best = coding, q
+		}
+	}
+	return encoding
+}
+
+// compressWriter compresses the data written to it.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressResponseWriter decides when the response headers are written
+// whether the response should be compressed and, if so, compresses the
+// response body with the negotiated encoding.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding       string
+	contentTypes   []string
+	minSize        int
+	wroteHeader    bool
+	compressWriter compressWriter
+}
+
+// WriteHeader determines whether to compress the response and writes the
+// response headers.
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	if w.shouldCompress(code) {
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", w.encoding)
+		if w.encoding == "br" {
+			w.compressWriter = brotli.NewWriter(w.ResponseWriter)
+		} else {
+			w.compressWriter = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// shouldCompress determines whether the response should be compressed
+// based on the status code and response headers.
+// Event streams are never compressed as clients expect each event to be
+// delivered as soon as it is written.
+func (w *compressResponseWriter) shouldCompress(code int) bool {
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		return false
+	}
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if length := header.Get("Content-Length"); length != "" {
+		if n, err := strconv.Atoi(length); err == nil && n < w.minSize {
+			return false
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, contentType := range w.contentTypes {
+		if strings.EqualFold(mediaType, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the response headers if they have not been written and then
+// writes the data to the client, compressing it if required.
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressWriter != nil {
+		return w.compressWriter.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+// Compressed data is flushed so that streamed responses are not delayed.
+func (w *compressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressWriter != nil {
+		_ = w.compressWriter.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows WebSocket connections to take over the connection.
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
+
+// close completes the compressed response body.
+func (w *compressResponseWriter) close() {
+	if w.compressWriter != nil {
+		_ = w.compressWriter.Close()
+	}
+}
@@ -0,0 +1,129 @@
+package upstream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/brotli"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression Suite", func() {
+	body := strings.Repeat("Hello, world! ", 100)
+
+	type compressionTableInput struct {
+		method          string
+		acceptEncoding  string
+		responseHeaders http.Header
+		expectEncoding  string
+	}
+
+	DescribeTable("newCompression",
+		func(in compressionTableInput) {
+			method := in.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			if in.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", in.acceptEncoding)
+			}
+
+			handler := newCompression(&options.Compression{})(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				for name, values := range in.responseHeaders {
+					rw.Header()[name] = values
+				}
+				rw.WriteHeader(http.StatusOK)
+				rw.Write([]byte(body))
+			}))
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if in.expectEncoding == "" {
+				Expect(rw.Header().Get("Content-Encoding")).To(Equal(in.responseHeaders.Get("Content-Encoding")))
+				Expect(rw.Body.String()).To(Equal(body))
+				return
+			}
+
+			Expect(rw.Header().Get("Content-Encoding")).To(Equal(in.expectEncoding))
+			Expect(rw.Header().Get("Content-Length")).To(BeEmpty())
+			Expect(rw.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+
+			if in.expectEncoding == "br" {
+				var expected bytes.Buffer
+				bw := brotli.NewWriter(&expected)
+				bw.Write([]byte(body))
+				Expect(bw.Close()).To(Succeed())
+				Expect(rw.Body.Bytes()).To(Equal(expected.Bytes()))
+				return
+			}
+
+			reader, err := gzip.NewReader(rw.Body)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(body))
+		},
+		Entry("with a compressible response", compressionTableInput{
+			acceptEncoding:  "deflate, gzip;q=0.8",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+			expectEncoding:  "gzip",
+		}),
+		Entry("when the client accepts brotli", compressionTableInput{
+			acceptEncoding:  "gzip, deflate, br",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+			expectEncoding:  "br",
+		}),
+		Entry("when the client prefers gzip", compressionTableInput{
+			acceptEncoding:  "br;q=0.5, gzip",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+			expectEncoding:  "gzip",
+		}),
+		Entry("when the client does not accept gzip", compressionTableInput{
+			acceptEncoding:  "gzip;q=0, br",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+			expectEncoding:  "br",
+		}),
+		Entry("when the client accepts any encoding", compressionTableInput{
+			acceptEncoding:  "*",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+			expectEncoding:  "br",
+		}),
+		Entry("when the client does not accept brotli or gzip", compressionTableInput{
+			acceptEncoding:  "deflate, br;q=0, *;q=0",
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+		}),
+		Entry("with no Accept-Encoding", compressionTableInput{
+			responseHeaders: http.Header{"Content-Type": []string{"text/html"}},
+		}),
+		Entry("with an already compressed content type", compressionTableInput{
+			acceptEncoding:  "gzip",
+			responseHeaders: http.Header{"Content-Type": []string{"image/png"}},
+		}),
+		Entry("with an already encoded response", compressionTableInput{
+			acceptEncoding: "gzip",
+			responseHeaders: http.Header{
+				"Content-Type":     []string{"text/html"},
+				"Content-Encoding": []string{"br"},
+			},
+		}),
+		Entry("with an event stream", compressionTableInput{
+			acceptEncoding:  "gzip",
+			responseHeaders: http.Header{"Content-Type": []string{"text/event-stream"}},
+		}),
+		Entry("with a response smaller than the minimum size", compressionTableInput{
+			acceptEncoding: "gzip",
+			responseHeaders: http.Header{
+				"Content-Type":   []string{"text/html"},
+				"Content-Length": []string{"10"},
+			},
+		}),
+	)
+})
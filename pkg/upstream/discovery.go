@@ -0,0 +1,203 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// serviceAccountDir is where Kubernetes mounts the service account
+// credentials within a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// resolver discovers the URIs of the upstream servers.
+type resolver interface {
+	resolve(ctx context.Context) ([]string, error)
+}
+
+// newResolver creates the resolver for the discovery configuration.
+func newResolver(opts *options.UpstreamDiscovery) (resolver, error) {
+	scheme := opts.Scheme
+	if scheme == "" {
+		scheme = httpScheme
+	}
+
+	switch {
+	case opts.DNSSRV != "":
+		return &dnsSRVResolver{
+			name:      opts.DNSSRV,
+			scheme:    scheme,
+			lookupSRV: net.DefaultResolver.LookupSRV,
+		}, nil
+	case opts.Kubernetes != nil:
+		return newKubernetesResolver(opts.Kubernetes, scheme)
+	default:
+		return nil, errors.New("no discovery method configured")
+	}
+}
+
+// dnsSRVResolver discovers upstream servers from a DNS SRV record.
+type dnsSRVResolver struct {
+	name      string
+	scheme    string
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// resolve looks up the SRV record and returns a URI for each target.
+func (r *dnsSRVResolver) resolve(ctx context.Context) ([]string, error) {
+	_, records, err := r.lookupSRV(ctx, "", "", r.name)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up SRV record %q: %v", r.name, err)
+	}
+
+	uris := []string{}
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		uris = append(uris, r.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	sort.Strings(uris)
+	return uris, nil
+}
+
+// kubernetesResolver discovers upstream servers from the EndpointSlices of a
+// Kubernetes Service using the in cluster service account.
+type kubernetesResolver struct {
+	client    *http.Client
+	apiServer string
+	tokenFile string
+	namespace string
+	service   string
+	port      string
+	scheme    string
+}
+
+// newKubernetesResolver creates a kubernetesResolver using the in cluster
+// configuration.
+func newKubernetesResolver(opts *options.KubernetesDiscovery, scheme string) (*kubernetesResolver, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("kubernetes discovery is only supported within a Kubernetes cluster")
+	}
+
+	rootCAs, err := util.GetCertPool([]string{filepath.Join(serviceAccountDir, "ca.crt")})
+	if err != nil {
+		return nil, fmt.Errorf("could not load service account CA: %v", err)
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		data, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("could not determine namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	}
+
+	return &kubernetesResolver{
+		client:    &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+		namespace: namespace,
+		service:   opts.Service,
+		port:      opts.Port,
+		scheme:    scheme,
+	}, nil
+}
+
+// endpointSliceList is the subset of the discovery.k8s.io/v1 EndpointSliceList
+// used to discover upstream servers.
+type endpointSliceList struct {
+	Items []struct {
+		Endpoints []struct {
+			Addresses  []string `json:"addresses"`
+			Conditions struct {
+				Ready *bool `json:"ready"`
+			} `json:"conditions"`
+		} `json:"endpoints"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int32  `json:"port"`
+		} `json:"ports"`
+	} `json:"items"`
+}
+
+// resolve lists the EndpointSlices of the Service and returns a URI for each
+// ready endpoint address.
+func (r *kubernetesResolver) resolve(ctx context.Context) ([]string, error) {
+	// Service account tokens are rotated, so the token is read for each request
+	token, err := ioutil.ReadFile(r.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?labelSelector=%s",
+		r.apiServer, url.PathEscape(r.namespace), url.QueryEscape("kubernetes.io/service-name="+r.service))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not list endpoint slices: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not list endpoint slices: unexpected status code %d", resp.StatusCode)
+	}
+
+	var slices endpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&slices); err != nil {
+		return nil, fmt.Errorf("could not decode endpoint slices: %v", err)
+	}
+
+	uris := []string{}
+	for _, slice := range slices.Items {
+		port := int32(0)
+		for _, p := range slice.Ports {
+			if r.port == "" || p.Name == r.port {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		for _, e := range slice.Endpoints {
+			// Endpoints with an unknown ready state should be considered ready
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, address := range e.Addresses {
+				uris = append(uris, r.scheme+"://"+net.JoinHostPort(address, strconv.Itoa(int(port))))
+			}
+		}
+	}
+	sort.Strings(uris)
+	return uris, nil
+}
@@ -0,0 +1,105 @@
+package upstream
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upstream Discovery Suite", func() {
+	Context("with DNS SRV records", func() {
+		It("returns a URI for each target", func() {
+			r := &dnsSRVResolver{
+				name:   "_http._tcp.app.example.com",
+				scheme: "https",
+				lookupSRV: func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+					Expect(name).To(Equal("_http._tcp.app.example.com"))
+					return "", []*net.SRV{
+						{Target: "b.app.example.com.", Port: 8443},
+						{Target: "a.app.example.com.", Port: 8443},
+					}, nil
+				},
+			}
+
+			uris, err := r.resolve(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uris).To(Equal([]string{"https://a.app.example.com:8443", "https://b.app.example.com:8443"}))
+		})
+	})
+
+	Context("with Kubernetes EndpointSlices", func() {
+		var dir string
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "oauth2-proxy-discovery")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "token"), []byte("token\n"), 0600)).To(Succeed())
+
+			server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.URL.Path).To(Equal("/apis/discovery.k8s.io/v1/namespaces/default/endpointslices"))
+				Expect(req.URL.Query().Get("labelSelector")).To(Equal("kubernetes.io/service-name=app"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+
+				rw.Write([]byte(`{"items": [{
+					"endpoints": [
+						{"addresses": ["10.0.0.2"], "conditions": {"ready": true}},
+						{"addresses": ["10.0.0.3"], "conditions": {"ready": false}},
+						{"addresses": ["10.0.0.1"], "conditions": {}}
+					],
+					"ports": [{"name": "metrics", "port": 9090}, {"name": "http", "port": 8080}]
+				}]}`))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("returns a URI for each ready endpoint", func() {
+			r := &kubernetesResolver{
+				client:    server.Client(),
+				apiServer: server.URL,
+				tokenFile: filepath.Join(dir, "token"),
+				namespace: "default",
+				service:   "app",
+				port:      "http",
+				scheme:    "http",
+			}
+
+			uris, err := r.resolve(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uris).To(Equal([]string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}))
+		})
+	})
+
+	It("updates the load balanced endpoints", func() {
+		handler, err := newLoadBalancer(options.Upstream{
+			ID:   "discovered",
+			URIs: []string{"http://static:8080"},
+		}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		balancer := handler.(*loadBalancer)
+		static := balancer.endpoints[0]
+
+		balancer.setEndpoints([]string{"http://static:8080", "http://a:8080", "http://b:8080", "http://a:8080"})
+		Expect(balancer.endpoints).To(HaveLen(3))
+		Expect(balancer.endpoints[0]).To(BeIdenticalTo(static))
+		first := balancer.endpoints[1]
+
+		balancer.setEndpoints([]string{"http://static:8080", "http://a:8080"})
+		Expect(balancer.endpoints).To(HaveLen(2))
+		Expect(balancer.endpoints[1]).To(BeIdenticalTo(first))
+	})
+})
@@ -0,0 +1,204 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/watcher"
+)
+
+// dynamicProxy serves the configured upstreams along with upstreams that are
+// added and removed at runtime.
+// Each change builds a new multiUpstreamProxy, reusing the handlers of
+// unchanged upstreams, which then replaces the current one atomically.
+type dynamicProxy struct {
+	config  options.UpstreamConfig
+	sigData *options.SignatureData
+	writer  pagewriter.Writer
+
+	// current holds the *multiUpstreamProxy serving requests.
+	current atomic.Value
+
+	// mutex ensures only one change is applied at a time.
+	mutex    sync.Mutex
+	dynamic  []options.Upstream
+	handlers map[string]*upstreamHandler
+	done     chan bool
+	closed   bool
+}
+
+// ServeHTTP serves the request with the current upstreams.
+func (p *dynamicProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	p.load().ServeHTTP(rw, req)
+}
+
+// VerifyConnection returns an error if any health checked upstream has no
+// healthy servers.
+func (p *dynamicProxy) VerifyConnection(ctx context.Context) error {
+	return p.load().VerifyConnection(ctx)
+}
+
+// Upstreams returns the configured upstreams followed by those added at
+// runtime.
+func (p *dynamicProxy) Upstreams() []options.Upstream {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append(append([]options.Upstream{}, p.config.Upstreams...), p.dynamic...)
+}
+
+// MatchUpstream returns the upstream that would serve the request with the
+// current upstreams.
+func (p *dynamicProxy) MatchUpstream(req *http.Request) (options.Upstream, bool) {
+	return p.load().MatchUpstream(req)
+}
+
+// Reconcile replaces the upstreams added at runtime with those given.
+func (p *dynamicProxy) Reconcile(upstreams []options.Upstream) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.reconcile(upstreams)
+}
+
+// AddUpstream adds an upstream at runtime, replacing any upstream added at
+// runtime with the same ID.
+func (p *dynamicProxy) AddUpstream(upstream options.Upstream) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	dynamic := make([]options.Upstream, 0, len(p.dynamic)+1)
+	for _, u := range p.dynamic {
+		if u.ID != upstream.ID {
+			dynamic = append(dynamic, u)
+		}
+	}
+	return p.reconcile(append(dynamic, upstream))
+}
+
+// RemoveUpstream removes an upstream that was added at runtime.
+func (p *dynamicProxy) RemoveUpstream(id string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	dynamic := make([]options.Upstream, 0, len(p.dynamic))
+	for _, u := range p.dynamic {
+		if u.ID != id {
+			dynamic = append(dynamic, u)
+		}
+	}
+	if len(dynamic) == len(p.dynamic) {
+		return fmt.Errorf("no upstream with id %q was added at runtime", id)
+	}
+	return p.reconcile(dynamic)
+}
+
+// Close stops watching the dynamic upstreams file and stops the background
+// work of every upstream.
+func (p *dynamicProxy) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for _, h := range p.handlers {
+		h.stop()
+	}
+}
+
+func (p *dynamicProxy) load() *multiUpstreamProxy {
+	return p.current.Load().(*multiUpstreamProxy)
+}
+
+// reconcile builds the routes for the configured and dynamic upstreams and
+// replaces the current routes with them.
+// Handlers for upstreams that have not changed are reused so that their state
+// is kept. The mutex must be held by the caller.
+func (p *dynamicProxy) reconcile(dynamic []options.Upstream) error {
+	if p.closed {
+		return errors.New("proxy has been closed")
+	}
+	if len(dynamic) > 0 {
+		if err := validation.ValidateDynamicUpstreams(p.config, dynamic); err != nil {
+			return err
+		}
+	}
+
+	handlers := make(map[string]*upstreamHandler, len(p.config.Upstreams)+len(dynamic))
+	var built []*upstreamHandler
+	stopBuilt := func() {
+		for _, h := range built {
+			h.stop()
+		}
+	}
+
+	for _, upstream := range append(append([]options.Upstream{}, p.config.Upstreams...), dynamic...) {
+		if h, ok := p.handlers[upstream.ID]; ok && reflect.DeepEqual(h.upstream, upstream) {
+			handlers[upstream.ID] = h
+			continue
+		}
+
+		h, err := newUpstreamHandler(upstream, p.sigData, p.writer)
+		if err != nil {
+			stopBuilt()
+			return err
+		}
+		built = append(built, h)
+		handlers[upstream.ID] = h
+	}
+
+	m, err := newMultiUpstreamProxy(p.config.ProxyRawPath, handlers, p.writer)
+	if err != nil {
+		stopBuilt()
+		return err
+	}
+	p.current.Store(m)
+
+	// Requests in flight to removed upstreams complete, only their background
+	// work is stopped
+	for id, h := range p.handlers {
+		if handlers[id] != h {
+			logger.Printf("removing upstream %q from path %q", id, h.upstream.Path)
+			h.stop()
+		}
+	}
+	p.handlers = handlers
+	p.dynamic = dynamic
+	return nil
+}
+
+// watch reconciles the upstreams in the dynamic upstreams file whenever it
+// changes. If the file cannot be loaded, the current upstreams are kept.
+func (p *dynamicProxy) watch(path string) error {
+	return watcher.WatchFileForUpdates(path, p.done, func() {
+		upstreams, err := options.LoadDynamicUpstreams(path)
+		if err != nil {
+			logger.Errorf("Error updating dynamic upstreams: %v", err)
+			return
+		}
+		if err := p.Reconcile(upstreams); err != nil {
+			logger.Errorf("Error updating dynamic upstreams: %v", err)
+			return
+		}
+		logger.Printf("Updated dynamic upstreams from %s", path)
+	})
+}
+
+// stop stops the background work of the upstream.
+func (h *upstreamHandler) stop() {
+	if h.close != nil {
+		h.close()
+	}
+}
@@ -0,0 +1,119 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dynamic Upstreams Suite", func() {
+	accepted := http.StatusAccepted
+	created := http.StatusCreated
+
+	configured := options.Upstream{
+		ID:         "configured",
+		Path:       "/configured/",
+		Static:     true,
+		StaticCode: &accepted,
+	}
+	preview := options.Upstream{
+		ID:         "preview",
+		Path:       "/preview/",
+		Static:     true,
+		StaticCode: &created,
+	}
+
+	writer := &pagewriter.WriterFuncs{}
+
+	serve := func(proxy Proxy, path string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		proxy.ServeHTTP(rw, middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{}))
+		return rw.Code
+	}
+
+	var proxy Proxy
+
+	BeforeEach(func() {
+		var err error
+		proxy, err = NewProxy(options.UpstreamConfig{Upstreams: []options.Upstream{configured}}, nil, writer)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		proxy.Close()
+	})
+
+	It("adds and removes upstreams at runtime", func() {
+		Expect(serve(proxy, "/preview/")).To(Equal(http.StatusNotFound))
+
+		Expect(proxy.AddUpstream(preview)).To(Succeed())
+		Expect(serve(proxy, "/preview/")).To(Equal(http.StatusCreated))
+		Expect(serve(proxy, "/configured/")).To(Equal(http.StatusAccepted))
+		Expect(proxy.Upstreams()).To(Equal([]options.Upstream{configured, preview}))
+
+		Expect(proxy.RemoveUpstream("preview")).To(Succeed())
+		Expect(serve(proxy, "/preview/")).To(Equal(http.StatusNotFound))
+		Expect(proxy.Upstreams()).To(Equal([]options.Upstream{configured}))
+	})
+
+	It("keeps the handlers of unchanged upstreams", func() {
+		Expect(proxy.Reconcile([]options.Upstream{preview})).To(Succeed())
+		handler := proxy.(*dynamicProxy).handlers["preview"]
+
+		other := options.Upstream{ID: "other", Path: "/other/", Static: true}
+		Expect(proxy.Reconcile([]options.Upstream{preview, other})).To(Succeed())
+		Expect(proxy.(*dynamicProxy).handlers["preview"]).To(BeIdenticalTo(handler))
+		Expect(serve(proxy, "/other/")).To(Equal(http.StatusOK))
+	})
+
+	It("rejects upstreams that clash with the configured upstreams", func() {
+		clash := preview
+		clash.Path = configured.Path
+		Expect(proxy.AddUpstream(clash)).To(MatchError(ContainSubstring("upstream paths must be unique")))
+		Expect(serve(proxy, "/configured/")).To(Equal(http.StatusAccepted))
+	})
+
+	It("does not remove configured upstreams", func() {
+		Expect(proxy.RemoveUpstream("configured")).To(MatchError("no upstream with id \"configured\" was added at runtime"))
+	})
+
+	Context("with a dynamic upstreams file", func() {
+		var dir, path string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "dynamic-upstreams")
+			Expect(err).ToNot(HaveOccurred())
+			path = filepath.Join(dir, "upstreams.yaml")
+			Expect(ioutil.WriteFile(path, []byte("upstreams:\n- id: preview\n  path: /preview/\n  static: true\n  staticCode: 201\n"), 0600)).To(Succeed())
+
+			proxy.Close()
+			proxy, err = NewProxy(options.UpstreamConfig{
+				Upstreams:            []options.Upstream{configured},
+				DynamicUpstreamsFile: path,
+			}, nil, writer)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("serves the upstreams in the file and updates them when it changes", func() {
+			Expect(serve(proxy, "/preview/")).To(Equal(http.StatusCreated))
+
+			Expect(ioutil.WriteFile(path, []byte("upstreams:\n- id: other\n  path: /other/\n  static: true\n"), 0600)).To(Succeed())
+			Eventually(func() int { return serve(proxy, "/other/") }).Should(Equal(http.StatusOK))
+			Expect(serve(proxy, "/preview/")).To(Equal(http.StatusNotFound))
+		})
+	})
+})
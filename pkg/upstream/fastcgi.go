@@ -0,0 +1,414 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+const fastCGIScheme = "fcgi"
+
+// FastCGI protocol constants, see https://fastcgi-archives.github.io/FastCGI_Specification.html
+const (
+	fcgiVersion       = 1
+	fcgiBeginRequest  = 1
+	fcgiEndRequest    = 3
+	fcgiParams        = 4
+	fcgiStdin         = 5
+	fcgiStdout        = 6
+	fcgiStderr        = 7
+	fcgiResponder     = 1
+	fcgiRequestID     = 1
+	fcgiHeaderLen     = 8
+	fcgiMaxContentLen = 65535
+)
+
+// fastCGIBuffers is the pool of buffers the request bodies are split into
+// records with.
+var fastCGIBuffers = newBufferPool(fcgiMaxContentLen)
+
+// fastCGIHandler serves requests by forwarding them to a FastCGI responder.
+// A new connection is used for each request.
+type fastCGIHandler struct {
+	upstream     string
+	network      string
+	address      string
+	dial         dialFunc
+	timeout      time.Duration
+	root         string
+	index        string
+	splitPath    string
+	params       []options.FastCGIParam
+	errorHandler ProxyErrorHandler
+}
+
+// newFastCGIHandler creates a fastCGIHandler for the fcgi:// URL.
+// URLs with a host are connected to using TCP, otherwise the path is used as
+// a unix domain socket.
+func newFastCGIHandler(upstream options.Upstream, u *url.URL, errorHandler ProxyErrorHandler) http.Handler {
+	h := &fastCGIHandler{
+		upstream:     upstream.ID,
+		network:      "tcp",
+		address:      u.Host,
+		dial:         newUpstreamDialer(upstream, ""),
+		timeout:      options.DefaultUpstreamTimeout,
+		index:        options.DefaultFastCGIIndex,
+		splitPath:    options.DefaultFastCGISplitPath,
+		errorHandler: errorHandler,
+	}
+	if u.Host == "" {
+		h.network = unixScheme
+		h.address = u.Path
+	}
+	if upstream.Timeout != nil {
+		h.timeout = upstream.Timeout.Duration()
+	}
+	if opts := upstream.FastCGI; opts != nil {
+		h.root = opts.Root
+		h.params = opts.Params
+		if opts.Index != "" {
+			h.index = opts.Index
+		}
+		if opts.SplitPath != "" {
+			h.splitPath = opts.SplitPath
+		}
+	}
+	return h
+}
+
+// ServeHTTP forwards the request to the FastCGI server and writes its
+// response to the client.
+func (h *fastCGIHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := h.serve(rw, req); err != nil {
+		logger.Errorf("Error proxying to FastCGI upstream %q: %v", h.upstream, err)
+		h.errorHandler(rw, req, err)
+	}
+}
+
+// serve performs the FastCGI request.
+// An error is only returned if nothing has been written to the client.
+func (h *fastCGIHandler) serve(rw http.ResponseWriter, req *http.Request) error {
+	body, contentLength, err := fastCGIRequestBody(req)
+	if err != nil {
+		return fmt.Errorf("could not read request body: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), h.timeout)
+	defer cancel()
+
+	conn, err := h.dial(ctx, h.network, h.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// Abort the request if the client goes away
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	w := bufio.NewWriter(conn)
+	if err := writeFastCGIRecord(w, fcgiBeginRequest, []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	if err := writeFastCGIStream(w, fcgiParams, bytes.NewReader(encodeFastCGIParams(h.requestParams(req, contentLength)))); err != nil {
+		return err
+	}
+	if err := writeFastCGIStream(w, fcgiStdin, body); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(&fastCGIStdoutReader{upstream: h.upstream, r: bufio.NewReader(conn)})
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("could not read response headers: %v", err)
+	}
+
+	status := http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		code, err := strconv.Atoi(strings.SplitN(s, " ", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid status %q", s)
+		}
+		status = code
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+
+	for name, values := range header {
+		rw.Header()[name] = values
+	}
+	rw.WriteHeader(status)
+	if _, err := copyBuffer(rw, r); err != nil {
+		logger.Errorf("Error copying response from FastCGI upstream %q: %v", h.upstream, err)
+	}
+	return nil
+}
+
+// fastCGIRequestBody returns the request body and its length.
+// Bodies of unknown length are buffered as FastCGI requires CONTENT_LENGTH.
+func fastCGIRequestBody(req *http.Request) (io.Reader, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return bytes.NewReader(nil), 0, nil
+	}
+	if req.ContentLength >= 0 {
+		return io.LimitReader(req.Body, req.ContentLength), req.ContentLength, nil
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// requestParams builds the CGI parameters for the request.
+// Requests whose path does not contain the split path are handled by the
+// index script.
+func (h *fastCGIHandler) requestParams(req *http.Request, contentLength int64) [][2]string {
+	reqPath := req.URL.Path
+	scriptName, pathInfo := reqPath, ""
+	if i := strings.Index(reqPath, h.splitPath); i >= 0 {
+		scriptName, pathInfo = reqPath[:i+len(h.splitPath)], reqPath[i+len(h.splitPath):]
+	} else if strings.HasSuffix(reqPath, "/") {
+		scriptName = reqPath + h.index
+	} else {
+		scriptName = "/" + h.index
+	}
+	scriptName = path.Clean("/" + scriptName)
+
+	scheme := "http"
+	if req.TLS != nil || requestutil.GetRequestProto(req) == httpsScheme {
+		scheme = httpsScheme
+	}
+	serverName, serverPort, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		serverName, serverPort = req.Host, "80"
+		if scheme == httpsScheme {
+			serverPort = "443"
+		}
+	}
+	remoteAddr, remotePort, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteAddr = req.RemoteAddr
+	}
+
+	params := [][2]string{
+		{"GATEWAY_INTERFACE", "CGI/1.1"},
+		{"SERVER_SOFTWARE", "oauth2-proxy"},
+		{"SERVER_PROTOCOL", req.Proto},
+		{"SERVER_NAME", serverName},
+		{"SERVER_PORT", serverPort},
+		{"REQUEST_SCHEME", scheme},
+		{"REQUEST_METHOD", req.Method},
+		{"REQUEST_URI", req.URL.RequestURI()},
+		{"QUERY_STRING", req.URL.RawQuery},
+		{"DOCUMENT_ROOT", h.root},
+		{"DOCUMENT_URI", scriptName},
+		{"SCRIPT_NAME", scriptName},
+		{"SCRIPT_FILENAME", path.Join(h.root, scriptName)},
+		{"PATH_INFO", pathInfo},
+		{"REMOTE_ADDR", remoteAddr},
+		{"REMOTE_PORT", remotePort},
+		{"CONTENT_TYPE", req.Header.Get("Content-Type")},
+		{"CONTENT_LENGTH", strconv.FormatInt(contentLength, 10)},
+	}
+	if pathInfo != "" {
+		params = append(params, [2]string{"PATH_TRANSLATED", path.Join(h.root, pathInfo)})
+	}
+	if scheme == httpsScheme {
+		params = append(params, [2]string{"HTTPS", "on"})
+	}
+
+	for name, values := range req.Header {
+		// The Proxy header must not be passed to avoid httpoxy attacks
+		if name == "Proxy" || name == "Content-Type" || name == "Content-Length" {
+			continue
+		}
+		params = append(params, [2]string{"HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")), strings.Join(values, ", ")})
+	}
+	if req.Host != "" {
+		params = append(params, [2]string{"HTTP_HOST", req.Host})
+	}
+
+	for _, param := range h.params {
+		params = setFastCGIParam(params, param.Name, param.Value)
+	}
+	return params
+}
+
+// setFastCGIParam replaces the parameter with the given name or adds it if
+// it is not present.
+func setFastCGIParam(params [][2]string, name, value string) [][2]string {
+	for i := range params {
+		if params[i][0] == name {
+			params[i][1] = value
+			return params
+		}
+	}
+	return append(params, [2]string{name, value})
+}
+
+// encodeFastCGIParams encodes the parameters as FastCGI name-value pairs.
+func encodeFastCGIParams(params [][2]string) []byte {
+	buf := &bytes.Buffer{}
+	for _, param := range params {
+		writeFastCGIParamLength(buf, len(param[0]))
+		writeFastCGIParamLength(buf, len(param[1]))
+		buf.WriteString(param[0])
+		buf.WriteString(param[1])
+	}
+	return buf.Bytes()
+}
+
+// writeFastCGIParamLength writes the length of a name or value using one
+// byte for short lengths and four bytes otherwise.
+func writeFastCGIParamLength(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|1<<31)
+	buf.Write(b[:])
+}
+
+// writeFastCGIStream writes the data as a stream of records, followed by the
+// empty record that ends the stream.
+func writeFastCGIStream(w io.Writer, recordType byte, r io.Reader) error {
+	buf := fastCGIBuffers.Get()
+	defer fastCGIBuffers.Put(buf)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := writeFastCGIRecord(w, recordType, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return writeFastCGIRecord(w, recordType, nil)
+}
+
+// writeFastCGIRecord writes a single record, padded to a multiple of eight
+// bytes.
+func writeFastCGIRecord(w io.Writer, recordType byte, content []byte) error {
+	padding := -len(content) & 7
+	header := [fcgiHeaderLen]byte{fcgiVersion, recordType}
+	binary.BigEndian.PutUint16(header[2:], fcgiRequestID)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(content)))
+	header[6] = byte(padding)
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	_, err := w.Write(make([]byte, padding))
+	return err
+}
+
+// fastCGIStdoutReader reads the stdout stream of a FastCGI response.
+// Data written to stderr is logged and the stream ends when the request
+// ends.
+type fastCGIStdoutReader struct {
+	upstream  string
+	r         *bufio.Reader
+	remaining int
+	padding   int
+	done      bool
+}
+
+// Read reads stdout data from the FastCGI records.
+func (s *fastCGIStdoutReader) Read(p []byte) (int, error) {
+	for s.remaining == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.nextRecord(); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(p) > s.remaining {
+		p = p[:s.remaining]
+	}
+	n, err := s.r.Read(p)
+	s.remaining -= n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextRecord reads records until a stdout record with content is found or
+// the request ends.
+func (s *fastCGIStdoutReader) nextRecord() error {
+	if _, err := s.r.Discard(s.padding); err != nil {
+		return err
+	}
+	s.padding = 0
+
+	var header [fcgiHeaderLen]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	padding := int(header[6])
+
+	switch header[1] {
+	case fcgiStdout:
+		s.remaining, s.padding = length, padding
+		return nil
+	case fcgiStderr:
+		data := make([]byte, length)
+		if _, err := io.ReadFull(s.r, data); err != nil {
+			return err
+		}
+		if msg := strings.TrimSpace(string(data)); msg != "" {
+			logger.Errorf("FastCGI upstream %q: %s", s.upstream, msg)
+		}
+	case fcgiEndRequest:
+		s.done = true
+	}
+
+	if header[1] != fcgiStderr {
+		if _, err := s.r.Discard(length); err != nil {
+			return err
+		}
+	}
+	_, err := s.r.Discard(padding)
+	return err
+}
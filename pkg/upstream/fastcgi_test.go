@@ -0,0 +1,95 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FastCGI Suite", func() {
+	var listener net.Listener
+	var handler http.Handler
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		go func() {
+			_ = fcgi.Serve(listener, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				env := fcgi.ProcessEnv(req)
+				body, _ := ioutil.ReadAll(req.Body)
+
+				rw.Header().Set("X-Script-Filename", env["SCRIPT_FILENAME"])
+				rw.Header().Set("X-Custom", env["CUSTOM"])
+				rw.Header().Set("X-Forwarded-User", req.Header.Get("X-Forwarded-User"))
+				rw.WriteHeader(http.StatusCreated)
+				rw.Write([]byte(req.Method + " " + req.URL.RequestURI() + " " + string(body)))
+			}))
+		}()
+
+		u, err := url.Parse("fcgi://" + listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		handler = newFastCGIHandler(options.Upstream{
+			ID: "php",
+			FastCGI: &options.FastCGI{
+				Root:   "/var/www/html",
+				Params: []options.FastCGIParam{{Name: "CUSTOM", Value: "value"}},
+			},
+		}, u, func(rw http.ResponseWriter, _ *http.Request, err error) {
+			rw.WriteHeader(http.StatusBadGateway)
+		})
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("proxies requests to the FastCGI server", func() {
+		req := httptest.NewRequest("POST", "/app/index.php/users?page=2", strings.NewReader("name=john"))
+		req.Header.Set("X-Forwarded-User", "john")
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		Expect(rw.Code).To(Equal(http.StatusCreated))
+		Expect(rw.Header().Get("X-Script-Filename")).To(Equal("/var/www/html/app/index.php"))
+		Expect(rw.Header().Get("X-Custom")).To(Equal("value"))
+		Expect(rw.Header().Get("X-Forwarded-User")).To(Equal("john"))
+		Expect(rw.Body.String()).To(Equal("POST /app/index.php/users?page=2 name=john"))
+	})
+
+	It("returns an error when the FastCGI server is unavailable", func() {
+		listener.Close()
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+		Expect(rw.Code).To(Equal(http.StatusBadGateway))
+	})
+
+	DescribeTable("requestParams maps the script name",
+		func(path, scriptName, pathInfo string) {
+			h := newFastCGIHandler(options.Upstream{FastCGI: &options.FastCGI{Root: "/srv"}}, &url.URL{Host: "localhost:9000"}, nil).(*fastCGIHandler)
+			params := map[string]string{}
+			for _, param := range h.requestParams(httptest.NewRequest("GET", path, nil), 0) {
+				params[param[0]] = param[1]
+			}
+
+			Expect(params["SCRIPT_NAME"]).To(Equal(scriptName))
+			Expect(params["SCRIPT_FILENAME"]).To(Equal("/srv" + scriptName))
+			Expect(params["PATH_INFO"]).To(Equal(pathInfo))
+		},
+		Entry("with a script", "/info.php", "/info.php", ""),
+		Entry("with path info", "/info.php/extra", "/info.php", "/extra"),
+		Entry("with a directory", "/admin/", "/admin/index.php", ""),
+		Entry("with a front controller route", "/users/1", "/index.php", ""),
+	)
+})
@@ -2,32 +2,107 @@ package upstream
 
 import (
 	"net/http"
+	"os"
 	"runtime"
 	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 )
 
-const fileScheme = "file"
+const (
+	fileScheme = "file"
+
+	// defaultIndexFile is the index file served by http.FileServer.
+	defaultIndexFile = "index.html"
+)
 
 // newFileServer creates a new fileServer that can serve requests
 // to a file system location.
-func newFileServer(id, path, fileSystemPath string) http.Handler {
+func newFileServer(id, path, fileSystemPath string, opts *options.FileServer) http.Handler {
 	return &fileServer{
 		upstream: id,
-		handler:  newFileServerForPath(path, fileSystemPath),
+		handler:  newFileServerForPath(path, fileSystemPath, opts),
 	}
 }
 
 // newFileServerForPath creates a http.Handler to serve files from the filesystem
-func newFileServerForPath(path string, filesystemPath string) http.Handler {
+func newFileServerForPath(path string, filesystemPath string, opts *options.FileServer) http.Handler {
 	// Windows fileSSystemPath will be be prefixed with `/`, eg`/C:/...,
 	// if they were parsed by url.Parse`
 	if runtime.GOOS == "windows" {
 		filesystemPath = strings.TrimPrefix(filesystemPath, "/")
 	}
 
-	return http.StripPrefix(path, http.FileServer(http.Dir(filesystemPath)))
+	fs := &indexFileSystem{
+		FileSystem:       http.Dir(filesystemPath),
+		indexFiles:       []string{defaultIndexFile},
+		directoryListing: true,
+	}
+	if opts != nil {
+		if len(opts.IndexFiles) > 0 {
+			fs.indexFiles = opts.IndexFiles
+		}
+		if opts.DirectoryListing != nil {
+			fs.directoryListing = *opts.DirectoryListing
+		}
+	}
+
+	return http.StripPrefix(path, http.FileServer(fs))
+}
+
+// indexFileSystem serves the configured index files in place of the
+// index.html that http.FileServer looks for, and hides directories without an
+// index file when directory listings are disabled.
+type indexFileSystem struct {
+	http.FileSystem
+	indexFiles       []string
+	directoryListing bool
+}
+
+// Open opens the named file, or the first index file that exists when
+// http.FileServer looks for the directory index.
+func (f *indexFileSystem) Open(name string) (http.File, error) {
+	if strings.HasSuffix(name, "/"+defaultIndexFile) {
+		return f.openIndex(strings.TrimSuffix(name, defaultIndexFile))
+	}
+
+	file, err := f.FileSystem.Open(name)
+	if err != nil || f.directoryListing {
+		return file, err
+	}
+
+	// Directories may only be served when they contain an index file
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if stat.IsDir() {
+		index, err := f.openIndex(strings.TrimSuffix(name, "/") + "/")
+		if err != nil {
+			file.Close()
+			return nil, os.ErrPermission
+		}
+		index.Close()
+	}
+	return file, nil
+}
+
+// openIndex opens the first index file that exists within the directory.
+func (f *indexFileSystem) openIndex(dir string) (http.File, error) {
+	for _, index := range f.indexFiles {
+		file, err := f.FileSystem.Open(dir + index)
+		if err != nil {
+			continue
+		}
+		if stat, err := file.Stat(); err != nil || stat.IsDir() {
+			file.Close()
+			continue
+		}
+		return file, nil
+	}
+	return nil, os.ErrNotExist
 }
 
 // fileServer represents a single filesystem upstream proxy
@@ -3,11 +3,14 @@ package upstream
 import (
 	"crypto/rand"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -33,7 +36,7 @@ var _ = Describe("File Server Suite", func() {
 		Expect(err).ToNot(HaveOccurred())
 		id = string(idBytes)
 
-		handler = newFileServer(id, "/files", filesDir)
+		handler = newFileServer(id, "/files", filesDir, nil)
 	})
 
 	AfterEach(func() {
@@ -60,4 +63,46 @@ var _ = Describe("File Server Suite", func() {
 		Entry("for a non-existent file inside the path", "/files/baz", 404, pageNotFound),
 		Entry("for a non-existent file oustide the path", "/baz", 404, pageNotFound),
 	)
+
+	Context("with directory options", func() {
+		var siteDir string
+
+		BeforeEach(func() {
+			var err error
+			siteDir, err = ioutil.TempDir("", "oauth2-proxy-file-server")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(path.Join(siteDir, "home.html"), []byte("home"), 0644)).To(Succeed())
+			Expect(os.Mkdir(path.Join(siteDir, "docs"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(siteDir, "docs", "index.html"), []byte("docs"), 0644)).To(Succeed())
+			Expect(os.Mkdir(path.Join(siteDir, "artifacts"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(siteDir, "artifacts", "build.tar"), []byte("build"), 0644)).To(Succeed())
+
+			directoryListing := false
+			handler = newFileServer(id, "/site", siteDir, &options.FileServer{
+				DirectoryListing: &directoryListing,
+				IndexFiles:       []string{"home.html", "index.html"},
+			})
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(siteDir)).To(Succeed())
+		})
+
+		DescribeTable("fileServer ServeHTTP",
+			func(requestPath string, expectedResponseCode int, expectedBody string) {
+				req := httptest.NewRequest("", requestPath, nil)
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, req)
+
+				Expect(rw.Code).To(Equal(expectedResponseCode))
+				Expect(rw.Body.String()).To(Equal(expectedBody))
+			},
+			Entry("for the root directory with an index file", "/site/", 200, "home"),
+			Entry("for a directory with a fallback index file", "/site/docs/", 200, "docs"),
+			Entry("for a directory without an index file", "/site/artifacts/", 403, "403 Forbidden\n"),
+			Entry("for a file in a directory without an index file", "/site/artifacts/build.tar", 200, "build"),
+		)
+	})
 })
@@ -0,0 +1,42 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// newGRPCReverseProxy creates a new reverse proxy for proxying gRPC requests
+// to upstream servers.
+// gRPC requires HTTP/2, so requests are always sent to the upstream using
+// HTTP/2.
+// The identity headers injected into the request are sent as HTTP/2 headers,
+// which gRPC servers read as metadata, so they need no mapping of their own.
+func newGRPCReverseProxy(target *url.URL, upstream options.Upstream, tlsConfig *tls.Config, dial dialFunc, errorHandler ProxyErrorHandler) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	transport := newHTTP2Transport(target, tlsConfig, dial)
+
+	// gRPC messages must be streamed to the client as soon as they arrive
+	proxy.FlushInterval = -1
+	proxy.BufferPool = copyBuffers
+
+	// Ensure we always pass the original request path
+	setProxyDirector(proxy)
+
+	if upstream.PassHostHeader != nil && !*upstream.PassHostHeader {
+		setProxyUpstreamHostHeader(proxy, target)
+	}
+
+	// Failures are converted to a gRPC status by the gRPC middleware
+	if errorHandler != nil {
+		proxy.ErrorHandler = errorHandler
+	}
+
+	proxy.Transport = transport
+
+	return proxy
+}
@@ -0,0 +1,67 @@
+package upstream
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ = Describe("gRPC Upstream Suite", func() {
+	var grpcServer *httptest.Server
+
+	BeforeEach(func() {
+		// A minimal h2c gRPC server that echoes the request message and
+		// identity metadata back to the client.
+		grpcServer = httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.ProtoMajor).To(Equal(2))
+
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			rw.Header().Set("Content-Type", "application/grpc")
+			rw.Header().Set("Trailer", "Grpc-Status")
+			rw.Header().Set("X-Forwarded-User", req.Header.Get("X-Forwarded-User"))
+			rw.WriteHeader(http.StatusOK)
+			rw.Write(body)
+			rw.Header().Set("Grpc-Status", "0")
+		}), &http2.Server{}))
+	})
+
+	AfterEach(func() {
+		grpcServer.Close()
+	})
+
+	It("proxies gRPC requests to an h2c upstream", func() {
+		u, err := url.Parse(grpcServer.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		handler, err := newHTTPUpstreamProxy(options.Upstream{ID: "grpc"}, u, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		message := []byte{0, 0, 0, 0, 2, 8, 1}
+		req := httptest.NewRequest("POST", "/service.Foo/Bar", bytes.NewReader(message))
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("X-Forwarded-User", "john")
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		resp := rw.Result()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/grpc"))
+		Expect(resp.Header.Get("X-Forwarded-User")).To(Equal("john"))
+		Expect(rw.Body.Bytes()).To(Equal(message))
+		Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("0"))
+	})
+})
@@ -0,0 +1,175 @@
+package upstream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// newRequestHeaderPolicy creates a new middleware that will modify the
+// request headers before handing the request to the next server.
+func newRequestHeaderPolicy(policy *options.RequestHeaderPolicy) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			applyRequestHeaderPolicy(policy, req.Header)
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// applyRequestHeaderPolicy removes, renames and then sets the headers.
+func applyRequestHeaderPolicy(policy *options.RequestHeaderPolicy, header http.Header) {
+	for _, name := range policy.Remove {
+		header.Del(name)
+	}
+
+	for _, rename := range policy.Rename {
+		values := header.Values(rename.From)
+		if len(values) == 0 {
+			continue
+		}
+		header.Del(rename.From)
+		header.Del(rename.To)
+		for _, value := range values {
+			header.Add(rename.To, value)
+		}
+	}
+
+	for _, h := range policy.Set {
+		header.Set(h.Name, h.Value)
+	}
+}
+
+// newResponseHeaderPolicy creates a new middleware that will modify the
+// response headers before they are written to the client.
+func newResponseHeaderPolicy(policy *options.ResponseHeaderPolicy) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(&headerPolicyResponseWriter{
+				ResponseWriter: rw,
+				policy:         policy,
+			}, req)
+		})
+	}
+}
+
+// applyResponseHeaderPolicy removes, sets and then defaults the headers.
+func applyResponseHeaderPolicy(policy *options.ResponseHeaderPolicy, header http.Header) {
+	for _, name := range policy.Remove {
+		header.Del(name)
+	}
+
+	for _, h := range policy.Set {
+		header.Set(h.Name, h.Value)
+	}
+
+	for _, h := range policy.Default {
+		if len(header.Values(h.Name)) == 0 {
+			header.Set(h.Name, h.Value)
+		}
+	}
+}
+
+// headerPolicyResponseWriter applies the response header policy when the
+// response headers are written.
+type headerPolicyResponseWriter struct {
+	http.ResponseWriter
+	policy      *options.ResponseHeaderPolicy
+	wroteHeader bool
+}
+
+// WriteHeader applies the header policy and writes the response headers.
+func (w *headerPolicyResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		applyResponseHeaderPolicy(w.policy, w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response headers if they have not been written and then
+// writes the data to the client.
+func (w *headerPolicyResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom writes the response headers if they have not been written and then
+// copies the data to the client, using the ReadFrom of the wrapped writer when
+// it has one.
+func (w *headerPolicyResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return copyBuffer(w.ResponseWriter, r)
+}
+
+// Flush sends any buffered data to the client.
+func (w *headerPolicyResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows WebSocket connections to take over the connection.
+func (w *headerPolicyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
+
+// newHeaderEncryption creates a new middleware that will encrypt the values
+// of the headers as compact JWEs before handing the request to the next
+// server.
+// Values that cannot be encrypted are removed rather than sent in plain text.
+func newHeaderEncryption(upstream string, opts *options.HeaderEncryption) (alice.Constructor, error) {
+	encrypter, err := encryption.LoadJWEEncrypter(opts.PublicKeyFile, opts.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load header encryption key for upstream %q: %v", upstream, err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			encryptHeaders(upstream, encrypter, opts.Headers, req.Header)
+			next.ServeHTTP(rw, req)
+		})
+	}, nil
+}
+
+// encryptHeaders replaces each value of the headers with its compact JWE.
+func encryptHeaders(upstream string, encrypter jose.Encrypter, names []string, header http.Header) {
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		header.Del(name)
+		for _, value := range values {
+			jwe, err := encrypter.Encrypt([]byte(value))
+			if err == nil {
+				var serialized string
+				serialized, err = jwe.CompactSerialize()
+				if err == nil {
+					header.Add(name, serialized)
+					continue
+				}
+			}
+			logger.Errorf("Error encrypting header %q for upstream %q: %v", name, upstream, err)
+		}
+	}
+}
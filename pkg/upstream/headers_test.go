@@ -0,0 +1,237 @@
+package upstream
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2"
+)
+
+var _ = Describe("Header Policy Suite", func() {
+	type requestHeaderPolicyTableInput struct {
+		policy          *options.RequestHeaderPolicy
+		requestHeaders  http.Header
+		expectedHeaders http.Header
+	}
+
+	DescribeTable("newRequestHeaderPolicy",
+		func(in requestHeaderPolicyTableInput) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header = in.requestHeaders
+
+			var gotHeaders http.Header
+			handler := newRequestHeaderPolicy(in.policy)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				gotHeaders = req.Header
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(gotHeaders).To(Equal(in.expectedHeaders))
+		},
+		Entry("with headers to remove", requestHeaderPolicyTableInput{
+			policy: &options.RequestHeaderPolicy{
+				Remove: []string{"x-forwarded-user", "X-Missing"},
+			},
+			requestHeaders: http.Header{
+				"X-Forwarded-User": []string{"spoofed"},
+				"Accept":           []string{"text/html"},
+			},
+			expectedHeaders: http.Header{
+				"Accept": []string{"text/html"},
+			},
+		}),
+		Entry("with headers to rename", requestHeaderPolicyTableInput{
+			policy: &options.RequestHeaderPolicy{
+				Rename: []options.HeaderRename{
+					{From: "X-Forwarded-Email", To: "X-Remote-User"},
+					{From: "X-Missing", To: "X-Other"},
+				},
+			},
+			requestHeaders: http.Header{
+				"X-Forwarded-Email": []string{"john@example.com"},
+				"X-Remote-User":     []string{"spoofed"},
+			},
+			expectedHeaders: http.Header{
+				"X-Remote-User": []string{"john@example.com"},
+			},
+		}),
+		Entry("with headers to set", requestHeaderPolicyTableInput{
+			policy: &options.RequestHeaderPolicy{
+				Set: []options.StaticHeader{
+					{Name: "X-Backend", Value: "reports"},
+				},
+			},
+			requestHeaders: http.Header{
+				"X-Backend": []string{"spoofed", "values"},
+			},
+			expectedHeaders: http.Header{
+				"X-Backend": []string{"reports"},
+			},
+		}),
+		Entry("with headers removed before being renamed and set", requestHeaderPolicyTableInput{
+			policy: &options.RequestHeaderPolicy{
+				Remove: []string{"X-User"},
+				Rename: []options.HeaderRename{{From: "X-User", To: "X-Renamed"}},
+				Set:    []options.StaticHeader{{Name: "X-Renamed", Value: "static"}},
+			},
+			requestHeaders: http.Header{
+				"X-User": []string{"john"},
+			},
+			expectedHeaders: http.Header{
+				"X-Renamed": []string{"static"},
+			},
+		}),
+	)
+
+	type responseHeaderPolicyTableInput struct {
+		policy           *options.ResponseHeaderPolicy
+		upstreamHeaders  http.Header
+		expectedHeaders  http.Header
+		writeHeaderFirst bool
+	}
+
+	DescribeTable("newResponseHeaderPolicy",
+		func(in responseHeaderPolicyTableInput) {
+			handler := newResponseHeaderPolicy(in.policy)(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				for key, values := range in.upstreamHeaders {
+					rw.Header()[key] = values
+				}
+				if in.writeHeaderFirst {
+					rw.WriteHeader(http.StatusOK)
+				}
+				rw.Write([]byte("body"))
+			}))
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Body.String()).To(Equal("body"))
+			Expect(rw.Header()).To(Equal(in.expectedHeaders))
+		},
+		Entry("with headers to remove", responseHeaderPolicyTableInput{
+			policy: &options.ResponseHeaderPolicy{
+				Remove: []string{"Server", "X-Powered-By"},
+			},
+			upstreamHeaders: http.Header{
+				"Content-Type": []string{"text/plain"},
+				"Server":       []string{"legacy/1.0"},
+			},
+			expectedHeaders: http.Header{
+				"Content-Type": []string{"text/plain"},
+			},
+		}),
+		Entry("with headers to set", responseHeaderPolicyTableInput{
+			policy: &options.ResponseHeaderPolicy{
+				Set: []options.StaticHeader{
+					{Name: "Strict-Transport-Security", Value: "max-age=31536000"},
+					{Name: "X-Frame-Options", Value: "DENY"},
+				},
+			},
+			upstreamHeaders: http.Header{
+				"Content-Type":    []string{"text/plain"},
+				"X-Frame-Options": []string{"ALLOWALL"},
+			},
+			expectedHeaders: http.Header{
+				"Content-Type":              []string{"text/plain"},
+				"Strict-Transport-Security": []string{"max-age=31536000"},
+				"X-Frame-Options":           []string{"DENY"},
+			},
+			writeHeaderFirst: true,
+		}),
+		Entry("with default headers", responseHeaderPolicyTableInput{
+			policy: &options.ResponseHeaderPolicy{
+				Default: []options.StaticHeader{
+					{Name: "Cache-Control", Value: "no-store"},
+					{Name: "X-Content-Type-Options", Value: "nosniff"},
+				},
+			},
+			upstreamHeaders: http.Header{
+				"Content-Type":  []string{"text/plain"},
+				"Cache-Control": []string{"max-age=60"},
+			},
+			expectedHeaders: http.Header{
+				"Content-Type":           []string{"text/plain"},
+				"Cache-Control":          []string{"max-age=60"},
+				"X-Content-Type-Options": []string{"nosniff"},
+			},
+		}),
+	)
+
+	Context("newHeaderEncryption", func() {
+		var key *ecdsa.PrivateKey
+		var dir, keyFile string
+
+		BeforeEach(func() {
+			var err error
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			der, err := x509.MarshalPKIXPublicKey(key.Public())
+			Expect(err).ToNot(HaveOccurred())
+
+			dir, err = ioutil.TempDir("", "oauth2-proxy-header-encryption")
+			Expect(err).ToNot(HaveOccurred())
+			keyFile = filepath.Join(dir, "key.pem")
+			Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("encrypts each value of the headers for the upstream", func() {
+			encrypt, err := newHeaderEncryption("foo", &options.HeaderEncryption{
+				Headers:       []string{"X-Forwarded-Access-Token", "X-Missing"},
+				PublicKeyFile: keyFile,
+				KeyID:         "foo-1",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Add("X-Forwarded-Access-Token", "token-1")
+			req.Header.Add("X-Forwarded-Access-Token", "token-2")
+			req.Header.Set("X-Forwarded-User", "john")
+
+			var gotHeaders http.Header
+			encrypt(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				gotHeaders = req.Header
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(gotHeaders.Get("X-Forwarded-User")).To(Equal("john"))
+			Expect(gotHeaders).ToNot(HaveKey("X-Missing"))
+
+			values := gotHeaders.Values("X-Forwarded-Access-Token")
+			Expect(values).To(HaveLen(2))
+			for i, value := range values {
+				jwe, err := jose.ParseEncrypted(value)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(jwe.Header.KeyID).To(Equal("foo-1"))
+				Expect(jwe.Header.Algorithm).To(Equal(string(jose.ECDH_ES_A256KW)))
+
+				decrypted, err := jwe.Decrypt(key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(decrypted)).To(Equal(fmt.Sprintf("token-%d", i+1)))
+			}
+		})
+
+		It("fails when the public key cannot be loaded", func() {
+			_, err := newHeaderEncryption("foo", &options.HeaderEncryption{
+				Headers:       []string{"X-Forwarded-Access-Token"},
+				PublicKeyFile: "/dev/null",
+			})
+			Expect(err).To(MatchError("could not load header encryption key for upstream \"foo\": no PEM encoded public key found"))
+		})
+	})
+})
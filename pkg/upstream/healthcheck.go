@@ -0,0 +1,185 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errUnhealthy is returned when a request is made to an unhealthy upstream.
+var errUnhealthy = errors.New("upstream server is unhealthy")
+
+// healthChecker periodically probes a single upstream server and tracks
+// whether it is healthy.
+type healthChecker struct {
+	upstream string
+	host     string
+	url      string
+	client   *http.Client
+	interval time.Duration
+	gauge    prometheus.Gauge
+
+	healthyThreshold   int
+	unhealthyThreshold int
+
+	mutex     sync.RWMutex
+	isHealthy bool
+	successes int
+	failures  int
+
+	stop chan struct{}
+}
+
+// newHealthChecker creates a healthChecker for the target server.
+// Servers are considered healthy until the health check first fails.
+func newHealthChecker(upstreamID string, target *url.URL, opts *options.HealthCheck, transport http.RoundTripper) *healthChecker {
+	checkURL := *target
+	checkURL.Path = opts.Path
+
+	c := &healthChecker{
+		upstream:           upstreamID,
+		host:               target.Host,
+		url:                checkURL.String(),
+		client:             &http.Client{Transport: transport, Timeout: options.DefaultHealthCheckTimeout},
+		interval:           options.DefaultHealthCheckInterval,
+		gauge:              registerUpstreamHealthyGauge(prometheus.DefaultRegisterer).WithLabelValues(upstreamID, target.Host),
+		healthyThreshold:   opts.HealthyThreshold,
+		unhealthyThreshold: opts.UnhealthyThreshold,
+		isHealthy:          true,
+		stop:               make(chan struct{}),
+	}
+	if opts.Interval != nil {
+		c.interval = opts.Interval.Duration()
+	}
+	if opts.Timeout != nil {
+		c.client.Timeout = opts.Timeout.Duration()
+	}
+	if c.healthyThreshold <= 0 {
+		c.healthyThreshold = options.DefaultHealthCheckHealthyThreshold
+	}
+	if c.unhealthyThreshold <= 0 {
+		c.unhealthyThreshold = options.DefaultHealthCheckUnhealthyThreshold
+	}
+	c.gauge.Set(1)
+
+	return c
+}
+
+// start runs the health checks in the background until close is called.
+func (c *healthChecker) start() {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.record(c.check())
+
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// close stops the background health checks.
+func (c *healthChecker) close() {
+	close(c.stop)
+}
+
+// check performs a single health check against the server.
+func (c *healthChecker) check() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// record updates the health of the server based on the result of a check.
+func (c *healthChecker) record(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err == nil {
+		c.failures = 0
+		c.successes++
+		if !c.isHealthy && c.successes >= c.healthyThreshold {
+			logger.Printf("Upstream %q server %q is healthy", c.upstream, c.host)
+			c.isHealthy = true
+			c.gauge.Set(1)
+		}
+		return
+	}
+
+	c.successes = 0
+	c.failures++
+	if c.isHealthy && c.failures >= c.unhealthyThreshold {
+		logger.Errorf("Upstream %q server %q is unhealthy: %v", c.upstream, c.host, err)
+		c.isHealthy = false
+		c.gauge.Set(0)
+	}
+}
+
+// healthy reports whether the server is currently healthy.
+func (c *healthChecker) healthy() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.isHealthy
+}
+
+// registerUpstreamHealthyGauge registers the 'oauth2_proxy_upstream_healthy'
+// metric.
+// This reports whether each upstream server is passing its health checks.
+func registerUpstreamHealthyGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oauth2_proxy_upstream_healthy",
+			Help: "Whether the upstream server is passing health checks (1) or not (0).",
+		},
+		[]string{"upstream", "host"},
+	)
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
@@ -0,0 +1,94 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health Check Suite", func() {
+	var server *httptest.Server
+	var status int
+	var checker *healthChecker
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/healthz" {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			rw.WriteHeader(status)
+		}))
+
+		u, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		checker = newHealthChecker("foo", u, &options.HealthCheck{
+			Path:               "/healthz",
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 2,
+		}, http.DefaultTransport)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("checks the health check path", func() {
+		Expect(checker.check()).To(Succeed())
+
+		status = http.StatusServiceUnavailable
+		Expect(checker.check()).To(MatchError("unexpected status code 503"))
+	})
+
+	It("changes health after reaching the thresholds", func() {
+		Expect(checker.healthy()).To(BeTrue())
+
+		checker.record(errors.New("failed"))
+		Expect(checker.healthy()).To(BeTrue())
+		checker.record(errors.New("failed"))
+		Expect(checker.healthy()).To(BeFalse())
+
+		checker.record(nil)
+		Expect(checker.healthy()).To(BeFalse())
+		checker.record(nil)
+		Expect(checker.healthy()).To(BeTrue())
+	})
+
+	It("fails requests to unhealthy upstreams", func() {
+		proxy := &httpUpstreamProxy{
+			upstream: "foo",
+			handler:  http.NotFoundHandler(),
+			health:   checker,
+		}
+		checker.record(errors.New("failed"))
+		checker.record(errors.New("failed"))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusBadGateway))
+	})
+
+	It("reports upstreams with no healthy servers", func() {
+		m := &multiUpstreamProxy{
+			healthChecks: []upstreamHealthChecks{{
+				upstream: "foo",
+				checkers: func() []*healthChecker { return []*healthChecker{checker} },
+			}},
+		}
+		Expect(m.VerifyConnection(context.Background())).To(Succeed())
+
+		checker.record(errors.New("failed"))
+		checker.record(errors.New("failed"))
+		Expect(m.VerifyConnection(context.Background())).To(MatchError("upstream \"foo\" has no healthy endpoints"))
+	})
+})
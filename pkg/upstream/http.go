@@ -1,14 +1,19 @@
 package upstream
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
 
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/spiffe"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 )
 
 const (
@@ -38,17 +43,46 @@ var SignatureHeaders = []string{
 
 // newHTTPUpstreamProxy creates a new httpUpstreamProxy that can serve requests
 // to a single upstream host.
-func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, errorHandler ProxyErrorHandler) http.Handler {
+// Unix domain socket URIs are proxied to using HTTP over the socket.
+func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, errorHandler ProxyErrorHandler) (http.Handler, error) {
+	var socketPath string
+	if u.Scheme == unixScheme {
+		u, socketPath = unixSocketTarget(u)
+	}
+	dial := newUpstreamDialer(upstream, socketPath)
+
 	// Set path to empty so that request paths start at the server root
 	u.Path = ""
 
+	tlsConfig, err := newUpstreamTLSConfig(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	var outlier *outlierDetector
+	if upstream.OutlierDetection != nil {
+		outlier = newOutlierDetector(upstream.OutlierDetection)
+	}
+
 	// Create a ReverseProxy
-	proxy := newReverseProxy(u, upstream, errorHandler)
+	proxy := newReverseProxy(u, upstream, tlsConfig, dial, outlier, errorHandler)
+
+	// gRPC requests must be proxied using HTTP/2
+	grpcProxy := newGRPCReverseProxy(u, upstream, tlsConfig, dial, errorHandler)
 
 	// Set up a WebSocket proxy if required
 	var wsProxy http.Handler
 	if upstream.ProxyWebSockets == nil || *upstream.ProxyWebSockets {
-		wsProxy = newWebSocketReverseProxy(u, upstream.InsecureSkipTLSVerify)
+		wsProxy = newWebSocketReverseProxy(u, tlsConfig, dial)
+	}
+
+	var impersonator *kubernetesImpersonator
+	if upstream.KubernetesImpersonation != nil {
+		var err error
+		impersonator, err = newKubernetesImpersonator(upstream.KubernetesImpersonation)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var auth hmacauth.HmacAuth
@@ -56,20 +90,44 @@ func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *option
 		auth = hmacauth.NewHmacAuth(sigData.Hash, []byte(sigData.Key), SignatureHeader, SignatureHeaders)
 	}
 
-	return &httpUpstreamProxy{
-		upstream:  upstream.ID,
-		handler:   proxy,
-		wsHandler: wsProxy,
-		auth:      auth,
+	if upstream.Streaming != nil {
+		proxy = newStreamingHandler(upstream.Streaming, proxy)
+	}
+
+	var health *healthChecker
+	if upstream.HealthCheck != nil {
+		var transport http.RoundTripper = newHealthCheckTransport(tlsConfig, dial)
+		if upstream.HTTP2 {
+			transport = newHTTP2Transport(u, tlsConfig, dial)
+		}
+		health = newHealthChecker(upstream.ID, u, upstream.HealthCheck, transport)
+		health.start()
 	}
+
+	return &httpUpstreamProxy{
+		upstream:     upstream.ID,
+		handler:      proxy,
+		grpcHandler:  grpcProxy,
+		wsHandler:    wsProxy,
+		auth:         auth,
+		impersonator: impersonator,
+		outlier:      outlier,
+		health:       health,
+		errorHandler: errorHandler,
+	}, nil
 }
 
 // httpUpstreamProxy represents a single HTTP(S) upstream proxy
 type httpUpstreamProxy struct {
-	upstream  string
-	handler   http.Handler
-	wsHandler http.Handler
-	auth      hmacauth.HmacAuth
+	upstream     string
+	handler      http.Handler
+	grpcHandler  http.Handler
+	wsHandler    http.Handler
+	auth         hmacauth.HmacAuth
+	impersonator *kubernetesImpersonator
+	outlier      *outlierDetector
+	health       *healthChecker
+	errorHandler ProxyErrorHandler
 }
 
 // ServeHTTP proxies requests to the upstream provider while signing the
@@ -80,23 +138,48 @@ func (h *httpUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 	// A scope should always be injected before this handler is called.
 	scope.Upstream = h.upstream
 
+	if h.health != nil && !h.health.healthy() {
+		h.serveError(rw, req, errUnhealthy)
+		return
+	}
+
+	if h.impersonator != nil {
+		if err := h.impersonator.impersonate(req, scope.Session); err != nil {
+			logger.Errorf("Error impersonating user for upstream %q: %v", h.upstream, err)
+			h.serveError(rw, req, err)
+			return
+		}
+	}
+
 	// TODO (@NickMeves) - Deprecate GAP-Signature & remove GAP-Auth
 	if h.auth != nil {
 		req.Header.Set("GAP-Auth", rw.Header().Get("GAP-Auth"))
 		h.auth.SignRequest(req)
 	}
-	if h.wsHandler != nil && strings.EqualFold(req.Header.Get("Connection"), "upgrade") && req.Header.Get("Upgrade") == "websocket" {
+	switch {
+	case h.grpcHandler != nil && requestutil.IsGRPCRequest(req):
+		h.grpcHandler.ServeHTTP(rw, req)
+	case h.wsHandler != nil && requestutil.IsWebSocketRequest(req):
 		h.wsHandler.ServeHTTP(rw, req)
-	} else {
+	default:
 		h.handler.ServeHTTP(rw, req)
 	}
 }
 
+// serveError renders an error using the error handler when one is configured.
+func (h *httpUpstreamProxy) serveError(rw http.ResponseWriter, req *http.Request, err error) {
+	if h.errorHandler != nil {
+		h.errorHandler(rw, req, err)
+		return
+	}
+	rw.WriteHeader(http.StatusBadGateway)
+}
+
 // newReverseProxy creates a new reverse proxy for proxying requests to upstream
 // servers based on the upstream configuration provided.
 // The proxy should render an error page if there are failures connecting to the
 // upstream server.
-func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler ProxyErrorHandler) http.Handler {
+func newReverseProxy(target *url.URL, upstream options.Upstream, tlsConfig *tls.Config, dial dialFunc, outlier *outlierDetector, errorHandler ProxyErrorHandler) http.Handler {
 	proxy := httputil.NewSingleHostReverseProxy(target)
 
 	// Inherit default transport options from Go's stdlib
@@ -107,6 +190,10 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 		transport.ResponseHeaderTimeout = upstream.Timeout.Duration()
 	}
 
+	// Connect using the upstream dialer so that the connect timeout and unix
+	// domain sockets are respected
+	transport.DialContext = dial
+
 	// Configure options on the SingleHostReverseProxy
 	if upstream.FlushInterval != nil {
 		proxy.FlushInterval = upstream.FlushInterval.Duration()
@@ -114,12 +201,23 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 		proxy.FlushInterval = options.DefaultUpstreamFlushInterval
 	}
 
-	// InsecureSkipVerify is a configurable option we allow
-	/* #nosec G402 */
-	if upstream.InsecureSkipTLSVerify {
-		transport.TLSClientConfig.InsecureSkipVerify = true
+	// Streaming responses are flushed immediately and wait for the upstream
+	// for the streaming timeout instead
+	if upstream.Streaming != nil {
+		proxy.FlushInterval = -1
+		transport.ResponseHeaderTimeout = 0
+		if upstream.Streaming.Timeout != nil {
+			transport.ResponseHeaderTimeout = upstream.Streaming.Timeout.Duration()
+		}
 	}
 
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	// Reuse the buffers of previous responses to copy the response body
+	proxy.BufferPool = copyBuffers
+
 	// Ensure we always pass the original request path
 	setProxyDirector(proxy)
 
@@ -133,12 +231,35 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 		proxy.ErrorHandler = errorHandler
 	}
 
+	// Upstreams with HTTP/2 enabled are always connected to using HTTP/2,
+	// otherwise HTTP/2 is only negotiated with HTTPS upstreams
+	var roundTripper http.RoundTripper = transport
+	if upstream.HTTP2 {
+		roundTripper = newHTTP2RoundTripper(target, tlsConfig, dial, transport.ResponseHeaderTimeout)
+	}
+
 	// Apply the customized transport to our proxy before returning it
-	proxy.Transport = transport
+	proxy.Transport = newUpstreamRoundTripper(upstream, roundTripper, outlier)
 
 	return proxy
 }
 
+// newUpstreamRoundTripper wraps the transport with outlier detection and
+// retries when they are configured for the upstream.
+// Retries wrap outlier detection so that every attempt is recorded.
+func newUpstreamRoundTripper(upstream options.Upstream, transport http.RoundTripper, outlier *outlierDetector) http.RoundTripper {
+	if outlier != nil {
+		transport = &outlierTransport{
+			transport: transport,
+			detector:  outlier,
+		}
+	}
+	if upstream.Retries != nil && upstream.Retries.Attempts > 0 {
+		transport = newRetryTransport(upstream.Retries, transport)
+	}
+	return transport
+}
+
 // setProxyUpstreamHostHeader sets the proxy.Director so that upstream requests
 // receive a host header matching the target URL.
 func setProxyUpstreamHostHeader(proxy *httputil.ReverseProxy, target *url.URL) {
@@ -163,19 +284,66 @@ func setProxyDirector(proxy *httputil.ReverseProxy) {
 }
 
 // newWebSocketReverseProxy creates a new reverse proxy for proxying websocket connections.
-func newWebSocketReverseProxy(u *url.URL, skipTLSVerify bool) http.Handler {
+func newWebSocketReverseProxy(u *url.URL, tlsConfig *tls.Config, dial dialFunc) http.Handler {
 	wsProxy := httputil.NewSingleHostReverseProxy(u)
 
 	// Inherit default transport options from Go's stdlib
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-
-	/* #nosec G402 */
-	if skipTLSVerify {
-		transport.TLSClientConfig.InsecureSkipVerify = true
+	transport.DialContext = dial
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
 	}
 
 	// Apply the customized transport to our proxy before returning it
 	wsProxy.Transport = transport
+	wsProxy.BufferPool = copyBuffers
 
 	return wsProxy
 }
+
+// newHealthCheckTransport creates the transport used to send health checks to
+// the upstream server.
+func newHealthCheckTransport(tlsConfig *tls.Config, dial dialFunc) http.RoundTripper {
+	// Inherit default transport options from Go's stdlib
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	return transport
+}
+
+// newUpstreamTLSConfig creates the TLS configuration used to connect to the
+// upstream server, or nil when the default configuration should be used.
+func newUpstreamTLSConfig(upstream options.Upstream) (*tls.Config, error) {
+	if !upstream.InsecureSkipTLSVerify && len(upstream.CAFiles) == 0 && upstream.SPIFFE == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	// InsecureSkipVerify is a configurable option we allow
+	/* #nosec G402 */
+	if upstream.InsecureSkipTLSVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if len(upstream.CAFiles) > 0 {
+		pool, err := util.GetCertPool(upstream.CAFiles)
+		if err != nil {
+			return nil, fmt.Errorf("could not load CA files: %v", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if upstream.SPIFFE != nil {
+		source, err := spiffe.GetSource(upstream.SPIFFE.EndpointSocket)
+		if err != nil {
+			return nil, fmt.Errorf("could not create SPIFFE source: %v", err)
+		}
+		source.ConfigureClientTLS(tlsConfig, upstream.SPIFFE.AuthorizedIDs)
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,107 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// newHTTP2Transport creates a transport that always speaks HTTP/2 to the
+// upstream server.
+// Insecure upstreams are connected to using cleartext HTTP/2 (h2c) with
+// prior knowledge.
+func newHTTP2Transport(target *url.URL, tlsConfig *tls.Config, dial dialFunc) *http2.Transport {
+	transport := &http2.Transport{
+		TLSClientConfig: &tls.Config{},
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	if transport.TLSClientConfig.MinVersion == 0 {
+		transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if target.Scheme == httpScheme {
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, addr)
+		}
+	} else {
+		transport.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			conn, err := dial(context.Background(), network, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+
+	return transport
+}
+
+// responseHeaderTimeoutTransport cancels requests when the upstream does not
+// respond with headers within the timeout.
+// This is equivalent to http.Transport's ResponseHeaderTimeout, which the
+// HTTP/2 transport does not support.
+type responseHeaderTimeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+// RoundTrip performs the request, cancelling it if the response headers are
+// not received before the timeout.
+func (t *responseHeaderTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	timedOut := !timer.Stop()
+	if err != nil {
+		cancel()
+		if timedOut {
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
+
+	// The request context must remain valid until the body has been read
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnCloseBody cancels the request context when the response body is
+// closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the request context.
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// newHTTP2RoundTripper creates the round tripper used for upstreams that have
+// HTTP/2 enabled.
+func newHTTP2RoundTripper(target *url.URL, tlsConfig *tls.Config, dial dialFunc, responseHeaderTimeout time.Duration) http.RoundTripper {
+	var transport http.RoundTripper = newHTTP2Transport(target, tlsConfig, dial)
+	if responseHeaderTimeout > 0 {
+		transport = &responseHeaderTimeoutTransport{
+			transport: transport,
+			timeout:   responseHeaderTimeout,
+		}
+	}
+	return transport
+}
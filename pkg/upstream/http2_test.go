@@ -0,0 +1,77 @@
+package upstream
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ = Describe("HTTP/2 Upstream Suite", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		// Respond with the protocol version used by the proxy
+		server = httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(strconv.Itoa(req.ProtoMajor)))
+		}), &http2.Server{}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	serve := func(upstream options.Upstream) string {
+		u, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		handler, err := newHTTPUpstreamProxy(upstream, u, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		return rw.Body.String()
+	}
+
+	It("uses HTTP/1.1 for insecure upstreams by default", func() {
+		Expect(serve(options.Upstream{ID: "h1"})).To(Equal("1"))
+	})
+
+	It("uses h2c for insecure upstreams with HTTP/2 enabled", func() {
+		Expect(serve(options.Upstream{ID: "h2c", HTTP2: true})).To(Equal("2"))
+	})
+
+	It("times out requests waiting for response headers", func() {
+		transport := &responseHeaderTimeoutTransport{
+			transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, errors.New("request cancelled")
+			}),
+			timeout: 10 * time.Millisecond,
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://upstream:8080/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = transport.RoundTrip(req)
+		Expect(err).To(MatchError("context deadline exceeded"))
+	})
+})
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
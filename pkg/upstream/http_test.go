@@ -75,7 +75,8 @@ var _ = Describe("HTTP Upstream Suite", func() {
 			u, err := url.Parse(*in.serverAddr)
 			Expect(err).ToNot(HaveOccurred())
 
-			handler := newHTTPUpstreamProxy(upstream, u, in.signatureData, in.errorHandler)
+			handler, err := newHTTPUpstreamProxy(upstream, u, in.signatureData, in.errorHandler)
+			Expect(err).ToNot(HaveOccurred())
 			handler.ServeHTTP(rw, req)
 
 			Expect(rw.Code).To(Equal(in.expectedResponse.code))
@@ -331,7 +332,8 @@ var _ = Describe("HTTP Upstream Suite", func() {
 		u, err := url.Parse(serverAddr)
 		Expect(err).ToNot(HaveOccurred())
 
-		handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+		handler, err := newHTTPUpstreamProxy(upstream, u, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
 		httpUpstream, ok := handler.(*httpUpstreamProxy)
 		Expect(ok).To(BeTrue())
 
@@ -371,7 +373,8 @@ var _ = Describe("HTTP Upstream Suite", func() {
 				Timeout:               &in.timeout,
 			}
 
-			handler := newHTTPUpstreamProxy(upstream, u, in.sigData, in.errorHandler)
+			handler, err := newHTTPUpstreamProxy(upstream, u, in.sigData, in.errorHandler)
+			Expect(err).ToNot(HaveOccurred())
 			upstreamProxy, ok := handler.(*httpUpstreamProxy)
 			Expect(ok).To(BeTrue())
 
@@ -461,7 +464,8 @@ var _ = Describe("HTTP Upstream Suite", func() {
 			u, err := url.Parse(serverAddr)
 			Expect(err).ToNot(HaveOccurred())
 
-			handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+			handler, err := newHTTPUpstreamProxy(upstream, u, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
 
 			proxyServer = httptest.NewServer(middleware.NewScope(false, "X-Request-Id")(handler))
 		})
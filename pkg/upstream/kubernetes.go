@@ -0,0 +1,179 @@
+package upstream
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// Headers understood by the Kubernetes API server when impersonating users.
+// See https://kubernetes.io/docs/reference/access-authn-authz/authentication/#user-impersonation
+const (
+	impersonateUserHeader        = "Impersonate-User"
+	impersonateGroupHeader       = "Impersonate-Group"
+	impersonateUIDHeader         = "Impersonate-Uid"
+	impersonateExtraHeaderPrefix = "Impersonate-Extra-"
+
+	defaultImpersonationUserClaim   = "user"
+	defaultImpersonationGroupsClaim = "groups"
+)
+
+// kubernetesImpersonator maps authenticated sessions onto the Kubernetes
+// impersonation headers and authenticates the request to the API server.
+type kubernetesImpersonator struct {
+	userClaim   string
+	userPrefix  string
+	groupsClaim string
+	groupPrefix string
+	token       *impersonationToken
+}
+
+// newKubernetesImpersonator creates a kubernetesImpersonator from the given
+// configuration, applying defaults for any claims that have not been set.
+func newKubernetesImpersonator(opts *options.KubernetesImpersonation) (*kubernetesImpersonator, error) {
+	if opts.Token == nil {
+		return nil, fmt.Errorf("a token is required for kubernetes impersonation")
+	}
+
+	token, err := newImpersonationToken(opts.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &kubernetesImpersonator{
+		userClaim:   opts.UserClaim,
+		userPrefix:  opts.UserPrefix,
+		groupsClaim: opts.GroupsClaim,
+		groupPrefix: opts.GroupPrefix,
+		token:       token,
+	}
+	if k.userClaim == "" {
+		k.userClaim = defaultImpersonationUserClaim
+	}
+	if k.groupsClaim == "" {
+		k.groupsClaim = defaultImpersonationGroupsClaim
+	}
+	return k, nil
+}
+
+// impersonate removes any impersonation or authorization headers supplied by
+// the client and, when the session identifies a user, replaces them with the
+// identity from the session and the service account credentials.
+// Requests without a user are forwarded anonymously so that the API server
+// makes the authorization decision.
+func (k *kubernetesImpersonator) impersonate(req *http.Request, session *sessionsapi.SessionState) error {
+	for name := range req.Header {
+		if strings.HasPrefix(name, impersonateExtraHeaderPrefix) {
+			req.Header.Del(name)
+		}
+	}
+	req.Header.Del(impersonateUserHeader)
+	req.Header.Del(impersonateGroupHeader)
+	req.Header.Del(impersonateUIDHeader)
+	req.Header.Del("Authorization")
+
+	user := firstNonEmpty(session.GetClaim(k.userClaim))
+	if user == "" {
+		return nil
+	}
+
+	token, err := k.token.get()
+	if err != nil {
+		return fmt.Errorf("could not load kubernetes impersonation token: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(impersonateUserHeader, k.userPrefix+user)
+	// Groups must be sent as individual headers, the API server does not
+	// split comma separated values.
+	for _, group := range session.GetClaim(k.groupsClaim) {
+		if group != "" {
+			req.Header.Add(impersonateGroupHeader, k.groupPrefix+group)
+		}
+	}
+	return nil
+}
+
+// firstNonEmpty returns the first non-empty value, or an empty string if
+// none exists.
+func firstNonEmpty(values []string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// impersonationToken holds the bearer token used to authenticate to the
+// API server.
+// Tokens loaded from files are reloaded whenever the modification time of the
+// file changes.
+type impersonationToken struct {
+	path string
+
+	mutex   sync.Mutex
+	value   string
+	modTime time.Time
+}
+
+// newImpersonationToken loads the initial token from the secret source.
+func newImpersonationToken(source *options.SecretSource) (*impersonationToken, error) {
+	t := &impersonationToken{path: source.FromFile}
+	if t.path != "" {
+		if _, err := t.get(); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	value, err := util.GetSecretValue(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not load kubernetes impersonation token: %v", err)
+	}
+	t.value = strings.TrimSpace(string(value))
+	if t.value == "" {
+		return nil, fmt.Errorf("kubernetes impersonation token is empty")
+	}
+	return t, nil
+}
+
+// get returns the current token, reloading it from disk if it has changed.
+func (t *impersonationToken) get() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.path == "" {
+		return t.value, nil
+	}
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return "", err
+	}
+	if t.value != "" && info.ModTime().Equal(t.modTime) {
+		return t.value, nil
+	}
+
+	// The token path is a configurable option
+	data, err := ioutil.ReadFile(t.path) // #nosec G304
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("token file %q is empty", t.path)
+	}
+
+	t.value = value
+	t.modTime = info.ModTime()
+	return t.value, nil
+}
@@ -0,0 +1,135 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Kubernetes Impersonation Suite", func() {
+	type impersonateTableInput struct {
+		opts            options.KubernetesImpersonation
+		session         *sessionsapi.SessionState
+		requestHeaders  http.Header
+		expectedHeaders http.Header
+	}
+
+	token := &options.SecretSource{Value: []byte("service-account-token")}
+
+	DescribeTable("impersonate",
+		func(in impersonateTableInput) {
+			impersonator, err := newKubernetesImpersonator(&in.opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			req, err := http.NewRequest("GET", "/api/v1/namespaces", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header = in.requestHeaders
+
+			Expect(impersonator.impersonate(req, in.session)).To(Succeed())
+			Expect(req.Header).To(Equal(in.expectedHeaders))
+		},
+		Entry("with a session and default claims", impersonateTableInput{
+			opts: options.KubernetesImpersonation{Token: token},
+			session: &sessionsapi.SessionState{
+				User:   "user-id",
+				Email:  "user@example.com",
+				Groups: []string{"admins", "developers"},
+			},
+			requestHeaders: http.Header{},
+			expectedHeaders: http.Header{
+				"Authorization":     []string{"Bearer service-account-token"},
+				"Impersonate-User":  []string{"user-id"},
+				"Impersonate-Group": []string{"admins", "developers"},
+			},
+		}),
+		Entry("with custom claims and prefixes", impersonateTableInput{
+			opts: options.KubernetesImpersonation{
+				Token:       token,
+				UserClaim:   "email",
+				UserPrefix:  "oidc:",
+				GroupPrefix: "oidc:",
+			},
+			session: &sessionsapi.SessionState{
+				User:   "user-id",
+				Email:  "user@example.com",
+				Groups: []string{"admins"},
+			},
+			requestHeaders: http.Header{},
+			expectedHeaders: http.Header{
+				"Authorization":     []string{"Bearer service-account-token"},
+				"Impersonate-User":  []string{"oidc:user@example.com"},
+				"Impersonate-Group": []string{"oidc:admins"},
+			},
+		}),
+		Entry("strips client supplied impersonation headers", impersonateTableInput{
+			opts: options.KubernetesImpersonation{Token: token},
+			session: &sessionsapi.SessionState{
+				User: "user-id",
+			},
+			requestHeaders: http.Header{
+				"Authorization":           []string{"Bearer client-token"},
+				"Impersonate-User":        []string{"system:admin"},
+				"Impersonate-Group":       []string{"system:masters"},
+				"Impersonate-Uid":         []string{"1234"},
+				"Impersonate-Extra-Scope": []string{"all"},
+				"Accept":                  []string{"application/json"},
+			},
+			expectedHeaders: http.Header{
+				"Authorization":    []string{"Bearer service-account-token"},
+				"Impersonate-User": []string{"user-id"},
+				"Accept":           []string{"application/json"},
+			},
+		}),
+		Entry("without a session", impersonateTableInput{
+			opts:    options.KubernetesImpersonation{Token: token},
+			session: nil,
+			requestHeaders: http.Header{
+				"Authorization":    []string{"Bearer client-token"},
+				"Impersonate-User": []string{"system:admin"},
+			},
+			expectedHeaders: http.Header{},
+		}),
+	)
+
+	Context("with a token file", func() {
+		var tokenFile string
+
+		BeforeEach(func() {
+			dir, err := ioutil.TempDir("", "oauth2-proxy-kubernetes-token")
+			Expect(err).ToNot(HaveOccurred())
+			tokenFile = path.Join(dir, "token")
+			Expect(ioutil.WriteFile(tokenFile, []byte("first-token\n"), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(path.Dir(tokenFile))).To(Succeed())
+		})
+
+		It("reloads the token when the file changes", func() {
+			impersonator, err := newKubernetesImpersonator(&options.KubernetesImpersonation{
+				Token: &options.SecretSource{FromFile: tokenFile},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(impersonator.token.get()).To(Equal("first-token"))
+
+			Expect(ioutil.WriteFile(tokenFile, []byte("second-token\n"), 0600)).To(Succeed())
+			later := time.Now().Add(time.Minute)
+			Expect(os.Chtimes(tokenFile, later, later)).To(Succeed())
+
+			Expect(impersonator.token.get()).To(Equal("second-token"))
+		})
+	})
+
+	It("requires a token", func() {
+		_, err := newKubernetesImpersonator(&options.KubernetesImpersonation{})
+		Expect(err).To(MatchError("a token is required for kubernetes impersonation"))
+	})
+})
@@ -0,0 +1,47 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+)
+
+// newAllowedMethods creates a new middleware that will reject requests to the
+// upstream that do not use one of the allowed methods.
+func newAllowedMethods(upstream string, methods []string, writer pagewriter.Writer) alice.Constructor {
+	allowed := make(map[string]struct{}, len(methods))
+	normalized := make([]string, 0, len(methods))
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if _, ok := allowed[method]; ok {
+			continue
+		}
+		allowed[method] = struct{}{}
+		normalized = append(normalized, method)
+	}
+	allowHeader := strings.Join(normalized, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if _, ok := allowed[req.Method]; ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			scope := middleware.GetRequestScope(req)
+			scope.Upstream = upstream
+
+			rw.Header().Set("Allow", allowHeader)
+			writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
+				Status:         http.StatusMethodNotAllowed,
+				RequestID:      scope.RequestID,
+				AppError:       fmt.Sprintf("Method %s is not allowed for upstream %q", req.Method, upstream),
+				AcceptLanguage: req.Header.Get("Accept-Language"),
+			})
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Allowed Methods Suite", func() {
+	type allowedMethodsTableInput struct {
+		method         string
+		expectedStatus int
+		expectedAllow  string
+	}
+
+	DescribeTable("when serving a request",
+		func(in allowedMethodsTableInput) {
+			req := httptest.NewRequest(in.method, "/reports", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			rw := httptest.NewRecorder()
+
+			handler := newAllowedMethods("reports", []string{"get", "HEAD", "GET"}, &pagewriter.WriterFuncs{})(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedStatus))
+			Expect(rw.Header().Get("Allow")).To(Equal(in.expectedAllow))
+		},
+		Entry("with an allowed method", allowedMethodsTableInput{
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+		}),
+		Entry("with another allowed method", allowedMethodsTableInput{
+			method:         http.MethodHead,
+			expectedStatus: http.StatusOK,
+		}),
+		Entry("with a method that is not allowed", allowedMethodsTableInput{
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, HEAD",
+		}),
+	)
+})
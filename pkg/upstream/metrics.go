@@ -0,0 +1,195 @@
+package upstream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// upstreamMetrics are the traffic metrics of an upstream.
+type upstreamMetrics struct {
+	upstream string
+	requests *prometheus.CounterVec
+	inFlight prometheus.Gauge
+	duration prometheus.Observer
+}
+
+// newUpstreamMetrics registers the traffic metrics of the upstream with the
+// registerer, or reuses them if they are already registered.
+func newUpstreamMetrics(registerer prometheus.Registerer, upstreamID string) *upstreamMetrics {
+	labels := prometheus.Labels{"upstream": upstreamID}
+	return &upstreamMetrics{
+		upstream: upstreamID,
+		requests: registerUpstreamRequestsCounter(registerer).MustCurryWith(labels),
+		inFlight: registerUpstreamInFlightGauge(registerer).With(labels),
+		duration: registerUpstreamDurationHistogram(registerer).With(labels),
+	}
+}
+
+// middleware records the requests served by the upstream, by the class of
+// their response status code, how many are in flight and how long they take.
+// The ID of the trace of a request is added to its latency as an exemplar.
+// Each request is also written to the debug log of the upstream component.
+func (m *upstreamMetrics) middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+
+			start := time.Now()
+			mrw := &metricsResponseWriter{ResponseWriter: rw}
+			next.ServeHTTP(mrw, req)
+			duration := time.Since(start).Seconds()
+
+			m.requests.WithLabelValues(statusClass(mrw.status)).Inc()
+			logger.Debugf(logger.ComponentUpstream, "%s %s served by upstream %q: %d in %.3fs", req.Method, req.URL.RequestURI(), m.upstream, mrw.status, duration)
+
+			scope := middlewareapi.GetRequestScope(req)
+			if eo, ok := m.duration.(prometheus.ExemplarObserver); ok && scope != nil && scope.TraceID != "" {
+				eo.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": scope.TraceID})
+				return
+			}
+			m.duration.Observe(duration)
+		})
+	}
+}
+
+// statusClass returns the class of the status code, such as `2xx`. A response
+// whose status code was never written was sent as a 200.
+func statusClass(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// metricsResponseWriter records the status code of the response.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records and writes the status code of the response.
+func (w *metricsResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the data to the client.
+func (w *metricsResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom copies the data to the client, using the ReadFrom of the wrapped
+// writer when it has one.
+func (w *metricsResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return copyBuffer(w.ResponseWriter, r)
+}
+
+// Flush sends any buffered data to the client.
+func (w *metricsResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows WebSocket connections to take over the connection. The
+// status code of the upgrade is written directly to the connection, so it is
+// recorded as a 101.
+func (w *metricsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		if w.status == 0 {
+			w.status = http.StatusSwitchingProtocols
+		}
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not available on writer")
+}
+
+// registerUpstreamRequestsCounter registers the
+// 'oauth2_proxy_upstream_requests_total' metric.
+// This keeps a tally of the requests served by each upstream, bucketed by the
+// class of their response status code.
+func registerUpstreamRequestsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_upstream_requests_total",
+			Help: "Total number of requests served by the upstream by class of HTTP status code.",
+		},
+		[]string{"upstream", "class"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
+
+// registerUpstreamInFlightGauge registers the
+// 'oauth2_proxy_upstream_requests_in_flight' metric.
+// This keeps the count of the requests each upstream is currently serving.
+func registerUpstreamInFlightGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oauth2_proxy_upstream_requests_in_flight",
+			Help: "Current number of requests being served by the upstream.",
+		},
+		[]string{"upstream"},
+	)
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
+
+// registerUpstreamDurationHistogram registers the
+// 'oauth2_proxy_upstream_request_duration_seconds' metric.
+// This keeps a tally of the requests served by each upstream bucketed by the
+// time taken to serve them.
+func registerUpstreamDurationHistogram(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "oauth2_proxy_upstream_request_duration_seconds",
+			Help:    "A histogram of the latencies of requests served by the upstream.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"upstream"},
+	)
+
+	if err := registerer.Register(histogram); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			histogram = are.ExistingCollector.(*prometheus.HistogramVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return histogram
+}
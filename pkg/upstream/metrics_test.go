@@ -0,0 +1,59 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Upstream Metrics Suite", func() {
+	var registry *prometheus.Registry
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+	})
+
+	It("counts the requests by upstream and class of status code", func() {
+		ok := newUpstreamMetrics(registry, "app").middleware()(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			rw.Write([]byte("ok"))
+		}))
+		notFound := newUpstreamMetrics(registry, "api").middleware()(http.NotFoundHandler())
+
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", nil))
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", nil))
+		notFound.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/api", nil))
+
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP oauth2_proxy_upstream_requests_total Total number of requests served by the upstream by class of HTTP status code.
+# TYPE oauth2_proxy_upstream_requests_total counter
+oauth2_proxy_upstream_requests_total{class="2xx",upstream="app"} 2
+oauth2_proxy_upstream_requests_total{class="4xx",upstream="api"} 1
+`), "oauth2_proxy_upstream_requests_total")).To(Succeed())
+		Expect(testutil.GatherAndCount(registry, "oauth2_proxy_upstream_request_duration_seconds")).To(Equal(2))
+	})
+
+	It("counts the requests in flight", func() {
+		metrics := newUpstreamMetrics(registry, "app")
+		handler := metrics.middleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			Expect(testutil.ToFloat64(metrics.inFlight)).To(Equal(1.0))
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", nil))
+		Expect(testutil.ToFloat64(metrics.inFlight)).To(Equal(0.0))
+	})
+
+	DescribeTable("statusClass",
+		func(status int, expected string) {
+			Expect(statusClass(status)).To(Equal(expected))
+		},
+		Entry("with no status", 0, "2xx"),
+		Entry("with a redirect", http.StatusFound, "3xx"),
+		Entry("with a server error", http.StatusBadGateway, "5xx"),
+	)
+})
@@ -0,0 +1,38 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// newWebSocketOriginCheck creates a new middleware that will reject WebSocket
+// upgrade requests to the upstream from origins that are not allowed.
+// Browsers always send the Origin header with WebSocket requests, so
+// requests without an Origin are from non-browser clients and are allowed.
+func newWebSocketOriginCheck(upstream string, origins []string, writer pagewriter.Writer) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if !requestutil.IsWebSocketRequest(req) || origin == "" || util.IsOriginAllowed(origin, origins) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			scope := middleware.GetRequestScope(req)
+			scope.Upstream = upstream
+
+			writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
+				Status:         http.StatusForbidden,
+				RequestID:      scope.RequestID,
+				AppError:       fmt.Sprintf("WebSocket origin %q is not allowed for upstream %q", origin, upstream),
+				AcceptLanguage: req.Header.Get("Accept-Language"),
+			})
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebSocket Origin Suite", func() {
+	type originTableInput struct {
+		websocket      bool
+		origin         string
+		expectedStatus int
+	}
+
+	DescribeTable("when serving a request",
+		func(in originTableInput) {
+			req := httptest.NewRequest("GET", "/socket", nil)
+			if in.websocket {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if in.origin != "" {
+				req.Header.Set("Origin", in.origin)
+			}
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			rw := httptest.NewRecorder()
+
+			handler := newWebSocketOriginCheck("socket", []string{"https://*.example.com"}, &pagewriter.WriterFuncs{})(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedStatus))
+		},
+		Entry("with an allowed origin", originTableInput{
+			websocket:      true,
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusOK,
+		}),
+		Entry("with a disallowed origin", originTableInput{
+			websocket:      true,
+			origin:         "https://evil.com",
+			expectedStatus: http.StatusForbidden,
+		}),
+		Entry("without an origin", originTableInput{
+			websocket:      true,
+			expectedStatus: http.StatusOK,
+		}),
+		Entry("with a disallowed origin on a non WebSocket request", originTableInput{
+			origin:         "https://evil.com",
+			expectedStatus: http.StatusOK,
+		}),
+	)
+})
@@ -0,0 +1,121 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// errHostEjected is returned when a request is made to an ejected host.
+var errHostEjected = errors.New("upstream host has been ejected after repeated failures")
+
+// outlierDetector tracks consecutive failures for upstream hosts and ejects
+// hosts which fail repeatedly.
+type outlierDetector struct {
+	consecutiveFailures int
+	ejectionDuration    time.Duration
+	now                 func() time.Time
+
+	mutex sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState is the failure state of a single upstream host.
+type hostState struct {
+	failures     int
+	ejectedUntil time.Time
+}
+
+// newOutlierDetector creates an outlierDetector from the configuration,
+// applying defaults to any unset options.
+func newOutlierDetector(opts *options.OutlierDetection) *outlierDetector {
+	o := &outlierDetector{
+		consecutiveFailures: opts.ConsecutiveFailures,
+		ejectionDuration:    options.DefaultOutlierEjectionDuration,
+		now:                 time.Now,
+		hosts:               make(map[string]*hostState),
+	}
+	if o.consecutiveFailures <= 0 {
+		o.consecutiveFailures = options.DefaultOutlierConsecutiveFailures
+	}
+	if opts.EjectionDuration != nil {
+		o.ejectionDuration = opts.EjectionDuration.Duration()
+	}
+	return o
+}
+
+// available determines whether requests may be sent to the host.
+func (o *outlierDetector) available(host string) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	state, ok := o.hosts[host]
+	if !ok {
+		return true
+	}
+	return !o.now().Before(state.ejectedUntil)
+}
+
+// success resets the failure count for the host.
+func (o *outlierDetector) success(host string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	delete(o.hosts, host)
+}
+
+// failure records a failure for the host, ejecting it when the number of
+// consecutive failures reaches the threshold.
+func (o *outlierDetector) failure(host string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	state, ok := o.hosts[host]
+	if !ok {
+		state = &hostState{}
+		o.hosts[host] = state
+	}
+
+	state.failures++
+	if state.failures >= o.consecutiveFailures {
+		logger.Errorf("Ejecting upstream host %q for %s after %d consecutive failures", host, o.ejectionDuration, state.failures)
+		state.ejectedUntil = o.now().Add(o.ejectionDuration)
+		state.failures = 0
+	}
+}
+
+// outlierTransport records the result of each round trip with the
+// outlierDetector and fails requests to ejected hosts.
+type outlierTransport struct {
+	transport http.RoundTripper
+	detector  *outlierDetector
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *outlierTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !t.detector.available(host) {
+		return nil, fmt.Errorf("%w: %s", errHostEjected, host)
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		// Cancelled requests say nothing about the health of the upstream
+		if req.Context().Err() == nil {
+			t.detector.failure(host)
+		}
+		return resp, err
+	}
+
+	if isRetryableStatus(resp.StatusCode) {
+		t.detector.failure(host)
+	} else {
+		t.detector.success(host)
+	}
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package upstream
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Outlier Detection Suite", func() {
+	var now time.Time
+	var detector *outlierDetector
+
+	BeforeEach(func() {
+		ejectionDuration := options.Duration(time.Minute)
+		detector = newOutlierDetector(&options.OutlierDetection{
+			ConsecutiveFailures: 2,
+			EjectionDuration:    &ejectionDuration,
+		})
+		now = time.Now()
+		detector.now = func() time.Time { return now }
+	})
+
+	It("ejects hosts after consecutive failures", func() {
+		detector.failure("upstream:8080")
+		Expect(detector.available("upstream:8080")).To(BeTrue())
+
+		detector.failure("upstream:8080")
+		Expect(detector.available("upstream:8080")).To(BeFalse())
+		Expect(detector.available("other:8080")).To(BeTrue())
+
+		now = now.Add(time.Minute)
+		Expect(detector.available("upstream:8080")).To(BeTrue())
+	})
+
+	It("resets the failure count after a success", func() {
+		detector.failure("upstream:8080")
+		detector.success("upstream:8080")
+		detector.failure("upstream:8080")
+		Expect(detector.available("upstream:8080")).To(BeTrue())
+	})
+
+	It("fails requests to ejected hosts without contacting the upstream", func() {
+		fake := &fakeRoundTripper{results: []fakeRoundTripResult{
+			{err: errors.New("connection refused")},
+			{status: http.StatusBadGateway},
+			{status: http.StatusOK},
+		}}
+		transport := &outlierTransport{transport: fake, detector: detector}
+
+		req, err := http.NewRequest(http.MethodGet, "http://upstream:8080/", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = transport.RoundTrip(req)
+		Expect(err).To(HaveOccurred())
+		resp, err := transport.RoundTrip(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+
+		_, err = transport.RoundTrip(req)
+		Expect(errors.Is(err, errHostEjected)).To(BeTrue())
+		Expect(fake.calls).To(Equal(2))
+	})
+})
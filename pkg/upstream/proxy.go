@@ -7,93 +7,337 @@ import (
 	"net/url"
 	"regexp"
 	"sort"
-	"strings"
 
-	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ProxyErrorHandler is a function that will be used to render error pages when
 // HTTP proxies fail to connect to upstream servers.
 type ProxyErrorHandler func(http.ResponseWriter, *http.Request, error)
 
-// NewProxy creates a new multiUpstreamProxy that can serve requests directed to
-// multiple upstreams.
-func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
-	m := &multiUpstreamProxy{
-		serveMux: mux.NewRouter(),
+// Proxy serves requests directed to the configured upstreams.
+type Proxy interface {
+	http.Handler
+
+	// VerifyConnection checks that every health checked upstream has at least
+	// one healthy server.
+	VerifyConnection(context.Context) error
+
+	// Upstreams returns the upstreams that are currently being served,
+	// including those added at runtime.
+	Upstreams() []options.Upstream
+
+	// MatchUpstream returns the upstream that would serve the request, if
+	// any.
+	MatchUpstream(*http.Request) (options.Upstream, bool)
+
+	// Reconcile replaces the upstreams added at runtime with those given.
+	// Unchanged upstreams keep their state, such as their health checks, and
+	// the routes are swapped atomically so requests in flight are not
+	// affected.
+	Reconcile([]options.Upstream) error
+
+	// AddUpstream adds an upstream at runtime, replacing any upstream added
+	// at runtime with the same ID.
+	AddUpstream(options.Upstream) error
+
+	// RemoveUpstream removes an upstream that was added at runtime.
+	RemoveUpstream(id string) error
+
+	// Close stops the background health checks and service discovery of the
+	// upstreams. Requests may still be served once the proxy is closed.
+	Close()
+}
+
+// NewProxy creates a new Proxy that can serve requests directed to multiple
+// upstreams.
+// When a DynamicUpstreamsFile is configured, its upstreams are served along
+// with the configured upstreams and are reconciled whenever the file changes.
+func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (Proxy, error) {
+	p := &dynamicProxy{
+		config:   upstreams,
+		sigData:  sigData,
+		writer:   writer,
+		handlers: make(map[string]*upstreamHandler),
+		done:     make(chan bool),
 	}
 
-	if upstreams.ProxyRawPath {
-		m.serveMux.UseEncodedPath()
+	var dynamic []options.Upstream
+	if upstreams.DynamicUpstreamsFile != "" {
+		var err error
+		dynamic, err = options.LoadDynamicUpstreams(upstreams.DynamicUpstreamsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.reconcile(dynamic); err != nil {
+		return nil, err
 	}
 
-	for _, upstream := range sortByPathLongest(upstreams.Upstreams) {
-		if upstream.Static {
-			if err := m.registerStaticResponseHandler(upstream, writer); err != nil {
-				return nil, fmt.Errorf("could not register static upstream %q: %v", upstream.ID, err)
-			}
-			continue
+	if upstreams.DynamicUpstreamsFile != "" {
+		if err := p.watch(upstreams.DynamicUpstreamsFile); err != nil {
+			p.Close()
+			return nil, err
 		}
+	}
+	return p, nil
+}
 
-		u, err := url.Parse(upstream.URI)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing URI for upstream %q: %w", upstream.ID, err)
+// newMultiUpstreamProxy creates a multiUpstreamProxy that routes requests to
+// the handlers of the upstreams.
+func newMultiUpstreamProxy(proxyRawPath bool, handlers map[string]*upstreamHandler, writer pagewriter.Writer) (*multiUpstreamProxy, error) {
+	m := &multiUpstreamProxy{
+		routes:    newRoutes(proxyRawPath),
+		upstreams: make(map[string]options.Upstream, len(handlers)),
+	}
+
+	upstreams := make([]options.Upstream, 0, len(handlers))
+	for _, h := range handlers {
+		upstreams = append(upstreams, h.upstream)
+	}
+	for _, upstream := range sortByPathLongest(upstreams) {
+		h := handlers[upstream.ID]
+		m.upstreams[upstream.ID] = upstream
+		if h.healthChecks != nil {
+			m.healthChecks = append(m.healthChecks, *h.healthChecks)
 		}
-		switch u.Scheme {
-		case fileScheme:
-			if err := m.registerFileServer(upstream, u, writer); err != nil {
-				return nil, fmt.Errorf("could not register file upstream %q: %v", upstream.ID, err)
-			}
-		case httpScheme, httpsScheme:
-			if err := m.registerHTTPUpstreamProxy(upstream, u, sigData, writer); err != nil {
-				return nil, fmt.Errorf("could not register HTTP upstream %q: %v", upstream.ID, err)
-			}
-		default:
-			return nil, fmt.Errorf("unknown scheme for upstream %q: %q", upstream.ID, u.Scheme)
+		if err := m.registerHandler(upstream, h.handler, writer); err != nil {
+			return nil, fmt.Errorf("could not register upstream %q: %v", upstream.ID, err)
 		}
 	}
 
-	registerTrailingSlashHandler(m.serveMux)
+	m.routes.compile()
 	return m, nil
 }
 
 // multiUpstreamProxy will serve requests directed to multiple upstream servers
-// registered in the serverMux.
+// registered in the routes.
 type multiUpstreamProxy struct {
-	serveMux *mux.Router
+	routes       *routes
+	healthChecks []upstreamHealthChecks
+
+	// upstreams maps the route IDs, which are the upstream IDs, to the
+	// upstreams.
+	upstreams map[string]options.Upstream
+}
+
+// upstreamHealthChecks are the health checks for each server of an upstream.
+// The checkers are returned by a function as the servers of load balanced
+// upstreams may change.
+type upstreamHealthChecks struct {
+	upstream string
+	checkers func() []*healthChecker
 }
 
 // ServerHTTP handles HTTP requests.
 func (m *multiUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	m.serveMux.ServeHTTP(rw, req)
+	m.routes.ServeHTTP(rw, req)
 }
 
-// registerStaticResponseHandler registers a static response handler with at the given path.
-func (m *multiUpstreamProxy) registerStaticResponseHandler(upstream options.Upstream, writer pagewriter.Writer) error {
+// MatchUpstream returns the upstream whose route matches the request.
+func (m *multiUpstreamProxy) MatchUpstream(req *http.Request) (options.Upstream, bool) {
+	rt, ok := m.routes.match(req.URL)
+	if !ok {
+		return options.Upstream{}, false
+	}
+	upstream, ok := m.upstreams[rt.id]
+	return upstream, ok
+}
+
+// VerifyConnection returns an error if any health checked upstream has no
+// healthy servers.
+func (m *multiUpstreamProxy) VerifyConnection(_ context.Context) error {
+	for _, hc := range m.healthChecks {
+		healthy := false
+		for _, checker := range hc.checkers() {
+			if checker.healthy() {
+				healthy = true
+				break
+			}
+		}
+		if !healthy {
+			return fmt.Errorf("upstream %q has no healthy endpoints", hc.upstream)
+		}
+	}
+	return nil
+}
+
+// upstreamHandler is the handler built for an upstream, along with its health
+// checks and a function to stop its background work.
+type upstreamHandler struct {
+	upstream     options.Upstream
+	handler      http.Handler
+	healthChecks *upstreamHealthChecks
+	close        func()
+}
+
+// newUpstreamHandler builds the handler for the upstream based on the
+// configuration given.
+func newUpstreamHandler(upstream options.Upstream, sigData *options.SignatureData, writer pagewriter.Writer) (*upstreamHandler, error) {
+	h, err := newBaseUpstreamHandler(upstream, sigData, writer)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.wrap(writer); err != nil {
+		h.stop()
+		return nil, err
+	}
+	return h, nil
+}
+
+// newBaseUpstreamHandler builds the handler that serves the requests for the
+// upstream, before any middlewares are applied.
+func newBaseUpstreamHandler(upstream options.Upstream, sigData *options.SignatureData, writer pagewriter.Writer) (*upstreamHandler, error) {
+	if upstream.Static {
+		h, err := newStaticResponseUpstream(upstream)
+		if err != nil {
+			return nil, fmt.Errorf("could not register static upstream %q: %v", upstream.ID, err)
+		}
+		return h, nil
+	}
+
+	if len(upstream.URIs) > 0 || upstream.Discovery != nil {
+		h, err := newLoadBalancerUpstream(upstream, sigData, writer)
+		if err != nil {
+			return nil, fmt.Errorf("could not register load balanced upstream %q: %v", upstream.ID, err)
+		}
+		return h, nil
+	}
+
+	u, err := url.Parse(upstream.URI)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URI for upstream %q: %w", upstream.ID, err)
+	}
+	switch u.Scheme {
+	case fileScheme:
+		return newFileServerUpstream(upstream, u), nil
+	case httpScheme, httpsScheme, unixScheme:
+		h, err := newHTTPUpstream(upstream, u, sigData, writer)
+		if err != nil {
+			return nil, fmt.Errorf("could not register HTTP upstream %q: %v", upstream.ID, err)
+		}
+		return h, nil
+	case fastCGIScheme:
+		return newFastCGIUpstream(upstream, u, writer), nil
+	default:
+		return nil, fmt.Errorf("unknown scheme for upstream %q: %q", upstream.ID, u.Scheme)
+	}
+}
+
+// newStaticResponseUpstream creates a static response handler.
+func newStaticResponseUpstream(upstream options.Upstream) (*upstreamHandler, error) {
 	logger.Printf("mapping path %q => static response %d", upstream.Path, derefStaticCode(upstream.StaticCode))
-	return m.registerHandler(upstream, newStaticResponseHandler(upstream.ID, upstream.StaticCode), writer)
+	if upstream.StaticResponse != nil {
+		handler, err := newStaticTemplateHandler(upstream.ID, upstream.StaticCode, upstream.StaticResponse)
+		if err != nil {
+			return nil, err
+		}
+		return &upstreamHandler{upstream: upstream, handler: handler}, nil
+	}
+	return &upstreamHandler{upstream: upstream, handler: newStaticResponseHandler(upstream.ID, upstream.StaticCode)}, nil
 }
 
-// registerFileServer registers a new fileServer based on the configuration given.
-func (m *multiUpstreamProxy) registerFileServer(upstream options.Upstream, u *url.URL, writer pagewriter.Writer) error {
+// newFileServerUpstream creates a new fileServer based on the configuration given.
+func newFileServerUpstream(upstream options.Upstream, u *url.URL) *upstreamHandler {
 	logger.Printf("mapping path %q => file system %q", upstream.Path, u.Path)
-	return m.registerHandler(upstream, newFileServer(upstream.ID, upstream.Path, u.Path), writer)
+	return &upstreamHandler{upstream: upstream, handler: newFileServer(upstream.ID, upstream.Path, u.Path, upstream.FileServer)}
 }
 
-// registerHTTPUpstreamProxy registers a new httpUpstreamProxy based on the configuration given.
-func (m *multiUpstreamProxy) registerHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer) error {
+// newHTTPUpstream creates a new httpUpstreamProxy based on the configuration given.
+func newHTTPUpstream(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer) (*upstreamHandler, error) {
 	logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
-	return m.registerHandler(upstream, newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), writer)
+	handler, err := newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler)
+	if err != nil {
+		return nil, err
+	}
+	h := &upstreamHandler{upstream: upstream, handler: handler}
+	if health := handler.(*httpUpstreamProxy).health; health != nil {
+		h.healthChecks = &upstreamHealthChecks{
+			upstream: upstream.ID,
+			checkers: func() []*healthChecker { return []*healthChecker{health} },
+		}
+		h.close = health.close
+	}
+	return h, nil
+}
+
+// newFastCGIUpstream creates a new fastCGIHandler based on the configuration given.
+func newFastCGIUpstream(upstream options.Upstream, u *url.URL, writer pagewriter.Writer) *upstreamHandler {
+	logger.Printf("mapping path %q => FastCGI upstream %q", upstream.Path, upstream.URI)
+	return &upstreamHandler{upstream: upstream, handler: newFastCGIHandler(upstream, u, writer.ProxyErrorHandler)}
+}
+
+// newLoadBalancerUpstream creates a new loadBalancer based on the configuration given.
+func newLoadBalancerUpstream(upstream options.Upstream, sigData *options.SignatureData, writer pagewriter.Writer) (*upstreamHandler, error) {
+	logger.Printf("mapping path %q => upstreams %q", upstream.Path, upstream.URIs)
+	handler, err := newLoadBalancer(upstream, sigData, writer.ProxyErrorHandler)
+	if err != nil {
+		return nil, err
+	}
+	h := &upstreamHandler{upstream: upstream, handler: handler, close: handler.(*loadBalancer).close}
+	if upstream.HealthCheck != nil {
+		h.healthChecks = &upstreamHealthChecks{
+			upstream: upstream.ID,
+			checkers: handler.(*loadBalancer).healthCheckers,
+		}
+	}
+	return h, nil
 }
 
-// registerHandler ensures the given handler is regiestered with the serveMux.
+// wrap applies the middlewares configured for the upstream to its handler.
+func (h *upstreamHandler) wrap(writer pagewriter.Writer) error {
+	upstream := h.upstream
+	handler := h.handler
+	if upstream.Shadow != nil {
+		shadow, err := newTrafficShadow(upstream)
+		if err != nil {
+			return fmt.Errorf("could not create shadow for upstream %q: %v", upstream.ID, err)
+		}
+		handler = shadow.middleware()(handler)
+	}
+	if upstream.ResponseHeaders != nil {
+		handler = newResponseHeaderPolicy(upstream.ResponseHeaders)(handler)
+	}
+	if upstream.Cache != nil {
+		cache, err := newResponseCache(upstream.ID, upstream.Cache)
+		if err != nil {
+			return fmt.Errorf("could not create cache for upstream %q: %v", upstream.ID, err)
+		}
+		handler = cache.middleware()(handler)
+	}
+	if upstream.Compression != nil {
+		handler = newCompression(upstream.Compression)(handler)
+	}
+	if upstream.RequestHeaders != nil && upstream.RequestHeaders.Encrypt != nil {
+		encrypt, err := newHeaderEncryption(upstream.ID, upstream.RequestHeaders.Encrypt)
+		if err != nil {
+			return err
+		}
+		handler = encrypt(handler)
+	}
+	if upstream.RequestHeaders != nil {
+		handler = newRequestHeaderPolicy(upstream.RequestHeaders)(handler)
+	}
+	if len(upstream.AllowedMethods) > 0 {
+		handler = newAllowedMethods(upstream.ID, upstream.AllowedMethods, writer)(handler)
+	}
+	if len(upstream.WebSocketAllowedOrigins) > 0 {
+		handler = newWebSocketOriginCheck(upstream.ID, upstream.WebSocketAllowedOrigins, writer)(handler)
+	}
+	handler = newUpstreamMetrics(prometheus.DefaultRegisterer, upstream.ID).middleware()(handler)
+
+	h.handler = handler
+	return nil
+}
+
+// registerHandler ensures the given handler is regiestered with the routes.
 func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler http.Handler, writer pagewriter.Writer) error {
-	if upstream.RewriteTarget == "" {
-		m.registerSimpleHandler(upstream.Path, handler)
+	if !hasRewrite(upstream) {
+		m.registerSimpleHandler(upstream.ID, upstream.Path, handler)
 		return nil
 	}
 
@@ -102,12 +346,10 @@ func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler
 
 // registerSimpleHandler maintains the behaviour of the go standard serveMux
 // by ensuring any path with a trailing `/` matches all paths under that prefix.
-func (m *multiUpstreamProxy) registerSimpleHandler(path string, handler http.Handler) {
-	if strings.HasSuffix(path, "/") {
-		m.serveMux.PathPrefix(path).Handler(handler)
-	} else {
-		m.serveMux.Path(path).Handler(handler)
-	}
+// The route holds the upstream ID so that it can be matched back to the
+// upstream.
+func (m *multiUpstreamProxy) registerSimpleHandler(id, path string, handler http.Handler) {
+	m.routes.addPath(id, path, handler)
 }
 
 // registerRewriteHandler ensures the handler is registered for all paths
@@ -120,35 +362,22 @@ func (m *multiUpstreamProxy) registerRewriteHandler(upstream options.Upstream, h
 		return fmt.Errorf("invalid path %q for upstream: %v", upstream.Path, err)
 	}
 
-	rewrite := newRewritePath(rewriteRegExp, upstream.RewriteTarget, writer)
+	rules, err := newRewriteRules(upstream.RewriteRules)
+	if err != nil {
+		return fmt.Errorf("invalid rewrite rules for upstream: %v", err)
+	}
+
+	rewrite := newRewritePath(rewriteRegExp, upstream.RewriteTarget, rules, writer)
 	h := alice.New(rewrite).Then(handler)
-	m.serveMux.MatcherFunc(func(req *http.Request, match *mux.RouteMatch) bool {
-		return rewriteRegExp.MatchString(req.URL.Path)
-	}).Handler(h)
+	m.routes.addRewrite(upstream.ID, rewriteRegExp, h)
 
 	return nil
 }
 
-// registerTrailingSlashHandler creates a new matcher that will check if the
-// requested path would match if it had a trailing slash appended.
-// If the path matches with a trailing slash, we send back a redirect.
-// This allows us to be consistent with the built in go servemux implementation.
-func registerTrailingSlashHandler(serveMux *mux.Router) {
-	serveMux.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
-		if strings.HasSuffix(req.URL.Path, "/") {
-			return false
-		}
-
-		// Use a separate RouteMatch so that we can redirect to the path + /.
-		// If we pass through the match then the matched backed will be served
-		// instead of the redirect handler.
-		m := &mux.RouteMatch{}
-		slashReq := req.Clone(context.Background())
-		slashReq.URL.Path += "/"
-		return serveMux.Match(slashReq, m)
-	}).Handler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		http.Redirect(rw, req, req.URL.String()+"/", http.StatusMovedPermanently)
-	}))
+// hasRewrite determines whether the upstream Path is a pattern used to
+// rewrite the request path.
+func hasRewrite(upstream options.Upstream) bool {
+	return upstream.RewriteTarget != "" || len(upstream.RewriteRules) > 0
 }
 
 // sortByPathLongest ensures that the upstreams are sorted by longest path.
@@ -159,18 +388,18 @@ func registerTrailingSlashHandler(serveMux *mux.Router) {
 // This should maintain the sorting behaviour of the standard go serve mux.
 func sortByPathLongest(in []options.Upstream) []options.Upstream {
 	sort.Slice(in, func(i, j int) bool {
-		iRW := in[i].RewriteTarget
-		jRW := in[j].RewriteTarget
+		iRW := hasRewrite(in[i])
+		jRW := hasRewrite(in[j])
 
 		switch {
-		case iRW != "" && jRW != "":
+		case iRW && jRW:
 			// If both have a rewrite target, whichever has the longest pattern
 			// should go first
 			return len(in[i].Path) > len(in[j].Path)
-		case iRW != "" && jRW == "":
+		case iRW && !jRW:
 			// Only one has rewrite, it goes first
 			return true
-		case iRW == "" && jRW != "":
+		case !iRW && jRW:
 			// Only one has rewrite, it goes first
 			return false
 		default:
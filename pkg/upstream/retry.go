@@ -0,0 +1,176 @@
+package upstream
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+const (
+	// retryBudgetWindow is the period over which the retry budget is
+	// calculated.
+	retryBudgetWindow = 10 * time.Second
+
+	// minRetriesPerWindow allows a small number of retries regardless of the
+	// budget so that upstreams with little traffic can still be retried.
+	minRetriesPerWindow = 3
+
+	// retryBackoff is the base duration to wait before retrying, it is
+	// doubled after each retry.
+	retryBackoff = 25 * time.Millisecond
+)
+
+// retryTransport retries idempotent requests that fail, within the limits of
+// the retry budget.
+type retryTransport struct {
+	transport http.RoundTripper
+	attempts  int
+	budget    *retryBudget
+}
+
+// newRetryTransport wraps the transport so that failed requests are retried.
+func newRetryTransport(opts *options.UpstreamRetries, transport http.RoundTripper) http.RoundTripper {
+	budgetPercent := opts.BudgetPercent
+	if budgetPercent <= 0 {
+		budgetPercent = options.DefaultRetryBudgetPercent
+	}
+
+	return &retryTransport{
+		transport: transport,
+		attempts:  opts.Attempts,
+		budget:    newRetryBudget(budgetPercent, time.Now),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.budget.recordRequest()
+
+	resp, err := t.transport.RoundTrip(req)
+	if !isRetryableRequest(req) {
+		return resp, err
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; attempt < t.attempts && shouldRetry(req, resp, err); attempt++ {
+		if !t.budget.allowRetry() {
+			break
+		}
+
+		// Discard the failed response before trying again
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		resp, err = t.transport.RoundTrip(req)
+	}
+
+	return resp, err
+}
+
+// isRetryableRequest determines whether the request can safely be sent to
+// the upstream more than once.
+func isRetryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry determines whether the result of a round trip should be
+// retried.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		// There is no point retrying a host that has been ejected
+		return !errors.Is(err, errHostEjected)
+	}
+	return isRetryableStatus(resp.StatusCode)
+}
+
+// isRetryableStatus determines whether the response status indicates the
+// upstream was unable to handle the request.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryBudget limits the number of retries to a percentage of the requests
+// made within the budget window.
+type retryBudget struct {
+	percent int
+	now     func() time.Time
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+// newRetryBudget creates a new retryBudget.
+func newRetryBudget(percent int, now func() time.Time) *retryBudget {
+	return &retryBudget{
+		percent:     percent,
+		now:         now,
+		windowStart: now(),
+	}
+}
+
+// recordRequest records a new request against the budget.
+func (b *retryBudget) recordRequest() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.resetExpiredWindow()
+	b.requests++
+}
+
+// allowRetry reserves a retry from the budget if one is available.
+func (b *retryBudget) allowRetry() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.resetExpiredWindow()
+	if b.retries >= minRetriesPerWindow && b.retries*100 >= b.requests*b.percent {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+// resetExpiredWindow starts a new budget window once the current window has
+// expired.
+// The caller must hold the mutex.
+func (b *retryBudget) resetExpiredWindow() {
+	now := b.now()
+	if now.Sub(b.windowStart) >= retryBudgetWindow {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
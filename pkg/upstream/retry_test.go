@@ -0,0 +1,164 @@
+package upstream
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRoundTripper returns the results it is given in order, repeating the
+// last result once exhausted.
+type fakeRoundTripper struct {
+	results []fakeRoundTripResult
+	calls   int
+}
+
+type fakeRoundTripResult struct {
+	status int
+	err    error
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	result := f.results[len(f.results)-1]
+	if f.calls < len(f.results) {
+		result = f.results[f.calls]
+	}
+	f.calls++
+
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &http.Response{
+		StatusCode: result.status,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+var _ = Describe("Retry Suite", func() {
+	type retryTableInput struct {
+		method         string
+		body           string
+		attempts       int
+		results        []fakeRoundTripResult
+		expectedStatus int
+		expectedErr    bool
+		expectedCalls  int
+	}
+
+	DescribeTable("RoundTrip",
+		func(in retryTableInput) {
+			fake := &fakeRoundTripper{results: in.results}
+			transport := newRetryTransport(&options.UpstreamRetries{Attempts: in.attempts, BudgetPercent: 100}, fake)
+
+			req, err := http.NewRequest(in.method, "http://upstream/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			if in.body != "" {
+				req.Body = ioutil.NopCloser(strings.NewReader(in.body))
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if in.expectedErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(in.expectedStatus))
+			}
+			Expect(fake.calls).To(Equal(in.expectedCalls))
+		},
+		Entry("with a successful request", retryTableInput{
+			method:         http.MethodGet,
+			attempts:       2,
+			results:        []fakeRoundTripResult{{status: http.StatusOK}},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+		}),
+		Entry("with a connection error followed by success", retryTableInput{
+			method:         http.MethodGet,
+			attempts:       2,
+			results:        []fakeRoundTripResult{{err: errors.New("connection refused")}, {status: http.StatusOK}},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+		}),
+		Entry("with a 503 followed by success", retryTableInput{
+			method:         http.MethodHead,
+			attempts:       2,
+			results:        []fakeRoundTripResult{{status: http.StatusServiceUnavailable}, {status: http.StatusOK}},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+		}),
+		Entry("with repeated failures", retryTableInput{
+			method:        http.MethodGet,
+			attempts:      2,
+			results:       []fakeRoundTripResult{{err: errors.New("connection refused")}},
+			expectedErr:   true,
+			expectedCalls: 3,
+		}),
+		Entry("with a non idempotent method", retryTableInput{
+			method:         http.MethodPost,
+			attempts:       2,
+			results:        []fakeRoundTripResult{{status: http.StatusBadGateway}, {status: http.StatusOK}},
+			expectedStatus: http.StatusBadGateway,
+			expectedCalls:  1,
+		}),
+		Entry("with a request body", retryTableInput{
+			method:         http.MethodDelete,
+			body:           "body",
+			attempts:       2,
+			results:        []fakeRoundTripResult{{status: http.StatusBadGateway}, {status: http.StatusOK}},
+			expectedStatus: http.StatusBadGateway,
+			expectedCalls:  1,
+		}),
+		Entry("with an ejected host", retryTableInput{
+			method:        http.MethodGet,
+			attempts:      2,
+			results:       []fakeRoundTripResult{{err: errHostEjected}},
+			expectedErr:   true,
+			expectedCalls: 1,
+		}),
+	)
+
+	Context("retryBudget", func() {
+		var now time.Time
+		var budget *retryBudget
+
+		BeforeEach(func() {
+			now = time.Now()
+			budget = newRetryBudget(20, func() time.Time { return now })
+		})
+
+		It("allows a minimum number of retries", func() {
+			for i := 0; i < minRetriesPerWindow; i++ {
+				Expect(budget.allowRetry()).To(BeTrue())
+			}
+			Expect(budget.allowRetry()).To(BeFalse())
+		})
+
+		It("allows retries up to the budget percentage", func() {
+			for i := 0; i < 50; i++ {
+				budget.recordRequest()
+			}
+			for i := 0; i < 10; i++ {
+				Expect(budget.allowRetry()).To(BeTrue())
+			}
+			Expect(budget.allowRetry()).To(BeFalse())
+		})
+
+		It("resets the budget after the window", func() {
+			for i := 0; i < minRetriesPerWindow; i++ {
+				Expect(budget.allowRetry()).To(BeTrue())
+			}
+			Expect(budget.allowRetry()).To(BeFalse())
+
+			now = now.Add(retryBudgetWindow)
+			Expect(budget.allowRetry()).To(BeTrue())
+		})
+	})
+})
@@ -9,42 +9,157 @@ import (
 
 	"github.com/justinas/alice"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
+// rewriteRule is a rewrite target that is used when the request headers match.
+type rewriteRule struct {
+	target  string
+	headers []rewriteHeaderMatcher
+}
+
+// rewriteHeaderMatcher matches the values of a request header.
+type rewriteHeaderMatcher struct {
+	name  string
+	value *regexp.Regexp
+}
+
+// newRewriteRules compiles the header matchers for the rewrite rules.
+func newRewriteRules(rules []options.RewriteRule) ([]rewriteRule, error) {
+	compiled := []rewriteRule{}
+	for _, rule := range rules {
+		r := rewriteRule{target: rule.Target}
+		for _, header := range rule.Headers {
+			value, err := regexp.Compile(header.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for header %q: %v", header.Value, header.Name, err)
+			}
+			r.headers = append(r.headers, rewriteHeaderMatcher{
+				name:  header.Name,
+				value: value,
+			})
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// match determines whether the request headers match the rule.
+// Any named groups captured from the header values are returned so that they
+// can be used in the rewrite target.
+func (r rewriteRule) match(req *http.Request) (map[string]string, bool) {
+	captures := map[string]string{}
+	for _, header := range r.headers {
+		if !header.match(req.Header.Values(header.name), captures) {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// match determines whether any of the header values match, recording the
+// named groups captured by the first matching value.
+// Values whose captured groups are not safe to use as a path segment do not
+// match.
+func (m rewriteHeaderMatcher) match(values []string, captures map[string]string) bool {
+	for _, value := range values {
+		submatches := m.value.FindStringSubmatch(value)
+		if submatches == nil || !safeCaptures(m.value.SubexpNames(), submatches) {
+			continue
+		}
+		for i, name := range m.value.SubexpNames() {
+			if name != "" {
+				captures[name] = submatches[i]
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// safeCaptures determines whether the named groups captured from a header
+// value can be used in the rewrite target without changing its structure.
+// Captures are rejected rather than escaped as the rewritten path is stored
+// unescaped, so a `/`, `?` or `#` would add path segments, a query or a
+// fragment, and a `.` or `..` segment would move up the path.
+func safeCaptures(names, submatches []string) bool {
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		value := submatches[i]
+		if strings.ContainsAny(value, "/?#") || value == "." || value == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// expandHeaderCaptures replaces references to the captured header groups in
+// the rewrite target.
+// Any `$` in the captured values is escaped so that it is not expanded again
+// by the path regexp.
+func expandHeaderCaptures(target string, captures map[string]string) string {
+	for name, value := range captures {
+		target = strings.ReplaceAll(target, "${"+name+"}", strings.ReplaceAll(value, "$", "$$"))
+	}
+	return target
+}
+
 // newRewritePath creates a new middleware that will rewrite the request URI
 // path before handing the request to the next server.
-func newRewritePath(rewriteRegExp *regexp.Regexp, rewriteTarget string, writer pagewriter.Writer) alice.Constructor {
+func newRewritePath(rewriteRegExp *regexp.Regexp, rewriteTarget string, rules []rewriteRule, writer pagewriter.Writer) alice.Constructor {
 	return func(next http.Handler) http.Handler {
-		return rewritePath(rewriteRegExp, rewriteTarget, writer, next)
+		return rewritePath(rewriteRegExp, rewriteTarget, rules, writer, next)
+	}
+}
+
+// rewriteTargetFor returns the rewrite target for the request.
+// The first matching rule takes precedence over the default rewrite target.
+func rewriteTargetFor(req *http.Request, rewriteTarget string, rules []rewriteRule) string {
+	for _, rule := range rules {
+		if captures, ok := rule.match(req); ok {
+			return expandHeaderCaptures(rule.target, captures)
+		}
 	}
+	return rewriteTarget
 }
 
 // rewritePath uses the regexp to rewrite the request URI based on the provided
-// rewriteTarget.
-func rewritePath(rewriteRegExp *regexp.Regexp, rewriteTarget string, writer pagewriter.Writer, next http.Handler) http.Handler {
+// rewriteTarget and rules.
+func rewritePath(rewriteRegExp *regexp.Regexp, rewriteTarget string, rules []rewriteRule, writer pagewriter.Writer, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		target := rewriteTargetFor(req, rewriteTarget, rules)
+		if target == "" {
+			// No rewrite applies to this request
+			next.ServeHTTP(rw, req)
+			return
+		}
+
 		reqURL, err := url.ParseRequestURI(req.RequestURI)
 		if err != nil {
 			logger.Errorf("could not parse request URI: %v", err)
 			writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
-				Status:    http.StatusInternalServerError,
-				RequestID: middleware.GetRequestScope(req).RequestID,
-				AppError:  fmt.Sprintf("Could not parse request URI: %v", err),
+				Status:         http.StatusInternalServerError,
+				RequestID:      middleware.GetRequestScope(req).RequestID,
+				AppError:       fmt.Sprintf("Could not parse request URI: %v", err),
+				AcceptLanguage: req.Header.Get("Accept-Language"),
 			})
 			return
 		}
 
 		// Use the regex to rewrite the request path before proxying to the upstream.
-		newURI := rewriteRegExp.ReplaceAllString(reqURL.Path, rewriteTarget)
+		newURI := rewriteRegExp.ReplaceAllString(reqURL.Path, target)
 		reqURL.Path, reqURL.RawQuery, err = splitPathAndQuery(reqURL.Query(), newURI)
 		if err != nil {
 			logger.Errorf("could not parse rewrite URI: %v", err)
 			writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
-				Status:    http.StatusInternalServerError,
-				RequestID: middleware.GetRequestScope(req).RequestID,
-				AppError:  fmt.Sprintf("Could not parse rewrite URI: %v", err),
+				Status:         http.StatusInternalServerError,
+				RequestID:      middleware.GetRequestScope(req).RequestID,
+				AppError:       fmt.Sprintf("Could not parse rewrite URI: %v", err),
+				AcceptLanguage: req.Header.Get("Accept-Language"),
 			})
 			return
 		}
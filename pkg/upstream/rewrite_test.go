@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"regexp"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -15,17 +16,25 @@ var _ = Describe("Rewrite", func() {
 	type rewritePathTableInput struct {
 		rewriteRegex       *regexp.Regexp
 		rewriteTarget      string
+		rewriteRules       []options.RewriteRule
 		requestTarget      string
+		requestHeaders     map[string]string
 		expectedRequestURI string
 	}
 
 	DescribeTable("should rewrite the request path",
 		func(in rewritePathTableInput) {
 			req := httptest.NewRequest("", in.requestTarget, nil)
+			for key, value := range in.requestHeaders {
+				req.Header.Set(key, value)
+			}
 			rw := httptest.NewRecorder()
 
+			rules, err := newRewriteRules(in.rewriteRules)
+			Expect(err).ToNot(HaveOccurred())
+
 			var gotRequestURI string
-			handler := newRewritePath(in.rewriteRegex, in.rewriteTarget, &pagewriter.WriterFuncs{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := newRewritePath(in.rewriteRegex, in.rewriteTarget, rules, &pagewriter.WriterFuncs{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				gotRequestURI = r.RequestURI
 			}))
 			handler.ServeHTTP(rw, req)
@@ -56,5 +65,82 @@ var _ = Describe("Rewrite", func() {
 			requestTarget:      "http://example.com/articles/blog-2021-01-01",
 			expectedRequestURI: "http://example.com/article?id=blog-2021-01-01",
 		}),
+		Entry("when the regexp has named capture groups", rewritePathTableInput{
+			rewriteRegex:       regexp.MustCompile(`^/users/(?P<user>[^/]+)/(?P<rest>.*)$`),
+			rewriteTarget:      "/${rest}?user=${user}",
+			requestTarget:      "http://example.com/users/john/profile",
+			expectedRequestURI: "http://example.com/profile?user=john",
+		}),
+		Entry("when a rewrite rule matches the request headers", rewritePathTableInput{
+			rewriteRegex:  regexp.MustCompile("^/api/(.*)"),
+			rewriteTarget: "/v1/$1",
+			rewriteRules: []options.RewriteRule{
+				{
+					Target: "/${version}/$1",
+					Headers: []options.RewriteHeaderMatch{
+						{Name: "X-Api-Version", Value: "^(?P<version>v[0-9]+)$"},
+					},
+				},
+			},
+			requestTarget:      "http://example.com/api/users",
+			requestHeaders:     map[string]string{"X-Api-Version": "v2"},
+			expectedRequestURI: "http://example.com/v2/users",
+		}),
+		Entry("when no rewrite rule matches the request headers", rewritePathTableInput{
+			rewriteRegex:  regexp.MustCompile("^/api/(.*)"),
+			rewriteTarget: "/v1/$1",
+			rewriteRules: []options.RewriteRule{
+				{
+					Target: "/${version}/$1",
+					Headers: []options.RewriteHeaderMatch{
+						{Name: "X-Api-Version", Value: "^(?P<version>v[0-9]+)$"},
+					},
+				},
+			},
+			requestTarget:      "http://example.com/api/users",
+			requestHeaders:     map[string]string{"X-Api-Version": "latest"},
+			expectedRequestURI: "http://example.com/v1/users",
+		}),
+		Entry("when a header capture would change the structure of the path", rewritePathTableInput{
+			rewriteRegex:  regexp.MustCompile("^/api/(.*)"),
+			rewriteTarget: "/v1/$1",
+			rewriteRules: []options.RewriteRule{
+				{
+					Target: "/tenants/${tenant}/$1",
+					Headers: []options.RewriteHeaderMatch{
+						{Name: "X-Tenant", Value: "^(?P<tenant>.+)$"},
+					},
+				},
+			},
+			requestTarget:      "http://example.com/api/users",
+			requestHeaders:     map[string]string{"X-Tenant": "../admin"},
+			expectedRequestURI: "http://example.com/v1/users",
+		}),
+		Entry("when a header capture is a parent path segment", rewritePathTableInput{
+			rewriteRegex:  regexp.MustCompile("^/api/(.*)"),
+			rewriteTarget: "/v1/$1",
+			rewriteRules: []options.RewriteRule{
+				{
+					Target: "/tenants/${tenant}/$1",
+					Headers: []options.RewriteHeaderMatch{
+						{Name: "X-Tenant", Value: "^(?P<tenant>.+)$"},
+					},
+				},
+			},
+			requestTarget:      "http://example.com/api/users",
+			requestHeaders:     map[string]string{"X-Tenant": ".."},
+			expectedRequestURI: "http://example.com/v1/users",
+		}),
+		Entry("when no rewrite rule matches and there is no rewrite target", rewritePathTableInput{
+			rewriteRegex: regexp.MustCompile("^/api/(.*)"),
+			rewriteRules: []options.RewriteRule{
+				{
+					Target:  "/beta/$1",
+					Headers: []options.RewriteHeaderMatch{{Name: "X-Beta"}},
+				},
+			},
+			requestTarget:      "http://example.com/api/users",
+			expectedRequestURI: "http://example.com/api/users",
+		}),
 	)
 })
@@ -0,0 +1,150 @@
+package upstream
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/matcher"
+)
+
+// route is the handler of an upstream registered in the routes.
+type route struct {
+	id      string
+	handler http.Handler
+}
+
+// routes matches requests to the upstreams by their paths.
+// Paths with a trailing `/` match all paths under that prefix, and are held
+// in a radix tree so that the longest match is found in a single pass over
+// the request path. The patterns of rewrites are matched as a set, in the
+// order they were added, before any other path.
+// This keeps the behaviour of the gorilla mux the upstreams were previously
+// registered with, without matching every request against every upstream.
+type routes struct {
+	// encodedPath matches the paths against the encoded request path.
+	encodedPath bool
+
+	exact        map[string]route
+	prefixes     matcher.PrefixTree
+	prefixRoutes []route
+
+	rewritePatterns []*regexp.Regexp
+	rewriteRoutes   []route
+	rewrites        *matcher.RegexpSet
+}
+
+// newRoutes creates empty routes, matching the paths against the encoded
+// request path when encodedPath is true.
+func newRoutes(encodedPath bool) *routes {
+	return &routes{
+		encodedPath: encodedPath,
+		exact:       make(map[string]route),
+	}
+}
+
+// addPath registers the handler for the path, or for every path under it
+// when it has a trailing `/`.
+func (r *routes) addPath(id, path string, handler http.Handler) {
+	if !strings.HasSuffix(path, "/") {
+		if _, ok := r.exact[path]; !ok {
+			r.exact[path] = route{id: id, handler: handler}
+		}
+		return
+	}
+	r.prefixes.Insert(path, len(r.prefixRoutes))
+	r.prefixRoutes = append(r.prefixRoutes, route{id: id, handler: handler})
+}
+
+// addRewrite registers the handler for the paths matching the pattern.
+// Patterns are matched in the order they were added, once the routes are
+// compiled.
+func (r *routes) addRewrite(id string, pattern *regexp.Regexp, handler http.Handler) {
+	r.rewritePatterns = append(r.rewritePatterns, pattern)
+	r.rewriteRoutes = append(r.rewriteRoutes, route{id: id, handler: handler})
+}
+
+// compile builds the set of the rewrite patterns. It must be called once all
+// the routes are added, and before any request is matched.
+func (r *routes) compile() {
+	r.rewrites = matcher.NewRegexpSet(r.rewritePatterns...)
+}
+
+// match returns the route matching the request URL.
+func (r *routes) match(u *url.URL) (route, bool) {
+	if i := r.rewrites.FirstMatch(u.Path); i >= 0 {
+		return r.rewriteRoutes[i], true
+	}
+
+	path := r.path(u)
+	if rt, ok := r.exact[path]; ok {
+		return rt, true
+	}
+	if values, ok := r.prefixes.LongestPrefix(path); ok {
+		return r.prefixRoutes[values[0]], true
+	}
+	return route{}, false
+}
+
+// matchWithTrailingSlash returns whether the request URL would match a route
+// if its path had a trailing slash appended.
+func (r *routes) matchWithTrailingSlash(u *url.URL) bool {
+	if strings.HasSuffix(u.Path, "/") {
+		return false
+	}
+	slashURL := *u
+	slashURL.Path += "/"
+	_, ok := r.match(&slashURL)
+	return ok
+}
+
+// path returns the path of the request URL that the paths are matched
+// against.
+func (r *routes) path(u *url.URL) string {
+	if r.encodedPath {
+		return u.EscapedPath()
+	}
+	return u.Path
+}
+
+// ServeHTTP serves the request with the handler of the route matching it.
+// Paths that are not clean are redirected to their clean form first, and
+// paths that would match with a trailing slash are redirected to it.
+func (r *routes) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	reqPath := r.path(req.URL)
+	if p := cleanPath(reqPath); p != reqPath {
+		u := *req.URL
+		u.Path = p
+		rw.Header().Set("Location", u.String())
+		rw.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+
+	if rt, ok := r.match(req.URL); ok {
+		rt.handler.ServeHTTP(rw, req)
+		return
+	}
+	if r.matchWithTrailingSlash(req.URL) {
+		http.Redirect(rw, req, req.URL.String()+"/", http.StatusMovedPermanently)
+		return
+	}
+	http.NotFound(rw, req)
+}
+
+// cleanPath returns the canonical form of the path, keeping its trailing
+// slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
@@ -0,0 +1,109 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+	newTestRoutes := func(encodedPath bool) *routes {
+		r := newRoutes(encodedPath)
+		r.addRewrite("rewrite-long", regexp.MustCompile("^/rewrite/long/(.*)"), http.NotFoundHandler())
+		r.addRewrite("rewrite", regexp.MustCompile("^/rewrite/(.*)"), http.NotFoundHandler())
+		r.addRewrite("suffix", regexp.MustCompile(`\.php$`), http.NotFoundHandler())
+		r.addPath("exact", "/foo/bar", http.NotFoundHandler())
+		r.addPath("prefix-long", "/foo/bar/", http.NotFoundHandler())
+		r.addPath("prefix", "/foo/", http.NotFoundHandler())
+		r.addPath("escaped", "/a%2Fb/", http.NotFoundHandler())
+		r.addPath("root", "/", http.NotFoundHandler())
+		r.compile()
+		return r
+	}
+
+	type matchTableInput struct {
+		encodedPath bool
+		rawURL      string
+		expectedID  string
+	}
+
+	DescribeTable("match",
+		func(in matchTableInput) {
+			u, err := url.Parse(in.rawURL)
+			Expect(err).ToNot(HaveOccurred())
+
+			rt, ok := newTestRoutes(in.encodedPath).match(u)
+			Expect(ok).To(BeTrue())
+			Expect(rt.id).To(Equal(in.expectedID))
+		},
+		Entry("matches an exact path before a prefix", matchTableInput{
+			rawURL:     "/foo/bar",
+			expectedID: "exact",
+		}),
+		Entry("matches the longest prefix", matchTableInput{
+			rawURL:     "/foo/bar/baz",
+			expectedID: "prefix-long",
+		}),
+		Entry("matches a shorter prefix", matchTableInput{
+			rawURL:     "/foo/baz",
+			expectedID: "prefix",
+		}),
+		Entry("falls back to the root prefix", matchTableInput{
+			rawURL:     "/other",
+			expectedID: "root",
+		}),
+		Entry("matches rewrites in the order they were added", matchTableInput{
+			rawURL:     "/rewrite/long/path",
+			expectedID: "rewrite-long",
+		}),
+		Entry("matches rewrites before paths", matchTableInput{
+			rawURL:     "/foo/index.php",
+			expectedID: "suffix",
+		}),
+		Entry("matches the decoded path", matchTableInput{
+			rawURL:     "/a%2Fb/c",
+			expectedID: "root",
+		}),
+		Entry("matches the encoded path", matchTableInput{
+			encodedPath: true,
+			rawURL:      "/a%2Fb/c",
+			expectedID:  "escaped",
+		}),
+	)
+
+	It("does not match paths without a route", func() {
+		r := newRoutes(false)
+		r.addPath("prefix", "/foo/", http.NotFoundHandler())
+		r.compile()
+
+		_, ok := r.match(&url.URL{Path: "/bar"})
+		Expect(ok).To(BeFalse())
+		Expect(r.matchWithTrailingSlash(&url.URL{Path: "/foo"})).To(BeTrue())
+		Expect(r.matchWithTrailingSlash(&url.URL{Path: "/bar"})).To(BeFalse())
+	})
+})
+
+func BenchmarkRoutes(b *testing.B) {
+	r := newRoutes(false)
+	for i := 0; i < 500; i++ {
+		r.addPath(fmt.Sprintf("service-%d", i), fmt.Sprintf("/service-%d/", i), http.NotFoundHandler())
+		r.addPath(fmt.Sprintf("exact-%d", i), fmt.Sprintf("/exact-%d", i), http.NotFoundHandler())
+	}
+	r.compile()
+
+	req := httptest.NewRequest("GET", "/service-499/resource", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := r.match(req.URL); !ok {
+			b.Fatal("expected a route to match")
+		}
+	}
+}
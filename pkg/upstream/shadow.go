@@ -0,0 +1,168 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// maxShadowBodySize is the largest request body that will be mirrored.
+	maxShadowBodySize = 1 << 20
+
+	// maxShadowRequests is the maximum number of mirrored requests in flight.
+	// Further requests are not mirrored until earlier ones complete.
+	maxShadowRequests = 100
+)
+
+// hopHeaders are the hop-by-hop headers that must not be forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// trafficShadow mirrors a percentage of requests to a shadow upstream.
+type trafficShadow struct {
+	upstream   string
+	target     *url.URL
+	percentage int
+	timeout    time.Duration
+	client     *http.Client
+	inFlight   chan struct{}
+	random     func() int
+}
+
+// newTrafficShadow creates a trafficShadow for the upstream.
+func newTrafficShadow(upstream options.Upstream) (*trafficShadow, error) {
+	target, err := url.Parse(upstream.Shadow.URI)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse shadow uri: %v", err)
+	}
+
+	tlsConfig, err := newUpstreamTLSConfig(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &trafficShadow{
+		upstream:   upstream.ID,
+		target:     target,
+		percentage: options.DefaultShadowPercentage,
+		timeout:    options.DefaultUpstreamTimeout,
+		client: &http.Client{
+			Transport: newHealthCheckTransport(tlsConfig, newUpstreamDialer(upstream, "")),
+			// Redirects are returned to the caller, which discards them
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		inFlight: make(chan struct{}, maxShadowRequests),
+		// Sampling does not require a secure random source
+		/* #nosec G404 */
+		random: func() int { return rand.Intn(100) },
+	}
+	if upstream.Shadow.Percentage != nil {
+		s.percentage = *upstream.Shadow.Percentage
+	}
+	if upstream.Shadow.Timeout != nil {
+		s.timeout = upstream.Shadow.Timeout.Duration()
+	}
+
+	return s, nil
+}
+
+// middleware creates a middleware that mirrors requests before passing them
+// to the next handler.
+func (s *trafficShadow) middleware() alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if s.random() < s.percentage && req.Header.Get("Upgrade") == "" {
+				s.mirror(req)
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// mirror sends a copy of the request to the shadow upstream in the
+// background.
+// The request body is buffered so that it can be read by both the shadow and
+// the next handler.
+func (s *trafficShadow) mirror(req *http.Request) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		buf, err := ioutil.ReadAll(io.LimitReader(req.Body, maxShadowBodySize+1))
+		// Restore the body for the next handler, including any unread data
+		req.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(buf), req.Body), Closer: req.Body}
+		if err != nil || len(buf) > maxShadowBodySize {
+			return
+		}
+		body = buf
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		logger.Errorf("Too many requests in flight to shadow of upstream %q, request not mirrored", s.upstream)
+		return
+	}
+
+	shadowReq := s.newRequest(req, body)
+	go func() {
+		defer func() { <-s.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+
+		resp, err := s.client.Do(shadowReq.WithContext(ctx))
+		if err != nil {
+			logger.Errorf("Error mirroring request to shadow of upstream %q: %v", s.upstream, err)
+			return
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+	}()
+}
+
+// newRequest creates the request sent to the shadow upstream.
+// The original request headers, including identity headers, are preserved.
+func (s *trafficShadow) newRequest(req *http.Request, body []byte) *http.Request {
+	shadowReq := req.Clone(context.Background())
+	shadowReq.RequestURI = ""
+	shadowReq.URL.Scheme = s.target.Scheme
+	shadowReq.URL.Host = s.target.Host
+	shadowReq.Host = s.target.Host
+	shadowReq.ContentLength = int64(len(body))
+	shadowReq.Body = http.NoBody
+	if len(body) > 0 {
+		shadowReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	for _, header := range hopHeaders {
+		shadowReq.Header.Del(header)
+	}
+	shadowReq.Header.Set("X-Forwarded-Host", req.Host)
+
+	return shadowReq
+}
+
+// readCloser combines a Reader with the Closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,91 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traffic Shadow Suite", func() {
+	type mirroredRequest struct {
+		method string
+		path   string
+		host   string
+		header http.Header
+		body   string
+	}
+
+	var server *httptest.Server
+	var mirrored chan mirroredRequest
+
+	BeforeEach(func() {
+		mirrored = make(chan mirroredRequest, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			mirrored <- mirroredRequest{
+				method: req.Method,
+				path:   req.URL.RequestURI(),
+				host:   req.Host,
+				header: req.Header,
+				body:   string(body),
+			}
+			rw.WriteHeader(http.StatusInternalServerError)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newShadowHandler := func(percentage int, next http.Handler) http.Handler {
+		shadow, err := newTrafficShadow(options.Upstream{
+			ID: "foo",
+			Shadow: &options.TrafficShadow{
+				URI:        server.URL,
+				Percentage: &percentage,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		shadow.random = func() int { return 49 }
+		return shadow.middleware()(next)
+	}
+
+	It("mirrors requests with identity headers intact", func() {
+		var primaryBody string
+		handler := newShadowHandler(50, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			primaryBody = string(body)
+			rw.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest("POST", "http://app.example.com/api/items?id=1", strings.NewReader("payload"))
+		req.Header.Set("X-Forwarded-User", "john")
+		req.Header.Set("Connection", "close")
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(primaryBody).To(Equal("payload"))
+
+		var got mirroredRequest
+		Eventually(mirrored).Should(Receive(&got))
+		Expect(got.method).To(Equal("POST"))
+		Expect(got.path).To(Equal("/api/items?id=1"))
+		Expect(got.host).To(Equal(strings.TrimPrefix(server.URL, "http://")))
+		Expect(got.header.Get("X-Forwarded-User")).To(Equal("john"))
+		Expect(got.header.Get("X-Forwarded-Host")).To(Equal("app.example.com"))
+		Expect(got.body).To(Equal("payload"))
+	})
+
+	It("only mirrors the configured percentage of requests", func() {
+		handler := newShadowHandler(49, http.NotFoundHandler())
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		Consistently(mirrored).ShouldNot(Receive())
+	})
+})
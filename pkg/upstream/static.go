@@ -1,14 +1,25 @@
 package upstream
 
 import (
+	"bytes"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	texttemplate "text/template"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
-const defaultStaticResponseCode = 200
+const (
+	defaultStaticResponseCode = 200
+
+	defaultStaticContentType = "text/plain; charset=utf-8"
+)
 
 // newStaticResponseHandler creates a new staticResponseHandler that serves a
 // a static response code.
@@ -39,6 +50,109 @@ func (s *staticResponseHandler) ServeHTTP(rw http.ResponseWriter, req *http.Requ
 	}
 }
 
+// staticTemplate is implemented by both text and html templates.
+type staticTemplate interface {
+	Execute(io.Writer, interface{}) error
+}
+
+// newStaticTemplateHandler creates a new staticTemplateHandler that serves a
+// static response code with a templated body.
+func newStaticTemplateHandler(upstream string, code *int, opts *options.StaticResponse) (http.Handler, error) {
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = defaultStaticContentType
+	}
+
+	var tmpl staticTemplate
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/html" {
+		tmpl, err = htmltemplate.New(upstream).Parse(opts.Template)
+	} else {
+		tmpl, err = texttemplate.New(upstream).Parse(opts.Template)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse static response template: %v", err)
+	}
+
+	return &staticTemplateHandler{
+		code:        derefStaticCode(code),
+		upstream:    upstream,
+		contentType: contentType,
+		tmpl:        tmpl,
+	}, nil
+}
+
+// staticTemplateHandler responds with the given response code and a body
+// rendered from the template.
+type staticTemplateHandler struct {
+	code        int
+	upstream    string
+	contentType string
+	tmpl        staticTemplate
+}
+
+// staticSessionData is the session information available to static response
+// templates.
+type staticSessionData struct {
+	User              string
+	Email             string
+	Groups            []string
+	PreferredUsername string
+}
+
+// staticRequestData is the request information available to static response
+// templates.
+type staticRequestData struct {
+	Method string
+	Host   string
+	Path   string
+	Query  url.Values
+	Header http.Header
+}
+
+// ServeHTTP renders the template and serves the static response.
+func (s *staticTemplateHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	scope := middleware.GetRequestScope(req)
+	// If scope is nil, this will panic.
+	// A scope should always be injected before this handler is called.
+	scope.Upstream = s.upstream
+
+	data := struct {
+		Session *staticSessionData
+		Request staticRequestData
+	}{
+		Request: staticRequestData{
+			Method: req.Method,
+			Host:   req.Host,
+			Path:   req.URL.Path,
+			Query:  req.URL.Query(),
+			Header: req.Header,
+		},
+	}
+	if session := scope.Session; session != nil {
+		data.Session = &staticSessionData{
+			User:              session.User,
+			Email:             session.Email,
+			Groups:            session.Groups,
+			PreferredUsername: session.PreferredUsername,
+		}
+	}
+
+	// Render the body first so that errors can be reported with a 500
+	var body bytes.Buffer
+	if err := s.tmpl.Execute(&body, data); err != nil {
+		logger.Errorf("Error rendering static response for upstream %q: %v", s.upstream, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", s.contentType)
+	rw.WriteHeader(s.code)
+	if _, err := rw.Write(body.Bytes()); err != nil {
+		logger.Errorf("Error writing static response: %v", err)
+	}
+}
+
 // derefStaticCode returns the derefenced value, or the default if the value is nil
 func derefStaticCode(code *int) int {
 	if code != nil {
@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -83,4 +85,63 @@ var _ = Describe("Static Response Suite", func() {
 			expectedCode: http.StatusTeapot,
 		}),
 	)
+
+	type templateTableInput struct {
+		template            string
+		contentType         string
+		session             *sessionsapi.SessionState
+		expectedBody        string
+		expectedContentType string
+		expectedCode        int
+	}
+
+	DescribeTable("staticTemplateHandler ServeHTTP",
+		func(in *templateTableInput) {
+			code := http.StatusAccepted
+			handler, err := newStaticTemplateHandler(id, &code, &options.StaticResponse{
+				Template:    in.template,
+				ContentType: in.contentType,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "http://example.com/whoami?foo=bar", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: in.session})
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			Expect(middlewareapi.GetRequestScope(req).Upstream).To(Equal(id))
+			Expect(rw.Code).To(Equal(in.expectedCode))
+			Expect(rw.Body.String()).To(Equal(in.expectedBody))
+			Expect(rw.Header().Get("Content-Type")).To(Equal(in.expectedContentType))
+		},
+		Entry("with session and request data", &templateTableInput{
+			template:            "{{ .Session.User }} {{ .Session.Email }} {{ .Request.Host }}{{ .Request.Path }} {{ .Request.Query.Get \"foo\" }}",
+			session:             &sessionsapi.SessionState{User: "john", Email: "john@example.com"},
+			expectedBody:        "john john@example.com example.com/whoami bar",
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedCode:        http.StatusAccepted,
+		}),
+		Entry("with an HTML content type", &templateTableInput{
+			template:            "<p>{{ .Session.User }}</p>",
+			contentType:         "text/html; charset=utf-8",
+			session:             &sessionsapi.SessionState{User: "<script>"},
+			expectedBody:        "<p>&lt;script&gt;</p>",
+			expectedContentType: "text/html; charset=utf-8",
+			expectedCode:        http.StatusAccepted,
+		}),
+		Entry("with no session", &templateTableInput{
+			template:            "{{ with .Session }}{{ .User }}{{ else }}anonymous{{ end }}",
+			contentType:         "application/json",
+			expectedBody:        "anonymous",
+			expectedContentType: "application/json",
+			expectedCode:        http.StatusAccepted,
+		}),
+		Entry("with a template that fails to render", &templateTableInput{
+			template:            "{{ .Session.User }}",
+			expectedBody:        "",
+			expectedContentType: "",
+			expectedCode:        http.StatusInternalServerError,
+		}),
+	)
 })
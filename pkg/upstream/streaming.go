@@ -0,0 +1,74 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// newStreamingHandler wraps the upstream handler so that streaming responses
+// are not buffered by proxies in front of OAuth2 Proxy and are closed when
+// the upstream stops sending data for longer than the idle timeout.
+func newStreamingHandler(opts *options.UpstreamStreaming, handler http.Handler) http.Handler {
+	s := &streamingHandler{
+		handler: handler,
+	}
+	if opts.IdleTimeout != nil {
+		s.idleTimeout = opts.IdleTimeout.Duration()
+	}
+	return s
+}
+
+// streamingHandler serves requests for streaming upstreams.
+type streamingHandler struct {
+	handler     http.Handler
+	idleTimeout time.Duration
+}
+
+// ServeHTTP proxies the request to the upstream, cancelling the request if
+// no data is written to the client within the idle timeout.
+func (s *streamingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// Prevent NGINX from buffering the response
+	// See https://www.nginx.com/resources/wiki/start/topics/examples/x-accel/#x-accel-buffering
+	rw.Header().Set("X-Accel-Buffering", "no")
+
+	if s.idleTimeout <= 0 {
+		s.handler.ServeHTTP(rw, req)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	timer := time.AfterFunc(s.idleTimeout, cancel)
+	defer timer.Stop()
+
+	s.handler.ServeHTTP(&idleTimeoutResponseWriter{
+		ResponseWriter: rw,
+		timer:          timer,
+		idleTimeout:    s.idleTimeout,
+	}, req.WithContext(ctx))
+}
+
+// idleTimeoutResponseWriter resets the idle timer every time data is written
+// to the client.
+type idleTimeoutResponseWriter struct {
+	http.ResponseWriter
+	timer       *time.Timer
+	idleTimeout time.Duration
+}
+
+// Write resets the idle timer and writes the data to the client.
+func (w *idleTimeoutResponseWriter) Write(b []byte) (int, error) {
+	w.timer.Reset(w.idleTimeout)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *idleTimeoutResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,79 @@
+package upstream
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streaming Upstream Suite", func() {
+	var eventServer, proxyServer *httptest.Server
+
+	BeforeEach(func() {
+		// Sends a single event and then holds the stream open until the client
+		// goes away.
+		eventServer = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", "application/octet-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.Write([]byte("data: hello\n"))
+			rw.(http.Flusher).Flush()
+			<-req.Context().Done()
+		}))
+
+		u, err := url.Parse(eventServer.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		flush := options.Duration(time.Hour)
+		idleTimeout := options.Duration(100 * time.Millisecond)
+		upstream := options.Upstream{
+			ID:            "events",
+			FlushInterval: &flush,
+			Streaming: &options.UpstreamStreaming{
+				IdleTimeout: &idleTimeout,
+			},
+		}
+
+		handler, err := newHTTPUpstreamProxy(upstream, u, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		proxyServer = httptest.NewServer(middleware.NewScope(false, "X-Request-Id")(handler))
+	})
+
+	AfterEach(func() {
+		proxyServer.Close()
+		eventServer.Close()
+	})
+
+	It("flushes data immediately and closes idle streams", func() {
+		resp, err := http.Get(proxyServer.URL)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("X-Accel-Buffering")).To(Equal("no"))
+
+		// The flush interval is an hour, so the event must have been flushed
+		// because the upstream is streaming
+		reader := bufio.NewReader(resp.Body)
+		line, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(line).To(Equal("data: hello\n"))
+
+		// The idle timeout should end the stream
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			_, err := reader.ReadString('\n')
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(done, 5*time.Second).Should(BeClosed())
+	})
+})
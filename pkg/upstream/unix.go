@@ -0,0 +1,42 @@
+package upstream
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+const unixScheme = "unix"
+
+// dialFunc establishes a connection to an upstream server.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newUpstreamDialer creates the dialFunc used to connect to the upstream
+// server.
+// When a socket path is given, every connection is made to the unix domain
+// socket regardless of the address being dialed.
+func newUpstreamDialer(upstream options.Upstream, socketPath string) dialFunc {
+	dialer := &net.Dialer{
+		Timeout:   options.DefaultUpstreamConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if upstream.ConnectTimeout != nil {
+		dialer.Timeout = upstream.ConnectTimeout.Duration()
+	}
+
+	if socketPath == "" {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, unixScheme, socketPath)
+	}
+}
+
+// unixSocketTarget returns the URL used to proxy HTTP requests over the
+// unix domain socket at the path of the given unix:// URL.
+func unixSocketTarget(u *url.URL) (*url.URL, string) {
+	return &url.URL{Scheme: httpScheme, Host: "localhost"}, u.Path
+}
@@ -0,0 +1,57 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unix Socket Upstream Suite", func() {
+	var dir string
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "oauth2-proxy-unix")
+		Expect(err).ToNot(HaveOccurred())
+
+		listener, err := net.Listen("unix", filepath.Join(dir, "app.sock"))
+		Expect(err).ToNot(HaveOccurred())
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(req.Method + " " + req.URL.RequestURI()))
+		}))
+		server.Listener = listener
+		server.Start()
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("proxies requests over the unix socket", func() {
+		u, err := url.Parse("unix://" + filepath.Join(dir, "app.sock"))
+		Expect(err).ToNot(HaveOccurred())
+
+		handler, err := newHTTPUpstreamProxy(options.Upstream{ID: "unix"}, u, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		req := httptest.NewRequest("GET", "/foo?bar=baz", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(Equal("GET /foo?bar=baz"))
+	})
+})
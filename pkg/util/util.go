@@ -10,6 +10,7 @@ import (
 	"math/big"
 	"net"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 )
@@ -149,3 +150,21 @@ func isHostnameAllowed(hostname, allowedHost string) bool {
 
 	return false
 }
+
+// IsOriginAllowed checks whether the request Origin matches one of the
+// allowed origins.
+// Allowed origins may be `*` to allow any origin or may contain wildcards,
+// eg `https://*.example.com`.
+func IsOriginAllowed(origin string, allowedOrigins []string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range allowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if matched, err := path.Match(allowed, origin); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
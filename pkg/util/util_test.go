@@ -236,3 +236,15 @@ func TestGetCertPool(t *testing.T) {
 	_, err3 := cert3.Verify(opts)
 	assert.Error(t, err3)
 }
+
+func TestIsOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.apps.example.com"}
+
+	assert.True(t, IsOriginAllowed("https://app.example.com", allowed))
+	assert.True(t, IsOriginAllowed("https://APP.example.com", allowed))
+	assert.True(t, IsOriginAllowed("https://foo.apps.example.com", allowed))
+	assert.False(t, IsOriginAllowed("http://app.example.com", allowed))
+	assert.False(t, IsOriginAllowed("https://app.example.com.evil.com", allowed))
+	assert.False(t, IsOriginAllowed("https://evil.com/.apps.example.com", allowed))
+	assert.True(t, IsOriginAllowed("https://evil.com", []string{"*"}))
+}
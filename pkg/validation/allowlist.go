@@ -2,7 +2,6 @@ package validation
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 
@@ -10,23 +9,6 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 )
 
-func validateAllowlists(o *options.Options) []string {
-	msgs := []string{}
-
-	msgs = append(msgs, validateAuthRoutes(o)...)
-	msgs = append(msgs, validateAuthRegexes(o)...)
-	msgs = append(msgs, validateTrustedIPs(o)...)
-
-	if len(o.TrustedIPs) > 0 && o.ReverseProxy {
-		_, err := fmt.Fprintln(os.Stderr, "WARNING: mixing --trusted-ip with --reverse-proxy is a potential security vulnerability. An attacker can inject a trusted IP into an X-Real-IP or X-Forwarded-For header if they aren't properly protected outside of oauth2-proxy")
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	return msgs
-}
-
 // validateAuthRoutes validates method=path routes passed with options.SkipAuthRoutes
 func validateAuthRoutes(o *options.Options) []string {
 	msgs := []string{}
@@ -67,6 +49,11 @@ func validateAPIRoutes(o *options.Options) []string {
 	return validateRegexes(o.APIRoutes)
 }
 
+// validateRedirectRoutes validates regex paths passed with options.RedirectRoutes
+func validateRedirectRoutes(o *options.Options) []string {
+	return validateRegexes(o.RedirectRoutes)
+}
+
 // validateRegexes validates all regexes and returns a list of messages in case of error
 func validateRegexes(regexes []string) []string {
 	msgs := []string{}
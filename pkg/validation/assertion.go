@@ -0,0 +1,20 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/assertion"
+)
+
+// validateJWTAssertion checks that the key signing the JWT assertions sent to
+// upstreams can be loaded.
+func validateJWTAssertion(o *options.Options) []string {
+	if o.JWTAssertionKeyFile == "" {
+		return nil
+	}
+	if _, err := assertion.LoadSigner(o.JWTAssertionKeyFile, o.JWTAssertionIssuer); err != nil {
+		return []string{fmt.Sprintf("invalid jwt_assertion_key_file: %v", err)}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJWTAssertion(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("not a key"), 0600))
+
+	o := testOptions()
+	assert.Empty(t, validateJWTAssertion(o))
+
+	o.JWTAssertionKeyFile = keyFile
+	assert.Empty(t, validateJWTAssertion(o))
+
+	o.JWTAssertionKeyFile = invalidFile
+	assert.Equal(t, []string{"invalid jwt_assertion_key_file: no PEM encoded private key found"}, validateJWTAssertion(o))
+
+	o.JWTAssertionKeyFile = filepath.Join(dir, "missing.pem")
+	msgs := validateJWTAssertion(o)
+	assert.Len(t, msgs, 1)
+	assert.Contains(t, msgs[0], "invalid jwt_assertion_key_file: could not read key file")
+}
@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateConsent checks that the consent terms can be read and have a
+// version, and that the accepted versions are persisted in redis.
+func validateConsent(o *options.Options) []string {
+	msgs := []string{}
+	if o.Consent.TermsFile == "" {
+		if o.Consent.Persist {
+			msgs = append(msgs, "consent_persist requires consent_terms_file")
+		}
+		return msgs
+	}
+
+	if _, err := os.Stat(o.Consent.TermsFile); err != nil {
+		msgs = append(msgs, fmt.Sprintf("consent_terms_file %q could not be read: %v", o.Consent.TermsFile, err))
+	}
+	if o.Consent.Version == "" {
+		msgs = append(msgs, "consent_version is required with consent_terms_file")
+	}
+	if o.Consent.Persist && o.Session.Type != options.RedisSessionStoreType {
+		msgs = append(msgs, "consent_persist requires the redis session store")
+	}
+	return msgs
+}
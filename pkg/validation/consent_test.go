@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Consent", func() {
+	var termsFile string
+
+	BeforeEach(func() {
+		f, err := ioutil.TempFile("", "oauth2-proxy-consent-test-*.html")
+		Expect(err).ToNot(HaveOccurred())
+		termsFile = f.Name()
+		Expect(f.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(termsFile)).To(Succeed())
+	})
+
+	type consentTableInput struct {
+		consent     options.Consent
+		sessionType string
+		errStrings  []string
+	}
+
+	DescribeTable("validateConsent",
+		func(in consentTableInput) {
+			if in.consent.TermsFile == "<terms-file>" {
+				in.consent.TermsFile = termsFile
+			}
+			o := &options.Options{Consent: in.consent}
+			o.Session.Type = in.sessionType
+			Expect(validateConsent(o)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without consent terms", consentTableInput{
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{},
+		}),
+		Entry("with consent terms", consentTableInput{
+			consent:     options.Consent{TermsFile: "<terms-file>", Version: "1"},
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{},
+		}),
+		Entry("with persisted consent terms in redis", consentTableInput{
+			consent:     options.Consent{TermsFile: "<terms-file>", Version: "1", Persist: true},
+			sessionType: options.RedisSessionStoreType,
+			errStrings:  []string{},
+		}),
+		Entry("without a version", consentTableInput{
+			consent:     options.Consent{TermsFile: "<terms-file>"},
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{"consent_version is required with consent_terms_file"},
+		}),
+		Entry("with a missing terms file", consentTableInput{
+			consent:     options.Consent{TermsFile: "/does/not/exist.html", Version: "1"},
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{`consent_terms_file "/does/not/exist.html" could not be read: stat /does/not/exist.html: no such file or directory`},
+		}),
+		Entry("with persisted consent terms in cookies", consentTableInput{
+			consent:     options.Consent{TermsFile: "<terms-file>", Version: "1", Persist: true},
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{"consent_persist requires the redis session store"},
+		}),
+		Entry("with persisted consent without terms", consentTableInput{
+			consent:     options.Consent{Persist: true},
+			sessionType: options.RedisSessionStoreType,
+			errStrings:  []string{"consent_persist requires consent_terms_file"},
+		}),
+	)
+})
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 )
 
@@ -25,15 +27,145 @@ func validateCookie(o options.Cookie) []string {
 		msgs = append(msgs, fmt.Sprintf("cookie_samesite (%q) must be one of ['', 'lax', 'strict', 'none']", o.SameSite))
 	}
 
+	switch o.Cipher {
+	case "", encryption.AESCFBAlgorithm, encryption.AESGCMAlgorithm, encryption.XChaCha20Poly1305Algorithm, encryption.AESGCMSIVAlgorithm:
+	default:
+		msgs = append(msgs, fmt.Sprintf("cookie_cipher (%q) must be one of %q", o.Cipher, encryption.CipherAlgorithms))
+	}
+	if o.CipherRejectLegacy && (o.Cipher == "" || o.Cipher == encryption.AESCFBAlgorithm) {
+		msgs = append(msgs, fmt.Sprintf("cookie_cipher_reject_legacy requires an authenticated cookie_cipher, not %q", encryption.AESCFBAlgorithm))
+	}
+
+	switch {
+	case o.CSRFPerRequestLimit < 0:
+		msgs = append(msgs, fmt.Sprintf("cookie_csrf_per_request_limit (%d) must not be negative", o.CSRFPerRequestLimit))
+	case o.CSRFCombined && o.CSRFPerRequestLimit > cookies.MaxCSRFCombinedLimit:
+		msgs = append(msgs, fmt.Sprintf("cookie_csrf_per_request_limit (%d) must be at most %d with cookie_csrf_combined", o.CSRFPerRequestLimit, cookies.MaxCSRFCombinedLimit))
+	}
+
+	switch o.Prefix {
+	case "", options.CookiePrefixHost, options.CookiePrefixSecure:
+	default:
+		msgs = append(msgs, fmt.Sprintf("cookie_prefix (%q) must be one of ['', '%s', '%s']", o.Prefix, options.CookiePrefixHost, options.CookiePrefixSecure))
+	}
+
 	// Sort cookie domains by length, so that we try longer (and more specific) domains first
 	sort.Slice(o.Domains, func(i, j int) bool {
 		return len(o.Domains[i]) > len(o.Domains[j])
 	})
 
 	msgs = append(msgs, validateCookieName(o.Name)...)
+	for _, name := range o.LegacyNames {
+		if name == o.Name {
+			msgs = append(msgs, fmt.Sprintf("cookie_legacy_names must not include the cookie_name (%q)", name))
+			continue
+		}
+		msgs = append(msgs, validateCookieName(name)...)
+	}
+	msgs = append(msgs, validateCookiePrefix(o)...)
+	msgs = append(msgs, validateCookiePolicies(o)...)
 	return msgs
 }
 
+// validateCookiePolicies checks that each cookie policy has hosts, and that
+// the attributes it sets are allowed with the SameSite attribute and prefix
+// of the cookies.
+func validateCookiePolicies(o options.Cookie) []string {
+	msgs := []string{}
+	for i, policy := range o.Policies {
+		if len(policy.Hosts) == 0 {
+			msgs = append(msgs, fmt.Sprintf("cookiePolicies[%d] must have at least one host", i))
+		}
+
+		sameSite, secure := o.SameSite, o.Secure
+		if policy.SameSite != "" {
+			sameSite = policy.SameSite
+		}
+		if policy.Secure != nil {
+			secure = *policy.Secure
+		}
+
+		switch policy.SameSite {
+		case "", "none", "lax", "strict":
+		default:
+			msgs = append(msgs, fmt.Sprintf("cookiePolicies[%d].sameSite (%q) must be one of ['lax', 'strict', 'none']", i, policy.SameSite))
+		}
+		if sameSite == "none" && !secure {
+			msgs = append(msgs, fmt.Sprintf("cookiePolicies[%d]: cookies with a SameSite attribute of none must be secure", i))
+		}
+		if o.Prefix != "" && !secure {
+			msgs = append(msgs, fmt.Sprintf("cookiePolicies[%d]: cookie_prefix requires secure cookies", i))
+		}
+	}
+	return msgs
+}
+
+// applyCookiePrefix adds the prefix selected by cookie_prefix to the cookie
+// name, replacing any other prefix, and sets the attributes it requires.
+// The previous name is not made a legacy name, as cookies with a name that
+// has no prefix can be set by other subdomains: it returns a warning for the
+// renamed cookie, as well as for the configured attributes that it overrides.
+func applyCookiePrefix(o *options.Cookie) []string {
+	var prefix string
+	switch o.Prefix {
+	case options.CookiePrefixHost:
+		prefix = "__Host-"
+	case options.CookiePrefixSecure:
+		prefix = "__Secure-"
+	default:
+		return nil
+	}
+
+	var warnings []string
+	if !strings.HasPrefix(o.Name, prefix) {
+		name := prefix + strings.TrimPrefix(strings.TrimPrefix(o.Name, "__Host-"), "__Secure-")
+		if isLegacyCookieName(o, o.Name) {
+			warnings = append(warnings, fmt.Sprintf("cookie_legacy_names include the cookie_name (%q) that is renamed %q for the %s prefix of cookie_prefix: remove it once existing sessions have been saved with the new name, as the legacy cookie can be set by other subdomains", o.Name, name, prefix))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("cookie_name (%q) is renamed %q for the %s prefix of cookie_prefix, which signs out existing sessions unless the previous name is given in cookie_legacy_names", o.Name, name, prefix))
+		}
+		o.Name = name
+	}
+	if !o.Secure {
+		warnings = append(warnings, fmt.Sprintf("cookie_secure is enabled for the %s prefix of cookie_prefix", prefix))
+		o.Secure = true
+	}
+	if o.Prefix == options.CookiePrefixHost {
+		if o.Path != "/" {
+			warnings = append(warnings, fmt.Sprintf("cookie_path (%q) is replaced by \"/\" for the __Host- prefix of cookie_prefix", o.Path))
+			o.Path = "/"
+		}
+		if len(o.Domains) > 0 {
+			warnings = append(warnings, fmt.Sprintf("cookie_domains (%q) are ignored for the __Host- prefix of cookie_prefix", o.Domains))
+			o.Domains = nil
+		}
+	}
+	return warnings
+}
+
+// isLegacyCookieName determines whether the name is one of the legacy names
+// of the cookie.
+func isLegacyCookieName(o *options.Cookie, name string) bool {
+	for _, legacyName := range o.LegacyNames {
+		if legacyName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCookiePrefix checks the cookie has the attributes required by
+// browsers for the prefix of its name, as cookies without them are rejected.
+func validateCookiePrefix(o options.Cookie) []string {
+	switch {
+	case strings.HasPrefix(o.Name, "__Host-") && (!o.Secure || o.Path != "/" || len(o.Domains) > 0):
+		return []string{fmt.Sprintf("cookie_name (%q) with the __Host- prefix requires cookie_secure, a cookie_path of \"/\" and no cookie_domains", o.Name)}
+	case strings.HasPrefix(o.Name, "__Secure-") && !o.Secure:
+		return []string{fmt.Sprintf("cookie_name (%q) with the __Secure- prefix requires cookie_secure", o.Name)}
+	}
+	return nil
+}
+
 func validateCookieName(name string) []string {
 	msgs := []string{}
 
@@ -48,12 +180,13 @@ func validateCookieName(name string) []string {
 	return msgs
 }
 
-func validateCookieSecret(secret string) []string {
-	if secret == "" {
+func validateCookieSecret(secret []byte) []string {
+	if len(secret) == 0 {
 		return []string{"missing setting: cookie-secret"}
 	}
 
 	secretBytes := encryption.SecretBytes(secret)
+	defer encryption.Wipe(secretBytes)
 	// Check if the secret is a valid length
 	switch len(secretBytes) {
 	case 16, 24, 32:
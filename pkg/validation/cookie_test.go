@@ -16,10 +16,10 @@ func TestValidateCookie(t *testing.T) {
 	invalidName := "_oauth2;proxy" // Separater character not allowed
 	// 10 times the alphabet should be longer than 256 characters
 	longName := strings.Repeat(alphabet, 10)
-	validSecret := "secretthirtytwobytes+abcdefghijk"
-	invalidSecret := "abcdef"                                          // 6 bytes is not a valid size
-	validBase64Secret := "c2VjcmV0dGhpcnR5dHdvYnl0ZXMrYWJjZGVmZ2hpams" // Base64 encoding of "secretthirtytwobytes+abcdefghijk"
-	invalidBase64Secret := "YWJjZGVmCg"                                // Base64 encoding of "abcdef"
+	validSecret := []byte("secretthirtytwobytes+abcdefghijk")
+	invalidSecret := []byte("abcdef")                                          // 6 bytes is not a valid size
+	validBase64Secret := []byte("c2VjcmV0dGhpcnR5dHdvYnl0ZXMrYWJjZGVmZ2hpams") // Base64 encoding of "secretthirtytwobytes+abcdefghijk"
+	invalidBase64Secret := []byte("YWJjZGVmCg")                                // Base64 encoding of "abcdef"
 	emptyDomains := []string{}
 	domains := []string{
 		"a.localhost",
@@ -61,7 +61,7 @@ func TestValidateCookie(t *testing.T) {
 			name: "with no cookie secret",
 			cookie: options.Cookie{
 				Name:     validName,
-				Secret:   "",
+				Secret:   nil,
 				Domains:  emptyDomains,
 				Path:     "",
 				Expire:   time.Hour,
@@ -236,6 +236,144 @@ func TestValidateCookie(t *testing.T) {
 				invalidSameSiteMsg,
 			},
 		},
+		{
+			name: "with the xchacha20-poly1305 cipher",
+			cookie: options.Cookie{
+				Name:    validName,
+				Secret:  validSecret,
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Cipher:  "xchacha20-poly1305",
+			},
+			errStrings: []string{},
+		},
+		{
+			name: "rejecting the legacy cipher",
+			cookie: options.Cookie{
+				Name:               validName,
+				Secret:             validSecret,
+				Expire:             time.Hour,
+				Refresh:            15 * time.Minute,
+				Cipher:             "aes-cfb",
+				CipherRejectLegacy: true,
+			},
+			errStrings: []string{
+				"cookie_cipher_reject_legacy requires an authenticated cookie_cipher, not \"aes-cfb\"",
+			},
+		},
+		{
+			name: "with an invalid cipher",
+			cookie: options.Cookie{
+				Name:    validName,
+				Secret:  validSecret,
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Cipher:  "aes-ecb",
+			},
+			errStrings: []string{
+				"cookie_cipher (\"aes-ecb\") must be one of [\"aes-cfb\" \"aes-gcm\" \"xchacha20-poly1305\" \"aes-gcm-siv\"]",
+			},
+		},
+		{
+			name: "with a __Host- prefixed name",
+			cookie: options.Cookie{
+				Name:    "__Host-oauth2_proxy",
+				Secret:  validSecret,
+				Path:    "/",
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Secure:  true,
+			},
+			errStrings: []string{},
+		},
+		{
+			name: "with a __Host- prefixed name and domains",
+			cookie: options.Cookie{
+				Name:    "__Host-oauth2_proxy",
+				Secret:  validSecret,
+				Domains: domains,
+				Path:    "/",
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Secure:  true,
+			},
+			errStrings: []string{
+				"cookie_name (\"__Host-oauth2_proxy\") with the __Host- prefix requires cookie_secure, a cookie_path of \"/\" and no cookie_domains",
+			},
+		},
+		{
+			name: "with an insecure __Secure- prefixed name",
+			cookie: options.Cookie{
+				Name:    "__Secure-oauth2_proxy",
+				Secret:  validSecret,
+				Path:    "/",
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Secure:  false,
+			},
+			errStrings: []string{
+				"cookie_name (\"__Secure-oauth2_proxy\") with the __Secure- prefix requires cookie_secure",
+			},
+		},
+		{
+			name: "with a negative CSRF per request limit",
+			cookie: options.Cookie{
+				Name:                validName,
+				Secret:              validSecret,
+				Path:                "/",
+				Expire:              time.Hour,
+				Refresh:             15 * time.Minute,
+				CSRFPerRequestLimit: -1,
+			},
+			errStrings: []string{
+				"cookie_csrf_per_request_limit (-1) must not be negative",
+			},
+		},
+		{
+			name: "with a CSRF per request limit too high for the combined cookie",
+			cookie: options.Cookie{
+				Name:                validName,
+				Secret:              validSecret,
+				Path:                "/",
+				Expire:              time.Hour,
+				Refresh:             15 * time.Minute,
+				CSRFPerRequestLimit: 20,
+				CSRFCombined:        true,
+			},
+			errStrings: []string{
+				"cookie_csrf_per_request_limit (20) must be at most 10 with cookie_csrf_combined",
+			},
+		},
+		{
+			name: "with invalid legacy names",
+			cookie: options.Cookie{
+				Name:        validName,
+				LegacyNames: []string{"_legacy_proxy", validName, "_invalid proxy"},
+				Secret:      validSecret,
+				Path:        "/",
+				Expire:      time.Hour,
+				Refresh:     15 * time.Minute,
+			},
+			errStrings: []string{
+				"cookie_legacy_names must not include the cookie_name (\"_oauth2_proxy\")",
+				"invalid cookie name: \"_invalid proxy\"",
+			},
+		},
+		{
+			name: "with an invalid prefix",
+			cookie: options.Cookie{
+				Name:    validName,
+				Secret:  validSecret,
+				Path:    "/",
+				Expire:  time.Hour,
+				Refresh: 15 * time.Minute,
+				Secure:  true,
+				Prefix:  "host-only",
+			},
+			errStrings: []string{
+				"cookie_prefix (\"host-only\") must be one of ['', 'host', 'secure']",
+			},
+		},
 		{
 			name: "with a combination of configuration errors",
 			cookie: options.Cookie{
@@ -271,3 +409,114 @@ func TestValidateCookie(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyCookiePrefix(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cookie   options.Cookie
+		expected options.Cookie
+		warnings []string
+	}{
+		{
+			name:     "without a prefix",
+			cookie:   options.Cookie{Name: "_oauth2_proxy", Path: "/app", Domains: []string{"example.com"}},
+			expected: options.Cookie{Name: "_oauth2_proxy", Path: "/app", Domains: []string{"example.com"}},
+		},
+		{
+			name:     "with the host prefix",
+			cookie:   options.Cookie{Name: "oauth2_proxy", Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			expected: options.Cookie{Name: "__Host-oauth2_proxy", Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			warnings: []string{
+				"cookie_name (\"oauth2_proxy\") is renamed \"__Host-oauth2_proxy\" for the __Host- prefix of cookie_prefix, which signs out existing sessions unless the previous name is given in cookie_legacy_names",
+			},
+		},
+		{
+			name:     "with the host prefix and the name as a legacy name",
+			cookie:   options.Cookie{Name: "oauth2_proxy", LegacyNames: []string{"oauth2_proxy"}, Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			expected: options.Cookie{Name: "__Host-oauth2_proxy", LegacyNames: []string{"oauth2_proxy"}, Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			warnings: []string{
+				"cookie_legacy_names include the cookie_name (\"oauth2_proxy\") that is renamed \"__Host-oauth2_proxy\" for the __Host- prefix of cookie_prefix: remove it once existing sessions have been saved with the new name, as the legacy cookie can be set by other subdomains",
+			},
+		},
+		{
+			name:     "with the host prefix and a prefixed name",
+			cookie:   options.Cookie{Name: "__Host-oauth2_proxy", Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			expected: options.Cookie{Name: "__Host-oauth2_proxy", Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+		},
+		{
+			name:     "with the host prefix and conflicting attributes",
+			cookie:   options.Cookie{Name: "__Secure-oauth2_proxy", Path: "/app", Domains: []string{"example.com"}, Prefix: options.CookiePrefixHost},
+			expected: options.Cookie{Name: "__Host-oauth2_proxy", Path: "/", Secure: true, Prefix: options.CookiePrefixHost},
+			warnings: []string{
+				"cookie_name (\"__Secure-oauth2_proxy\") is renamed \"__Host-oauth2_proxy\" for the __Host- prefix of cookie_prefix, which signs out existing sessions unless the previous name is given in cookie_legacy_names",
+				"cookie_secure is enabled for the __Host- prefix of cookie_prefix",
+				"cookie_path (\"/app\") is replaced by \"/\" for the __Host- prefix of cookie_prefix",
+				"cookie_domains ([\"example.com\"]) are ignored for the __Host- prefix of cookie_prefix",
+			},
+		},
+		{
+			name:     "with the secure prefix",
+			cookie:   options.Cookie{Name: "__Host-oauth2_proxy", Path: "/app", Domains: []string{"example.com"}, Prefix: options.CookiePrefixSecure},
+			expected: options.Cookie{Name: "__Secure-oauth2_proxy", Path: "/app", Domains: []string{"example.com"}, Secure: true, Prefix: options.CookiePrefixSecure},
+			warnings: []string{
+				"cookie_name (\"__Host-oauth2_proxy\") is renamed \"__Secure-oauth2_proxy\" for the __Secure- prefix of cookie_prefix, which signs out existing sessions unless the previous name is given in cookie_legacy_names",
+				"cookie_secure is enabled for the __Secure- prefix of cookie_prefix",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cookie := tc.cookie
+
+			g.Expect(applyCookiePrefix(&cookie)).To(Equal(tc.warnings))
+			g.Expect(cookie).To(Equal(tc.expected))
+			g.Expect(validateCookiePrefix(cookie)).To(BeEmpty())
+		})
+	}
+}
+
+func TestValidateCookiePolicies(t *testing.T) {
+	insecure := false
+	testCases := []struct {
+		name     string
+		cookie   options.Cookie
+		expected []string
+	}{
+		{
+			name: "with a policy for an embedded application",
+			cookie: options.Cookie{Secure: true, SameSite: "lax", Policies: []options.CookiePolicy{
+				{Hosts: []string{"embedded.example.com"}, SameSite: "none"},
+			}},
+			expected: []string{},
+		},
+		{
+			name: "with a policy without hosts and an invalid SameSite",
+			cookie: options.Cookie{Secure: true, Policies: []options.CookiePolicy{
+				{SameSite: "relaxed"},
+			}},
+			expected: []string{
+				"cookiePolicies[0] must have at least one host",
+				"cookiePolicies[0].sameSite (\"relaxed\") must be one of ['lax', 'strict', 'none']",
+			},
+		},
+		{
+			name: "with an insecure policy",
+			cookie: options.Cookie{Secure: true, SameSite: "none", Prefix: options.CookiePrefixSecure, Policies: []options.CookiePolicy{
+				{Hosts: []string{"*.example.com"}, Secure: &insecure},
+			}},
+			expected: []string{
+				"cookiePolicies[0]: cookies with a SameSite attribute of none must be secure",
+				"cookiePolicies[0]: cookie_prefix requires secure cookies",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(validateCookiePolicies(tc.cookie)).To(Equal(tc.expected))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mbland/hmacauth"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/fips"
+)
+
+// validateFIPS checks that only FIPS approved algorithms are configured when
+// the proxy runs in FIPS mode.
+func validateFIPS(o *options.Options) []string {
+	msgs := []string{}
+	if !fips.Enabled(o.FIPSMode) {
+		return msgs
+	}
+
+	msgs = append(msgs, validateFIPSCipherSuites("tls_cipher_suites", o.Server.TLS)...)
+	msgs = append(msgs, validateFIPSCipherSuites("metrics_tls_cipher_suites", o.MetricsServer.TLS)...)
+
+	if !encryption.IsFIPSApprovedCipher(o.Cookie.Cipher) {
+		msgs = append(msgs, fmt.Sprintf("cookie_cipher %q is not FIPS approved", o.Cookie.Cipher))
+	}
+
+	if components := strings.Split(o.SignatureKey, ":"); len(components) == 2 {
+		hash, err := hmacauth.DigestNameToCryptoHash(components[0])
+		if err == nil && !fips.IsApprovedHMACHash(hash) {
+			msgs = append(msgs, fmt.Sprintf("signature_key hash algorithm %q is not FIPS approved", components[0]))
+		}
+	}
+	return msgs
+}
+
+// validateFIPSCipherSuites checks that the cipher suites of the TLS options
+// are FIPS approved.
+func validateFIPSCipherSuites(name string, tls *options.TLS) []string {
+	msgs := []string{}
+	if tls == nil {
+		return msgs
+	}
+
+	for _, cipherSuite := range tls.CipherSuites {
+		if !fips.IsApprovedCipherSuite(cipherSuite) {
+			msgs = append(msgs, fmt.Sprintf("%s: cipher suite %q is not FIPS approved", name, cipherSuite))
+		}
+	}
+	return msgs
+}
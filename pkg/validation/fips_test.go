@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FIPS", func() {
+	DescribeTable("validateFIPS",
+		func(o *options.Options, errStrings []string) {
+			Expect(validateFIPS(o)).To(ConsistOf(errStrings))
+		},
+		Entry("with approved algorithms", &options.Options{
+			FIPSMode:     true,
+			SignatureKey: "sha256:secret",
+			Cookie:       options.Cookie{Cipher: "aes-gcm"},
+			Server: options.Server{
+				TLS: &options.TLS{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			},
+		}, []string{}),
+		Entry("with non-approved algorithms", &options.Options{
+			FIPSMode:     true,
+			SignatureKey: "md5:secret",
+			Cookie:       options.Cookie{Cipher: "xchacha20-poly1305"},
+			Server: options.Server{
+				TLS: &options.TLS{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}},
+			},
+			MetricsServer: options.Server{
+				TLS: &options.TLS{CipherSuites: []string{"TLS_RSA_WITH_AES_128_CBC_SHA"}},
+			},
+		}, []string{
+			`tls_cipher_suites: cipher suite "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256" is not FIPS approved`,
+			`metrics_tls_cipher_suites: cipher suite "TLS_RSA_WITH_AES_128_CBC_SHA" is not FIPS approved`,
+			`cookie_cipher "xchacha20-poly1305" is not FIPS approved`,
+			`signature_key hash algorithm "md5" is not FIPS approved`,
+		}),
+		Entry("with the AES-GCM-SIV cookie cipher", &options.Options{
+			FIPSMode: true,
+			Cookie:   options.Cookie{Cipher: "aes-gcm-siv"},
+		}, []string{
+			`cookie_cipher "aes-gcm-siv" is not FIPS approved`,
+		}),
+	)
+})
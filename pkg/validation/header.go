@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 )
 
 func validateHeaders(headers []options.Header) []string {
@@ -40,10 +41,12 @@ func validateHeader(header options.Header, names map[string]struct{}) []string {
 
 func validateHeaderValue(name string, value options.HeaderValue) []string {
 	switch {
-	case value.SecretSource != nil && value.ClaimSource == nil:
+	case value.SecretSource != nil && value.ClaimSource == nil && value.TemplateSource == nil:
 		return []string{validateSecretSource(*value.SecretSource)}
-	case value.SecretSource == nil && value.ClaimSource != nil:
+	case value.SecretSource == nil && value.ClaimSource != nil && value.TemplateSource == nil:
 		return validateHeaderValueClaimSource(*value.ClaimSource)
+	case value.SecretSource == nil && value.ClaimSource == nil && value.TemplateSource != nil:
+		return validateHeaderValueTemplateSource(*value.TemplateSource)
 	default:
 		return []string{"header value has multiple entries: only one entry per value is allowed"}
 	}
@@ -61,3 +64,14 @@ func validateHeaderValueClaimSource(claim options.ClaimSource) []string {
 	}
 	return msgs
 }
+
+func validateHeaderValueTemplateSource(source options.TemplateSource) []string {
+	if source.Template == "" {
+		return []string{"template should not be empty"}
+	}
+
+	if _, err := header.ParseValueTemplate(source.Template); err != nil {
+		return []string{fmt.Sprintf("invalid template: %v", err)}
+	}
+	return []string{}
+}
@@ -123,6 +123,52 @@ var _ = Describe("Headers", func() {
 				"invalid header \"Without-Claim\": invalid values: claim should not be empty",
 			},
 		}),
+		Entry("with a header with a claim and template source", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "With-Claim-And-Template",
+					Values: []options.HeaderValue{
+						{
+							ClaimSource:    &options.ClaimSource{Claim: "user"},
+							TemplateSource: &options.TemplateSource{Template: "{{ .User }}"},
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"With-Claim-And-Template\": invalid values: header value has multiple entries: only one entry per value is allowed",
+			},
+		}),
+		Entry("with a header with an empty template", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "With-Empty-Template",
+					Values: []options.HeaderValue{
+						{
+							TemplateSource: &options.TemplateSource{},
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"With-Empty-Template\": invalid values: template should not be empty",
+			},
+		}),
+		Entry("with a header with an invalid template", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "With-Invalid-Template",
+					Values: []options.HeaderValue{
+						{
+							TemplateSource: &options.TemplateSource{Template: "{{ unknown .User }}"},
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"With-Invalid-Template\": invalid values: invalid template: template: header:1: function \"unknown\" not defined",
+			},
+		}),
 		Entry("with a header with invalid secret source", validateHeaderTableInput{
 			headers: []options.Header{
 				{
@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateLoadShedding checks the concurrency limits of the proxy.
+func validateLoadShedding(o options.LoadShedding) []string {
+	msgs := []string{}
+	for name, value := range map[string]int{
+		"max_in_flight_requests":           o.MaxInFlightRequests,
+		"max_concurrent_refreshes":         o.MaxConcurrentRefreshes,
+		"max_concurrent_provider_requests": o.MaxConcurrentProviderRequests,
+	} {
+		if value < 0 {
+			msgs = append(msgs, fmt.Sprintf("%s must not be negative", name))
+		}
+	}
+	if o.QueueTimeout < 0 {
+		msgs = append(msgs, "concurrency_queue_timeout must not be negative")
+	}
+	return msgs
+}
@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LoadShedding", func() {
+	DescribeTable("validateLoadShedding",
+		func(o options.LoadShedding, errStrings []string) {
+			Expect(validateLoadShedding(o)).To(ConsistOf(errStrings))
+		},
+		Entry("with the default options", options.LoadShedding{
+			QueueTimeout: time.Second,
+		}, []string{}),
+		Entry("with limits", options.LoadShedding{
+			MaxInFlightRequests:           1000,
+			MaxConcurrentRefreshes:        50,
+			MaxConcurrentProviderRequests: 100,
+		}, []string{}),
+		Entry("with negative values", options.LoadShedding{
+			MaxInFlightRequests:           -1,
+			MaxConcurrentRefreshes:        -1,
+			MaxConcurrentProviderRequests: -1,
+			QueueTimeout:                  -1,
+		}, []string{
+			"max_in_flight_requests must not be negative",
+			"max_concurrent_refreshes must not be negative",
+			"max_concurrent_provider_requests must not be negative",
+			"concurrency_queue_timeout must not be negative",
+		}),
+	)
+})
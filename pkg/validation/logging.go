@@ -1,9 +1,12 @@
 package validation
 
 import (
+	"fmt"
 	"os"
+	"regexp"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -43,6 +46,50 @@ func configureLogger(o options.Logging, msgs []string) []string {
 		logger.Error("Warning: Logging disabled. No further logs will be shown.")
 	}
 
+	switch o.Format {
+	case logger.TextFormat, logger.JSONFormat:
+	default:
+		msgs = append(msgs, fmt.Sprintf("invalid logging format %q, must be one of: %s, %s", o.Format, logger.TextFormat, logger.JSONFormat))
+	}
+	if err := logger.ValidateFields(o.IncludeFields); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	if err := logger.ValidateFields(o.ExcludeFields); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+
+	level, err := logger.ParseLevel(o.Level)
+	if err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	if err := logger.ValidateComponents(o.DebugComponents); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+
+	sampleRates, err := logger.ParseSampleRates(o.SampleRates)
+	if err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	excludeRegexes := make([]*regexp.Regexp, 0, len(o.ExcludeRegexes))
+	for _, r := range o.ExcludeRegexes {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("error compiling exclude logging path regex /%s/: %v", r, err))
+			continue
+		}
+		excludeRegexes = append(excludeRegexes, re)
+	}
+
+	redactPatterns := make([]*regexp.Regexp, 0, len(o.RedactPatterns))
+	for _, r := range o.RedactPatterns {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("error compiling logging redact pattern /%s/: %v", r, err))
+			continue
+		}
+		redactPatterns = append(redactPatterns, re)
+	}
+
 	// Pass configuration values to the standard logger
 	logger.SetStandardEnabled(o.StandardEnabled)
 	logger.SetErrToInfo(o.ErrToInfo)
@@ -51,8 +98,21 @@ func configureLogger(o options.Logging, msgs []string) []string {
 	logger.SetStandardTemplate(o.StandardFormat)
 	logger.SetAuthTemplate(o.AuthFormat)
 	logger.SetReqTemplate(o.RequestFormat)
+	logger.SetFormat(o.Format)
+	logger.SetRedaction(o.RedactTokens, redactPatterns)
+	logger.SetFields(o.IncludeFields, o.ExcludeFields)
+	logger.SetLevel(level)
+	logger.SetDebugComponents(o.DebugComponents)
 
 	logger.SetExcludePaths(o.ExcludePaths)
+	logger.SetExcludePathRegexes(excludeRegexes)
+	logger.SetReqSampleRates(sampleRates)
+
+	// The sinks are only checked here, they are created once the
+	// configuration is served so that validating it does not send any events
+	for _, err := range audit.ValidateSinks(o) {
+		msgs = append(msgs, err.Error())
+	}
 
 	if !o.LocalTime {
 		logger.SetFlags(logger.Flags() | logger.LUTC)
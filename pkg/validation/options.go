@@ -10,6 +10,9 @@ import (
 
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/fips"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
@@ -19,15 +22,88 @@ import (
 // Validate checks that required options are set and validates those that they
 // are of the correct format
 func Validate(o *options.Options) error {
-	msgs := validateCookie(o.Cookie)
-	msgs = append(msgs, validateSessionCookieMinimal(o)...)
-	msgs = append(msgs, validateRedisSessionStore(o)...)
-	msgs = append(msgs, prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders)...)...)
-	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders)...)...)
-	msgs = append(msgs, validateProviders(o)...)
-	msgs = append(msgs, validateAPIRoutes(o)...)
-	msgs = configureLogger(o.Logging, msgs)
-	msgs = parseSignatureKey(o, msgs)
+	r := Check(o, true)
+	r.logWarnings()
+
+	if !r.Valid() {
+		return fmt.Errorf("invalid configuration:\n  %s",
+			strings.Join(r.errorMessages(), "\n  "))
+	}
+	return nil
+}
+
+// Check validates the options in the same way as Validate, reporting the
+// errors and warnings found along with the options they relate to.
+// Checks that connect to external services, such as the Redis session store
+// and the discovery of extra JWT issuers, are only made when connect is true.
+func Check(o *options.Options, connect bool) *Report {
+	r := validate(o, connect)
+	r.merge("", "", validateTenants(o, connect))
+	return r
+}
+
+// validate validates the options, other than the tenants, and sets the
+// values derived from them.
+func validate(o *options.Options, connect bool) *Report {
+	r := &Report{}
+	for _, msg := range applyCookiePrefix(&o.Cookie) {
+		r.addWarning("cookie_prefix", msg)
+	}
+	r.addErrors("cookie", validateCookie(o.Cookie)...)
+	r.addErrors("session_cookie_minimal", validateSessionCookieMinimal(o)...)
+	r.addErrors("session_cookie_overflow", validateSessionCookieOverflow(o)...)
+	r.addErrors("session_sign_out_everywhere", validateSignOutEverywhere(o)...)
+	if connect {
+		r.addErrors("redis", validateRedisSessionStore(o)...)
+	} else {
+		r.addErrors("redis", validateRedisSPIFFE(o)...)
+	}
+	r.addErrors("injectRequestHeaders", prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders)...)...)
+	r.addErrors("injectResponseHeaders", prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders)...)...)
+	r.addErrors("authResponseHeaders", prefixValues("authResponseHeaders: ", validateHeaders(o.AuthResponseHeaders)...)...)
+	r.addErrors("providers", validateProviders(o)...)
+	if o.Profile == options.ProfileStrict {
+		for _, provider := range o.Providers {
+			if provider.CodeChallengeMethod != encryption.CodeChallengeMethodS256 {
+				r.addWarning("providers", fmt.Sprintf("provider %q does not use the S256 code challenge method of the strict profile", provider.ID))
+			}
+		}
+	}
+	r.addErrors("api_routes", validateAPIRoutes(o)...)
+	r.addErrors("redirect_routes", validateRedirectRoutes(o)...)
+	r.addErrors("unauthenticated_response", validateUnauthenticatedResponse(o)...)
+	r.addErrors("auth_cache_ttl", validateAuthCache(o)...)
+	r.addErrors("provider_lookup_cache_ttl", validateProviderLookupCache(o)...)
+	if o.SessionValidationInterval < 0 {
+		r.addErrors("session_validation_interval", "session_validation_interval must not be negative")
+	}
+	if o.Session.ReplayCacheTTL < 0 {
+		r.addErrors("session_replay_cache_ttl", "session_replay_cache_ttl must not be negative")
+	}
+	if o.Session.ExpiryWarning < 0 {
+		r.addErrors("session_expiry_warning", "session_expiry_warning must not be negative")
+	}
+	r.addErrors("server", validateServer(o)...)
+	r.addErrors("metrics_server", validateMetricsServer(o.MetricsServer)...)
+	r.addErrors("logging", configureLogger(o.Logging, nil)...)
+	r.addErrors("statsd", validateStatsD(o.StatsD)...)
+	r.addErrors("provider_client", validateProviderClient(o.ProviderClient)...)
+	r.addErrors("load_shedding", validateLoadShedding(o.LoadShedding)...)
+	r.addErrors("sign_in_challenge", validateSignInChallenge(o)...)
+	r.addErrors("security_headers", validateSecurityHeaders(o.SecurityHeaders)...)
+	r.addErrors("templates", validateTemplates(o.Templates)...)
+	r.addErrors("silent_auth_origins", validateSilentAuthOrigins(o)...)
+	r.addErrors("consent", validateConsent(o)...)
+	r.addErrors("userInfo", validateUserInfo(o)...)
+	if o.SignatureKey != "" {
+		r.addWarning("signature_key", "`--signature-key` is deprecated. It will be removed in a future release")
+	}
+	r.addErrors("signature_key", parseSignatureKey(o, nil)...)
+	r.addErrors("jwt_assertion_key_file", validateJWTAssertion(o)...)
+	r.addErrors("fips_mode", validateFIPS(o)...)
+	if o.FIPSMode && !fips.ModuleEnabled() {
+		r.addWarning("fips_mode", "fips_mode only restricts the configured algorithms, the proxy is not running with a FIPS validated cryptographic module")
+	}
 
 	if o.SSLInsecureSkipVerify {
 		// InsecureSkipVerify is a configurable option we allow
@@ -47,47 +123,60 @@ func Validate(o *options.Options) error {
 
 			http.DefaultClient = &http.Client{Transport: transport}
 		} else {
-			msgs = append(msgs, fmt.Sprintf("unable to load provider CA file(s): %v", err))
+			r.addErrors("providers", fmt.Sprintf("unable to load provider CA file(s): %v", err))
 		}
 	}
 
 	if o.AuthenticatedEmailsFile == "" && len(o.EmailDomains) == 0 && o.HtpasswdFile == "" {
-		msgs = append(msgs, "missing setting for email validation: email-domain or authenticated-emails-file required."+
+		r.addErrors("email_domains", "missing setting for email validation: email-domain or authenticated-emails-file required."+
 			"\n      use email-domain=* to authorize all email addresses")
 	}
 
 	if o.SkipJwtBearerTokens {
 		// Configure extra issuers
 		if len(o.ExtraJwtIssuers) > 0 {
-			var jwtIssuers []jwtIssuer
-			jwtIssuers, msgs = parseJwtIssuers(o.ExtraJwtIssuers, msgs)
+			jwtIssuers, msgs := parseJwtIssuers(o.ExtraJwtIssuers, nil)
+			r.addErrors("extra_jwt_issuers", msgs...)
 			for _, jwtIssuer := range jwtIssuers {
+				if !connect {
+					// Building the verifier discovers the issuer
+					continue
+				}
 				verifier, err := newVerifierFromJwtIssuer(
 					o.Providers[0].OIDCConfig.AudienceClaims,
 					o.Providers[0].OIDCConfig.ExtraAudiences,
 					jwtIssuer,
 				)
 				if err != nil {
-					msgs = append(msgs, fmt.Sprintf("error building verifiers: %s", err))
+					r.addErrors("extra_jwt_issuers", fmt.Sprintf("error building verifiers: %s", err))
 				}
 				o.SetJWTBearerVerifiers(append(o.GetJWTBearerVerifiers(), verifier))
 			}
 		}
 	}
 
-	var redirectURL *url.URL
-	redirectURL, msgs = parseURL(o.RawRedirectURL, "redirect", msgs)
+	redirectURL, msgs := parseURL(o.RawRedirectURL, "redirect", nil)
+	r.addErrors("redirect_url", msgs...)
 	o.SetRedirectURL(redirectURL)
 	if o.RawRedirectURL == "" && !o.Cookie.Secure && !o.ReverseProxy {
-		logger.Print("WARNING: no explicit redirect URL: redirects will default to insecure HTTP")
+		r.addWarning("redirect_url", "no explicit redirect URL: redirects will default to insecure HTTP")
+	}
+	if _, err := redirect.NewCallbackURLs(o.RedirectURLHosts, ""); err != nil {
+		r.addErrors("redirect_url_hosts", err.Error())
+	}
+	if _, err := redirect.NewCallbackURLs(nil, o.RedirectURLTemplate); err != nil {
+		r.addErrors("redirect_url_template", err.Error())
+	}
+	if _, err := redirect.NewPolicyValidator(o.WhitelistDomains, o.RedirectPolicy); err != nil {
+		r.addErrors("redirectPolicy", err.Error())
 	}
 
-	msgs = append(msgs, validateUpstreams(o.UpstreamServers)...)
+	r.addErrors("upstreamConfig", validateUpstreams(o.UpstreamServers)...)
 
 	if o.ReverseProxy {
 		parser, err := ip.GetRealClientIPParser(o.RealClientIPHeader)
 		if err != nil {
-			msgs = append(msgs, fmt.Sprintf("real_client_ip_header (%s) not accepted parameter value: %v", o.RealClientIPHeader, err))
+			r.addErrors("real_client_ip_header", fmt.Sprintf("real_client_ip_header (%s) not accepted parameter value: %v", o.RealClientIPHeader, err))
 		}
 		o.SetRealClientIPParser(parser)
 
@@ -98,13 +187,45 @@ func Validate(o *options.Options) error {
 	}
 
 	// Do this after ReverseProxy validation for TrustedIP coordinated checks
-	msgs = append(msgs, validateAllowlists(o)...)
+	r.addErrors("skip_auth_routes", validateAuthRoutes(o)...)
+	r.addErrors("skip_auth_regex", validateAuthRegexes(o)...)
+	r.addErrors("trusted_ips", validateTrustedIPs(o)...)
+	if len(o.TrustedIPs) > 0 && o.ReverseProxy {
+		r.addWarning("trusted_ips", "mixing --trusted-ip with --reverse-proxy is a potential security vulnerability. An attacker can inject a trusted IP into an X-Real-IP or X-Forwarded-For header if they aren't properly protected outside of oauth2-proxy")
+	}
+	return r
+}
 
-	if len(msgs) != 0 {
-		return fmt.Errorf("invalid configuration:\n  %s",
-			strings.Join(msgs, "\n  "))
+func validateUnauthenticatedResponse(o *options.Options) []string {
+	switch o.UnauthenticatedResponse {
+	case "", options.UnauthenticatedResponseDefault, options.UnauthenticatedResponseNegotiate:
+		return []string{}
+	default:
+		return []string{fmt.Sprintf("unauthenticated_response (%s) must be one of %q or %q",
+			o.UnauthenticatedResponse, options.UnauthenticatedResponseDefault, options.UnauthenticatedResponseNegotiate)}
 	}
-	return nil
+}
+
+func validateAuthCache(o *options.Options) []string {
+	msgs := []string{}
+	if o.AuthCacheTTL < 0 {
+		msgs = append(msgs, "auth_cache_ttl must not be negative")
+	}
+	if o.AuthCacheTTL > 0 && o.AuthCacheMaxEntries < 1 {
+		msgs = append(msgs, "auth_cache_max_entries must be greater than 0 when auth_cache_ttl is set")
+	}
+	return msgs
+}
+
+func validateProviderLookupCache(o *options.Options) []string {
+	msgs := []string{}
+	if o.ProviderLookupCacheTTL < 0 {
+		msgs = append(msgs, "provider_lookup_cache_ttl must not be negative")
+	}
+	if o.ProviderLookupCacheTTL > 0 && o.ProviderLookupCacheMaxEntries < 1 {
+		msgs = append(msgs, "provider_lookup_cache_max_entries must be greater than 0 when provider_lookup_cache_ttl is set")
+	}
+	return msgs
 }
 
 func parseSignatureKey(o *options.Options, msgs []string) []string {
@@ -112,8 +233,6 @@ func parseSignatureKey(o *options.Options, msgs []string) []string {
 		return msgs
 	}
 
-	logger.Print("WARNING: `--signature-key` is deprecated. It will be removed in a future release")
-
 	components := strings.Split(o.SignatureKey, ":")
 	if len(components) != 2 {
 		return append(msgs, "invalid signature hash:key spec: "+
@@ -27,10 +27,10 @@ func testOptions() *options.Options {
 		Path: "/",
 		URI:  "http://127.0.0.1:8080/",
 	})
-	o.Cookie.Secret = cookieSecret
+	o.Cookie.Secret = []byte(cookieSecret)
 	o.Providers[0].ID = providerID
 	o.Providers[0].ClientID = clientID
-	o.Providers[0].ClientSecret = clientSecret
+	o.Providers[0].ClientSecret = []byte(clientSecret)
 	o.EmailDomains = []string{"*"}
 	return o
 }
@@ -102,7 +102,7 @@ func TestCookieRefreshMustBeLessThanCookieExpire(t *testing.T) {
 	o := testOptions()
 	assert.Equal(t, nil, Validate(o))
 
-	o.Cookie.Secret = "0123456789abcdef"
+	o.Cookie.Secret = []byte("0123456789abcdef")
 	o.Cookie.Refresh = o.Cookie.Expire
 	assert.NotEqual(t, nil, Validate(o))
 
@@ -115,23 +115,23 @@ func TestBase64CookieSecret(t *testing.T) {
 	assert.Equal(t, nil, Validate(o))
 
 	// 32 byte, base64 (urlsafe) encoded key
-	o.Cookie.Secret = "yHBw2lh2Cvo6aI_jn_qMTr-pRAjtq0nzVgDJNb36jgQ="
+	o.Cookie.Secret = []byte("yHBw2lh2Cvo6aI_jn_qMTr-pRAjtq0nzVgDJNb36jgQ=")
 	assert.Equal(t, nil, Validate(o))
 
 	// 32 byte, base64 (urlsafe) encoded key, w/o padding
-	o.Cookie.Secret = "yHBw2lh2Cvo6aI_jn_qMTr-pRAjtq0nzVgDJNb36jgQ"
+	o.Cookie.Secret = []byte("yHBw2lh2Cvo6aI_jn_qMTr-pRAjtq0nzVgDJNb36jgQ")
 	assert.Equal(t, nil, Validate(o))
 
 	// 24 byte, base64 (urlsafe) encoded key
-	o.Cookie.Secret = "Kp33Gj-GQmYtz4zZUyUDdqQKx5_Hgkv3"
+	o.Cookie.Secret = []byte("Kp33Gj-GQmYtz4zZUyUDdqQKx5_Hgkv3")
 	assert.Equal(t, nil, Validate(o))
 
 	// 16 byte, base64 (urlsafe) encoded key
-	o.Cookie.Secret = "LFEqZYvYUwKwzn0tEuTpLA=="
+	o.Cookie.Secret = []byte("LFEqZYvYUwKwzn0tEuTpLA==")
 	assert.Equal(t, nil, Validate(o))
 
 	// 16 byte, base64 (urlsafe) encoded key, w/o padding
-	o.Cookie.Secret = "LFEqZYvYUwKwzn0tEuTpLA"
+	o.Cookie.Secret = []byte("LFEqZYvYUwKwzn0tEuTpLA")
 	assert.Equal(t, nil, Validate(o))
 }
 
@@ -165,6 +165,122 @@ func TestGCPHealthcheck(t *testing.T) {
 	assert.Equal(t, nil, Validate(o))
 }
 
+func TestAuthCache(t *testing.T) {
+	o := testOptions()
+	o.AuthCacheTTL = 5 * time.Second
+	assert.Equal(t, nil, Validate(o))
+
+	o.AuthCacheMaxEntries = 0
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"auth_cache_max_entries must be greater than 0 when auth_cache_ttl is set",
+	})
+	assert.Equal(t, expected, err.Error())
+
+	o = testOptions()
+	o.AuthCacheTTL = -time.Second
+	err = Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected = errorMsg([]string{
+		"auth_cache_ttl must not be negative",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
+func TestProviderLookupCache(t *testing.T) {
+	o := testOptions()
+	o.ProviderLookupCacheTTL = 5 * time.Minute
+	assert.Equal(t, nil, Validate(o))
+
+	o.ProviderLookupCacheMaxEntries = 0
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"provider_lookup_cache_max_entries must be greater than 0 when provider_lookup_cache_ttl is set",
+	})
+	assert.Equal(t, expected, err.Error())
+
+	o = testOptions()
+	o.ProviderLookupCacheTTL = -time.Minute
+	err = Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected = errorMsg([]string{
+		"provider_lookup_cache_ttl must not be negative",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
+func TestSessionValidationInterval(t *testing.T) {
+	o := testOptions()
+	o.SessionValidationInterval = time.Minute
+	assert.Equal(t, nil, Validate(o))
+
+	o.SessionValidationInterval = -time.Minute
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"session_validation_interval must not be negative",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
+func TestSessionReplayCacheTTL(t *testing.T) {
+	o := testOptions()
+	o.Session.ReplayCacheTTL = 15 * time.Minute
+	assert.Equal(t, nil, Validate(o))
+
+	o.Session.ReplayCacheTTL = -time.Minute
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"session_replay_cache_ttl must not be negative",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
+func TestSessionExpiryWarning(t *testing.T) {
+	o := testOptions()
+	o.Session.ExpiryWarning = time.Minute
+	assert.Equal(t, nil, Validate(o))
+
+	o.Session.ExpiryWarning = -time.Minute
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"session_expiry_warning must not be negative",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
+func TestStrictProfileCodeChallengeMethod(t *testing.T) {
+	o := testOptions()
+	o.Profile = options.ProfileStrict
+	r := Check(o, false)
+	assert.True(t, r.Valid())
+	assert.Equal(t, []Issue{{
+		Path:    "providers",
+		Message: "provider \"providerID\" does not use the S256 code challenge method of the strict profile",
+	}}, r.Warnings)
+
+	o.Providers[0].CodeChallengeMethod = "S256"
+	assert.Empty(t, Check(o, false).Warnings)
+}
+
+func TestUnauthenticatedResponse(t *testing.T) {
+	o := testOptions()
+	o.UnauthenticatedResponse = options.UnauthenticatedResponseNegotiate
+	assert.Equal(t, nil, Validate(o))
+
+	o.UnauthenticatedResponse = "redirect"
+	err := Validate(o)
+	assert.NotEqual(t, nil, err)
+	expected := errorMsg([]string{
+		"unauthenticated_response (redirect) must be one of \"default\" or \"negotiate\"",
+	})
+	assert.Equal(t, expected, err.Error())
+}
+
 func TestRealClientIPHeader(t *testing.T) {
 	// Ensure nil if ReverseProxy not set.
 	o := testOptions()
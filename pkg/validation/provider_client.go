@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateProviderClient checks the options of the connections to the
+// providers.
+func validateProviderClient(o options.ProviderClient) []string {
+	msgs := []string{}
+	for name, value := range map[string]int{
+		"provider_max_idle_conns":          o.MaxIdleConns,
+		"provider_max_idle_conns_per_host": o.MaxIdleConnsPerHost,
+		"provider_max_conns_per_host":      o.MaxConnsPerHost,
+		"provider_tls_session_cache_size":  o.TLSSessionCacheSize,
+	} {
+		if value < 0 {
+			msgs = append(msgs, fmt.Sprintf("%s must not be negative", name))
+		}
+	}
+	for name, value := range map[string]time.Duration{
+		"provider_idle_conn_timeout":       o.IdleConnTimeout,
+		"provider_dial_timeout":            o.DialTimeout,
+		"provider_tls_handshake_timeout":   o.TLSHandshakeTimeout,
+		"provider_response_header_timeout": o.ResponseHeaderTimeout,
+		"provider_request_timeout":         o.RequestTimeout,
+	} {
+		if value < 0 {
+			msgs = append(msgs, fmt.Sprintf("%s must not be negative", name))
+		}
+	}
+	if o.MaxConnsPerHost > 0 && o.MaxIdleConnsPerHost > o.MaxConnsPerHost {
+		msgs = append(msgs, "provider_max_idle_conns_per_host must not be greater than provider_max_conns_per_host")
+	}
+	return msgs
+}
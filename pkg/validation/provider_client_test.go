@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProviderClient", func() {
+	DescribeTable("validateProviderClient",
+		func(o options.ProviderClient, errStrings []string) {
+			Expect(validateProviderClient(o)).To(ConsistOf(errStrings))
+		},
+		Entry("with the default options", options.ProviderClient{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			KeepAlive:           30 * time.Second,
+			TLSSessionCacheSize: 64,
+			DialTimeout:         30 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}, []string{}),
+		Entry("with keep-alive probes disabled", options.ProviderClient{
+			KeepAlive: -1,
+		}, []string{}),
+		Entry("with more idle connections than connections per host", options.ProviderClient{
+			MaxIdleConnsPerHost: 10,
+			MaxConnsPerHost:     5,
+		}, []string{"provider_max_idle_conns_per_host must not be greater than provider_max_conns_per_host"}),
+		Entry("with negative values", options.ProviderClient{
+			MaxIdleConns:          -1,
+			MaxIdleConnsPerHost:   -1,
+			MaxConnsPerHost:       -1,
+			TLSSessionCacheSize:   -1,
+			IdleConnTimeout:       -1,
+			DialTimeout:           -1,
+			TLSHandshakeTimeout:   -1,
+			ResponseHeaderTimeout: -1,
+			RequestTimeout:        -1,
+		}, []string{
+			"provider_max_idle_conns must not be negative",
+			"provider_max_idle_conns_per_host must not be negative",
+			"provider_max_conns_per_host must not be negative",
+			"provider_tls_session_cache_size must not be negative",
+			"provider_idle_conn_timeout must not be negative",
+			"provider_dial_timeout must not be negative",
+			"provider_tls_handshake_timeout must not be negative",
+			"provider_response_header_timeout must not be negative",
+			"provider_request_timeout must not be negative",
+		}),
+	)
+})
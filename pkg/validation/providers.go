@@ -3,6 +3,7 @@ package validation
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -49,10 +50,10 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 
 	// login.gov uses a signed JWT to authenticate, not a client-secret
 	if provider.Type != "login.gov" {
-		if provider.ClientSecret == "" && provider.ClientSecretFile == "" {
+		if len(provider.ClientSecret) == 0 && provider.ClientSecretFile == "" {
 			msgs = append(msgs, "missing setting: client-secret or client-secret-file")
 		}
-		if provider.ClientSecret == "" && provider.ClientSecretFile != "" {
+		if len(provider.ClientSecret) == 0 && provider.ClientSecretFile != "" {
 			_, err := ioutil.ReadFile(provider.ClientSecretFile)
 			if err != nil {
 				msgs = append(msgs, "could not read client secret file: "+provider.ClientSecretFile)
@@ -61,10 +62,28 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 	}
 
 	msgs = append(msgs, validateGoogleConfig(provider)...)
+	msgs = append(msgs, validateLogoutConfig(provider)...)
 
 	return msgs
 }
 
+func validateLogoutConfig(provider options.Provider) []string {
+	msgs := []string{}
+	if provider.LogoutURL != "" {
+		u, err := url.Parse(provider.LogoutURL)
+		if err != nil || !u.IsAbs() {
+			msgs = append(msgs, fmt.Sprintf("invalid setting: logout-url %q must be an absolute URL", provider.LogoutURL))
+		}
+	}
+
+	// Without discovery the end session endpoint has to be configured
+	discovery := provider.OIDCConfig.IssuerURL != "" && !provider.OIDCConfig.SkipDiscovery
+	if provider.RPInitiatedLogout && provider.LogoutURL == "" && !discovery {
+		msgs = append(msgs, "missing setting: logout-url is required for rp-initiated-logout without OIDC discovery")
+	}
+	return msgs
+}
+
 func validateGoogleConfig(provider options.Provider) []string {
 	msgs := []string{}
 	if len(provider.GoogleConfig.Groups) > 0 ||
@@ -16,25 +16,33 @@ var _ = Describe("Providers", func() {
 	validProvider := options.Provider{
 		ID:           "ProviderID",
 		ClientID:     "ClientID",
-		ClientSecret: "ClientSecret",
+		ClientSecret: []byte("ClientSecret"),
 	}
 
 	validLoginGovProvider := options.Provider{
 		Type:         "login.gov",
 		ID:           "ProviderIDLoginGov",
 		ClientID:     "ClientID",
-		ClientSecret: "ClientSecret",
+		ClientSecret: []byte("ClientSecret"),
 	}
 
 	missingIDProvider := options.Provider{
 		ClientID:     "ClientID",
-		ClientSecret: "ClientSecret",
+		ClientSecret: []byte("ClientSecret"),
 	}
 
 	missingProvider := "at least one provider has to be defined"
 	emptyIDMsg := "provider has empty id: ids are required for all providers"
 	duplicateProviderIDMsg := "multiple providers found with id ProviderID: provider ids must be unique"
 	skipButtonAndMultipleProvidersMsg := "SkipProviderButton and multiple providers are mutually exclusive"
+	relativeLogoutURLMsg := "invalid setting: logout-url \"/logout\" must be an absolute URL"
+	missingLogoutURLMsg := "missing setting: logout-url is required for rp-initiated-logout without OIDC discovery"
+
+	withLogout := func(provider options.Provider, rpInitiatedLogout bool, logoutURL string) options.Provider {
+		provider.RPInitiatedLogout = rpInitiatedLogout
+		provider.LogoutURL = logoutURL
+		return provider
+	}
 
 	DescribeTable("validateProviders",
 		func(o *validateProvidersTableInput) {
@@ -80,5 +88,41 @@ var _ = Describe("Providers", func() {
 			},
 			errStrings: []string{skipButtonAndMultipleProvidersMsg},
 		}),
+		Entry("with rp-initiated logout and a logout URL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					withLogout(validProvider, true, "https://idp.example.com/logout"),
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a relative logout URL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					withLogout(validProvider, true, "/logout"),
+				},
+			},
+			errStrings: []string{relativeLogoutURLMsg},
+		}),
+		Entry("with rp-initiated logout and no logout URL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					withLogout(validProvider, true, ""),
+				},
+			},
+			errStrings: []string{missingLogoutURLMsg},
+		}),
+		Entry("with rp-initiated logout and a discovered logout URL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					func() options.Provider {
+						p := withLogout(validProvider, true, "")
+						p.OIDCConfig.IssuerURL = "https://idp.example.com"
+						return p
+					}(),
+				},
+			},
+			errStrings: []string{},
+		}),
 	)
 })
@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// Issue is an error or warning found when validating the configuration.
+type Issue struct {
+	// Path is the option, or group of options, that the issue relates to.
+	// Legacy options are named by their config file key and alpha options
+	// by their path in the alpha config, for example
+	// `upstreamConfig.upstreams`.
+	Path string `json:"path,omitempty"`
+
+	// Message describes the issue.
+	Message string `json:"message"`
+}
+
+// String formats the issue for display.
+func (i Issue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// Report contains the errors and warnings found when validating the
+// configuration. The configuration is invalid when there are any errors.
+type Report struct {
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+}
+
+// Valid determines whether no errors were found.
+func (r *Report) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// addErrors records each non empty message as an error of the option path.
+func (r *Report) addErrors(path string, msgs ...string) {
+	for _, msg := range msgs {
+		if msg != "" {
+			r.Errors = append(r.Errors, Issue{Path: path, Message: msg})
+		}
+	}
+}
+
+// addWarning records the message as a warning of the option path.
+func (r *Report) addWarning(path, msg string) {
+	r.Warnings = append(r.Warnings, Issue{Path: path, Message: msg})
+}
+
+// merge appends the issues of the other report, joining their paths to the
+// path given and prefixing their messages.
+func (r *Report) merge(path, prefix string, other *Report) {
+	for _, issue := range other.Errors {
+		r.Errors = append(r.Errors, Issue{Path: joinPath(path, issue.Path), Message: prefix + issue.Message})
+	}
+	for _, issue := range other.Warnings {
+		r.Warnings = append(r.Warnings, Issue{Path: joinPath(path, issue.Path), Message: prefix + issue.Message})
+	}
+}
+
+// errorMessages returns the messages of the errors.
+func (r *Report) errorMessages() []string {
+	msgs := []string{}
+	for _, issue := range r.Errors {
+		msgs = append(msgs, issue.Message)
+	}
+	return msgs
+}
+
+// logWarnings logs each of the warnings.
+func (r *Report) logWarnings() {
+	for _, issue := range r.Warnings {
+		logger.Printf("WARNING: %s", issue.Message)
+	}
+}
+
+func joinPath(parent, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	default:
+		return parent + "." + child
+	}
+}
@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// minHSTSPreloadMaxAge is the minimum max-age accepted by the HSTS preload
+// lists of browsers.
+const minHSTSPreloadMaxAge = 365 * 24 * time.Hour
+
+// validateSecurityHeaders checks the HSTS directives and the values of the
+// cross-origin policies.
+func validateSecurityHeaders(opts options.SecurityHeaders) []string {
+	msgs := []string{}
+
+	switch {
+	case opts.HSTSMaxAge < 0:
+		msgs = append(msgs, "hsts_max_age must not be negative")
+	case opts.HSTSMaxAge == 0 && (opts.HSTSIncludeSubdomains || opts.HSTSPreload):
+		msgs = append(msgs, "hsts_include_subdomains and hsts_preload require hsts_max_age")
+	case opts.HSTSPreload && (opts.HSTSMaxAge < minHSTSPreloadMaxAge || !opts.HSTSIncludeSubdomains):
+		msgs = append(msgs, fmt.Sprintf("hsts_preload requires hsts_include_subdomains and an hsts_max_age of at least %s", minHSTSPreloadMaxAge))
+	}
+
+	switch opts.CrossOriginOpenerPolicy {
+	case "", "unsafe-none", "same-origin-allow-popups", "same-origin", "noopener-allow-popups":
+	default:
+		msgs = append(msgs, fmt.Sprintf("cross_origin_opener_policy (%q) must be one of ['unsafe-none', 'same-origin-allow-popups', 'same-origin', 'noopener-allow-popups']", opts.CrossOriginOpenerPolicy))
+	}
+
+	switch opts.CrossOriginEmbedderPolicy {
+	case "", "unsafe-none", "require-corp", "credentialless":
+	default:
+		msgs = append(msgs, fmt.Sprintf("cross_origin_embedder_policy (%q) must be one of ['unsafe-none', 'require-corp', 'credentialless']", opts.CrossOriginEmbedderPolicy))
+	}
+	return msgs
+}
@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Security Headers", func() {
+	DescribeTable("validateSecurityHeaders",
+		func(o options.SecurityHeaders, errStrings []string) {
+			Expect(validateSecurityHeaders(o)).To(ConsistOf(errStrings))
+		},
+		Entry("without security headers", options.SecurityHeaders{}, []string{}),
+		Entry("with preloaded HSTS and cross-origin isolation", options.SecurityHeaders{
+			HSTSMaxAge:                2 * 365 * 24 * time.Hour,
+			HSTSIncludeSubdomains:     true,
+			HSTSPreload:               true,
+			CrossOriginOpenerPolicy:   "same-origin",
+			CrossOriginEmbedderPolicy: "require-corp",
+		}, []string{}),
+		Entry("with HSTS directives without a max age", options.SecurityHeaders{
+			HSTSIncludeSubdomains: true,
+		}, []string{"hsts_include_subdomains and hsts_preload require hsts_max_age"}),
+		Entry("with a short preloaded HSTS max age", options.SecurityHeaders{
+			HSTSMaxAge:            24 * time.Hour,
+			HSTSIncludeSubdomains: true,
+			HSTSPreload:           true,
+		}, []string{"hsts_preload requires hsts_include_subdomains and an hsts_max_age of at least 8760h0m0s"}),
+		Entry("with invalid cross-origin policies", options.SecurityHeaders{
+			CrossOriginOpenerPolicy:   "same-site",
+			CrossOriginEmbedderPolicy: "require-cors",
+		}, []string{
+			`cross_origin_opener_policy ("same-site") must be one of ['unsafe-none', 'same-origin-allow-popups', 'same-origin', 'noopener-allow-popups']`,
+			`cross_origin_embedder_policy ("require-cors") must be one of ['unsafe-none', 'require-corp', 'credentialless']`,
+		}),
+	)
+})
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateServer checks the TLS and ACME configuration of the app server.
+func validateServer(o *options.Options) []string {
+	msgs := validateServerTLS(o.Server.TLS)
+	msgs = append(msgs, validateACME(o)...)
+	msgs = append(msgs, validateClientCertificateSessions(o)...)
+	if o.Server.BearerToken != nil {
+		msgs = append(msgs, "a bearer token is only supported by the metrics server")
+	}
+	return msgs
+}
+
+// validateMetricsServer checks that the secure metrics server has a
+// certificate, and that client certificates are only required by it.
+func validateMetricsServer(server options.Server) []string {
+	msgs := []string{}
+	secure := server.SecureBindAddress != "" && server.SecureBindAddress != "-"
+
+	if secure && (server.TLS == nil || server.TLS.Key == nil || server.TLS.Cert == nil) {
+		msgs = append(msgs, "metrics_secure_address requires a TLS key and certificate (metrics_tls_key_file and metrics_tls_cert_file)")
+	}
+	if !secure && server.TLS != nil && server.TLS.ClientCA != nil {
+		msgs = append(msgs, "a metrics client CA (metrics_tls_client_ca_file) requires metrics_secure_address")
+	}
+	if server.ACME != nil {
+		msgs = append(msgs, "acme is not supported by the metrics server")
+	}
+	return msgs
+}
+
+// validateServerTLS checks that each additional certificate has a key and
+// a cert, and the client certificate verification options.
+func validateServerTLS(tls *options.TLS) []string {
+	msgs := []string{}
+	if tls == nil {
+		return msgs
+	}
+
+	for i, certificate := range tls.AdditionalCertificates {
+		if certificate.Key == nil {
+			msgs = append(msgs, fmt.Sprintf("additional TLS certificate %d has no key", i))
+		}
+		if certificate.Cert == nil {
+			msgs = append(msgs, fmt.Sprintf("additional TLS certificate %d has no cert", i))
+		}
+	}
+
+	switch tls.ClientAuth {
+	case "", options.TLSClientAuthOptional, options.TLSClientAuthRequired:
+		if tls.ClientAuth != "" && tls.ClientCA == nil {
+			msgs = append(msgs, "tls_client_auth requires a client CA (tls_client_ca_file)")
+		}
+	default:
+		msgs = append(msgs, fmt.Sprintf("tls_client_auth (%s) must be one of %q or %q", tls.ClientAuth, options.TLSClientAuthOptional, options.TLSClientAuthRequired))
+	}
+	return msgs
+}
+
+// validateClientCertificateSessions checks that client certificates are
+// verified when sessions are created from them.
+func validateClientCertificateSessions(o *options.Options) []string {
+	msgs := []string{}
+	if !o.ClientCertificateSessions {
+		return msgs
+	}
+
+	if o.Server.TLS == nil || o.Server.TLS.ClientCA == nil {
+		msgs = append(msgs, "client_certificate_sessions requires a client CA (tls_client_ca_file)")
+	}
+	switch o.ClientCertificateUserAttribute {
+	case options.ClientCertificateUserCN, options.ClientCertificateUserDNS, options.ClientCertificateUserURI, options.ClientCertificateUserEmail:
+	default:
+		msgs = append(msgs, fmt.Sprintf("client_certificate_user_attribute (%s) must be one of %q, %q, %q or %q", o.ClientCertificateUserAttribute,
+			options.ClientCertificateUserCN, options.ClientCertificateUserDNS, options.ClientCertificateUserURI, options.ClientCertificateUserEmail))
+	}
+	return msgs
+}
+
+// validateACME checks the ACME configuration of the app server.
+func validateACME(o *options.Options) []string {
+	acme := o.Server.ACME
+	if acme == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if len(acme.Domains) == 0 {
+		msgs = append(msgs, "acme requires at least one domain")
+	}
+	if !acme.AcceptTOS {
+		msgs = append(msgs, "acme requires the terms of service of the certificate authority to be accepted (acme_accept_tos)")
+	}
+	if o.Server.TLS != nil && (o.Server.TLS.Key != nil || o.Server.TLS.Cert != nil || len(o.Server.TLS.AdditionalCertificates) > 0) {
+		msgs = append(msgs, "acme and a TLS key and certificate are mutually exclusive")
+	}
+	if acme.DirectoryURL != "" {
+		if u, err := url.Parse(acme.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			msgs = append(msgs, "acme directory URL must be an https URL")
+		}
+	}
+
+	// Without a cache, certificates are requested again after every restart
+	// which quickly hits the rate limits of the certificate authority
+	switch {
+	case acme.UseSessionStore && acme.CacheDir != "":
+		msgs = append(msgs, "acme cache dir and session store are mutually exclusive")
+	case acme.UseSessionStore && o.Session.Type != options.RedisSessionStoreType:
+		msgs = append(msgs, "acme session store requires the redis session store")
+	case !acme.UseSessionStore && acme.CacheDir == "":
+		msgs = append(msgs, "acme requires a cache dir or the session store to store certificates")
+	}
+	return msgs
+}
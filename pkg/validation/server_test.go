@@ -0,0 +1,213 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	type validateServerTableInput struct {
+		server             options.Server
+		sessionType        string
+		certificateSession bool
+		userAttribute      string
+		errStrings         []string
+	}
+
+	validACME := func() *options.ACME {
+		return &options.ACME{
+			Domains:   []string{"example.com"},
+			AcceptTOS: true,
+			CacheDir:  "/var/cache/oauth2-proxy",
+		}
+	}
+
+	DescribeTable("validateServer",
+		func(in validateServerTableInput) {
+			opts := &options.Options{
+				Server:                         in.server,
+				Session:                        options.SessionOptions{Type: in.sessionType},
+				ClientCertificateSessions:      in.certificateSession,
+				ClientCertificateUserAttribute: in.userAttribute,
+			}
+			Expect(validateServer(opts)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without acme", validateServerTableInput{
+			server:     options.Server{},
+			errStrings: []string{},
+		}),
+		Entry("with additional TLS certificates", validateServerTableInput{
+			server: options.Server{
+				TLS: &options.TLS{
+					Cert: &options.SecretSource{FromFile: "tls.crt"},
+					Key:  &options.SecretSource{FromFile: "tls.key"},
+					AdditionalCertificates: []options.TLSCertificate{
+						{
+							Cert: &options.SecretSource{FromFile: "app.crt"},
+							Key:  &options.SecretSource{FromFile: "app.key"},
+						},
+						{
+							Cert: &options.SecretSource{FromFile: "api.crt"},
+						},
+					},
+				},
+			},
+			errStrings: []string{"additional TLS certificate 1 has no key"},
+		}),
+		Entry("with a client CA", validateServerTableInput{
+			server: options.Server{
+				TLS: &options.TLS{
+					Cert:       &options.SecretSource{FromFile: "tls.crt"},
+					Key:        &options.SecretSource{FromFile: "tls.key"},
+					ClientCA:   &options.SecretSource{FromFile: "ca.crt"},
+					ClientAuth: options.TLSClientAuthRequired,
+				},
+			},
+			certificateSession: true,
+			userAttribute:      options.ClientCertificateUserEmail,
+			errStrings:         []string{},
+		}),
+		Entry("with an invalid client auth", validateServerTableInput{
+			server: options.Server{
+				TLS: &options.TLS{
+					Cert:       &options.SecretSource{FromFile: "tls.crt"},
+					Key:        &options.SecretSource{FromFile: "tls.key"},
+					ClientAuth: "always",
+				},
+			},
+			errStrings: []string{"tls_client_auth (always) must be one of \"optional\" or \"required\""},
+		}),
+		Entry("with client auth and no client CA", validateServerTableInput{
+			server: options.Server{
+				TLS: &options.TLS{
+					Cert:       &options.SecretSource{FromFile: "tls.crt"},
+					Key:        &options.SecretSource{FromFile: "tls.key"},
+					ClientAuth: options.TLSClientAuthOptional,
+				},
+			},
+			errStrings: []string{"tls_client_auth requires a client CA (tls_client_ca_file)"},
+		}),
+		Entry("with client certificate sessions and no client CA", validateServerTableInput{
+			server:             options.Server{},
+			certificateSession: true,
+			userAttribute:      "ou",
+			errStrings: []string{
+				"client_certificate_sessions requires a client CA (tls_client_ca_file)",
+				"client_certificate_user_attribute (ou) must be one of \"cn\", \"dns\", \"uri\" or \"email\"",
+			},
+		}),
+		Entry("with valid acme", validateServerTableInput{
+			server: options.Server{
+				ACME: validACME(),
+				TLS:  &options.TLS{MinVersion: "TLS1.3"},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with acme and no domains or terms of service", validateServerTableInput{
+			server: options.Server{
+				ACME: &options.ACME{CacheDir: "/var/cache/oauth2-proxy"},
+			},
+			errStrings: []string{
+				"acme requires at least one domain",
+				"acme requires the terms of service of the certificate authority to be accepted (acme_accept_tos)",
+			},
+		}),
+		Entry("with acme and a TLS certificate", validateServerTableInput{
+			server: options.Server{
+				ACME: validACME(),
+				TLS: &options.TLS{
+					Cert: &options.SecretSource{FromFile: "tls.crt"},
+					Key:  &options.SecretSource{FromFile: "tls.key"},
+				},
+			},
+			errStrings: []string{"acme and a TLS key and certificate are mutually exclusive"},
+		}),
+		Entry("with an http directory URL", validateServerTableInput{
+			server: options.Server{
+				ACME: &options.ACME{
+					Domains:      []string{"example.com"},
+					AcceptTOS:    true,
+					CacheDir:     "/var/cache/oauth2-proxy",
+					DirectoryURL: "http://acme.example.com/directory",
+				},
+			},
+			errStrings: []string{"acme directory URL must be an https URL"},
+		}),
+		Entry("with acme and no cache", validateServerTableInput{
+			server: options.Server{
+				ACME: &options.ACME{
+					Domains:   []string{"example.com"},
+					AcceptTOS: true,
+				},
+			},
+			errStrings: []string{"acme requires a cache dir or the session store to store certificates"},
+		}),
+		Entry("with the acme session store cache and the cookie session store", validateServerTableInput{
+			server: options.Server{
+				ACME: &options.ACME{
+					Domains:         []string{"example.com"},
+					AcceptTOS:       true,
+					UseSessionStore: true,
+				},
+			},
+			sessionType: options.CookieSessionStoreType,
+			errStrings:  []string{"acme session store requires the redis session store"},
+		}),
+		Entry("with a bearer token", validateServerTableInput{
+			server: options.Server{
+				BearerToken: &options.SecretSource{FromFile: "token"},
+			},
+			errStrings: []string{"a bearer token is only supported by the metrics server"},
+		}),
+		Entry("with the acme session store cache and the redis session store", validateServerTableInput{
+			server: options.Server{
+				ACME: &options.ACME{
+					Domains:         []string{"example.com"},
+					AcceptTOS:       true,
+					UseSessionStore: true,
+				},
+			},
+			sessionType: options.RedisSessionStoreType,
+			errStrings:  []string{},
+		}),
+	)
+
+	DescribeTable("validateMetricsServer",
+		func(server options.Server, errStrings []string) {
+			Expect(validateMetricsServer(server)).To(ConsistOf(errStrings))
+		},
+		Entry("with an insecure address", options.Server{
+			BindAddress: ":9100",
+			BearerToken: &options.SecretSource{FromFile: "token"},
+		}, []string{}),
+		Entry("with a secure address and client certificates", options.Server{
+			SecureBindAddress: ":9443",
+			TLS: &options.TLS{
+				Cert:       &options.SecretSource{FromFile: "tls.crt"},
+				Key:        &options.SecretSource{FromFile: "tls.key"},
+				ClientCA:   &options.SecretSource{FromFile: "ca.crt"},
+				ClientAuth: options.TLSClientAuthRequired,
+			},
+		}, []string{}),
+		Entry("with a secure address and no certificate", options.Server{
+			SecureBindAddress: ":9443",
+		}, []string{"metrics_secure_address requires a TLS key and certificate (metrics_tls_key_file and metrics_tls_cert_file)"}),
+		Entry("with client certificates and no secure address", options.Server{
+			BindAddress: ":9100",
+			TLS: &options.TLS{
+				ClientCA:   &options.SecretSource{FromFile: "ca.crt"},
+				ClientAuth: options.TLSClientAuthRequired,
+			},
+		}, []string{"a metrics client CA (metrics_tls_client_ca_file) requires metrics_secure_address"}),
+		Entry("with acme", options.Server{
+			SecureBindAddress: ":9443",
+			TLS: &options.TLS{
+				Cert: &options.SecretSource{FromFile: "tls.crt"},
+				Key:  &options.SecretSource{FromFile: "tls.key"},
+			},
+			ACME: &options.ACME{Domains: []string{"example.com"}},
+		}, []string{"acme is not supported by the metrics server"}),
+	)
+})
@@ -17,7 +17,8 @@ func validateSessionCookieMinimal(o *options.Options) []string {
 	}
 
 	msgs := []string{}
-	for _, header := range append(o.InjectRequestHeaders, o.InjectResponseHeaders...) {
+	headers := append(append(o.InjectRequestHeaders, o.InjectResponseHeaders...), o.AuthResponseHeaders...)
+	for _, header := range headers {
 		for _, value := range header.Values {
 			if value.ClaimSource != nil {
 				if value.ClaimSource.Claim == "access_token" {
@@ -39,13 +40,89 @@ func validateSessionCookieMinimal(o *options.Options) []string {
 	return msgs
 }
 
+// validateSessionCookieOverflow checks that the sessions too large for a
+// cookie can be stored where the overflow is set to.
+func validateSessionCookieOverflow(o *options.Options) []string {
+	switch o.Session.Cookie.Overflow {
+	case "":
+		return []string{}
+	case options.RedisCookieOverflow:
+		if o.Session.Type == options.CookieSessionStoreType && !redis.HasConnection(o.Session.Redis) {
+			return []string{"session_cookie_overflow=redis requires a redis connection URL"}
+		}
+		return []string{}
+	default:
+		return []string{fmt.Sprintf("invalid session_cookie_overflow %q: must be empty or %q", o.Session.Cookie.Overflow, options.RedisCookieOverflow)}
+	}
+}
+
+// validateSignOutEverywhere checks that the sessions of users can be indexed
+// to sign them out everywhere.
+func validateSignOutEverywhere(o *options.Options) []string {
+	msgs := []string{}
+	if o.Session.SignOutEverywhere && o.Session.Type != options.RedisSessionStoreType {
+		msgs = append(msgs, "session_sign_out_everywhere requires the redis session store")
+	}
+	if o.Session.RevokeRefreshTokens && !o.Session.SignOutEverywhere {
+		msgs = append(msgs, "session_revoke_refresh_tokens requires session_sign_out_everywhere")
+	}
+	return msgs
+}
+
+// validateRedisSPIFFE checks the SPIFFE options of the Redis session store
+// without connecting to it.
+func validateRedisSPIFFE(o *options.Options) []string {
+	msgs := []string{}
+	if !usesRedis(o) || !o.Session.Redis.UseSPIFFE {
+		return msgs
+	}
+
+	if o.Session.Redis.InsecureSkipTLSVerify {
+		msgs = append(msgs, "redis_use_spiffe and redis_insecure_skip_tls_verify are mutually exclusive")
+	}
+	for _, msg := range validateSPIFFEIDs(o.Session.Redis.SPIFFEIDs) {
+		msgs = append(msgs, fmt.Sprintf("invalid redis_spiffe_ids: %s", msg))
+	}
+	return msgs
+}
+
+// validateRedisPool checks the connection pool options of the Redis session
+// store without connecting to it.
+func validateRedisPool(o *options.Options) []string {
+	msgs := []string{}
+	redisOpts := o.Session.Redis
+	if redisOpts.PoolSize < 0 {
+		msgs = append(msgs, "redis_connection_pool_size must not be negative")
+	}
+	if redisOpts.MinIdleConns < 0 {
+		msgs = append(msgs, "redis_connection_min_idle must not be negative")
+	}
+	if redisOpts.PoolSize > 0 && redisOpts.MinIdleConns > redisOpts.PoolSize {
+		msgs = append(msgs, "redis_connection_min_idle must not be greater than redis_connection_pool_size")
+	}
+	if redisOpts.DialTimeout < 0 {
+		msgs = append(msgs, "redis_connection_dial_timeout must not be negative")
+	}
+	if redisOpts.PoolTimeout < 0 {
+		msgs = append(msgs, "redis_connection_pool_timeout must not be negative")
+	}
+	return msgs
+}
+
 // validateRedisSessionStore builds a Redis Client from the options and
 // attempts to connect, Set, Get and Del a random health check key
 func validateRedisSessionStore(o *options.Options) []string {
-	if o.Session.Type != options.RedisSessionStoreType {
+	if !usesRedis(o) {
 		return []string{}
 	}
 
+	if msgs := validateRedisSPIFFE(o); len(msgs) > 0 {
+		return msgs
+	}
+	if msgs := validateRedisPool(o); len(msgs) > 0 {
+		return msgs
+	}
+
 	client, err := redis.NewRedisClient(o.Session.Redis)
 	if err != nil {
 		return []string{fmt.Sprintf("unable to initialize a redis client: %v", err)}
@@ -61,6 +138,20 @@ func validateRedisSessionStore(o *options.Options) []string {
 	return sendRedisConnectionTest(client, key, nonce)
 }
 
+// usesRedis returns whether sessions are stored in Redis: with the Redis
+// session store, or with the cookie session store when it falls back to Redis
+// for the sessions that are too large for a cookie.
+func usesRedis(o *options.Options) bool {
+	switch o.Session.Type {
+	case options.RedisSessionStoreType:
+		return true
+	case options.CookieSessionStoreType:
+		return o.Session.Cookie.Overflow == options.RedisCookieOverflow
+	default:
+		return false
+	}
+}
+
 func sendRedisConnectionTest(client redis.Client, key string, val string) []string {
 	msgs := []string{}
 	ctx := context.Background()
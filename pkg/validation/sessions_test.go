@@ -242,6 +242,29 @@ var _ = Describe("Sessions", func() {
 			},
 			errStrings: []string{},
 		}),
+		Entry("cookie sessions with a redis connection are not checked", &redisStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+					Redis: options.RedisStoreOptions{
+						ConnectionURL: "redis://127.0.0.1:65535",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("cookie sessions falling back to redis are checked", &redisStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type:   options.CookieSessionStoreType,
+					Cookie: options.CookieStoreOptions{Overflow: options.RedisCookieOverflow},
+					Redis: options.RedisStoreOptions{
+						ConnectionURL: "redis://127.0.0.1:65535",
+					},
+				},
+			},
+			errStrings: []string{unreachableRedisSetMsg, unreachableRedisDelMsg},
+		}),
 		Entry("connect successfully to pure redis", &redisStoreTableInput{
 			setAddr: true,
 
@@ -405,4 +428,124 @@ var _ = Describe("Sessions", func() {
 			errStrings: []string{clusterAndSentinelMsg},
 		}),
 	)
+
+	type redisPoolTableInput struct {
+		redis      options.RedisStoreOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateRedisPool",
+		func(o *redisPoolTableInput) {
+			Expect(validateRedisPool(&options.Options{Session: options.SessionOptions{Redis: o.redis}})).To(ConsistOf(o.errStrings))
+		},
+		Entry("with the default pool", &redisPoolTableInput{
+			errStrings: []string{},
+		}),
+		Entry("with a tuned pool", &redisPoolTableInput{
+			redis: options.RedisStoreOptions{
+				PoolSize:     100,
+				MinIdleConns: 10,
+				DialTimeout:  time.Second,
+				ReadTimeout:  -1,
+				WriteTimeout: 500 * time.Millisecond,
+				PoolTimeout:  2 * time.Second,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with more idle connections than the pool size", &redisPoolTableInput{
+			redis: options.RedisStoreOptions{
+				PoolSize:     10,
+				MinIdleConns: 20,
+			},
+			errStrings: []string{"redis_connection_min_idle must not be greater than redis_connection_pool_size"},
+		}),
+		Entry("with negative values", &redisPoolTableInput{
+			redis: options.RedisStoreOptions{
+				PoolSize:     -1,
+				MinIdleConns: -1,
+				DialTimeout:  -1,
+				PoolTimeout:  -1,
+			},
+			errStrings: []string{
+				"redis_connection_pool_size must not be negative",
+				"redis_connection_min_idle must not be negative",
+				"redis_connection_dial_timeout must not be negative",
+				"redis_connection_pool_timeout must not be negative",
+			},
+		}),
+	)
+
+	type signOutEverywhereTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateSignOutEverywhere",
+		func(o *signOutEverywhereTableInput) {
+			Expect(validateSignOutEverywhere(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("with sign out everywhere disabled", &signOutEverywhereTableInput{
+			session:    options.SessionOptions{Type: options.CookieSessionStoreType},
+			errStrings: []string{},
+		}),
+		Entry("with the redis session store and revoked refresh tokens", &signOutEverywhereTableInput{
+			session: options.SessionOptions{
+				Type:                options.RedisSessionStoreType,
+				SignOutEverywhere:   true,
+				RevokeRefreshTokens: true,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with the cookie session store", &signOutEverywhereTableInput{
+			session: options.SessionOptions{
+				Type:              options.CookieSessionStoreType,
+				SignOutEverywhere: true,
+			},
+			errStrings: []string{"session_sign_out_everywhere requires the redis session store"},
+		}),
+		Entry("with revoked refresh tokens only", &signOutEverywhereTableInput{
+			session: options.SessionOptions{
+				Type:                options.RedisSessionStoreType,
+				RevokeRefreshTokens: true,
+			},
+			errStrings: []string{"session_revoke_refresh_tokens requires session_sign_out_everywhere"},
+		}),
+	)
+
+	type cookieOverflowTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateSessionCookieOverflow",
+		func(o *cookieOverflowTableInput) {
+			Expect(validateSessionCookieOverflow(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("without an overflow", &cookieOverflowTableInput{
+			session:    options.SessionOptions{Type: options.CookieSessionStoreType},
+			errStrings: []string{},
+		}),
+		Entry("with the overflow to redis and a redis connection", &cookieOverflowTableInput{
+			session: options.SessionOptions{
+				Type:   options.CookieSessionStoreType,
+				Cookie: options.CookieStoreOptions{Overflow: options.RedisCookieOverflow},
+				Redis:  options.RedisStoreOptions{UseCluster: true, ClusterConnectionURLs: []string{"redis://127.0.0.1:6379"}},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with the overflow to redis without a redis connection", &cookieOverflowTableInput{
+			session: options.SessionOptions{
+				Type:   options.CookieSessionStoreType,
+				Cookie: options.CookieStoreOptions{Overflow: options.RedisCookieOverflow},
+			},
+			errStrings: []string{"session_cookie_overflow=redis requires a redis connection URL"},
+		}),
+		Entry("with an unknown overflow", &cookieOverflowTableInput{
+			session: options.SessionOptions{
+				Type:   options.CookieSessionStoreType,
+				Cookie: options.CookieStoreOptions{Overflow: "memcached"},
+			},
+			errStrings: []string{`invalid session_cookie_overflow "memcached": must be empty or "redis"`},
+		}),
+	)
 })
@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/challenge"
+)
+
+// validateSignInChallenge checks that the sign-in challenge can be created
+// and that the sign-in page it is rendered on is shown.
+func validateSignInChallenge(o *options.Options) []string {
+	if o.SignInChallenge.Type == "" {
+		return nil
+	}
+
+	msgs := []string{}
+	if _, err := challenge.New(o.SignInChallenge, o.Cookie.Secret, nil); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid sign_in_challenge: %v", err))
+	}
+	if o.SkipProviderButton {
+		msgs = append(msgs, "sign_in_challenge cannot be used with skip_provider_button, as the sign_in page is not shown")
+	}
+	return msgs
+}
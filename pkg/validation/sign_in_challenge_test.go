@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign-in Challenge", func() {
+	DescribeTable("validateSignInChallenge",
+		func(challenge options.SignInChallenge, skipProviderButton bool, errStrings []string) {
+			o := &options.Options{
+				Cookie:             options.Cookie{Secret: []byte(cookieSecret)},
+				SignInChallenge:    challenge,
+				SkipProviderButton: skipProviderButton,
+			}
+			Expect(validateSignInChallenge(o)).To(ConsistOf(errStrings))
+		},
+		Entry("without a challenge", options.SignInChallenge{}, true, []string{}),
+		Entry("with a proof-of-work challenge", options.SignInChallenge{
+			Type:                  options.SignInChallengeProofOfWork,
+			ProofOfWorkDifficulty: options.DefaultProofOfWorkDifficulty,
+		}, false, []string{}),
+		Entry("with a hCaptcha challenge without a secret key", options.SignInChallenge{
+			Type:    options.SignInChallengeHCaptcha,
+			SiteKey: "site-key",
+		}, false, []string{"invalid sign_in_challenge: a secret key is required"}),
+		Entry("with an unknown challenge", options.SignInChallenge{
+			Type: "recaptcha",
+		}, false, []string{`invalid sign_in_challenge: unknown sign-in challenge "recaptcha", must be hcaptcha, turnstile or proof-of-work`}),
+		Entry("with the provider button skipped", options.SignInChallenge{
+			Type:      options.SignInChallengeTurnstile,
+			SiteKey:   "site-key",
+			SecretKey: "secret-key",
+		}, true, []string{"sign_in_challenge cannot be used with skip_provider_button, as the sign_in page is not shown"}),
+	)
+})
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateSilentAuthOrigins checks that the origins allowed to authenticate
+// silently are http or https origins, without a path, as the result of the
+// authentication is posted to them.
+func validateSilentAuthOrigins(o *options.Options) []string {
+	msgs := []string{}
+	for _, origin := range o.SilentAuthOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			msgs = append(msgs, fmt.Sprintf("silent auth origin %q must be an http or https origin, eg https://app.example.com", origin))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,24 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Silent Auth", func() {
+	DescribeTable("validateSilentAuthOrigins",
+		func(origins []string, errStrings []string) {
+			Expect(validateSilentAuthOrigins(&options.Options{SilentAuthOrigins: origins})).To(ConsistOf(errStrings))
+		},
+		Entry("without origins", nil, []string{}),
+		Entry("with valid origins", []string{"https://app.example.com", "http://localhost:3000"}, []string{}),
+		Entry("with invalid origins", []string{"app.example.com", "https://app.example.com/", "*", "https://app.example.com/path"}, []string{
+			`silent auth origin "app.example.com" must be an http or https origin, eg https://app.example.com`,
+			`silent auth origin "https://app.example.com/" must be an http or https origin, eg https://app.example.com`,
+			`silent auth origin "*" must be an http or https origin, eg https://app.example.com`,
+			`silent auth origin "https://app.example.com/path" must be an http or https origin, eg https://app.example.com`,
+		}),
+	)
+})
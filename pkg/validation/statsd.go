@@ -0,0 +1,28 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/statsd"
+)
+
+// validateStatsD checks the address of the DogStatsD agent, the tags added
+// to the metrics and the flush interval when metrics are sent to an agent.
+func validateStatsD(o options.StatsD) []string {
+	msgs := []string{}
+	if o.Address == "" {
+		return msgs
+	}
+
+	if _, _, err := statsd.ParseAddress(o.Address); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	for _, tag := range o.Tags {
+		if err := statsd.ValidateTag(tag); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if o.FlushInterval <= 0 {
+		msgs = append(msgs, "statsd_flush_interval must be positive")
+	}
+	return msgs
+}
@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsD", func() {
+	DescribeTable("validateStatsD",
+		func(o options.StatsD, errStrings []string) {
+			Expect(validateStatsD(o)).To(ConsistOf(errStrings))
+		},
+		Entry("without an address", options.StatsD{}, []string{}),
+		Entry("with an agent", options.StatsD{
+			Address:       "unix:///var/run/datadog/dsd.socket",
+			Tags:          []string{"env:prod", "canary"},
+			FlushInterval: 10 * time.Second,
+		}, []string{}),
+		Entry("with an invalid address and tag", options.StatsD{
+			Address:       "localhost",
+			Tags:          []string{"env:prod|team:iam"},
+			FlushInterval: 10 * time.Second,
+		}, []string{
+			`invalid statsd address "udp://localhost": address localhost: missing port in address`,
+			`invalid statsd tag "env:prod|team:iam": must be key:value or key, without ',', '|' or '#'`,
+		}),
+		Entry("without a flush interval", options.StatsD{
+			Address: "localhost:8125",
+		}, []string{"statsd_flush_interval must be positive"}),
+	)
+})
@@ -0,0 +1,47 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// brandColorPattern matches hex colors, eg #0078d4, and color names, which
+// are safe to render in the styles of the sign_in and error pages.
+var brandColorPattern = regexp.MustCompile(`^(#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[a-zA-Z]+)$`)
+
+// validateTemplates checks the branding of the sign_in and error pages and
+// that the custom templates directory is set when it is watched.
+func validateTemplates(opts options.Templates) []string {
+	msgs := []string{}
+
+	if opts.Watch && opts.Path == "" {
+		msgs = append(msgs, "watch_custom_templates_dir requires custom_templates_dir")
+	}
+
+	for name, color := range map[string]string{
+		"brand_color":            opts.BrandColor,
+		"brand_background_color": opts.BrandBackgroundColor,
+	} {
+		if color != "" && !brandColorPattern.MatchString(color) {
+			msgs = append(msgs, fmt.Sprintf("%s (%q) must be a hex color, eg #0078d4, or a color name", name, color))
+		}
+	}
+
+	for _, link := range opts.FooterLinks {
+		parts := strings.SplitN(link, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			msgs = append(msgs, fmt.Sprintf("footer link %q must be in the format label=url", link))
+			continue
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && (u.Scheme != "" || !strings.HasPrefix(u.Path, "/"))) {
+			msgs = append(msgs, fmt.Sprintf("footer link %q must link to an absolute path or an http or https URL", link))
+		}
+	}
+
+	return msgs
+}
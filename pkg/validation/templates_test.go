@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	DescribeTable("validateTemplates",
+		func(o options.Templates, errStrings []string) {
+			Expect(validateTemplates(o)).To(ConsistOf(errStrings))
+		},
+		Entry("without branding", options.Templates{}, []string{}),
+		Entry("with valid branding", options.Templates{
+			Path:                 "/etc/oauth2-proxy/templates",
+			Watch:                true,
+			BrandColor:           "#0078d4",
+			BrandBackgroundColor: "white",
+			FooterLinks:          []string{"Privacy=https://example.com/privacy", "Help=/help"},
+		}, []string{}),
+		Entry("with a watched templates directory without a path", options.Templates{
+			Watch: true,
+		}, []string{"watch_custom_templates_dir requires custom_templates_dir"}),
+		Entry("with invalid brand colors", options.Templates{
+			BrandColor:           "#00",
+			BrandBackgroundColor: "red; background-image: url(https://evil.example.com)",
+		}, []string{
+			`brand_color ("#00") must be a hex color, eg #0078d4, or a color name`,
+			`brand_background_color ("red; background-image: url(https://evil.example.com)") must be a hex color, eg #0078d4, or a color name`,
+		}),
+		Entry("with invalid footer links", options.Templates{
+			FooterLinks: []string{"https://example.com", "=https://example.com", "Script=javascript:alert(1)", "Relative=help"},
+		}, []string{
+			`footer link "https://example.com" must be in the format label=url`,
+			`footer link "=https://example.com" must be in the format label=url`,
+			`footer link "Script=javascript:alert(1)" must link to an absolute path or an http or https URL`,
+			`footer link "Relative=help" must link to an absolute path or an http or https URL`,
+		}),
+	)
+})
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateTenants validates the options of each tenant with its overrides
+// applied, and sets the validated tenant options so that they can be used to
+// build the proxy for each tenant.
+func validateTenants(o *options.Options, connect bool) *Report {
+	r := &Report{}
+	ids := make(map[string]struct{})
+	hosts := make(map[string]struct{})
+	tenantOptions := make([]*options.Options, 0, len(o.Tenants))
+
+	absoluteRedirectURL := false
+	if u, err := url.Parse(o.RawRedirectURL); err == nil && u.Host != "" {
+		absoluteRedirectURL = true
+	}
+
+	for _, tenant := range o.Tenants {
+		path := fmt.Sprintf("tenants[%s]", tenant.ID)
+		if tenant.ID == "" {
+			r.addErrors("tenants", "tenant has empty id: ids are required for all tenants")
+		}
+		if _, ok := ids[tenant.ID]; ok {
+			r.addErrors("tenants", fmt.Sprintf("multiple tenants found with id %q: tenant ids must be unique", tenant.ID))
+		}
+		ids[tenant.ID] = struct{}{}
+
+		if len(tenant.Hosts) == 0 {
+			r.addErrors(path+".hosts", fmt.Sprintf("tenant %q has no hosts: at least one host is required for all tenants", tenant.ID))
+		}
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if _, ok := hosts[host]; ok {
+				r.addErrors(path+".hosts", fmt.Sprintf("multiple tenants found with host %q: tenant hosts must be unique", host))
+			}
+			hosts[host] = struct{}{}
+		}
+
+		if absoluteRedirectURL && tenant.RedirectURL == "" {
+			r.addErrors(path+".redirectURL", fmt.Sprintf("tenant %q has no redirectURL: a redirectURL is required for tenants when the redirect-url is absolute", tenant.ID))
+		}
+
+		tenantOpts := o.ForTenant(tenant)
+		r.merge(path, fmt.Sprintf("tenant %q: ", tenant.ID), validate(tenantOpts, connect))
+		tenantOptions = append(tenantOptions, tenantOpts)
+	}
+
+	o.SetTenantOptions(tenantOptions)
+	return r
+}
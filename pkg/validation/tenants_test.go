@@ -0,0 +1,56 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTenant(o *options.Options) options.Tenant {
+	provider := o.Providers[0]
+	provider.ID = "app-provider"
+	provider.ClientID = "app-client"
+	return options.Tenant{
+		ID:        "app",
+		Hosts:     []string{"app.example.com"},
+		Providers: options.Providers{provider},
+		Cookie:    &options.TenantCookie{Name: "_app"},
+	}
+}
+
+func TestTenantOptions(t *testing.T) {
+	o := testOptions()
+	o.Tenants = []options.Tenant{testTenant(o)}
+	assert.NoError(t, Validate(o))
+
+	tenants := o.GetTenantOptions()
+	assert.Len(t, tenants, 1)
+	assert.Equal(t, "_app", tenants[0].Cookie.Name)
+	assert.Equal(t, "app-client", tenants[0].Providers[0].ClientID)
+	assert.NotNil(t, tenants[0].GetRedirectURL())
+	assert.Nil(t, tenants[0].Tenants)
+
+	assert.Equal(t, "_oauth2_proxy", o.Cookie.Name)
+	assert.Equal(t, clientID, o.Providers[0].ClientID)
+}
+
+func TestTenantValidation(t *testing.T) {
+	o := testOptions()
+	o.RawRedirectURL = "https://auth.example.com/oauth2/callback"
+
+	missingHosts := testTenant(o)
+	missingHosts.Hosts = nil
+	missingHosts.Providers[0].ClientID = ""
+	duplicate := testTenant(o)
+	duplicate.RedirectURL = "https://app.example.com/oauth2/callback"
+	o.Tenants = []options.Tenant{missingHosts, duplicate}
+
+	expected := errorMsg([]string{
+		"tenant \"app\" has no hosts: at least one host is required for all tenants",
+		"tenant \"app\" has no redirectURL: a redirectURL is required for tenants when the redirect-url is absolute",
+		"tenant \"app\": provider missing setting: client-id",
+		"multiple tenants found with id \"app\": tenant ids must be unique",
+	})
+	assert.EqualError(t, Validate(o), expected)
+}
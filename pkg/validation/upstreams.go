@@ -2,17 +2,49 @@ package validation
 
 import (
 	"fmt"
+	"mime"
 	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 )
 
 func validateUpstreams(upstreams options.UpstreamConfig) []string {
+	msgs := []string{}
+	all := upstreams.Upstreams
+
+	if upstreams.DynamicUpstreamsFile != "" {
+		dynamic, err := options.LoadDynamicUpstreams(upstreams.DynamicUpstreamsFile)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		all = append(append([]options.Upstream{}, all...), dynamic...)
+	}
+
+	return append(msgs, validateUpstreamList(all)...)
+}
+
+// ValidateDynamicUpstreams validates upstreams that are added at runtime
+// along with the configured upstreams, whose IDs and paths they must not
+// clash with.
+func ValidateDynamicUpstreams(upstreams options.UpstreamConfig, dynamic []options.Upstream) error {
+	msgs := validateUpstreamList(append(append([]options.Upstream{}, upstreams.Upstreams...), dynamic...))
+	if len(msgs) != 0 {
+		return fmt.Errorf("invalid upstreams:\n  %s", strings.Join(msgs, "\n  "))
+	}
+	return nil
+}
+
+func validateUpstreamList(upstreams []options.Upstream) []string {
 	msgs := []string{}
 	ids := make(map[string]struct{})
 	paths := make(map[string]struct{})
 
-	for _, upstream := range upstreams.Upstreams {
+	for _, upstream := range upstreams {
 		msgs = append(msgs, validateUpstream(upstream, ids, paths)...)
 	}
 
@@ -45,6 +77,20 @@ func validateUpstream(upstream options.Upstream, ids, paths map[string]struct{})
 
 	msgs = append(msgs, validateUpstreamURI(upstream)...)
 	msgs = append(msgs, validateStaticUpstream(upstream)...)
+	msgs = append(msgs, validateKubernetesImpersonation(upstream)...)
+	msgs = append(msgs, validateUpstreamFailureHandling(upstream)...)
+	msgs = append(msgs, validateUpstreamHealthCheck(upstream)...)
+	msgs = append(msgs, validateRewriteRules(upstream)...)
+	msgs = append(msgs, validateAllowedMethods(upstream)...)
+	msgs = append(msgs, validateRequestHeaderPolicy(upstream)...)
+	msgs = append(msgs, validateResponseHeaderPolicy(upstream)...)
+	msgs = append(msgs, validateCompression(upstream)...)
+	msgs = append(msgs, validateResponseCache(upstream)...)
+	msgs = append(msgs, validateUpstreamSession(upstream)...)
+	msgs = append(msgs, validateTrafficShadow(upstream)...)
+	msgs = append(msgs, validateCORS(upstream)...)
+	msgs = append(msgs, validateWebSocketAllowedOrigins(upstream)...)
+	msgs = append(msgs, validateUpstreamSPIFFE(upstream)...)
 	return msgs
 }
 
@@ -57,6 +103,14 @@ func validateStaticUpstream(upstream options.Upstream) []string {
 	if !upstream.Static && upstream.StaticCode != nil {
 		msgs = append(msgs, fmt.Sprintf("upstream %q has staticCode (%d), but is not a static upstream, set 'static' for a static response", upstream.ID, *upstream.StaticCode))
 	}
+	if !upstream.Static && upstream.StaticResponse != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has staticResponse, but is not a static upstream, set 'static' for a static response", upstream.ID))
+	}
+	if upstream.Static && upstream.StaticResponse != nil {
+		if _, err := template.New("").Parse(upstream.StaticResponse.Template); err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid staticResponse template: %v", upstream.ID, err))
+		}
+	}
 
 	// Checks after this only make sense when the upstream is static
 	if !upstream.Static {
@@ -78,6 +132,30 @@ func validateStaticUpstream(upstream options.Upstream) []string {
 	if upstream.ProxyWebSockets != nil {
 		msgs = append(msgs, fmt.Sprintf("upstream %q has proxyWebSockets, but is a static upstream, this will have no effect.", upstream.ID))
 	}
+	if upstream.HTTP2 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has http2, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if len(upstream.CAFiles) > 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has caFiles, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.SPIFFE != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has spiffe, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.Streaming != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has streaming, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.ConnectTimeout != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has connectTimeout, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.Retries != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has retries, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.OutlierDetection != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has outlierDetection, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.HealthCheck != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has healthCheck, but is a static upstream, this will have no effect.", upstream.ID))
+	}
 
 	return msgs
 }
@@ -85,6 +163,10 @@ func validateStaticUpstream(upstream options.Upstream) []string {
 func validateUpstreamURI(upstream options.Upstream) []string {
 	msgs := []string{}
 
+	if len(upstream.URIs) > 0 || upstream.LoadBalancing != nil || upstream.Discovery != nil {
+		return validateUpstreamURIs(upstream)
+	}
+
 	if !upstream.Static && upstream.URI == "" {
 		msgs = append(msgs, fmt.Sprintf("upstream %q has empty uri: uris are required for all non-static upstreams", upstream.ID))
 		return msgs
@@ -104,9 +186,437 @@ func validateUpstreamURI(upstream options.Upstream) []string {
 	switch u.Scheme {
 	case "http", "https", "file":
 		// Valid, do nothing
+	case "unix":
+		if u.Path == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has unix uri with no socket path", upstream.ID))
+		}
+	case "fcgi":
+		if u.Host == "" && u.Path == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has fcgi uri with no address or socket path", upstream.ID))
+		}
+		if upstream.FastCGI == nil || upstream.FastCGI.Root == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has fcgi uri, but has no fastCGI root", upstream.ID))
+		}
 	default:
 		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid scheme: %q", upstream.ID, u.Scheme))
 	}
 
+	if upstream.FileServer != nil && u.Scheme != "file" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has fileServer, but is not a file upstream, this will have no effect.", upstream.ID))
+	}
+	if upstream.FastCGI != nil && u.Scheme != "fcgi" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has fastCGI, but is not a fcgi upstream, this will have no effect.", upstream.ID))
+	}
+
+	return msgs
+}
+
+// validateKubernetesImpersonation checks that impersonation is only configured
+// for HTTP(S) upstreams and that a token has been provided.
+func validateKubernetesImpersonation(upstream options.Upstream) []string {
+	msgs := []string{}
+	impersonation := upstream.KubernetesImpersonation
+	if impersonation == nil {
+		return msgs
+	}
+
+	if upstream.Static {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has kubernetesImpersonation, but is a static upstream, this will have no effect.", upstream.ID))
+		return msgs
+	}
+
+	// Load balanced upstreams are validated to be HTTP(S) separately
+	if u, err := url.Parse(upstream.URI); len(upstream.URIs) == 0 && upstream.Discovery == nil && err == nil && u.Scheme != "http" && u.Scheme != "https" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has kubernetesImpersonation, but is not an HTTP(S) upstream", upstream.ID))
+	}
+
+	if impersonation.Token == nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has kubernetesImpersonation with no token: a token is required to authenticate to the API server", upstream.ID))
+	} else {
+		msgs = append(msgs, prefixValues(fmt.Sprintf("upstream %q kubernetesImpersonation token: ", upstream.ID), validateSecretSource(*impersonation.Token))...)
+	}
+
+	return msgs
+}
+
+// validateUpstreamFailureHandling checks that the retry and outlier detection
+// options are within range.
+func validateUpstreamFailureHandling(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	if retries := upstream.Retries; retries != nil {
+		if retries.Attempts < 0 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid retries attempts (%d): attempts must not be negative", upstream.ID, retries.Attempts))
+		}
+		if retries.BudgetPercent < 0 || retries.BudgetPercent > 100 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid retries budgetPercent (%d): budgetPercent must be between 0 and 100", upstream.ID, retries.BudgetPercent))
+		}
+	}
+
+	if outlier := upstream.OutlierDetection; outlier != nil && outlier.ConsecutiveFailures < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid outlierDetection consecutiveFailures (%d): consecutiveFailures must not be negative", upstream.ID, outlier.ConsecutiveFailures))
+	}
+
+	return msgs
+}
+
+// validateUpstreamHealthCheck checks that the health check has a path and
+// that the thresholds are valid.
+func validateUpstreamHealthCheck(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	healthCheck := upstream.HealthCheck
+	if healthCheck == nil || upstream.Static {
+		return msgs
+	}
+
+	if healthCheck.Path == "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has healthCheck with empty path: a path is required for health checks", upstream.ID))
+	}
+	if healthCheck.HealthyThreshold < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid healthCheck healthyThreshold (%d): healthyThreshold must not be negative", upstream.ID, healthCheck.HealthyThreshold))
+	}
+	if healthCheck.UnhealthyThreshold < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid healthCheck unhealthyThreshold (%d): unhealthyThreshold must not be negative", upstream.ID, healthCheck.UnhealthyThreshold))
+	}
+
+	return msgs
+}
+
+// validateRewriteRules checks that each rewrite rule has a target and valid
+// header matches.
+func validateRewriteRules(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	for i, rule := range upstream.RewriteRules {
+		if rule.Target == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q rewrite rule %d has no target", upstream.ID, i))
+		}
+		for _, header := range rule.Headers {
+			if header.Name == "" {
+				msgs = append(msgs, fmt.Sprintf("upstream %q rewrite rule %d has a header match with no name", upstream.ID, i))
+			}
+			if _, err := regexp.Compile(header.Value); err != nil {
+				msgs = append(msgs, fmt.Sprintf("upstream %q rewrite rule %d has invalid value for header %q: %v", upstream.ID, i, header.Name, err))
+			}
+		}
+	}
+
+	return msgs
+}
+
+// validateAllowedMethods checks that the allowed methods are valid HTTP
+// method names.
+func validateAllowedMethods(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	for _, method := range upstream.AllowedMethods {
+		if method == "" || strings.ContainsAny(method, " \t\r\n/()<>@,;:\\\"[]?={}") {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid allowed method: %q", upstream.ID, method))
+		}
+	}
+
+	return msgs
+}
+
+// validateRequestHeaderPolicy checks that every header in the request header
+// policy has a name.
+func validateRequestHeaderPolicy(upstream options.Upstream) []string {
+	msgs := []string{}
+	policy := upstream.RequestHeaders
+	if policy == nil {
+		return msgs
+	}
+
+	for _, name := range policy.Remove {
+		if name == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders has a header to remove with no name", upstream.ID))
+		}
+	}
+	for _, rename := range policy.Rename {
+		if rename.From == "" || rename.To == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders has a rename without both from and to", upstream.ID))
+		}
+	}
+	for _, header := range policy.Set {
+		if header.Name == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders has a header to set with no name", upstream.ID))
+		}
+	}
+	if policy.Encrypt != nil {
+		msgs = append(msgs, validateHeaderEncryption(upstream.ID, policy.Encrypt)...)
+	}
+
+	return msgs
+}
+
+// validateHeaderEncryption checks that there are headers to encrypt and that
+// the public key encrypting them can be loaded.
+func validateHeaderEncryption(upstreamID string, opts *options.HeaderEncryption) []string {
+	msgs := []string{}
+
+	if len(opts.Headers) == 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders encrypt has no headers", upstreamID))
+	}
+	for _, name := range opts.Headers {
+		if name == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders encrypt has a header with no name", upstreamID))
+		}
+	}
+
+	if opts.PublicKeyFile == "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders encrypt has no publicKeyFile", upstreamID))
+	} else if _, err := encryption.LoadJWEEncrypter(opts.PublicKeyFile, opts.KeyID); err != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q requestHeaders encrypt has an invalid publicKeyFile: %v", upstreamID, err))
+	}
+
+	return msgs
+}
+
+// validateResponseHeaderPolicy checks that every header in the response
+// header policy has a name.
+func validateResponseHeaderPolicy(upstream options.Upstream) []string {
+	msgs := []string{}
+	policy := upstream.ResponseHeaders
+	if policy == nil {
+		return msgs
+	}
+
+	for _, name := range policy.Remove {
+		if name == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q responseHeaders has a header to remove with no name", upstream.ID))
+		}
+	}
+	for _, header := range append(append([]options.StaticHeader{}, policy.Set...), policy.Default...) {
+		if header.Name == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q responseHeaders has a header to set with no name", upstream.ID))
+		}
+	}
+
+	return msgs
+}
+
+// validateCompression checks that the compression content types are valid
+// media types and that the minimum size is not negative.
+func validateCompression(upstream options.Upstream) []string {
+	msgs := []string{}
+	if upstream.Compression == nil {
+		return msgs
+	}
+
+	for _, contentType := range upstream.Compression.ContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid compression content type %q: %v", upstream.ID, contentType, err))
+		}
+	}
+	if upstream.Compression.MinSize < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative compression minSize", upstream.ID))
+	}
+
+	return msgs
+}
+
+// validateResponseCache checks that the cache limits are not negative.
+func validateResponseCache(upstream options.Upstream) []string {
+	msgs := []string{}
+	if upstream.Cache == nil {
+		return msgs
+	}
+
+	if upstream.Cache.MaxEntries < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative cache maxEntries", upstream.ID))
+	}
+	if upstream.Cache.MaxEntrySize < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative cache maxEntrySize", upstream.ID))
+	}
+
+	return msgs
+}
+
+// validateUpstreamSession checks that the session intervals are not negative.
+func validateUpstreamSession(upstream options.Upstream) []string {
+	msgs := []string{}
+	if upstream.Session == nil {
+		return msgs
+	}
+
+	if upstream.Session.RefreshPeriod != nil && upstream.Session.RefreshPeriod.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative session refreshPeriod", upstream.ID))
+	}
+	if upstream.Session.ValidationInterval != nil && upstream.Session.ValidationInterval.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative session validationInterval", upstream.ID))
+	}
+
+	return msgs
+}
+
+// validateTrafficShadow checks that the shadow URI is an HTTP(S) URI and
+// that the percentage is valid.
+func validateTrafficShadow(upstream options.Upstream) []string {
+	msgs := []string{}
+	shadow := upstream.Shadow
+	if shadow == nil {
+		return msgs
+	}
+
+	u, err := url.Parse(shadow.URI)
+	switch {
+	case shadow.URI == "":
+		msgs = append(msgs, fmt.Sprintf("upstream %q has shadow, but has no uri", upstream.ID))
+	case err != nil:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid shadow uri: %v", upstream.ID, err))
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid shadow uri %q: must be an http(s) uri", upstream.ID, shadow.URI))
+	}
+
+	if shadow.Percentage != nil && (*shadow.Percentage < 0 || *shadow.Percentage > 100) {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid shadow percentage (%d): must be between 0 and 100", upstream.ID, *shadow.Percentage))
+	}
+
+	return msgs
+}
+
+// validateCORS checks that allowed origins are configured and that
+// credentials are not allowed for any origin.
+func validateCORS(upstream options.Upstream) []string {
+	msgs := []string{}
+	cors := upstream.CORS
+	if cors == nil {
+		return msgs
+	}
+
+	if len(cors.AllowedOrigins) == 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has cors, but has no allowedOrigins", upstream.ID))
+	}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" && cors.AllowCredentials {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has cors allowCredentials, which cannot be used with an allowed origin of \"*\"", upstream.ID))
+		}
+		if _, err := path.Match(origin, ""); err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid cors allowed origin %q: %v", upstream.ID, origin, err))
+		}
+	}
+
+	return msgs
+}
+
+// validateWebSocketAllowedOrigins checks that the allowed origins are valid
+// patterns.
+func validateWebSocketAllowedOrigins(upstream options.Upstream) []string {
+	msgs := []string{}
+	for _, origin := range upstream.WebSocketAllowedOrigins {
+		if _, err := path.Match(origin, ""); err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid webSocketAllowedOrigin %q: %v", upstream.ID, origin, err))
+		}
+	}
+	if len(upstream.WebSocketAllowedOrigins) > 0 && upstream.ProxyWebSockets != nil && !*upstream.ProxyWebSockets {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has webSocketAllowedOrigins, but does not proxy websockets, this will have no effect.", upstream.ID))
+	}
+	return msgs
+}
+
+// validateUpstreamURIs validates the URIs of a load balanced upstream.
+// Load balanced upstreams must only contain HTTP(S) URIs.
+func validateUpstreamURIs(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	if len(upstream.URIs) == 0 && upstream.Discovery == nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has loadBalancing, but has no uris, this will have no effect.", upstream.ID))
+		return msgs
+	}
+	if upstream.Static {
+		if len(upstream.URIs) > 0 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has uris, but is a static upstream, this will have no effect.", upstream.ID))
+		}
+		if upstream.Discovery != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has discovery, but is a static upstream, this will have no effect.", upstream.ID))
+		}
+		return msgs
+	}
+	if upstream.URI != "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has both uri and uris: only one of uri or uris may be set", upstream.ID))
+	}
+
+	for _, uri := range upstream.URIs {
+		u, err := url.Parse(uri)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid uri: %v", upstream.ID, err))
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid scheme for load balancing: %q", upstream.ID, u.Scheme))
+		}
+	}
+
+	if upstream.LoadBalancing != nil {
+		switch upstream.LoadBalancing.Policy {
+		case "", options.RoundRobinPolicy, options.LeastConnectionsPolicy:
+			// Valid, do nothing
+		default:
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid load balancing policy: %q", upstream.ID, upstream.LoadBalancing.Policy))
+		}
+	}
+
+	msgs = append(msgs, validateUpstreamDiscovery(upstream)...)
+	return msgs
+}
+
+// validateUpstreamDiscovery checks that exactly one discovery method is
+// configured and that it is valid.
+func validateUpstreamDiscovery(upstream options.Upstream) []string {
+	msgs := []string{}
+	discovery := upstream.Discovery
+	if discovery == nil {
+		return msgs
+	}
+
+	switch {
+	case discovery.DNSSRV == "" && discovery.Kubernetes == nil:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has discovery with no method: one of dnsSRV or kubernetes must be set", upstream.ID))
+	case discovery.DNSSRV != "" && discovery.Kubernetes != nil:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has discovery with multiple methods: only one of dnsSRV or kubernetes may be set", upstream.ID))
+	case discovery.Kubernetes != nil && discovery.Kubernetes.Service == "":
+		msgs = append(msgs, fmt.Sprintf("upstream %q has kubernetes discovery with no service", upstream.ID))
+	}
+
+	switch discovery.Scheme {
+	case "", "http", "https":
+		// Valid, do nothing
+	default:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid scheme for discovery: %q", upstream.ID, discovery.Scheme))
+	}
+
+	return msgs
+}
+
+// validateUpstreamSPIFFE checks that SPIFFE is not combined with other TLS
+// verification options and that the authorized IDs are SPIFFE IDs.
+func validateUpstreamSPIFFE(upstream options.Upstream) []string {
+	if upstream.SPIFFE == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if upstream.InsecureSkipTLSVerify {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has spiffe and insecureSkipTLSVerify: spiffe upstreams are always verified", upstream.ID))
+	}
+	if len(upstream.CAFiles) > 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has spiffe and caFiles: spiffe upstreams are verified with the SPIFFE trust bundle", upstream.ID))
+	}
+	for _, msg := range validateSPIFFEIDs(upstream.SPIFFE.AuthorizedIDs) {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid spiffe authorizedIDs: %s", upstream.ID, msg))
+	}
+	return msgs
+}
+
+// validateSPIFFEIDs checks that each ID is a SPIFFE ID with a trust domain.
+func validateSPIFFEIDs(ids []string) []string {
+	msgs := []string{}
+	for _, id := range ids {
+		u, err := url.Parse(id)
+		if err != nil || u.Scheme != "spiffe" || u.Host == "" {
+			msgs = append(msgs, fmt.Sprintf("%q is not a SPIFFE ID of the form spiffe://trust-domain/path", id))
+		}
+	}
 	return msgs
 }
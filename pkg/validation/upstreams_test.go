@@ -16,8 +16,13 @@ var _ = Describe("Upstreams", func() {
 	}
 
 	flushInterval := options.Duration(5 * time.Second)
+	zeroDuration := options.Duration(0)
+	oneMinute := options.Duration(time.Minute)
+	negativeDuration := options.Duration(-time.Minute)
 	staticCode200 := 200
 	truth := true
+	percentage := 50
+	invalidPercentage := 101
 
 	validHTTPUpstream := options.Upstream{
 		ID:   "validHTTPUpstream",
@@ -40,6 +45,8 @@ var _ = Describe("Upstreams", func() {
 	emptyURIMsg := "upstream \"foo\" has empty uri: uris are required for all non-static upstreams"
 	invalidURIMsg := "upstream \"foo\" has invalid uri: parse \":\": missing protocol scheme"
 	invalidURISchemeMsg := "upstream \"foo\" has invalid scheme: \"ftp\""
+	fileServerNotFileMsg := "upstream \"foo\" has fileServer, but is not a file upstream, this will have no effect."
+	unixNoSocketPathMsg := "upstream \"foo\" has unix uri with no socket path"
 	staticWithURIMsg := "upstream \"foo\" has uri, but is a static upstream, this will have no effect."
 	staticWithInsecureMsg := "upstream \"foo\" has insecureSkipTLSVerify, but is a static upstream, this will have no effect."
 	staticWithFlushIntervalMsg := "upstream \"foo\" has flushInterval, but is a static upstream, this will have no effect."
@@ -48,6 +55,58 @@ var _ = Describe("Upstreams", func() {
 	multipleIDsMsg := "multiple upstreams found with id \"foo\": upstream ids must be unique"
 	multiplePathsMsg := "multiple upstreams found with path \"/foo\": upstream paths must be unique"
 	staticCodeMsg := "upstream \"foo\" has staticCode (200), but is not a static upstream, set 'static' for a static response"
+	staticResponseMsg := "upstream \"foo\" has staticResponse, but is not a static upstream, set 'static' for a static response"
+	invalidStaticTemplateMsg := "upstream \"foo\" has invalid staticResponse template: template: :1: unexpected \"}\" in operand"
+	staticWithStreamingMsg := "upstream \"foo\" has streaming, but is a static upstream, this will have no effect."
+	staticWithHTTP2Msg := "upstream \"foo\" has http2, but is a static upstream, this will have no effect."
+	invalidRetryAttemptsMsg := "upstream \"foo\" has invalid retries attempts (-1): attempts must not be negative"
+	invalidRetryBudgetMsg := "upstream \"foo\" has invalid retries budgetPercent (101): budgetPercent must be between 0 and 100"
+	invalidConsecutiveFailuresMsg := "upstream \"foo\" has invalid outlierDetection consecutiveFailures (-1): consecutiveFailures must not be negative"
+	healthCheckNoPathMsg := "upstream \"foo\" has healthCheck with empty path: a path is required for health checks"
+	invalidHealthyThresholdMsg := "upstream \"foo\" has invalid healthCheck healthyThreshold (-1): healthyThreshold must not be negative"
+	invalidUnhealthyThresholdMsg := "upstream \"foo\" has invalid healthCheck unhealthyThreshold (-2): unhealthyThreshold must not be negative"
+	rewriteRuleNoTargetMsg := "upstream \"foo\" rewrite rule 0 has no target"
+	rewriteRuleNoHeaderNameMsg := "upstream \"foo\" rewrite rule 0 has a header match with no name"
+	rewriteRuleInvalidValueMsg := "upstream \"foo\" rewrite rule 0 has invalid value for header \"\": error parsing regexp: missing closing ): `(`"
+	invalidAllowedMethodMsg := "upstream \"foo\" has invalid allowed method: \"GET HEAD\""
+	discoveryNoMethodMsg := "upstream \"foo\" has discovery with no method: one of dnsSRV or kubernetes must be set"
+	discoveryMultipleMethodsMsg := "upstream \"foo\" has discovery with multiple methods: only one of dnsSRV or kubernetes may be set"
+	discoveryNoServiceMsg := "upstream \"foo\" has kubernetes discovery with no service"
+	discoveryInvalidSchemeMsg := "upstream \"foo\" has invalid scheme for discovery: \"ftp\""
+	headerRemoveNoNameMsg := "upstream \"foo\" requestHeaders has a header to remove with no name"
+	headerRenameMsg := "upstream \"foo\" requestHeaders has a rename without both from and to"
+	headerSetNoNameMsg := "upstream \"foo\" requestHeaders has a header to set with no name"
+	headerEncryptNoHeadersMsg := "upstream \"foo\" requestHeaders encrypt has no headers"
+	headerEncryptNoNameMsg := "upstream \"foo\" requestHeaders encrypt has a header with no name"
+	headerEncryptNoKeyMsg := "upstream \"foo\" requestHeaders encrypt has no publicKeyFile"
+	headerEncryptInvalidKeyMsg := "upstream \"foo\" requestHeaders encrypt has an invalid publicKeyFile: no PEM encoded public key found"
+	responseHeaderRemoveNoNameMsg := "upstream \"foo\" responseHeaders has a header to remove with no name"
+	responseHeaderSetNoNameMsg := "upstream \"foo\" responseHeaders has a header to set with no name"
+	compressionContentTypeMsg := "upstream \"foo\" has invalid compression content type \"text/\": mime: expected token after slash"
+	compressionMinSizeMsg := "upstream \"foo\" has a negative compression minSize"
+	cacheMaxEntriesMsg := "upstream \"foo\" has a negative cache maxEntries"
+	cacheMaxEntrySizeMsg := "upstream \"foo\" has a negative cache maxEntrySize"
+	sessionRefreshPeriodMsg := "upstream \"foo\" has a negative session refreshPeriod"
+	sessionValidationIntervalMsg := "upstream \"foo\" has a negative session validationInterval"
+	shadowNoURIMsg := "upstream \"foo\" has shadow, but has no uri"
+	shadowInvalidURIMsg := "upstream \"foo\" has invalid shadow uri \"file:///tmp\": must be an http(s) uri"
+	shadowPercentageMsg := "upstream \"foo\" has invalid shadow percentage (101): must be between 0 and 100"
+	fastCGINoAddressMsg := "upstream \"foo\" has fcgi uri with no address or socket path"
+	fastCGINoRootMsg := "upstream \"foo\" has fcgi uri, but has no fastCGI root"
+	fastCGINotFCGIMsg := "upstream \"foo\" has fastCGI, but is not a fcgi upstream, this will have no effect."
+	corsNoOriginsMsg := "upstream \"foo\" has cors, but has no allowedOrigins"
+	corsCredentialsMsg := "upstream \"foo\" has cors allowCredentials, which cannot be used with an allowed origin of \"*\""
+	webSocketOriginMsg := "upstream \"foo\" has invalid webSocketAllowedOrigin \"https://[\": syntax error in pattern"
+	uriAndURIsMsg := "upstream \"foo\" has both uri and uris: only one of uri or uris may be set"
+	invalidLoadBalancingSchemeMsg := "upstream \"foo\" has invalid scheme for load balancing: \"file\""
+	invalidLoadBalancingPolicyMsg := "upstream \"foo\" has invalid load balancing policy: \"random\""
+	loadBalancingWithoutURIsMsg := "upstream \"foo\" has loadBalancing, but has no uris, this will have no effect."
+	staticWithImpersonationMsg := "upstream \"foo\" has kubernetesImpersonation, but is a static upstream, this will have no effect."
+	fileWithImpersonationMsg := "upstream \"foo\" has kubernetesImpersonation, but is not an HTTP(S) upstream"
+	impersonationNoTokenMsg := "upstream \"foo\" has kubernetesImpersonation with no token: a token is required to authenticate to the API server"
+	spiffeInsecureMsg := "upstream \"foo\" has spiffe and insecureSkipTLSVerify: spiffe upstreams are always verified"
+	dynamicUpstreamsFileMsg := "could not load dynamic upstreams: unable to load config file: open /does/not/exist.yaml: no such file or directory"
+	spiffeInvalidIDMsg := "upstream \"foo\" has invalid spiffe authorizedIDs: \"https://example.org/redis\" is not a SPIFFE ID of the form spiffe://trust-domain/path"
 
 	DescribeTable("validateUpstreams",
 		func(o *validateUpstreamTableInput) {
@@ -139,6 +198,94 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{invalidURISchemeMsg},
 		}),
+		Entry("with a file server on a file upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:         "foo",
+						Path:       "/foo",
+						URI:        "file:///var/www",
+						FileServer: &options.FileServer{IndexFiles: []string{"index.htm"}},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a file server on an HTTP upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:         "foo",
+						Path:       "/foo",
+						URI:        "http://localhost:8080",
+						FileServer: &options.FileServer{},
+					},
+				},
+			},
+			errStrings: []string{fileServerNotFileMsg},
+		}),
+		Entry("with a unix socket upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "unix:///var/run/app.sock",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a FastCGI upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:      "foo",
+						Path:    "/foo",
+						URI:     "fcgi:///run/php-fpm.sock",
+						FastCGI: &options.FastCGI{Root: "/var/www/html"},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid FastCGI upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "fcgi://",
+					},
+				},
+			},
+			errStrings: []string{fastCGINoAddressMsg, fastCGINoRootMsg},
+		}),
+		Entry("with fastCGI on a non FastCGI upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:      "foo",
+						Path:    "/foo",
+						URI:     "http://localhost:8080",
+						FastCGI: &options.FastCGI{Root: "/var/www/html"},
+					},
+				},
+			},
+			errStrings: []string{fastCGINotFCGIMsg},
+		}),
+		Entry("with a unix socket upstream with no socket path", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "unix://",
+					},
+				},
+			},
+			errStrings: []string{unixNoSocketPathMsg},
+		}),
 		Entry("with a static upstream and invalid optons", &validateUpstreamTableInput{
 			upstreams: options.UpstreamConfig{
 				Upstreams: []options.Upstream{
@@ -208,5 +355,727 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{emptyURIMsg, staticCodeMsg},
 		}),
+		Entry("with a static response, but no static", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:             "foo",
+						Path:           "/foo",
+						URI:            "http://localhost:8080",
+						StaticResponse: &options.StaticResponse{Template: "Hello"},
+					},
+				},
+			},
+			errStrings: []string{staticResponseMsg},
+		}),
+		Entry("with a static response with an invalid template", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:             "foo",
+						Path:           "/foo",
+						Static:         true,
+						StaticResponse: &options.StaticResponse{Template: "{{ .Session.User }"},
+					},
+				},
+			},
+			errStrings: []string{invalidStaticTemplateMsg},
+		}),
+		Entry("with streaming on a static upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:        "foo",
+						Path:      "/foo",
+						Static:    true,
+						Streaming: &options.UpstreamStreaming{},
+					},
+				},
+			},
+			errStrings: []string{staticWithStreamingMsg},
+		}),
+		Entry("with http2 on a static upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						Static: true,
+						HTTP2:  true,
+					},
+				},
+			},
+			errStrings: []string{staticWithHTTP2Msg},
+		}),
+		Entry("with a valid load balanced upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URIs: []string{"http://localhost:8080", "http://localhost:8081"},
+						LoadBalancing: &options.LoadBalancing{
+							Policy:         options.LeastConnectionsPolicy,
+							StickySessions: true,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid load balanced upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						URIs: []string{"http://localhost:8080", "file://var/lib/foo"},
+						LoadBalancing: &options.LoadBalancing{
+							Policy: "random",
+						},
+					},
+				},
+			},
+			errStrings: []string{uriAndURIsMsg, invalidLoadBalancingSchemeMsg, invalidLoadBalancingPolicyMsg},
+		}),
+		Entry("with load balancing but no uris", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						LoadBalancing: &options.LoadBalancing{},
+					},
+				},
+			},
+			errStrings: []string{loadBalancingWithoutURIsMsg},
+		}),
+		Entry("with DNS SRV discovery", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						Discovery: &options.UpstreamDiscovery{
+							DNSSRV: "_http._tcp.app.default.svc.cluster.local",
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with Kubernetes discovery", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						Discovery: &options.UpstreamDiscovery{
+							Kubernetes: &options.KubernetesDiscovery{Service: "app"},
+							Scheme:     "https",
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with discovery but no method", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:        "foo",
+						Path:      "/foo",
+						Discovery: &options.UpstreamDiscovery{Scheme: "ftp"},
+					},
+				},
+			},
+			errStrings: []string{discoveryNoMethodMsg, discoveryInvalidSchemeMsg},
+		}),
+		Entry("with multiple discovery methods", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						Discovery: &options.UpstreamDiscovery{
+							DNSSRV:     "_http._tcp.app.default.svc.cluster.local",
+							Kubernetes: &options.KubernetesDiscovery{Service: "app"},
+						},
+					},
+				},
+			},
+			errStrings: []string{discoveryMultipleMethodsMsg},
+		}),
+		Entry("with Kubernetes discovery but no service", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						Discovery: &options.UpstreamDiscovery{
+							Kubernetes: &options.KubernetesDiscovery{},
+						},
+					},
+				},
+			},
+			errStrings: []string{discoveryNoServiceMsg},
+		}),
+		Entry("with valid retries and outlier detection", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Retries: &options.UpstreamRetries{
+							Attempts:      2,
+							BudgetPercent: 50,
+						},
+						OutlierDetection: &options.OutlierDetection{
+							ConsecutiveFailures: 3,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid retries and outlier detection", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Retries: &options.UpstreamRetries{
+							Attempts:      -1,
+							BudgetPercent: 101,
+						},
+						OutlierDetection: &options.OutlierDetection{
+							ConsecutiveFailures: -1,
+						},
+					},
+				},
+			},
+			errStrings: []string{invalidRetryAttemptsMsg, invalidRetryBudgetMsg, invalidConsecutiveFailuresMsg},
+		}),
+		Entry("with valid rewrite rules", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "^/foo/(.*)",
+						URI:  "http://localhost:8080",
+						RewriteRules: []options.RewriteRule{
+							{
+								Target:  "/${version}/$1",
+								Headers: []options.RewriteHeaderMatch{{Name: "X-Api-Version", Value: "^(?P<version>v[0-9]+)$"}},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid rewrite rules", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "^/foo/(.*)",
+						URI:  "http://localhost:8080",
+						RewriteRules: []options.RewriteRule{
+							{
+								Headers: []options.RewriteHeaderMatch{{Value: "("}},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{rewriteRuleNoTargetMsg, rewriteRuleNoHeaderNameMsg, rewriteRuleInvalidValueMsg},
+		}),
+		Entry("with valid allowed methods", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:             "foo",
+						Path:           "/foo",
+						URI:            "http://localhost:8080",
+						AllowedMethods: []string{"GET", "HEAD"},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid allowed methods", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:             "foo",
+						Path:           "/foo",
+						URI:            "http://localhost:8080",
+						AllowedMethods: []string{"GET HEAD"},
+					},
+				},
+			},
+			errStrings: []string{invalidAllowedMethodMsg},
+		}),
+		Entry("with a valid request header policy", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						RequestHeaders: &options.RequestHeaderPolicy{
+							Remove: []string{"X-Forwarded-User"},
+							Rename: []options.HeaderRename{{From: "X-Forwarded-Email", To: "X-Remote-User"}},
+							Set:    []options.StaticHeader{{Name: "X-Backend", Value: "foo"}},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid request header policy", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						RequestHeaders: &options.RequestHeaderPolicy{
+							Remove: []string{""},
+							Rename: []options.HeaderRename{{From: "X-Forwarded-Email"}},
+							Set:    []options.StaticHeader{{Value: "foo"}},
+						},
+					},
+				},
+			},
+			errStrings: []string{headerRemoveNoNameMsg, headerRenameMsg, headerSetNoNameMsg},
+		}),
+		Entry("with request header encryption without headers or a key", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						RequestHeaders: &options.RequestHeaderPolicy{
+							Encrypt: &options.HeaderEncryption{},
+						},
+					},
+				},
+			},
+			errStrings: []string{headerEncryptNoHeadersMsg, headerEncryptNoKeyMsg},
+		}),
+		Entry("with request header encryption with an invalid key", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						RequestHeaders: &options.RequestHeaderPolicy{
+							Encrypt: &options.HeaderEncryption{
+								Headers:       []string{"X-Forwarded-Access-Token", ""},
+								PublicKeyFile: "/dev/null",
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{headerEncryptNoNameMsg, headerEncryptInvalidKeyMsg},
+		}),
+		Entry("with a valid response header policy", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						ResponseHeaders: &options.ResponseHeaderPolicy{
+							Remove:  []string{"Server"},
+							Set:     []options.StaticHeader{{Name: "X-Frame-Options", Value: "DENY"}},
+							Default: []options.StaticHeader{{Name: "Cache-Control", Value: "no-store"}},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid response header policy", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						ResponseHeaders: &options.ResponseHeaderPolicy{
+							Remove:  []string{""},
+							Set:     []options.StaticHeader{{Value: "DENY"}},
+							Default: []options.StaticHeader{{Value: "no-store"}},
+						},
+					},
+				},
+			},
+			errStrings: []string{responseHeaderRemoveNoNameMsg, responseHeaderSetNoNameMsg, responseHeaderSetNoNameMsg},
+		}),
+		Entry("with valid compression", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Compression: &options.Compression{
+							ContentTypes: []string{"text/html", "application/json"},
+							MinSize:      512,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid compression", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Compression: &options.Compression{
+							ContentTypes: []string{"text/"},
+							MinSize:      -1,
+						},
+					},
+				},
+			},
+			errStrings: []string{compressionContentTypeMsg, compressionMinSizeMsg},
+		}),
+		Entry("with an invalid cache", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Cache: &options.ResponseCache{
+							MaxEntries:   -1,
+							MaxEntrySize: -1,
+						},
+					},
+				},
+			},
+			errStrings: []string{cacheMaxEntriesMsg, cacheMaxEntrySizeMsg},
+		}),
+		Entry("with a valid session override", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Session: &options.UpstreamSession{
+							RefreshPeriod:      &zeroDuration,
+							ValidationInterval: &oneMinute,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid session override", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Session: &options.UpstreamSession{
+							RefreshPeriod:      &negativeDuration,
+							ValidationInterval: &negativeDuration,
+						},
+					},
+				},
+			},
+			errStrings: []string{sessionRefreshPeriodMsg, sessionValidationIntervalMsg},
+		}),
+		Entry("with a valid shadow", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Shadow: &options.TrafficShadow{
+							URI:        "http://localhost:8081",
+							Percentage: &percentage,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a shadow with no uri", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						URI:    "http://localhost:8080",
+						Shadow: &options.TrafficShadow{},
+					},
+				},
+			},
+			errStrings: []string{shadowNoURIMsg},
+		}),
+		Entry("with an invalid shadow", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Shadow: &options.TrafficShadow{
+							URI:        "file:///tmp",
+							Percentage: &invalidPercentage,
+						},
+					},
+				},
+			},
+			errStrings: []string{shadowInvalidURIMsg, shadowPercentageMsg},
+		}),
+		Entry("with valid cors", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						CORS: &options.CORS{
+							AllowedOrigins:   []string{"https://*.example.com"},
+							AllowCredentials: true,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with cors with no allowed origins", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						CORS: &options.CORS{},
+					},
+				},
+			},
+			errStrings: []string{corsNoOriginsMsg},
+		}),
+		Entry("with cors allowing credentials for any origin", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						CORS: &options.CORS{
+							AllowedOrigins:   []string{"*"},
+							AllowCredentials: true,
+						},
+					},
+				},
+			},
+			errStrings: []string{corsCredentialsMsg},
+		}),
+		Entry("with valid WebSocket allowed origins", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:                      "foo",
+						Path:                    "/foo",
+						URI:                     "http://localhost:8080",
+						WebSocketAllowedOrigins: []string{"https://app.example.com", "https://*.example.com"},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid WebSocket allowed origin", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:                      "foo",
+						Path:                    "/foo",
+						URI:                     "http://localhost:8080",
+						WebSocketAllowedOrigins: []string{"https://["},
+					},
+				},
+			},
+			errStrings: []string{webSocketOriginMsg},
+		}),
+		Entry("with a valid health check", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						HealthCheck: &options.HealthCheck{
+							Path:               "/healthz",
+							HealthyThreshold:   1,
+							UnhealthyThreshold: 2,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid health check", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						HealthCheck: &options.HealthCheck{
+							HealthyThreshold:   -1,
+							UnhealthyThreshold: -2,
+						},
+					},
+				},
+			},
+			errStrings: []string{healthCheckNoPathMsg, invalidHealthyThresholdMsg, invalidUnhealthyThresholdMsg},
+		}),
+		Entry("with valid kubernetes impersonation", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "https://kubernetes.default.svc",
+						KubernetesImpersonation: &options.KubernetesImpersonation{
+							Token: &options.SecretSource{Value: []byte("token")},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with kubernetes impersonation without a token", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:                      "foo",
+						Path:                    "/foo",
+						URI:                     "https://kubernetes.default.svc",
+						KubernetesImpersonation: &options.KubernetesImpersonation{},
+					},
+				},
+			},
+			errStrings: []string{impersonationNoTokenMsg},
+		}),
+		Entry("with kubernetes impersonation on a file upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "file://var/lib/foo",
+						KubernetesImpersonation: &options.KubernetesImpersonation{
+							Token: &options.SecretSource{Value: []byte("token")},
+						},
+					},
+				},
+			},
+			errStrings: []string{fileWithImpersonationMsg},
+		}),
+		Entry("with kubernetes impersonation on a static upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						Static: true,
+						KubernetesImpersonation: &options.KubernetesImpersonation{
+							Token: &options.SecretSource{Value: []byte("token")},
+						},
+					},
+				},
+			},
+			errStrings: []string{staticWithImpersonationMsg},
+		}),
+		Entry("with valid spiffe", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "https://foo",
+						SPIFFE: &options.SPIFFE{
+							AuthorizedIDs: []string{"spiffe://example.org/foo"},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with spiffe and insecureSkipTLSVerify", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:                    "foo",
+						Path:                  "/foo",
+						URI:                   "https://foo",
+						InsecureSkipTLSVerify: true,
+						SPIFFE:                &options.SPIFFE{},
+					},
+				},
+			},
+			errStrings: []string{spiffeInsecureMsg},
+		}),
+		Entry("with an invalid spiffe authorized ID", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "https://foo",
+						SPIFFE: &options.SPIFFE{
+							AuthorizedIDs: []string{"https://example.org/redis"},
+						},
+					},
+				},
+			},
+			errStrings: []string{spiffeInvalidIDMsg},
+		}),
+		Entry("with a missing dynamic upstreams file", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams:            []options.Upstream{validHTTPUpstream},
+				DynamicUpstreamsFile: "/does/not/exist.yaml",
+			},
+			errStrings: []string{dynamicUpstreamsFileMsg},
+		}),
 	)
+
+	Context("ValidateDynamicUpstreams", func() {
+		configured := options.UpstreamConfig{
+			Upstreams: []options.Upstream{validHTTPUpstream},
+		}
+
+		It("allows upstreams that do not clash with the configured upstreams", func() {
+			Expect(ValidateDynamicUpstreams(configured, []options.Upstream{validStaticUpstream})).To(Succeed())
+		})
+
+		It("rejects upstreams that clash with the configured upstreams", func() {
+			dynamic := validStaticUpstream
+			dynamic.ID = validHTTPUpstream.ID
+			Expect(ValidateDynamicUpstreams(configured, []options.Upstream{dynamic})).To(MatchError(
+				"invalid upstreams:\n  multiple upstreams found with id \"validHTTPUpstream\": upstream ids must be unique",
+			))
+		})
+	})
 })
@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateUserInfo checks that the fields of the userinfo response have
+// names, and that no two fields are set at the same place in the response.
+func validateUserInfo(o *options.Options) []string {
+	msgs := []string{}
+	if o.UserInfo == nil {
+		return msgs
+	}
+
+	names := []string{}
+	for i, field := range o.UserInfo.Fields {
+		if field.Name == "" {
+			msgs = append(msgs, fmt.Sprintf("userInfo.fields[%d] has no name", i))
+			continue
+		}
+		for _, part := range strings.Split(field.Name, ".") {
+			if part == "" {
+				msgs = append(msgs, fmt.Sprintf("userInfo field %q has an empty path segment", field.Name))
+				break
+			}
+		}
+		names = append(names, field.Name)
+	}
+	if o.UserInfo.Groups {
+		names = append(names, "groups")
+	}
+
+	for i, name := range names {
+		for _, other := range names[:i] {
+			if name == other || strings.HasPrefix(name, other+".") || strings.HasPrefix(other, name+".") {
+				msgs = append(msgs, fmt.Sprintf("userInfo field %q conflicts with field %q", name, other))
+			}
+		}
+	}
+	return msgs
+}
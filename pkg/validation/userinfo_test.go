@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UserInfo", func() {
+	DescribeTable("validateUserInfo",
+		func(userInfo *options.UserInfo, errStrings []string) {
+			Expect(validateUserInfo(&options.Options{UserInfo: userInfo})).To(ConsistOf(errStrings))
+		},
+		Entry("without user info", nil, []string{}),
+		Entry("with valid fields", &options.UserInfo{
+			Fields: []options.UserInfoField{
+				{Name: "id", Claim: "user"},
+				{Name: "profile.email", Claim: "email"},
+				{Name: "profile.name", Claim: "name"},
+			},
+			Groups: true,
+		}, []string{}),
+		Entry("with invalid names", &options.UserInfo{
+			Fields: []options.UserInfoField{
+				{Claim: "user"},
+				{Name: "profile..email", Claim: "email"},
+				{Name: ".name"},
+			},
+		}, []string{
+			"userInfo.fields[0] has no name",
+			`userInfo field "profile..email" has an empty path segment`,
+			`userInfo field ".name" has an empty path segment`,
+		}),
+		Entry("with conflicting fields", &options.UserInfo{
+			Fields: []options.UserInfoField{
+				{Name: "email"},
+				{Name: "profile", Claim: "profile"},
+				{Name: "profile.name", Claim: "name"},
+				{Name: "email", Claim: "mail"},
+				{Name: "groups.admin", Claim: "admin"},
+			},
+			Groups: true,
+		}, []string{
+			`userInfo field "profile.name" conflicts with field "profile"`,
+			`userInfo field "email" conflicts with field "email"`,
+			`userInfo field "groups" conflicts with field "groups.admin"`,
+		}),
+	)
+})
@@ -42,6 +42,44 @@ func WatchFileForUpdates(filename string, done <-chan bool, action func()) error
 	return nil
 }
 
+// WatchDirForUpdates performs an action every time a file in a directory on
+// disk is created, updated, removed or renamed. Subdirectories are not
+// watched.
+func WatchDirForUpdates(dir string, done <-chan bool, action func()) error {
+	dir = filepath.Clean(dir)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher for '%s': %s", dir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-done:
+				logger.Printf("shutting down watcher for: %s", dir)
+				return
+			case event := <-watcher.Events:
+				// Only the permissions of the file changed
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				logger.Printf("reloading after event: %s", event)
+				action()
+			case err = <-watcher.Errors:
+				logger.Errorf("error watching '%s': %s", dir, err)
+			}
+		}
+	}()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to add '%s' to watcher: %v", dir, err)
+	}
+	logger.Printf("watching '%s' for updates", dir)
+
+	return nil
+}
+
 // Filter file operations based on the events sent by the watcher.
 // Execute the action() function when the following conditions are met:
 //  - the real path of the file was changed (Kubernetes ConfigMap/Secret)
@@ -0,0 +1,215 @@
+package workers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The results of the runs of a worker.
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Worker records the health of a background loop, such as the refresh of
+// secrets, in the metrics of the loop:
+//   - when it last ran, and when it last succeeded, as Unix timestamps
+//   - whether its last run succeeded
+//   - how many times it succeeded and failed
+//   - the interval at which it runs, if it runs periodically
+//
+// A loop that has stopped running is detected when the time since its last
+// run is much longer than its interval.
+type Worker struct {
+	runs        *prometheus.CounterVec
+	lastRun     prometheus.Gauge
+	lastSuccess prometheus.Gauge
+	healthy     prometheus.Gauge
+}
+
+// New registers the metrics of the worker with the default registry, or
+// reuses them if they are already registered.
+// The interval is the time between runs of a periodic worker, and is 0 for
+// workers that run when they have work to do.
+func New(name string, interval time.Duration) *Worker {
+	return newWorker(prometheus.DefaultRegisterer, name, interval)
+}
+
+func newWorker(registerer prometheus.Registerer, name string, interval time.Duration) *Worker {
+	labels := prometheus.Labels{"worker": name}
+	if interval > 0 {
+		registerIntervalGauge(registerer).With(labels).Set(interval.Seconds())
+	}
+	return &Worker{
+		runs:        registerRunsCounter(registerer).MustCurryWith(labels),
+		lastRun:     registerLastRunGauge(registerer).With(labels),
+		lastSuccess: registerLastSuccessGauge(registerer).With(labels),
+		healthy:     registerHealthyGauge(registerer).With(labels),
+	}
+}
+
+// Record records a run of the worker, which failed when the error is not nil.
+func (w *Worker) Record(err error) {
+	now := float64(time.Now().UnixNano()) / 1e9
+	w.lastRun.Set(now)
+	if err != nil {
+		w.runs.WithLabelValues(resultFailure).Inc()
+		w.healthy.Set(0)
+		return
+	}
+	w.runs.WithLabelValues(resultSuccess).Inc()
+	w.lastSuccess.Set(now)
+	w.healthy.Set(1)
+}
+
+// queue is the depth function of a queue registered by RegisterQueue.
+type queue struct {
+	depth func() int
+}
+
+// queueCollector collects the depth of the queues of workers when the
+// metrics are gathered.
+type queueCollector struct {
+	desc *prometheus.Desc
+
+	mutex  sync.RWMutex
+	queues map[string]*queue
+}
+
+func newQueueCollector() *queueCollector {
+	return &queueCollector{
+		desc: prometheus.NewDesc(
+			"oauth2_proxy_worker_queue_depth",
+			"Current number of items queued for the worker.",
+			[]string{"worker"}, nil,
+		),
+		queues: make(map[string]*queue),
+	}
+}
+
+// register sets the depth function of the queue of the worker, replacing the
+// one it had. It returns a function removing the queue, which does nothing
+// once the queue has been replaced.
+func (c *queueCollector) register(name string, depth func() int) func() {
+	q := &queue{depth: depth}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.queues[name] = q
+
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if c.queues[name] == q {
+			delete(c.queues, name)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for name, q := range c.queues {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(q.depth()), name)
+	}
+}
+
+var (
+	defaultQueues         = newQueueCollector()
+	registerDefaultQueues sync.Once
+)
+
+// RegisterQueue exposes the depth of the queue of the worker, as returned by
+// the depth function, in the default registry. The queue of a worker that is
+// registered again replaces the previous one.
+// It returns a function to call when the queue is no longer used.
+func RegisterQueue(name string, depth func() int) func() {
+	registerDefaultQueues.Do(func() {
+		prometheus.MustRegister(defaultQueues)
+	})
+	return defaultQueues.register(name, depth)
+}
+
+// registerRunsCounter registers the 'oauth2_proxy_worker_runs_total' metric.
+// This keeps a tally of the runs of each worker by their result.
+func registerRunsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_worker_runs_total",
+			Help: "Total number of runs of the worker by result.",
+		},
+		[]string{"worker", "result"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
+
+// registerLastRunGauge registers the
+// 'oauth2_proxy_worker_last_run_timestamp_seconds' metric.
+// This keeps the time of the last run of each worker.
+func registerLastRunGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	return registerGaugeVec(registerer, prometheus.GaugeOpts{
+		Name: "oauth2_proxy_worker_last_run_timestamp_seconds",
+		Help: "Unix timestamp of the last run of the worker.",
+	})
+}
+
+// registerLastSuccessGauge registers the
+// 'oauth2_proxy_worker_last_success_timestamp_seconds' metric.
+// This keeps the time of the last successful run of each worker.
+func registerLastSuccessGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	return registerGaugeVec(registerer, prometheus.GaugeOpts{
+		Name: "oauth2_proxy_worker_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful run of the worker.",
+	})
+}
+
+// registerHealthyGauge registers the 'oauth2_proxy_worker_healthy' metric.
+// This keeps whether the last run of each worker succeeded.
+func registerHealthyGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	return registerGaugeVec(registerer, prometheus.GaugeOpts{
+		Name: "oauth2_proxy_worker_healthy",
+		Help: "Whether the last run of the worker succeeded (1) or failed (0).",
+	})
+}
+
+// registerIntervalGauge registers the 'oauth2_proxy_worker_interval_seconds'
+// metric.
+// This keeps the interval at which each periodic worker runs.
+func registerIntervalGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	return registerGaugeVec(registerer, prometheus.GaugeOpts{
+		Name: "oauth2_proxy_worker_interval_seconds",
+		Help: "Interval at which the worker runs.",
+	})
+}
+
+// registerGaugeVec registers a gauge labelled by worker, or returns the
+// gauge that is already registered.
+func registerGaugeVec(registerer prometheus.Registerer, opts prometheus.GaugeOpts) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(opts, []string{"worker"})
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
@@ -0,0 +1,13 @@
+package workers
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWorkersSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Workers")
+}
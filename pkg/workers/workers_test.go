@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Workers Suite", func() {
+	var registry *prometheus.Registry
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+	})
+
+	It("records the runs of a worker", func() {
+		worker := newWorker(registry, "secrets", time.Minute)
+
+		before := float64(time.Now().Unix())
+		worker.Record(nil)
+		worker.Record(errors.New("unavailable"))
+
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP oauth2_proxy_worker_healthy Whether the last run of the worker succeeded (1) or failed (0).
+# TYPE oauth2_proxy_worker_healthy gauge
+oauth2_proxy_worker_healthy{worker="secrets"} 0
+# HELP oauth2_proxy_worker_interval_seconds Interval at which the worker runs.
+# TYPE oauth2_proxy_worker_interval_seconds gauge
+oauth2_proxy_worker_interval_seconds{worker="secrets"} 60
+# HELP oauth2_proxy_worker_runs_total Total number of runs of the worker by result.
+# TYPE oauth2_proxy_worker_runs_total counter
+oauth2_proxy_worker_runs_total{result="failure",worker="secrets"} 1
+oauth2_proxy_worker_runs_total{result="success",worker="secrets"} 1
+`), "oauth2_proxy_worker_healthy", "oauth2_proxy_worker_interval_seconds", "oauth2_proxy_worker_runs_total")).To(Succeed())
+		Expect(testutil.ToFloat64(worker.lastRun)).To(BeNumerically(">=", before))
+		Expect(testutil.ToFloat64(worker.lastSuccess)).To(BeNumerically(">=", before))
+	})
+
+	It("does not record the interval of a worker that is not periodic", func() {
+		worker := newWorker(registry, "audit_webhook", 0)
+		worker.Record(nil)
+
+		Expect(testutil.GatherAndCount(registry, "oauth2_proxy_worker_interval_seconds")).To(Equal(0))
+		Expect(testutil.ToFloat64(worker.healthy)).To(Equal(1.0))
+	})
+
+	It("does not record a success for a worker that has only failed", func() {
+		worker := newWorker(registry, "config_source", time.Minute)
+		worker.Record(errors.New("unavailable"))
+
+		Expect(testutil.ToFloat64(worker.lastRun)).To(BeNumerically(">", 0))
+		Expect(testutil.ToFloat64(worker.lastSuccess)).To(Equal(0.0))
+	})
+
+	Context("with queues", func() {
+		var queues *queueCollector
+
+		BeforeEach(func() {
+			queues = newQueueCollector()
+			Expect(registry.Register(queues)).To(Succeed())
+		})
+
+		It("collects the depth of the queues", func() {
+			depth := 3
+			queues.register("audit_webhook", func() int { return depth })
+			queues.register("audit_syslog", func() int { return 0 })
+			depth = 5
+
+			Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP oauth2_proxy_worker_queue_depth Current number of items queued for the worker.
+# TYPE oauth2_proxy_worker_queue_depth gauge
+oauth2_proxy_worker_queue_depth{worker="audit_syslog"} 0
+oauth2_proxy_worker_queue_depth{worker="audit_webhook"} 5
+`))).To(Succeed())
+		})
+
+		It("does not remove a queue that has been replaced", func() {
+			remove := queues.register("audit_webhook", func() int { return 1 })
+			queues.register("audit_webhook", func() int { return 2 })
+			remove()
+
+			Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP oauth2_proxy_worker_queue_depth Current number of items queued for the worker.
+# TYPE oauth2_proxy_worker_queue_depth gauge
+oauth2_proxy_worker_queue_depth{worker="audit_webhook"} 2
+`))).To(Succeed())
+		})
+
+		It("removes a queue", func() {
+			remove := queues.register("audit_webhook", func() int { return 1 })
+			remove()
+
+			Expect(testutil.GatherAndCount(registry, "oauth2_proxy_worker_queue_depth")).To(Equal(0))
+		})
+	})
+})
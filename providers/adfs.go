@@ -97,7 +97,7 @@ func (p *ADFSProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 }
 
 func (p *ADFSProvider) fallbackUPN(ctx context.Context, s *sessions.SessionState) error {
-	claims, err := p.getClaimExtractor(s.IDToken, s.AccessToken)
+	claims, err := p.getClaimExtractor(string(s.IDToken), string(s.AccessToken))
 	if err != nil {
 		return fmt.Errorf("could not extract claims: %v", err)
 	}
@@ -142,7 +142,7 @@ var _ = Describe("ADFS Provider Tests", func() {
 
 	Context("with bad token", func() {
 		It("should trigger an error", func() {
-			session := &sessions.SessionState{AccessToken: "unexpected_adfs_access_token", IDToken: "malformed_token"}
+			session := &sessions.SessionState{AccessToken: []byte("unexpected_adfs_access_token"), IDToken: []byte("malformed_token")}
 			err := p.EnrichSession(context.Background(), session)
 			Expect(err).NotTo(BeNil())
 		})
@@ -153,7 +153,7 @@ var _ = Describe("ADFS Provider Tests", func() {
 			rawIDToken, _ := newSignedTestIDToken(defaultIDToken)
 			session, err := p.buildSessionFromClaims(rawIDToken, "")
 			Expect(err).To(BeNil())
-			session.IDToken = rawIDToken
+			session.IDToken = []byte(rawIDToken)
 			err = p.EnrichSession(context.Background(), session)
 			Expect(session.Email).To(Equal("janed@me.com"))
 			Expect(err).To(BeNil())
@@ -233,7 +233,7 @@ var _ = Describe("ADFS Provider Tests", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			session = &sessions.SessionState{
-				IDToken: idToken,
+				IDToken: []byte(idToken),
 			}
 		})
 
@@ -11,7 +11,7 @@ import (
 var authorizedAccessToken = "imaginary_access_token"
 
 func CreateAuthorizedSession() *sessions.SessionState {
-	return &sessions.SessionState{AccessToken: authorizedAccessToken}
+	return &sessions.SessionState{AccessToken: []byte(authorizedAccessToken)}
 }
 
 func IsAuthorizedInHeader(reqHeader http.Header) bool {
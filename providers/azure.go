@@ -138,14 +138,14 @@ func (p *AzureProvider) Redeem(ctx context.Context, redirectURL, code, codeVerif
 	}
 
 	session := &sessions.SessionState{
-		AccessToken:  jsonResponse.AccessToken,
-		IDToken:      jsonResponse.IDToken,
-		RefreshToken: jsonResponse.RefreshToken,
+		AccessToken:  []byte(jsonResponse.AccessToken),
+		IDToken:      []byte(jsonResponse.IDToken),
+		RefreshToken: []byte(jsonResponse.RefreshToken),
 	}
 	session.CreatedAtNow()
 	session.SetExpiresOn(time.Unix(jsonResponse.ExpiresOn, 0))
 
-	email, err := p.verifyTokenAndExtractEmail(ctx, session.IDToken, session.AccessToken)
+	email, err := p.verifyTokenAndExtractEmail(ctx, string(session.IDToken), string(session.AccessToken))
 
 	// https://github.com/oauth2-proxy/oauth2-proxy/pull/914#issuecomment-782285814
 	// https://github.com/AzureAD/azure-activedirectory-library-for-java/issues/117
@@ -158,7 +158,7 @@ func (p *AzureProvider) Redeem(ctx context.Context, redirectURL, code, codeVerif
 	}
 
 	if session.Email == "" {
-		email, err = p.verifyTokenAndExtractEmail(ctx, session.AccessToken, session.AccessToken)
+		email, err = p.verifyTokenAndExtractEmail(ctx, string(session.AccessToken), string(session.AccessToken))
 		if err == nil && email != "" {
 			session.Email = email
 		} else {
@@ -175,7 +175,7 @@ func (p *AzureProvider) EnrichSession(ctx context.Context, s *sessions.SessionSt
 		return nil
 	}
 
-	email, err := p.getEmailFromProfileAPI(ctx, s.AccessToken)
+	email, err := p.getEmailFromProfileAPI(ctx, string(s.AccessToken))
 	if err != nil {
 		return fmt.Errorf("unable to get email address: %v", err)
 	}
@@ -236,7 +236,7 @@ func (p *AzureProvider) verifyTokenAndExtractEmail(ctx context.Context, rawIDTok
 
 // RefreshSession uses the RefreshToken to fetch new Access and ID Tokens
 func (p *AzureProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
-	if s == nil || s.RefreshToken == "" {
+	if s == nil || len(s.RefreshToken) == 0 {
 		return false, nil
 	}
 
@@ -257,7 +257,7 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 	params := url.Values{}
 	params.Add("client_id", p.ClientID)
 	params.Add("client_secret", clientSecret)
-	params.Add("refresh_token", s.RefreshToken)
+	params.Add("refresh_token", string(s.RefreshToken))
 	params.Add("grant_type", "refresh_token")
 
 	var jsonResponse struct {
@@ -278,14 +278,14 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 		return err
 	}
 
-	s.AccessToken = jsonResponse.AccessToken
-	s.IDToken = jsonResponse.IDToken
-	s.RefreshToken = jsonResponse.RefreshToken
+	s.AccessToken = []byte(jsonResponse.AccessToken)
+	s.IDToken = []byte(jsonResponse.IDToken)
+	s.RefreshToken = []byte(jsonResponse.RefreshToken)
 
 	s.CreatedAtNow()
 	s.SetExpiresOn(time.Unix(jsonResponse.ExpiresOn, 0))
 
-	email, err := p.verifyTokenAndExtractEmail(ctx, s.IDToken, s.AccessToken)
+	email, err := p.verifyTokenAndExtractEmail(ctx, string(s.IDToken), string(s.AccessToken))
 
 	// https://github.com/oauth2-proxy/oauth2-proxy/pull/914#issuecomment-782285814
 	// https://github.com/AzureAD/azure-activedirectory-library-for-java/issues/117
@@ -298,7 +298,7 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 	}
 
 	if s.Email == "" {
-		email, err = p.verifyTokenAndExtractEmail(ctx, s.AccessToken, s.AccessToken)
+		email, err = p.verifyTokenAndExtractEmail(ctx, string(s.AccessToken), string(s.AccessToken))
 		if err == nil && email != "" {
 			s.Email = email
 		} else {
@@ -338,6 +338,9 @@ func getEmailFromJSON(json *simplejson.Json) (string, error) {
 	return email, err
 }
 
+// getEmailFromProfileAPI gets the email of the user from the Graph /me
+// endpoint. It is not cached by the LookupCache, as the user is only known
+// once it is called.
 func (p *AzureProvider) getEmailFromProfileAPI(ctx context.Context, accessToken string) (string, error) {
 	if accessToken == "" {
 		return "", errors.New("missing access token")
@@ -357,5 +360,5 @@ func (p *AzureProvider) getEmailFromProfileAPI(ctx context.Context, accessToken
 
 // ValidateSession validates the AccessToken
 func (p *AzureProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeAzureHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeAzureHeader(string(s.AccessToken)))
 }
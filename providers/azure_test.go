@@ -331,10 +331,10 @@ func TestAzureProviderRedeem(t *testing.T) {
 				assert.NotNil(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, idTokenString, s.IDToken)
-				assert.Equal(t, accessTokenString, s.AccessToken)
+				assert.Equal(t, []byte(idTokenString), s.IDToken)
+				assert.Equal(t, []byte(accessTokenString), s.AccessToken)
 				assert.Equal(t, testCase.ExpiresOn.Unix(), s.ExpiresOn.Unix())
-				assert.Equal(t, testCase.RefreshToken, s.RefreshToken)
+				assert.Equal(t, []byte(testCase.RefreshToken), s.RefreshToken)
 				if testCase.EmailFromIDToken != "" {
 					assert.Equal(t, testCase.EmailFromIDToken, s.Email)
 				} else {
@@ -385,15 +385,15 @@ func TestAzureProviderRefresh(t *testing.T) {
 	p := testAzureProvider(bURL.Host, options.AzureOptions{})
 
 	expires := time.Now().Add(time.Duration(-1) * time.Hour)
-	session := &sessions.SessionState{AccessToken: "some_access_token", RefreshToken: refreshToken, IDToken: "some_id_token", ExpiresOn: &expires}
+	session := &sessions.SessionState{AccessToken: []byte("some_access_token"), RefreshToken: []byte(refreshToken), IDToken: []byte("some_id_token"), ExpiresOn: &expires}
 
 	refreshed, err := p.RefreshSession(context.Background(), session)
 	assert.Equal(t, nil, err)
 	assert.True(t, refreshed)
 	assert.NotEqual(t, session, nil)
-	assert.Equal(t, newAccessToken, session.AccessToken)
-	assert.Equal(t, "new_some_refresh_token", session.RefreshToken)
-	assert.Equal(t, idTokenString, session.IDToken)
+	assert.Equal(t, []byte(newAccessToken), session.AccessToken)
+	assert.Equal(t, []byte("new_some_refresh_token"), session.RefreshToken)
+	assert.Equal(t, []byte(idTokenString), session.IDToken)
 	assert.Equal(t, email, session.Email)
 	assert.Equal(t, timestamp, session.ExpiresOn.UTC())
 }
@@ -111,7 +111,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 		}
 	}
 
-	requestURL := p.ValidateURL.String() + "?access_token=" + s.AccessToken
+	requestURL := p.ValidateURL.String() + "?access_token=" + string(s.AccessToken)
 	err := requests.New(requestURL).
 		WithContext(ctx).
 		Do().
@@ -126,7 +126,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 		*teamURL = *p.ValidateURL
 		teamURL.Path = "/2.0/teams"
 
-		requestURL := teamURL.String() + "?role=member&access_token=" + s.AccessToken
+		requestURL := teamURL.String() + "?role=member&access_token=" + string(s.AccessToken)
 
 		err := requests.New(requestURL).
 			WithContext(ctx).
@@ -156,7 +156,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 
 		requestURL := repositoriesURL.String() + "?role=contributor" +
 			"&q=full_name=" + url.QueryEscape("\""+p.Repository+"\"") +
-			"&access_token=" + s.AccessToken
+			"&access_token=" + string(s.AccessToken)
 
 		err := requests.New(requestURL).
 			WithContext(ctx).
@@ -150,7 +150,7 @@ func TestBitbucketProviderGetEmailAddressFailedRequest(t *testing.T) {
 	// We'll trigger a request failure by using an unexpected access
 	// token. Alternatively, we could allow the parsing of the payload as
 	// JSON to fail.
-	session := &sessions.SessionState{AccessToken: "unexpected_access_token"}
+	session := &sessions.SessionState{AccessToken: []byte("unexpected_access_token")}
 	email, err := p.GetEmailAddress(context.Background(), session)
 	assert.NotEqual(t, nil, err)
 	assert.Equal(t, "", email)
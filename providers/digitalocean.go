@@ -64,13 +64,13 @@ func NewDigitalOceanProvider(p *ProviderData) *DigitalOceanProvider {
 
 // GetEmailAddress returns the Account email address
 func (p *DigitalOceanProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
-	if s.AccessToken == "" {
+	if len(s.AccessToken) == 0 {
 		return "", errors.New("missing access token")
 	}
 
 	json, err := requests.New(p.ProfileURL.String()).
 		WithContext(ctx).
-		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		WithHeaders(makeOIDCHeader(string(s.AccessToken))).
 		Do().
 		UnmarshalJSON()
 	if err != nil {
@@ -86,5 +86,5 @@ func (p *DigitalOceanProvider) GetEmailAddress(ctx context.Context, s *sessions.
 
 // ValidateSession validates the AccessToken
 func (p *DigitalOceanProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeOIDCHeader(string(s.AccessToken)))
 }
@@ -64,7 +64,7 @@ func NewFacebookProvider(p *ProviderData) *FacebookProvider {
 
 // GetEmailAddress returns the Account email address
 func (p *FacebookProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
-	if s.AccessToken == "" {
+	if len(s.AccessToken) == 0 {
 		return "", errors.New("missing access token")
 	}
 
@@ -76,7 +76,7 @@ func (p *FacebookProvider) GetEmailAddress(ctx context.Context, s *sessions.Sess
 	requestURL := p.ProfileURL.String() + "?fields=name,email"
 	err := requests.New(requestURL).
 		WithContext(ctx).
-		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		WithHeaders(makeOIDCHeader(string(s.AccessToken))).
 		Do().
 		UnmarshalInto(&r)
 	if err != nil {
@@ -91,5 +91,5 @@ func (p *FacebookProvider) GetEmailAddress(ctx context.Context, s *sessions.Sess
 
 // ValidateSession validates the AccessToken
 func (p *FacebookProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeOIDCHeader(string(s.AccessToken)))
 }
@@ -109,8 +109,48 @@ func (p *GitHubProvider) setUsers(users []string) {
 	p.Users = users
 }
 
+// githubLookupSession is the lookup of the email and user of a session,
+// which checks the org, team, repository and collaborator restrictions.
+const githubLookupSession = "github:session"
+
+// githubSession is the cached email and user of a session.
+type githubSession struct {
+	email string
+	user  string
+}
+
 // EnrichSession updates the User & Email after the initial Redeem
+// When the LookupCache is enabled, they are cached for the login of the user
+// so that the restrictions are not checked again on each of their logins.
 func (p *GitHubProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if p.LookupCache == nil {
+		return p.enrichSession(ctx, s)
+	}
+
+	login, err := p.getLogin(ctx, string(s.AccessToken))
+	if err != nil {
+		return err
+	}
+	key := lookupCacheKey{user: login, lookup: githubLookupSession}
+	if cached, ok := p.LookupCache.get(key); ok {
+		session := cached.(githubSession)
+		s.Email = session.email
+		s.User = session.user
+		return nil
+	}
+
+	if err := p.enrichSession(ctx, s); err != nil {
+		return err
+	}
+	// Sessions without an email failed the restrictions, and are checked
+	// again on the next login
+	if s.Email != "" && s.User == login {
+		p.LookupCache.store(key, githubSession{email: s.Email, user: s.User})
+	}
+	return nil
+}
+
+func (p *GitHubProvider) enrichSession(ctx context.Context, s *sessions.SessionState) error {
 	err := p.getEmail(ctx, s)
 	if err != nil {
 		return err
@@ -120,7 +160,7 @@ func (p *GitHubProvider) EnrichSession(ctx context.Context, s *sessions.SessionS
 
 // ValidateSession validates the AccessToken
 func (p *GitHubProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeGitHubHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeGitHubHeader(string(s.AccessToken)))
 }
 
 func (p *GitHubProvider) hasOrg(ctx context.Context, accessToken string) (bool, error) {
@@ -359,6 +399,31 @@ func (p *GitHubProvider) hasUser(ctx context.Context, accessToken string) (bool,
 	return false, nil
 }
 
+// getLogin returns the login of the authenticated user
+func (p *GitHubProvider) getLogin(ctx context.Context, accessToken string) (string, error) {
+	// https://developer.github.com/v3/users/#get-the-authenticated-user
+
+	var user struct {
+		Login string `json:"login"`
+	}
+
+	endpoint := &url.URL{
+		Scheme: p.ValidateURL.Scheme,
+		Host:   p.ValidateURL.Host,
+		Path:   path.Join(p.ValidateURL.Path, "/user"),
+	}
+
+	err := requests.New(endpoint.String()).
+		WithContext(ctx).
+		WithHeaders(makeGitHubHeader(accessToken)).
+		Do().
+		UnmarshalInto(&user)
+	if err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
 func (p *GitHubProvider) isCollaborator(ctx context.Context, username, accessToken string) (bool, error) {
 	//https://developer.github.com/v3/repos/collaborators/#check-if-a-user-is-a-collaborator
 
@@ -398,7 +463,7 @@ func (p *GitHubProvider) getEmail(ctx context.Context, s *sessions.SessionState)
 	verifiedUser := false
 	if len(p.Users) > 0 {
 		var err error
-		verifiedUser, err = p.hasUser(ctx, s.AccessToken)
+		verifiedUser, err = p.hasUser(ctx, string(s.AccessToken))
 		if err != nil {
 			return err
 		}
@@ -411,16 +476,16 @@ func (p *GitHubProvider) getEmail(ctx context.Context, s *sessions.SessionState)
 	if !verifiedUser {
 		if p.Org != "" {
 			if p.Team != "" {
-				if ok, err := p.hasOrgAndTeam(ctx, s.AccessToken); err != nil || !ok {
+				if ok, err := p.hasOrgAndTeam(ctx, string(s.AccessToken)); err != nil || !ok {
 					return err
 				}
 			} else {
-				if ok, err := p.hasOrg(ctx, s.AccessToken); err != nil || !ok {
+				if ok, err := p.hasOrg(ctx, string(s.AccessToken)); err != nil || !ok {
 					return err
 				}
 			}
 		} else if p.Repo != "" && p.Token == "" { // If we have a token we'll do the collaborator check in GetUserName
-			if ok, err := p.hasRepo(ctx, s.AccessToken); err != nil || !ok {
+			if ok, err := p.hasRepo(ctx, string(s.AccessToken)); err != nil || !ok {
 				return err
 			}
 		}
@@ -433,7 +498,7 @@ func (p *GitHubProvider) getEmail(ctx context.Context, s *sessions.SessionState)
 	}
 	err := requests.New(endpoint.String()).
 		WithContext(ctx).
-		WithHeaders(makeGitHubHeader(s.AccessToken)).
+		WithHeaders(makeGitHubHeader(string(s.AccessToken))).
 		Do().
 		UnmarshalInto(&emails)
 	if err != nil {
@@ -467,7 +532,7 @@ func (p *GitHubProvider) getUser(ctx context.Context, s *sessions.SessionState)
 
 	err := requests.New(endpoint.String()).
 		WithContext(ctx).
-		WithHeaders(makeGitHubHeader(s.AccessToken)).
+		WithHeaders(makeGitHubHeader(string(s.AccessToken))).
 		Do().
 		UnmarshalInto(&user)
 	if err != nil {
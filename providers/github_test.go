@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -278,7 +279,7 @@ func TestGitHubProvider_getEmailFailedRequest(t *testing.T) {
 	// We'll trigger a request failure by using an unexpected access
 	// token. Alternatively, we could allow the parsing of the payload as
 	// JSON to fail.
-	session := &sessions.SessionState{AccessToken: "unexpected_access_token"}
+	session := &sessions.SessionState{AccessToken: []byte("unexpected_access_token")}
 	err := p.getEmail(context.Background(), session)
 	assert.Error(t, err)
 	assert.Empty(t, session.Email)
@@ -440,3 +441,63 @@ func TestGitHubProvider_getEmailWithUsernameAndNoAccessToPrivateRepo(t *testing.
 	assert.NoError(t, err)
 	assert.Equal(t, "michael.bland@gsa.gov", session.Email)
 }
+
+func TestGitHubProvider_EnrichSessionWithLookupCache(t *testing.T) {
+	requests := map[string]int{}
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"login": "mbland"}`))
+		case "/user/emails":
+			w.Write([]byte(`[ {"email": "michael.bland@gsa.gov", "verified": true, "primary": true} ]`))
+		case "/user/orgs":
+			if r.URL.Query().Get("page") == "1" {
+				w.Write([]byte(`[ {"login":"testorg"} ]`))
+			} else {
+				w.Write([]byte(`[ ]`))
+			}
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testGitHubProvider(bURL.Host, options.GitHubOptions{Org: "testorg"})
+	p.LookupCache = NewLookupCache(time.Minute, 10)
+
+	for i := 0; i < 2; i++ {
+		session := CreateAuthorizedSession()
+		err := p.EnrichSession(context.Background(), session)
+		assert.NoError(t, err)
+		assert.Equal(t, "michael.bland@gsa.gov", session.Email)
+		assert.Equal(t, "mbland", session.User)
+	}
+	assert.Equal(t, 2, requests["/user/orgs"])
+	assert.Equal(t, 1, requests["/user/emails"])
+
+	assert.Equal(t, 1, p.LookupCache.Flush("mbland"))
+	err := p.EnrichSession(context.Background(), CreateAuthorizedSession())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests["/user/emails"])
+}
+
+func TestGitHubProvider_EnrichSessionWithLookupCacheNotInOrg(t *testing.T) {
+	b := testGitHubBackend(map[string][]string{
+		"/user":        {`{"login": "mbland"}`},
+		"/user/emails": {`[ {"email": "michael.bland@gsa.gov", "verified": true, "primary": true} ]`},
+		"/user/orgs":   {`[ {"login":"testorg"} ]`, `[ ]`},
+	})
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testGitHubProvider(bURL.Host, options.GitHubOptions{Org: "otherorg"})
+	p.LookupCache = NewLookupCache(time.Minute, 10)
+
+	session := CreateAuthorizedSession()
+	err := p.EnrichSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.Empty(t, session.Email)
+	assert.Equal(t, 0, p.LookupCache.Flush(""))
+}
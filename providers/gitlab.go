@@ -167,7 +167,7 @@ func (p *GitLabProvider) getUserinfo(ctx context.Context, s *sessions.SessionSta
 	var userinfo gitlabUserinfo
 	err := requests.New(userinfoURL.String()).
 		WithContext(ctx).
-		SetHeader("Authorization", "Bearer "+s.AccessToken).
+		SetHeader("Authorization", "Bearer "+string(s.AccessToken)).
 		Do().
 		UnmarshalInto(&userinfo)
 	if err != nil {
@@ -235,7 +235,19 @@ type gitlabProjectInfo struct {
 	Permissions       gitlabProjectPermission `json:"permissions"`
 }
 
+// getProjectInfo returns the information of the project, which is cached for
+// the user by the LookupCache.
 func (p *GitLabProvider) getProjectInfo(ctx context.Context, s *sessions.SessionState, project string) (*gitlabProjectInfo, error) {
+	projectInfo, err := p.LookupCache.lookup(s.User, gitlabProjectPrefix+project, func() (interface{}, error) {
+		return p.fetchProjectInfo(ctx, s, project)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return projectInfo.(*gitlabProjectInfo), nil
+}
+
+func (p *GitLabProvider) fetchProjectInfo(ctx context.Context, s *sessions.SessionState, project string) (*gitlabProjectInfo, error) {
 	var projectInfo gitlabProjectInfo
 
 	endpointURL := &url.URL{
@@ -246,7 +258,7 @@ func (p *GitLabProvider) getProjectInfo(ctx context.Context, s *sessions.Session
 
 	err := requests.New(fmt.Sprintf("%s%s", endpointURL.String(), url.QueryEscape(project))).
 		WithContext(ctx).
-		SetHeader("Authorization", "Bearer "+s.AccessToken).
+		SetHeader("Authorization", "Bearer "+string(s.AccessToken)).
 		Do().
 		UnmarshalInto(&projectInfo)
 	if err != nil {
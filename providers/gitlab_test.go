@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -173,7 +174,7 @@ var _ = Describe("Gitlab Provider Tests", func() {
 	Context("with bad token", func() {
 		It("should trigger an error", func() {
 			p.AllowUnverifiedEmail = false
-			session := &sessions.SessionState{AccessToken: "unexpected_gitlab_access_token"}
+			session := &sessions.SessionState{AccessToken: []byte("unexpected_gitlab_access_token")}
 			err := p.EnrichSession(context.Background(), session)
 			Expect(err).To(MatchError(errors.New("failed to retrieve user info: error getting user info: unexpected status \"401\": ")))
 		})
@@ -189,7 +190,7 @@ var _ = Describe("Gitlab Provider Tests", func() {
 		DescribeTable("should return expected results",
 			func(in emailsTableInput) {
 				p.AllowUnverifiedEmail = in.allowUnverifiedEmail
-				session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+				session := &sessions.SessionState{AccessToken: []byte("gitlab_access_token")}
 
 				err := p.EnrichSession(context.Background(), session)
 
@@ -240,7 +241,7 @@ var _ = Describe("Gitlab Provider Tests", func() {
 				}
 
 				p.AllowUnverifiedEmail = true
-				session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+				session := &sessions.SessionState{AccessToken: []byte("gitlab_access_token")}
 
 				Expect(p.Scope).To(Equal(in.expectedScope))
 
@@ -316,6 +317,31 @@ var _ = Describe("Gitlab Provider Tests", func() {
 		)
 	})
 
+	Context("with a lookup cache", func() {
+		It("caches the project information of the user", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.GitLabOptions{
+				Projects: []string{"my_group/my_project"},
+			})
+			Expect(err).To(BeNil())
+			p.AllowUnverifiedEmail = true
+			p.LookupCache = NewLookupCache(time.Minute, 10)
+
+			session := &sessions.SessionState{AccessToken: []byte("gitlab_access_token")}
+			Expect(p.EnrichSession(context.Background(), session)).To(Succeed())
+			Expect(session.Groups).To(ContainElement("project:my_group/my_project"))
+			Expect(p.LookupCache.entries).To(HaveKey(lookupCacheKey{user: "FooBar", lookup: "project:my_group/my_project"}))
+
+			// The cached information is used while the projects API is unavailable
+			b.Close()
+			projects := &sessions.SessionState{User: "FooBar", AccessToken: []byte("gitlab_access_token")}
+			p.addProjectsToSession(context.Background(), projects)
+			Expect(projects.Groups).To(Equal([]string{"project:my_group/my_project"}))
+		})
+	})
+
 	Context("when refreshing", func() {
 		It("keeps the existing nickname after refreshing", func() {
 			session := &sessions.SessionState{
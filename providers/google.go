@@ -184,9 +184,9 @@ func (p *GoogleProvider) Redeem(ctx context.Context, redirectURL, code, codeVeri
 	}
 
 	ss := &sessions.SessionState{
-		AccessToken:  jsonResponse.AccessToken,
-		IDToken:      jsonResponse.IDToken,
-		RefreshToken: jsonResponse.RefreshToken,
+		AccessToken:  []byte(jsonResponse.AccessToken),
+		IDToken:      []byte(jsonResponse.IDToken),
+		RefreshToken: []byte(jsonResponse.RefreshToken),
 		Email:        c.Email,
 		User:         c.Subject,
 	}
@@ -287,7 +287,7 @@ func userInGroup(service *admin.Service, group string, email string) bool {
 
 // RefreshSession uses the RefreshToken to fetch new Access and ID Tokens
 func (p *GoogleProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
-	if s == nil || s.RefreshToken == "" {
+	if s == nil || len(s.RefreshToken) == 0 {
 		return false, nil
 	}
 
@@ -317,7 +317,7 @@ func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.Ses
 	params := url.Values{}
 	params.Add("client_id", p.ClientID)
 	params.Add("client_secret", clientSecret)
-	params.Add("refresh_token", s.RefreshToken)
+	params.Add("refresh_token", string(s.RefreshToken))
 	params.Add("grant_type", "refresh_token")
 
 	var data struct {
@@ -337,8 +337,8 @@ func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.Ses
 		return err
 	}
 
-	s.AccessToken = data.AccessToken
-	s.IDToken = data.IDToken
+	s.AccessToken = []byte(data.AccessToken)
+	s.IDToken = []byte(data.IDToken)
 
 	s.CreatedAtNow()
 	s.ExpiresIn(time.Duration(data.ExpiresIn) * time.Second)
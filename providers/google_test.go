@@ -116,8 +116,8 @@ func TestGoogleProviderGetEmailAddress(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.NotEqual(t, session, nil)
 	assert.Equal(t, "michael.bland@gsa.gov", session.Email)
-	assert.Equal(t, "a1234", session.AccessToken)
-	assert.Equal(t, "refresh12345", session.RefreshToken)
+	assert.Equal(t, []byte("a1234"), session.AccessToken)
+	assert.Equal(t, []byte("refresh12345"), session.RefreshToken)
 }
 
 func TestGoogleProviderGroupValidator(t *testing.T) {
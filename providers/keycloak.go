@@ -66,6 +66,8 @@ func NewKeycloakProvider(p *ProviderData, opts options.KeycloakOptions) *Keycloa
 
 // EnrichSession uses the Keycloak userinfo endpoint to populate the session's
 // email and groups.
+// The userinfo call is not cached by the LookupCache, as it is what identifies
+// the user.
 func (p *KeycloakProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
 	// Fallback to ValidateURL if ProfileURL not set for legacy compatibility
 	profileURL := p.ValidateURL.String()
@@ -75,7 +77,7 @@ func (p *KeycloakProvider) EnrichSession(ctx context.Context, s *sessions.Sessio
 
 	json, err := requests.New(profileURL).
 		WithContext(ctx).
-		SetHeader("Authorization", "Bearer "+s.AccessToken).
+		SetHeader("Authorization", "Bearer "+string(s.AccessToken)).
 		Do().
 		UnmarshalJSON()
 	if err != nil {
@@ -103,5 +105,5 @@ func (p *KeycloakProvider) EnrichSession(ctx context.Context, s *sessions.Sessio
 
 // ValidateSession validates the AccessToken
 func (p *KeycloakProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeOIDCHeader(string(s.AccessToken)))
 }
@@ -124,17 +124,17 @@ var _ = Describe("Keycloak OIDC Provider Tests", func() {
 				User:         "already",
 				Email:        "a@b.com",
 				Groups:       nil,
-				IDToken:      idToken,
-				AccessToken:  getAccessToken(),
-				RefreshToken: refreshToken,
+				IDToken:      []byte(idToken),
+				AccessToken:  []byte(getAccessToken()),
+				RefreshToken: []byte(refreshToken),
 			}
 			expectedSession := &sessions.SessionState{
 				User:         "already",
 				Email:        "a@b.com",
 				Groups:       []string{"role:write", "role:default:read"},
-				IDToken:      idToken,
-				AccessToken:  getAccessToken(),
-				RefreshToken: refreshToken,
+				IDToken:      []byte(idToken),
+				AccessToken:  []byte(getAccessToken()),
+				RefreshToken: []byte(refreshToken),
 			}
 
 			err = provider.EnrichSession(context.Background(), existingSession)
@@ -154,17 +154,17 @@ var _ = Describe("Keycloak OIDC Provider Tests", func() {
 				User:         "already",
 				Email:        "a@b.com",
 				Groups:       []string{"existing", "group"},
-				IDToken:      idToken,
-				AccessToken:  getAccessToken(),
-				RefreshToken: refreshToken,
+				IDToken:      []byte(idToken),
+				AccessToken:  []byte(getAccessToken()),
+				RefreshToken: []byte(refreshToken),
 			}
 			expectedSession := &sessions.SessionState{
 				User:         "already",
 				Email:        "a@b.com",
 				Groups:       []string{"existing", "group", "role:write", "role:default:read"},
-				IDToken:      idToken,
-				AccessToken:  getAccessToken(),
-				RefreshToken: refreshToken,
+				IDToken:      []byte(idToken),
+				AccessToken:  []byte(getAccessToken()),
+				RefreshToken: []byte(refreshToken),
 			}
 
 			err = provider.EnrichSession(context.Background(), existingSession)
@@ -186,9 +186,9 @@ var _ = Describe("Keycloak OIDC Provider Tests", func() {
 				User:         "already",
 				Email:        "a@b.com",
 				Groups:       nil,
-				IDToken:      idToken,
-				AccessToken:  getAccessToken(),
-				RefreshToken: refreshToken,
+				IDToken:      []byte(idToken),
+				AccessToken:  []byte(getAccessToken()),
+				RefreshToken: []byte(refreshToken),
 			}
 
 			refreshed, err := provider.RefreshSession(context.Background(), existingSession)
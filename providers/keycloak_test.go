@@ -110,7 +110,7 @@ var _ = Describe("Keycloak Provider Tests", func() {
 				)
 				Expect(err).To(BeNil())
 
-				session := &sessions.SessionState{AccessToken: keycloakAccessToken}
+				session := &sessions.SessionState{AccessToken: []byte(keycloakAccessToken)}
 				err = p.EnrichSession(context.Background(), session)
 
 				if in.expectedError != nil {
@@ -81,14 +81,14 @@ func makeLinkedInHeader(accessToken string) http.Header {
 
 // GetEmailAddress returns the Account email address
 func (p *LinkedInProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
-	if s.AccessToken == "" {
+	if len(s.AccessToken) == 0 {
 		return "", errors.New("missing access token")
 	}
 
 	requestURL := p.ProfileURL.String() + "?q=members&projection=(elements*(handle~))"
 	json, err := requests.New(requestURL).
 		WithContext(ctx).
-		WithHeaders(makeLinkedInHeader(s.AccessToken)).
+		WithHeaders(makeLinkedInHeader(string(s.AccessToken))).
 		Do().
 		UnmarshalJSON()
 	if err != nil {
@@ -103,5 +103,5 @@ func (p *LinkedInProvider) GetEmailAddress(ctx context.Context, s *sessions.Sess
 
 // ValidateSession validates the AccessToken
 func (p *LinkedInProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeLinkedInHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeLinkedInHeader(string(s.AccessToken)))
 }
@@ -261,8 +261,8 @@ func (p *LoginGovProvider) Redeem(ctx context.Context, _, code, codeVerifier str
 	}
 
 	session := &sessions.SessionState{
-		AccessToken: jsonResponse.AccessToken,
-		IDToken:     jsonResponse.IDToken,
+		AccessToken: []byte(jsonResponse.AccessToken),
+		IDToken:     []byte(jsonResponse.IDToken),
 		Email:       email,
 	}
 
@@ -285,5 +285,5 @@ func (p *LoginGovProvider) GetLoginURL(redirectURI, state, _ string, extraParams
 
 // ValidateSession validates the AccessToken
 func (p *LoginGovProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeOIDCHeader(string(s.AccessToken)))
 }
@@ -239,7 +239,7 @@ func TestLoginGovProviderSessionData(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEqual(t, session, nil)
 	assert.Equal(t, "timothy.spencer@gsa.gov", session.Email)
-	assert.Equal(t, "a1234", session.AccessToken)
+	assert.Equal(t, []byte("a1234"), session.AccessToken)
 
 	// The test ought to run in under 2 seconds.  If not, you may need to bump this up.
 	assert.InDelta(t, session.ExpiresOn.Unix(), time.Now().Unix()+expiresIn, 2)
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// LookupCache caches the results of the provider API calls made for each user
+// when they sign in, such as their GitHub team memberships or their GitLab
+// project permissions, so that they are not looked up again until they
+// expire.
+// Changes to the memberships of a user are only seen once their cached
+// lookups expire, or are flushed.
+// The calls that identify the user, such as the Azure Graph /me call and the
+// Keycloak userinfo call, are not cached, as there is no user to key them by
+// before they are made.
+// A nil LookupCache caches nothing.
+type LookupCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	entries map[lookupCacheKey]lookupCacheEntry
+}
+
+// lookupCacheKey identifies a lookup for a user.
+type lookupCacheKey struct {
+	user   string
+	lookup string
+}
+
+// lookupCacheEntry is the cached result of a lookup.
+type lookupCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewLookupCache creates a cache holding the results of at most maxEntries
+// lookups for the ttl. It returns nil, caching nothing, when the ttl is not
+// positive.
+func NewLookupCache(ttl time.Duration, maxEntries int) *LookupCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &LookupCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[lookupCacheKey]lookupCacheEntry),
+	}
+}
+
+// lookup returns the cached result of the lookup for the user, or the result
+// of fetch, which is cached unless it fails.
+// Lookups are not cached for unknown users.
+func (c *LookupCache) lookup(user, lookup string, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil || user == "" {
+		return fetch()
+	}
+
+	key := lookupCacheKey{user: user, lookup: lookup}
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, value)
+	return value, nil
+}
+
+// get returns the cached result if it has not expired.
+func (c *LookupCache) get(key lookupCacheKey) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// store caches the result, removing expired entries when the cache is full.
+func (c *LookupCache) store(key lookupCacheKey, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		// Drop an arbitrary entry, it will be looked up again when needed
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = lookupCacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// Flush removes the cached lookups of the user, or of all users when the
+// user is empty, and returns the number of lookups removed.
+func (c *LookupCache) Flush(user string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if user == "" {
+		flushed := len(c.entries)
+		c.entries = make(map[lookupCacheKey]lookupCacheEntry)
+		return flushed
+	}
+
+	flushed := 0
+	for key := range c.entries {
+		if key.user == user {
+			delete(c.entries, key)
+			flushed++
+		}
+	}
+	return flushed
+}
+
+// FlushHandler flushes the cached lookups on DELETE requests, of the user
+// given by the user query parameter or of all users when it is not given.
+func (c *LookupCache) FlushHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			rw.Header().Set("Allow", "DELETE")
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := req.URL.Query().Get("user")
+		flushed := c.Flush(user)
+		if user == "" {
+			logger.Printf("Flushed %d cached provider lookups", flushed)
+		} else {
+			logger.Printf("Flushed %d cached provider lookups of user %q", flushed, user)
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(struct {
+			Flushed int `json:"flushed"`
+		}{Flushed: flushed}); err != nil {
+			logger.Errorf("Error encoding flushed provider lookups: %v", err)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lookup Cache", func() {
+	var cache *LookupCache
+	var now time.Time
+	var fetches int
+
+	fetch := func(value string) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			fetches++
+			return value, nil
+		}
+	}
+
+	BeforeEach(func() {
+		now = time.Now()
+		fetches = 0
+		cache = NewLookupCache(time.Minute, 2)
+		cache.now = func() time.Time { return now }
+	})
+
+	It("is disabled without a ttl", func() {
+		Expect(NewLookupCache(0, 2)).To(BeNil())
+
+		var disabled *LookupCache
+		for i := 0; i < 2; i++ {
+			value, err := disabled.lookup("user", "groups", fetch("a"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("a"))
+		}
+		Expect(fetches).To(Equal(2))
+		Expect(disabled.Flush("")).To(Equal(0))
+	})
+
+	It("caches the lookups of each user until they expire", func() {
+		value, err := cache.lookup("user", "groups", fetch("a"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("a"))
+
+		value, err = cache.lookup("user", "groups", fetch("b"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("a"))
+		Expect(fetches).To(Equal(1))
+
+		value, err = cache.lookup("other", "groups", fetch("c"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("c"))
+		Expect(fetches).To(Equal(2))
+
+		now = now.Add(time.Minute)
+		value, err = cache.lookup("user", "groups", fetch("d"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("d"))
+		Expect(fetches).To(Equal(3))
+	})
+
+	It("does not cache failed lookups or unknown users", func() {
+		failure := errors.New("failed")
+		_, err := cache.lookup("user", "groups", func() (interface{}, error) {
+			return nil, failure
+		})
+		Expect(err).To(MatchError(failure))
+
+		_, err = cache.lookup("", "groups", fetch("a"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("holds at most the maximum number of lookups", func() {
+		for _, user := range []string{"a", "b", "c"} {
+			_, err := cache.lookup(user, "groups", fetch(user))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(cache.entries).To(HaveLen(2))
+		Expect(cache.entries).To(HaveKey(lookupCacheKey{user: "c", lookup: "groups"}))
+	})
+
+	It("flushes the lookups of a user or of all users", func() {
+		for _, key := range []lookupCacheKey{{"a", "groups"}, {"a", "teams"}, {"b", "groups"}} {
+			cache.maxEntries = 3
+			_, err := cache.lookup(key.user, key.lookup, fetch(key.user))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(cache.Flush("a")).To(Equal(2))
+		Expect(cache.entries).To(HaveLen(1))
+		Expect(cache.Flush("")).To(Equal(1))
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	Context("FlushHandler", func() {
+		It("flushes the lookups of the user given on DELETE requests", func() {
+			_, err := cache.lookup("a", "groups", fetch("a"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cache.lookup("b", "groups", fetch("b"))
+			Expect(err).ToNot(HaveOccurred())
+
+			rw := httptest.NewRecorder()
+			cache.FlushHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/provider-lookup-cache?user=a", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Body.String()).To(MatchJSON(`{"flushed":1}`))
+
+			rw = httptest.NewRecorder()
+			cache.FlushHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/provider-lookup-cache", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Body.String()).To(MatchJSON(`{"flushed":1}`))
+		})
+
+		It("rejects other methods", func() {
+			rw := httptest.NewRecorder()
+			cache.FlushHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/provider-lookup-cache", nil))
+			Expect(rw.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(rw.Header().Get("Allow")).To(Equal("DELETE"))
+		})
+	})
+})
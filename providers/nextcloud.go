@@ -42,7 +42,7 @@ func (p *NextcloudProvider) EnrichSession(ctx context.Context, s *sessions.Sessi
 
 	json, err := requests.New(profileURL).
 		WithContext(ctx).
-		SetHeader("Authorization", "Bearer "+s.AccessToken).
+		SetHeader("Authorization", "Bearer "+string(s.AccessToken)).
 		Do().
 		UnmarshalJSON()
 	if err != nil {
@@ -76,5 +76,5 @@ func (p *NextcloudProvider) EnrichSession(ctx context.Context, s *sessions.Sessi
 
 // ValidateSession validates the AccessToken
 func (p *NextcloudProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+	return validateToken(ctx, p, string(s.AccessToken), makeOIDCHeader(string(s.AccessToken)))
 }
@@ -82,7 +82,7 @@ func (p *OIDCProvider) EnrichSession(ctx context.Context, s *sessions.SessionSta
 
 // ValidateSession checks that the session's IDToken is still valid
 func (p *OIDCProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	_, err := p.Verifier.Verify(ctx, s.IDToken)
+	_, err := p.Verifier.Verify(ctx, string(s.IDToken))
 	if err != nil {
 		logger.Errorf("id_token verification failed: %v", err)
 		return false
@@ -102,7 +102,7 @@ func (p *OIDCProvider) ValidateSession(ctx context.Context, s *sessions.SessionS
 
 // RefreshSession uses the RefreshToken to fetch new Access and ID Tokens
 func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
-	if s == nil || s.RefreshToken == "" {
+	if s == nil || len(s.RefreshToken) == 0 {
 		return false, nil
 	}
 
@@ -130,7 +130,7 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 		},
 	}
 	t := &oauth2.Token{
-		RefreshToken: s.RefreshToken,
+		RefreshToken: string(s.RefreshToken),
 		Expiry:       time.Now().Add(-time.Hour),
 	}
 	token, err := c.TokenSource(ctx, t).Token()
@@ -146,7 +146,7 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 	// It's possible that if the refresh token isn't in the token response the
 	// session will not contain an id token.
 	// If it doesn't it's probably better to retain the old one
-	if newSession.IDToken != "" {
+	if len(newSession.IDToken) > 0 {
 		s.IDToken = newSession.IDToken
 		s.Email = newSession.Email
 		s.User = newSession.User
@@ -179,9 +179,9 @@ func (p *OIDCProvider) CreateSessionFromToken(ctx context.Context, token string)
 		ss.Email = ss.User
 	}
 
-	ss.AccessToken = token
-	ss.IDToken = token
-	ss.RefreshToken = ""
+	ss.AccessToken = []byte(token)
+	ss.IDToken = []byte(token)
+	ss.RefreshToken = nil
 
 	ss.CreatedAtNow()
 	ss.SetExpiresOn(idToken.Expiry)
@@ -211,9 +211,9 @@ func (p *OIDCProvider) createSession(ctx context.Context, token *oauth2.Token, r
 		return nil, err
 	}
 
-	ss.AccessToken = token.AccessToken
-	ss.RefreshToken = token.RefreshToken
-	ss.IDToken = rawIDToken
+	ss.AccessToken = []byte(token.AccessToken)
+	ss.RefreshToken = []byte(token.RefreshToken)
+	ss.IDToken = []byte(rawIDToken)
 
 	ss.CreatedAtNow()
 	ss.SetExpiresOn(token.Expiry)
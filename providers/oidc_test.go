@@ -34,7 +34,7 @@ func newOIDCProvider(serverURL *url.URL, skipNonce bool) *OIDCProvider {
 	providerData := &ProviderData{
 		ProviderName: "oidc",
 		ClientID:     oidcClientID,
-		ClientSecret: oidcSecret,
+		ClientSecret: []byte(oidcSecret),
 		LoginURL: &url.URL{
 			Scheme: serverURL.Scheme,
 			Host:   serverURL.Host,
@@ -122,9 +122,9 @@ func TestOIDCProviderRedeem(t *testing.T) {
 	session, err := provider.Redeem(context.Background(), provider.RedeemURL.String(), "code1234", "")
 	assert.Equal(t, nil, err)
 	assert.Equal(t, defaultIDToken.Email, session.Email)
-	assert.Equal(t, accessToken, session.AccessToken)
-	assert.Equal(t, idToken, session.IDToken)
-	assert.Equal(t, refreshToken, session.RefreshToken)
+	assert.Equal(t, []byte(accessToken), session.AccessToken)
+	assert.Equal(t, []byte(idToken), session.IDToken)
+	assert.Equal(t, []byte(refreshToken), session.RefreshToken)
 	assert.Equal(t, "123456789", session.User)
 }
 
@@ -161,11 +161,11 @@ func TestOIDCProviderRefreshSessionIfNeededWithoutIdToken(t *testing.T) {
 	defer server.Close()
 
 	existingSession := &sessions.SessionState{
-		AccessToken:  "changeit",
-		IDToken:      idToken,
+		AccessToken:  []byte("changeit"),
+		IDToken:      []byte(idToken),
 		CreatedAt:    nil,
 		ExpiresOn:    nil,
-		RefreshToken: refreshToken,
+		RefreshToken: []byte(refreshToken),
 		Email:        "janedoe@example.com",
 		User:         "11223344",
 	}
@@ -174,9 +174,9 @@ func TestOIDCProviderRefreshSessionIfNeededWithoutIdToken(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, refreshed, true)
 	assert.Equal(t, "janedoe@example.com", existingSession.Email)
-	assert.Equal(t, accessToken, existingSession.AccessToken)
-	assert.Equal(t, idToken, existingSession.IDToken)
-	assert.Equal(t, refreshToken, existingSession.RefreshToken)
+	assert.Equal(t, []byte(accessToken), existingSession.AccessToken)
+	assert.Equal(t, []byte(idToken), existingSession.IDToken)
+	assert.Equal(t, []byte(refreshToken), existingSession.RefreshToken)
 	assert.Equal(t, "11223344", existingSession.User)
 }
 
@@ -195,11 +195,11 @@ func TestOIDCProviderRefreshSessionIfNeededWithIdToken(t *testing.T) {
 	defer server.Close()
 
 	existingSession := &sessions.SessionState{
-		AccessToken:  "changeit",
-		IDToken:      "changeit",
+		AccessToken:  []byte("changeit"),
+		IDToken:      []byte("changeit"),
 		CreatedAt:    nil,
 		ExpiresOn:    nil,
-		RefreshToken: refreshToken,
+		RefreshToken: []byte(refreshToken),
 		Email:        "changeit",
 		User:         "changeit",
 	}
@@ -208,9 +208,9 @@ func TestOIDCProviderRefreshSessionIfNeededWithIdToken(t *testing.T) {
 	assert.Equal(t, refreshed, true)
 	assert.Equal(t, defaultIDToken.Email, existingSession.Email)
 	assert.Equal(t, defaultIDToken.Subject, existingSession.User)
-	assert.Equal(t, accessToken, existingSession.AccessToken)
-	assert.Equal(t, idToken, existingSession.IDToken)
-	assert.Equal(t, refreshToken, existingSession.RefreshToken)
+	assert.Equal(t, []byte(accessToken), existingSession.AccessToken)
+	assert.Equal(t, []byte(idToken), existingSession.IDToken)
+	assert.Equal(t, []byte(refreshToken), existingSession.RefreshToken)
 }
 
 func TestOIDCProviderCreateSessionFromToken(t *testing.T) {
@@ -269,9 +269,9 @@ func TestOIDCProviderCreateSessionFromToken(t *testing.T) {
 			assert.Equal(t, tc.ExpectedUser, ss.User)
 			assert.Equal(t, tc.ExpectedEmail, ss.Email)
 			assert.Equal(t, tc.ExpectedGroups, ss.Groups)
-			assert.Equal(t, rawIDToken, ss.IDToken)
-			assert.Equal(t, rawIDToken, ss.AccessToken)
-			assert.Equal(t, "", ss.RefreshToken)
+			assert.Equal(t, []byte(rawIDToken), ss.IDToken)
+			assert.Equal(t, []byte(rawIDToken), ss.AccessToken)
+			assert.Empty(t, ss.RefreshToken)
 		})
 	}
 }
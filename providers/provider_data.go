@@ -13,6 +13,7 @@ import (
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
@@ -33,10 +34,18 @@ type ProviderData struct {
 	ProfileURL        *url.URL
 	ProtectedResource *url.URL
 	ValidateURL       *url.URL
-	ClientID          string
-	ClientSecret      string
-	ClientSecretFile  string
-	Scope             string
+	// LogoutURL is the end session endpoint users are redirected to when
+	// they sign out, or nil when they are not signed out of the provider.
+	LogoutURL *url.URL
+	// RevokeURL is the token revocation endpoint, empty when the provider has
+	// none.
+	RevokeURL *url.URL
+	ClientID  string
+	// ClientSecret is shared with the options, which wipe it once the
+	// configuration is no longer used
+	ClientSecret     []byte
+	ClientSecretFile string
+	Scope            string
 	// The picked CodeChallenge Method or empty if none.
 	CodeChallengeMethod string
 	// Code challenge methods supported by the Provider
@@ -49,10 +58,17 @@ type ProviderData struct {
 	GroupsClaim          string
 	Verifier             internaloidc.IDTokenVerifier
 
+	// providerVerifier refreshes the keys of the Verifier in the background
+	providerVerifier internaloidc.ProviderVerifier
+
 	// Universal Group authorization data structure
 	// any provider can set to consume
 	AllowedGroups map[string]struct{}
 
+	// LookupCache caches the API calls made for each user when they sign in,
+	// or is nil when they are not cached
+	LookupCache *LookupCache
+
 	getAuthorizationHeaderFunc func(string) http.Header
 	loginURLParameterDefaults  url.Values
 	loginURLParameterOverrides map[string]*regexp.Regexp
@@ -61,9 +77,17 @@ type ProviderData struct {
 // Data returns the ProviderData
 func (p *ProviderData) Data() *ProviderData { return p }
 
+// Close stops the background work of the provider, such as refreshing the
+// keys of its Verifier.
+func (p *ProviderData) Close() {
+	if p.providerVerifier != nil {
+		p.providerVerifier.Close()
+	}
+}
+
 func (p *ProviderData) GetClientSecret() (clientSecret string, err error) {
-	if p.ClientSecret != "" || p.ClientSecretFile == "" {
-		return p.ClientSecret, nil
+	if len(p.ClientSecret) > 0 || p.ClientSecretFile == "" {
+		return string(p.ClientSecret), nil
 	}
 
 	// Getting ClientSecret can fail in runtime so we need to report it without returning the file name to the user
@@ -72,6 +96,7 @@ func (p *ProviderData) GetClientSecret() (clientSecret string, err error) {
 		logger.Errorf("error reading client secret file %s: %s", p.ClientSecretFile, err)
 		return "", errors.New("could not read client secret file")
 	}
+	defer encryption.Wipe(fileClientSecret)
 	return string(fileClientSecret), nil
 }
 
@@ -105,6 +130,18 @@ func (p *ProviderData) LoginURLParams(overrides url.Values) url.Values {
 	return params
 }
 
+// reservedLoginURLParameters are set on the login URL by the proxy itself, so
+// they cannot be overridden from the start URL.
+var reservedLoginURLParameters = map[string]struct{}{
+	"client_id":             {},
+	"code_challenge":        {},
+	"code_challenge_method": {},
+	"nonce":                 {},
+	"redirect_uri":          {},
+	"response_type":         {},
+	"state":                 {},
+}
+
 // Compile the given set of LoginURLParameter options into the internal defaults
 // and regular expressions used to validate any overrides.
 func (p *ProviderData) compileLoginParams(paramConfig []options.LoginURLParameter) []error {
@@ -121,7 +158,9 @@ func (p *ProviderData) compileLoginParams(paramConfig []options.LoginURLParamete
 				p.loginURLParameterDefaults[param.Name] = param.Default
 			}
 			// record allow rules if any
-			if len(param.Allow) > 0 {
+			if _, reserved := reservedLoginURLParameters[param.Name]; reserved && len(param.Allow) > 0 {
+				errs = append(errs, fmt.Errorf("parameter %s in loginURLParameters is reserved and cannot be overridden", param.Name))
+			} else if len(param.Allow) > 0 {
 				errs = p.convertAllowRules(errs, param)
 			}
 		}
@@ -291,7 +330,7 @@ func (p *ProviderData) getClaimExtractor(rawIDToken, accessToken string) (util.C
 
 // checkNonce compares the session's nonce with the IDToken's nonce claim
 func (p *ProviderData) checkNonce(s *sessions.SessionState) error {
-	extractor, err := p.getClaimExtractor(s.IDToken, "")
+	extractor, err := p.getClaimExtractor(string(s.IDToken), "")
 	if err != nil {
 		return fmt.Errorf("id_token claims extraction failed: %v", err)
 	}
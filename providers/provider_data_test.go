@@ -480,7 +480,7 @@ func TestProviderData_checkNonce(t *testing.T) {
 			// as the nonce claim is extracted to compare with the session nonce
 			rawIDToken, err := newSignedTestIDToken(tc.IDToken)
 			g.Expect(err).ToNot(HaveOccurred())
-			tc.Session.IDToken = rawIDToken
+			tc.Session.IDToken = []byte(rawIDToken)
 
 			verificationOptions := internaloidc.IDTokenVerificationOptions{
 				AudienceClaims: []string{"aud"},
@@ -612,3 +612,27 @@ func TestProviderData_loginURLParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestProviderData_reservedLoginURLParameters(t *testing.T) {
+	anything := ".+"
+	allowAnything := []options.URLParameterRule{
+		{Pattern: &anything},
+	}
+
+	data := ProviderData{}
+	errs := data.compileLoginParams([]options.LoginURLParameter{
+		{Name: "login_hint", Allow: allowAnything},
+		{Name: "redirect_uri", Allow: allowAnything},
+	})
+	assert.Equal(t, []error{
+		errors.New("parameter redirect_uri in loginURLParameters is reserved and cannot be overridden"),
+	}, errs)
+
+	redirectParams := data.LoginURLParams(url.Values{
+		"login_hint":   {"john.doe@example.com"},
+		"redirect_uri": {"https://evil.example.com/callback"},
+	})
+	assert.Equal(t, url.Values{
+		"login_hint": {"john.doe@example.com"},
+	}, redirectParams)
+}
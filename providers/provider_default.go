@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
@@ -29,6 +30,10 @@ var (
 	// but an attempt to call `Verifier.Verify` was about to be made.
 	ErrMissingOIDCVerifier = errors.New("oidc verifier is not configured")
 
+	// ErrMissingRevokeURL is returned when a token is revoked with a provider
+	// that has no token revocation endpoint.
+	ErrMissingRevokeURL = errors.New("provider has no token revocation endpoint")
+
 	_ Provider = (*ProviderData)(nil)
 )
 
@@ -40,6 +45,18 @@ func (p *ProviderData) GetLoginURL(redirectURI, state, _ string, extraParams url
 	return loginURL.String()
 }
 
+// GetLogoutURL returns the URL of the end session endpoint signing the user of
+// the session out of the provider, with the ID token of the session as the
+// `id_token_hint`, which redirects them to the postLogoutRedirectURI.
+// It returns "" when users are not signed out of the provider.
+func (p *ProviderData) GetLogoutURL(s *sessions.SessionState, postLogoutRedirectURI string) string {
+	if p.LogoutURL == nil {
+		return ""
+	}
+	logoutURL := makeLogoutURL(p, s, postLogoutRedirectURI)
+	return logoutURL.String()
+}
+
 // Redeem provides a default implementation of the OAuth2 token redemption process
 // The codeVerifier is set if a code_verifier parameter should be sent for PKCE
 func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
@@ -81,7 +98,7 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	err = result.UnmarshalInto(&jsonResponse)
 	if err == nil {
 		return &sessions.SessionState{
-			AccessToken: jsonResponse.AccessToken,
+			AccessToken: []byte(jsonResponse.AccessToken),
 		}, nil
 	}
 
@@ -92,7 +109,7 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	// TODO (@NickMeves): Uses OAuth `expires_in` to set an expiration
 	if token := values.Get("access_token"); token != "" {
 		ss := &sessions.SessionState{
-			AccessToken: token,
+			AccessToken: []byte(token),
 		}
 		ss.CreatedAtNow()
 		return ss, nil
@@ -101,6 +118,38 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	return nil, fmt.Errorf("no access token found %s", result.Body())
 }
 
+// RevokeRefreshToken revokes a refresh token with the token revocation
+// endpoint of the provider, as described in RFC 7009.
+func (p *ProviderData) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	if p.RevokeURL == nil || p.RevokeURL.String() == "" {
+		return ErrMissingRevokeURL
+	}
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Add("client_id", p.ClientID)
+	params.Add("client_secret", clientSecret)
+	params.Add("token", refreshToken)
+	params.Add("token_type_hint", "refresh_token")
+
+	result := requests.New(p.RevokeURL.String()).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do()
+	if result.Error() != nil {
+		return result.Error()
+	}
+	if result.StatusCode() != http.StatusOK {
+		return fmt.Errorf("got %d from %q: %s", result.StatusCode(), p.RevokeURL.String(), result.Body())
+	}
+	return nil
+}
+
 // GetEmailAddress returns the Account email address
 // Deprecated: Migrate to EnrichSession
 func (p *ProviderData) GetEmailAddress(_ context.Context, _ *sessions.SessionState) (string, error) {
@@ -131,7 +180,7 @@ func (p *ProviderData) Authorize(_ context.Context, s *sessions.SessionState) (b
 
 // ValidateSession validates the AccessToken
 func (p *ProviderData) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	return validateToken(ctx, p, s.AccessToken, nil)
+	return validateToken(ctx, p, string(s.AccessToken), nil)
 }
 
 // RefreshSession refreshes the user's session
@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -61,6 +63,50 @@ func TestCodeChallengeNotConfigured(t *testing.T) {
 	assert.NotContains(t, result, "code_challenge_method")
 }
 
+func TestGetLogoutURL(t *testing.T) {
+	p := &ProviderData{ClientID: "client"}
+	assert.Equal(t, "", p.GetLogoutURL(&sessions.SessionState{IDToken: []byte("id.token")}, "https://my.test.app/"))
+
+	p.LogoutURL = &url.URL{
+		Scheme:   "https",
+		Host:     "my.test.idp",
+		Path:     "/logout",
+		RawQuery: "tenant=test",
+	}
+	assert.Equal(t,
+		"https://my.test.idp/logout?client_id=client&id_token_hint=id.token&post_logout_redirect_uri=https%3A%2F%2Fmy.test.app%2F&tenant=test",
+		p.GetLogoutURL(&sessions.SessionState{IDToken: []byte("id.token")}, "https://my.test.app/"))
+	assert.Equal(t,
+		"https://my.test.idp/logout?client_id=client&tenant=test",
+		p.GetLogoutURL(nil, ""))
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	var form url.Values
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.NoError(t, req.ParseForm())
+		form = req.PostForm
+		rw.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := &ProviderData{ClientID: "client", ClientSecret: []byte("secret")}
+	assert.Equal(t, ErrMissingRevokeURL, p.RevokeRefreshToken(context.Background(), "refresh"))
+
+	p.RevokeURL, _ = url.Parse(server.URL)
+	assert.NoError(t, p.RevokeRefreshToken(context.Background(), "refresh"))
+	assert.Equal(t, url.Values{
+		"client_id":       {"client"},
+		"client_secret":   {"secret"},
+		"token":           {"refresh"},
+		"token_type_hint": {"refresh_token"},
+	}, form)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, p.RevokeRefreshToken(context.Background(), "refresh"))
+}
+
 func TestProviderDataEnrichSession(t *testing.T) {
 	g := NewWithT(t)
 	p := &ProviderData{}
@@ -2,13 +2,16 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
@@ -31,8 +34,11 @@ type Provider interface {
 	CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error)
 }
 
-func NewProvider(providerConfig options.Provider) (Provider, error) {
-	providerData, err := newProviderDataFromConfig(providerConfig)
+// NewProvider creates the provider of the configuration. The requests made to
+// create it, such as fetching its OIDC discovery document, are made with the
+// client, or http.DefaultClient when it is nil.
+func NewProvider(providerConfig options.Provider, client *http.Client) (Provider, error) {
+	providerData, err := newProviderDataFromConfig(providerConfig, client)
 	if err != nil {
 		return nil, fmt.Errorf("could not create provider data: %v", err)
 	}
@@ -70,7 +76,7 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 	}
 }
 
-func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData, error) {
+func newProviderDataFromConfig(providerConfig options.Provider, client *http.Client) (*ProviderData, error) {
 	p := &ProviderData{
 		Scope:            providerConfig.Scope,
 		ClientID:         providerConfig.ClientID,
@@ -84,12 +90,20 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	}
 
 	if needsVerifier {
-		pv, err := internaloidc.NewProviderVerifier(context.TODO(), internaloidc.ProviderVerifierOptions{
+		keysRefreshInterval := options.DefaultOIDCKeysRefreshInterval
+		if providerConfig.OIDCConfig.KeysRefreshInterval != nil {
+			keysRefreshInterval = providerConfig.OIDCConfig.KeysRefreshInterval.Duration()
+		}
+
+		ctx := requests.WithClient(requests.WithProviderEndpoint(context.TODO(), providerConfig.ID, requests.ProviderEndpointDiscovery), client)
+		pv, err := internaloidc.NewProviderVerifier(ctx, internaloidc.ProviderVerifierOptions{
 			AudienceClaims:         providerConfig.OIDCConfig.AudienceClaims,
 			ClientID:               providerConfig.ClientID,
 			ExtraAudiences:         providerConfig.OIDCConfig.ExtraAudiences,
 			IssuerURL:              providerConfig.OIDCConfig.IssuerURL,
 			JWKsURL:                providerConfig.OIDCConfig.JwksURL,
+			KeysRefreshInterval:    keysRefreshInterval,
+			ProviderID:             providerConfig.ID,
 			SkipDiscovery:          providerConfig.OIDCConfig.SkipDiscovery,
 			SkipIssuerVerification: providerConfig.OIDCConfig.InsecureSkipIssuerVerification,
 		})
@@ -98,6 +112,7 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		}
 
 		p.Verifier = pv.Verifier()
+		p.providerVerifier = pv
 		if pv.DiscoveryEnabled() {
 			// Use the discovered values rather than any specified values
 			endpoints := pv.Provider().Endpoints()
@@ -107,6 +122,13 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			providerConfig.ProfileURL = endpoints.UserInfoURL
 			providerConfig.OIDCConfig.JwksURL = endpoints.JWKsURL
 			p.SupportedCodeChallengeMethods = pkce.CodeChallengeAlgs
+			// Not all providers advertise their end session endpoint
+			if providerConfig.LogoutURL == "" {
+				providerConfig.LogoutURL = endpoints.EndSessionURL
+			}
+			if providerConfig.RevokeURL == "" {
+				providerConfig.RevokeURL = endpoints.RevocationURL
+			}
 		}
 	}
 
@@ -120,6 +142,7 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		"profile":  {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
 		"validate": {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
 		"resource": {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
+		"revoke":   {dst: &p.RevokeURL, raw: providerConfig.RevokeURL},
 	} {
 		var err error
 		*u.dst, err = url.Parse(u.raw)
@@ -127,6 +150,13 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			errs = append(errs, fmt.Errorf("could not parse %s URL: %v", name, err))
 		}
 	}
+	if providerConfig.RPInitiatedLogout {
+		logoutURL, err := parseLogoutURL(providerConfig.LogoutURL)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		p.LogoutURL = logoutURL
+	}
 	// handle LoginURLParameters
 	errs = append(errs, p.compileLoginParams(providerConfig.LoginURLParameters)...)
 
@@ -168,6 +198,22 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	return p, nil
 }
 
+// parseLogoutURL parses the end session endpoint of a provider, which must be
+// an absolute URL.
+func parseLogoutURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, errors.New("rp-initiated logout requires a logout URL, as the provider has no end session endpoint")
+	}
+	logoutURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse logout URL: %v", err)
+	}
+	if !logoutURL.IsAbs() {
+		return nil, fmt.Errorf("logout URL %q must be an absolute URL", raw)
+	}
+	return logoutURL, nil
+}
+
 // Pick the most appropriate code challenge method for PKCE
 // At this time we do not consider what the server supports to be safe and
 // only enable PKCE if the user opts-in
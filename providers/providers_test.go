@@ -30,7 +30,7 @@ func TestClientSecretFileOptionFails(t *testing.T) {
 		ClientSecretFile: clientSecret,
 	}
 
-	p, err := newProviderDataFromConfig(providerConfig)
+	p, err := newProviderDataFromConfig(providerConfig, nil)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(p.ClientSecretFile).To(Equal(clientSecret))
 	g.Expect(p.ClientSecret).To(BeEmpty())
@@ -63,7 +63,7 @@ func TestClientSecretFileOption(t *testing.T) {
 		ClientSecretFile: clientSecretFileName,
 	}
 
-	p, err := newProviderDataFromConfig(providerConfig)
+	p, err := newProviderDataFromConfig(providerConfig, nil)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(p.ClientSecretFile).To(Equal(clientSecretFileName))
 	g.Expect(p.ClientSecret).To(BeEmpty())
@@ -86,14 +86,14 @@ func TestSkipOIDCDiscovery(t *testing.T) {
 		},
 	}
 
-	_, err := newProviderDataFromConfig(providerConfig)
+	_, err := newProviderDataFromConfig(providerConfig, nil)
 	g.Expect(err).To(MatchError("error building OIDC ProviderVerifier: invalid provider verifier options: missing required setting: jwks-url"))
 
 	providerConfig.LoginURL = msAuthURL
 	providerConfig.RedeemURL = msTokenURL
 	providerConfig.OIDCConfig.JwksURL = msKeysURL
 
-	_, err = newProviderDataFromConfig(providerConfig)
+	_, err = newProviderDataFromConfig(providerConfig, nil)
 	g.Expect(err).ToNot(HaveOccurred())
 }
 
@@ -114,13 +114,50 @@ func TestURLsCorrectlyParsed(t *testing.T) {
 		},
 	}
 
-	pd, err := newProviderDataFromConfig(providerConfig)
+	pd, err := newProviderDataFromConfig(providerConfig, nil)
 	g.Expect(err).ToNot(HaveOccurred())
 
 	g.Expect(pd.LoginURL.String()).To(Equal(msAuthURL))
 	g.Expect(pd.RedeemURL.String()).To(Equal(msTokenURL))
 }
 
+func TestRPInitiatedLogoutURL(t *testing.T) {
+	g := NewWithT(t)
+
+	providerConfig := options.Provider{
+		ID:                providerID,
+		Type:              "oidc",
+		ClientID:          clientID,
+		ClientSecretFile:  clientSecret,
+		LoginURL:          msAuthURL,
+		RedeemURL:         msTokenURL,
+		LogoutURL:         "https://login.microsoftonline.com/fabrikamb2c.onmicrosoft.com/oauth2/v2.0/logout",
+		RPInitiatedLogout: false,
+		OIDCConfig: options.OIDCOptions{
+			IssuerURL:     msIssuerURL,
+			SkipDiscovery: true,
+			JwksURL:       msKeysURL,
+		},
+	}
+
+	pd, err := newProviderDataFromConfig(providerConfig, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pd.LogoutURL).To(BeNil())
+
+	providerConfig.RPInitiatedLogout = true
+	pd, err = newProviderDataFromConfig(providerConfig, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pd.LogoutURL.String()).To(Equal(providerConfig.LogoutURL))
+
+	providerConfig.LogoutURL = "/logout"
+	_, err = newProviderDataFromConfig(providerConfig, nil)
+	g.Expect(err).To(MatchError(`logout URL "/logout" must be an absolute URL`))
+
+	providerConfig.LogoutURL = ""
+	_, err = newProviderDataFromConfig(providerConfig, nil)
+	g.Expect(err).To(MatchError("rp-initiated logout requires a logout URL, as the provider has no end session endpoint"))
+}
+
 func TestScope(t *testing.T) {
 	g := NewWithT(t)
 
@@ -165,7 +202,7 @@ func TestScope(t *testing.T) {
 			},
 		}
 
-		pd, err := newProviderDataFromConfig(providerConfig)
+		pd, err := newProviderDataFromConfig(providerConfig, nil)
 		g.Expect(err).ToNot(HaveOccurred())
 
 		g.Expect(pd.Scope).To(Equal(tc.expectedScope))
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"golang.org/x/oauth2"
 )
 
@@ -51,6 +52,23 @@ func makeLoginURL(p *ProviderData, redirectURI, state string, extraParams url.Va
 	return a
 }
 
+// makeLogoutURL returns the URL of the end session endpoint of the provider
+// for OpenID Connect RP-Initiated Logout.
+// The session may be nil when the user has no session anymore.
+func makeLogoutURL(p *ProviderData, s *sessions.SessionState, postLogoutRedirectURI string) url.URL {
+	a := *p.LogoutURL
+	params, _ := url.ParseQuery(a.RawQuery)
+	params.Set("client_id", p.ClientID)
+	if s != nil && len(s.IDToken) > 0 {
+		params.Set("id_token_hint", string(s.IDToken))
+	}
+	if postLogoutRedirectURI != "" {
+		params.Set("post_logout_redirect_uri", postLogoutRedirectURI)
+	}
+	a.RawQuery = params.Encode()
+	return a
+}
+
 // getIDToken extracts an IDToken stored in the `Extra` fields of an
 // oauth2.Token
 func getIDToken(token *oauth2.Token) string {
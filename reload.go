@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	proxyhttp "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/http"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/systemd"
+)
+
+// ReloadFunc loads the configuration again and builds a new OAuthProxy from
+// it. The returned OAuthProxy does not need its servers to be set up.
+type ReloadFunc func() (*OAuthProxy, error)
+
+// reloadHandler serves requests with the most recently loaded OAuthProxy so
+// that the configuration can be replaced while the servers keep running.
+// Requests that are in flight when the configuration is replaced complete
+// with the OAuthProxy that accepted them.
+type reloadHandler struct {
+	current atomic.Value
+
+	// mutex ensures only one reload happens at a time.
+	mutex sync.Mutex
+}
+
+func newReloadHandler(p *OAuthProxy) *reloadHandler {
+	h := &reloadHandler{}
+	h.current.Store(p)
+	return h
+}
+
+// ServeHTTP serves the request with the current OAuthProxy.
+func (h *reloadHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	for {
+		// An OAuthProxy replaced since it was loaded no longer accepts
+		// requests, as its stores are closed once it is idle
+		p := h.load()
+		if p.startRequest() {
+			defer p.finishRequest()
+			p.ServeHTTP(rw, req)
+			return
+		}
+	}
+}
+
+func (h *reloadHandler) load() *OAuthProxy {
+	return h.current.Load().(*OAuthProxy)
+}
+
+// SetReloadFunc enables reloading the configuration when the process receives
+// a SIGHUP, using the function given to build the new OAuthProxy.
+func (p *OAuthProxy) SetReloadFunc(reload ReloadFunc) {
+	p.reload = reload
+}
+
+// ReloadConfig builds a new OAuthProxy with the reload function and serves new
+// requests with it.
+// Sessions are kept as long as the cookie and session store options are not
+// changed. If the new configuration cannot be loaded, the error is returned
+// and the existing configuration continues to be used.
+// The stores of the previous configuration are closed, and its secrets wiped,
+// once the requests in flight that it accepted have been served.
+func (p *OAuthProxy) ReloadConfig() error {
+	if p.reload == nil || p.handler == nil {
+		return errors.New("configuration reloading is not enabled")
+	}
+
+	p.handler.mutex.Lock()
+	defer p.handler.mutex.Unlock()
+
+	if err := systemd.Notify(systemd.Reloading()); err != nil {
+		logger.Errorf("Error notifying systemd: %v", err)
+	}
+	defer func() {
+		if err := systemd.Notify(systemd.Ready); err != nil {
+			logger.Errorf("Error notifying systemd: %v", err)
+		}
+	}()
+
+	next, err := p.reload()
+	if err != nil {
+		return fmt.Errorf("could not reload configuration: %v", err)
+	}
+
+	previous := p.handler.load()
+	p.handler.current.Store(next)
+
+	// The previous upstreams and provider may still be serving requests in
+	// flight, only their background health checks, discovery and key
+	// refreshes are stopped
+	previous.closeBackgroundWork()
+	previous.replace()
+
+	p.reloadCertificates(next)
+
+	logger.Printf("Configuration reloaded")
+	return nil
+}
+
+// reloadCertificates replaces the certificates of the servers with those of
+// the reloaded configuration, so that rotated certificates are served without
+// a restart. Certificates that cannot be loaded are logged and the previous
+// certificates continue to be served.
+func (p *OAuthProxy) reloadCertificates(next *OAuthProxy) {
+	servers := []struct {
+		name   string
+		server proxyhttp.Server
+		tls    *options.TLS
+	}{
+		{name: "server", server: p.appServer, tls: next.serverTLS},
+		{name: "metrics server", server: p.metricsServer, tls: next.metricsServerTLS},
+	}
+	for _, s := range servers {
+		reloader, ok := s.server.(proxyhttp.CertificateReloader)
+		if !ok {
+			continue
+		}
+		if err := reloader.ReloadCertificates(s.tls); err != nil {
+			logger.Errorf("ERROR: could not reload the certificates of the %s: %v", s.name, err)
+		}
+	}
+}
+
+// startRequest counts a request served by the OAuthProxy, unless it has been
+// replaced.
+func (p *OAuthProxy) startRequest() bool {
+	p.requestsMutex.Lock()
+	defer p.requestsMutex.Unlock()
+	if p.replaced {
+		return false
+	}
+	p.requests++
+	return true
+}
+
+// finishRequest counts a request as served, and releases the OAuthProxy once
+// it has been replaced and has served all of its requests.
+func (p *OAuthProxy) finishRequest() {
+	p.requestsMutex.Lock()
+	p.requests--
+	idle := p.replaced && p.requests == 0
+	p.requestsMutex.Unlock()
+
+	if idle {
+		p.release()
+	}
+}
+
+// replace stops the OAuthProxy from accepting requests, and releases it once
+// the requests in flight have been served.
+func (p *OAuthProxy) replace() {
+	p.requestsMutex.Lock()
+	p.replaced = true
+	idle := p.requests == 0
+	p.requestsMutex.Unlock()
+
+	if idle {
+		p.release()
+	}
+}
+
+// release closes the stores of a replaced OAuthProxy and wipes its secrets.
+func (p *OAuthProxy) release() {
+	p.closeStores()
+	p.wipeSecrets()
+}
+
+// closeStores closes the connections of the session store, the replay cache
+// and the consent store of the OAuthProxy and its tenants, as each reload
+// creates new ones.
+func (p *OAuthProxy) closeStores() {
+	stores := map[string]interface{}{
+		"session store": p.sessionStore,
+		"replay cache":  p.replayCache,
+	}
+	if p.consent != nil {
+		stores["consent store"] = p.consent.store
+	}
+	for name, store := range stores {
+		if closer, ok := store.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Errorf("Error closing the %s of the previous configuration: %v", name, err)
+			}
+		}
+	}
+	for _, tenant := range p.tenants {
+		tenant.proxy.closeStores()
+	}
+}
+
+// wipeSecrets wipes the cookie secret and the client secret of the provider
+// of the OAuthProxy and its tenants, which are shared with the options rather
+// than copied, so that they do not remain in memory once the configuration
+// is replaced.
+func (p *OAuthProxy) wipeSecrets() {
+	encryption.Wipe(p.CookieOptions.Secret)
+	encryption.Wipe(p.provider.Data().ClientSecret)
+	for _, tenant := range p.tenants {
+		tenant.proxy.wipeSecrets()
+	}
+}
+
+// closeBackgroundWork stops the background work of the upstreams and the
+// provider of the OAuthProxy and its tenants, and closes the idle connections
+// to the provider.
+func (p *OAuthProxy) closeBackgroundWork() {
+	p.upstreamProxy.Close()
+	p.provider.Data().Close()
+	p.providerClient.CloseIdleConnections()
+	for _, tenant := range p.tenants {
+		tenant.proxy.closeBackgroundWork()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadTestOptions(skipAuthRegex ...string) *options.Options {
+	opts := baseTestOptions()
+	opts.UpstreamServers = options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:     "static",
+				Path:   "/",
+				Static: true,
+			},
+		},
+	}
+	opts.SkipAuthRegex = skipAuthRegex
+	return opts
+}
+
+func serveReloadTestRequest(p *OAuthProxy) int {
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/public", nil)
+	p.handler.ServeHTTP(rw, req)
+	return rw.Code
+}
+
+func TestReloadConfig(t *testing.T) {
+	opts := reloadTestOptions()
+	require.NoError(t, validation.Validate(opts))
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, serveReloadTestRequest(proxy))
+
+	proxy.SetReloadFunc(func() (*OAuthProxy, error) {
+		reloaded := reloadTestOptions("^/public")
+		if err := validation.Validate(reloaded); err != nil {
+			return nil, err
+		}
+		return newOAuthProxy(reloaded, func(string) bool { return true })
+	})
+	require.NoError(t, proxy.ReloadConfig())
+	assert.Equal(t, http.StatusOK, serveReloadTestRequest(proxy))
+
+	// The previous configuration is unchanged for requests it is still serving
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/public", nil))
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+}
+
+func TestReloadConfigKeepsConfigurationOnError(t *testing.T) {
+	opts := reloadTestOptions("^/public")
+	require.NoError(t, validation.Validate(opts))
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	assert.EqualError(t, proxy.ReloadConfig(), "configuration reloading is not enabled")
+
+	proxy.SetReloadFunc(func() (*OAuthProxy, error) {
+		return nil, errors.New("invalid configuration")
+	})
+	assert.EqualError(t, proxy.ReloadConfig(), "could not reload configuration: invalid configuration")
+	assert.Equal(t, http.StatusOK, serveReloadTestRequest(proxy))
+}
+
+// closeRecordingStore is a session store recording whether it was closed.
+type closeRecordingStore struct {
+	sessionsapi.SessionStore
+	closed bool
+}
+
+func (s *closeRecordingStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestReloadConfigClosesPreviousStoresOnceIdle(t *testing.T) {
+	opts := reloadTestOptions()
+	require.NoError(t, validation.Validate(opts))
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	store := &closeRecordingStore{SessionStore: proxy.sessionStore}
+	proxy.sessionStore = store
+	proxy.SetReloadFunc(func() (*OAuthProxy, error) {
+		reloaded := reloadTestOptions("^/public")
+		if err := validation.Validate(reloaded); err != nil {
+			return nil, err
+		}
+		return newOAuthProxy(reloaded, func(string) bool { return true })
+	})
+
+	// A request still in flight when the configuration is reloaded
+	require.True(t, proxy.startRequest())
+	require.NoError(t, proxy.ReloadConfig())
+	assert.False(t, store.closed)
+	assert.False(t, proxy.startRequest())
+	assert.Equal(t, http.StatusOK, serveReloadTestRequest(proxy))
+
+	proxy.finishRequest()
+	assert.True(t, store.closed)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/schema"
+	"github.com/spf13/pflag"
+)
+
+// schemaCommand is the name of the command that prints the schema of the
+// alpha configuration.
+const schemaCommand = "schema"
+
+// runSchema prints the schema of the alpha configuration in the format given
+// by the arguments and returns the exit code.
+func runSchema(args []string, stdout, stderr io.Writer) int {
+	flagSet := pflag.NewFlagSet("oauth2-proxy schema", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	format := flagSet.String("format", "json-schema", "schema format: json-schema, or openapi for the openAPIV3Schema of a Kubernetes CustomResourceDefinition")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	var s schema.Schema
+	switch *format {
+	case "json-schema":
+		s = schema.JSONSchema(options.AlphaOptions{})
+		// Config files may include other files, which are merged before the
+		// configuration is loaded
+		s["properties"].(schema.Schema)["includes"] = schema.Schema{
+			"type":  "array",
+			"items": schema.Schema{"type": "string"},
+		}
+	case "openapi":
+		s = schema.OpenAPISchema(options.AlphaOptions{})
+	default:
+		fmt.Fprintf(stderr, "invalid format %q: must be json-schema or openapi\n", *format)
+		return 2
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "could not marshal schema: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(data))
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaCommand(t *testing.T) {
+	testCases := map[string]struct {
+		args                         []string
+		expectedCode                 int
+		expectIncludes               bool
+		expectedAdditionalProperties interface{}
+	}{
+		"default format": {
+			expectedCode:                 0,
+			expectIncludes:               true,
+			expectedAdditionalProperties: false,
+		},
+		"openapi format": {
+			args:         []string{"--format", "openapi"},
+			expectedCode: 0,
+		},
+		"invalid format": {
+			args:         []string{"--format", "xml"},
+			expectedCode: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			require.Equal(t, tc.expectedCode, runSchema(tc.args, stdout, stderr))
+			if tc.expectedCode != 0 {
+				assert.NotEmpty(t, stderr.String())
+				return
+			}
+
+			s := struct {
+				Type                 string                     `json:"type"`
+				Properties           map[string]json.RawMessage `json:"properties"`
+				AdditionalProperties interface{}                `json:"additionalProperties"`
+			}{}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &s))
+			assert.Equal(t, "object", s.Type)
+			assert.Contains(t, s.Properties, "upstreamConfig")
+			assert.Equal(t, tc.expectedAdditionalProperties, s.AdditionalProperties)
+			_, ok := s.Properties["includes"]
+			assert.Equal(t, tc.expectIncludes, ok)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/watcher"
+)
+
+// reloadSettleDelay is how long to wait after a watched file changes before
+// reloading, so that files replaced together, such as a certificate and its
+// key, cause a single reload.
+const reloadSettleDelay = time.Second
+
+// secretFiles returns the files of secrets and certificates that are read
+// when the OAuthProxy is built.
+// The htpasswd file is not included as it is reloaded in place when it
+// changes.
+func secretFiles(opts *options.Options) []string {
+	seen := map[string]bool{}
+	add := func(file string) {
+		if file != "" {
+			seen[file] = true
+		}
+	}
+	addSource := func(source *options.SecretSource) {
+		if source != nil {
+			add(source.FromFile)
+		}
+	}
+
+	for _, server := range []options.Server{opts.Server, opts.MetricsServer} {
+		if server.TLS == nil {
+			continue
+		}
+		addSource(server.TLS.Key)
+		addSource(server.TLS.Cert)
+		for _, additional := range server.TLS.AdditionalCertificates {
+			addSource(additional.Key)
+			addSource(additional.Cert)
+		}
+	}
+	for _, provider := range opts.Providers {
+		add(provider.ClientSecretFile)
+		add(provider.LoginGovConfig.JWTKeyFile)
+	}
+	for _, tenantOpts := range opts.GetTenantOptions() {
+		for _, file := range secretFiles(tenantOpts) {
+			add(file)
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// watchSecretFiles reloads the configuration when any of the files changes,
+// until done is closed.
+func watchSecretFiles(files []string, done <-chan bool, reload func()) error {
+	changed := make(chan struct{}, 1)
+	for _, file := range files {
+		if err := watcher.WatchFileForUpdates(file, done, notifyChanged(changed)); err != nil {
+			return err
+		}
+	}
+
+	go reloadWhenSettled(changed, done, reload)
+	return nil
+}
+
+// notifyChanged returns a watcher action that signals the changed channel
+// without blocking.
+func notifyChanged(changed chan<- struct{}) func() {
+	return func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reloadWhenSettled reloads the configuration once the watched files have
+// settled after each change, until done is closed.
+func reloadWhenSettled(changed <-chan struct{}, done <-chan bool, reload func()) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-changed:
+		}
+
+		timer := time.NewTimer(reloadSettleDelay)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Changes made while settling are included in this reload
+		select {
+		case <-changed:
+		default:
+		}
+		reload()
+	}
+}
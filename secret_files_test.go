@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretFiles(t *testing.T) {
+	opts := options.NewOptions()
+	opts.Server.TLS = &options.TLS{
+		Key:  &options.SecretSource{FromFile: "/etc/tls/tls.key"},
+		Cert: &options.SecretSource{FromFile: "/etc/tls/tls.crt"},
+		AdditionalCertificates: []options.TLSCertificate{{
+			Key:  &options.SecretSource{Value: []byte("key")},
+			Cert: &options.SecretSource{FromFile: "/etc/tls/sni.crt"},
+		}},
+	}
+	opts.MetricsServer.TLS = &options.TLS{
+		Key:  &options.SecretSource{FromFile: "/etc/tls/tls.key"},
+		Cert: &options.SecretSource{FromEnv: "METRICS_CERT"},
+	}
+	opts.Providers[0].ClientSecretFile = "/etc/oauth2-proxy/client-secret"
+	opts.Providers[0].LoginGovConfig.JWTKeyFile = "/etc/oauth2-proxy/jwt.key"
+	opts.HtpasswdFile = "/etc/oauth2-proxy/htpasswd"
+
+	assert.Equal(t, []string{
+		"/etc/oauth2-proxy/client-secret",
+		"/etc/oauth2-proxy/jwt.key",
+		"/etc/tls/sni.crt",
+		"/etc/tls/tls.crt",
+		"/etc/tls/tls.key",
+	}, secretFiles(opts))
+}
+
+func TestWatchSecretFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth2-proxy-secret-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, cert := filepath.Join(dir, "tls.key"), filepath.Join(dir, "tls.crt")
+	require.NoError(t, ioutil.WriteFile(key, []byte("key"), 0600))
+	require.NoError(t, ioutil.WriteFile(cert, []byte("cert"), 0600))
+
+	reloads := make(chan struct{}, 2)
+	done := make(chan bool)
+	defer close(done)
+	require.NoError(t, watchSecretFiles([]string{key, cert}, done, func() { reloads <- struct{}{} }))
+
+	require.NoError(t, ioutil.WriteFile(key, []byte("rotated key"), 0600))
+	require.NoError(t, ioutil.WriteFile(cert, []byte("rotated cert"), 0600))
+
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the configuration was not reloaded")
+	}
+	select {
+	case <-reloads:
+		t.Fatal("the configuration was reloaded for each file")
+	case <-time.After(2 * reloadSettleDelay):
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// sessionEventsInterval is how often the session of an events stream is
+// checked, and a comment is sent to keep the stream open.
+const sessionEventsInterval = 15 * time.Second
+
+// The events of the session sent by the SessionEvents endpoint, each with the
+// status of the session.
+const (
+	// sessionEventStatus is the first event of the stream
+	sessionEventStatus = "status"
+	// sessionEventRefreshed is sent when the session was refreshed
+	sessionEventRefreshed = "refreshed"
+	// sessionEventExpiring is sent once the session expires within the
+	// expiry warning
+	sessionEventExpiring = "expiring"
+	// sessionEventExpired is sent when the session expired, and ends the
+	// stream
+	sessionEventExpired = "expired"
+	// sessionEventRevoked is sent when the session was removed before it
+	// expired, eg because the user signed out, and ends the stream
+	sessionEventRevoked = "revoked"
+)
+
+// sessionEvents notifies browsers of the changes of their session.
+type sessionEvents struct {
+	// interval is how often the session is checked.
+	interval time.Duration
+
+	// expiryWarning is how long before the session expires the expiring
+	// event is sent, or 0 to never send it.
+	expiryWarning time.Duration
+
+	// reload loads the session from the session store again at every
+	// interval, to notify of its refreshes and revocations.
+	// Sessions of the cookie session store cannot be loaded again, as the
+	// cookies of the stream do not change when the session is refreshed by
+	// other requests. Their streams end once the session is due to be
+	// refreshed instead, and browsers reconnect with their current cookies.
+	reload bool
+}
+
+// buildSessionEvents builds the session events from the options.
+func buildSessionEvents(opts *options.Options) *sessionEvents {
+	return &sessionEvents{
+		interval:      sessionEventsInterval,
+		expiryWarning: opts.Session.ExpiryWarning,
+		reload:        opts.Session.Type == options.RedisSessionStoreType,
+	}
+}
+
+// SessionEvents streams the events of the session of the request as
+// server-sent events, so that apps can lock their UI as soon as the session
+// expires or is revoked.
+// Requests without a valid session get a 401 Unauthorized response.
+func (p *OAuthProxy) SessionEvents(rw http.ResponseWriter, req *http.Request) {
+	session, err := p.getAuthenticatedSession(rw, req)
+	if err != nil || session == nil {
+		p.errorJSON(rw, http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		logger.Errorf("Error streaming session events: the response cannot be flushed")
+		p.errorJSON(rw, http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	// Stop reverse proxies such as NGINX from buffering the events
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(p.sessionEvents.interval)
+	defer ticker.Stop()
+
+	event := sessionEventStatus
+	warned := false
+	for {
+		status := newSessionStatus(session, p.CookieOptions.Expire, p.CookieOptions.Refresh)
+		if event != "" {
+			p.writeSessionEvent(rw, event, status)
+		}
+		if !warned && p.sessionEvents.expiryWarning > 0 && status.ExpiresIn != nil && time.Duration(*status.ExpiresIn)*time.Second <= p.sessionEvents.expiryWarning {
+			warned = true
+			p.writeSessionEvent(rw, sessionEventExpiring, status)
+		}
+		flusher.Flush()
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		if status.ExpiresAt != nil && !session.Clock.Now().Before(*status.ExpiresAt) {
+			p.writeSessionEvent(rw, sessionEventExpired, &sessionStatus{})
+			flusher.Flush()
+			return
+		}
+		event = ""
+		if !p.sessionEvents.reload {
+			// End the stream once the session is due to be refreshed, so that
+			// the browser reconnects with its current session cookie
+			if status.RefreshAt != nil && !session.Clock.Now().Before(*status.RefreshAt) {
+				return
+			}
+			// Keep the stream open through proxies that close idle connections
+			fmt.Fprint(rw, ": keep-alive\n\n")
+			continue
+		}
+
+		reloaded, err := p.sessionStore.Load(req)
+		if err != nil || reloaded == nil {
+			p.writeSessionEvent(rw, sessionEventRevoked, &sessionStatus{})
+			flusher.Flush()
+			return
+		}
+		if isRefreshed(session, reloaded) {
+			event = sessionEventRefreshed
+			warned = false
+			session = reloaded
+		} else {
+			fmt.Fprint(rw, ": keep-alive\n\n")
+		}
+	}
+}
+
+// writeSessionEvent writes the event with the status of the session as its
+// data.
+func (p *OAuthProxy) writeSessionEvent(rw http.ResponseWriter, event string, status *sessionStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		logger.Errorf("Error encoding session status: %v", err)
+		return
+	}
+	if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		logger.Errorf("Error writing session event: %v", err)
+	}
+}
+
+// isRefreshed returns whether the reloaded session was refreshed since the
+// session was loaded.
+func isRefreshed(session, reloaded *sessionsapi.SessionState) bool {
+	if reloaded.CreatedAt == nil {
+		return false
+	}
+	return session.CreatedAt == nil || reloaded.CreatedAt.After(*session.CreatedAt)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionEventStream reads the events of a session events stream.
+type sessionEventStream struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+}
+
+// next returns the name and the status of the next event of the stream,
+// skipping its comments.
+func (s *sessionEventStream) next() (string, *sessionStatus) {
+	var event string
+	status := &sessionStatus{}
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(s.t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), status))
+		case line == "" && event != "":
+			return event, status
+		}
+	}
+	return "", nil
+}
+
+// newSessionEventsTest serves the proxy, and returns a request for its
+// session events stream with the cookies of the session.
+func newSessionEventsTest(t *testing.T, opts *options.Options, session *sessions.SessionState) (*OAuthProxy, *http.Request) {
+	require.NoError(t, validation.Validate(opts))
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+	proxy.sessionEvents.interval = 10 * time.Millisecond
+
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/oauth2/events", nil)
+	require.NoError(t, err)
+	if session != nil {
+		rw := httptest.NewRecorder()
+		require.NoError(t, proxy.sessionStore.Save(rw, req, session))
+		for _, c := range rw.Result().Cookies() {
+			req.AddCookie(c)
+		}
+	}
+	return proxy, req
+}
+
+// openSessionEvents opens the session events stream of the request.
+func openSessionEvents(t *testing.T, req *http.Request) *sessionEventStream {
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	return &sessionEventStream{t: t, scanner: bufio.NewScanner(resp.Body)}
+}
+
+func TestSessionEventsRefreshedAndRevoked(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	opts := baseTestOptions()
+	opts.Session.Type = options.RedisSessionStoreType
+	opts.Session.Redis.ConnectionURL = "redis://" + mr.Addr()
+
+	createdAt := time.Now().Add(-time.Hour)
+	proxy, req := newSessionEventsTest(t, opts, &sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("access"), CreatedAt: &createdAt})
+	stream := openSessionEvents(t, req)
+
+	event, status := stream.next()
+	assert.Equal(t, sessionEventStatus, event)
+	assert.True(t, status.Authenticated)
+	assert.Equal(t, "john.doe@example.com", status.Email)
+
+	refreshedAt := time.Now()
+	require.NoError(t, proxy.sessionStore.Save(httptest.NewRecorder(), req, &sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("refreshed"), CreatedAt: &refreshedAt}))
+	event, status = stream.next()
+	assert.Equal(t, sessionEventRefreshed, event)
+	assert.True(t, status.Authenticated)
+	assert.Equal(t, refreshedAt.Add(opts.Cookie.Expire).UTC().Truncate(time.Second), status.ExpiresAt.Truncate(time.Second))
+
+	require.NoError(t, proxy.sessionStore.Clear(httptest.NewRecorder(), req))
+	event, status = stream.next()
+	assert.Equal(t, sessionEventRevoked, event)
+	assert.False(t, status.Authenticated)
+
+	event, _ = stream.next()
+	assert.Equal(t, "", event)
+}
+
+func TestSessionEventsExpiringAndExpired(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Session.ExpiryWarning = time.Minute
+
+	createdAt := time.Now().Add(-opts.Cookie.Expire).Add(time.Second)
+	_, req := newSessionEventsTest(t, opts, &sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("access"), CreatedAt: &createdAt})
+	stream := openSessionEvents(t, req)
+
+	event, status := stream.next()
+	assert.Equal(t, sessionEventStatus, event)
+	assert.True(t, status.Authenticated)
+
+	event, status = stream.next()
+	assert.Equal(t, sessionEventExpiring, event)
+	assert.True(t, status.Authenticated)
+
+	event, status = stream.next()
+	assert.Equal(t, sessionEventExpired, event)
+	assert.False(t, status.Authenticated)
+}
+
+func TestSessionEventsUnauthenticated(t *testing.T) {
+	_, req := newSessionEventsTest(t, baseTestOptions(), nil)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// sessionStatus is the response of the SessionStatus endpoint.
+// It describes when the session expires and is refreshed, so that apps can
+// warn users before their session expires, but never includes its tokens.
+// The countdowns are in seconds, and are never negative.
+type sessionStatus struct {
+	Authenticated bool   `json:"authenticated"`
+	User          string `json:"user,omitempty"`
+	Email         string `json:"email,omitempty"`
+
+	// ExpiresAt is when the session cookie expires, after which the user
+	// must sign in again
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn *int64     `json:"expiresIn,omitempty"`
+
+	// AccessTokenExpiresAt is when the access token expires, after which
+	// the session is only valid once it is refreshed
+	AccessTokenExpiresAt *time.Time `json:"accessTokenExpiresAt,omitempty"`
+	AccessTokenExpiresIn *int64     `json:"accessTokenExpiresIn,omitempty"`
+
+	// RefreshAt is when the session is refreshed by the next request
+	RefreshAt *time.Time `json:"refreshAt,omitempty"`
+	RefreshIn *int64     `json:"refreshIn,omitempty"`
+}
+
+// newSessionStatus returns the status of the session, with the expiry and
+// refresh period of the session cookie.
+func newSessionStatus(session *sessionsapi.SessionState, expire, refresh time.Duration) *sessionStatus {
+	status := &sessionStatus{
+		Authenticated: true,
+		User:          session.User,
+		Email:         session.Email,
+	}
+	now := session.Clock.Now()
+
+	if session.CreatedAt != nil && !session.CreatedAt.IsZero() {
+		status.ExpiresAt, status.ExpiresIn = countdown(session.CreatedAt.Add(expire), now)
+		if refresh > 0 {
+			status.RefreshAt, status.RefreshIn = countdown(session.CreatedAt.Add(refresh), now)
+		}
+	}
+	if session.ExpiresOn != nil && !session.ExpiresOn.IsZero() {
+		status.AccessTokenExpiresAt, status.AccessTokenExpiresIn = countdown(*session.ExpiresOn, now)
+	}
+	return status
+}
+
+// countdown returns the deadline and the seconds left until it.
+func countdown(deadline, now time.Time) (*time.Time, *int64) {
+	deadline = deadline.UTC()
+	seconds := int64(deadline.Sub(now) / time.Second)
+	if seconds < 0 {
+		seconds = 0
+	}
+	return &deadline, &seconds
+}
+
+// SessionStatus returns whether the request has a valid session, and when
+// the session expires and is refreshed, in JSON format.
+// It also returns the new status of sessions refreshed on demand.
+// Requests without a valid session get a 401 Unauthorized response.
+func (p *OAuthProxy) SessionStatus(rw http.ResponseWriter, req *http.Request) {
+	status := &sessionStatus{}
+	code := http.StatusUnauthorized
+	if session, err := p.getAuthenticatedSession(rw, req); err == nil && session != nil {
+		status = newSessionStatus(session, p.CookieOptions.Expire, p.CookieOptions.Refresh)
+		code = http.StatusOK
+	}
+
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(status); err != nil {
+		logger.Errorf("Error encoding session status: %v", err)
+	}
+}
+
+// forceSessionRefresh makes the stored session loader refresh the session of
+// POST requests, regardless of its age, under the same lock as the refreshes
+// of proxied requests.
+// Other methods get a 405 Method Not Allowed response, so that sessions are
+// not refreshed by requests that may be cross-site.
+func (p *OAuthProxy) forceSessionRefresh(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			p.errorJSON(rw, http.StatusMethodNotAllowed)
+			return
+		}
+		middlewareapi.GetRequestScope(req).ForceSessionRefresh = true
+		next.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionStatus(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-30 * time.Minute)
+	expiresOn := now.Add(5 * time.Minute)
+	expiredOn := now.Add(-5 * time.Minute)
+
+	seconds := func(d time.Duration) *int64 {
+		s := int64(d / time.Second)
+		return &s
+	}
+	at := func(t time.Time) *time.Time { return &t }
+
+	testCases := []struct {
+		name           string
+		session        *sessions.SessionState
+		refresh        time.Duration
+		expectedStatus *sessionStatus
+	}{
+		{
+			name:    "with an access token expiry and refresh period",
+			session: &sessions.SessionState{User: "john.doe", Email: "john.doe@example.com", AccessToken: []byte("access"), CreatedAt: &createdAt, ExpiresOn: &expiresOn},
+			refresh: time.Hour,
+			expectedStatus: &sessionStatus{
+				Authenticated:        true,
+				User:                 "john.doe",
+				Email:                "john.doe@example.com",
+				ExpiresAt:            at(createdAt.Add(168 * time.Hour)),
+				ExpiresIn:            seconds(168*time.Hour - 30*time.Minute),
+				AccessTokenExpiresAt: at(expiresOn),
+				AccessTokenExpiresIn: seconds(5 * time.Minute),
+				RefreshAt:            at(createdAt.Add(time.Hour)),
+				RefreshIn:            seconds(30 * time.Minute),
+			},
+		},
+		{
+			name:    "without a refresh period",
+			session: &sessions.SessionState{User: "john.doe", CreatedAt: &createdAt},
+			expectedStatus: &sessionStatus{
+				Authenticated: true,
+				User:          "john.doe",
+				ExpiresAt:     at(createdAt.Add(168 * time.Hour)),
+				ExpiresIn:     seconds(168*time.Hour - 30*time.Minute),
+			},
+		},
+		{
+			name:    "with a past refresh and access token expiry",
+			session: &sessions.SessionState{User: "john.doe", CreatedAt: &createdAt, ExpiresOn: &expiredOn},
+			refresh: time.Minute,
+			expectedStatus: &sessionStatus{
+				Authenticated:        true,
+				User:                 "john.doe",
+				ExpiresAt:            at(createdAt.Add(168 * time.Hour)),
+				ExpiresIn:            seconds(168*time.Hour - 30*time.Minute),
+				AccessTokenExpiresAt: at(expiredOn),
+				AccessTokenExpiresIn: seconds(0),
+				RefreshAt:            at(createdAt.Add(time.Minute)),
+				RefreshIn:            seconds(0),
+			},
+		},
+		{
+			name:    "without a creation time",
+			session: &sessions.SessionState{User: "john.doe"},
+			refresh: time.Hour,
+			expectedStatus: &sessionStatus{
+				Authenticated: true,
+				User:          "john.doe",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.session.Clock.Set(now)
+			assert.Equal(t, tc.expectedStatus, newSessionStatus(tc.session, 168*time.Hour, tc.refresh))
+		})
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Cookie.Refresh = time.Hour
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/session", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"authenticated\":false}\n", rw.Body.String())
+
+	createdAt := time.Now()
+	expiresOn := createdAt.Add(10 * time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/session", nil)
+	rw = httptest.NewRecorder()
+	require.NoError(t, proxy.sessionStore.Save(rw, req, &sessions.SessionState{
+		User:         "john.doe",
+		Email:        "john.doe@example.com",
+		AccessToken:  []byte("my_access_token"),
+		RefreshToken: []byte("my_refresh_token"),
+		CreatedAt:    &createdAt,
+		ExpiresOn:    &expiresOn,
+	}))
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotContains(t, rw.Body.String(), "my_access_token")
+	assert.NotContains(t, rw.Body.String(), "my_refresh_token")
+
+	var status sessionStatus
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.True(t, status.Authenticated)
+	assert.Equal(t, "john.doe@example.com", status.Email)
+	assert.Equal(t, expiresOn.Unix(), status.AccessTokenExpiresAt.Unix())
+	assert.Equal(t, createdAt.Add(time.Hour).Unix(), status.RefreshAt.Unix())
+	assert.Equal(t, createdAt.Add(opts.Cookie.Expire).Unix(), status.ExpiresAt.Unix())
+}
+
+func TestRefreshSession(t *testing.T) {
+	validateServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(validateServer.Close)
+
+	opts := baseTestOptions()
+	opts.Providers[0].Type = options.GitHubProvider
+	opts.Providers[0].ValidateURL = validateServer.URL
+	opts.Cookie.Refresh = time.Hour
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	createdAt := time.Now().Add(-10 * time.Minute)
+	rw := httptest.NewRecorder()
+	require.NoError(t, proxy.sessionStore.Save(rw, httptest.NewRequest(http.MethodPost, "/oauth2/refresh", nil), &sessions.SessionState{
+		Email:       "john.doe@example.com",
+		AccessToken: []byte("my_access_token"),
+		CreatedAt:   &createdAt,
+	}))
+	sessionCookies := rw.Result().Cookies()
+
+	refresh := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/oauth2/refresh", nil)
+		for _, c := range sessionCookies {
+			req.AddCookie(c)
+		}
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw = refresh(http.MethodGet)
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+	assert.Equal(t, http.MethodPost, rw.Header().Get("Allow"))
+
+	rw = refresh(http.MethodPost)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Len(t, rw.Result().Cookies(), 1)
+
+	var status sessionStatus
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.True(t, status.Authenticated)
+	require.True(t, status.RefreshAt != nil)
+	assert.True(t, status.RefreshAt.After(createdAt.Add(time.Hour)))
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/oauth2/refresh", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
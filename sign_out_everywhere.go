@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/errcode"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// signOutEverywhere signs users out of all of their sessions, on every
+// device, with the sessions of each user indexed in the session store.
+type signOutEverywhere struct {
+	// store clears all of the sessions of a user.
+	store sessionsapi.UserSessionStore
+
+	// revokeRefreshTokens revokes the refresh tokens of the cleared sessions
+	// with the provider.
+	revokeRefreshTokens bool
+
+	// secret signs the tokens of the sign-out forms. It is the cookie secret
+	// of the options, which is wiped once the configuration is replaced.
+	secret []byte
+}
+
+// buildSignOutEverywhere builds the sign out everywhere from the options, or
+// nil when users cannot sign out everywhere.
+func buildSignOutEverywhere(opts *options.Options, sessionStore sessionsapi.SessionStore) (*signOutEverywhere, error) {
+	if !opts.Session.SignOutEverywhere {
+		return nil, nil
+	}
+
+	store, ok := sessionStore.(sessionsapi.UserSessionStore)
+	if !ok {
+		return nil, errors.New("the session store does not index the sessions of users")
+	}
+	return &signOutEverywhere{
+		store:               store,
+		revokeRefreshTokens: opts.Session.RevokeRefreshTokens,
+		secret:              opts.Cookie.Secret,
+	}, nil
+}
+
+// token returns the token of the sign-out form of the user, so that they
+// cannot be signed out everywhere by a cross-site request.
+// NOTE: Error checking (G104) is purposefully skipped:
+// `hash.Hash` interface's `Write` has an error signature, but
+// `hmac.hmac.Write` does not use it.
+/* #nosec G104 */
+func (s *signOutEverywhere) token(session *sessionsapi.SessionState) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte("sign_out_everywhere|" + sessionUser(session)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// SignOutEverywhere serves the sign-out page, and signs the user out of all
+// of their sessions, on every device, when its form is submitted.
+func (p *OAuthProxy) SignOutEverywhere(rw http.ResponseWriter, req *http.Request) {
+	if p.signOutEverywhere == nil {
+		p.ErrorPage(rw, req, http.StatusNotFound, "signing out everywhere is not enabled")
+		return
+	}
+
+	redirect, err := p.appDirector.GetRedirect(req)
+	if err != nil {
+		errcode.Record(req, errcode.InvalidRedirect)
+		logger.Errorf("Error obtaining redirect: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	session := middleware.GetRequestScope(req).Session
+	if session == nil {
+		http.Redirect(rw, req, redirect, http.StatusFound)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		p.pageWriter.WriteSignOutPage(rw, req, pagewriter.SignOutPageOpts{
+			User:        sessionUser(session),
+			RedirectURL: redirect,
+			Token:       p.signOutEverywhere.token(session),
+		})
+	case http.MethodPost:
+		if !hmac.Equal([]byte(req.PostForm.Get("token")), []byte(p.signOutEverywhere.token(session))) {
+			p.ErrorPage(rw, req, http.StatusForbidden, "invalid sign out token")
+			return
+		}
+
+		cleared, err := p.signOutEverywhere.store.ClearUserSessions(req.Context(), session)
+		if err != nil {
+			logger.Errorf("Error clearing the sessions of %s: %v", sessionUser(session), err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := p.ClearSessionCookie(rw, req); err != nil {
+			logger.Errorf("Error clearing session cookie: %v", err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if p.signOutEverywhere.revokeRefreshTokens {
+			p.revokeRefreshTokens(req, cleared)
+		}
+		logger.PrintSecurityEventf(session.Email, req, logger.AuthSuccess, securityEvent(logger.EventLogout, "", session),
+			"Signed out everywhere of %d sessions: %s", len(cleared), session)
+
+		if logoutURL := p.provider.Data().GetLogoutURL(session, p.getAbsoluteURL(req, redirect)); logoutURL != "" {
+			redirect = logoutURL
+		}
+		http.Redirect(rw, req, redirect, http.StatusFound)
+	default:
+		p.ErrorPage(rw, req, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// revokeRefreshTokens revokes the refresh tokens of the sessions with the
+// provider, with the provider client. The sessions are already cleared, so
+// errors are only logged.
+func (p *OAuthProxy) revokeRefreshTokens(req *http.Request, sessions []*sessionsapi.SessionState) {
+	ctx := p.providerContext(req.Context(), requests.ProviderEndpointRevoke)
+	revoked := map[string]bool{}
+	for _, session := range sessions {
+		if len(session.RefreshToken) == 0 || revoked[string(session.RefreshToken)] {
+			continue
+		}
+		revoked[string(session.RefreshToken)] = true
+		if err := p.provider.Data().RevokeRefreshToken(ctx, string(session.RefreshToken)); err != nil {
+			logger.Errorf("Error revoking a refresh token of %s: %v", sessionUser(session), err)
+		}
+	}
+}
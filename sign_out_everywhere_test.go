@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignOutEverywhere(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	revoked := []string{}
+	revokeServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		revoked = append(revoked, req.FormValue("token"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(revokeServer.Close)
+
+	opts := baseTestOptions()
+	opts.Session.Type = options.RedisSessionStoreType
+	opts.Session.Redis.ConnectionURL = "redis://" + mr.Addr()
+	opts.Session.SignOutEverywhere = true
+	opts.Session.RevokeRefreshTokens = true
+	opts.Providers[0].RevokeURL = revokeServer.URL
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	// signIn saves a session on a new device, returning its cookies
+	signIn := func(session *sessions.SessionState) []*http.Cookie {
+		rw := httptest.NewRecorder()
+		require.NoError(t, proxy.sessionStore.Save(rw, httptest.NewRequest(http.MethodGet, "/", nil), session))
+		return rw.Result().Cookies()
+	}
+	serve := func(req *http.Request, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		return rw
+	}
+	signOutForm := func(token string) *http.Request {
+		form := url.Values{"token": {token}, "rd": {"/app"}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/sign_out_everywhere", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	laptopSession := &sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("access"), RefreshToken: []byte("laptop")}
+	laptop := signIn(laptopSession)
+	phone := signIn(&sessions.SessionState{Email: "john.doe@example.com", AccessToken: []byte("access"), RefreshToken: []byte("phone")})
+	other := signIn(&sessions.SessionState{Email: "jane.doe@example.com", AccessToken: []byte("access"), RefreshToken: []byte("other")})
+
+	rw := serve(httptest.NewRequest(http.MethodGet, "/oauth2/sign_out_everywhere?rd=/app", nil), laptop)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	token := proxy.signOutEverywhere.token(laptopSession)
+	assert.Contains(t, rw.Body.String(), `<input type="hidden" name="token" value="`+token+`">`)
+
+	rw = serve(signOutForm("invalid"), laptop)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "/oauth2/session", nil), phone).Code)
+
+	rw = serve(signOutForm(token), laptop)
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/app", rw.Header().Get("Location"))
+	sort.Strings(revoked)
+	assert.Equal(t, []string{"laptop", "phone"}, revoked)
+
+	assert.Equal(t, http.StatusUnauthorized, serve(httptest.NewRequest(http.MethodGet, "/oauth2/session", nil), laptop).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(httptest.NewRequest(http.MethodGet, "/oauth2/session", nil), phone).Code)
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "/oauth2/session", nil), other).Code)
+}
+
+func TestSignOutEverywhereNotEnabled(t *testing.T) {
+	opts := baseTestOptions()
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/sign_out_everywhere", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
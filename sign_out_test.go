@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignOutRPInitiatedLogout(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Providers[0].RPInitiatedLogout = true
+	opts.Providers[0].LogoutURL = "https://my.test.idp/logout"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out?rd=/app", nil)
+	require.NoError(t, proxy.sessionStore.Save(rw, req, &sessions.SessionState{
+		Email:   "john.doe@example.com",
+		IDToken: []byte("my_id_token"),
+	}))
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	location, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "my.test.idp", location.Host)
+	assert.Equal(t, "/logout", location.Path)
+	assert.Equal(t, clientID, location.Query().Get("client_id"))
+	assert.Equal(t, "my_id_token", location.Query().Get("id_token_hint"))
+	assert.Equal(t, "https://example.com/app", location.Query().Get("post_logout_redirect_uri"))
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/sign_out?rd=/app", nil))
+	assert.Equal(t, http.StatusFound, rw.Code)
+	location, err = url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "", location.Query().Get("id_token_hint"))
+	assert.Equal(t, "https://example.com/app", location.Query().Get("post_logout_redirect_uri"))
+}
+
+func TestSignOutWithoutRPInitiatedLogout(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Providers[0].LogoutURL = "https://my.test.idp/logout"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/sign_out?rd=/app", nil))
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/app", rw.Header().Get("Location"))
+}
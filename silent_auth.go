@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// silentAuthMessageType is the type of the messages posted with the
+	// result of silent authentications.
+	silentAuthMessageType = "oauth2-proxy:silent-auth"
+
+	// silentAuthAccessDenied is the error posted when the user signed in
+	// silently but is not allowed a session.
+	silentAuthAccessDenied = "access_denied"
+)
+
+// silentAuthResultTemplate posts the result of a silent authentication to the
+// window of the single-page app that opened it, in a hidden iframe or a popup.
+var silentAuthResultTemplate = template.Must(template.New("silent_auth").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Silent authentication</title></head>
+<body>
+<script nonce="{{.Nonce}}">
+  (window.opener || window.parent).postMessage({type: {{.Type}}, error: {{.Error}}}, {{.Origin}});
+</script>
+</body>
+</html>
+`))
+
+// buildSilentAuthOrigins builds the set of origins allowed to authenticate
+// silently.
+func buildSilentAuthOrigins(origins []string) map[string]bool {
+	set := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		set[origin] = true
+	}
+	return set
+}
+
+// SilentAuth starts a silent authentication, with `prompt=none`, for a
+// single-page app that loads it in a hidden iframe or a popup.
+// Rather than redirecting, the callback posts the result to the window of the
+// app, so that sessions can be re-established without a visible redirect.
+func (p *OAuthProxy) SilentAuth(rw http.ResponseWriter, req *http.Request) {
+	origin := req.URL.Query().Get("origin")
+	if !p.silentAuthOrigins[origin] {
+		logger.Errorf("Origin %q is not allowed to authenticate silently", origin)
+		p.ErrorPage(rw, req, http.StatusForbidden, fmt.Sprintf("origin %q is not allowed to authenticate silently", origin))
+		return
+	}
+
+	p.doOAuthStart(rw, req, nil, origin)
+}
+
+// silentAuthError posts the error returned by the provider to the callback to
+// the single-page app, when the authentication is silent, eg
+// `login_required` when the user must sign in interactively.
+// It returns whether the error was handled.
+func (p *OAuthProxy) silentAuthError(rw http.ResponseWriter, req *http.Request, errorString string) bool {
+	csrf, err := cookies.LoadCSRFCookie(req, p.CookieOptions)
+	if err != nil || csrf.GetSilentOrigin() == "" {
+		return false
+	}
+
+	// Only the provider the silent authentication was started with can
+	// report its error
+	nonce, _, err := decodeState(req)
+	if err != nil || !csrf.CheckOAuthState(nonce) {
+		return false
+	}
+
+	csrf.ClearCookie(rw, req)
+	logger.Printf("Silent authentication failed: %s", errorString)
+	writeSilentAuthResult(rw, csrf.GetSilentOrigin(), errorString)
+	return true
+}
+
+// writeSilentAuthResult writes the page posting the result of a silent
+// authentication to the origin: a null error when the user was signed in, or
+// the OAuth2 error code otherwise.
+// The page may only be framed by the origin.
+func writeSilentAuthResult(rw http.ResponseWriter, origin, errorString string) {
+	nonceBytes, err := encryption.Nonce(16)
+	if err != nil {
+		logger.Errorf("Error generating silent authentication nonce: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(nonceBytes)
+
+	var errorValue *string
+	if errorString != "" {
+		errorValue = &errorString
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Content-Security-Policy", fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'; frame-ancestors %s", nonce, origin))
+	rw.WriteHeader(http.StatusOK)
+
+	err = silentAuthResultTemplate.Execute(rw, struct {
+		Nonce  string
+		Type   string
+		Error  *string
+		Origin string
+	}{
+		Nonce:  nonce,
+		Type:   silentAuthMessageType,
+		Error:  errorValue,
+		Origin: origin,
+	})
+	if err != nil {
+		logger.Errorf("Error rendering silent authentication result: %v", err)
+	}
+}
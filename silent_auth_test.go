@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSilentAuthOrigin = "https://app.example.com"
+
+func TestSilentAuthStart(t *testing.T) {
+	opts := baseTestOptions()
+	opts.SilentAuthOrigins = []string{testSilentAuthOrigin}
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/silent?origin=https://evil.example.com", nil))
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/silent?origin="+url.QueryEscape(testSilentAuthOrigin), nil))
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	loginURL, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "none", loginURL.Query().Get("prompt"))
+	assert.Equal(t, "", loginURL.Query().Get("approval_prompt"))
+	assert.Len(t, rw.Result().Cookies(), 1)
+}
+
+func TestSilentAuthCallback(t *testing.T) {
+	patTest, err := NewPassAccessTokenTest(PassAccessTokenTestOptions{ValidToken: true})
+	require.NoError(t, err)
+	t.Cleanup(patTest.Close)
+
+	callback := func(query string) *httptest.ResponseRecorder {
+		csrf, err := cookies.NewCSRF(patTest.proxy.CookieOptions, "")
+		require.NoError(t, err)
+		csrf.SetSilentOrigin(testSilentAuthOrigin)
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth2/callback?%s&state=%s", query, encodeState(csrf.HashOAuthState(), "%2F")), nil)
+		csrfCookie, err := csrf.SetCookie(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		req.AddCookie(csrfCookie)
+
+		rw := httptest.NewRecorder()
+		patTest.proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("posts the success of the authentication", func(t *testing.T) {
+		rw := callback("code=callback_code")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Header().Get("Content-Security-Policy"), "frame-ancestors "+testSilentAuthOrigin)
+		assert.Contains(t, rw.Body.String(), `error:  null }, "https://app.example.com");`)
+
+		var session *http.Cookie
+		for _, c := range rw.Result().Cookies() {
+			if c.Name == patTest.proxy.CookieOptions.Name {
+				session = c
+			}
+		}
+		assert.NotNil(t, session)
+	})
+
+	t.Run("posts the error of the provider", func(t *testing.T) {
+		rw := callback("error=login_required")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `error: "login_required"}, "https://app.example.com");`)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// tenantProxy serves the requests to the hosts of a tenant.
+type tenantProxy struct {
+	hosts []string
+	proxy *OAuthProxy
+}
+
+// tenantFor returns the OAuthProxy of the tenant serving the host of the
+// request, or nil when the request should be served by the main
+// configuration.
+// Exact host matches take precedence over wildcard matches.
+func (p *OAuthProxy) tenantFor(req *http.Request) *OAuthProxy {
+	if len(p.tenants) == 0 {
+		return nil
+	}
+
+	// The request scope has not been set up yet so the forwarded host is
+	// read directly
+	host := req.Host
+	if forwardedHost := req.Header.Get(requestutil.XForwardedHost); p.reverseProxy && forwardedHost != "" {
+		host = forwardedHost
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for _, tenant := range p.tenants {
+		for _, pattern := range tenant.hosts {
+			if strings.ToLower(pattern) == host {
+				return tenant.proxy
+			}
+		}
+	}
+	for _, tenant := range p.tenants {
+		for _, pattern := range tenant.hosts {
+			if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, strings.ToLower(pattern[1:])) {
+				return tenant.proxy
+			}
+		}
+	}
+	return nil
+}
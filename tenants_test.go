@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenants(t *testing.T) {
+	staticUpstream := func(code int) *options.UpstreamConfig {
+		return &options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:         "static",
+					Path:       "/",
+					Static:     true,
+					StaticCode: &code,
+				},
+			},
+		}
+	}
+
+	opts := baseTestOptions()
+	opts.SkipAuthRegex = []string{".*"}
+	opts.UpstreamServers = *staticUpstream(http.StatusOK)
+	opts.Tenants = []options.Tenant{
+		{
+			ID:             "app",
+			Hosts:          []string{"app.example.com"},
+			UpstreamConfig: staticUpstream(http.StatusAccepted),
+		},
+		{
+			ID:             "preview",
+			Hosts:          []string{"*.preview.example.com"},
+			UpstreamConfig: staticUpstream(http.StatusCreated),
+		},
+	}
+	require.NoError(t, validation.Validate(opts))
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		host           string
+		forwardedHost  string
+		expectedStatus int
+	}{
+		"main configuration": {
+			host:           "example.com",
+			expectedStatus: http.StatusOK,
+		},
+		"exact host": {
+			host:           "App.example.com:8443",
+			expectedStatus: http.StatusAccepted,
+		},
+		"wildcard host": {
+			host:           "pr-1.preview.example.com",
+			expectedStatus: http.StatusCreated,
+		},
+		"wildcard parent domain": {
+			host:           "preview.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		"forwarded host without reverse proxy": {
+			host:           "example.com",
+			forwardedHost:  "app.example.com",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tc.host
+			if tc.forwardedHost != "" {
+				req.Header.Set("X-Forwarded-Host", tc.forwardedHost)
+			}
+			rw := httptest.NewRecorder()
+			proxy.ServeHTTP(rw, req)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+)
+
+// buildUserInfo builds the response of the UserInfo endpoint for the session,
+// with the fields of the userInfo options.
+func buildUserInfo(ctx context.Context, opts *options.UserInfo, session *sessionsapi.SessionState) map[string]interface{} {
+	userInfo := map[string]interface{}{}
+
+	var idTokenClaims util.ClaimExtractor
+	if len(session.IDToken) > 0 {
+		// The ID token was verified when it was redeemed, and without a
+		// profile URL no request is made for missing claims
+		idTokenClaims, _ = util.NewClaimExtractor(ctx, string(session.IDToken), nil, nil)
+	}
+
+	for _, field := range opts.Fields {
+		claim := field.Claim
+		if claim == "" {
+			claim = field.Name
+		}
+		if value, ok := userInfoClaim(claim, session, idTokenClaims); ok {
+			setUserInfoField(userInfo, strings.Split(field.Name, "."), value)
+		}
+	}
+	if opts.Groups {
+		userInfo["groups"] = sessionGroups(session)
+	}
+	return userInfo
+}
+
+// userInfoClaim returns the value of a claim of the session, or of its ID
+// token, and whether the claim has a value.
+func userInfoClaim(claim string, session *sessionsapi.SessionState, idTokenClaims util.ClaimExtractor) (interface{}, bool) {
+	switch claim {
+	case "user":
+		return session.User, session.User != ""
+	case "email":
+		return session.Email, session.Email != ""
+	case "preferred_username":
+		return session.PreferredUsername, session.PreferredUsername != ""
+	case "groups":
+		return sessionGroups(session), true
+	}
+
+	if idTokenClaims == nil {
+		return nil, false
+	}
+	value, ok, err := idTokenClaims.GetClaim(claim)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return value, true
+}
+
+// sessionGroups returns the groups of the session, as an empty rather than a
+// nil list when there are none, so that it is encoded as [] rather than null.
+func sessionGroups(session *sessionsapi.SessionState) []string {
+	if session.Groups == nil {
+		return []string{}
+	}
+	return session.Groups
+}
+
+// setUserInfoField sets the value at the path of a field, creating the objects
+// it is nested in.
+func setUserInfoField(userInfo map[string]interface{}, path []string, value interface{}) {
+	for _, name := range path[:len(path)-1] {
+		nested, ok := userInfo[name].(map[string]interface{})
+		if !ok {
+			nested = map[string]interface{}{}
+			userInfo[name] = nested
+		}
+		userInfo = nested
+	}
+	userInfo[path[len(path)-1]] = value
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildUserInfo(t *testing.T) {
+	idToken := "eyJhbGciOiJub25lIn0." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"name":"John Doe","email":"john@idp.example.com","address":{"country":"UK"},"roles":["admin"],"age":42}`)) +
+		".signature"
+
+	testCases := []struct {
+		name             string
+		userInfo         *options.UserInfo
+		session          *sessions.SessionState
+		expectedUserInfo map[string]interface{}
+	}{
+		{
+			name: "renamed session fields",
+			userInfo: &options.UserInfo{
+				Fields: []options.UserInfoField{
+					{Name: "id", Claim: "user"},
+					{Name: "email"},
+					{Name: "username", Claim: "preferred_username"},
+				},
+			},
+			session: &sessions.SessionState{User: "john.doe", Email: "john.doe@example.com", Groups: []string{"users"}},
+			expectedUserInfo: map[string]interface{}{
+				"id":    "john.doe",
+				"email": "john.doe@example.com",
+			},
+		},
+		{
+			name: "nested ID token claims",
+			userInfo: &options.UserInfo{
+				Fields: []options.UserInfoField{
+					{Name: "profile.name", Claim: "name"},
+					{Name: "profile.country", Claim: "address.country"},
+					{Name: "profile.age", Claim: "age"},
+					{Name: "roles"},
+					{Name: "missing"},
+				},
+			},
+			session: &sessions.SessionState{User: "john.doe", IDToken: []byte(idToken)},
+			expectedUserInfo: map[string]interface{}{
+				"profile": map[string]interface{}{
+					"name":    "John Doe",
+					"country": "UK",
+					"age":     json.Number("42"),
+				},
+				"roles": []interface{}{"admin"},
+			},
+		},
+		{
+			name: "groups without groups",
+			userInfo: &options.UserInfo{
+				Fields: []options.UserInfoField{{Name: "email"}},
+				Groups: true,
+			},
+			session: &sessions.SessionState{Email: "john.doe@example.com"},
+			expectedUserInfo: map[string]interface{}{
+				"email":  "john.doe@example.com",
+				"groups": []string{},
+			},
+		},
+		{
+			name: "session fields take precedence over the ID token",
+			userInfo: &options.UserInfo{
+				Fields: []options.UserInfoField{{Name: "email"}},
+			},
+			session: &sessions.SessionState{Email: "john.doe@example.com", IDToken: []byte(idToken)},
+			expectedUserInfo: map[string]interface{}{
+				"email": "john.doe@example.com",
+			},
+		},
+		{
+			name: "invalid ID token",
+			userInfo: &options.UserInfo{
+				Fields: []options.UserInfoField{{Name: "name"}},
+			},
+			session:          &sessions.SessionState{IDToken: []byte("not-a-jwt")},
+			expectedUserInfo: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedUserInfo, buildUserInfo(context.Background(), tc.userInfo, tc.session))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	"github.com/spf13/pflag"
+)
+
+// validateCommand is the name of the command that validates the
+// configuration without starting the proxy.
+const validateCommand = "validate"
+
+// validateResult is the output of the validate command.
+type validateResult struct {
+	Valid bool `json:"valid"`
+	*validation.Report
+}
+
+// runValidate loads and validates the configuration given by the arguments,
+// prints the errors and warnings found and returns the exit code.
+// Checks that connect to external services, such as OIDC discovery and the
+// Redis session store, are only made with --check-connectivity so that the
+// configuration can be checked in CI before it is deployed.
+func runValidate(args []string, stdout io.Writer) int {
+	flagSet := pflag.NewFlagSet("oauth2-proxy validate", pflag.ContinueOnError)
+	flagSet.ParseErrorsWhitelist.UnknownFlags = true
+
+	config := flagSet.String("config", "", "path to config file")
+	alphaConfig := flagSet.String("alpha-config", "", "path to alpha config file")
+	checkConnectivity := flagSet.Bool("check-connectivity", false, "also check the connection to the session store and the OIDC discovery document of each provider")
+	output := flagSet.String("output", "text", "output format: text or json")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(stdout, "invalid arguments: %v\n", err)
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stdout, "invalid output format %q: must be text or json\n", *output)
+		return 2
+	}
+
+	// Logs must not be mixed with the output
+	logger.SetOutput(os.Stderr)
+
+	report := &validation.Report{}
+	opts, err := loadConfiguration(*config, *alphaConfig, flagSet, args)
+	if err == nil {
+		err = resolveSecrets(opts)
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, validation.Issue{Message: err.Error()})
+	} else {
+		report = validation.Check(opts, *checkConnectivity)
+		if *checkConnectivity && report.Valid() {
+			report.Errors = append(report.Errors, checkProviders("", opts)...)
+			for i, tenantOpts := range opts.GetTenantOptions() {
+				report.Errors = append(report.Errors, checkProviders(fmt.Sprintf("tenants[%s].", opts.Tenants[i].ID), tenantOpts)...)
+			}
+		}
+	}
+
+	result := validateResult{Valid: report.Valid(), Report: report}
+	if *output == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stdout, "could not marshal result: %v\n", err)
+			return 2
+		}
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		printValidateResult(stdout, result)
+	}
+
+	if !result.Valid {
+		return 1
+	}
+	return 0
+}
+
+// checkProviders builds each provider, which fetches the discovery document
+// of OIDC providers, reporting any that cannot be built.
+// The documents are fetched with the provider client of the proxy, so that its
+// timeouts and limits apply.
+func checkProviders(pathPrefix string, opts *options.Options) []validation.Issue {
+	issues := []validation.Issue{}
+	providerClient := newProviderClient(opts)
+	for _, provider := range opts.Providers {
+		p, err := providers.NewProvider(provider, providerClient)
+		if err != nil {
+			issues = append(issues, validation.Issue{
+				Path:    fmt.Sprintf("%sproviders[%s]", pathPrefix, provider.ID),
+				Message: fmt.Sprintf("could not initialise provider: %v", err),
+			})
+			continue
+		}
+		p.Data().Close()
+	}
+	return issues
+}
+
+// printValidateResult prints the result in a human readable form.
+func printValidateResult(w io.Writer, result validateResult) {
+	for _, issue := range result.Errors {
+		fmt.Fprintf(w, "ERROR: %s\n", issue)
+	}
+	for _, issue := range result.Warnings {
+		fmt.Fprintf(w, "WARNING: %s\n", issue)
+	}
+
+	if result.Valid {
+		fmt.Fprintf(w, "Configuration is valid (%d warnings)\n", len(result.Warnings))
+	} else {
+		fmt.Fprintf(w, "Configuration is invalid (%d errors, %d warnings)\n", len(result.Errors), len(result.Warnings))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validateTestConfig = `
+cookie_secret="OQINaROshtE9TcZkNAm-5Zs2Pv3xaWytBmc5W7sPX7w="
+email_domains="example.com"
+upstreams="http://httpbin"
+client_id="oauth2-proxy"
+client_secret="b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK"
+redirect_url="http://localhost:4180/oauth2/callback"
+`
+
+func writeValidateTestConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "oauth2-proxy.cfg")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestValidateCommand(t *testing.T) {
+	testCases := map[string]struct {
+		config           string
+		args             []string
+		expectedCode     int
+		expectedErrors   int
+		expectedPaths    []string
+		expectedWarnings int
+	}{
+		"valid configuration": {
+			config:       validateTestConfig,
+			expectedCode: 0,
+		},
+		"invalid configuration": {
+			config:         validateTestConfig + "auth_cache_ttl=\"-1s\"\ntrusted_ips=\"not-an-ip\"\n",
+			expectedCode:   1,
+			expectedErrors: 2,
+			expectedPaths:  []string{"auth_cache_ttl", "trusted_ips"},
+		},
+		"configuration with warnings": {
+			config:           validateTestConfig + "signature_key=\"sha256:secret\"\n",
+			expectedCode:     0,
+			expectedWarnings: 1,
+		},
+		"configuration that cannot be loaded": {
+			config:         validateTestConfig + "unknown_field=\"something\"\n",
+			expectedCode:   1,
+			expectedErrors: 1,
+			expectedPaths:  []string{""},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"--config", writeValidateTestConfig(t, tc.config), "--output", "json"}, tc.args...)
+			out := &bytes.Buffer{}
+			assert.Equal(t, tc.expectedCode, runValidate(args, out))
+
+			result := struct {
+				Valid    bool
+				Errors   []struct{ Path, Message string }
+				Warnings []struct{ Path, Message string }
+			}{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+			assert.Equal(t, tc.expectedCode == 0, result.Valid)
+			assert.Len(t, result.Errors, tc.expectedErrors)
+			assert.Len(t, result.Warnings, tc.expectedWarnings)
+			for i, path := range tc.expectedPaths {
+				assert.Equal(t, path, result.Errors[i].Path)
+			}
+		})
+	}
+}
+
+func TestValidateCommandTextOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	args := []string{"--config", writeValidateTestConfig(t, validateTestConfig+"auth_cache_ttl=\"-1s\"\n")}
+	assert.Equal(t, 1, runValidate(args, out))
+	assert.Equal(t, "ERROR: auth_cache_ttl: auth_cache_ttl must not be negative\nConfiguration is invalid (1 errors, 0 warnings)\n", out.String())
+}
+
+func TestValidateCommandChecksProvidersWithTheProviderClient(t *testing.T) {
+	// The discovery document is served after the request timeout of the
+	// provider client
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	config := validateTestConfig + fmt.Sprintf("provider=\"oidc\"\noidc_issuer_url=%q\nprovider_request_timeout=\"100ms\"\n", server.URL)
+	args := []string{"--config", writeValidateTestConfig(t, config), "--output", "json", "--check-connectivity"}
+	out := &bytes.Buffer{}
+	assert.Equal(t, 1, runValidate(args, out))
+
+	result := struct {
+		Errors []struct{ Path, Message string }
+	}{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Len(t, result.Errors, 1)
+	assert.True(t, strings.HasPrefix(result.Errors[0].Path, "providers["))
+	assert.Contains(t, result.Errors[0].Message, "Client.Timeout exceeded")
+}